@@ -0,0 +1,216 @@
+// Package audio определяет формат аудио и конвертирует его между форматами мессенджеров
+// (голосовые Telegram и WhatsApp — OGG/Opus) и форматами провайдеров (MP3, WAV).
+// Конвертация выполняется через ffmpeg; если его нет, Convert возвращает ErrNoConverter,
+// а ForTranscription отдаёт аудио без изменений.
+package audio
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/ikermy/AiR_Common/pkg/mode"
+)
+
+// Format формат аудиофайла, совпадает с расширением
+type Format string
+
+const (
+	OGG     Format = "ogg" // Opus или Vorbis в контейнере Ogg (голосовые Telegram, WhatsApp)
+	MP3     Format = "mp3"
+	WAV     Format = "wav"
+	FLAC    Format = "flac"
+	M4A     Format = "m4a"
+	WebM    Format = "webm"
+	AMR     Format = "amr"
+	CAF     Format = "caf"
+	Unknown Format = ""
+)
+
+// ErrNoConverter ffmpeg не найден (mode.FFmpegPath)
+var ErrNoConverter = errors.New("ffmpeg не найден, конвертация аудио недоступна")
+
+var mimeTypes = map[Format]string{
+	OGG:  "audio/ogg",
+	MP3:  "audio/mpeg",
+	WAV:  "audio/wav",
+	FLAC: "audio/flac",
+	M4A:  "audio/mp4",
+	WebM: "audio/webm",
+	AMR:  "audio/amr",
+	CAF:  "audio/x-caf",
+}
+
+// transcribable форматы, которые принимают все провайдеры распознавания речи
+var transcribable = map[Format]bool{OGG: true, MP3: true, WAV: true, FLAC: true, M4A: true}
+
+// MimeType MIME-тип формата, для неизвестного — application/octet-stream
+func (f Format) MimeType() string {
+	if mt, ok := mimeTypes[f]; ok {
+		return mt
+	}
+	return "application/octet-stream"
+}
+
+// Detect определяет формат по сигнатуре, а если она не распознана — по расширению name
+func Detect(data []byte, name string) Format {
+	switch {
+	case bytes.HasPrefix(data, []byte("OggS")):
+		return OGG
+	case len(data) >= 12 && bytes.HasPrefix(data, []byte("RIFF")) && string(data[8:12]) == "WAVE":
+		return WAV
+	case bytes.HasPrefix(data, []byte("ID3")), len(data) >= 2 && data[0] == 0xFF && data[1]&0xE0 == 0xE0 && data[1]&0x06 != 0:
+		return MP3
+	case bytes.HasPrefix(data, []byte("fLaC")):
+		return FLAC
+	case len(data) >= 8 && string(data[4:8]) == "ftyp":
+		return M4A
+	case bytes.HasPrefix(data, []byte{0x1A, 0x45, 0xDF, 0xA3}):
+		return WebM
+	case bytes.HasPrefix(data, []byte("#!AMR")):
+		return AMR
+	case bytes.HasPrefix(data, []byte("caff")):
+		return CAF
+	}
+
+	switch ext := strings.ToLower(strings.TrimPrefix(path.Ext(name), ".")); ext {
+	case "ogg", "oga", "opus":
+		return OGG
+	case "mp3", "mpeg", "mpga":
+		return MP3
+	case "mp4", "aac":
+		return M4A
+	case "wav", "flac", "m4a", "webm", "amr", "caf":
+		return Format(ext)
+	}
+	return Unknown
+}
+
+// Available найден ли ffmpeg
+func Available() bool {
+	_, err := ffmpegPath()
+	return err == nil
+}
+
+var (
+	lookOnce sync.Once
+	lookPath string
+	lookErr  error
+)
+
+func ffmpegPath() (string, error) {
+	lookOnce.Do(func() {
+		lookPath, lookErr = exec.LookPath(mode.FFmpegPath)
+	})
+	if lookErr != nil {
+		return "", ErrNoConverter
+	}
+	return lookPath, nil
+}
+
+// outputArgs параметры кодирования ffmpeg для целевого формата
+var outputArgs = map[Format][]string{
+	OGG:  {"-c:a", "libopus", "-b:a", "32k", "-f", "ogg"}, // голосовое сообщение Telegram
+	MP3:  {"-c:a", "libmp3lame", "-q:a", "4", "-f", "mp3"},
+	WAV:  {"-c:a", "pcm_s16le", "-f", "wav"},
+	FLAC: {"-c:a", "flac", "-f", "flac"},
+}
+
+// Convert перекодирует аудио в формат to через ffmpeg (с тайм-аутом mode.AudioConvertTimeout).
+// Вход пишется во временный файл: контейнер MP4 нельзя прочитать из канала
+func Convert(ctx context.Context, data []byte, to Format) ([]byte, error) {
+	args, ok := outputArgs[to]
+	if !ok {
+		return nil, fmt.Errorf("конвертация в %q не поддерживается", to)
+	}
+	bin, err := ffmpegPath()
+	if err != nil {
+		return nil, err
+	}
+
+	in, err := os.CreateTemp("", "audio-*")
+	if err != nil {
+		return nil, fmt.Errorf("ошибка создания временного файла: %w", err)
+	}
+	defer func() { _ = os.Remove(in.Name()) }()
+	_, err = in.Write(data)
+	if errClose := in.Close(); err == nil {
+		err = errClose
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка записи временного файла: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, mode.AudioConvertTimeout)
+	defer cancel()
+
+	cmdArgs := append([]string{"-hide_banner", "-loglevel", "error", "-i", in.Name(), "-vn"}, args...)
+	cmd := exec.CommandContext(ctx, bin, append(cmdArgs, "pipe:1")...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("ffmpeg: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.Bytes(), nil
+}
+
+// ForTranscription приводит аудио к формату, который принимают все провайдеры распознавания
+// речи: AMR, CAF, WebM и неизвестные форматы перекодируются в MP3. Возвращает данные и имя
+// файла с расширением итогового формата. Без ffmpeg аудио возвращается как есть
+func ForTranscription(ctx context.Context, data []byte, fileName string) ([]byte, string) {
+	format := Detect(data, fileName)
+	if !transcribable[format] {
+		if out, err := Convert(ctx, data, MP3); err == nil {
+			return out, withExt(fileName, MP3)
+		}
+		return data, fileName
+	}
+	if Format(strings.ToLower(strings.TrimPrefix(path.Ext(fileName), "."))) != format {
+		fileName = withExt(fileName, format)
+	}
+	return data, fileName
+}
+
+// ToVoice перекодирует аудио в OGG/Opus для отправки голосовым сообщением
+func ToVoice(ctx context.Context, data []byte) ([]byte, error) {
+	if Detect(data, "") == OGG {
+		return data, nil
+	}
+	return Convert(ctx, data, OGG)
+}
+
+// PCMToWAV оборачивает PCM 16 бит little-endian (аудио Realtime API) в WAV без ffmpeg
+func PCMToWAV(pcm []byte, sampleRate, channels int) []byte {
+	var buf bytes.Buffer
+	buf.Grow(44 + len(pcm))
+	byteRate := sampleRate * channels * 2
+
+	buf.WriteString("RIFF")
+	_ = binary.Write(&buf, binary.LittleEndian, uint32(36+len(pcm)))
+	buf.WriteString("WAVEfmt ")
+	_ = binary.Write(&buf, binary.LittleEndian, uint32(16))         // размер fmt
+	_ = binary.Write(&buf, binary.LittleEndian, uint16(1))          // PCM
+	_ = binary.Write(&buf, binary.LittleEndian, uint16(channels))   // каналы
+	_ = binary.Write(&buf, binary.LittleEndian, uint32(sampleRate)) // частота
+	_ = binary.Write(&buf, binary.LittleEndian, uint32(byteRate))   // байт в секунду
+	_ = binary.Write(&buf, binary.LittleEndian, uint16(channels*2)) // выравнивание блока
+	_ = binary.Write(&buf, binary.LittleEndian, uint16(16))         // бит на отсчёт
+	buf.WriteString("data")
+	_ = binary.Write(&buf, binary.LittleEndian, uint32(len(pcm)))
+	buf.Write(pcm)
+	return buf.Bytes()
+}
+
+func withExt(name string, f Format) string {
+	if name == "" {
+		name = "audio"
+	}
+	return strings.TrimSuffix(name, path.Ext(name)) + "." + string(f)
+}
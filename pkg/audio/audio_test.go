@@ -0,0 +1,64 @@
+package audio
+
+import (
+	"bytes"
+	"context"
+	"testing"
+)
+
+func TestDetect(t *testing.T) {
+	cases := []struct {
+		data []byte
+		name string
+		want Format
+	}{
+		{[]byte("OggS\x00\x02"), "voice", OGG},
+		{[]byte("ID3\x04\x00"), "", MP3},
+		{[]byte{0xFF, 0xFB, 0x90, 0x00}, "", MP3},
+		{[]byte{0xFF, 0xF1, 0x50, 0x80}, "voice.aac", M4A}, // ADTS AAC — не MP3
+		{[]byte("fLaC\x00"), "", FLAC},
+		{[]byte("\x00\x00\x00\x20ftypM4A "), "", M4A},
+		{[]byte("#!AMR\n"), "", AMR},
+		{[]byte("garbage"), "voice.oga", OGG},
+		{[]byte("garbage"), "note.bin", Unknown},
+		{PCMToWAV(make([]byte, 32), 24000, 1), "", WAV},
+	}
+	for _, c := range cases {
+		if got := Detect(c.data, c.name); got != c.want {
+			t.Errorf("Detect(%q, %q) = %q, want %q", c.data, c.name, got, c.want)
+		}
+	}
+}
+
+func TestPCMToWAV(t *testing.T) {
+	pcm := []byte{1, 2, 3, 4}
+	wav := PCMToWAV(pcm, 16000, 1)
+	if len(wav) != 44+len(pcm) || !bytes.HasSuffix(wav, pcm) {
+		t.Fatalf("длина WAV %d", len(wav))
+	}
+	if wav[24] != 0x80 || wav[25] != 0x3E { // 16000 little-endian
+		t.Errorf("частота записана неверно: % x", wav[24:28])
+	}
+}
+
+func TestForTranscription_KeepsSupportedFormats(t *testing.T) {
+	ogg := []byte("OggS\x00\x02 voice")
+	data, name := ForTranscription(context.Background(), ogg, "voice.oga")
+	if !bytes.Equal(data, ogg) || name != "voice.ogg" {
+		t.Errorf("ForTranscription = %q, %q", data, name)
+	}
+}
+
+func TestConvert(t *testing.T) {
+	if !Available() {
+		t.Skip("ffmpeg не установлен")
+	}
+	wav := PCMToWAV(make([]byte, 16000*2), 16000, 1)
+	out, err := Convert(context.Background(), wav, MP3)
+	if err != nil {
+		t.Fatalf("Convert: %v", err)
+	}
+	if Detect(out, "") != MP3 {
+		t.Errorf("результат не MP3: % x", out[:min(len(out), 8)])
+	}
+}
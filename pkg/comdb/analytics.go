@@ -0,0 +1,91 @@
+package comdb
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/ikermy/AiR_Common/pkg/mode"
+)
+
+// AssistantDayStats дневные показатели ассистента: диалоги, достижения цели (Meta "target"),
+// ходы до цели и запросы оператора. Хранится в таблице assistant_stats_daily, счётчики
+// только увеличиваются.
+//
+//	CREATE TABLE assistant_stats_daily (
+//	    user_id           INT UNSIGNED NOT NULL,
+//	    assist_name       VARCHAR(255) NOT NULL,
+//	    day               DATE         NOT NULL,
+//	    dialogs           INT UNSIGNED NOT NULL DEFAULT 0,
+//	    targets           INT UNSIGNED NOT NULL DEFAULT 0,
+//	    target_turns      INT UNSIGNED NOT NULL DEFAULT 0,
+//	    operator_requests INT UNSIGNED NOT NULL DEFAULT 0,
+//	    PRIMARY KEY (user_id, assist_name, day)
+//	);
+type AssistantDayStats struct {
+	UserID           uint32    `json:"user_id"`
+	AssistName       string    `json:"assist_name"`
+	Day              time.Time `json:"day"`
+	Dialogs          int       `json:"dialogs"`
+	Targets          int       `json:"targets"`      // диалогов, достигших цели
+	TargetTurns      int       `json:"target_turns"` // сумма ходов пользователя до цели
+	OperatorRequests int       `json:"operator_requests"`
+
+	// Вычисляются при чтении
+	Conversion    float64 `json:"conversion"`      // Targets / Dialogs, %
+	TurnsToTarget float64 `json:"turns_to_target"` // TargetTurns / Targets
+}
+
+// AddAssistantStats прибавляет счётчики d к показателям ассистента за день d.Day
+func (d *DB) AddAssistantStats(s AssistantDayStats) error {
+	ctx, cancel := context.WithTimeout(d.Context(), mode.SqlTimeToCancel)
+	defer cancel()
+
+	if _, err := d.Conn().ExecContext(ctx,
+		`INSERT INTO assistant_stats_daily (user_id, assist_name, day, dialogs, targets, target_turns, operator_requests)
+		 VALUES (?, ?, ?, ?, ?, ?, ?)
+		 ON DUPLICATE KEY UPDATE dialogs = dialogs + VALUES(dialogs), targets = targets + VALUES(targets),
+		 target_turns = target_turns + VALUES(target_turns), operator_requests = operator_requests + VALUES(operator_requests)`,
+		s.UserID, s.AssistName, s.Day.Format(time.DateOnly), s.Dialogs, s.Targets, s.TargetTurns, s.OperatorRequests); err != nil {
+		return fmt.Errorf("ошибка обновления показателей ассистента %s: %w", s.AssistName, err)
+	}
+	return nil
+}
+
+// GetAssistantStats показатели ассистентов пользователя по дням с from по to включительно
+func (d *DB) GetAssistantStats(userID uint32, from, to time.Time) ([]AssistantDayStats, error) {
+	ctx, cancel := context.WithTimeout(d.Context(), mode.SqlTimeToCancel)
+	defer cancel()
+
+	rows, err := d.Conn().QueryContext(ctx,
+		`SELECT assist_name, day, dialogs, targets, target_turns, operator_requests
+		 FROM assistant_stats_daily WHERE user_id = ? AND day BETWEEN ? AND ?
+		 ORDER BY day, assist_name`,
+		userID, from.Format(time.DateOnly), to.Format(time.DateOnly))
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения показателей пользователя %d: %w", userID, err)
+	}
+	defer rows.Close()
+
+	var out []AssistantDayStats
+	for rows.Next() {
+		s := AssistantDayStats{UserID: userID}
+		if err := rows.Scan(&s.AssistName, &s.Day, &s.Dialogs, &s.Targets, &s.TargetTurns, &s.OperatorRequests); err != nil {
+			return nil, fmt.Errorf("ошибка разбора показателей пользователя %d: %w", userID, err)
+		}
+		s.Derive()
+		out = append(out, s)
+	}
+	return out, rows.Err()
+}
+
+// Derive вычисляет Conversion и TurnsToTarget из счётчиков
+func (s *AssistantDayStats) Derive() {
+	s.Conversion, s.TurnsToTarget = 0, 0
+	if s.Dialogs > 0 {
+		s.Conversion = float64(s.Targets) / float64(s.Dialogs) * 100
+	}
+	if s.Targets > 0 {
+		s.TurnsToTarget = float64(s.TargetTurns) / float64(s.Targets)
+	}
+}
@@ -0,0 +1,152 @@
+package comdb
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ikermy/AiR_Common/pkg/mode"
+	"github.com/ikermy/AiR_Common/pkg/model/create"
+)
+
+// BatchJob — пакетное задание у провайдера (OpenAI Batch API / Gemini batch).
+// Хранится в таблице batch_jobs, чтобы опрос статуса пережил перезапуск.
+//
+//	CREATE TABLE batch_jobs (
+//	    id           BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+//	    user_id      INT UNSIGNED    NOT NULL,
+//	    provider     TINYINT UNSIGNED NOT NULL,
+//	    kind         VARCHAR(64)     NOT NULL,
+//	    external_id  VARCHAR(255)    NOT NULL,
+//	    status       VARCHAR(16)     NOT NULL,
+//	    requests     INT UNSIGNED    NOT NULL DEFAULT 0,
+//	    error        TEXT,
+//	    created_at   DATETIME        NOT NULL DEFAULT CURRENT_TIMESTAMP,
+//	    completed_at DATETIME        NULL,
+//	    KEY idx_batch_jobs_pending (completed_at),
+//	    KEY idx_batch_jobs_user (user_id)
+//	);
+type BatchJob struct {
+	ID          uint64              `json:"id"`
+	UserID      uint32              `json:"user_id"`
+	Provider    create.ProviderType `json:"provider"`
+	Kind        string              `json:"kind"`        // назначение задания, например "reembed" или "summarize"
+	ExternalID  string              `json:"external_id"` // id пакета у провайдера
+	Status      string              `json:"status"`      // create.BatchPending / BatchRunning / BatchCompleted / BatchFailed
+	Requests    int                 `json:"requests"`
+	Error       string              `json:"error,omitempty"`
+	CreatedAt   time.Time           `json:"created_at"`
+	CompletedAt *time.Time          `json:"completed_at,omitempty"`
+}
+
+// SaveBatchJob сохраняет отправленное пакетное задание
+func (d *DB) SaveBatchJob(job BatchJob) (uint64, error) {
+	if job.ExternalID == "" {
+		return 0, fmt.Errorf("получен пустой id пакета")
+	}
+
+	ctx, cancel := context.WithTimeout(d.Context(), mode.SqlTimeToCancel)
+	defer cancel()
+
+	res, err := d.Conn().ExecContext(ctx,
+		"INSERT INTO batch_jobs (user_id, provider, kind, external_id, status, requests) VALUES (?, ?, ?, ?, ?, ?)",
+		job.UserID, job.Provider, job.Kind, job.ExternalID, job.Status, job.Requests)
+	if err != nil {
+		switch {
+		case errors.Is(err, context.DeadlineExceeded):
+			return 0, fmt.Errorf("тайм-аут при сохранении пакетного задания: %w", err)
+		case errors.Is(err, context.Canceled):
+			return 0, fmt.Errorf("операция отменена: %w", err)
+		default:
+			return 0, fmt.Errorf("ошибка сохранения пакетного задания: %w", err)
+		}
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("ошибка получения id пакетного задания: %w", err)
+	}
+	return uint64(id), nil
+}
+
+// UpdateBatchJob обновляет статус задания. Завершённые и упавшие задания получают completed_at
+// и больше не попадают в ListPendingBatchJobs
+func (d *DB) UpdateBatchJob(id uint64, status, errText string) error {
+	ctx, cancel := context.WithTimeout(d.Context(), mode.SqlTimeToCancel)
+	defer cancel()
+
+	query := "UPDATE batch_jobs SET status = ?, error = ? WHERE id = ?"
+	if status == create.BatchCompleted || status == create.BatchFailed {
+		query = "UPDATE batch_jobs SET status = ?, error = ?, completed_at = NOW() WHERE id = ?"
+	}
+	if _, err := d.Conn().ExecContext(ctx, query, status, errText, id); err != nil {
+		return fmt.Errorf("ошибка обновления пакетного задания %d: %w", id, err)
+	}
+	return nil
+}
+
+// ListPendingBatchJobs возвращает незавершённые задания, старые первыми
+func (d *DB) ListPendingBatchJobs(limit int) ([]BatchJob, error) {
+	if limit <= 0 {
+		limit = 100
+	}
+
+	ctx, cancel := context.WithTimeout(d.Context(), mode.SqlTimeToCancel)
+	defer cancel()
+
+	rows, err := d.Conn().QueryContext(ctx,
+		`SELECT id, user_id, provider, kind, external_id, status, requests, COALESCE(error, ''), created_at, completed_at
+		 FROM batch_jobs WHERE completed_at IS NULL ORDER BY created_at LIMIT ?`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения пакетных заданий: %w", err)
+	}
+	defer rows.Close()
+
+	var result []BatchJob
+	for rows.Next() {
+		job, err := scanBatchJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, job)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка чтения пакетных заданий: %w", err)
+	}
+	return result, nil
+}
+
+// GetBatchJob возвращает задание по id
+func (d *DB) GetBatchJob(id uint64) (*BatchJob, error) {
+	ctx, cancel := context.WithTimeout(d.Context(), mode.SqlTimeToCancel)
+	defer cancel()
+
+	row := d.Conn().QueryRowContext(ctx,
+		`SELECT id, user_id, provider, kind, external_id, status, requests, COALESCE(error, ''), created_at, completed_at
+		 FROM batch_jobs WHERE id = ?`, id)
+	job, err := scanBatchJob(row)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("пакетное задание %d не найдено", id)
+		}
+		return nil, err
+	}
+	return &job, nil
+}
+
+func scanBatchJob(row rowScanner) (BatchJob, error) {
+	var (
+		job       BatchJob
+		completed sql.NullTime
+	)
+	if err := row.Scan(&job.ID, &job.UserID, &job.Provider, &job.Kind, &job.ExternalID, &job.Status,
+		&job.Requests, &job.Error, &job.CreatedAt, &completed); err != nil {
+		return job, fmt.Errorf("ошибка чтения пакетного задания: %w", err)
+	}
+	if completed.Valid {
+		job.CompletedAt = &completed.Time
+	}
+	return job, nil
+}
@@ -0,0 +1,136 @@
+package comdb
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ikermy/AiR_Common/pkg/mode"
+)
+
+// CannedReply — шаблон быстрого ответа оператора. Text может содержать переменные вида {name}.
+//
+//	CREATE TABLE canned_replies (
+//	    id         BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+//	    user_id    INT UNSIGNED NOT NULL,
+//	    title      VARCHAR(255) NOT NULL,
+//	    text       TEXT         NOT NULL,
+//	    tags       JSON         NULL,
+//	    created_at DATETIME     NOT NULL DEFAULT CURRENT_TIMESTAMP,
+//	    KEY idx_canned_user (user_id)
+//	);
+type CannedReply struct {
+	ID        uint64    `json:"id"`
+	UserID    uint32    `json:"user_id"`
+	Title     string    `json:"title"`
+	Text      string    `json:"text"`
+	Tags      []string  `json:"tags,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// SaveCannedReply добавляет шаблон (ID == 0) или обновляет существующий
+func (d *DB) SaveCannedReply(r CannedReply) (uint64, error) {
+	if r.Text == "" {
+		return 0, fmt.Errorf("пустой текст шаблона")
+	}
+
+	ctx, cancel := context.WithTimeout(d.Context(), mode.SqlTimeToCancel)
+	defer cancel()
+
+	tags, err := json.Marshal(r.Tags)
+	if err != nil {
+		return 0, fmt.Errorf("ошибка сериализации тегов шаблона: %w", err)
+	}
+
+	if r.ID != 0 {
+		res, err := d.Conn().ExecContext(ctx,
+			"UPDATE canned_replies SET title = ?, text = ?, tags = ? WHERE id = ? AND user_id = ?",
+			r.Title, r.Text, string(tags), r.ID, r.UserID)
+		if err != nil {
+			return 0, fmt.Errorf("ошибка обновления шаблона %d: %w", r.ID, err)
+		}
+		rowsAffected, err := res.RowsAffected()
+		if err != nil {
+			return 0, fmt.Errorf("ошибка получения количества обновленных строк: %w", err)
+		}
+		if rowsAffected == 0 {
+			// MySQL не считает строку, если значения не изменились: проверяем, что шаблон есть
+			var one int
+			err := d.Conn().QueryRowContext(ctx,
+				"SELECT 1 FROM canned_replies WHERE id = ? AND user_id = ?", r.ID, r.UserID).Scan(&one)
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				return 0, fmt.Errorf("шаблон %d не найден", r.ID)
+			case err != nil:
+				return 0, fmt.Errorf("ошибка проверки шаблона %d: %w", r.ID, err)
+			}
+		}
+		return r.ID, nil
+	}
+
+	res, err := d.Conn().ExecContext(ctx,
+		"INSERT INTO canned_replies (user_id, title, text, tags) VALUES (?, ?, ?, ?)",
+		r.UserID, r.Title, r.Text, string(tags))
+	if err != nil {
+		switch {
+		case errors.Is(err, context.DeadlineExceeded):
+			return 0, fmt.Errorf("тайм-аут при сохранении шаблона: %w", err)
+		case errors.Is(err, context.Canceled):
+			return 0, fmt.Errorf("операция отменена: %w", err)
+		default:
+			return 0, fmt.Errorf("ошибка сохранения шаблона: %w", err)
+		}
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("ошибка получения id шаблона: %w", err)
+	}
+	return uint64(id), nil
+}
+
+// ListCannedReplies возвращает шаблоны пользователя в порядке добавления
+func (d *DB) ListCannedReplies(userID uint32) ([]CannedReply, error) {
+	ctx, cancel := context.WithTimeout(d.Context(), mode.SqlTimeToCancel)
+	defer cancel()
+
+	rows, err := d.Conn().QueryContext(ctx,
+		"SELECT id, user_id, title, text, tags, created_at FROM canned_replies WHERE user_id = ? ORDER BY id", userID)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения шаблонов: %w", err)
+	}
+	defer rows.Close()
+
+	var result []CannedReply
+	for rows.Next() {
+		var (
+			r    CannedReply
+			tags []byte
+		)
+		if err := rows.Scan(&r.ID, &r.UserID, &r.Title, &r.Text, &tags, &r.CreatedAt); err != nil {
+			return nil, fmt.Errorf("ошибка чтения шаблона: %w", err)
+		}
+		if len(tags) > 0 {
+			_ = json.Unmarshal(tags, &r.Tags)
+		}
+		result = append(result, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка чтения шаблонов: %w", err)
+	}
+	return result, nil
+}
+
+// DeleteCannedReply удаляет шаблон пользователя
+func (d *DB) DeleteCannedReply(userID uint32, id uint64) error {
+	ctx, cancel := context.WithTimeout(d.Context(), mode.SqlTimeToCancel)
+	defer cancel()
+
+	if _, err := d.Conn().ExecContext(ctx, "DELETE FROM canned_replies WHERE id = ? AND user_id = ?", id, userID); err != nil {
+		return fmt.Errorf("ошибка удаления шаблона %d: %w", id, err)
+	}
+	return nil
+}
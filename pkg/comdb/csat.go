@@ -0,0 +1,121 @@
+package comdb
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/ikermy/AiR_Common/pkg/mode"
+)
+
+// CSATRating опрос удовлетворённости после завершения диалога: запрос оценки и ответ
+// пользователя. Хранится в таблице csat_ratings, по строке на диалог.
+//
+//	CREATE TABLE csat_ratings (
+//	    dialog_id    BIGINT UNSIGNED PRIMARY KEY,
+//	    user_id      INT UNSIGNED    NOT NULL,
+//	    assist_name  VARCHAR(255)    NOT NULL,
+//	    reason       VARCHAR(16)     NOT NULL,  -- target | ttl
+//	    score        TINYINT         NULL,      -- NULL — пользователь ещё не ответил
+//	    comment      TEXT            NULL,
+//	    requested_at DATETIME        NOT NULL,
+//	    rated_at     DATETIME        NULL,
+//	    KEY idx_csat_user (user_id, requested_at)
+//	);
+type CSATRating struct {
+	DialogID    uint64    `json:"dialog_id"`
+	UserID      uint32    `json:"user_id"`
+	AssistName  string    `json:"assist_name"`
+	Reason      string    `json:"reason"`
+	Score       int       `json:"score,omitempty"` // 0 — оценки нет
+	Comment     string    `json:"comment,omitempty"`
+	RequestedAt time.Time `json:"requested_at"`
+	RatedAt     time.Time `json:"rated_at,omitempty"`
+}
+
+// CSATStats сводка опросов ассистента
+type CSATStats struct {
+	AssistName string  `json:"assist_name"`
+	Requested  int     `json:"requested"`
+	Rated      int     `json:"rated"`
+	Average    float64 `json:"average"` // средняя оценка
+	CSAT       float64 `json:"csat"`    // доля довольных (оценка >= satisfied) среди ответивших, %
+}
+
+// SaveCSATRequest фиксирует запрос оценки; повторный запрос по диалогу ничего не меняет
+func (d *DB) SaveCSATRequest(r CSATRating) error {
+	if r.DialogID == 0 {
+		return fmt.Errorf("получен пустой dialogId")
+	}
+	if r.RequestedAt.IsZero() {
+		r.RequestedAt = time.Now()
+	}
+
+	ctx, cancel := context.WithTimeout(d.Context(), mode.SqlTimeToCancel)
+	defer cancel()
+
+	if _, err := d.Conn().ExecContext(ctx,
+		`INSERT IGNORE INTO csat_ratings (dialog_id, user_id, assist_name, reason, requested_at) VALUES (?, ?, ?, ?, ?)`,
+		r.DialogID, r.UserID, r.AssistName, r.Reason, r.RequestedAt); err != nil {
+		return fmt.Errorf("ошибка сохранения запроса оценки диалога %d: %w", r.DialogID, err)
+	}
+	return nil
+}
+
+// SaveCSATRating сохраняет оценку пользователя; оценка без запроса тоже сохраняется
+func (d *DB) SaveCSATRating(r CSATRating) error {
+	if r.DialogID == 0 {
+		return fmt.Errorf("получен пустой dialogId")
+	}
+	if r.RatedAt.IsZero() {
+		r.RatedAt = time.Now()
+	}
+	if r.RequestedAt.IsZero() {
+		r.RequestedAt = r.RatedAt
+	}
+
+	ctx, cancel := context.WithTimeout(d.Context(), mode.SqlTimeToCancel)
+	defer cancel()
+
+	var comment sql.NullString
+	if r.Comment != "" {
+		comment = sql.NullString{String: r.Comment, Valid: true}
+	}
+	if _, err := d.Conn().ExecContext(ctx,
+		`INSERT INTO csat_ratings (dialog_id, user_id, assist_name, reason, score, comment, requested_at, rated_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		 ON DUPLICATE KEY UPDATE score = VALUES(score), comment = VALUES(comment), rated_at = VALUES(rated_at)`,
+		r.DialogID, r.UserID, r.AssistName, r.Reason, r.Score, comment, r.RequestedAt, r.RatedAt); err != nil {
+		return fmt.Errorf("ошибка сохранения оценки диалога %d: %w", r.DialogID, err)
+	}
+	return nil
+}
+
+// GetCSATStats сводка опросов пользователя по ассистентам с момента since.
+// satisfied — минимальная оценка довольного пользователя (4 для шкалы 1..5)
+func (d *DB) GetCSATStats(userID uint32, since time.Time, satisfied int) ([]CSATStats, error) {
+	ctx, cancel := context.WithTimeout(d.Context(), mode.SqlTimeToCancel)
+	defer cancel()
+
+	rows, err := d.Conn().QueryContext(ctx,
+		`SELECT assist_name, COUNT(*), COUNT(score), COALESCE(AVG(score), 0),
+		        COALESCE(SUM(score >= ?) / NULLIF(COUNT(score), 0) * 100, 0)
+		 FROM csat_ratings WHERE user_id = ? AND requested_at >= ?
+		 GROUP BY assist_name ORDER BY assist_name`,
+		satisfied, userID, since)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения оценок пользователя %d: %w", userID, err)
+	}
+	defer rows.Close()
+
+	var out []CSATStats
+	for rows.Next() {
+		var st CSATStats
+		if err := rows.Scan(&st.AssistName, &st.Requested, &st.Rated, &st.Average, &st.CSAT); err != nil {
+			return nil, fmt.Errorf("ошибка разбора оценок пользователя %d: %w", userID, err)
+		}
+		out = append(out, st)
+	}
+	return out, rows.Err()
+}
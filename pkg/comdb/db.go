@@ -505,6 +505,22 @@ func (d *DB) DeleteDialog(userID uint32, dialogId uint64) error {
 	return nil
 }
 
+// GetDialogOwner возвращает пользователя, которому принадлежит диалог; 0 — диалога нет
+func (d *DB) GetDialogOwner(ctx context.Context, dialogId uint64) (uint32, error) {
+	ctx, cancel := context.WithTimeout(ctx, mode.SqlTimeToCancel)
+	defer cancel()
+
+	var userId uint32
+	err := d.Conn().QueryRowContext(ctx, "SELECT `User` FROM dialogs WHERE Id = ?", dialogId).Scan(&userId)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return 0, nil
+	case err != nil:
+		return 0, fmt.Errorf("ошибка чтения владельца диалога %d: %w", dialogId, err)
+	}
+	return userId, nil
+}
+
 // SaveDialog сохраняет всю историю диалога в базу данных
 func (d *DB) SaveDialog(treadId uint64, message json.RawMessage) error {
 	if treadId == 0 {
@@ -1061,6 +1077,64 @@ func (d *DB) ReadUserModelByProvider(userID uint32, provider create.ProviderType
 	return decodedData, vecIds, nil
 }
 
+// ReadUserModelById получает сжатые данные модели пользователя по ModelId
+// (у пользователя может быть несколько моделей одного провайдера)
+func (d *DB) ReadUserModelById(userID uint32, modelID uint64) ([]byte, *create.VecIds, error) {
+	if userID == 0 || modelID == 0 {
+		return nil, nil, fmt.Errorf("получены некорректные userID=%d или modelID=%d", userID, modelID)
+	}
+
+	ctx, cancel := context.WithTimeout(d.Context(), sqlTimeToCancel*time.Second)
+	defer cancel()
+
+	query := `
+		SELECT TO_BASE64(ug.Data), ug.Ids, um.Provider
+		FROM user_models um
+		JOIN user_gpt ug ON um.ModelId = ug.Id
+		WHERE um.userID = ? AND um.ModelId = ?`
+
+	var base64Data sql.NullString
+	var idsJson sql.NullString
+	var provider uint8
+
+	err := d.Conn().QueryRowContext(ctx, query, userID, modelID).Scan(&base64Data, &idsJson, &provider)
+	if err != nil {
+		switch {
+		case errors.Is(err, context.DeadlineExceeded):
+			return nil, nil, fmt.Errorf("тайм-аут (%d с) при вызове ReadUserModelById: %w", sqlTimeToCancel, err)
+		case errors.Is(err, context.Canceled):
+			return nil, nil, fmt.Errorf("операция отменена: %w", err)
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, nil, nil // Модель не найдена, но это не ошибка
+		default:
+			return nil, nil, fmt.Errorf("ошибка получения данных ReadUserModelById: %w", err)
+		}
+	}
+
+	if !base64Data.Valid || base64Data.String == "" {
+		return nil, nil, nil
+	}
+
+	vecIds := &create.VecIds{
+		VectorId: []string{},
+		FileIds:  []create.Ids{},
+	}
+	// Как в ReadUserModelByProvider: у Google в Ids конфигурация модели, а не file_ids/vector_id
+	if create.ProviderType(provider) != create.ProviderGoogle &&
+		idsJson.Valid && idsJson.String != "" && idsJson.String != "null" {
+		if err := json.Unmarshal([]byte(idsJson.String), vecIds); err != nil {
+			return nil, nil, fmt.Errorf("ошибка разбора Ids: %w", err)
+		}
+	}
+
+	decodedData, err := base64.StdEncoding.DecodeString(base64Data.String)
+	if err != nil {
+		return nil, nil, fmt.Errorf("ошибка декодирования base64: %w", err)
+	}
+
+	return decodedData, vecIds, nil
+}
+
 // DefaultProvidersModels возвращает модель по умолчанию для указанного провайдера
 func (d *DB) DefaultProvidersModels(providerName string) (uint, string, error) {
 	// Проверяем входные данные
@@ -0,0 +1,182 @@
+package comdb
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ikermy/AiR_Common/pkg/crypto"
+	"github.com/ikermy/AiR_Common/pkg/mode"
+)
+
+// DeadLetter — ход диалога, который не удалось обработать или доставить.
+// Хранится в таблице dead_letters до повторной обработки оператором.
+//
+//	CREATE TABLE dead_letters (
+//	    id          BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+//	    user_id     INT UNSIGNED    NOT NULL,
+//	    dialog_id   BIGINT UNSIGNED NOT NULL,
+//	    resp_id     BIGINT UNSIGNED NOT NULL,
+//	    reason      VARCHAR(64)     NOT NULL,
+//	    error       TEXT,
+//	    payload     MEDIUMTEXT      NOT NULL,
+//	    created_at  DATETIME        NOT NULL DEFAULT CURRENT_TIMESTAMP,
+//	    replayed_at DATETIME        NULL,
+//	    KEY idx_dead_letters_user (user_id, replayed_at)
+//	);
+type DeadLetter struct {
+	ID         uint64          `json:"id"`
+	UserID     uint32          `json:"user_id"`
+	DialogID   uint64          `json:"dialog_id"`
+	RespID     uint64          `json:"resp_id"`
+	Reason     string          `json:"reason"`
+	Error      string          `json:"error"`
+	Payload    json.RawMessage `json:"payload"`
+	CreatedAt  time.Time       `json:"created_at"`
+	ReplayedAt *time.Time      `json:"replayed_at,omitempty"`
+}
+
+// SaveDeadLetter сохраняет необработанный ход. Payload шифруется MasterKey пользователя, если он доступен.
+func (d *DB) SaveDeadLetter(dl DeadLetter) (uint64, error) {
+	if dl.DialogID == 0 {
+		return 0, fmt.Errorf("получен пустой dialogId")
+	}
+
+	ctx, cancel := context.WithTimeout(d.Context(), mode.SqlTimeToCancel)
+	defer cancel()
+
+	payload := string(dl.Payload)
+	if d.MasterKeyResolver != nil {
+		if mk, ok := d.MasterKeyResolver(dl.UserID); ok {
+			if enc, err := crypto.EncryptFieldWithMasterKey(mk, payload); err == nil {
+				payload = enc
+			}
+		}
+	}
+
+	res, err := d.Conn().ExecContext(ctx,
+		"INSERT INTO dead_letters (user_id, dialog_id, resp_id, reason, error, payload) VALUES (?, ?, ?, ?, ?, ?)",
+		dl.UserID, dl.DialogID, dl.RespID, dl.Reason, dl.Error, payload)
+	if err != nil {
+		switch {
+		case errors.Is(err, context.DeadlineExceeded):
+			return 0, fmt.Errorf("тайм-аут при сохранении dead letter: %w", err)
+		case errors.Is(err, context.Canceled):
+			return 0, fmt.Errorf("операция отменена: %w", err)
+		default:
+			return 0, fmt.Errorf("ошибка сохранения dead letter: %w", err)
+		}
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("ошибка получения id dead letter: %w", err)
+	}
+	return uint64(id), nil
+}
+
+// ListDeadLetters возвращает необработанные (не переигранные) ходы пользователя, новые первыми
+func (d *DB) ListDeadLetters(userID uint32, limit int) ([]DeadLetter, error) {
+	if limit <= 0 {
+		limit = 100
+	}
+
+	ctx, cancel := context.WithTimeout(d.Context(), mode.SqlTimeToCancel)
+	defer cancel()
+
+	rows, err := d.Conn().QueryContext(ctx,
+		`SELECT id, user_id, dialog_id, resp_id, reason, COALESCE(error, ''), payload, created_at, replayed_at
+		 FROM dead_letters WHERE user_id = ? AND replayed_at IS NULL ORDER BY id DESC LIMIT ?`, userID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения dead letters: %w", err)
+	}
+	defer rows.Close()
+
+	var result []DeadLetter
+	for rows.Next() {
+		dl, err := d.scanDeadLetter(rows)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, dl)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка чтения dead letters: %w", err)
+	}
+	return result, nil
+}
+
+// GetDeadLetter возвращает dead letter по id
+func (d *DB) GetDeadLetter(id uint64) (*DeadLetter, error) {
+	ctx, cancel := context.WithTimeout(d.Context(), mode.SqlTimeToCancel)
+	defer cancel()
+
+	row := d.Conn().QueryRowContext(ctx,
+		`SELECT id, user_id, dialog_id, resp_id, reason, COALESCE(error, ''), payload, created_at, replayed_at
+		 FROM dead_letters WHERE id = ?`, id)
+	dl, err := d.scanDeadLetter(row)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("dead letter %d не найден", id)
+		}
+		return nil, err
+	}
+	return &dl, nil
+}
+
+// MarkDeadLetterReplayed помечает dead letter как повторно обработанный
+func (d *DB) MarkDeadLetterReplayed(id uint64) error {
+	ctx, cancel := context.WithTimeout(d.Context(), mode.SqlTimeToCancel)
+	defer cancel()
+
+	if _, err := d.Conn().ExecContext(ctx, "UPDATE dead_letters SET replayed_at = NOW() WHERE id = ?", id); err != nil {
+		return fmt.Errorf("ошибка обновления dead letter %d: %w", id, err)
+	}
+	return nil
+}
+
+// DeleteDeadLetter удаляет dead letter
+func (d *DB) DeleteDeadLetter(id uint64) error {
+	ctx, cancel := context.WithTimeout(d.Context(), mode.SqlTimeToCancel)
+	defer cancel()
+
+	if _, err := d.Conn().ExecContext(ctx, "DELETE FROM dead_letters WHERE id = ?", id); err != nil {
+		return fmt.Errorf("ошибка удаления dead letter %d: %w", id, err)
+	}
+	return nil
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// scanDeadLetter читает строку и расшифровывает payload
+func (d *DB) scanDeadLetter(row rowScanner) (DeadLetter, error) {
+	var (
+		dl       DeadLetter
+		payload  string
+		replayed sql.NullTime
+	)
+	if err := row.Scan(&dl.ID, &dl.UserID, &dl.DialogID, &dl.RespID, &dl.Reason, &dl.Error, &payload, &dl.CreatedAt, &replayed); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return dl, err
+		}
+		return dl, fmt.Errorf("ошибка чтения dead letter: %w", err)
+	}
+	if replayed.Valid {
+		dl.ReplayedAt = &replayed.Time
+	}
+
+	if crypto.IsEncryptedWithMasterKey(payload) && d.MasterKeyResolver != nil {
+		if mk, ok := d.MasterKeyResolver(dl.UserID); ok {
+			if dec, err := crypto.DecryptFieldWithMasterKey(mk, payload); err == nil {
+				payload = dec
+			}
+		}
+	}
+	dl.Payload = json.RawMessage(payload)
+	return dl, nil
+}
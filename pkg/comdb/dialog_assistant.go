@@ -0,0 +1,55 @@
+package comdb
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/ikermy/AiR_Common/pkg/mode"
+)
+
+// Ассистент, ведущий диалог, когда у пользователя их несколько (продажи, поддержка).
+// Привязка хранится в таблице dialog_assistants и переживает перезапуск процесса.
+//
+//	CREATE TABLE dialog_assistants (
+//	    dialog_id  BIGINT UNSIGNED PRIMARY KEY,
+//	    user_id    INT UNSIGNED    NOT NULL,
+//	    assist_id  VARCHAR(128)    NOT NULL,
+//	    updated_at DATETIME        NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
+//	);
+
+// SaveDialogAssistant привязывает диалог к ассистенту assistID
+func (d *DB) SaveDialogAssistant(userID uint32, dialogID uint64, assistID string) error {
+	if dialogID == 0 {
+		return fmt.Errorf("получен пустой dialogId")
+	}
+
+	ctx, cancel := context.WithTimeout(d.Context(), mode.SqlTimeToCancel)
+	defer cancel()
+
+	if _, err := d.Conn().ExecContext(ctx,
+		`INSERT INTO dialog_assistants (dialog_id, user_id, assist_id) VALUES (?, ?, ?)
+		 ON DUPLICATE KEY UPDATE user_id = VALUES(user_id), assist_id = VALUES(assist_id)`,
+		dialogID, userID, assistID); err != nil {
+		return fmt.Errorf("ошибка сохранения ассистента диалога %d: %w", dialogID, err)
+	}
+	return nil
+}
+
+// GetDialogAssistant ассистент диалога; пустая строка — диалог ещё не привязан
+func (d *DB) GetDialogAssistant(dialogID uint64) (string, error) {
+	ctx, cancel := context.WithTimeout(d.Context(), mode.SqlTimeToCancel)
+	defer cancel()
+
+	var assistID string
+	err := d.Conn().QueryRowContext(ctx,
+		"SELECT assist_id FROM dialog_assistants WHERE dialog_id = ?", dialogID).Scan(&assistID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", nil
+		}
+		return "", fmt.Errorf("ошибка чтения ассистента диалога %d: %w", dialogID, err)
+	}
+	return assistID, nil
+}
@@ -0,0 +1,178 @@
+package comdb
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ikermy/AiR_Common/pkg/crypto"
+	"github.com/ikermy/AiR_Common/pkg/mode"
+)
+
+// Сжатие истории диалогов: ранние сообщения Data заменяются одной сводкой.
+// Время последнего сжатия хранится в dialog_compactions, чтобы не сжимать диалог повторно,
+// пока в нём нет новых сообщений.
+//
+//	CREATE TABLE dialog_compactions (
+//	    dialog_id    BIGINT UNSIGNED PRIMARY KEY,
+//	    compacted_at DATETIME NOT NULL
+//	);
+
+// ListDialogsToCompact диалоги без сообщений дольше idle, с Data длиннее minBytes,
+// не сжатые после последнего сообщения; сначала самые давние
+func (d *DB) ListDialogsToCompact(minBytes int, idle time.Duration, limit int) ([]uint64, error) {
+	ctx, cancel := context.WithTimeout(d.Context(), mode.SqlTimeToCancel)
+	defer cancel()
+
+	rows, err := d.Conn().QueryContext(ctx,
+		`SELECT dg.Id FROM dialogs dg
+		 LEFT JOIN dialog_compactions dc ON dc.dialog_id = dg.Id
+		 WHERE LENGTH(dg.Data) > ? AND dg.Date < ? AND (dc.compacted_at IS NULL OR dc.compacted_at < dg.Date)
+		 ORDER BY dg.Date LIMIT ?`,
+		minBytes, time.Now().Add(-idle), limit)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка поиска диалогов для сжатия: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []uint64
+	for rows.Next() {
+		var id uint64
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("ошибка чтения диалога для сжатия: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка поиска диалогов для сжатия: %w", err)
+	}
+	return ids, nil
+}
+
+// ReadDialogData расшифрованные сообщения диалога (элементы Data) по порядку
+func (d *DB) ReadDialogData(dialogID uint64) ([]json.RawMessage, error) {
+	ctx, cancel := context.WithTimeout(d.Context(), mode.SqlTimeToCancel)
+	defer cancel()
+
+	_, arr, _, err := d.dialogData(ctx, d.Conn(), dialogID, false)
+	return arr, err
+}
+
+// ReplaceDialogHead заменяет первые len(head) сообщений диалога одним summary и отмечает
+// диалог сжатым. replaced == false — начало диалога изменилось после чтения, замена не выполнена
+func (d *DB) ReplaceDialogHead(dialogID uint64, head []json.RawMessage, summary json.RawMessage) (replaced bool, err error) {
+	if len(head) == 0 {
+		return false, nil
+	}
+
+	ctx, cancel := context.WithTimeout(d.Context(), mode.SqlTimeToCancel)
+	defer cancel()
+
+	tx, err := d.Conn().BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("ошибка начала транзакции сжатия диалога %d: %w", dialogID, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	userID, arr, encrypted, err := d.dialogData(ctx, tx, dialogID, true)
+	if err != nil {
+		return false, err
+	}
+	if len(arr) < len(head) {
+		return false, nil
+	}
+	for i := range head {
+		if !bytes.Equal(arr[i], head[i]) {
+			return false, nil
+		}
+	}
+
+	out := append([]json.RawMessage{summary}, arr[len(head):]...)
+	data, err := json.Marshal(out)
+	if err != nil {
+		return false, fmt.Errorf("ошибка сериализации сжатого диалога %d: %w", dialogID, err)
+	}
+	newData := string(data)
+	if encrypted {
+		mk, _ := d.MasterKeyResolver(userID)
+		if newData, err = crypto.EncryptFieldWithMasterKey(mk, newData); err != nil {
+			return false, fmt.Errorf("ошибка шифрования сжатого диалога %d: %w", dialogID, err)
+		}
+	}
+
+	// Date не меняется: сжатие не новое сообщение
+	if _, err := tx.ExecContext(ctx, "UPDATE dialogs SET `Data` = ? WHERE Id = ?", newData, dialogID); err != nil {
+		return false, fmt.Errorf("ошибка сохранения сжатого диалога %d: %w", dialogID, err)
+	}
+	if err := markCompacted(ctx, tx, dialogID); err != nil {
+		return false, err
+	}
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("ошибка фиксации сжатого диалога %d: %w", dialogID, err)
+	}
+	return true, nil
+}
+
+// MarkDialogCompacted отмечает диалог сжатым без изменения истории (сжимать нечего)
+func (d *DB) MarkDialogCompacted(dialogID uint64) error {
+	ctx, cancel := context.WithTimeout(d.Context(), mode.SqlTimeToCancel)
+	defer cancel()
+
+	return markCompacted(ctx, d.Conn(), dialogID)
+}
+
+type sqlRunner interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+func markCompacted(ctx context.Context, q sqlRunner, dialogID uint64) error {
+	if _, err := q.ExecContext(ctx,
+		`INSERT INTO dialog_compactions (dialog_id, compacted_at) VALUES (?, NOW())
+		 ON DUPLICATE KEY UPDATE compacted_at = VALUES(compacted_at)`, dialogID); err != nil {
+		return fmt.Errorf("ошибка отметки сжатия диалога %d: %w", dialogID, err)
+	}
+	return nil
+}
+
+// dialogData читает Data диалога, расшифровывает и разворачивает в массив сообщений.
+// encrypted — Data был зашифрован MasterKey пользователя
+func (d *DB) dialogData(ctx context.Context, q sqlRunner, dialogID uint64, forUpdate bool) (userID uint32, arr []json.RawMessage, encrypted bool, err error) {
+	query := "SELECT `User`, `Data` FROM dialogs WHERE Id = ?"
+	if forUpdate {
+		query += " FOR UPDATE"
+	}
+	var raw sql.NullString
+	if err = q.QueryRowContext(ctx, query, dialogID).Scan(&userID, &raw); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, nil, false, fmt.Errorf("диалог %d не найден", dialogID)
+		}
+		return 0, nil, false, fmt.Errorf("ошибка чтения диалога %d: %w", dialogID, err)
+	}
+	if !raw.Valid || raw.String == "" {
+		return userID, nil, false, nil
+	}
+
+	data := raw.String
+	if crypto.IsEncryptedWithMasterKey(data) {
+		if d.MasterKeyResolver == nil {
+			return 0, nil, false, fmt.Errorf("диалог %d зашифрован, MasterKey недоступен", dialogID)
+		}
+		mk, ok := d.MasterKeyResolver(userID)
+		if !ok {
+			return 0, nil, false, fmt.Errorf("диалог %d зашифрован, MasterKey недоступен", dialogID)
+		}
+		if data, err = crypto.DecryptFieldWithMasterKey(mk, data); err != nil {
+			return 0, nil, false, fmt.Errorf("ошибка расшифровки диалога %d: %w", dialogID, err)
+		}
+		encrypted = true
+	}
+	if err = json.Unmarshal(d.normalizeDataArray(json.RawMessage(data)), &arr); err != nil {
+		return 0, nil, false, fmt.Errorf("ошибка разбора диалога %d: %w", dialogID, err)
+	}
+	return userID, arr, encrypted, nil
+}
@@ -0,0 +1,129 @@
+package comdb
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ikermy/AiR_Common/pkg/crypto"
+	"github.com/ikermy/AiR_Common/pkg/mode"
+)
+
+// DialogSnapshot — состояние диалога в памяти провайдера (респондент, кэш истории),
+// сохранённое при завершении процесса. Новый процесс загружает снимки одним запросом
+// вместо того, чтобы каждый активный диалог одновременно перечитывал историю из dialogs.
+//
+//	CREATE TABLE dialog_snapshots (
+//	    dialog_id BIGINT UNSIGNED PRIMARY KEY,
+//	    user_id   INT UNSIGNED    NOT NULL,
+//	    provider  VARCHAR(32)     NOT NULL,
+//	    data      MEDIUMTEXT      NOT NULL,
+//	    expire_at DATETIME        NOT NULL,
+//	    KEY idx_provider_expire (provider, expire_at)
+//	);
+type DialogSnapshot struct {
+	DialogID uint64    `json:"dialog_id"`
+	UserID   uint32    `json:"user_id"`
+	Provider string    `json:"provider"`
+	Data     string    `json:"data"`      // состояние в формате провайдера
+	ExpireAt time.Time `json:"expire_at"` // после этого момента снимок не восстанавливается
+}
+
+// SaveDialogSnapshots сохраняет снимки диалогов одной транзакцией, заменяя прежние.
+// Data шифруется MasterKey пользователя, если он доступен.
+func (d *DB) SaveDialogSnapshots(snaps []DialogSnapshot) error {
+	if len(snaps) == 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(d.Context(), mode.SqlTimeToCancel)
+	defer cancel()
+
+	tx, err := d.Conn().BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("ошибка начала транзакции снимков диалогов: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	for _, s := range snaps {
+		data := s.Data
+		if d.MasterKeyResolver != nil {
+			if mk, ok := d.MasterKeyResolver(s.UserID); ok {
+				if enc, err := crypto.EncryptFieldWithMasterKey(mk, data); err == nil {
+					data = enc
+				}
+			}
+		}
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO dialog_snapshots (dialog_id, user_id, provider, data, expire_at) VALUES (?, ?, ?, ?, ?)
+			 ON DUPLICATE KEY UPDATE user_id = VALUES(user_id), provider = VALUES(provider), data = VALUES(data),
+			 expire_at = VALUES(expire_at)`,
+			s.DialogID, s.UserID, s.Provider, data, s.ExpireAt); err != nil {
+			return fmt.Errorf("ошибка сохранения снимка диалога %d: %w", s.DialogID, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("ошибка фиксации снимков диалогов: %w", err)
+	}
+	return nil
+}
+
+// LoadDialogSnapshots возвращает действующие снимки диалогов провайдера и удаляет их вместе
+// с истёкшими: снимок восстанавливается один раз, следующее сохранение создаёт новый
+func (d *DB) LoadDialogSnapshots(provider string) ([]DialogSnapshot, error) {
+	ctx, cancel := context.WithTimeout(d.Context(), mode.SqlTimeToCancel)
+	defer cancel()
+
+	if _, err := d.Conn().ExecContext(ctx, "DELETE FROM dialog_snapshots WHERE expire_at <= ?", time.Now()); err != nil {
+		return nil, fmt.Errorf("ошибка удаления истёкших снимков диалогов: %w", err)
+	}
+
+	rows, err := d.Conn().QueryContext(ctx,
+		"SELECT dialog_id, user_id, provider, data, expire_at FROM dialog_snapshots WHERE provider = ? AND expire_at > ?",
+		provider, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения снимков диалогов %s: %w", provider, err)
+	}
+	defer rows.Close()
+
+	var snaps []DialogSnapshot
+	var ids []any
+	for rows.Next() {
+		var s DialogSnapshot
+		if err := rows.Scan(&s.DialogID, &s.UserID, &s.Provider, &s.Data, &s.ExpireAt); err != nil {
+			return nil, fmt.Errorf("ошибка чтения снимка диалога: %w", err)
+		}
+		ids = append(ids, s.DialogID)
+		if crypto.IsEncryptedWithMasterKey(s.Data) {
+			if d.MasterKeyResolver == nil {
+				continue
+			}
+			mk, ok := d.MasterKeyResolver(s.UserID)
+			if !ok {
+				continue
+			}
+			dec, err := crypto.DecryptFieldWithMasterKey(mk, s.Data)
+			if err != nil {
+				continue
+			}
+			s.Data = dec
+		}
+		snaps = append(snaps, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка чтения снимков диалогов %s: %w", provider, err)
+	}
+	_ = rows.Close()
+
+	// Удаляются прочитанные строки, а не все снимки провайдера: другой экземпляр сервиса
+	// мог сохранить свои снимки после SELECT
+	if len(ids) > 0 {
+		query := "DELETE FROM dialog_snapshots WHERE provider = ? AND dialog_id IN (?" + strings.Repeat(", ?", len(ids)-1) + ")"
+		if _, err := d.Conn().ExecContext(ctx, query, append([]any{provider}, ids...)...); err != nil {
+			return nil, fmt.Errorf("ошибка удаления загруженных снимков диалогов %s: %w", provider, err)
+		}
+	}
+	return snaps, nil
+}
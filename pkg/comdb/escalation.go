@@ -0,0 +1,131 @@
+package comdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ikermy/AiR_Common/pkg/crypto"
+	"github.com/ikermy/AiR_Common/pkg/mode"
+)
+
+// Escalation — диалог в очереди ожидания свободного оператора.
+// Хранится в таблице operator_queue; порядок очереди — по id, поэтому позиция переживает перезапуск.
+//
+//	CREATE TABLE operator_queue (
+//	    id         BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+//	    user_id    INT UNSIGNED    NOT NULL,
+//	    dialog_id  BIGINT UNSIGNED NOT NULL,
+//	    resp_id    BIGINT UNSIGNED NOT NULL,
+//	    question   MEDIUMTEXT      NOT NULL,
+//	    voice      TINYINT(1)      NOT NULL DEFAULT 0,
+//	    created_at DATETIME        NOT NULL DEFAULT CURRENT_TIMESTAMP,
+//	    UNIQUE KEY uq_operator_queue_dialog (dialog_id),
+//	    KEY idx_operator_queue_user (user_id, id)
+//	);
+type Escalation struct {
+	ID        uint64    `json:"id"`
+	UserID    uint32    `json:"user_id"`
+	DialogID  uint64    `json:"dialog_id"`
+	RespID    uint64    `json:"resp_id"`
+	Question  string    `json:"question"` // вопрос, с которым пользователь ждёт оператора
+	Voice     bool      `json:"voice"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// SaveEscalation ставит диалог в очередь к оператору. Повторная постановка того же диалога
+// сохраняет его место в очереди и возвращает прежний id. Вопрос шифруется MasterKey пользователя, если он доступен.
+func (d *DB) SaveEscalation(e Escalation) (uint64, error) {
+	if e.DialogID == 0 {
+		return 0, fmt.Errorf("получен пустой dialogId")
+	}
+
+	ctx, cancel := context.WithTimeout(d.Context(), mode.SqlTimeToCancel)
+	defer cancel()
+
+	question := e.Question
+	if d.MasterKeyResolver != nil {
+		if mk, ok := d.MasterKeyResolver(e.UserID); ok {
+			if enc, err := crypto.EncryptFieldWithMasterKey(mk, question); err == nil {
+				question = enc
+			}
+		}
+	}
+
+	res, err := d.Conn().ExecContext(ctx,
+		`INSERT INTO operator_queue (user_id, dialog_id, resp_id, question, voice) VALUES (?, ?, ?, ?, ?)
+		 ON DUPLICATE KEY UPDATE id = LAST_INSERT_ID(id)`,
+		e.UserID, e.DialogID, e.RespID, question, e.Voice)
+	if err != nil {
+		switch {
+		case errors.Is(err, context.DeadlineExceeded):
+			return 0, fmt.Errorf("тайм-аут при постановке в очередь к оператору: %w", err)
+		case errors.Is(err, context.Canceled):
+			return 0, fmt.Errorf("операция отменена: %w", err)
+		default:
+			return 0, fmt.Errorf("ошибка постановки в очередь к оператору: %w", err)
+		}
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("ошибка получения id записи очереди: %w", err)
+	}
+	return uint64(id), nil
+}
+
+// ListEscalations возвращает очередь к операторам пользователя (userID == 0 — всех), первые в очереди первыми
+func (d *DB) ListEscalations(userID uint32) ([]Escalation, error) {
+	ctx, cancel := context.WithTimeout(d.Context(), mode.SqlTimeToCancel)
+	defer cancel()
+
+	query := "SELECT id, user_id, dialog_id, resp_id, question, voice, created_at FROM operator_queue"
+	args := []any{}
+	if userID != 0 {
+		query += " WHERE user_id = ?"
+		args = append(args, userID)
+	}
+	query += " ORDER BY id"
+
+	rows, err := d.Conn().QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения очереди к оператору: %w", err)
+	}
+	defer rows.Close()
+
+	var result []Escalation
+	for rows.Next() {
+		var (
+			e        Escalation
+			question string
+		)
+		if err := rows.Scan(&e.ID, &e.UserID, &e.DialogID, &e.RespID, &question, &e.Voice, &e.CreatedAt); err != nil {
+			return nil, fmt.Errorf("ошибка чтения записи очереди: %w", err)
+		}
+		if crypto.IsEncryptedWithMasterKey(question) && d.MasterKeyResolver != nil {
+			if mk, ok := d.MasterKeyResolver(e.UserID); ok {
+				if dec, err := crypto.DecryptFieldWithMasterKey(mk, question); err == nil {
+					question = dec
+				}
+			}
+		}
+		e.Question = question
+		result = append(result, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка чтения очереди к оператору: %w", err)
+	}
+	return result, nil
+}
+
+// DeleteEscalation убирает диалог из очереди к оператору
+func (d *DB) DeleteEscalation(dialogID uint64) error {
+	ctx, cancel := context.WithTimeout(d.Context(), mode.SqlTimeToCancel)
+	defer cancel()
+
+	if _, err := d.Conn().ExecContext(ctx, "DELETE FROM operator_queue WHERE dialog_id = ?", dialogID); err != nil {
+		return fmt.Errorf("ошибка удаления диалога %d из очереди к оператору: %w", dialogID, err)
+	}
+	return nil
+}
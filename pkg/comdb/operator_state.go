@@ -0,0 +1,85 @@
+package comdb
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ikermy/AiR_Common/pkg/mode"
+)
+
+// OperatorState — диалог в операторском режиме. Хранится в таблице operator_sessions,
+// чтобы перезапуск процесса не возвращал пользователя к AI посреди разговора с оператором.
+//
+//	CREATE TABLE operator_sessions (
+//	    dialog_id BIGINT UNSIGNED PRIMARY KEY,
+//	    user_id   INT UNSIGNED    NOT NULL,
+//	    resp_id   BIGINT UNSIGNED NOT NULL,
+//	    since     DATETIME        NOT NULL
+//	);
+type OperatorState struct {
+	UserID   uint32    `json:"user_id"`
+	DialogID uint64    `json:"dialog_id"`
+	RespID   uint64    `json:"resp_id"`
+	Since    time.Time `json:"since"` // начало операторского режима
+}
+
+// SaveOperatorState отмечает диалог как находящийся в операторском режиме
+func (d *DB) SaveOperatorState(st OperatorState) error {
+	if st.DialogID == 0 {
+		return fmt.Errorf("получен пустой dialogId")
+	}
+
+	ctx, cancel := context.WithTimeout(d.Context(), mode.SqlTimeToCancel)
+	defer cancel()
+
+	if st.Since.IsZero() {
+		st.Since = time.Now()
+	}
+	_, err := d.Conn().ExecContext(ctx,
+		`INSERT INTO operator_sessions (dialog_id, user_id, resp_id, since) VALUES (?, ?, ?, ?)
+		 ON DUPLICATE KEY UPDATE user_id = VALUES(user_id), resp_id = VALUES(resp_id), since = VALUES(since)`,
+		st.DialogID, st.UserID, st.RespID, st.Since)
+	if err != nil {
+		switch {
+		case errors.Is(err, context.DeadlineExceeded):
+			return fmt.Errorf("тайм-аут при сохранении операторского режима диалога %d: %w", st.DialogID, err)
+		case errors.Is(err, context.Canceled):
+			return fmt.Errorf("операция отменена: %w", err)
+		default:
+			return fmt.Errorf("ошибка сохранения операторского режима диалога %d: %w", st.DialogID, err)
+		}
+	}
+	return nil
+}
+
+// GetOperatorState возвращает операторский режим диалога; nil — диалог ведёт AI
+func (d *DB) GetOperatorState(dialogID uint64) (*OperatorState, error) {
+	ctx, cancel := context.WithTimeout(d.Context(), mode.SqlTimeToCancel)
+	defer cancel()
+
+	var st OperatorState
+	err := d.Conn().QueryRowContext(ctx,
+		"SELECT dialog_id, user_id, resp_id, since FROM operator_sessions WHERE dialog_id = ?", dialogID).
+		Scan(&st.DialogID, &st.UserID, &st.RespID, &st.Since)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("ошибка чтения операторского режима диалога %d: %w", dialogID, err)
+	}
+	return &st, nil
+}
+
+// DeleteOperatorState снимает операторский режим диалога
+func (d *DB) DeleteOperatorState(dialogID uint64) error {
+	ctx, cancel := context.WithTimeout(d.Context(), mode.SqlTimeToCancel)
+	defer cancel()
+
+	if _, err := d.Conn().ExecContext(ctx, "DELETE FROM operator_sessions WHERE dialog_id = ?", dialogID); err != nil {
+		return fmt.Errorf("ошибка удаления операторского режима диалога %d: %w", dialogID, err)
+	}
+	return nil
+}
@@ -0,0 +1,156 @@
+package comdb
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ikermy/AiR_Common/pkg/crypto"
+	"github.com/ikermy/AiR_Common/pkg/mode"
+)
+
+// PendingAsk — вопросы диалога, накопленные батчингом (Endpoint.SetUserAsk) и ещё не
+// отправленные модели. Хранится в таблице pending_asks, поэтому переживает падение процесса.
+//
+//	CREATE TABLE pending_asks (
+//	    dialog_id  BIGINT UNSIGNED PRIMARY KEY,
+//	    user_id    INT UNSIGNED    NOT NULL,
+//	    resp_id    BIGINT UNSIGNED NOT NULL,
+//	    asks       MEDIUMTEXT      NOT NULL,
+//	    voice      TINYINT(1)      NOT NULL DEFAULT 0,
+//	    updated_at DATETIME        NOT NULL
+//	);
+type PendingAsk struct {
+	UserID    uint32    `json:"user_id"`
+	DialogID  uint64    `json:"dialog_id"`
+	RespID    uint64    `json:"resp_id"`
+	Asks      []string  `json:"asks"`
+	Voice     bool      `json:"voice"`
+	UpdatedAt time.Time `json:"updated_at"` // время последнего вопроса: от него отсчитывается таймер батчинга
+}
+
+// SavePendingAsk сохраняет накопленные вопросы диалога, заменяя прежние.
+// Вопросы шифруются MasterKey пользователя, если он доступен.
+func (d *DB) SavePendingAsk(p PendingAsk) error {
+	if p.DialogID == 0 {
+		return fmt.Errorf("получен пустой dialogId")
+	}
+
+	ctx, cancel := context.WithTimeout(d.Context(), mode.SqlTimeToCancel)
+	defer cancel()
+
+	data, err := json.Marshal(p.Asks)
+	if err != nil {
+		return fmt.Errorf("ошибка сериализации вопросов: %w", err)
+	}
+	asks := string(data)
+	if d.MasterKeyResolver != nil {
+		if mk, ok := d.MasterKeyResolver(p.UserID); ok {
+			if enc, err := crypto.EncryptFieldWithMasterKey(mk, asks); err == nil {
+				asks = enc
+			}
+		}
+	}
+	if p.UpdatedAt.IsZero() {
+		p.UpdatedAt = time.Now()
+	}
+
+	_, err = d.Conn().ExecContext(ctx,
+		`INSERT INTO pending_asks (dialog_id, user_id, resp_id, asks, voice, updated_at) VALUES (?, ?, ?, ?, ?, ?)
+		 ON DUPLICATE KEY UPDATE user_id = VALUES(user_id), resp_id = VALUES(resp_id), asks = VALUES(asks),
+		 voice = VALUES(voice), updated_at = VALUES(updated_at)`,
+		p.DialogID, p.UserID, p.RespID, asks, p.Voice, p.UpdatedAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, context.DeadlineExceeded):
+			return fmt.Errorf("тайм-аут при сохранении вопросов диалога %d: %w", p.DialogID, err)
+		case errors.Is(err, context.Canceled):
+			return fmt.Errorf("операция отменена: %w", err)
+		default:
+			return fmt.Errorf("ошибка сохранения вопросов диалога %d: %w", p.DialogID, err)
+		}
+	}
+	return nil
+}
+
+// GetPendingAsk возвращает накопленные вопросы диалога; nil — вопросов нет
+func (d *DB) GetPendingAsk(dialogID uint64) (*PendingAsk, error) {
+	ctx, cancel := context.WithTimeout(d.Context(), mode.SqlTimeToCancel)
+	defer cancel()
+
+	var (
+		p    PendingAsk
+		asks string
+	)
+	err := d.Conn().QueryRowContext(ctx,
+		"SELECT dialog_id, user_id, resp_id, asks, voice, updated_at FROM pending_asks WHERE dialog_id = ?", dialogID).
+		Scan(&p.DialogID, &p.UserID, &p.RespID, &asks, &p.Voice, &p.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("ошибка чтения вопросов диалога %d: %w", dialogID, err)
+	}
+	if err := d.decodePendingAsks(&p, asks); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// ListPendingAsks возвращает накопленные вопросы всех диалогов (восстановление после перезапуска)
+func (d *DB) ListPendingAsks() ([]PendingAsk, error) {
+	ctx, cancel := context.WithTimeout(d.Context(), mode.SqlTimeToCancel)
+	defer cancel()
+
+	rows, err := d.Conn().QueryContext(ctx,
+		"SELECT dialog_id, user_id, resp_id, asks, voice, updated_at FROM pending_asks ORDER BY updated_at")
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения накопленных вопросов: %w", err)
+	}
+	defer rows.Close()
+
+	var out []PendingAsk
+	for rows.Next() {
+		var (
+			p    PendingAsk
+			asks string
+		)
+		if err := rows.Scan(&p.DialogID, &p.UserID, &p.RespID, &asks, &p.Voice, &p.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("ошибка чтения накопленных вопросов: %w", err)
+		}
+		if err := d.decodePendingAsks(&p, asks); err != nil {
+			return nil, err
+		}
+		out = append(out, p)
+	}
+	return out, rows.Err()
+}
+
+// decodePendingAsks расшифровывает и разбирает сохранённые вопросы в p.Asks
+func (d *DB) decodePendingAsks(p *PendingAsk, asks string) error {
+	if crypto.IsEncryptedWithMasterKey(asks) && d.MasterKeyResolver != nil {
+		if mk, ok := d.MasterKeyResolver(p.UserID); ok {
+			if dec, err := crypto.DecryptFieldWithMasterKey(mk, asks); err == nil {
+				asks = dec
+			}
+		}
+	}
+	if err := json.Unmarshal([]byte(asks), &p.Asks); err != nil {
+		return fmt.Errorf("ошибка разбора вопросов диалога %d: %w", p.DialogID, err)
+	}
+	return nil
+}
+
+// DeletePendingAsk удаляет накопленные вопросы диалога (ушли модели или устарели)
+func (d *DB) DeletePendingAsk(dialogID uint64) error {
+	ctx, cancel := context.WithTimeout(d.Context(), mode.SqlTimeToCancel)
+	defer cancel()
+
+	if _, err := d.Conn().ExecContext(ctx, "DELETE FROM pending_asks WHERE dialog_id = ?", dialogID); err != nil {
+		return fmt.Errorf("ошибка удаления вопросов диалога %d: %w", dialogID, err)
+	}
+	return nil
+}
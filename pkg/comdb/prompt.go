@@ -0,0 +1,181 @@
+package comdb
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/ikermy/AiR_Common/pkg/mode"
+	"github.com/ikermy/AiR_Common/pkg/model/create"
+)
+
+// Версии системных промптов моделей и ходы диалогов, которые они обслужили.
+//
+//	CREATE TABLE prompt_versions (
+//	    model_id   BIGINT UNSIGNED NOT NULL,
+//	    version    INT UNSIGNED    NOT NULL,
+//	    user_id    INT UNSIGNED    NOT NULL,
+//	    prompt     MEDIUMTEXT      NOT NULL,
+//	    rendered   MEDIUMTEXT      NOT NULL,
+//	    hash       CHAR(64)        NOT NULL,
+//	    created_at DATETIME        NOT NULL DEFAULT CURRENT_TIMESTAMP,
+//	    PRIMARY KEY (model_id, version)
+//	);
+//
+//	CREATE TABLE prompt_turns (
+//	    id         BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+//	    dialog_id  BIGINT UNSIGNED NOT NULL,
+//	    model_id   BIGINT UNSIGNED NOT NULL,
+//	    version    INT UNSIGNED    NOT NULL,
+//	    created_at DATETIME        NOT NULL DEFAULT CURRENT_TIMESTAMP,
+//	    KEY idx_prompt_turns_dialog (dialog_id, id)
+//	);
+
+var _ create.PromptVersionStore = (*DB)(nil)
+
+// SavePromptVersion сохраняет новую версию промпта модели, если rendered отличается от последней
+// версии, и возвращает номер актуальной версии
+func (d *DB) SavePromptVersion(userID uint32, modelID uint64, prompt, rendered string) (uint32, error) {
+	if modelID == 0 {
+		return 0, fmt.Errorf("получен пустой modelId")
+	}
+
+	ctx, cancel := context.WithTimeout(d.Context(), mode.SqlTimeToCancel)
+	defer cancel()
+
+	sum := sha256.Sum256([]byte(rendered))
+	hash := hex.EncodeToString(sum[:])
+
+	tx, err := d.Conn().BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("ошибка начала транзакции: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var (
+		last     uint32
+		lastHash string
+	)
+	err = tx.QueryRowContext(ctx,
+		"SELECT version, hash FROM prompt_versions WHERE model_id = ? ORDER BY version DESC LIMIT 1 FOR UPDATE",
+		modelID).Scan(&last, &lastHash)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+	case err != nil:
+		return 0, fmt.Errorf("ошибка чтения версии промпта модели %d: %w", modelID, err)
+	case lastHash == hash:
+		return last, nil
+	}
+
+	if _, err := tx.ExecContext(ctx,
+		"INSERT INTO prompt_versions (model_id, version, user_id, prompt, rendered, hash) VALUES (?, ?, ?, ?, ?, ?)",
+		modelID, last+1, userID, prompt, rendered, hash); err != nil {
+		return 0, fmt.Errorf("ошибка сохранения версии промпта модели %d: %w", modelID, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("ошибка фиксации версии промпта модели %d: %w", modelID, err)
+	}
+	return last + 1, nil
+}
+
+// GetPromptVersion возвращает версию промпта модели (nil, если её нет)
+func (d *DB) GetPromptVersion(modelID uint64, version uint32) (*create.PromptVersion, error) {
+	ctx, cancel := context.WithTimeout(d.Context(), mode.SqlTimeToCancel)
+	defer cancel()
+
+	v := create.PromptVersion{ModelID: modelID, Version: version}
+	err := d.Conn().QueryRowContext(ctx,
+		"SELECT user_id, prompt, rendered, created_at FROM prompt_versions WHERE model_id = ? AND version = ?",
+		modelID, version).Scan(&v.UserID, &v.Prompt, &v.Rendered, &v.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения версии промпта %d модели %d: %w", version, modelID, err)
+	}
+	return &v, nil
+}
+
+// ListPromptVersions возвращает версии промпта модели от новых к старым, без текстов
+func (d *DB) ListPromptVersions(modelID uint64) ([]create.PromptVersion, error) {
+	ctx, cancel := context.WithTimeout(d.Context(), mode.SqlTimeToCancel)
+	defer cancel()
+
+	rows, err := d.Conn().QueryContext(ctx,
+		"SELECT version, user_id, created_at FROM prompt_versions WHERE model_id = ? ORDER BY version DESC", modelID)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения версий промпта модели %d: %w", modelID, err)
+	}
+	defer rows.Close()
+
+	var result []create.PromptVersion
+	for rows.Next() {
+		v := create.PromptVersion{ModelID: modelID}
+		if err := rows.Scan(&v.Version, &v.UserID, &v.CreatedAt); err != nil {
+			return nil, fmt.Errorf("ошибка чтения версии промпта: %w", err)
+		}
+		result = append(result, v)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка чтения версий промпта модели %d: %w", modelID, err)
+	}
+	return result, nil
+}
+
+// DiffPrompts построчно сравнивает итоговые промпты версий from и to модели
+func (d *DB) DiffPrompts(modelID uint64, from, to uint32) ([]create.PromptDiffLine, error) {
+	a, err := d.GetPromptVersion(modelID, from)
+	if err != nil {
+		return nil, err
+	}
+	b, err := d.GetPromptVersion(modelID, to)
+	if err != nil {
+		return nil, err
+	}
+	if a == nil || b == nil {
+		return nil, fmt.Errorf("версия промпта модели %d не найдена (%d, %d)", modelID, from, to)
+	}
+	return create.DiffPromptText(a.Rendered, b.Rendered), nil
+}
+
+// RecordPromptTurn отмечает, какой версией промпта обслужен ход диалога
+func (d *DB) RecordPromptTurn(dialogID, modelID uint64, version uint32) error {
+	ctx, cancel := context.WithTimeout(d.Context(), mode.SqlTimeToCancel)
+	defer cancel()
+
+	if _, err := d.Conn().ExecContext(ctx,
+		"INSERT INTO prompt_turns (dialog_id, model_id, version) VALUES (?, ?, ?)",
+		dialogID, modelID, version); err != nil {
+		return fmt.Errorf("ошибка сохранения версии промпта хода диалога %d: %w", dialogID, err)
+	}
+	return nil
+}
+
+// DialogPromptTurns возвращает версии промптов, обслужившие ходы диалога, по порядку
+func (d *DB) DialogPromptTurns(dialogID uint64) ([]create.PromptTurn, error) {
+	ctx, cancel := context.WithTimeout(d.Context(), mode.SqlTimeToCancel)
+	defer cancel()
+
+	rows, err := d.Conn().QueryContext(ctx,
+		"SELECT model_id, version, created_at FROM prompt_turns WHERE dialog_id = ? ORDER BY id", dialogID)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения версий промпта диалога %d: %w", dialogID, err)
+	}
+	defer rows.Close()
+
+	var result []create.PromptTurn
+	for rows.Next() {
+		t := create.PromptTurn{DialogID: dialogID}
+		if err := rows.Scan(&t.ModelID, &t.Version, &t.CreatedAt); err != nil {
+			return nil, fmt.Errorf("ошибка чтения версии промпта хода: %w", err)
+		}
+		result = append(result, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка чтения версий промпта диалога %d: %w", dialogID, err)
+	}
+	return result, nil
+}
@@ -0,0 +1,171 @@
+package comdb
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ikermy/AiR_Common/pkg/crypto"
+	"github.com/ikermy/AiR_Common/pkg/mode"
+)
+
+// ScheduledMessage — сообщение, которое нужно доставить в диалог в заданное время.
+// Хранится в таблице scheduled_messages, поэтому переживает перезапуск.
+//
+//	CREATE TABLE scheduled_messages (
+//	    id           BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+//	    user_id      INT UNSIGNED    NOT NULL,
+//	    dialog_id    BIGINT UNSIGNED NOT NULL,
+//	    resp_id      BIGINT UNSIGNED NOT NULL,
+//	    deliver_at   DATETIME        NOT NULL,
+//	    payload      MEDIUMTEXT      NOT NULL,
+//	    created_at   DATETIME        NOT NULL DEFAULT CURRENT_TIMESTAMP,
+//	    delivered_at DATETIME        NULL,
+//	    attempts     INT UNSIGNED    NOT NULL DEFAULT 0,
+//	    KEY idx_scheduled_due (delivered_at, deliver_at),
+//	    KEY idx_scheduled_dialog (dialog_id)
+//	);
+type ScheduledMessage struct {
+	ID          uint64          `json:"id"`
+	UserID      uint32          `json:"user_id"`
+	DialogID    uint64          `json:"dialog_id"`
+	RespID      uint64          `json:"resp_id"`
+	DeliverAt   time.Time       `json:"deliver_at"`
+	Payload     json.RawMessage `json:"payload"`
+	CreatedAt   time.Time       `json:"created_at"`
+	DeliveredAt *time.Time      `json:"delivered_at,omitempty"`
+	Attempts    uint32          `json:"attempts"` // неудачных попыток доставки
+}
+
+// SaveScheduledMessage сохраняет отложенное сообщение. Payload шифруется MasterKey пользователя, если он доступен.
+func (d *DB) SaveScheduledMessage(m ScheduledMessage) (uint64, error) {
+	if m.DialogID == 0 {
+		return 0, fmt.Errorf("получен пустой dialogId")
+	}
+
+	ctx, cancel := context.WithTimeout(d.Context(), mode.SqlTimeToCancel)
+	defer cancel()
+
+	payload := string(m.Payload)
+	if d.MasterKeyResolver != nil {
+		if mk, ok := d.MasterKeyResolver(m.UserID); ok {
+			if enc, err := crypto.EncryptFieldWithMasterKey(mk, payload); err == nil {
+				payload = enc
+			}
+		}
+	}
+
+	res, err := d.Conn().ExecContext(ctx,
+		"INSERT INTO scheduled_messages (user_id, dialog_id, resp_id, deliver_at, payload) VALUES (?, ?, ?, ?, ?)",
+		m.UserID, m.DialogID, m.RespID, m.DeliverAt.UTC(), payload)
+	if err != nil {
+		switch {
+		case errors.Is(err, context.DeadlineExceeded):
+			return 0, fmt.Errorf("тайм-аут при сохранении отложенного сообщения: %w", err)
+		case errors.Is(err, context.Canceled):
+			return 0, fmt.Errorf("операция отменена: %w", err)
+		default:
+			return 0, fmt.Errorf("ошибка сохранения отложенного сообщения: %w", err)
+		}
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("ошибка получения id отложенного сообщения: %w", err)
+	}
+	return uint64(id), nil
+}
+
+// ListDueScheduledMessages возвращает недоставленные сообщения со временем доставки не позже now, старые первыми
+func (d *DB) ListDueScheduledMessages(now time.Time, limit int) ([]ScheduledMessage, error) {
+	if limit <= 0 {
+		limit = 100
+	}
+
+	ctx, cancel := context.WithTimeout(d.Context(), mode.SqlTimeToCancel)
+	defer cancel()
+
+	rows, err := d.Conn().QueryContext(ctx,
+		`SELECT id, user_id, dialog_id, resp_id, deliver_at, payload, created_at, delivered_at, attempts
+		 FROM scheduled_messages WHERE delivered_at IS NULL AND deliver_at <= ? ORDER BY deliver_at LIMIT ?`, now.UTC(), limit)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения отложенных сообщений: %w", err)
+	}
+	defer rows.Close()
+
+	var result []ScheduledMessage
+	for rows.Next() {
+		m, err := d.scanScheduledMessage(rows)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка чтения отложенных сообщений: %w", err)
+	}
+	return result, nil
+}
+
+// MarkScheduledMessageDelivered помечает отложенное сообщение как доставленное
+func (d *DB) MarkScheduledMessageDelivered(id uint64) error {
+	ctx, cancel := context.WithTimeout(d.Context(), mode.SqlTimeToCancel)
+	defer cancel()
+
+	if _, err := d.Conn().ExecContext(ctx, "UPDATE scheduled_messages SET delivered_at = NOW() WHERE id = ?", id); err != nil {
+		return fmt.Errorf("ошибка обновления отложенного сообщения %d: %w", id, err)
+	}
+	return nil
+}
+
+// RescheduleScheduledMessage переносит недоставленное сообщение на время at и увеличивает счётчик попыток
+func (d *DB) RescheduleScheduledMessage(id uint64, at time.Time) error {
+	ctx, cancel := context.WithTimeout(d.Context(), mode.SqlTimeToCancel)
+	defer cancel()
+
+	if _, err := d.Conn().ExecContext(ctx,
+		"UPDATE scheduled_messages SET deliver_at = ?, attempts = attempts + 1 WHERE id = ? AND delivered_at IS NULL",
+		at.UTC(), id); err != nil {
+		return fmt.Errorf("ошибка переноса отложенного сообщения %d: %w", id, err)
+	}
+	return nil
+}
+
+// DeleteScheduledMessage отменяет отложенное сообщение
+func (d *DB) DeleteScheduledMessage(id uint64) error {
+	ctx, cancel := context.WithTimeout(d.Context(), mode.SqlTimeToCancel)
+	defer cancel()
+
+	if _, err := d.Conn().ExecContext(ctx, "DELETE FROM scheduled_messages WHERE id = ? AND delivered_at IS NULL", id); err != nil {
+		return fmt.Errorf("ошибка удаления отложенного сообщения %d: %w", id, err)
+	}
+	return nil
+}
+
+// scanScheduledMessage читает строку и расшифровывает payload
+func (d *DB) scanScheduledMessage(row rowScanner) (ScheduledMessage, error) {
+	var (
+		m         ScheduledMessage
+		payload   string
+		delivered sql.NullTime
+	)
+	if err := row.Scan(&m.ID, &m.UserID, &m.DialogID, &m.RespID, &m.DeliverAt, &payload, &m.CreatedAt, &delivered, &m.Attempts); err != nil {
+		return m, fmt.Errorf("ошибка чтения отложенного сообщения: %w", err)
+	}
+	if delivered.Valid {
+		m.DeliveredAt = &delivered.Time
+	}
+
+	if crypto.IsEncryptedWithMasterKey(payload) && d.MasterKeyResolver != nil {
+		if mk, ok := d.MasterKeyResolver(m.UserID); ok {
+			if dec, err := crypto.DecryptFieldWithMasterKey(mk, payload); err == nil {
+				payload = dec
+			}
+		}
+	}
+	m.Payload = json.RawMessage(payload)
+	return m, nil
+}
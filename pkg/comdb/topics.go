@@ -0,0 +1,103 @@
+package comdb
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ikermy/AiR_Common/pkg/mode"
+)
+
+// DialogTopics темы завершённого диалога. Хранится в таблице dialog_topics, по строке
+// на тему; повторная разметка диалога заменяет его темы.
+//
+//	CREATE TABLE dialog_topics (
+//	    dialog_id   BIGINT UNSIGNED NOT NULL,
+//	    user_id     INT UNSIGNED    NOT NULL,
+//	    assist_name VARCHAR(255)    NOT NULL,
+//	    topic       VARCHAR(64)     NOT NULL,
+//	    tagged_at   DATETIME        NOT NULL,
+//	    PRIMARY KEY (dialog_id, topic),
+//	    KEY idx_topics_user (user_id, tagged_at)
+//	);
+type DialogTopics struct {
+	DialogID   uint64    `json:"dialog_id"`
+	UserID     uint32    `json:"user_id"`
+	AssistName string    `json:"assist_name"`
+	Topics     []string  `json:"topics"`
+	TaggedAt   time.Time `json:"tagged_at"`
+}
+
+// TopicCount сколько диалогов помечено темой
+type TopicCount struct {
+	Topic   string  `json:"topic"`
+	Dialogs int     `json:"dialogs"`
+	Share   float64 `json:"share"` // доля размеченных диалогов, %
+}
+
+// SaveDialogTopics сохраняет темы диалога вместо прежних
+func (d *DB) SaveDialogTopics(t DialogTopics) error {
+	if t.DialogID == 0 {
+		return fmt.Errorf("получен пустой dialogId")
+	}
+	if t.TaggedAt.IsZero() {
+		t.TaggedAt = time.Now()
+	}
+
+	ctx, cancel := context.WithTimeout(d.Context(), mode.SqlTimeToCancel)
+	defer cancel()
+
+	tx, err := d.Conn().BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("saveDialogTopics begin tx: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err = tx.ExecContext(ctx, `DELETE FROM dialog_topics WHERE dialog_id = ?`, t.DialogID); err != nil {
+		return fmt.Errorf("ошибка удаления тем диалога %d: %w", t.DialogID, err)
+	}
+	if len(t.Topics) > 0 {
+		args := make([]any, 0, len(t.Topics)*5)
+		for _, topic := range t.Topics {
+			args = append(args, t.DialogID, t.UserID, t.AssistName, topic, t.TaggedAt)
+		}
+		values := strings.TrimSuffix(strings.Repeat("(?, ?, ?, ?, ?), ", len(t.Topics)), ", ")
+		if _, err = tx.ExecContext(ctx,
+			`INSERT IGNORE INTO dialog_topics (dialog_id, user_id, assist_name, topic, tagged_at) VALUES `+values,
+			args...); err != nil {
+			return fmt.Errorf("ошибка сохранения тем диалога %d: %w", t.DialogID, err)
+		}
+	}
+	return tx.Commit()
+}
+
+// GetTopTopics самые частые темы диалогов пользователя с момента since, не больше limit.
+// assistName "" — по всем ассистентам
+func (d *DB) GetTopTopics(userID uint32, assistName string, since time.Time, limit int) ([]TopicCount, error) {
+	ctx, cancel := context.WithTimeout(d.Context(), mode.SqlTimeToCancel)
+	defer cancel()
+
+	rows, err := d.Conn().QueryContext(ctx,
+		`SELECT t.topic, COUNT(*), COUNT(*) / NULLIF(
+		        (SELECT COUNT(DISTINCT dialog_id) FROM dialog_topics
+		         WHERE user_id = ? AND tagged_at >= ? AND (? = '' OR assist_name = ?)), 0) * 100
+		 FROM dialog_topics t
+		 WHERE t.user_id = ? AND t.tagged_at >= ? AND (? = '' OR t.assist_name = ?)
+		 GROUP BY t.topic ORDER BY COUNT(*) DESC, t.topic LIMIT ?`,
+		userID, since, assistName, assistName, userID, since, assistName, assistName, limit)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения тем диалогов пользователя %d: %w", userID, err)
+	}
+	defer rows.Close()
+
+	var out []TopicCount
+	for rows.Next() {
+		var tc TopicCount
+		if err := rows.Scan(&tc.Topic, &tc.Dialogs, &tc.Share); err != nil {
+			return nil, fmt.Errorf("ошибка разбора тем диалогов пользователя %d: %w", userID, err)
+		}
+		out = append(out, tc)
+	}
+	return out, rows.Err()
+}
@@ -14,16 +14,16 @@ import (
 )
 
 // SaveEmbedding сохраняет эмбеддинг документа в MariaDB с привязкой к модели
-// Поддерживает динамические размерности: 512 (OpenAI small), 768 (Google), 1536 (OpenAI medium), 3072 (OpenAI large)
+// Поддерживает динамические размерности: 512 (OpenAI small), 768 (Google), 1024 (Mistral), 1536 (OpenAI medium), 3072 (OpenAI large)
 // Использует нативный тип VECTOR(3072) с padding нулями для эффективного хранения
 func (d *DB) SaveEmbedding(userID uint32, modelId uint64, provider create.ProviderType, docID, docName, content string, embedding []float32, metadata create.DocumentMetadata) error {
 	ctx, cancel := context.WithTimeout(d.MainCTX(), time.Duration(sqlTimeToCancel)*time.Second)
 	defer cancel()
 
-	// Валидация размерности (поддержка Google 768 и OpenAI 512/1536/3072)
+	// Валидация размерности (поддержка Google 768, Mistral 1024 и OpenAI 512/1536/3072)
 	embeddingDim := len(embedding)
-	if embeddingDim != 512 && embeddingDim != 768 && embeddingDim != 1536 && embeddingDim != 3072 {
-		return fmt.Errorf("неподдерживаемая размерность эмбеддинга: %d (допустимо: 512, 768, 1536, 3072)", embeddingDim)
+	if embeddingDim != 512 && embeddingDim != 768 && embeddingDim != 1024 && embeddingDim != 1536 && embeddingDim != 3072 {
+		return fmt.Errorf("неподдерживаемая размерность эмбеддинга: %d (допустимо: 512, 768, 1024, 1536, 3072)", embeddingDim)
 	}
 
 	// Дополняем нулями до 3072 для совместимости с VECTOR(3072)
@@ -234,8 +234,8 @@ func (d *DB) SearchSimilarEmbeddings(modelId uint64, provider create.ProviderTyp
 
 	// Валидация размерности
 	queryDim := len(queryEmbedding)
-	if queryDim != 512 && queryDim != 768 && queryDim != 1536 && queryDim != 3072 {
-		return nil, fmt.Errorf("неподдерживаемая размерность эмбеддинга запроса: %d (допустимо: 512, 768, 1536, 3072)", queryDim)
+	if queryDim != 512 && queryDim != 768 && queryDim != 1024 && queryDim != 1536 && queryDim != 3072 {
+		return nil, fmt.Errorf("неподдерживаемая размерность эмбеддинга запроса: %d (допустимо: 512, 768, 1024, 1536, 3072)", queryDim)
 	}
 
 	// Дополняем нулями до 3072 для совместимости с VECTOR(3072)
@@ -0,0 +1,241 @@
+// Package events — внутренняя шина событий диалогов.
+//
+// Производитель (startpoint.Respondent и др.) публикует событие и не ждёт доставки;
+// каждый подписчик (запись в БД, webhook, метрики, брокеры сообщений) получает события
+// из своей очереди в отдельной горутине, поэтому медленный подписчик не задерживает остальных.
+package events
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ikermy/AiR_Common/pkg/safego"
+)
+
+// Type тип события
+type Type string
+
+const (
+	Notify            Type = "notify"             // уведомление каналам (Endpoint.SendEvent): Name — событие, Target — данные
+	Meta              Type = "meta"               // метка диалога в БД (Endpoint.Meta): Name — метка, Target — MetaAction
+	DialogStarted     Type = "dialog.started"     // начало обслуживания диалога
+	DialogEnded       Type = "dialog.ended"       // конец обслуживания диалога
+	TargetReached     Type = "target.reached"     // ассистент достиг цели MetaAction
+	OperatorRequested Type = "operator.requested" // диалог передан оператору
+	Error             Type = "error"              // ошибка обслуживания диалога: Data["error"]
+	Alert             Type = "alert"              // критическая ошибка модели, диалог завершён: Data["error"]
+	AssistantSwitched Type = "assistant.switched" // диалог передан другому ассистенту: Data["from"], Data["to"]
+	CSATRequested     Type = "csat.requested"     // отправлен опрос удовлетворённости: Data["reason"], Data["survey"]
+	CSATRated         Type = "csat.rated"         // пользователь оценил диалог: Data["score"]
+)
+
+// subscriberQueue ёмкость очереди подписчика по умолчанию
+const subscriberQueue = 256
+
+// Event событие диалога
+type Event struct {
+	Type       Type           `json:"type"`
+	Name       string         `json:"name,omitempty"`
+	UserID     uint32         `json:"user_id"`
+	DialogID   uint64         `json:"dialog_id,omitempty"`
+	RespID     uint64         `json:"resp_id,omitempty"`
+	RespName   string         `json:"resp_name,omitempty"`
+	AssistName string         `json:"assist_name,omitempty"`
+	Target     string         `json:"target,omitempty"`
+	Data       map[string]any `json:"data,omitempty"`
+	Time       time.Time      `json:"time"`
+}
+
+// Handler обработчик событий подписчика
+type Handler func(Event)
+
+// SubscriberStats счётчики подписчика
+type SubscriberStats struct {
+	Name      string `json:"name"`
+	Delivered uint64 `json:"delivered"`
+	Dropped   uint64 `json:"dropped"` // очередь подписчика была переполнена
+}
+
+type subscriber struct {
+	name    string
+	handler Handler
+	types   map[Type]bool // nil — все типы
+	queue   chan Event
+	done    chan struct{}
+
+	delivered atomic.Uint64
+	dropped   atomic.Uint64
+}
+
+func (s *subscriber) wants(t Type) bool {
+	return s.types == nil || s.types[t]
+}
+
+// Bus шина событий
+type Bus struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu     sync.RWMutex
+	subs   []*subscriber
+	closed bool
+	wg     sync.WaitGroup
+}
+
+// New создаёт шину; подписчики останавливаются вместе с parent или Close
+func New(parent context.Context) *Bus {
+	ctx, cancel := context.WithCancel(parent)
+	return &Bus{ctx: ctx, cancel: cancel}
+}
+
+// Subscribe добавляет подписчика на события types (пусто — на все).
+// Возвращает функцию отписки; события из очереди подписчика перед остановкой обрабатываются.
+func (b *Bus) Subscribe(name string, h Handler, types ...Type) (unsubscribe func()) {
+	sub := &subscriber{name: name, handler: h, queue: make(chan Event, subscriberQueue), done: make(chan struct{})}
+	if len(types) > 0 {
+		sub.types = make(map[Type]bool, len(types))
+		for _, t := range types {
+			sub.types[t] = true
+		}
+	}
+
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return func() {}
+	}
+	b.subs = append(b.subs, sub)
+	b.wg.Add(1)
+	b.mu.Unlock()
+
+	safego.Go("events."+name, func() {
+		defer b.wg.Done()
+		b.run(sub)
+	})
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			b.mu.Lock()
+			for i, cur := range b.subs {
+				if cur == sub {
+					b.subs = append(b.subs[:i:i], b.subs[i+1:]...)
+					break
+				}
+			}
+			b.mu.Unlock()
+			close(sub.done)
+		})
+	}
+}
+
+func (b *Bus) run(sub *subscriber) {
+	for {
+		select {
+		case e := <-sub.queue:
+			b.handle(sub, e)
+		case <-sub.done:
+			b.drain(sub)
+			return
+		case <-b.ctx.Done():
+			b.drain(sub)
+			return
+		}
+	}
+}
+
+func (b *Bus) drain(sub *subscriber) {
+	for {
+		select {
+		case e := <-sub.queue:
+			b.handle(sub, e)
+		default:
+			return
+		}
+	}
+}
+
+// handle вызывает обработчик; паника в нём не останавливает подписчика
+func (b *Bus) handle(sub *subscriber, e Event) {
+	sub.delivered.Add(1)
+	_ = safego.Run("events."+sub.name, func() { sub.handler(e) })
+}
+
+// Publish рассылает событие подписчикам, не блокируясь.
+// Если очередь подписчика заполнена, событие для него отбрасывается и учитывается в Dropped.
+func (b *Bus) Publish(e Event) {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if b.closed {
+		return
+	}
+	for _, sub := range b.subs {
+		if !sub.wants(e.Type) {
+			continue
+		}
+		select {
+		case sub.queue <- e:
+		default:
+			sub.dropped.Add(1)
+			//logger.Warn("events: очередь подписчика %s заполнена, событие %s отброшено", sub.name, e.Type)
+		}
+	}
+}
+
+// Close прекращает приём событий и ждёт, пока подписчики обработают свои очереди
+func (b *Bus) Close() {
+	b.mu.Lock()
+	b.closed = true
+	b.mu.Unlock()
+	b.cancel()
+	b.wg.Wait()
+}
+
+// Stats возвращает счётчики подписчиков по имени
+func (b *Bus) Stats() []SubscriberStats {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	out := make([]SubscriberStats, 0, len(b.subs))
+	for _, sub := range b.subs {
+		out = append(out, SubscriberStats{Name: sub.name, Delivered: sub.delivered.Load(), Dropped: sub.dropped.Load()})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// Counter подписчик-метрика: число событий по типу и имени
+type Counter struct {
+	mu     sync.Mutex
+	counts map[string]uint64
+}
+
+// Handle учитывает событие; ключ — Type или "Type:Name" для Notify и Meta
+func (c *Counter) Handle(e Event) {
+	key := string(e.Type)
+	if e.Name != "" && (e.Type == Notify || e.Type == Meta) {
+		key += ":" + e.Name
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.counts == nil {
+		c.counts = make(map[string]uint64)
+	}
+	c.counts[key]++
+}
+
+// Counts возвращает копию счётчиков
+func (c *Counter) Counts() map[string]uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	out := make(map[string]uint64, len(c.counts))
+	for k, v := range c.counts {
+		out[k] = v
+	}
+	return out
+}
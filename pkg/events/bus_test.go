@@ -0,0 +1,108 @@
+package events
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestBus_SubscribersAndFilter(t *testing.T) {
+	b := New(context.Background())
+
+	var mu sync.Mutex
+	var all, metas []Type
+	counter := &Counter{}
+	b.Subscribe("all", func(e Event) {
+		mu.Lock()
+		defer mu.Unlock()
+		all = append(all, e.Type)
+	})
+	b.Subscribe("meta", func(e Event) {
+		mu.Lock()
+		defer mu.Unlock()
+		metas = append(metas, e.Type)
+	}, Meta)
+	b.Subscribe("metrics", counter.Handle)
+	b.Subscribe("panics", func(Event) { panic("boom") })
+
+	b.Publish(Event{Type: DialogStarted, UserID: 1})
+	b.Publish(Event{Type: Meta, Name: "target", UserID: 1})
+	b.Publish(Event{Type: Meta, Name: "target", UserID: 1})
+	b.Close()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(all) != 3 || all[0] != DialogStarted {
+		t.Fatalf("unexpected events for all: %v", all)
+	}
+	if len(metas) != 2 {
+		t.Fatalf("type filter broken: %v", metas)
+	}
+	counts := counter.Counts()
+	if counts["meta:target"] != 2 || counts["dialog.started"] != 1 {
+		t.Fatalf("unexpected counts: %v", counts)
+	}
+	for _, st := range b.Stats() {
+		if st.Name == "panics" && st.Delivered != 3 {
+			t.Fatalf("panicking subscriber stopped: %+v", st)
+		}
+	}
+
+	b.Publish(Event{Type: DialogEnded}) // после Close — игнорируется
+}
+
+func TestBus_DropsWhenQueueFull(t *testing.T) {
+	b := New(context.Background())
+
+	release := make(chan struct{})
+	b.Subscribe("slow", func(Event) { <-release })
+	b.Subscribe("fast", func(Event) {})
+
+	const total = subscriberQueue + 10
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < total; i++ {
+			b.Publish(Event{Type: Notify})
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatalf("Publish blocked on slow subscriber")
+	}
+
+	stats := b.Stats()
+	close(release)
+	b.Close()
+	for _, st := range stats {
+		if st.Name == "slow" && st.Dropped < total-subscriberQueue-1 {
+			t.Fatalf("slow subscriber should drop events: %+v", st)
+		}
+	}
+}
+
+func TestBus_Unsubscribe(t *testing.T) {
+	b := New(context.Background())
+	defer b.Close()
+
+	got := make(chan Event, 4)
+	unsub := b.Subscribe("one", func(e Event) { got <- e })
+	b.Publish(Event{Type: Notify, Name: "a"})
+	unsub()
+	unsub()
+	b.Publish(Event{Type: Notify, Name: "b"})
+
+	if e := <-got; e.Name != "a" || e.Time.IsZero() {
+		t.Fatalf("unexpected event: %+v", e)
+	}
+	select {
+	case e := <-got:
+		t.Fatalf("event after unsubscribe: %+v", e)
+	case <-time.After(50 * time.Millisecond):
+	}
+	if len(b.Stats()) != 0 {
+		t.Fatalf("subscriber not removed: %v", b.Stats())
+	}
+}
@@ -0,0 +1,121 @@
+// Package experiment — A/B-эксперименты над настройками ассистента: варианты с другой моделью,
+// температурой или промптом, детерминированное распределение диалогов по dialogId
+// и метки варианта для событий Meta и token_usage.
+package experiment
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"sync"
+)
+
+// Variant вариант эксперимента; пустые поля — настройки модели без изменений
+type Variant struct {
+	Name        string   `json:"name"`
+	Weight      uint32   `json:"weight,omitempty"`     // доля диалогов; 0 — как 1
+	ModelName   string   `json:"model_name,omitempty"` // имя модели провайдера
+	Temperature *float64 `json:"temperature,omitempty"`
+	Prompt      string   `json:"prompt,omitempty"` // заменяет промпт модели (подсказки MCP сохраняются)
+}
+
+// Experiment эксперимент пользователя
+type Experiment struct {
+	Name     string    `json:"name"`
+	Variants []Variant `json:"variants"`
+}
+
+// Assignment вариант, назначенный диалогу
+type Assignment struct {
+	Experiment string
+	Variant
+}
+
+// Label метка варианта "эксперимент/вариант"
+func (a Assignment) Label() string {
+	return a.Experiment + "/" + a.Name
+}
+
+// Validate проверяет эксперимент: имя, не меньше двух вариантов с уникальными именами
+func (e Experiment) Validate() error {
+	if e.Name == "" {
+		return fmt.Errorf("пустое имя эксперимента")
+	}
+	if len(e.Variants) < 2 {
+		return fmt.Errorf("эксперимент %s: нужно не меньше двух вариантов", e.Name)
+	}
+	seen := make(map[string]bool, len(e.Variants))
+	for _, v := range e.Variants {
+		if v.Name == "" {
+			return fmt.Errorf("эксперимент %s: вариант без имени", e.Name)
+		}
+		if seen[v.Name] {
+			return fmt.Errorf("эксперимент %s: повторяется вариант %s", e.Name, v.Name)
+		}
+		seen[v.Name] = true
+	}
+	return nil
+}
+
+// Assign вариант диалога: одинаков для dialogID при неизменном наборе вариантов и весов
+func (e Experiment) Assign(dialogID uint64) Variant {
+	var total uint64
+	for _, v := range e.Variants {
+		total += uint64(max(v.Weight, 1))
+	}
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(e.Name))
+	_ = binary.Write(h, binary.BigEndian, dialogID)
+	n := h.Sum64() % total
+	for _, v := range e.Variants {
+		w := uint64(max(v.Weight, 1))
+		if n < w {
+			return v
+		}
+		n -= w
+	}
+	return e.Variants[len(e.Variants)-1]
+}
+
+// Registry активные эксперименты по пользователям (владельцам ассистентов); безопасен для конкурентного использования
+type Registry struct {
+	mu   sync.RWMutex
+	exps map[uint32]Experiment
+}
+
+// NewRegistry создаёт пустой Registry
+func NewRegistry() *Registry {
+	return &Registry{exps: make(map[uint32]Experiment)}
+}
+
+// Set запускает эксперимент для диалогов пользователя, заменяя прежний
+func (r *Registry) Set(userID uint32, e Experiment) error {
+	if err := e.Validate(); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	r.exps[userID] = e
+	r.mu.Unlock()
+	return nil
+}
+
+// Remove останавливает эксперимент пользователя
+func (r *Registry) Remove(userID uint32) {
+	r.mu.Lock()
+	delete(r.exps, userID)
+	r.mu.Unlock()
+}
+
+// Assign вариант диалога пользователя; false — у пользователя нет эксперимента
+func (r *Registry) Assign(userID uint32, dialogID uint64) (Assignment, bool) {
+	if r == nil {
+		return Assignment{}, false
+	}
+	r.mu.RLock()
+	e, ok := r.exps[userID]
+	r.mu.RUnlock()
+	if !ok {
+		return Assignment{}, false
+	}
+	return Assignment{Experiment: e.Name, Variant: e.Assign(dialogID)}, true
+}
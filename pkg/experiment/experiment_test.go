@@ -0,0 +1,46 @@
+package experiment
+
+import "testing"
+
+func TestAssign_DeterministicAndWeighted(t *testing.T) {
+	e := Experiment{Name: "prompt-v2", Variants: []Variant{{Name: "a", Weight: 3}, {Name: "b", Weight: 1}}}
+	if err := e.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	counts := map[string]int{}
+	for id := uint64(1); id <= 4000; id++ {
+		v := e.Assign(id)
+		if e.Assign(id).Name != v.Name {
+			t.Fatalf("диалог %d получил разные варианты", id)
+		}
+		counts[v.Name]++
+	}
+	if counts["a"] < 2700 || counts["a"] > 3300 {
+		t.Fatalf("распределение не соответствует весам: %v", counts)
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry()
+	if err := r.Set(1, Experiment{Name: "x", Variants: []Variant{{Name: "a"}, {Name: "a"}}}); err == nil {
+		t.Fatal("повтор варианта: ожидали ошибку")
+	}
+	if err := r.Set(1, Experiment{Name: "x", Variants: []Variant{{Name: "a"}, {Name: "b"}}}); err != nil {
+		t.Fatal(err)
+	}
+	a, ok := r.Assign(1, 42)
+	if !ok || a.Label() != "x/"+a.Name {
+		t.Fatalf("назначение: %+v %v", a, ok)
+	}
+	if _, ok := r.Assign(2, 42); ok {
+		t.Fatal("у пользователя 2 нет эксперимента")
+	}
+	r.Remove(1)
+	if _, ok := r.Assign(1, 42); ok {
+		t.Fatal("эксперимент не удалён")
+	}
+	var nilReg *Registry
+	if _, ok := nilReg.Assign(1, 1); ok {
+		t.Fatal("nil Registry без экспериментов")
+	}
+}
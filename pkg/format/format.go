@@ -0,0 +1,274 @@
+// Package format приводит Markdown ответа модели к разметке канала: Telegram HTML,
+// WhatsApp или обычный текст. Канал выбирает бот (Target); свои форматы
+// подключаются через Register.
+package format
+
+import (
+	"html"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/ikermy/AiR_Common/pkg/model"
+)
+
+// Target формат канала
+type Target string
+
+const (
+	Markdown     Target = "markdown"      // без изменений
+	TelegramHTML Target = "telegram_html" // parse_mode=HTML
+	WhatsApp     Target = "whatsapp"      // *жирный*, _курсив_, ~зачёркнутый~
+	PlainText    Target = "plain"         // без разметки
+)
+
+// Formatter преобразует Markdown модели в разметку канала
+type Formatter interface {
+	Format(markdown string) string
+}
+
+// FormatterFunc функция-адаптер Formatter
+type FormatterFunc func(markdown string) string
+
+func (f FormatterFunc) Format(markdown string) string { return f(markdown) }
+
+var (
+	formattersMu sync.RWMutex
+	formatters   = map[Target]Formatter{
+		TelegramHTML: telegramStyle,
+		WhatsApp:     whatsAppStyle,
+		PlainText:    plainStyle,
+	}
+)
+
+// Register подключает формат канала или заменяет встроенный
+func Register(target Target, f Formatter) {
+	formattersMu.Lock()
+	defer formattersMu.Unlock()
+	formatters[target] = f
+}
+
+// Format преобразует markdown в формат target; Markdown и неизвестный формат — без изменений
+func Format(target Target, markdown string) string {
+	formattersMu.RLock()
+	f, ok := formatters[target]
+	formattersMu.RUnlock()
+	if !ok {
+		return markdown
+	}
+	return f.Format(markdown)
+}
+
+// Response форматирует текст ответа и подписи файлов
+func Response(target Target, resp model.AssistResponse) model.AssistResponse {
+	resp.Message = Format(target, resp.Message)
+	if len(resp.Action.SendFiles) > 0 {
+		files := make([]model.File, len(resp.Action.SendFiles))
+		for i, f := range resp.Action.SendFiles {
+			f.Caption = Format(target, f.Caption)
+			files[i] = f
+		}
+		resp.Action.SendFiles = files
+	}
+	return resp
+}
+
+// style разметка канала для элементов Markdown
+type style struct {
+	escape                     func(string) string
+	bold, italic, strike, code [2]string
+	pre                        func(lang, code string) string
+	link                       func(text, url string) string
+	heading                    func(text string) string
+	quote                      func(lines []string) string
+	bullet                     string
+}
+
+var telegramStyle = &style{
+	escape: html.EscapeString,
+	bold:   [2]string{"<b>", "</b>"},
+	italic: [2]string{"<i>", "</i>"},
+	strike: [2]string{"<s>", "</s>"},
+	code:   [2]string{"<code>", "</code>"},
+	pre: func(lang, code string) string {
+		if lang != "" {
+			return `<pre><code class="language-` + html.EscapeString(lang) + `">` + html.EscapeString(code) + "</code></pre>"
+		}
+		return "<pre>" + html.EscapeString(code) + "</pre>"
+	},
+	link: func(text, url string) string {
+		return `<a href="` + html.EscapeString(url) + `">` + text + "</a>"
+	},
+	heading: func(text string) string { return "<b>" + text + "</b>" },
+	quote: func(lines []string) string {
+		return "<blockquote>" + strings.Join(lines, "\n") + "</blockquote>"
+	},
+	bullet: "• ",
+}
+
+var whatsAppStyle = &style{
+	escape: func(s string) string { return s },
+	bold:   [2]string{"*", "*"},
+	italic: [2]string{"_", "_"},
+	strike: [2]string{"~", "~"},
+	code:   [2]string{"`", "`"},
+	pre:    func(_, code string) string { return "```" + code + "```" },
+	link:   textWithURL,
+	heading: func(text string) string {
+		return "*" + text + "*"
+	},
+	quote: func(lines []string) string {
+		return "> " + strings.Join(lines, "\n> ")
+	},
+	bullet: "• ",
+}
+
+var plainStyle = &style{
+	escape:  func(s string) string { return s },
+	pre:     func(_, code string) string { return code },
+	link:    textWithURL,
+	heading: func(text string) string { return text },
+	quote:   func(lines []string) string { return strings.Join(lines, "\n") },
+	bullet:  "• ",
+}
+
+// textWithURL ссылка для каналов без гиперссылок: «текст (url)»
+func textWithURL(text, url string) string {
+	if text == "" || text == url {
+		return url
+	}
+	return text + " (" + url + ")"
+}
+
+var (
+	headingRe = regexp.MustCompile(`^#{1,6}\s+(.*?)\s*#*$`)
+	bulletRe  = regexp.MustCompile(`^(\s*)[-*+]\s+(.*)$`)
+	ruleRe    = regexp.MustCompile(`^\s*(-\s*){3,}$|^\s*(\*\s*){3,}$|^\s*(_\s*){3,}$`)
+)
+
+// Format преобразует блоки построчно: код, заголовки, списки, цитаты; остальное — inline
+func (s *style) Format(markdown string) string {
+	lines := strings.Split(strings.ReplaceAll(markdown, "\r\n", "\n"), "\n")
+	out := make([]string, 0, len(lines))
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		trimmed := strings.TrimSpace(line)
+
+		switch {
+		case strings.HasPrefix(trimmed, "```"):
+			lang := strings.TrimSpace(strings.TrimPrefix(trimmed, "```"))
+			var code []string
+			for i++; i < len(lines) && !strings.HasPrefix(strings.TrimSpace(lines[i]), "```"); i++ {
+				code = append(code, lines[i])
+			}
+			out = append(out, s.pre(lang, strings.Join(code, "\n")))
+		case strings.HasPrefix(trimmed, ">"):
+			var quoted []string
+			for ; i < len(lines) && strings.HasPrefix(strings.TrimSpace(lines[i]), ">"); i++ {
+				text := strings.TrimPrefix(strings.TrimSpace(lines[i]), ">")
+				quoted = append(quoted, s.inline(strings.TrimPrefix(text, " ")))
+			}
+			i--
+			out = append(out, s.quote(quoted))
+		case ruleRe.MatchString(line):
+			out = append(out, "")
+		default:
+			if m := headingRe.FindStringSubmatch(trimmed); m != nil {
+				out = append(out, s.heading(s.inline(m[1])))
+			} else if m := bulletRe.FindStringSubmatch(line); m != nil {
+				out = append(out, m[1]+s.bullet+s.inline(m[2]))
+			} else {
+				out = append(out, s.inline(line))
+			}
+		}
+	}
+	return strings.Join(out, "\n")
+}
+
+// inline преобразует выделение, код и ссылки внутри строки
+func (s *style) inline(text string) string {
+	var b strings.Builder
+	for i := 0; i < len(text); {
+		rest := text[i:]
+		switch {
+		case rest[0] == '\\' && len(rest) > 1 && strings.IndexByte("\\`*_~[]()#>-+.!", rest[1]) != -1:
+			b.WriteString(s.escape(rest[1:2]))
+			i += 2
+			continue
+		case rest[0] == '`':
+			if end := strings.IndexByte(rest[1:], '`'); end > 0 {
+				b.WriteString(s.code[0] + s.escape(rest[1:1+end]) + s.code[1])
+				i += end + 2
+				continue
+			}
+		case rest[0] == '[':
+			if text, url, n, ok := parseLink(rest); ok {
+				b.WriteString(s.link(s.inline(text), url))
+				i += n
+				continue
+			}
+		}
+		if inner, n, tags, ok := s.emphasis(text, i); ok {
+			b.WriteString(tags[0] + s.inline(inner) + tags[1])
+			i += n
+			continue
+		}
+		b.WriteString(s.escape(rest[:1]))
+		i++
+	}
+	return b.String()
+}
+
+// emphasis выделение с позиции i: **жирный**, __жирный__, ~~зачёркнутый~~, *курсив*, _курсив_.
+// Маркер не должен касаться пробела изнутри, а _ — стоять внутри слова (snake_case)
+func (s *style) emphasis(text string, i int) (inner string, n int, tags [2]string, ok bool) {
+	for _, m := range []struct {
+		marker string
+		tags   [2]string
+	}{
+		{"**", s.bold}, {"__", s.bold}, {"~~", s.strike}, {"*", s.italic}, {"_", s.italic},
+	} {
+		if !strings.HasPrefix(text[i:], m.marker) {
+			continue
+		}
+		if m.marker[0] == '_' && i > 0 && isWordByte(text[i-1]) {
+			return "", 0, tags, false
+		}
+		start := i + len(m.marker)
+		if start >= len(text) || text[start] == ' ' {
+			continue
+		}
+		end := strings.Index(text[start:], m.marker)
+		if end <= 0 || text[start+end-1] == ' ' {
+			continue
+		}
+		after := start + end + len(m.marker)
+		if m.marker[0] == '_' && after < len(text) && isWordByte(text[after]) {
+			continue
+		}
+		return text[start : start+end], after - i, m.tags, true
+	}
+	return "", 0, tags, false
+}
+
+// parseLink разбирает [текст](url) в начале строки
+func parseLink(text string) (label, url string, n int, ok bool) {
+	closeBracket := strings.Index(text, "](")
+	if closeBracket < 1 {
+		return "", "", 0, false
+	}
+	closeParen := strings.IndexByte(text[closeBracket+2:], ')')
+	if closeParen < 1 {
+		return "", "", 0, false
+	}
+	url = strings.TrimSpace(text[closeBracket+2 : closeBracket+2+closeParen])
+	if strings.ContainsAny(url, " \n") {
+		return "", "", 0, false
+	}
+	return text[1:closeBracket], url, closeBracket + 3 + closeParen, true
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= 0x80
+}
@@ -0,0 +1,47 @@
+package format
+
+import (
+	"testing"
+
+	"github.com/ikermy/AiR_Common/pkg/model"
+)
+
+func TestTelegramHTML(t *testing.T) {
+	in := "## Заказ <№1>\n**Итого:** 5 & _скидка_ ~~10~~\n- пункт `a<b>`\n[сайт](https://example.test/?a=1&b=2)\n```go\nif a < b {}\n```\n> цитата"
+	want := "<b>Заказ &lt;№1&gt;</b>\n<b>Итого:</b> 5 &amp; <i>скидка</i> <s>10</s>\n• пункт <code>a&lt;b&gt;</code>\n" +
+		`<a href="https://example.test/?a=1&amp;b=2">сайт</a>` + "\n" +
+		`<pre><code class="language-go">if a &lt; b {}</code></pre>` + "\n<blockquote>цитата</blockquote>"
+	if got := Format(TelegramHTML, in); got != want {
+		t.Fatalf("Format() =\n%s\nожидалось\n%s", got, want)
+	}
+}
+
+func TestPlainTextAndWhatsApp(t *testing.T) {
+	in := "**Важно**: см. [договор](https://example.test/d) и order_id_42 * 2"
+	if got := Format(PlainText, in); got != "Важно: см. договор (https://example.test/d) и order_id_42 * 2" {
+		t.Fatalf("PlainText = %q", got)
+	}
+	if got := Format(WhatsApp, in); got != "*Важно*: см. договор (https://example.test/d) и order_id_42 * 2" {
+		t.Fatalf("WhatsApp = %q", got)
+	}
+	if got := Format(Markdown, in); got != in {
+		t.Fatalf("Markdown = %q", got)
+	}
+}
+
+func TestRegisterAndResponse(t *testing.T) {
+	const upper Target = "test_upper"
+	Register(upper, FormatterFunc(func(s string) string { return "[" + s + "]" }))
+
+	resp := model.AssistResponse{
+		Message: "текст",
+		Action:  model.Action{SendFiles: []model.File{{Caption: "подпись"}}},
+	}
+	got := Response(upper, resp)
+	if got.Message != "[текст]" || got.Action.SendFiles[0].Caption != "[подпись]" {
+		t.Fatalf("Response = %+v", got)
+	}
+	if resp.Action.SendFiles[0].Caption != "подпись" {
+		t.Fatal("исходный ответ изменён")
+	}
+}
@@ -0,0 +1,516 @@
+// Package gateway открывает диалоги startpoint для внешних фронтендов (gRPC, HTTP, WebSocket)
+// без собственного бота: Hub создаёт канал диалога через модель, запускает для него Listener
+// и раздаёт ответы ассистента всем подписчикам диалога.
+package gateway
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ikermy/AiR_Common/pkg/logger"
+	"github.com/ikermy/AiR_Common/pkg/model"
+	"github.com/ikermy/AiR_Common/pkg/safego"
+	"github.com/ikermy/AiR_Common/pkg/startpoint"
+)
+
+// subscriberBuffer ёмкость очереди подписчика; медленный подписчик теряет сообщения, а не тормозит диалог
+const subscriberBuffer = 64
+
+// historySize сколько последних сообщений диалога хранится для Resume
+const historySize = 256
+
+// MsgTypeOperatorOff служебное сообщение подписчикам: диалог возвращён модели
+const MsgTypeOperatorOff = "operator_off"
+
+// MsgTypeResumeGap первое сообщение Resume, если часть пропущенных сообщений уже вытеснена
+// из истории (или диалог открыт заново) — клиенту стоит перечитать историю диалога
+const MsgTypeResumeGap = "resume_gap"
+
+// ErrDialogAccess диалог не найден или принадлежит другому пользователю
+var ErrDialogAccess = errors.New("нет доступа к диалогу")
+
+// OwnerLookup возвращает владельца сохранённого диалога (обычно (*comdb.DB).GetDialogOwner);
+// 0 — диалога нет
+type OwnerLookup func(ctx context.Context, dialogID uint64) (uint32, error)
+
+// Resolver возвращает настройки ассистента respID пользователя (обычно из БД)
+type Resolver func(ctx context.Context, userID uint32, respID uint64) (assist model.Assistant, respName string, err error)
+
+// DialogRef адрес диалога
+type DialogRef struct {
+	UserID   uint32 `json:"user_id"`
+	RespID   uint64 `json:"resp_id"`
+	DialogID uint64 `json:"dialog_id"`
+}
+
+// DialogState состояние диалога в Hub
+type DialogState struct {
+	DialogRef
+	Active       bool      `json:"active"`        // Listener обслуживает диалог
+	OperatorMode bool      `json:"operator_mode"` // ответы идут от оператора
+	Subscribers  int       `json:"subscribers"`
+	LastActivity time.Time `json:"last_activity"`
+}
+
+// Envelope сообщение диалога с порядковым номером (с 1 в пределах открытого в Hub диалога)
+type Envelope struct {
+	Seq     uint64
+	Message model.Message
+}
+
+// Option настройка Hub
+type Option func(*Hub)
+
+// WithProvider имя канала для логов и DialogInfo.Provider (по умолчанию "gateway")
+func WithProvider(name string) Option {
+	return func(h *Hub) { h.provider = name }
+}
+
+// WithOwnerLookup проверяет владельца диалога в БД, прежде чем открыть его в Hub:
+// иначе клиент, подобрав чужой DialogID, получил бы историю чужого диалога.
+// Без owner Hub не открывает диалоги
+func WithOwnerLookup(owner OwnerLookup) Option {
+	return func(h *Hub) { h.owner = owner }
+}
+
+// Hub диалоги внешних фронтендов
+type Hub struct {
+	ctx      context.Context
+	cancel   context.CancelFunc
+	start    *startpoint.Start
+	resolve  Resolver
+	owner    OwnerLookup
+	provider string
+
+	mu       sync.Mutex
+	sessions map[uint64]*session
+	opening  map[uint64]*openLock // открытие диалога: DialogID → блокировка (под mu)
+}
+
+// openLock сериализует открытие одного диалога; refs — сколько запросов его ждут
+type openLock struct {
+	mu   sync.Mutex
+	refs int
+}
+
+// New создаёт Hub над s; диалоги закрываются вместе с parent или Close
+func New(parent context.Context, s *startpoint.Start, resolve Resolver, opts ...Option) *Hub {
+	ctx, cancel := context.WithCancel(parent)
+	h := &Hub{ctx: ctx, cancel: cancel, start: s, resolve: resolve, provider: "gateway", sessions: make(map[uint64]*session), opening: make(map[uint64]*openLock)}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
+
+// Close отключает подписчиков всех диалогов
+func (h *Hub) Close() {
+	h.cancel()
+}
+
+type session struct {
+	ref   DialogRef
+	resp  *model.RespModel
+	ch    *model.Ch
+	errCh chan error    // ошибки Listener диалога
+	done  chan struct{} // закрыт вместе с диалогом
+
+	mu       sync.Mutex
+	subs     map[int]*subscriber
+	nextSub  int
+	seq      uint64
+	history  []Envelope // кольцо последних historySize сообщений
+	operator bool
+	last     time.Time
+	closed   bool
+}
+
+// subscriber получает сообщения в msgs (Subscribe) или с номерами в envs (Resume)
+type subscriber struct {
+	msgs chan model.Message
+	envs chan Envelope
+}
+
+func (s *subscriber) send(env Envelope) bool {
+	if s.envs != nil {
+		select {
+		case s.envs <- env:
+			return true
+		default:
+			return false
+		}
+	}
+	select {
+	case s.msgs <- env.Message:
+		return true
+	default:
+		return false
+	}
+}
+
+func (s *subscriber) close() {
+	if s.envs != nil {
+		close(s.envs)
+		return
+	}
+	close(s.msgs)
+}
+
+// Send передаёт сообщение пользователя в диалог, открывая его при первом обращении.
+// Пустой Type — "user".
+func (h *Hub) Send(ctx context.Context, ref DialogRef, msg model.Message) error {
+	sess, err := h.session(ctx, ref)
+	if err != nil {
+		return err
+	}
+	if msg.Type == "" {
+		msg.Type = "user"
+	}
+	if msg.Timestamp.IsZero() {
+		msg.Timestamp = time.Now()
+	}
+	if msg.Operator.SetOperator {
+		sess.setOperator(true)
+	}
+	sess.touch()
+	if err := sess.ch.SendToRx(msg); err != nil {
+		return fmt.Errorf("ошибка передачи сообщения в диалог %d: %w", ref.DialogID, err)
+	}
+	return nil
+}
+
+// Subscribe подписывает на ответы диалога. Канал закрывается отпиской, закрытием диалога или Hub.
+func (h *Hub) Subscribe(ctx context.Context, ref DialogRef) (<-chan model.Message, func(), error) {
+	out := make(chan model.Message, subscriberBuffer)
+	unsubscribe, err := h.subscribe(ctx, ref, &subscriber{msgs: out}, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	return out, unsubscribe, nil
+}
+
+// Resume подписывает на сообщения диалога с номерами, сначала повторяя из истории сообщения
+// с Seq > after (after = 0 — только новые). Если история неполна, первым приходит MsgTypeResumeGap.
+func (h *Hub) Resume(ctx context.Context, ref DialogRef, after uint64) (<-chan Envelope, func(), error) {
+	var out chan Envelope
+	unsubscribe, err := h.subscribe(ctx, ref, nil, func(sess *session) *subscriber {
+		replay, gap := sess.since(after)
+		out = make(chan Envelope, subscriberBuffer+len(replay)+1)
+		if gap {
+			out <- Envelope{Seq: sess.seq, Message: model.Message{Type: MsgTypeResumeGap, Timestamp: time.Now()}}
+		}
+		for _, env := range replay {
+			out <- env
+		}
+		return &subscriber{envs: out}
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return out, unsubscribe, nil
+}
+
+// subscribe регистрирует sub (или созданного build под блокировкой диалога) и возвращает отписку
+func (h *Hub) subscribe(ctx context.Context, ref DialogRef, sub *subscriber, build func(*session) *subscriber) (func(), error) {
+	sess, err := h.session(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	if sess.closed {
+		return nil, fmt.Errorf("диалог %d закрыт", ref.DialogID)
+	}
+	if build != nil {
+		sub = build(sess)
+	}
+	id := sess.nextSub
+	sess.nextSub++
+	sess.subs[id] = sub
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			sess.mu.Lock()
+			defer sess.mu.Unlock()
+			if c, ok := sess.subs[id]; ok {
+				delete(sess.subs, id)
+				c.close()
+			}
+		})
+	}, nil
+}
+
+// SetOperatorMode включает операторский режим (вопрос text уходит оператору)
+// или возвращает диалог модели
+func (h *Hub) SetOperatorMode(ctx context.Context, ref DialogRef, enable bool, text string) (DialogState, error) {
+	if enable {
+		if err := h.Send(ctx, ref, model.Message{Type: "user", Operator: model.Operator{SetOperator: true}, Content: model.AssistResponse{Message: text}}); err != nil {
+			return DialogState{}, err
+		}
+		return h.State(ref), nil
+	}
+	if err := h.start.ReturnToAI(ref.UserID, ref.DialogID); err != nil {
+		return DialogState{}, err
+	}
+	_ = h.DisableOperatorMode(ref.UserID, ref.DialogID)
+	return h.State(ref), nil
+}
+
+// DisableOperatorMode отмечает возврат диалога модели и уведомляет подписчиков MsgTypeOperatorOff.
+// Реализует startpoint.BotInterface: Hub можно передать в startpoint.New как бота
+// (или вызывать из его DisableOperatorMode).
+func (h *Hub) DisableOperatorMode(userID uint32, dialogID uint64, silent ...bool) error {
+	h.mu.Lock()
+	sess, ok := h.sessions[dialogID]
+	h.mu.Unlock()
+	if !ok || sess.ref.UserID != userID || !sess.setOperator(false) {
+		return nil
+	}
+	if len(silent) == 0 || !silent[0] {
+		sess.broadcast(model.Message{Type: MsgTypeOperatorOff, Timestamp: time.Now()})
+	}
+	return nil
+}
+
+// State состояние диалога; неизвестный Hub диалог — пустое состояние с ref
+func (h *Hub) State(ref DialogRef) DialogState {
+	st := DialogState{DialogRef: ref}
+	h.mu.Lock()
+	sess, ok := h.sessions[ref.DialogID]
+	h.mu.Unlock()
+	if ok && sess.ref.UserID == ref.UserID {
+		sess.mu.Lock()
+		st.RespID = sess.ref.RespID
+		st.OperatorMode = sess.operator
+		st.Subscribers = len(sess.subs)
+		st.LastActivity = sess.last
+		st.Active = sess.resp.Services.Listener != nil && sess.resp.Services.Listener.Load()
+		sess.mu.Unlock()
+	}
+	return st
+}
+
+// session возвращает открытый диалог или открывает новый: канал модели и Listener.
+// Открытие (проверка владельца, ассистент, респондент модели) идёт вне h.mu и
+// сериализуется только по DialogID: медленная загрузка одного диалога не задерживает остальные
+func (h *Hub) session(ctx context.Context, ref DialogRef) (*session, error) {
+	if h.ctx.Err() != nil {
+		return nil, fmt.Errorf("gateway остановлен")
+	}
+	if sess, err := h.openedSession(ref); sess != nil || err != nil {
+		return sess, err
+	}
+
+	unlock := h.lockOpen(ref.DialogID)
+	defer unlock()
+	// Пока ждали, диалог мог открыть параллельный запрос
+	if sess, err := h.openedSession(ref); sess != nil || err != nil {
+		return sess, err
+	}
+
+	// Новый для Hub диалог: модель подгрузит его историю, поэтому владельца проверяем по БД
+	if h.owner == nil {
+		return nil, fmt.Errorf("%w %d: проверка владельца не настроена (WithOwnerLookup)", ErrDialogAccess, ref.DialogID)
+	}
+	owner, err := h.owner(ctx, ref.DialogID)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка проверки владельца диалога %d: %w", ref.DialogID, err)
+	}
+	if owner == 0 || owner != ref.UserID {
+		return nil, fmt.Errorf("%w %d", ErrDialogAccess, ref.DialogID)
+	}
+
+	assist, respName, err := h.resolve(ctx, ref.UserID, ref.RespID)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения ассистента %d: %w", ref.RespID, err)
+	}
+	resp, err := h.start.Mod.GetOrSetRespGPT(assist, ref.DialogID, ref.RespID, respName)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка создания респондента %d: %w", ref.RespID, err)
+	}
+	ch := resp.Chan[ref.DialogID]
+	if ch == nil {
+		return nil, fmt.Errorf("модель не создала канал диалога %d", ref.DialogID)
+	}
+	if resp.Services.Listener == nil {
+		return nil, fmt.Errorf("респондент %d без флагов сервисов", ref.RespID)
+	}
+
+	sess := &session{
+		ref:   ref,
+		resp:  resp,
+		ch:    ch,
+		errCh: make(chan error, 1),
+		done:  make(chan struct{}),
+		subs:  make(map[int]*subscriber),
+		last:  time.Now(),
+	}
+	h.mu.Lock()
+	h.sessions[ref.DialogID] = sess
+	h.mu.Unlock()
+	safego.Go("gateway.fanout", func() { h.fanout(sess) }, safego.WithUserID(ref.UserID))
+	safego.Go("gateway.errors", func() { h.listenerErrors(sess) }, safego.WithUserID(ref.UserID))
+	h.startListener(sess)
+	return sess, nil
+}
+
+// openedSession открытый Hub диалог ref; nil — диалог нужно открыть
+func (h *Hub) openedSession(ref DialogRef) (*session, error) {
+	h.mu.Lock()
+	sess, ok := h.sessions[ref.DialogID]
+	if ok && sess.ref.UserID == ref.UserID && !sess.ch.IsRxOpen() {
+		delete(h.sessions, ref.DialogID)
+		ok = false
+	}
+	h.mu.Unlock()
+	if !ok {
+		return nil, nil
+	}
+	if sess.ref.UserID != ref.UserID {
+		return nil, fmt.Errorf("%w %d: принадлежит другому пользователю", ErrDialogAccess, ref.DialogID)
+	}
+	h.startListener(sess)
+	return sess, nil
+}
+
+// lockOpen блокирует открытие диалога dialogID и возвращает разблокировку
+func (h *Hub) lockOpen(dialogID uint64) (unlock func()) {
+	h.mu.Lock()
+	l := h.opening[dialogID]
+	if l == nil {
+		l = &openLock{}
+		h.opening[dialogID] = l
+	}
+	l.refs++
+	h.mu.Unlock()
+
+	l.mu.Lock()
+	return func() {
+		l.mu.Unlock()
+		h.mu.Lock()
+		if l.refs--; l.refs == 0 {
+			delete(h.opening, dialogID)
+		}
+		h.mu.Unlock()
+	}
+}
+
+// startListener запускает Listener диалога, если он не работает (StarterListener идемпотентен)
+func (h *Hub) startListener(sess *session) {
+	h.start.StarterListener(model.StartCh{
+		Ctx:      h.ctx,
+		Provider: h.provider,
+		Model:    sess.resp,
+		Chanel:   sess.ch,
+		TreadId:  sess.ref.DialogID,
+		RespId:   sess.ref.RespID,
+	}, sess.errCh)
+}
+
+// listenerErrors логирует ошибки Listener; следующее сообщение перезапустит его
+func (h *Hub) listenerErrors(sess *session) {
+	for {
+		select {
+		case err := <-sess.errCh:
+			logger.ForDialog(sess.ref.UserID, sess.ref.DialogID).Error("gateway: ошибка Listener", "err", err)
+		case <-sess.done:
+			return
+		}
+	}
+}
+
+// fanout раздаёт TxCh диалога подписчикам, пока канал не закрыт или Hub не остановлен
+func (h *Hub) fanout(sess *session) {
+	defer func() {
+		h.mu.Lock()
+		if h.sessions[sess.ref.DialogID] == sess {
+			delete(h.sessions, sess.ref.DialogID)
+		}
+		h.mu.Unlock()
+		sess.close()
+	}()
+	for {
+		select {
+		case <-h.ctx.Done():
+			return
+		case msg, ok := <-sess.ch.TxCh:
+			if !ok {
+				return
+			}
+			if msg.Operator.SetOperator || msg.Operator.Operator {
+				sess.setOperator(true)
+			}
+			sess.touch()
+			sess.broadcast(msg)
+		}
+	}
+}
+
+func (s *session) broadcast(msg model.Message) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.seq++
+	env := Envelope{Seq: s.seq, Message: msg}
+	if len(s.history) < historySize {
+		s.history = append(s.history, env)
+	} else {
+		s.history[(s.seq-1)%historySize] = env
+	}
+	for _, sub := range s.subs {
+		if !sub.send(env) {
+			//logger.Warn("gateway: подписчик диалога %d не успевает, сообщение отброшено", s.ref.DialogID)
+		}
+	}
+}
+
+// since сообщения истории с Seq > after по порядку; gap — часть из них уже недоступна.
+// Вызывается под s.mu.
+func (s *session) since(after uint64) (replay []Envelope, gap bool) {
+	if after == 0 {
+		return nil, false
+	}
+	if after > s.seq {
+		return nil, true // номер из прежнего открытия диалога
+	}
+	first := s.seq - uint64(len(s.history)) + 1 // номер старейшего сообщения в истории
+	if after+1 < first {
+		gap = true
+	}
+	for n := max(after+1, first); n <= s.seq; n++ {
+		replay = append(replay, s.history[(n-1)%historySize])
+	}
+	return replay, gap
+}
+
+// setOperator меняет режим, возвращает true при изменении
+func (s *session) setOperator(on bool) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	changed := s.operator != on
+	s.operator = on
+	return changed
+}
+
+func (s *session) touch() {
+	s.mu.Lock()
+	s.last = time.Now()
+	s.mu.Unlock()
+}
+
+func (s *session) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+	s.closed = true
+	close(s.done)
+	for id, sub := range s.subs {
+		delete(s.subs, id)
+		sub.close()
+	}
+}
@@ -0,0 +1,174 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ikermy/AiR_Common/pkg/gateway/internal/gatewaytest"
+	"github.com/ikermy/AiR_Common/pkg/mode"
+	"github.com/ikermy/AiR_Common/pkg/model"
+)
+
+func TestHub_SendAndSubscribe(t *testing.T) {
+	defer func(v bool) { mode.TestAnswer = v }(mode.TestAnswer)
+	mode.TestAnswer = true
+
+	hub := New(context.Background(), gatewaytest.Start(), gatewaytest.Resolve, WithOwnerLookup(gatewaytest.Owner))
+	defer hub.Close()
+
+	ref := DialogRef{UserID: 1, RespID: 2, DialogID: 3}
+	msgs, unsubscribe, err := hub.Subscribe(context.Background(), ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unsubscribe()
+	if _, _, err := hub.Subscribe(context.Background(), DialogRef{UserID: 9, RespID: 2, DialogID: 3}); err == nil {
+		t.Fatal("чужой диалог: ожидали ошибку")
+	}
+
+	if err := hub.Send(context.Background(), ref, model.Message{Content: model.AssistResponse{Message: "привет"}}); err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for len(got) < 2 {
+		select {
+		case msg := <-msgs:
+			got = append(got, msg.Type)
+		case <-time.After(2 * time.Second):
+			t.Fatalf("ожидали вопрос и ответ, получили %v", got)
+		}
+	}
+	if got[0] != "user" || got[1] != "assist" {
+		t.Fatalf("неожиданная последовательность: %v", got)
+	}
+
+	st := hub.State(ref)
+	if !st.Active || st.Subscribers != 1 || st.OperatorMode || st.LastActivity.IsZero() {
+		t.Fatalf("неожиданное состояние: %+v", st)
+	}
+
+	// Возврат диалога модели уведомляет подписчиков
+	hub.mu.Lock()
+	hub.sessions[ref.DialogID].setOperator(true)
+	hub.mu.Unlock()
+	if err := hub.DisableOperatorMode(1, 3); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case msg := <-msgs:
+		if msg.Type != MsgTypeOperatorOff {
+			t.Fatalf("ожидали %s, получили %s", MsgTypeOperatorOff, msg.Type)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("нет уведомления о возврате модели")
+	}
+	if hub.State(ref).OperatorMode {
+		t.Fatal("режим оператора не снят")
+	}
+
+	unsubscribe()
+	if hub.State(ref).Subscribers != 0 {
+		t.Fatal("подписчик не удалён")
+	}
+}
+
+func TestHub_ChecksDialogOwner(t *testing.T) {
+	hub := New(context.Background(), gatewaytest.Start(), gatewaytest.Resolve, WithOwnerLookup(gatewaytest.Owner))
+	defer hub.Close()
+
+	// Диалог ещё не открыт в Hub: владелец проверяется по БД
+	for _, ref := range []DialogRef{{UserID: 9, RespID: 2, DialogID: 3}, {UserID: 1, RespID: 2, DialogID: 4}} {
+		if _, _, err := hub.Subscribe(context.Background(), ref); !errors.Is(err, ErrDialogAccess) {
+			t.Fatalf("%+v: ожидали ErrDialogAccess, получили %v", ref, err)
+		}
+	}
+	hub.mu.Lock()
+	opened := len(hub.sessions)
+	hub.mu.Unlock()
+	if opened != 0 {
+		t.Fatalf("чужой диалог открыт в Hub: %d", opened)
+	}
+
+	// Без проверки владельца Hub диалоги не открывает
+	open := New(context.Background(), gatewaytest.Start(), gatewaytest.Resolve)
+	defer open.Close()
+	if err := open.Send(context.Background(), DialogRef{UserID: 1, RespID: 2, DialogID: 3}, model.Message{}); !errors.Is(err, ErrDialogAccess) {
+		t.Fatalf("без WithOwnerLookup: ожидали ErrDialogAccess, получили %v", err)
+	}
+}
+
+func TestSession_SinceRing(t *testing.T) {
+	s := &session{subs: make(map[int]*subscriber)}
+	for range historySize + 10 {
+		s.broadcast(model.Message{Type: "assist"})
+	}
+	if replay, gap := s.since(s.seq - 3); gap || len(replay) != 3 || replay[0].Seq != s.seq-2 || replay[2].Seq != s.seq {
+		t.Fatalf("хвост истории: gap=%v %d", gap, len(replay))
+	}
+	replay, gap := s.since(5)
+	if !gap || len(replay) != historySize || replay[0].Seq != 11 {
+		t.Fatalf("вытесненная история: gap=%v %d", gap, len(replay))
+	}
+	if replay, gap := s.since(s.seq + 1); !gap || replay != nil {
+		t.Fatal("номер из прежнего открытия диалога: ожидали gap")
+	}
+	if replay, gap := s.since(0); gap || replay != nil {
+		t.Fatal("after=0: ожидали только новые сообщения")
+	}
+}
+
+func TestHub_SlowOpenDoesNotBlockOtherDialogs(t *testing.T) {
+	release := make(chan struct{})
+	var lookups atomic.Int32
+	owner := func(ctx context.Context, dialogID uint64) (uint32, error) {
+		lookups.Add(1)
+		if dialogID == 5 {
+			<-release // медленная БД для одного диалога
+		}
+		return 1, nil
+	}
+	hub := New(context.Background(), gatewaytest.Start(), gatewaytest.Resolve, WithOwnerLookup(owner))
+	defer hub.Close()
+
+	slow := make(chan error, 2)
+	for range 2 {
+		go func() {
+			_, err := hub.session(context.Background(), DialogRef{UserID: 1, RespID: 2, DialogID: 5})
+			slow <- err
+		}()
+	}
+	time.Sleep(20 * time.Millisecond)
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := hub.session(context.Background(), DialogRef{UserID: 1, RespID: 2, DialogID: 3})
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("открытие диалога ждёт медленный диалог другого запроса")
+	}
+
+	close(release)
+	for range 2 {
+		if err := <-slow; err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Параллельные запросы одного диалога открывают его один раз
+	if lookups.Load() != 2 {
+		t.Fatalf("владелец проверен %d раз, want 2", lookups.Load())
+	}
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+	if len(hub.sessions) != 2 || len(hub.opening) != 0 {
+		t.Fatalf("sessions=%d opening=%d", len(hub.sessions), len(hub.opening))
+	}
+}
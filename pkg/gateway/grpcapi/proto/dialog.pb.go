@@ -0,0 +1,863 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.11
+// 	protoc        v5.29.3
+// source: dialog.proto
+
+package proto
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// DialogRef — dialog address.
+type DialogRef struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	UserId        uint32                 `protobuf:"varint,1,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	RespId        uint64                 `protobuf:"varint,2,opt,name=resp_id,json=respId,proto3" json:"resp_id,omitempty"`
+	DialogId      uint64                 `protobuf:"varint,3,opt,name=dialog_id,json=dialogId,proto3" json:"dialog_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DialogRef) Reset() {
+	*x = DialogRef{}
+	mi := &file_dialog_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DialogRef) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DialogRef) ProtoMessage() {}
+
+func (x *DialogRef) ProtoReflect() protoreflect.Message {
+	mi := &file_dialog_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DialogRef.ProtoReflect.Descriptor instead.
+func (*DialogRef) Descriptor() ([]byte, []int) {
+	return file_dialog_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *DialogRef) GetUserId() uint32 {
+	if x != nil {
+		return x.UserId
+	}
+	return 0
+}
+
+func (x *DialogRef) GetRespId() uint64 {
+	if x != nil {
+		return x.RespId
+	}
+	return 0
+}
+
+func (x *DialogRef) GetDialogId() uint64 {
+	if x != nil {
+		return x.DialogId
+	}
+	return 0
+}
+
+// File — file sent by the assistant (model.File).
+type File struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Type          string                 `protobuf:"bytes,1,opt,name=type,proto3" json:"type,omitempty"` // photo, video, audio, doc
+	Url           string                 `protobuf:"bytes,2,opt,name=url,proto3" json:"url,omitempty"`
+	FileName      string                 `protobuf:"bytes,3,opt,name=file_name,json=fileName,proto3" json:"file_name,omitempty"`
+	Caption       string                 `protobuf:"bytes,4,opt,name=caption,proto3" json:"caption,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *File) Reset() {
+	*x = File{}
+	mi := &file_dialog_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *File) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*File) ProtoMessage() {}
+
+func (x *File) ProtoReflect() protoreflect.Message {
+	mi := &file_dialog_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use File.ProtoReflect.Descriptor instead.
+func (*File) Descriptor() ([]byte, []int) {
+	return file_dialog_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *File) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+func (x *File) GetUrl() string {
+	if x != nil {
+		return x.Url
+	}
+	return ""
+}
+
+func (x *File) GetFileName() string {
+	if x != nil {
+		return x.FileName
+	}
+	return ""
+}
+
+func (x *File) GetCaption() string {
+	if x != nil {
+		return x.Caption
+	}
+	return ""
+}
+
+// FileUpload — file attached by the user (model.FileUpload).
+type FileUpload struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	MimeType      string                 `protobuf:"bytes,2,opt,name=mime_type,json=mimeType,proto3" json:"mime_type,omitempty"`
+	Url           string                 `protobuf:"bytes,3,opt,name=url,proto3" json:"url,omitempty"`
+	Content       []byte                 `protobuf:"bytes,4,opt,name=content,proto3" json:"content,omitempty"` // used when url is empty
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *FileUpload) Reset() {
+	*x = FileUpload{}
+	mi := &file_dialog_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FileUpload) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FileUpload) ProtoMessage() {}
+
+func (x *FileUpload) ProtoReflect() protoreflect.Message {
+	mi := &file_dialog_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FileUpload.ProtoReflect.Descriptor instead.
+func (*FileUpload) Descriptor() ([]byte, []int) {
+	return file_dialog_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *FileUpload) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *FileUpload) GetMimeType() string {
+	if x != nil {
+		return x.MimeType
+	}
+	return ""
+}
+
+func (x *FileUpload) GetUrl() string {
+	if x != nil {
+		return x.Url
+	}
+	return ""
+}
+
+func (x *FileUpload) GetContent() []byte {
+	if x != nil {
+		return x.Content
+	}
+	return nil
+}
+
+// AssistResponse — message content (model.AssistResponse).
+type AssistResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Message       string                 `protobuf:"bytes,1,opt,name=message,proto3" json:"message,omitempty"`
+	SendFiles     []*File                `protobuf:"bytes,2,rep,name=send_files,json=sendFiles,proto3" json:"send_files,omitempty"`
+	Target        bool                   `protobuf:"varint,3,opt,name=target,proto3" json:"target,omitempty"`
+	Operator      bool                   `protobuf:"varint,4,opt,name=operator,proto3" json:"operator,omitempty"`
+	Confidence    *float64               `protobuf:"fixed64,5,opt,name=confidence,proto3,oneof" json:"confidence,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AssistResponse) Reset() {
+	*x = AssistResponse{}
+	mi := &file_dialog_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AssistResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AssistResponse) ProtoMessage() {}
+
+func (x *AssistResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_dialog_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AssistResponse.ProtoReflect.Descriptor instead.
+func (*AssistResponse) Descriptor() ([]byte, []int) {
+	return file_dialog_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *AssistResponse) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+func (x *AssistResponse) GetSendFiles() []*File {
+	if x != nil {
+		return x.SendFiles
+	}
+	return nil
+}
+
+func (x *AssistResponse) GetTarget() bool {
+	if x != nil {
+		return x.Target
+	}
+	return false
+}
+
+func (x *AssistResponse) GetOperator() bool {
+	if x != nil {
+		return x.Operator
+	}
+	return false
+}
+
+func (x *AssistResponse) GetConfidence() float64 {
+	if x != nil && x.Confidence != nil {
+		return *x.Confidence
+	}
+	return 0
+}
+
+// Operator — operator flags of a message (model.Operator).
+type Operator struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	SenderName    string                 `protobuf:"bytes,1,opt,name=sender_name,json=senderName,proto3" json:"sender_name,omitempty"`
+	SetOperator   bool                   `protobuf:"varint,2,opt,name=set_operator,json=setOperator,proto3" json:"set_operator,omitempty"`
+	Operator      bool                   `protobuf:"varint,3,opt,name=operator,proto3" json:"operator,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Operator) Reset() {
+	*x = Operator{}
+	mi := &file_dialog_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Operator) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Operator) ProtoMessage() {}
+
+func (x *Operator) ProtoReflect() protoreflect.Message {
+	mi := &file_dialog_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Operator.ProtoReflect.Descriptor instead.
+func (*Operator) Descriptor() ([]byte, []int) {
+	return file_dialog_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *Operator) GetSenderName() string {
+	if x != nil {
+		return x.SenderName
+	}
+	return ""
+}
+
+func (x *Operator) GetSetOperator() bool {
+	if x != nil {
+		return x.SetOperator
+	}
+	return false
+}
+
+func (x *Operator) GetOperator() bool {
+	if x != nil {
+		return x.Operator
+	}
+	return false
+}
+
+// Message — dialog message (model.Message).
+type Message struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Type          string                 `protobuf:"bytes,1,opt,name=type,proto3" json:"type,omitempty"` // user, user_voice, assist, typing, ...
+	Content       *AssistResponse        `protobuf:"bytes,2,opt,name=content,proto3" json:"content,omitempty"`
+	Name          string                 `protobuf:"bytes,3,opt,name=name,proto3" json:"name,omitempty"`
+	TimestampMs   int64                  `protobuf:"varint,4,opt,name=timestamp_ms,json=timestampMs,proto3" json:"timestamp_ms,omitempty"`
+	Operator      *Operator              `protobuf:"bytes,5,opt,name=operator,proto3" json:"operator,omitempty"`
+	ExternalId    string                 `protobuf:"bytes,6,opt,name=external_id,json=externalId,proto3" json:"external_id,omitempty"`
+	Files         []*FileUpload          `protobuf:"bytes,7,rep,name=files,proto3" json:"files,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Message) Reset() {
+	*x = Message{}
+	mi := &file_dialog_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Message) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Message) ProtoMessage() {}
+
+func (x *Message) ProtoReflect() protoreflect.Message {
+	mi := &file_dialog_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Message.ProtoReflect.Descriptor instead.
+func (*Message) Descriptor() ([]byte, []int) {
+	return file_dialog_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *Message) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+func (x *Message) GetContent() *AssistResponse {
+	if x != nil {
+		return x.Content
+	}
+	return nil
+}
+
+func (x *Message) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Message) GetTimestampMs() int64 {
+	if x != nil {
+		return x.TimestampMs
+	}
+	return 0
+}
+
+func (x *Message) GetOperator() *Operator {
+	if x != nil {
+		return x.Operator
+	}
+	return nil
+}
+
+func (x *Message) GetExternalId() string {
+	if x != nil {
+		return x.ExternalId
+	}
+	return ""
+}
+
+func (x *Message) GetFiles() []*FileUpload {
+	if x != nil {
+		return x.Files
+	}
+	return nil
+}
+
+type SendMessageRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Dialog        *DialogRef             `protobuf:"bytes,1,opt,name=dialog,proto3" json:"dialog,omitempty"`
+	Message       *Message               `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SendMessageRequest) Reset() {
+	*x = SendMessageRequest{}
+	mi := &file_dialog_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SendMessageRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SendMessageRequest) ProtoMessage() {}
+
+func (x *SendMessageRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_dialog_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SendMessageRequest.ProtoReflect.Descriptor instead.
+func (*SendMessageRequest) Descriptor() ([]byte, []int) {
+	return file_dialog_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *SendMessageRequest) GetDialog() *DialogRef {
+	if x != nil {
+		return x.Dialog
+	}
+	return nil
+}
+
+func (x *SendMessageRequest) GetMessage() *Message {
+	if x != nil {
+		return x.Message
+	}
+	return nil
+}
+
+type SendMessageResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SendMessageResponse) Reset() {
+	*x = SendMessageResponse{}
+	mi := &file_dialog_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SendMessageResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SendMessageResponse) ProtoMessage() {}
+
+func (x *SendMessageResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_dialog_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SendMessageResponse.ProtoReflect.Descriptor instead.
+func (*SendMessageResponse) Descriptor() ([]byte, []int) {
+	return file_dialog_proto_rawDescGZIP(), []int{7}
+}
+
+type StreamAnswersRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Dialog        *DialogRef             `protobuf:"bytes,1,opt,name=dialog,proto3" json:"dialog,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StreamAnswersRequest) Reset() {
+	*x = StreamAnswersRequest{}
+	mi := &file_dialog_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StreamAnswersRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StreamAnswersRequest) ProtoMessage() {}
+
+func (x *StreamAnswersRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_dialog_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StreamAnswersRequest.ProtoReflect.Descriptor instead.
+func (*StreamAnswersRequest) Descriptor() ([]byte, []int) {
+	return file_dialog_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *StreamAnswersRequest) GetDialog() *DialogRef {
+	if x != nil {
+		return x.Dialog
+	}
+	return nil
+}
+
+type SetOperatorModeRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Dialog        *DialogRef             `protobuf:"bytes,1,opt,name=dialog,proto3" json:"dialog,omitempty"`
+	Enable        bool                   `protobuf:"varint,2,opt,name=enable,proto3" json:"enable,omitempty"`
+	Text          string                 `protobuf:"bytes,3,opt,name=text,proto3" json:"text,omitempty"` // question for the operator when enabling
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SetOperatorModeRequest) Reset() {
+	*x = SetOperatorModeRequest{}
+	mi := &file_dialog_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SetOperatorModeRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SetOperatorModeRequest) ProtoMessage() {}
+
+func (x *SetOperatorModeRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_dialog_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SetOperatorModeRequest.ProtoReflect.Descriptor instead.
+func (*SetOperatorModeRequest) Descriptor() ([]byte, []int) {
+	return file_dialog_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *SetOperatorModeRequest) GetDialog() *DialogRef {
+	if x != nil {
+		return x.Dialog
+	}
+	return nil
+}
+
+func (x *SetOperatorModeRequest) GetEnable() bool {
+	if x != nil {
+		return x.Enable
+	}
+	return false
+}
+
+func (x *SetOperatorModeRequest) GetText() string {
+	if x != nil {
+		return x.Text
+	}
+	return ""
+}
+
+// DialogState — dialog state (gateway.DialogState).
+type DialogState struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	Dialog         *DialogRef             `protobuf:"bytes,1,opt,name=dialog,proto3" json:"dialog,omitempty"`
+	Active         bool                   `protobuf:"varint,2,opt,name=active,proto3" json:"active,omitempty"`
+	OperatorMode   bool                   `protobuf:"varint,3,opt,name=operator_mode,json=operatorMode,proto3" json:"operator_mode,omitempty"`
+	Subscribers    int32                  `protobuf:"varint,4,opt,name=subscribers,proto3" json:"subscribers,omitempty"`
+	LastActivityMs int64                  `protobuf:"varint,5,opt,name=last_activity_ms,json=lastActivityMs,proto3" json:"last_activity_ms,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *DialogState) Reset() {
+	*x = DialogState{}
+	mi := &file_dialog_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DialogState) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DialogState) ProtoMessage() {}
+
+func (x *DialogState) ProtoReflect() protoreflect.Message {
+	mi := &file_dialog_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DialogState.ProtoReflect.Descriptor instead.
+func (*DialogState) Descriptor() ([]byte, []int) {
+	return file_dialog_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *DialogState) GetDialog() *DialogRef {
+	if x != nil {
+		return x.Dialog
+	}
+	return nil
+}
+
+func (x *DialogState) GetActive() bool {
+	if x != nil {
+		return x.Active
+	}
+	return false
+}
+
+func (x *DialogState) GetOperatorMode() bool {
+	if x != nil {
+		return x.OperatorMode
+	}
+	return false
+}
+
+func (x *DialogState) GetSubscribers() int32 {
+	if x != nil {
+		return x.Subscribers
+	}
+	return 0
+}
+
+func (x *DialogState) GetLastActivityMs() int64 {
+	if x != nil {
+		return x.LastActivityMs
+	}
+	return 0
+}
+
+var File_dialog_proto protoreflect.FileDescriptor
+
+const file_dialog_proto_rawDesc = "" +
+	"\n" +
+	"\fdialog.proto\x12\bdialogpb\"Z\n" +
+	"\tDialogRef\x12\x17\n" +
+	"\auser_id\x18\x01 \x01(\rR\x06userId\x12\x17\n" +
+	"\aresp_id\x18\x02 \x01(\x04R\x06respId\x12\x1b\n" +
+	"\tdialog_id\x18\x03 \x01(\x04R\bdialogId\"c\n" +
+	"\x04File\x12\x12\n" +
+	"\x04type\x18\x01 \x01(\tR\x04type\x12\x10\n" +
+	"\x03url\x18\x02 \x01(\tR\x03url\x12\x1b\n" +
+	"\tfile_name\x18\x03 \x01(\tR\bfileName\x12\x18\n" +
+	"\acaption\x18\x04 \x01(\tR\acaption\"i\n" +
+	"\n" +
+	"FileUpload\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x1b\n" +
+	"\tmime_type\x18\x02 \x01(\tR\bmimeType\x12\x10\n" +
+	"\x03url\x18\x03 \x01(\tR\x03url\x12\x18\n" +
+	"\acontent\x18\x04 \x01(\fR\acontent\"\xc1\x01\n" +
+	"\x0eAssistResponse\x12\x18\n" +
+	"\amessage\x18\x01 \x01(\tR\amessage\x12-\n" +
+	"\n" +
+	"send_files\x18\x02 \x03(\v2\x0e.dialogpb.FileR\tsendFiles\x12\x16\n" +
+	"\x06target\x18\x03 \x01(\bR\x06target\x12\x1a\n" +
+	"\boperator\x18\x04 \x01(\bR\boperator\x12#\n" +
+	"\n" +
+	"confidence\x18\x05 \x01(\x01H\x00R\n" +
+	"confidence\x88\x01\x01B\r\n" +
+	"\v_confidence\"j\n" +
+	"\bOperator\x12\x1f\n" +
+	"\vsender_name\x18\x01 \x01(\tR\n" +
+	"senderName\x12!\n" +
+	"\fset_operator\x18\x02 \x01(\bR\vsetOperator\x12\x1a\n" +
+	"\boperator\x18\x03 \x01(\bR\boperator\"\x85\x02\n" +
+	"\aMessage\x12\x12\n" +
+	"\x04type\x18\x01 \x01(\tR\x04type\x122\n" +
+	"\acontent\x18\x02 \x01(\v2\x18.dialogpb.AssistResponseR\acontent\x12\x12\n" +
+	"\x04name\x18\x03 \x01(\tR\x04name\x12!\n" +
+	"\ftimestamp_ms\x18\x04 \x01(\x03R\vtimestampMs\x12.\n" +
+	"\boperator\x18\x05 \x01(\v2\x12.dialogpb.OperatorR\boperator\x12\x1f\n" +
+	"\vexternal_id\x18\x06 \x01(\tR\n" +
+	"externalId\x12*\n" +
+	"\x05files\x18\a \x03(\v2\x14.dialogpb.FileUploadR\x05files\"n\n" +
+	"\x12SendMessageRequest\x12+\n" +
+	"\x06dialog\x18\x01 \x01(\v2\x13.dialogpb.DialogRefR\x06dialog\x12+\n" +
+	"\amessage\x18\x02 \x01(\v2\x11.dialogpb.MessageR\amessage\"\x15\n" +
+	"\x13SendMessageResponse\"C\n" +
+	"\x14StreamAnswersRequest\x12+\n" +
+	"\x06dialog\x18\x01 \x01(\v2\x13.dialogpb.DialogRefR\x06dialog\"q\n" +
+	"\x16SetOperatorModeRequest\x12+\n" +
+	"\x06dialog\x18\x01 \x01(\v2\x13.dialogpb.DialogRefR\x06dialog\x12\x16\n" +
+	"\x06enable\x18\x02 \x01(\bR\x06enable\x12\x12\n" +
+	"\x04text\x18\x03 \x01(\tR\x04text\"\xc3\x01\n" +
+	"\vDialogState\x12+\n" +
+	"\x06dialog\x18\x01 \x01(\v2\x13.dialogpb.DialogRefR\x06dialog\x12\x16\n" +
+	"\x06active\x18\x02 \x01(\bR\x06active\x12#\n" +
+	"\roperator_mode\x18\x03 \x01(\bR\foperatorMode\x12 \n" +
+	"\vsubscribers\x18\x04 \x01(\x05R\vsubscribers\x12(\n" +
+	"\x10last_activity_ms\x18\x05 \x01(\x03R\x0elastActivityMs2\xab\x02\n" +
+	"\rDialogService\x12J\n" +
+	"\vSendMessage\x12\x1c.dialogpb.SendMessageRequest\x1a\x1d.dialogpb.SendMessageResponse\x12D\n" +
+	"\rStreamAnswers\x12\x1e.dialogpb.StreamAnswersRequest\x1a\x11.dialogpb.Message0\x01\x12J\n" +
+	"\x0fSetOperatorMode\x12 .dialogpb.SetOperatorModeRequest\x1a\x15.dialogpb.DialogState\x12<\n" +
+	"\x0eGetDialogState\x12\x13.dialogpb.DialogRef\x1a\x15.dialogpb.DialogStateB8Z6github.com/ikermy/AiR_Common/pkg/gateway/grpcapi/protob\x06proto3"
+
+var (
+	file_dialog_proto_rawDescOnce sync.Once
+	file_dialog_proto_rawDescData []byte
+)
+
+func file_dialog_proto_rawDescGZIP() []byte {
+	file_dialog_proto_rawDescOnce.Do(func() {
+		file_dialog_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_dialog_proto_rawDesc), len(file_dialog_proto_rawDesc)))
+	})
+	return file_dialog_proto_rawDescData
+}
+
+var file_dialog_proto_msgTypes = make([]protoimpl.MessageInfo, 11)
+var file_dialog_proto_goTypes = []any{
+	(*DialogRef)(nil),              // 0: dialogpb.DialogRef
+	(*File)(nil),                   // 1: dialogpb.File
+	(*FileUpload)(nil),             // 2: dialogpb.FileUpload
+	(*AssistResponse)(nil),         // 3: dialogpb.AssistResponse
+	(*Operator)(nil),               // 4: dialogpb.Operator
+	(*Message)(nil),                // 5: dialogpb.Message
+	(*SendMessageRequest)(nil),     // 6: dialogpb.SendMessageRequest
+	(*SendMessageResponse)(nil),    // 7: dialogpb.SendMessageResponse
+	(*StreamAnswersRequest)(nil),   // 8: dialogpb.StreamAnswersRequest
+	(*SetOperatorModeRequest)(nil), // 9: dialogpb.SetOperatorModeRequest
+	(*DialogState)(nil),            // 10: dialogpb.DialogState
+}
+var file_dialog_proto_depIdxs = []int32{
+	1,  // 0: dialogpb.AssistResponse.send_files:type_name -> dialogpb.File
+	3,  // 1: dialogpb.Message.content:type_name -> dialogpb.AssistResponse
+	4,  // 2: dialogpb.Message.operator:type_name -> dialogpb.Operator
+	2,  // 3: dialogpb.Message.files:type_name -> dialogpb.FileUpload
+	0,  // 4: dialogpb.SendMessageRequest.dialog:type_name -> dialogpb.DialogRef
+	5,  // 5: dialogpb.SendMessageRequest.message:type_name -> dialogpb.Message
+	0,  // 6: dialogpb.StreamAnswersRequest.dialog:type_name -> dialogpb.DialogRef
+	0,  // 7: dialogpb.SetOperatorModeRequest.dialog:type_name -> dialogpb.DialogRef
+	0,  // 8: dialogpb.DialogState.dialog:type_name -> dialogpb.DialogRef
+	6,  // 9: dialogpb.DialogService.SendMessage:input_type -> dialogpb.SendMessageRequest
+	8,  // 10: dialogpb.DialogService.StreamAnswers:input_type -> dialogpb.StreamAnswersRequest
+	9,  // 11: dialogpb.DialogService.SetOperatorMode:input_type -> dialogpb.SetOperatorModeRequest
+	0,  // 12: dialogpb.DialogService.GetDialogState:input_type -> dialogpb.DialogRef
+	7,  // 13: dialogpb.DialogService.SendMessage:output_type -> dialogpb.SendMessageResponse
+	5,  // 14: dialogpb.DialogService.StreamAnswers:output_type -> dialogpb.Message
+	10, // 15: dialogpb.DialogService.SetOperatorMode:output_type -> dialogpb.DialogState
+	10, // 16: dialogpb.DialogService.GetDialogState:output_type -> dialogpb.DialogState
+	13, // [13:17] is the sub-list for method output_type
+	9,  // [9:13] is the sub-list for method input_type
+	9,  // [9:9] is the sub-list for extension type_name
+	9,  // [9:9] is the sub-list for extension extendee
+	0,  // [0:9] is the sub-list for field type_name
+}
+
+func init() { file_dialog_proto_init() }
+func file_dialog_proto_init() {
+	if File_dialog_proto != nil {
+		return
+	}
+	file_dialog_proto_msgTypes[3].OneofWrappers = []any{}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_dialog_proto_rawDesc), len(file_dialog_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   11,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_dialog_proto_goTypes,
+		DependencyIndexes: file_dialog_proto_depIdxs,
+		MessageInfos:      file_dialog_proto_msgTypes,
+	}.Build()
+	File_dialog_proto = out.File
+	file_dialog_proto_goTypes = nil
+	file_dialog_proto_depIdxs = nil
+}
@@ -0,0 +1,255 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.6.2
+// - protoc             v5.29.3
+// source: dialog.proto
+
+package proto
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	DialogService_SendMessage_FullMethodName     = "/dialogpb.DialogService/SendMessage"
+	DialogService_StreamAnswers_FullMethodName   = "/dialogpb.DialogService/StreamAnswers"
+	DialogService_SetOperatorMode_FullMethodName = "/dialogpb.DialogService/SetOperatorMode"
+	DialogService_GetDialogState_FullMethodName  = "/dialogpb.DialogService/GetDialogState"
+)
+
+// DialogServiceClient is the client API for DialogService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// DialogService — startpoint dialogs for non-Go frontends.
+// Authorization: metadata header "x-service-key", if the server is started with a service key.
+type DialogServiceClient interface {
+	// SendMessage passes a user message into the dialog, opening it on first use.
+	SendMessage(ctx context.Context, in *SendMessageRequest, opts ...grpc.CallOption) (*SendMessageResponse, error)
+	// StreamAnswers streams assistant and operator messages of the dialog until the client
+	// cancels the call or the dialog is closed.
+	StreamAnswers(ctx context.Context, in *StreamAnswersRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Message], error)
+	// SetOperatorMode hands the dialog over to an operator or returns it to the model.
+	SetOperatorMode(ctx context.Context, in *SetOperatorModeRequest, opts ...grpc.CallOption) (*DialogState, error)
+	// GetDialogState returns the dialog state as seen by the gateway.
+	GetDialogState(ctx context.Context, in *DialogRef, opts ...grpc.CallOption) (*DialogState, error)
+}
+
+type dialogServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewDialogServiceClient(cc grpc.ClientConnInterface) DialogServiceClient {
+	return &dialogServiceClient{cc}
+}
+
+func (c *dialogServiceClient) SendMessage(ctx context.Context, in *SendMessageRequest, opts ...grpc.CallOption) (*SendMessageResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SendMessageResponse)
+	err := c.cc.Invoke(ctx, DialogService_SendMessage_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *dialogServiceClient) StreamAnswers(ctx context.Context, in *StreamAnswersRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Message], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &DialogService_ServiceDesc.Streams[0], DialogService_StreamAnswers_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[StreamAnswersRequest, Message]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type DialogService_StreamAnswersClient = grpc.ServerStreamingClient[Message]
+
+func (c *dialogServiceClient) SetOperatorMode(ctx context.Context, in *SetOperatorModeRequest, opts ...grpc.CallOption) (*DialogState, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DialogState)
+	err := c.cc.Invoke(ctx, DialogService_SetOperatorMode_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *dialogServiceClient) GetDialogState(ctx context.Context, in *DialogRef, opts ...grpc.CallOption) (*DialogState, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DialogState)
+	err := c.cc.Invoke(ctx, DialogService_GetDialogState_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// DialogServiceServer is the server API for DialogService service.
+// All implementations must embed UnimplementedDialogServiceServer
+// for forward compatibility.
+//
+// DialogService — startpoint dialogs for non-Go frontends.
+// Authorization: metadata header "x-service-key", if the server is started with a service key.
+type DialogServiceServer interface {
+	// SendMessage passes a user message into the dialog, opening it on first use.
+	SendMessage(context.Context, *SendMessageRequest) (*SendMessageResponse, error)
+	// StreamAnswers streams assistant and operator messages of the dialog until the client
+	// cancels the call or the dialog is closed.
+	StreamAnswers(*StreamAnswersRequest, grpc.ServerStreamingServer[Message]) error
+	// SetOperatorMode hands the dialog over to an operator or returns it to the model.
+	SetOperatorMode(context.Context, *SetOperatorModeRequest) (*DialogState, error)
+	// GetDialogState returns the dialog state as seen by the gateway.
+	GetDialogState(context.Context, *DialogRef) (*DialogState, error)
+	mustEmbedUnimplementedDialogServiceServer()
+}
+
+// UnimplementedDialogServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedDialogServiceServer struct{}
+
+func (UnimplementedDialogServiceServer) SendMessage(context.Context, *SendMessageRequest) (*SendMessageResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method SendMessage not implemented")
+}
+func (UnimplementedDialogServiceServer) StreamAnswers(*StreamAnswersRequest, grpc.ServerStreamingServer[Message]) error {
+	return status.Error(codes.Unimplemented, "method StreamAnswers not implemented")
+}
+func (UnimplementedDialogServiceServer) SetOperatorMode(context.Context, *SetOperatorModeRequest) (*DialogState, error) {
+	return nil, status.Error(codes.Unimplemented, "method SetOperatorMode not implemented")
+}
+func (UnimplementedDialogServiceServer) GetDialogState(context.Context, *DialogRef) (*DialogState, error) {
+	return nil, status.Error(codes.Unimplemented, "method GetDialogState not implemented")
+}
+func (UnimplementedDialogServiceServer) mustEmbedUnimplementedDialogServiceServer() {}
+func (UnimplementedDialogServiceServer) testEmbeddedByValue()                       {}
+
+// UnsafeDialogServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to DialogServiceServer will
+// result in compilation errors.
+type UnsafeDialogServiceServer interface {
+	mustEmbedUnimplementedDialogServiceServer()
+}
+
+func RegisterDialogServiceServer(s grpc.ServiceRegistrar, srv DialogServiceServer) {
+	// If the following call panics, it indicates UnimplementedDialogServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&DialogService_ServiceDesc, srv)
+}
+
+func _DialogService_SendMessage_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SendMessageRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DialogServiceServer).SendMessage(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: DialogService_SendMessage_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DialogServiceServer).SendMessage(ctx, req.(*SendMessageRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _DialogService_StreamAnswers_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(StreamAnswersRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(DialogServiceServer).StreamAnswers(m, &grpc.GenericServerStream[StreamAnswersRequest, Message]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type DialogService_StreamAnswersServer = grpc.ServerStreamingServer[Message]
+
+func _DialogService_SetOperatorMode_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetOperatorModeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DialogServiceServer).SetOperatorMode(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: DialogService_SetOperatorMode_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DialogServiceServer).SetOperatorMode(ctx, req.(*SetOperatorModeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _DialogService_GetDialogState_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DialogRef)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DialogServiceServer).GetDialogState(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: DialogService_GetDialogState_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DialogServiceServer).GetDialogState(ctx, req.(*DialogRef))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// DialogService_ServiceDesc is the grpc.ServiceDesc for DialogService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var DialogService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "dialogpb.DialogService",
+	HandlerType: (*DialogServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "SendMessage",
+			Handler:    _DialogService_SendMessage_Handler,
+		},
+		{
+			MethodName: "SetOperatorMode",
+			Handler:    _DialogService_SetOperatorMode_Handler,
+		},
+		{
+			MethodName: "GetDialogState",
+			Handler:    _DialogService_GetDialogState_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamAnswers",
+			Handler:       _DialogService_StreamAnswers_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "dialog.proto",
+}
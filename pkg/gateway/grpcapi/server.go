@@ -0,0 +1,224 @@
+// Package grpcapi exposes gateway.Hub (and through it startpoint.Start) as the gRPC DialogService.
+//
+// Usage:
+//
+//	hub := gateway.New(ctx, start, resolve, gateway.WithOwnerLookup(db.GetDialogOwner))
+//	srv := grpc.NewServer(grpcapi.ServerOptions("my-service-key")...)
+//	grpcapi.Register(srv, hub)
+//	_ = srv.Serve(lis)
+package grpcapi
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"errors"
+	"time"
+
+	"github.com/ikermy/AiR_Common/pkg/gateway"
+	pb "github.com/ikermy/AiR_Common/pkg/gateway/grpcapi/proto"
+	"github.com/ikermy/AiR_Common/pkg/model"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// ServiceKeyHeader metadata header with the service key (same as pkg/rpc)
+const ServiceKeyHeader = "x-service-key"
+
+// Server implements pb.DialogServiceServer on top of gateway.Hub.
+type Server struct {
+	pb.UnimplementedDialogServiceServer
+	hub *gateway.Hub
+}
+
+// Register registers DialogService for hub on srv.
+func Register(srv grpc.ServiceRegistrar, hub *gateway.Hub) *Server {
+	s := &Server{hub: hub}
+	pb.RegisterDialogServiceServer(srv, s)
+	return s
+}
+
+// ServerOptions returns interceptors that check ServiceKeyHeader; an empty key disables the check.
+func ServerOptions(serviceKey string) []grpc.ServerOption {
+	if serviceKey == "" {
+		return nil
+	}
+	return []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, h grpc.UnaryHandler) (any, error) {
+			if err := checkKey(ctx, serviceKey); err != nil {
+				return nil, err
+			}
+			return h(ctx, req)
+		}),
+		grpc.ChainStreamInterceptor(func(srv any, ss grpc.ServerStream, _ *grpc.StreamServerInfo, h grpc.StreamHandler) error {
+			if err := checkKey(ss.Context(), serviceKey); err != nil {
+				return err
+			}
+			return h(srv, ss)
+		}),
+	}
+}
+
+func checkKey(ctx context.Context, serviceKey string) error {
+	md, _ := metadata.FromIncomingContext(ctx)
+	keys := md.Get(ServiceKeyHeader)
+	if len(keys) == 0 {
+		return status.Error(codes.Unauthenticated, "service key required")
+	}
+	if subtle.ConstantTimeCompare([]byte(keys[0]), []byte(serviceKey)) != 1 {
+		return status.Error(codes.PermissionDenied, "invalid service key")
+	}
+	return nil
+}
+
+// SendMessage passes a user message into the dialog.
+func (s *Server) SendMessage(ctx context.Context, req *pb.SendMessageRequest) (*pb.SendMessageResponse, error) {
+	ref, err := dialogRef(req.GetDialog())
+	if err != nil {
+		return nil, err
+	}
+	if req.GetMessage() == nil {
+		return nil, status.Error(codes.InvalidArgument, "message required")
+	}
+	if err := s.hub.Send(ctx, ref, FromProto(req.GetMessage())); err != nil {
+		return nil, hubError(err, codes.Unavailable)
+	}
+	return &pb.SendMessageResponse{}, nil
+}
+
+// StreamAnswers streams dialog messages until the client cancels the call or the dialog closes.
+func (s *Server) StreamAnswers(req *pb.StreamAnswersRequest, stream grpc.ServerStreamingServer[pb.Message]) error {
+	ref, err := dialogRef(req.GetDialog())
+	if err != nil {
+		return err
+	}
+	msgs, unsubscribe, err := s.hub.Subscribe(stream.Context(), ref)
+	if err != nil {
+		return hubError(err, codes.Unavailable)
+	}
+	defer unsubscribe()
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case msg, ok := <-msgs:
+			if !ok {
+				return nil
+			}
+			if err := stream.Send(ToProto(msg)); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// SetOperatorMode hands the dialog over to an operator or returns it to the model.
+func (s *Server) SetOperatorMode(ctx context.Context, req *pb.SetOperatorModeRequest) (*pb.DialogState, error) {
+	ref, err := dialogRef(req.GetDialog())
+	if err != nil {
+		return nil, err
+	}
+	st, err := s.hub.SetOperatorMode(ctx, ref, req.GetEnable(), req.GetText())
+	if err != nil {
+		return nil, hubError(err, codes.FailedPrecondition)
+	}
+	return stateToProto(st), nil
+}
+
+// GetDialogState returns the dialog state.
+func (s *Server) GetDialogState(_ context.Context, req *pb.DialogRef) (*pb.DialogState, error) {
+	ref, err := dialogRef(req)
+	if err != nil {
+		return nil, err
+	}
+	return stateToProto(s.hub.State(ref)), nil
+}
+
+// hubError статус ошибки Hub: чужой или неизвестный диалог — PermissionDenied, иначе fallback
+func hubError(err error, fallback codes.Code) error {
+	if errors.Is(err, gateway.ErrDialogAccess) {
+		return status.Error(codes.PermissionDenied, err.Error())
+	}
+	return status.Error(fallback, err.Error())
+}
+
+func dialogRef(r *pb.DialogRef) (gateway.DialogRef, error) {
+	if r == nil || r.GetUserId() == 0 || r.GetDialogId() == 0 {
+		return gateway.DialogRef{}, status.Error(codes.InvalidArgument, "user_id and dialog_id required")
+	}
+	return gateway.DialogRef{UserID: r.GetUserId(), RespID: r.GetRespId(), DialogID: r.GetDialogId()}, nil
+}
+
+func stateToProto(st gateway.DialogState) *pb.DialogState {
+	out := &pb.DialogState{
+		Dialog:       &pb.DialogRef{UserId: st.UserID, RespId: st.RespID, DialogId: st.DialogID},
+		Active:       st.Active,
+		OperatorMode: st.OperatorMode,
+		Subscribers:  int32(st.Subscribers),
+	}
+	if !st.LastActivity.IsZero() {
+		out.LastActivityMs = st.LastActivity.UnixMilli()
+	}
+	return out
+}
+
+// ToProto converts model.Message to pb.Message (file content readers are not sent).
+func ToProto(m model.Message) *pb.Message {
+	out := &pb.Message{
+		Type:       m.Type,
+		Name:       m.Name,
+		ExternalId: m.ExternalID,
+		Operator:   &pb.Operator{SenderName: m.Operator.SenderName, SetOperator: m.Operator.SetOperator, Operator: m.Operator.Operator},
+		Content: &pb.AssistResponse{
+			Message:    m.Content.Message,
+			Target:     m.Content.Meta,
+			Operator:   m.Content.Operator,
+			Confidence: m.Content.Confidence,
+		},
+	}
+	if !m.Timestamp.IsZero() {
+		out.TimestampMs = m.Timestamp.UnixMilli()
+	}
+	for _, f := range m.Content.Action.SendFiles {
+		out.Content.SendFiles = append(out.Content.SendFiles, &pb.File{Type: string(f.Type), Url: f.URL, FileName: f.FileName, Caption: f.Caption})
+	}
+	for _, f := range m.Files {
+		out.Files = append(out.Files, &pb.FileUpload{Name: f.Name, MimeType: f.MimeType, Url: f.URL})
+	}
+	return out
+}
+
+// FromProto converts pb.Message to model.Message.
+func FromProto(m *pb.Message) model.Message {
+	out := model.Message{
+		Type:       m.GetType(),
+		Name:       m.GetName(),
+		ExternalID: m.GetExternalId(),
+		Operator:   model.Operator{SenderName: m.GetOperator().GetSenderName(), SetOperator: m.GetOperator().GetSetOperator(), Operator: m.GetOperator().GetOperator()},
+	}
+	if ts := m.GetTimestampMs(); ts > 0 {
+		out.Timestamp = time.UnixMilli(ts)
+	}
+	if c := m.GetContent(); c != nil {
+		out.Content = model.AssistResponse{Message: c.GetMessage(), Meta: c.GetTarget(), Operator: c.GetOperator()}
+		if c.Confidence != nil {
+			v := c.GetConfidence()
+			out.Content.Confidence = &v
+		}
+		for _, f := range c.GetSendFiles() {
+			out.Content.Action.SendFiles = append(out.Content.Action.SendFiles, model.File{
+				Type: model.FileType(f.GetType()), URL: f.GetUrl(), FileName: f.GetFileName(), Caption: f.GetCaption(),
+			})
+		}
+	}
+	for _, f := range m.GetFiles() {
+		fu := model.FileUpload{Name: f.GetName(), MimeType: f.GetMimeType(), URL: f.GetUrl()}
+		if len(f.GetContent()) > 0 {
+			fu.Content = bytes.NewReader(f.GetContent())
+		}
+		out.Files = append(out.Files, fu)
+	}
+	return out
+}
@@ -0,0 +1,63 @@
+package grpcapi
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/ikermy/AiR_Common/pkg/model"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestProtoRoundTrip(t *testing.T) {
+	conf := 0.7
+	in := model.Message{
+		Type:       "assist",
+		Name:       "Ассистент",
+		ExternalID: "42",
+		Timestamp:  time.UnixMilli(1700000000123),
+		Operator:   model.Operator{SenderName: "Анна", Operator: true},
+		Content: model.AssistResponse{
+			Message:    "готово",
+			Meta:       true,
+			Confidence: &conf,
+			Action:     model.Action{SendFiles: []model.File{{Type: model.Doc, URL: "https://x/a.pdf", FileName: "a.pdf"}}},
+		},
+		Files: []model.FileUpload{{Name: "b.png", MimeType: "image/png", URL: "https://x/b.png"}},
+	}
+	out := FromProto(ToProto(in))
+	if out.Type != in.Type || out.Name != in.Name || out.ExternalID != in.ExternalID || !out.Timestamp.Equal(in.Timestamp) ||
+		out.Operator != in.Operator || out.Content.Message != "готово" || !out.Content.Meta || *out.Content.Confidence != conf ||
+		len(out.Content.Action.SendFiles) != 1 || out.Content.Action.SendFiles[0] != in.Content.Action.SendFiles[0] ||
+		len(out.Files) != 1 || out.Files[0].URL != in.Files[0].URL {
+		t.Fatalf("round trip mismatch:\n in=%+v\nout=%+v", in, out)
+	}
+
+	pm := ToProto(model.Message{Type: "user", Files: []model.FileUpload{{Name: "c.txt"}}})
+	pm.Files[0].Content = []byte("данные")
+	data, _ := io.ReadAll(FromProto(pm).Files[0].Content)
+	if string(data) != "данные" {
+		t.Fatalf("file content lost: %q", data)
+	}
+}
+
+func TestServerOptions_ServiceKey(t *testing.T) {
+	if ServerOptions("") != nil {
+		t.Fatal("empty key must disable the check")
+	}
+	call := func(md metadata.MD) error {
+		return checkKey(metadata.NewIncomingContext(context.Background(), md), "secret")
+	}
+	if code := status.Code(call(metadata.MD{})); code != codes.Unauthenticated {
+		t.Fatalf("no key: %v", code)
+	}
+	if code := status.Code(call(metadata.Pairs(ServiceKeyHeader, "wrong"))); code != codes.PermissionDenied {
+		t.Fatalf("wrong key: %v", code)
+	}
+	if err := call(metadata.Pairs(ServiceKeyHeader, "secret")); err != nil {
+		t.Fatalf("valid key: %v", err)
+	}
+}
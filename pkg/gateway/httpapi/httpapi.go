@@ -0,0 +1,232 @@
+// Package httpapi — HTTP-обработчики диалогов gateway.Hub для веб-виджетов:
+//
+//	POST /dialogs/{id}/messages  — сообщение пользователя (sendRequest)
+//	GET  /dialogs/{id}/events    — ответы диалога потоком SSE (событие = MessageView)
+//	GET  /dialogs/{id}           — gateway.DialogState
+//	POST /dialogs/{id}/operator  — включить/выключить операторский режим (operatorRequest)
+//
+// Ассистент задаётся параметром resp_id (в теле POST или в строке запроса), пользователь —
+// Authenticator по запросу (токен виджета, сессия и т.п.).
+package httpapi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ikermy/AiR_Common/pkg/gateway"
+	"github.com/ikermy/AiR_Common/pkg/model"
+)
+
+// heartbeatInterval период комментария-пинга в потоке SSE, чтобы прокси не закрывали соединение
+const heartbeatInterval = 15 * time.Second
+
+// maxBodySize максимальный размер тела POST-запроса
+const maxBodySize = 1 << 20
+
+// Authenticator возвращает пользователя запроса; ошибка — ответ 401
+type Authenticator func(r *http.Request) (userID uint32, err error)
+
+// Option настройка Handler
+type Option func(*Handler)
+
+// WithPrefix монтирует обработчики под префиксом (например, "/api")
+func WithPrefix(prefix string) Option {
+	return func(h *Handler) { h.prefix = prefix }
+}
+
+// Handler http.Handler диалогов
+type Handler struct {
+	hub    *gateway.Hub
+	auth   Authenticator
+	prefix string
+	mux    *http.ServeMux
+}
+
+// New создаёт Handler над hub
+func New(hub *gateway.Hub, auth Authenticator, opts ...Option) *Handler {
+	h := &Handler{hub: hub, auth: auth, mux: http.NewServeMux()}
+	for _, opt := range opts {
+		opt(h)
+	}
+	h.mux.HandleFunc("POST "+h.prefix+"/dialogs/{id}/messages", h.sendMessage)
+	h.mux.HandleFunc("GET "+h.prefix+"/dialogs/{id}/events", h.events)
+	h.mux.HandleFunc("GET "+h.prefix+"/dialogs/{id}", h.state)
+	h.mux.HandleFunc("POST "+h.prefix+"/dialogs/{id}/operator", h.operator)
+	return h
+}
+
+// ServeHTTP реализует http.Handler
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+// sendRequest тело POST /dialogs/{id}/messages
+type sendRequest struct {
+	RespID     uint64             `json:"resp_id"`
+	Text       string             `json:"text"`
+	Voice      bool               `json:"voice,omitempty"`
+	Files      []model.FileUpload `json:"files,omitempty"` // по URL
+	ExternalID string             `json:"external_id,omitempty"`
+	Operator   bool               `json:"operator,omitempty"` // вопрос оператору
+}
+
+// operatorRequest тело POST /dialogs/{id}/operator
+type operatorRequest struct {
+	RespID uint64 `json:"resp_id"`
+	Enable bool   `json:"enable"`
+	Text   string `json:"text,omitempty"`
+}
+
+func (h *Handler) sendMessage(w http.ResponseWriter, r *http.Request) {
+	var req sendRequest
+	ref, ok := h.dialog(w, r, &req)
+	if !ok {
+		return
+	}
+	ref.RespID = req.RespID
+	if req.Text == "" && len(req.Files) == 0 {
+		writeError(w, http.StatusBadRequest, errors.New("пустое сообщение"))
+		return
+	}
+	msg := model.Message{
+		Type:       "user",
+		Content:    model.AssistResponse{Message: req.Text},
+		Files:      req.Files,
+		ExternalID: req.ExternalID,
+		Operator:   model.Operator{SetOperator: req.Operator},
+	}
+	if req.Voice {
+		msg.Type = "user_voice"
+	}
+	if err := h.hub.Send(r.Context(), ref, msg); err != nil {
+		writeError(w, hubStatus(err, http.StatusServiceUnavailable), err)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (h *Handler) events(w http.ResponseWriter, r *http.Request) {
+	ref, ok := h.dialog(w, r, nil)
+	if !ok {
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, errors.New("потоковая передача не поддерживается"))
+		return
+	}
+	msgs, unsubscribe, err := h.hub.Subscribe(r.Context(), ref)
+	if err != nil {
+		writeError(w, hubStatus(err, http.StatusServiceUnavailable), err)
+		return
+	}
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return
+			}
+		case msg, ok := <-msgs:
+			if !ok {
+				return
+			}
+			data, err := json.Marshal(gateway.NewMessageView(msg))
+			if err != nil {
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", msg.Type, data); err != nil {
+				return
+			}
+		}
+		flusher.Flush()
+	}
+}
+
+func (h *Handler) state(w http.ResponseWriter, r *http.Request) {
+	ref, ok := h.dialog(w, r, nil)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, h.hub.State(ref))
+}
+
+func (h *Handler) operator(w http.ResponseWriter, r *http.Request) {
+	var req operatorRequest
+	ref, ok := h.dialog(w, r, &req)
+	if !ok {
+		return
+	}
+	ref.RespID = req.RespID
+	st, err := h.hub.SetOperatorMode(r.Context(), ref, req.Enable, req.Text)
+	if err != nil {
+		writeError(w, hubStatus(err, http.StatusConflict), err)
+		return
+	}
+	writeJSON(w, http.StatusOK, st)
+}
+
+// dialog проверяет пользователя, разбирает {id}, resp_id из строки запроса и тело в body (если не nil)
+func (h *Handler) dialog(w http.ResponseWriter, r *http.Request, body any) (gateway.DialogRef, bool) {
+	userID, err := h.auth(r)
+	if err != nil || userID == 0 {
+		if err == nil {
+			err = errors.New("пользователь не определён")
+		}
+		writeError(w, http.StatusUnauthorized, err)
+		return gateway.DialogRef{}, false
+	}
+	dialogID, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+	if err != nil || dialogID == 0 {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("некорректный id диалога %q", r.PathValue("id")))
+		return gateway.DialogRef{}, false
+	}
+	ref := gateway.DialogRef{UserID: userID, DialogID: dialogID}
+	if v := r.URL.Query().Get("resp_id"); v != "" {
+		if ref.RespID, err = strconv.ParseUint(v, 10, 64); err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("некорректный resp_id %q", v))
+			return gateway.DialogRef{}, false
+		}
+	}
+	if body != nil {
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodySize)).Decode(body); err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("ошибка разбора тела запроса: %w", err))
+			return gateway.DialogRef{}, false
+		}
+	}
+	return ref, true
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// hubStatus HTTP-статус ошибки Hub: чужой или неизвестный диалог — 403, иначе fallback
+func hubStatus(err error, fallback int) int {
+	if errors.Is(err, gateway.ErrDialogAccess) {
+		return http.StatusForbidden
+	}
+	return fallback
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,122 @@
+package httpapi
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ikermy/AiR_Common/pkg/gateway"
+	"github.com/ikermy/AiR_Common/pkg/gateway/internal/gatewaytest"
+	"github.com/ikermy/AiR_Common/pkg/mode"
+)
+
+func testAuth(r *http.Request) (uint32, error) {
+	if r.Header.Get("Authorization") != "Bearer user-1" {
+		return 0, errors.New("нет токена")
+	}
+	return 1, nil
+}
+
+func do(t *testing.T, method, url, body string) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer user-1")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+func TestHandler_MessagesAndEvents(t *testing.T) {
+	defer func(v bool) { mode.TestAnswer = v }(mode.TestAnswer)
+	mode.TestAnswer = true
+
+	hub := gateway.New(context.Background(), gatewaytest.Start(), gatewaytest.Resolve, gateway.WithOwnerLookup(gatewaytest.Owner))
+	defer hub.Close()
+	srv := httptest.NewServer(New(hub, testAuth, WithPrefix("/api")))
+	defer srv.Close()
+
+	// Без авторизации и с некорректным id
+	resp, err := http.Post(srv.URL+"/api/dialogs/3/messages", "application/json", strings.NewReader(`{"text":"x"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("без токена: %d", resp.StatusCode)
+	}
+	resp = do(t, http.MethodPost, srv.URL+"/api/dialogs/abc/messages", `{"text":"x"}`)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("некорректный id: %d", resp.StatusCode)
+	}
+	resp = do(t, http.MethodPost, srv.URL+"/api/dialogs/4/messages", `{"resp_id":2,"text":"x"}`)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("чужой диалог: %d", resp.StatusCode)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/api/dialogs/3/events?resp_id=2", nil)
+	req.Header.Set("Authorization", "Bearer user-1")
+	stream, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Body.Close()
+	if ct := stream.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Content-Type: %q", ct)
+	}
+
+	resp = do(t, http.MethodPost, srv.URL+"/api/dialogs/3/messages", `{"resp_id":2,"text":"привет"}`)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("отправка: %d", resp.StatusCode)
+	}
+
+	events := make(chan gateway.MessageView, 4)
+	go func() {
+		sc := bufio.NewScanner(stream.Body)
+		for sc.Scan() {
+			if data, ok := strings.CutPrefix(sc.Text(), "data: "); ok {
+				var v gateway.MessageView
+				if json.Unmarshal([]byte(data), &v) == nil {
+					events <- v
+				}
+			}
+		}
+	}()
+	var got []string
+	for len(got) < 2 {
+		select {
+		case v := <-events:
+			got = append(got, v.Type)
+		case <-time.After(2 * time.Second):
+			t.Fatalf("ожидали вопрос и ответ, получили %v", got)
+		}
+	}
+	if got[0] != "user" || got[1] != "assist" {
+		t.Fatalf("неожиданная последовательность: %v", got)
+	}
+
+	resp = do(t, http.MethodGet, srv.URL+"/api/dialogs/3", "")
+	defer resp.Body.Close()
+	var st gateway.DialogState
+	if err := json.NewDecoder(resp.Body).Decode(&st); err != nil {
+		t.Fatal(err)
+	}
+	if !st.Active || st.RespID != 2 || st.Subscribers != 1 {
+		t.Fatalf("неожиданное состояние: %+v", st)
+	}
+}
@@ -0,0 +1,97 @@
+// Package gatewaytest — фейковые модель и Endpoint для тестов gateway и его адаптеров.
+package gatewaytest
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+
+	"github.com/ikermy/AiR_Common/pkg/comdb"
+	"github.com/ikermy/AiR_Common/pkg/endpoint"
+	"github.com/ikermy/AiR_Common/pkg/model"
+	"github.com/ikermy/AiR_Common/pkg/startpoint"
+)
+
+// Model — model.Inter, создающий по каналу на диалог
+type Model struct {
+	model.Inter
+	mu    sync.Mutex
+	resps map[uint64]*model.RespModel
+}
+
+func (m *Model) GetOrSetRespGPT(assist model.Assistant, dialogID, respId uint64, respName string) (*model.RespModel, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.resps == nil {
+		m.resps = make(map[uint64]*model.RespModel)
+	}
+	resp, ok := m.resps[respId]
+	if !ok {
+		resp = &model.RespModel{
+			Assist:   assist,
+			RespName: respName,
+			Chan:     make(map[uint64]*model.Ch),
+			Services: model.Services{Listener: new(atomic.Bool), Respondent: new(atomic.Bool)},
+		}
+		m.resps[respId] = resp
+	}
+	if _, ok := resp.Chan[dialogID]; !ok {
+		resp.Chan[dialogID] = &model.Ch{TxCh: make(chan model.Message, 16), RxCh: make(chan model.Message, 4), UserID: assist.UserID, DialogID: dialogID}
+	}
+	return resp, nil
+}
+
+func (m *Model) NewMessage(op model.Operator, msgType string, content *model.AssistResponse, _ *string, files ...model.FileUpload) model.Message {
+	return model.Message{Operator: op, Type: msgType, Content: *content, Files: files}
+}
+
+// Endpoint — endpoint.Inter в памяти
+type Endpoint struct {
+	endpoint.Inter
+	mu   sync.Mutex
+	asks map[uint64][]string
+}
+
+func (e *Endpoint) SendEvent(uint32, string, string, string, string) {}
+
+func (e *Endpoint) SetUserAsk(dialogID, _ uint64, ask string, _ ...uint32) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.asks == nil {
+		e.asks = make(map[uint64][]string)
+	}
+	e.asks[dialogID] = append(e.asks[dialogID], ask)
+	return false
+}
+
+func (e *Endpoint) GetUserAsk(dialogID, _ uint64) []string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	res := e.asks[dialogID]
+	delete(e.asks, dialogID)
+	return res
+}
+
+func (e *Endpoint) SaveDialog(comdb.CreatorType, uint64, *model.AssistResponse) {}
+
+func (e *Endpoint) GetDialogHistory(uint64, int) ([]endpoint.Message, error) { return nil, nil }
+
+// Resolve ассистент "test" пользователя userID
+func Resolve(_ context.Context, userID uint32, _ uint64) (model.Assistant, string, error) {
+	return model.Assistant{UserID: userID, AssistName: "test"}, "resp", nil
+}
+
+// Owner владелец диалога для gateway.WithOwnerLookup: диалог 3 принадлежит пользователю 1,
+// других диалогов нет
+func Owner(_ context.Context, dialogID uint64) (uint32, error) {
+	if dialogID == 3 {
+		return 1, nil
+	}
+	return 0, nil
+}
+
+// Start — startpoint.Start с пулом из одного воркера над Model и Endpoint.
+// Для ответов без модели тест включает mode.TestAnswer.
+func Start() *startpoint.Start {
+	return startpoint.New(context.Background(), &Model{}, &Endpoint{}, nil, nil, startpoint.WithWorkerPool(1))
+}
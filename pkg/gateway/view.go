@@ -0,0 +1,41 @@
+package gateway
+
+import (
+	"time"
+
+	"github.com/ikermy/AiR_Common/pkg/model"
+)
+
+// MessageView JSON-представление сообщения диалога для HTTP и WebSocket клиентов
+type MessageView struct {
+	Type         string             `json:"type"` // user, user_voice, assist, typing, operator_off, ...
+	Message      string             `json:"message,omitempty"`
+	Name         string             `json:"name,omitempty"`
+	Files        []model.File       `json:"files,omitempty"`   // файлы ассистента
+	Buttons      []model.Button     `json:"buttons,omitempty"` // кнопки под сообщением ассистента
+	QuickReplies []model.Button     `json:"quick_replies,omitempty"`
+	Uploads      []model.FileUpload `json:"uploads,omitempty"` // файлы пользователя
+	Target       bool               `json:"target,omitempty"`
+	Operator     bool               `json:"operator,omitempty"`     // ответил оператор
+	SetOperator  bool               `json:"set_operator,omitempty"` // клиенту включить операторский режим
+	SenderName   string             `json:"sender_name,omitempty"`
+	Timestamp    time.Time          `json:"timestamp"`
+}
+
+// NewMessageView представление msg для клиента
+func NewMessageView(msg model.Message) MessageView {
+	return MessageView{
+		Type:         msg.Type,
+		Message:      msg.Content.Message,
+		Name:         msg.Name,
+		Files:        msg.Content.Action.SendFiles,
+		Buttons:      msg.Content.Action.Buttons,
+		QuickReplies: msg.Content.Action.QuickReplies,
+		Uploads:      msg.Files,
+		Target:       msg.Content.Meta,
+		Operator:     msg.Operator.Operator,
+		SetOperator:  msg.Operator.SetOperator,
+		SenderName:   msg.Operator.SenderName,
+		Timestamp:    msg.Timestamp,
+	}
+}
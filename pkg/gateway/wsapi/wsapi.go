@@ -0,0 +1,291 @@
+// Package wsapi — WebSocket-шлюз диалогов gateway.Hub: одно соединение на диалог.
+//
+//	GET /dialogs/{id}/ws?resp_id=<ассистент>&last_seq=<номер>
+//
+// Сервер шлёт кадры Frame (MessageView с номером seq), клиент — кадры Inbound:
+//
+//	{"type":"message","text":"...","files":[...]}   — сообщение пользователя
+//	{"type":"operator","enable":false}              — вернуть диалог модели
+//
+// При переподключении клиент передаёт last_seq последнего полученного кадра и получает
+// пропущенные сообщения (gateway.Hub.Resume); при неполной истории первым приходит кадр
+// gateway.MsgTypeResumeGap. Соединение поддерживается ping/pong.
+package wsapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/ikermy/AiR_Common/pkg/gateway"
+	"github.com/ikermy/AiR_Common/pkg/logger"
+	"github.com/ikermy/AiR_Common/pkg/model"
+	"github.com/ikermy/AiR_Common/pkg/safego"
+)
+
+const (
+	writeWait      = 10 * time.Second
+	maxMessageSize = 1 << 20
+
+	defaultPongWait = 60 * time.Second
+)
+
+// Типы входящих кадров
+const (
+	InboundMessage  = "message"
+	InboundOperator = "operator"
+)
+
+// Authenticator возвращает пользователя запроса до апгрейда соединения; ошибка — ответ 401
+type Authenticator func(r *http.Request) (userID uint32, err error)
+
+// Frame кадр сервера
+type Frame struct {
+	Seq uint64 `json:"seq"`
+	gateway.MessageView
+}
+
+// Inbound кадр клиента
+type Inbound struct {
+	Type       string             `json:"type"` // InboundMessage, InboundOperator
+	Text       string             `json:"text,omitempty"`
+	Voice      bool               `json:"voice,omitempty"`
+	Files      []model.FileUpload `json:"files,omitempty"` // по URL
+	ExternalID string             `json:"external_id,omitempty"`
+	Operator   bool               `json:"operator,omitempty"` // вопрос оператору
+	Enable     bool               `json:"enable,omitempty"`   // для InboundOperator
+}
+
+// errorFrame кадр ошибки обработки Inbound; соединение остаётся открытым
+type errorFrame struct {
+	Type  string `json:"type"` // "error"
+	Error string `json:"error"`
+}
+
+// Option настройка Handler
+type Option func(*Handler)
+
+// WithPrefix монтирует обработчик под префиксом (например, "/api")
+func WithPrefix(prefix string) Option {
+	return func(h *Handler) { h.prefix = prefix }
+}
+
+// WithCheckOrigin проверка Origin при апгрейде (по умолчанию — правило gorilla/websocket: тот же хост)
+func WithCheckOrigin(check func(r *http.Request) bool) Option {
+	return func(h *Handler) { h.upgrader.CheckOrigin = check }
+}
+
+// WithPongWait сколько ждать pong (или любой кадр) от клиента; ping уходит через 9/10 этого времени
+func WithPongWait(d time.Duration) Option {
+	return func(h *Handler) { h.pongWait = d }
+}
+
+// WithOnClose вызывается после закрытия соединения диалога (err — причина, nil при штатном закрытии)
+func WithOnClose(fn func(ref gateway.DialogRef, err error)) Option {
+	return func(h *Handler) { h.onClose = fn }
+}
+
+// Handler http.Handler WebSocket-соединений диалогов
+type Handler struct {
+	hub      *gateway.Hub
+	auth     Authenticator
+	prefix   string
+	pongWait time.Duration
+	onClose  func(ref gateway.DialogRef, err error)
+	upgrader websocket.Upgrader
+	mux      *http.ServeMux
+}
+
+// New создаёт Handler над hub
+func New(hub *gateway.Hub, auth Authenticator, opts ...Option) *Handler {
+	h := &Handler{hub: hub, auth: auth, pongWait: defaultPongWait, mux: http.NewServeMux()}
+	for _, opt := range opts {
+		opt(h)
+	}
+	h.mux.HandleFunc("GET "+h.prefix+"/dialogs/{id}/ws", h.serveWS)
+	return h
+}
+
+// ServeHTTP реализует http.Handler
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+func (h *Handler) serveWS(w http.ResponseWriter, r *http.Request) {
+	userID, err := h.auth(r)
+	if err != nil || userID == 0 {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	ref := gateway.DialogRef{UserID: userID}
+	if ref.DialogID, err = strconv.ParseUint(r.PathValue("id"), 10, 64); err != nil || ref.DialogID == 0 {
+		http.Error(w, "некорректный id диалога", http.StatusBadRequest)
+		return
+	}
+	q := r.URL.Query()
+	if v := q.Get("resp_id"); v != "" {
+		if ref.RespID, err = strconv.ParseUint(v, 10, 64); err != nil {
+			http.Error(w, "некорректный resp_id", http.StatusBadRequest)
+			return
+		}
+	}
+	var lastSeq uint64
+	if v := q.Get("last_seq"); v != "" {
+		if lastSeq, err = strconv.ParseUint(v, 10, 64); err != nil {
+			http.Error(w, "некорректный last_seq", http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Подписка до апгрейда: ошибку открытия диалога клиент получает HTTP-статусом
+	envs, unsubscribe, err := h.hub.Resume(r.Context(), ref, lastSeq)
+	if err != nil {
+		code := http.StatusServiceUnavailable
+		if errors.Is(err, gateway.ErrDialogAccess) {
+			code = http.StatusForbidden
+		}
+		http.Error(w, err.Error(), code)
+		return
+	}
+	defer unsubscribe()
+
+	conn, err := h.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return // Upgrade уже ответил клиенту
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c := &wsConn{h: h, conn: conn, ref: ref}
+	err = c.run(ctx, cancel, envs)
+	if h.onClose != nil {
+		h.onClose(ref, err)
+	}
+}
+
+// wsConn соединение одного диалога
+type wsConn struct {
+	h    *Handler
+	conn *websocket.Conn
+	ref  gateway.DialogRef
+
+	writeMu sync.Mutex // gorilla/websocket не допускает конкурентной записи
+}
+
+// run обслуживает соединение до его закрытия, закрытия диалога или Hub
+func (c *wsConn) run(ctx context.Context, cancel context.CancelFunc, envs <-chan gateway.Envelope) error {
+	defer c.conn.Close()
+
+	var readErr error
+	readDone := make(chan struct{})
+	safego.Go("wsapi.read", func() {
+		defer close(readDone)
+		defer cancel()
+		readErr = c.readLoop(ctx)
+	}, safego.WithUserID(c.ref.UserID))
+
+	ping := time.NewTicker(c.h.pongWait * 9 / 10)
+	defer ping.Stop()
+	for {
+		select {
+		case <-readDone:
+			return normalClose(readErr)
+		case env, ok := <-envs:
+			if !ok {
+				c.closeWith(websocket.CloseGoingAway, "dialog closed")
+				return nil
+			}
+			if err := c.write(Frame{Seq: env.Seq, MessageView: gateway.NewMessageView(env.Message)}); err != nil {
+				return err
+			}
+		case <-ping.C:
+			c.writeMu.Lock()
+			err := c.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(writeWait))
+			c.writeMu.Unlock()
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// readLoop читает кадры клиента и передаёт их в Hub
+func (c *wsConn) readLoop(ctx context.Context) error {
+	c.conn.SetReadLimit(maxMessageSize)
+	_ = c.conn.SetReadDeadline(time.Now().Add(c.h.pongWait))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(c.h.pongWait))
+	})
+	for {
+		_, r, err := c.conn.NextReader()
+		if err != nil {
+			return err
+		}
+		var in Inbound
+		if err := json.NewDecoder(r).Decode(&in); err != nil {
+			// Некорректный JSON — сообщаем клиенту и читаем дальше
+			if werr := c.write(errorFrame{Type: "error", Error: fmt.Sprintf("некорректный кадр: %v", err)}); werr != nil {
+				return werr
+			}
+			continue
+		}
+		_ = c.conn.SetReadDeadline(time.Now().Add(c.h.pongWait))
+		if err := c.handle(ctx, in); err != nil {
+			logger.ForDialog(c.ref.UserID, c.ref.DialogID).Warn("wsapi: ошибка обработки кадра", "err", err)
+			if werr := c.write(errorFrame{Type: "error", Error: err.Error()}); werr != nil {
+				return werr
+			}
+		}
+	}
+}
+
+func (c *wsConn) handle(ctx context.Context, in Inbound) error {
+	switch in.Type {
+	case InboundMessage, "":
+		if in.Text == "" && len(in.Files) == 0 {
+			return errors.New("пустое сообщение")
+		}
+		msg := model.Message{
+			Type:       "user",
+			Content:    model.AssistResponse{Message: in.Text},
+			Files:      in.Files,
+			ExternalID: in.ExternalID,
+			Operator:   model.Operator{SetOperator: in.Operator},
+		}
+		if in.Voice {
+			msg.Type = "user_voice"
+		}
+		return c.h.hub.Send(ctx, c.ref, msg)
+	case InboundOperator:
+		_, err := c.h.hub.SetOperatorMode(ctx, c.ref, in.Enable, in.Text)
+		return err
+	default:
+		return fmt.Errorf("неизвестный тип кадра %q", in.Type)
+	}
+}
+
+func (c *wsConn) write(v any) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	_ = c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+	return c.conn.WriteJSON(v)
+}
+
+func (c *wsConn) closeWith(code int, text string) {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	_ = c.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, text), time.Now().Add(writeWait))
+}
+
+// normalClose штатное закрытие клиентом — не ошибка
+func normalClose(err error) error {
+	if websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+		return nil
+	}
+	return err
+}
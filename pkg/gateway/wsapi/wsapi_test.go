@@ -0,0 +1,109 @@
+package wsapi
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/ikermy/AiR_Common/pkg/gateway"
+	"github.com/ikermy/AiR_Common/pkg/gateway/internal/gatewaytest"
+	"github.com/ikermy/AiR_Common/pkg/mode"
+	"github.com/ikermy/AiR_Common/pkg/model"
+)
+
+func testAuth(r *http.Request) (uint32, error) {
+	if r.URL.Query().Get("token") != "user-1" {
+		return 0, errors.New("нет токена")
+	}
+	return 1, nil
+}
+
+func dial(t *testing.T, url string) *websocket.Conn {
+	t.Helper()
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return conn
+}
+
+func readFrames(t *testing.T, conn *websocket.Conn, n int) []Frame {
+	t.Helper()
+	var got []Frame
+	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for len(got) < n {
+		var f Frame
+		if err := conn.ReadJSON(&f); err != nil {
+			t.Fatalf("получено %d кадров из %d: %v", len(got), n, err)
+		}
+		got = append(got, f)
+	}
+	return got
+}
+
+func TestHandler_MessagesAndResume(t *testing.T) {
+	defer func(v bool) { mode.TestAnswer = v }(mode.TestAnswer)
+	mode.TestAnswer = true
+
+	hub := gateway.New(context.Background(), gatewaytest.Start(), gatewaytest.Resolve, gateway.WithOwnerLookup(gatewaytest.Owner))
+	defer hub.Close()
+	srv := httptest.NewServer(New(hub, testAuth))
+	defer srv.Close()
+	base := "ws" + strings.TrimPrefix(srv.URL, "http") + "/dialogs/3/ws?resp_id=2"
+
+	if _, resp, err := websocket.DefaultDialer.Dial(base, nil); err == nil || resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("без токена: ожидали 401, %v", err)
+	}
+
+	conn := dial(t, base+"&token=user-1")
+	if err := conn.WriteJSON(Inbound{Type: InboundMessage, Text: "привет"}); err != nil {
+		t.Fatal(err)
+	}
+	got := readFrames(t, conn, 2)
+	if got[0].Type != "user" || got[1].Type != "assist" || got[0].Seq != 1 || got[1].Seq != 2 {
+		t.Fatalf("неожиданные кадры: %+v", got)
+	}
+
+	// Некорректный кадр не закрывает соединение
+	if err := conn.WriteMessage(websocket.TextMessage, []byte("{")); err != nil {
+		t.Fatal(err)
+	}
+	if f := readFrames(t, conn, 1)[0]; f.Type != "error" {
+		t.Fatalf("ожидали кадр ошибки: %+v", f)
+	}
+	_ = conn.Close()
+
+	// Пока клиент отключён, диалог продолжается
+	ref := gateway.DialogRef{UserID: 1, RespID: 2, DialogID: 3}
+	if err := hub.Send(context.Background(), ref, model.Message{Content: model.AssistResponse{Message: "ещё вопрос"}}); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		envs, unsubscribe, err := hub.Resume(context.Background(), ref, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		n := len(envs)
+		unsubscribe()
+		if n == 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("ответ на второй вопрос не получен")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	conn = dial(t, base+"&token=user-1&last_seq=2")
+	defer conn.Close()
+	got = readFrames(t, conn, 2)
+	if got[0].Seq != 3 || got[0].Message != "ещё вопрос" || got[1].Seq != 4 || got[1].Type != "assist" {
+		t.Fatalf("неожиданные кадры после переподключения: %+v", got)
+	}
+}
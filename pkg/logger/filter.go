@@ -0,0 +1,193 @@
+package logger
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Переопределения уровня по подсистемам и сэмплирование повторяющихся сообщений.
+//
+// Подсистема определяется по пакету места вызова (record.PC), ключ — суффикс пути
+// пакета: "model/google", "startpoint". Побеждает самый длинный совпавший ключ.
+
+var (
+	levelsMu   sync.RWMutex
+	baseLevel  = slog.LevelInfo
+	overrides  map[string]slog.Level // суффикс пакета -> уровень
+	pcPackages sync.Map              // uintptr -> путь пакета
+
+	sampleFirst      atomic.Int64 // сколько записей с одного места вызова пропускать за интервал
+	sampleThereafter atomic.Int64 // далее пропускать каждую N-ю
+	samplers         sync.Map     // uintptr -> *sampler
+)
+
+// SampleInterval окно сэмплирования
+const SampleInterval = time.Second
+
+// SetModuleLevels задаёт уровни по подсистемам строкой вида
+// "model/google=debug,startpoint=warn". Пустая строка сбрасывает переопределения.
+func SetModuleLevels(spec string) error {
+	parsed := make(map[string]slog.Level)
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, lvl, ok := strings.Cut(part, "=")
+		name = strings.Trim(strings.TrimSpace(name), "/")
+		if !ok || name == "" {
+			return fmt.Errorf("некорректное переопределение уровня логирования: %q", part)
+		}
+		parsed[name] = ParseLevel(lvl)
+	}
+
+	levelsMu.Lock()
+	overrides = parsed
+	levelsMu.Unlock()
+	recalcLevel()
+	return nil
+}
+
+// SetModuleLevel задаёт уровень для одной подсистемы
+func SetModuleLevel(module, lvl string) {
+	levelsMu.Lock()
+	next := make(map[string]slog.Level, len(overrides)+1)
+	for k, v := range overrides {
+		next[k] = v
+	}
+	next[strings.Trim(module, "/")] = ParseLevel(lvl)
+	overrides = next
+	levelsMu.Unlock()
+	recalcLevel()
+}
+
+// SetSampling включает сэмплирование Warn и ниже: с одного места вызова за SampleInterval
+// пишутся первые first записей, затем каждая thereafter-я. first <= 0 отключает сэмплирование.
+// Error не сэмплируется никогда.
+func SetSampling(first, thereafter int) {
+	sampleFirst.Store(int64(first))
+	sampleThereafter.Store(int64(thereafter))
+}
+
+func setBaseLevel(l slog.Level) {
+	levelsMu.Lock()
+	baseLevel = l
+	levelsMu.Unlock()
+	recalcLevel()
+}
+
+// recalcLevel выставляет минимальный уровень обработчика с учётом переопределений,
+// чтобы Enabled пропускал записи подсистем с более подробным уровнем
+func recalcLevel() {
+	levelsMu.RLock()
+	defer levelsMu.RUnlock()
+	min := baseLevel
+	for _, l := range overrides {
+		if l < min {
+			min = l
+		}
+	}
+	level.Set(min)
+}
+
+// levelFor возвращает уровень для места вызова
+func levelFor(pc uintptr) slog.Level {
+	levelsMu.RLock()
+	defer levelsMu.RUnlock()
+	if len(overrides) == 0 || pc == 0 {
+		return baseLevel
+	}
+
+	pkg := packageOf(pc)
+	best, bestLen := baseLevel, -1
+	for name, l := range overrides {
+		if (pkg == name || strings.HasSuffix(pkg, "/"+name)) && len(name) > bestLen {
+			best, bestLen = l, len(name)
+		}
+	}
+	return best
+}
+
+// packageOf извлекает путь пакета из PC (с кэшированием)
+func packageOf(pc uintptr) string {
+	if v, ok := pcPackages.Load(pc); ok {
+		return v.(string)
+	}
+	fn := runtime.FuncForPC(pc)
+	if fn == nil {
+		return ""
+	}
+	// github.com/org/repo/pkg/model/google.(*Model).loadAgentConfig
+	name := fn.Name()
+	slash := strings.LastIndexByte(name, '/')
+	if dot := strings.IndexByte(name[slash+1:], '.'); dot >= 0 {
+		name = name[:slash+1+dot]
+	}
+	pcPackages.Store(pc, name)
+	return name
+}
+
+type sampler struct {
+	mu     sync.Mutex
+	window time.Time
+	count  int64
+}
+
+// sampleAllow решает, пропустить ли запись с данного места вызова
+func sampleAllow(pc uintptr, lvl slog.Level, now time.Time) bool {
+	first := sampleFirst.Load()
+	if first <= 0 || lvl >= slog.LevelError || pc == 0 {
+		return true
+	}
+
+	v, _ := samplers.LoadOrStore(pc, &sampler{})
+	s := v.(*sampler)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if now.Sub(s.window) >= SampleInterval {
+		s.window = now
+		s.count = 0
+	}
+	s.count++
+	if s.count <= first {
+		return true
+	}
+	if n := sampleThereafter.Load(); n > 0 {
+		return (s.count-first)%n == 0
+	}
+	return false
+}
+
+// filterHandler применяет уровни подсистем и сэмплирование перед базовым обработчиком
+type filterHandler struct {
+	inner slog.Handler
+}
+
+func (h *filterHandler) Enabled(ctx context.Context, l slog.Level) bool {
+	return h.inner.Enabled(ctx, l)
+}
+
+func (h *filterHandler) Handle(ctx context.Context, r slog.Record) error {
+	if r.Level < levelFor(r.PC) {
+		return nil
+	}
+	if !sampleAllow(r.PC, r.Level, r.Time) {
+		return nil
+	}
+	return h.inner.Handle(ctx, r)
+}
+
+func (h *filterHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &filterHandler{inner: h.inner.WithAttrs(attrs)}
+}
+
+func (h *filterHandler) WithGroup(name string) slog.Handler {
+	return &filterHandler{inner: h.inner.WithGroup(name)}
+}
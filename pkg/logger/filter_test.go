@@ -0,0 +1,53 @@
+package logger
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestModuleLevels_OverrideByPackage(t *testing.T) {
+	var buf bytes.Buffer
+	SetOutput(&buf)
+	SetLevel("error")
+	defer func() {
+		_ = SetModuleLevels("")
+		StdOut()
+	}()
+
+	if err := SetModuleLevels("startpoint=warn, logger=debug"); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+
+	Debug("видно из pkg/logger")
+	if !strings.Contains(buf.String(), "видно из pkg/logger") {
+		t.Fatalf("override not applied: %q", buf.String())
+	}
+
+	if err := SetModuleLevels("bad-spec"); err == nil {
+		t.Fatalf("expected error for malformed spec")
+	}
+}
+
+func TestSampling_RepeatedWarn(t *testing.T) {
+	var buf bytes.Buffer
+	SetOutput(&buf)
+	SetSampling(2, 5)
+	defer func() {
+		SetSampling(0, 0)
+		StdOut()
+	}()
+
+	for i := 0; i < 12; i++ {
+		Warn("RAG недоступен")
+	}
+	Error("ошибка всегда пишется")
+
+	// 2 первых + каждая 5-я из оставшихся 10 + Error
+	if got := strings.Count(buf.String(), "RAG недоступен"); got != 4 {
+		t.Fatalf("expected 4 sampled warns, got %d", got)
+	}
+	if !strings.Contains(buf.String(), "ошибка всегда пишется") {
+		t.Fatalf("error must not be sampled")
+	}
+}
@@ -0,0 +1,283 @@
+// Package logger — структурированное логирование поверх log/slog.
+//
+// Сохраняет прежний printf-API (Debug/Info/Warn/Error) с завершающим userId,
+// но под капотом пишет типизированные поля user_id, dialog_id, provider, model.
+package logger
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ikermy/AiR_Common/pkg/mode"
+)
+
+// Ключи типизированных полей
+const (
+	KeyUserID   = "user_id"
+	KeyDialogID = "dialog_id"
+	KeyProvider = "provider"
+	KeyModel    = "model"
+)
+
+var (
+	level   = new(slog.LevelVar)
+	current atomic.Pointer[slog.Logger]
+	outMu   sync.Mutex
+	out     io.Closer // открытый файл лога (nil для stdout)
+	writer  io.Writer = os.Stdout
+	format            = FormatText
+)
+
+// Форматы вывода
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+func init() {
+	current.Store(slog.New(newHandler(os.Stdout, FormatText)))
+}
+
+// Init настраивает логгер по mode.LogLevel и mode.LogPath.
+// Если LogPath пуст — вывод в stdout, иначе в файл с ротацией по mode.LogMax*.
+func Init() error {
+	SetLevel(mode.LogLevel)
+	if err := SetModuleLevels(mode.LogLevels); err != nil {
+		return err
+	}
+	SetSampling(mode.LogSampleFirst, mode.LogSampleThereafter)
+	setFormat(mode.LogFormat)
+	if mode.LogPath == "" {
+		StdOut()
+		return nil
+	}
+
+	f, err := NewRotatingFile(mode.LogPath, RotateConfig{
+		MaxSizeMB:  mode.LogMaxSizeMB,
+		MaxAgeDays: mode.LogMaxAgeDays,
+		MaxBackups: mode.LogMaxBackups,
+		Compress:   mode.LogCompress,
+	})
+	if err != nil {
+		StdOut()
+		return err
+	}
+	setOutput(f, f)
+	return nil
+}
+
+// StdOut переключает вывод логов в stdout (для контейнеров)
+func StdOut() {
+	SetLevel(mode.LogLevel)
+	setFormat(mode.LogFormat)
+	setOutput(os.Stdout, nil)
+}
+
+// SetOutput перенаправляет логи в произвольный writer (тесты, внешние сборщики)
+func SetOutput(w io.Writer) {
+	setOutput(w, nil)
+}
+
+func setOutput(w io.Writer, closer io.Closer) {
+	outMu.Lock()
+	defer outMu.Unlock()
+	if out != nil {
+		_ = out.Close()
+	}
+	out = closer
+	writer = w
+	current.Store(slog.New(newHandler(w, format)))
+}
+
+// SetFormat переключает формат вывода: text | json
+func SetFormat(f string) {
+	outMu.Lock()
+	defer outMu.Unlock()
+	format = normalizeFormat(f)
+	current.Store(slog.New(newHandler(writer, format)))
+}
+
+func setFormat(f string) {
+	outMu.Lock()
+	format = normalizeFormat(f)
+	outMu.Unlock()
+}
+
+func normalizeFormat(f string) string {
+	if strings.EqualFold(strings.TrimSpace(f), FormatJSON) {
+		return FormatJSON
+	}
+	return FormatText
+}
+
+// newHandler создаёт slog.Handler выбранного формата.
+// В JSON поля (user_id, dialog_id, ...) пишутся на верхнем уровне записи.
+func newHandler(w io.Writer, f string) slog.Handler {
+	opts := &slog.HandlerOptions{Level: level}
+	if f == FormatJSON {
+		return &filterHandler{inner: slog.NewJSONHandler(w, opts)}
+	}
+	return &filterHandler{inner: slog.NewTextHandler(w, opts)}
+}
+
+// Close закрывает файл лога, если он был открыт
+func Close() {
+	outMu.Lock()
+	defer outMu.Unlock()
+	if out != nil {
+		_ = out.Close()
+		out = nil
+	}
+}
+
+// SetLevel меняет базовый уровень логирования на лету
+func SetLevel(l string) {
+	setBaseLevel(ParseLevel(l))
+}
+
+// ParseLevel преобразует строку уровня (debug | info | warn | error) в slog.Level.
+// Неизвестное значение трактуется как info.
+func ParseLevel(l string) slog.Level {
+	switch strings.ToLower(strings.TrimSpace(l)) {
+	case "debug":
+		return slog.LevelDebug
+	case "warn", "warning":
+		return slog.LevelWarn
+	case "error":
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
+}
+
+// L возвращает текущий *slog.Logger
+func L() *slog.Logger {
+	return current.Load()
+}
+
+// With возвращает логгер с привязанными полями, например
+// logger.With(logger.UserID(id), logger.DialogID(dialogID)).Info("...")
+func With(args ...any) *slog.Logger {
+	return L().With(args...)
+}
+
+// UserID типизированное поле user_id
+func UserID(id uint32) slog.Attr {
+	return slog.Uint64(KeyUserID, uint64(id))
+}
+
+// DialogID типизированное поле dialog_id
+func DialogID(id uint64) slog.Attr {
+	return slog.Uint64(KeyDialogID, id)
+}
+
+// Provider типизированное поле provider
+func Provider(name string) slog.Attr {
+	return slog.String(KeyProvider, name)
+}
+
+// Model типизированное поле model
+func Model(name string) slog.Attr {
+	return slog.String(KeyModel, name)
+}
+
+// Debug printf-шим: logger.Debug("формат %s", arg, userId)
+func Debug(format string, args ...any) {
+	logf(slog.LevelDebug, format, args...)
+}
+
+// Info printf-шим: logger.Info("формат %s", arg, userId)
+func Info(format string, args ...any) {
+	logf(slog.LevelInfo, format, args...)
+}
+
+// Warn printf-шим: logger.Warn("формат %s", arg, userId)
+func Warn(format string, args ...any) {
+	logf(slog.LevelWarn, format, args...)
+}
+
+// Error printf-шим: logger.Error("формат %s", arg, userId)
+func Error(format string, args ...any) {
+	logf(slog.LevelError, format, args...)
+}
+
+// Fatalf логирует ошибку и завершает процесс.
+// Сигнатура совместима с mode.InitFromEnv(logger.Fatalf).
+func Fatalf(format string, args ...any) {
+	logf(slog.LevelError, format, args...)
+	Close()
+	os.Exit(1)
+}
+
+// logf форматирует сообщение и выносит лишние аргументы в поля.
+// Завершающий uint32, не покрытый глаголами формата, считается user_id;
+// прочие лишние аргументы пишутся в поле extra, а не теряются как %!(EXTRA ...).
+func logf(lvl slog.Level, format string, args ...any) {
+	l := L()
+	if !l.Enabled(context.Background(), lvl) {
+		return
+	}
+
+	n := countVerbs(format)
+	if n > len(args) {
+		n = len(args)
+	}
+
+	var attrs []slog.Attr
+	extra := args[n:]
+	if len(extra) > 0 {
+		if uid, ok := extra[len(extra)-1].(uint32); ok {
+			attrs = append(attrs, UserID(uid))
+			extra = extra[:len(extra)-1]
+		}
+	}
+	if len(extra) > 0 {
+		attrs = append(attrs, slog.Any("extra", extra))
+	}
+
+	msg := format
+	if n > 0 {
+		// %w допустим только в fmt.Errorf — в логе печатаем как %v
+		msg = fmt.Sprintf(strings.ReplaceAll(format, "%w", "%v"), args[:n]...)
+	}
+
+	// PC места вызова шима (Callers, logf, Debug/Info/...) — для уровней подсистем и сэмплирования
+	var pcs [1]uintptr
+	runtime.Callers(3, pcs[:])
+	r := slog.NewRecord(time.Now(), lvl, msg, pcs[0])
+	r.AddAttrs(attrs...)
+	_ = l.Handler().Handle(context.Background(), r)
+}
+
+// countVerbs подсчитывает количество аргументов, потребляемых строкой формата
+func countVerbs(format string) int {
+	n := 0
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			continue
+		}
+		i++
+		if i < len(format) && format[i] == '%' {
+			continue
+		}
+		// флаги, ширина, точность; '*' забирает отдельный аргумент
+		for i < len(format) && strings.IndexByte("+-# 0123456789.*[]", format[i]) >= 0 {
+			if format[i] == '*' {
+				n++
+			}
+			i++
+		}
+		if i < len(format) {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,96 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestCountVerbs(t *testing.T) {
+	cases := map[string]int{
+		"без аргументов":      0,
+		"100%% готово":        0,
+		"id=%d err=%v":        2,
+		"%-10s|%5.2f|%*d":     4,
+		"хвост без глагола %": 0,
+		"%[1]d повтор":        1,
+		"%s: %w (%d%%)":       3,
+	}
+	for format, want := range cases {
+		if got := countVerbs(format); got != want {
+			t.Fatalf("countVerbs(%q) = %d, want %d", format, got, want)
+		}
+	}
+}
+
+func TestShim_TrailingUserID(t *testing.T) {
+	var buf bytes.Buffer
+	SetOutput(&buf)
+	defer StdOut()
+
+	Warn("ошибка чтения: %v", "timeout", uint32(42))
+
+	line := buf.String()
+	if !strings.Contains(line, `msg="ошибка чтения: timeout"`) {
+		t.Fatalf("unexpected msg: %q", line)
+	}
+	if !strings.Contains(line, "user_id=42") {
+		t.Fatalf("user_id not extracted: %q", line)
+	}
+	if strings.Contains(line, "EXTRA") {
+		t.Fatalf("extra args leaked into msg: %q", line)
+	}
+}
+
+func TestShim_UserIDWithoutVerbs(t *testing.T) {
+	var buf bytes.Buffer
+	SetOutput(&buf)
+	defer StdOut()
+
+	Info("Prompt пустой в БД!", uint32(7))
+
+	line := buf.String()
+	if !strings.Contains(line, `msg="Prompt пустой в БД!"`) || !strings.Contains(line, "user_id=7") {
+		t.Fatalf("unexpected line: %q", line)
+	}
+}
+
+func TestShim_LevelFilter(t *testing.T) {
+	var buf bytes.Buffer
+	SetOutput(&buf)
+	SetLevel("warn")
+	defer func() {
+		SetLevel("info")
+		StdOut()
+	}()
+
+	Debug("скрыто %d", 1, uint32(1))
+	Info("скрыто")
+	if buf.Len() != 0 {
+		t.Fatalf("expected nothing below warn, got %q", buf.String())
+	}
+}
+
+func TestShim_JSONFormat(t *testing.T) {
+	var buf bytes.Buffer
+	SetOutput(&buf)
+	SetFormat(FormatJSON)
+	defer func() {
+		SetFormat(FormatText)
+		StdOut()
+	}()
+
+	Error("ошибка запроса к %s: %v", "google", "429", uint32(5))
+
+	var rec map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &rec); err != nil {
+		t.Fatalf("invalid json %q: %v", buf.String(), err)
+	}
+	if rec["msg"] != "ошибка запроса к google: 429" {
+		t.Fatalf("unexpected msg: %v", rec["msg"])
+	}
+	if rec[KeyUserID] != float64(5) {
+		t.Fatalf("unexpected user_id: %v", rec[KeyUserID])
+	}
+}
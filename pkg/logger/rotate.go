@@ -0,0 +1,265 @@
+package logger
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const backupTimeFormat = "2006-01-02T15-04-05.000"
+
+// RotateConfig параметры ротации файла лога.
+// Нулевое значение поля отключает соответствующее ограничение.
+type RotateConfig struct {
+	MaxSizeMB  int  // Максимальный размер файла до ротации (МБ)
+	MaxAgeDays int  // Сколько дней хранить архивные файлы
+	MaxBackups int  // Сколько архивных файлов хранить
+	Compress   bool // Сжимать архивные файлы gzip
+}
+
+// RotatingFile — io.WriteCloser с встроенной ротацией по размеру.
+// При ротации файл переименовывается в <name>-<время><ext> и открывается заново,
+// поэтому дескриптор не «теряется», как при внешнем logrotate.
+type RotatingFile struct {
+	mu       sync.Mutex
+	path     string
+	conf     RotateConfig
+	file     *os.File
+	size     int64
+	millCh   chan struct{}
+	millOnce sync.Once
+}
+
+// NewRotatingFile открывает (или создаёт) файл лога с ротацией
+func NewRotatingFile(path string, conf RotateConfig) (*RotatingFile, error) {
+	r := &RotatingFile{
+		path:   path,
+		conf:   conf,
+		millCh: make(chan struct{}, 1),
+	}
+	if err := r.openExisting(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Write реализует io.Writer
+func (r *RotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.file == nil {
+		if err := r.openExisting(); err != nil {
+			return 0, err
+		}
+	}
+
+	if max := r.maxBytes(); max > 0 && r.size+int64(len(p)) > max && r.size > 0 {
+		if err := r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+// Rotate принудительно выполняет ротацию (например, по SIGHUP)
+func (r *RotatingFile) Rotate() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.rotate()
+}
+
+// Close закрывает текущий файл
+func (r *RotatingFile) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.file == nil {
+		return nil
+	}
+	err := r.file.Close()
+	r.file = nil
+	return err
+}
+
+func (r *RotatingFile) maxBytes() int64 {
+	return int64(r.conf.MaxSizeMB) * 1024 * 1024
+}
+
+func (r *RotatingFile) openExisting() error {
+	if err := os.MkdirAll(filepath.Dir(r.path), 0o755); err != nil {
+		return fmt.Errorf("ошибка создания каталога логов: %w", err)
+	}
+	f, err := os.OpenFile(r.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("ошибка открытия файла лога %s: %w", r.path, err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return fmt.Errorf("ошибка чтения размера файла лога: %w", err)
+	}
+	r.file = f
+	r.size = info.Size()
+	return nil
+}
+
+// rotate вызывается под r.mu
+func (r *RotatingFile) rotate() error {
+	if r.file != nil {
+		if err := r.file.Close(); err != nil {
+			return fmt.Errorf("ошибка закрытия файла лога: %w", err)
+		}
+		r.file = nil
+	}
+
+	if _, err := os.Stat(r.path); err == nil {
+		if err := os.Rename(r.path, r.backupName(time.Now())); err != nil {
+			return fmt.Errorf("ошибка ротации файла лога: %w", err)
+		}
+	}
+
+	if err := r.openExisting(); err != nil {
+		return err
+	}
+	r.startMill()
+	return nil
+}
+
+func (r *RotatingFile) backupName(t time.Time) string {
+	dir := filepath.Dir(r.path)
+	ext := filepath.Ext(r.path)
+	base := strings.TrimSuffix(filepath.Base(r.path), ext)
+	return filepath.Join(dir, fmt.Sprintf("%s-%s%s", base, t.Format(backupTimeFormat), ext))
+}
+
+// startMill запускает фоновую очистку/сжатие архивов (одна горутина на файл)
+func (r *RotatingFile) startMill() {
+	r.millOnce.Do(func() {
+		go func() {
+			for range r.millCh {
+				_ = r.millRun()
+			}
+		}()
+	})
+	select {
+	case r.millCh <- struct{}{}:
+	default:
+	}
+}
+
+type backupFile struct {
+	path string
+	t    time.Time
+}
+
+// millRun сжимает новые архивы и удаляет лишние по MaxBackups / MaxAgeDays
+func (r *RotatingFile) millRun() error {
+	backups, err := r.listBackups()
+	if err != nil {
+		return err
+	}
+
+	var remove []backupFile
+	if r.conf.MaxBackups > 0 && len(backups) > r.conf.MaxBackups {
+		remove = append(remove, backups[r.conf.MaxBackups:]...)
+		backups = backups[:r.conf.MaxBackups]
+	}
+	if r.conf.MaxAgeDays > 0 {
+		cutoff := time.Now().Add(-time.Duration(r.conf.MaxAgeDays) * 24 * time.Hour)
+		kept := backups[:0]
+		for _, b := range backups {
+			if b.t.Before(cutoff) {
+				remove = append(remove, b)
+			} else {
+				kept = append(kept, b)
+			}
+		}
+		backups = kept
+	}
+
+	for _, b := range remove {
+		_ = os.Remove(b.path)
+	}
+
+	if r.conf.Compress {
+		for _, b := range backups {
+			if !strings.HasSuffix(b.path, ".gz") {
+				if err := compressFile(b.path); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// listBackups возвращает архивы, отсортированные от новых к старым
+func (r *RotatingFile) listBackups() ([]backupFile, error) {
+	dir := filepath.Dir(r.path)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения каталога логов: %w", err)
+	}
+
+	ext := filepath.Ext(r.path)
+	prefix := strings.TrimSuffix(filepath.Base(r.path), ext) + "-"
+
+	var backups []backupFile
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		name := e.Name()
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		ts := strings.TrimSuffix(strings.TrimSuffix(strings.TrimPrefix(name, prefix), ".gz"), ext)
+		t, err := time.Parse(backupTimeFormat, ts)
+		if err != nil {
+			continue
+		}
+		backups = append(backups, backupFile{path: filepath.Join(dir, name), t: t})
+	}
+
+	sort.Slice(backups, func(i, j int) bool { return backups[i].t.After(backups[j].t) })
+	return backups, nil
+}
+
+func compressFile(src string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("ошибка открытия архива лога: %w", err)
+	}
+	defer in.Close()
+
+	dst := src + ".gz"
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("ошибка создания сжатого архива: %w", err)
+	}
+
+	gz := gzip.NewWriter(out)
+	if _, err := io.Copy(gz, in); err != nil {
+		_ = out.Close()
+		_ = os.Remove(dst)
+		return fmt.Errorf("ошибка сжатия архива лога: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		_ = out.Close()
+		_ = os.Remove(dst)
+		return fmt.Errorf("ошибка сжатия архива лога: %w", err)
+	}
+	if err := out.Close(); err != nil {
+		return fmt.Errorf("ошибка закрытия сжатого архива: %w", err)
+	}
+	return os.Remove(src)
+}
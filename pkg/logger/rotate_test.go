@@ -0,0 +1,52 @@
+package logger
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRotatingFile_RotatesBySize(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "bot.log")
+
+	r, err := NewRotatingFile(path, RotateConfig{MaxSizeMB: 1, MaxBackups: 1})
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	defer r.Close()
+
+	chunk := bytes.Repeat([]byte("x"), 700*1024)
+	for i := 0; i < 3; i++ {
+		if _, err := r.Write(chunk); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+		time.Sleep(2 * time.Millisecond) // уникальные имена архивов
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("stat: %v", err)
+	}
+	if info.Size() != int64(len(chunk)) {
+		t.Fatalf("expected fresh file after rotation, size=%d", info.Size())
+	}
+
+	// mill работает в фоне — ждём, пока останется MaxBackups архивов
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		backups, err := r.listBackups()
+		if err != nil {
+			t.Fatalf("list: %v", err)
+		}
+		if len(backups) == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected 1 backup, got %d", len(backups))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,89 @@
+package logger
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"sync"
+)
+
+// KeyTraceID ключ поля идентификатора хода (одного сообщения пользователя)
+const KeyTraceID = "trace_id"
+
+type traceKey struct{}
+
+// dialogTraces хранит trace_id текущего хода для каждого диалога: dialogID -> string.
+// Respondent обрабатывает диалог последовательно, поэтому у диалога в каждый момент
+// не больше одного активного хода — это позволяет провайдерам получить trace_id
+// по dialogID без изменения сигнатур model.Inter.
+var dialogTraces sync.Map
+
+// NewTraceID генерирует короткий случайный идентификатор хода
+func NewTraceID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b)
+}
+
+// TraceID типизированное поле trace_id
+func TraceID(id string) slog.Attr {
+	return slog.String(KeyTraceID, id)
+}
+
+// WithTraceID кладёт trace_id в контекст
+func WithTraceID(ctx context.Context, id string) context.Context {
+	if id == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, traceKey{}, id)
+}
+
+// TraceIDFrom извлекает trace_id из контекста
+func TraceIDFrom(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	id, _ := ctx.Value(traceKey{}).(string)
+	return id
+}
+
+// FromContext возвращает логгер с trace_id из контекста (если он есть)
+func FromContext(ctx context.Context) *slog.Logger {
+	if id := TraceIDFrom(ctx); id != "" {
+		return L().With(TraceID(id))
+	}
+	return L()
+}
+
+// BindDialog привязывает trace_id текущего хода к диалогу
+func BindDialog(dialogID uint64, id string) {
+	if id == "" {
+		return
+	}
+	dialogTraces.Store(dialogID, id)
+}
+
+// UnbindDialog удаляет привязку trace_id диалога
+func UnbindDialog(dialogID uint64) {
+	dialogTraces.Delete(dialogID)
+}
+
+// DialogTrace возвращает trace_id текущего хода диалога
+func DialogTrace(dialogID uint64) string {
+	if v, ok := dialogTraces.Load(dialogID); ok {
+		return v.(string)
+	}
+	return ""
+}
+
+// ForDialog возвращает логгер с полями user_id, dialog_id и trace_id текущего хода
+func ForDialog(userID uint32, dialogID uint64) *slog.Logger {
+	l := L().With(UserID(userID), DialogID(dialogID))
+	if id := DialogTrace(dialogID); id != "" {
+		l = l.With(TraceID(id))
+	}
+	return l
+}
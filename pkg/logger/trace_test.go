@@ -0,0 +1,45 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestForDialog_AttachesTraceID(t *testing.T) {
+	var buf bytes.Buffer
+	SetOutput(&buf)
+	defer StdOut()
+
+	id := NewTraceID()
+	if len(id) != 16 {
+		t.Fatalf("unexpected trace id: %q", id)
+	}
+	BindDialog(10, id)
+	defer UnbindDialog(10)
+
+	ForDialog(3, 10).Info("ход")
+
+	line := buf.String()
+	for _, want := range []string{"user_id=3", "dialog_id=10", "trace_id=" + id} {
+		if !strings.Contains(line, want) {
+			t.Fatalf("missing %q in %q", want, line)
+		}
+	}
+}
+
+func TestFromContext(t *testing.T) {
+	var buf bytes.Buffer
+	SetOutput(&buf)
+	defer StdOut()
+
+	ctx := WithTraceID(context.Background(), "abc")
+	if TraceIDFrom(ctx) != "abc" {
+		t.Fatalf("trace id not stored in context")
+	}
+	FromContext(ctx).Warn("ошибка")
+	if !strings.Contains(buf.String(), "trace_id=abc") {
+		t.Fatalf("trace id not logged: %q", buf.String())
+	}
+}
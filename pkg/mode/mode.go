@@ -15,18 +15,27 @@ const (
 	// BatchSize Endpoint
 	BatchSize         = 100
 	TimePeriodicFlush = 60
-	// Retry settings
-	RetryMaxAttempts = 3 // Максимальное количество повторных попыток
-	RetryBaseDelay   = 1 // Базовая задержка между попытками в секундах
 	// Mistral API settings
 	MistralBaseURL          = "https://api.mistral.ai/v1"
 	MistralAgentsBaseURL    = MistralBaseURL + "/agents"
 	MistralAgentsURL        = MistralAgentsBaseURL + "/completions"
 	MistralConversationsURL = MistralBaseURL + "/conversations"
+	MistralOCRURL           = MistralBaseURL + "/ocr"
+	MistralModerationsURL   = MistralBaseURL + "/moderations"
 	// Google API settings
 	GoogleAgentsURL = "https://generativelanguage.googleapis.com/v1beta"
 	// OpenAI API settings
 	OpenAIAgentsURL = "https://api.openai.com/v1"
+	// OpenRouter API settings
+	OpenRouterBaseURL = "https://openrouter.ai/api/v1"
+	// Yandex Cloud Foundation Models API settings
+	YandexLLMURL = "https://llm.api.cloud.yandex.net/foundationModels/v1"
+	YandexIAMURL = "https://iam.api.cloud.yandex.net/iam/v1/tokens"
+	// GigaChat API settings
+	GigaChatBaseURL  = "https://gigachat.devices.sberbank.ru/api/v1"
+	GigaChatOAuthURL = "https://ngw.devices.sberbank.ru:9443/api/v2/oauth"
+	// xAI Grok API settings
+	GrokBaseURL = "https://api.x.ai/v1"
 )
 
 var (
@@ -39,18 +48,201 @@ var (
 	InstantCh    = make(chan com.InstMsg, 1) // Канал для передачи мгновенных сообщений в панель управления
 	RealHost     string
 
+	// Атрибуция приложения в OpenRouter (заголовки HTTP-Referer и X-Title); пустые не передаются
+	OpenRouterAppURL  = "" // OPENROUTER_APP_URL
+	OpenRouterAppName = "" // OPENROUTER_APP_NAME
+
+	// Локальный сервер Ollama (on-prem, без внешней сети)
+	OllamaBaseURL    = "http://localhost:11434" // OLLAMA_URL
+	OllamaEmbedModel = "nomic-embed-text"       // OLLAMA_EMBED_MODEL
+
+	// GigaChat: сертификаты API выданы НУЦ Минцифры (Russian Trusted Root CA), которого нет
+	// в системном хранилище большинства образов — путь к PEM добавляется к системным корням
+	GigaChatCAFile      = ""                  // GIGACHAT_CA_FILE
+	GigaChatScope       = "GIGACHAT_API_PERS" // GIGACHAT_SCOPE; по умолчанию для ключа без префикса scope
+	GigaChatInsecureTLS = false               // GIGACHAT_INSECURE_TLS — только для отладки
+
+	// Явный кэш контекста Gemini (UniversalModelData.ContextCache): время жизни кэша в Google
+	GoogleContextCacheTTL = time.Hour // GOOGLE_CONTEXT_CACHE_TTL, минуты
+
 	// Operator settings
-	// Таймаут ожидания ПЕРВОГО ответа оператора в секундах
+	// Таймаут ожидания ПЕРВОГО ответа оператора в секундах, если у ассистента не задан Operator.Timeout.
 	// После первого ответа операторский режим становится постоянным (без таймера)
 	OperatorResponseTimeout = 120
 
+	// Очередь к операторам (startpoint.WithEscalationQueue)
+	EscalationPollInterval = 5 * time.Second  // период проверки освободившихся операторов
+	EscalationQueueTTL     = 30 * time.Minute // запись неактивного диалога удаляется из очереди
+
+	// Контекст диалога для оператора при включении операторского режима
+	OperatorTranscriptTurns   = 10  // последних сообщений целиком, если у ассистента не задан Operator.Transcript
+	OperatorTranscriptChars   = 300 // максимум символов одного сообщения
+	OperatorTranscriptHistory = 50  // сколько сообщений истории читать (ранние уходят в сводку)
+
+	// Исходящие webhook (pkg/webhook)
+	WebhookWorkers     = 4                // воркеров доставки
+	WebhookQueueSize   = 1000             // событий в очереди; при переполнении — сразу в dead-letter
+	WebhookMaxAttempts = 5                // попыток доставки до dead-letter
+	WebhookRetryDelay  = 2 * time.Second  // задержка перед первым повтором, далее удваивается
+	WebhookTimeout     = 10 * time.Second // тайм-аут одного запроса
+
+	// Транспорт Ch через брокер сообщений (pkg/transport)
+	TransportQuestions   = "air.questions" // топик сообщений пользователя (RxCh)
+	TransportAnswers     = "air.answers"   // топик ответов ассистента (TxCh)
+	TransportRetryDelay  = time.Second     // пауза перед повторной обработкой неподтверждённого сообщения
+	TransportMaxAttempts = 5               // попыток обработки сообщения; после последней оно пропускается с записью в лог
+	TransportLanes       = 16              // разделов топика NATS (одинаково у всех экземпляров); диалог всегда в одном
+	TransportMaxAge      = 24 * time.Hour  // срок хранения сообщений в потоке NATS JetStream
+	// Группы подписки (transport.WithGroup переопределяет). Пустая группа Client — "air-client-<hostname>":
+	// у каждого фронтенда своя группа, стабильная между перезапусками
+	TransportClientGroup = ""            // TRANSPORT_CLIENT_GROUP
+	TransportWorkerGroup = "air-workers" // TRANSPORT_WORKER_GROUP
+
+	// Дедупликация входящих сообщений в Listener
+	DedupTTL        = 10 * time.Minute // по ExternalID сообщения
+	DedupContentTTL = 10 * time.Second // по хэшу содержимого, если ExternalID не задан
+
+	// Накопленные вопросы диалога старше этого срока после перезапуска не восстанавливаются
+	// (startpoint.WithPendingAskStore)
+	PendingAskTTL = time.Hour
+
+	// Операторский режим, включённый раньше этого срока, после перезапуска не восстанавливается
+	// (startpoint.WithOperatorStateStore)
+	OperatorStateTTL = 24 * time.Hour
+
+	// Отправлять в TxCh события "typing"/"typing_done" на время запроса к модели
+	TypingEvents = true // TYPING_EVENTS
+
+	// Лимиты размера файлов пользователя до отправки провайдеру, МБ (model.DefaultFileSizeLimits; 0 — без лимита)
+	UploadLimitPhotoMB = 20  // UPLOAD_LIMIT_PHOTO
+	UploadLimitVideoMB = 100 // UPLOAD_LIMIT_VIDEO
+	UploadLimitAudioMB = 25  // UPLOAD_LIMIT_AUDIO: лимит распознавания речи OpenAI
+	UploadLimitDocMB   = 50  // UPLOAD_LIMIT_DOC
+
+	// Обработка изображений пользователя и сгенерированных изображений (model.DefaultImageOptions)
+	ImageMaxDimension = 0          // IMAGE_MAX_DIMENSION: наибольшая сторона в пикселях, 0 — без уменьшения
+	ImageJPEGQuality  = 85         // IMAGE_JPEG_QUALITY
+	ImageFormat       = ""         // IMAGE_FORMAT: jpeg или png, пусто — исходный формат
+	ImageMaxPixels    = 40_000_000 // IMAGE_MAX_PIXELS: изображения больше (ширина×высота) не декодируются
+
+	// Одновременные запросы к провайдерам (model.WithConcurrencyLimits; 0 — без ограничения).
+	// Сверх лимита запрос ждёт свободного места в очереди до тайм-аута ожидания ответа
+	ProviderMaxConcurrent     = 0 // PROVIDER_MAX_CONCURRENT: всего по всем провайдерам
+	ProviderMaxConcurrentEach = 0 // PROVIDER_MAX_CONCURRENT_EACH: на каждый провайдер
+
+	// Лимит входящих сообщений в минуту (0 — без ограничения)
+	RateLimitPerDialog = 30 // RATE_LIMIT_DIALOG: на диалог
+	RateLimitPerUser   = 0  // RATE_LIMIT_USER: на пользователя (все диалоги ассистента)
+
+	// Период опроса отложенных сообщений (startpoint.WithScheduleStore)
+	SchedulePollInterval = 15 * time.Second
+	// Предельная пауза перед повторной доставкой отложенного сообщения: пауза удваивается
+	// с каждой неудачной попыткой, начиная с SchedulePollInterval
+	ScheduleRetryMaxDelay = 10 * time.Minute
+
+	// Опрос удовлетворённости после диалога (startpoint.WithCSATSurvey)
+	CSATTargetDelay = 5 * time.Second // пауза после ответа, достигшего цели
+	CSATReplyWindow = 24 * time.Hour  // сколько ждать оценку пользователя
+
+	// Сжатие истории диалогов (startpoint.WithDialogCompaction): ранние сообщения давно
+	// неактивных длинных диалогов заменяются сводкой
+	DialogCompactInterval     = time.Hour
+	DialogCompactIdle         = 24 * time.Hour // без новых сообщений дольше этого срока
+	DialogCompactMinBytes     = 64 << 10       // DIALOG_COMPACT_MIN_BYTES: сжимаются диалоги с Data длиннее
+	DialogCompactKeep         = 20             // DIALOG_COMPACT_KEEP: последние сообщения остаются целиком
+	DialogCompactBatch        = 100            // диалогов за один проход
+	DialogCompactSummaryChars = 4000           // предел сводки без WithDialogCompaction summarizer
+	// Неудачное сжатие диалога повторяется не раньше Backoff; после MaxAttempts неудач диалог
+	// отмечается сжатым и ждёт новых сообщений, чтобы не занимать место в каждом проходе
+	DialogCompactRetry = RetryRule{MaxAttempts: 5, BaseDelay: time.Hour, MaxDelay: 24 * time.Hour}
+
+	// Период опроса пакетных заданий (model.BatchManager) — пакеты выполняются часами
+	BatchPollInterval = time.Minute
+
+	// Семантический кэш ответов (model.WithSemanticCache)
+	SemanticCacheThreshold  = float32(0.95) // минимальная косинусная близость вопросов
+	SemanticCacheTTL        = 24 * time.Hour
+	SemanticCacheMaxEntries = 1000 // на модель; старые записи вытесняются
+
+	// Нечёткие триггеры "~слово" (create.CompileTriggers): опечаток в слове не больше
+	TriggerFuzzyDistance = 2
+
+	// Классификатор намерений перед запросом к модели (startpoint.WithIntentRouting)
+	IntentMinScore = float32(0.8) // минимальная уверенность намерения (косинусная близость к центроиду)
+	IntentTimeout  = 3 * time.Second
+
+	// Тайм-аут проверки фрагмента базы знаний моделью (model.LLMInjectionClassifier)
+	InjectionCheckTimeout = 5 * time.Second
+
+	// Тайм-аут оценки тональности моделью (startpoint.LLMSentiment)
+	SentimentTimeout = 5 * time.Second
+
+	// Разметка тем завершённых диалогов (startpoint.WithTopicTagging)
+	TopicHistoryMessages = 40               // последних сообщений диалога для разметки
+	TopicMaxPerDialog    = 3                // тем на диалог
+	TopicTimeout         = 15 * time.Second // тайм-аут разметки моделью (startpoint.LLMTopicTagger)
+
+	// Тайм-аут исправления ответа модели под схему (model.LLMSchemaRepair)
+	SchemaRepairTimeout = 20 * time.Second
+
+	// Тайм-аут антивирусной проверки одного файла (model.WithFileScanner)
+	FileScanTimeout = 30 * time.Second
+
+	// Тайм-аут чтения сигнатур файлов send_files перед отправкой (model.CorrectFileTypes)
+	FileSniffTimeout = 3 * time.Second
+
+	// Конвертация аудио через ffmpeg (pkg/audio)
+	FFmpegPath          = "ffmpeg"    // FFMPEG_PATH
+	AudioConvertTimeout = time.Minute // тайм-аут одной конвертации
+
+	// Сгенерированные медиафайлы в объектном хранилище (pkg/storage)
+	MediaUploadTTL     = 15 * time.Minute   // срок действия presigned URL загрузки
+	MediaURLTTL        = 7 * 24 * time.Hour // срок действия presigned ссылки на файл (максимум SigV4)
+	MediaUploadTimeout = 2 * time.Minute    // тайм-аут загрузки одного файла
+
+	// Проверка размера запроса перед отправкой провайдеру (create.FitHistory)
+	ContextOutputReserve  = 4096             // токенов контекста оставляется на ответ модели
+	GoogleMediaPartTokens = 258              // токенов Gemini на изображение или фрагмент вложения
+	GoogleModelLimitRetry = 10 * time.Minute // через сколько повторить models.get после ошибки (до этого — ContextWindow)
+
+	// Google File API (create.GoogleAgentClient.UploadFile)
+	GoogleInlineDataLimit   = 20 << 20        // больше — файл загружается в File API вместо inline_data
+	GoogleUploadChunkSize   = 8 << 20         // часть resumable-загрузки, кратна 256 КиБ
+	GoogleFileActiveTimeout = 5 * time.Minute // ожидание обработки загруженного файла (видео)
+
+	// Очистка Google File API (create.GoogleAgentClient.FilesJanitor): файлы старше
+	// GoogleFilesRetention удаляются раз в GoogleFilesJanitorInterval; 0 — очистка отключена
+	GoogleFilesRetention       = 6 * time.Hour // GOOGLE_FILES_RETENTION, минуты
+	GoogleFilesJanitorInterval = time.Hour
+
+	// Скорость рассылки startpoint.Broadcast по умолчанию, сообщений в секунду
+	BroadcastRate = 20
+
+	// Максимальная длина текста сообщения Telegram в единицах UTF-16 (startpoint.WithMessageSplit)
+	TelegramMessageLimit = 4096
+
+	// Максимальное время drain при завершении: ожидание активных запросов к модели и сохранения диалогов
+	ShutdownDrainTimeout = 30 * time.Second
+
 	// Тайм-аут на операции с БД (в секундах)
 	SqlTimeToCancel = 5 * time.Second
 	UserModelTTl    = 5 * time.Minute
 
 	// Логирование — инициализируются через InitFromEnv()
-	LogLevel = "info" // LOG_LEVEL: debug | info | warn | error
-	LogPath  = ""     // LOG_PATH: путь к файлу лога, не используется в режиме logger.StdOut()
+	LogLevel  = "info" // LOG_LEVEL: debug | info | warn | error
+	LogPath   = ""     // LOG_PATH: путь к файлу лога, не используется в режиме logger.StdOut()
+	LogFormat = "text" // LOG_FORMAT: text | json (json — для Loki/ELK)
+	LogLevels = ""     // LOG_LEVELS: уровни подсистем, например "model/google=debug,startpoint=warn"
+
+	// Сэмплирование повторяющихся Warn и ниже (с одного места вызова за секунду)
+	LogSampleFirst      = 0   // LOG_SAMPLE_FIRST: сколько записей писать без сэмплирования (0 — выключено)
+	LogSampleThereafter = 100 // LOG_SAMPLE_THEREAFTER: далее писать каждую N-ю
+
+	// Ротация файла лога (0 — без ограничения)
+	LogMaxSizeMB  = 100  // LOG_MAX_SIZE: размер файла до ротации, МБ
+	LogMaxAgeDays = 30   // LOG_MAX_AGE: срок хранения архивов, дни
+	LogMaxBackups = 10   // LOG_MAX_BACKUPS: количество архивов
+	LogCompress   = true // LOG_COMPRESS: сжимать архивы gzip
 )
 
 func SetTextMode(enabled bool) {
@@ -95,9 +287,80 @@ func InitFromEnv(fatal func(format string, args ...any)) {
 		}
 	}
 
+	// Drain при завершении (секунды)
+	if v := os.Getenv("SHUTDOWN_DRAIN_TIMEOUT"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			fatal("mode.InitFromEnv: SHUTDOWN_DRAIN_TIMEOUT содержит некорректное значение: %q", v)
+		} else {
+			ShutdownDrainTimeout = time.Duration(n) * time.Second
+		}
+	}
+
+	// Лимиты входящих сообщений
+	RateLimitPerDialog = envInt("RATE_LIMIT_DIALOG", RateLimitPerDialog, fatal)
+	RateLimitPerUser = envInt("RATE_LIMIT_USER", RateLimitPerUser, fatal)
+
+	// Одновременные запросы к провайдерам
+	ProviderMaxConcurrent = envInt("PROVIDER_MAX_CONCURRENT", ProviderMaxConcurrent, fatal)
+	ProviderMaxConcurrentEach = envInt("PROVIDER_MAX_CONCURRENT_EACH", ProviderMaxConcurrentEach, fatal)
+
+	// Сжатие истории диалогов
+	DialogCompactMinBytes = envInt("DIALOG_COMPACT_MIN_BYTES", DialogCompactMinBytes, fatal)
+	DialogCompactKeep = envInt("DIALOG_COMPACT_KEEP", DialogCompactKeep, fatal)
+
+	// Лимиты размера файлов пользователя (МБ)
+	UploadLimitPhotoMB = envInt("UPLOAD_LIMIT_PHOTO", UploadLimitPhotoMB, fatal)
+	UploadLimitVideoMB = envInt("UPLOAD_LIMIT_VIDEO", UploadLimitVideoMB, fatal)
+	UploadLimitAudioMB = envInt("UPLOAD_LIMIT_AUDIO", UploadLimitAudioMB, fatal)
+	UploadLimitDocMB = envInt("UPLOAD_LIMIT_DOC", UploadLimitDocMB, fatal)
+	ImageMaxDimension = envInt("IMAGE_MAX_DIMENSION", ImageMaxDimension, fatal)
+	ImageJPEGQuality = envInt("IMAGE_JPEG_QUALITY", ImageJPEGQuality, fatal)
+	ImageFormat = envVal("IMAGE_FORMAT", ImageFormat)
+	ImageMaxPixels = envInt("IMAGE_MAX_PIXELS", ImageMaxPixels, fatal)
+	FFmpegPath = envVal("FFMPEG_PATH", FFmpegPath)
+
 	// Логирование — дефолты из var
 	LogLevel = envVal("LOG_LEVEL", LogLevel)
 	LogPath = envVal("LOG_PATH", LogPath)
+	LogFormat = envVal("LOG_FORMAT", LogFormat)
+	LogLevels = envVal("LOG_LEVELS", LogLevels)
+	LogSampleFirst = envInt("LOG_SAMPLE_FIRST", LogSampleFirst, fatal)
+	LogSampleThereafter = envInt("LOG_SAMPLE_THEREAFTER", LogSampleThereafter, fatal)
+	LogMaxSizeMB = envInt("LOG_MAX_SIZE", LogMaxSizeMB, fatal)
+	LogMaxAgeDays = envInt("LOG_MAX_AGE", LogMaxAgeDays, fatal)
+	LogMaxBackups = envInt("LOG_MAX_BACKUPS", LogMaxBackups, fatal)
+	LogCompress = envBool("LOG_COMPRESS", LogCompress, fatal)
+
+	// Группы подписки транспорта
+	TransportClientGroup = envVal("TRANSPORT_CLIENT_GROUP", TransportClientGroup)
+	TransportWorkerGroup = envVal("TRANSPORT_WORKER_GROUP", TransportWorkerGroup)
+
+	// Индикатор набора в TxCh
+	TypingEvents = envBool("TYPING_EVENTS", TypingEvents, fatal)
+
+	// Атрибуция OpenRouter
+	OpenRouterAppURL = envVal("OPENROUTER_APP_URL", OpenRouterAppURL)
+	OpenRouterAppName = envVal("OPENROUTER_APP_NAME", OpenRouterAppName)
+
+	// Ollama
+	OllamaBaseURL = envVal("OLLAMA_URL", OllamaBaseURL)
+	OllamaEmbedModel = envVal("OLLAMA_EMBED_MODEL", OllamaEmbedModel)
+
+	// GigaChat
+	GigaChatCAFile = envVal("GIGACHAT_CA_FILE", GigaChatCAFile)
+	GigaChatScope = envVal("GIGACHAT_SCOPE", GigaChatScope)
+	GigaChatInsecureTLS = envBool("GIGACHAT_INSECURE_TLS", GigaChatInsecureTLS, fatal)
+
+	// Политика повторов запросов к провайдерам
+	initRetryFromEnv(fatal)
+
+	// Кэш контекста Gemini (минуты)
+	if n := envInt("GOOGLE_CONTEXT_CACHE_TTL", 0, fatal); n > 0 {
+		GoogleContextCacheTTL = time.Duration(n) * time.Minute
+	}
+	// Хранение файлов в Google File API (минуты), 0 — не удалять
+	GoogleFilesRetention = time.Duration(envInt("GOOGLE_FILES_RETENTION", int(GoogleFilesRetention/time.Minute), fatal)) * time.Minute
 
 	// Полный URL хоста (для S3, action_handler и т.п.).
 	// Если REAL_HOST_URL задан — используем его напрямую,
@@ -115,3 +378,33 @@ func envVal(key, def string) string {
 	}
 	return def
 }
+
+// envInt возвращает неотрицательное целое из переменной окружения key,
+// или def если переменная не задана. Некорректное значение передаётся в fatal.
+func envInt(key string, def int, fatal func(format string, args ...any)) int {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		fatal("mode.InitFromEnv: %s содержит некорректное значение: %q", key, v)
+		return def
+	}
+	return n
+}
+
+// envBool возвращает логическое значение из переменной окружения key,
+// или def если переменная не задана. Некорректное значение передаётся в fatal.
+func envBool(key string, def bool, fatal func(format string, args ...any)) bool {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		fatal("mode.InitFromEnv: %s содержит некорректное значение: %q", key, v)
+		return def
+	}
+	return b
+}
@@ -0,0 +1,104 @@
+package mode
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RetryRule повторы для одного класса ошибок
+type RetryRule struct {
+	MaxAttempts int           // всего попыток вместе с первой; 1 — без повторов
+	BaseDelay   time.Duration // задержка перед первым повтором, дальше удваивается
+	MaxDelay    time.Duration // верхняя граница задержки (0 — без ограничения)
+}
+
+// Attempts число попыток, не меньше одной
+func (r RetryRule) Attempts() int {
+	return max(r.MaxAttempts, 1)
+}
+
+// Backoff задержка перед повтором номер attempt (с 1): BaseDelay·2^(attempt-1), не больше MaxDelay
+func (r RetryRule) Backoff(attempt int) time.Duration {
+	d := r.BaseDelay
+	for i := 1; i < attempt && (r.MaxDelay <= 0 || d < r.MaxDelay); i++ {
+		d *= 2
+	}
+	if r.MaxDelay > 0 && d > r.MaxDelay {
+		d = r.MaxDelay
+	}
+	return d
+}
+
+// RetryPolicy политика повторов запросов к провайдерам по классам ошибок
+type RetryPolicy struct {
+	// Transient 5xx, сетевые ошибки и тайм-ауты: повторяет AskWithRetry и дозагрузка файлов
+	Transient RetryRule
+	// RateLimit 429 провайдера: повторяет HTTP-клиент провайдера. Если провайдер сообщил
+	// время ожидания (Retry-After, RetryInfo Gemini), ждём его вместо Backoff, но не дольше
+	// MaxRetryAfter — иначе повтор бессмысленен и ошибка возвращается сразу
+	RateLimit     RetryRule
+	MaxRetryAfter time.Duration
+}
+
+// Значения Retry.Transient по умолчанию
+const (
+	// RetryMaxAttempts максимальное количество попыток.
+	//
+	// Deprecated: используйте Retry.Transient.MaxAttempts.
+	RetryMaxAttempts = 3
+	// RetryBaseDelay базовая задержка между попытками в секундах.
+	//
+	// Deprecated: используйте Retry.Transient.BaseDelay.
+	RetryBaseDelay = 1
+)
+
+// Retry текущая политика повторов (RETRY_* в InitFromEnv)
+var Retry = RetryPolicy{
+	Transient:     RetryRule{MaxAttempts: RetryMaxAttempts, BaseDelay: RetryBaseDelay * time.Second, MaxDelay: 30 * time.Second},
+	RateLimit:     RetryRule{MaxAttempts: 3, BaseDelay: 5 * time.Second, MaxDelay: time.Minute},
+	MaxRetryAfter: time.Minute,
+}
+
+// RateLimitDelay задержка перед повтором attempt (с 1) после 429. retryAfter — подсказка
+// провайдера (меньше 0 — нет). false — попытки исчерпаны или ждать дольше MaxRetryAfter
+func (p RetryPolicy) RateLimitDelay(attempt int, retryAfter time.Duration) (time.Duration, bool) {
+	if attempt >= p.RateLimit.Attempts() {
+		return 0, false
+	}
+	if retryAfter < 0 {
+		return p.RateLimit.Backoff(attempt), true
+	}
+	if p.MaxRetryAfter > 0 && retryAfter > p.MaxRetryAfter {
+		return 0, false
+	}
+	return retryAfter, true
+}
+
+// ParseRetryAfter разбирает заголовок Retry-After: секунды или HTTP-дата;
+// -1 — заголовка нет или он некорректен
+func ParseRetryAfter(header string) time.Duration {
+	header = strings.TrimSpace(header)
+	if header == "" {
+		return -1
+	}
+	if seconds, err := strconv.Atoi(header); err == nil {
+		return time.Duration(max(seconds, 0)) * time.Second
+	}
+	if t, err := http.ParseTime(header); err == nil {
+		return max(time.Until(t), 0)
+	}
+	return -1
+}
+
+// initRetryFromEnv читает политику повторов: RETRY_MAX_ATTEMPTS и RETRY_BASE_DELAY (секунды)
+// для временных ошибок, RETRY_RATE_LIMIT_ATTEMPTS и RETRY_RATE_LIMIT_DELAY (секунды) для 429,
+// RETRY_AFTER_MAX (секунды) — дольше провайдер просит ждать — не повторять
+func initRetryFromEnv(fatal func(format string, args ...any)) {
+	Retry.Transient.MaxAttempts = envInt("RETRY_MAX_ATTEMPTS", Retry.Transient.MaxAttempts, fatal)
+	Retry.Transient.BaseDelay = time.Duration(envInt("RETRY_BASE_DELAY", int(Retry.Transient.BaseDelay/time.Second), fatal)) * time.Second
+	Retry.RateLimit.MaxAttempts = envInt("RETRY_RATE_LIMIT_ATTEMPTS", Retry.RateLimit.MaxAttempts, fatal)
+	Retry.RateLimit.BaseDelay = time.Duration(envInt("RETRY_RATE_LIMIT_DELAY", int(Retry.RateLimit.BaseDelay/time.Second), fatal)) * time.Second
+	Retry.MaxRetryAfter = time.Duration(envInt("RETRY_AFTER_MAX", int(Retry.MaxRetryAfter/time.Second), fatal)) * time.Second
+}
@@ -0,0 +1,83 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// WithAnswerCoalescing объединяет повтор вопроса в диалоге, пришедший пока первый запрос
+// к модели ещё выполняется (двойное нажатие «отправить»): повтор ждёт и получает тот же
+// ответ. После ответа запись удаляется, поэтому тот же текст позже (например, «да» на
+// другой вопрос ассистента) снова идёт к модели
+func WithAnswerCoalescing() RouterOption {
+	return func(r *Router, _ context.Context, _ DB) error {
+		r.answers = newAnswerCache()
+		return nil
+	}
+}
+
+// answerCache объединяет одновременные запросы с одним (dialogID, нормализованный вопрос)
+type answerCache struct {
+	mu      sync.Mutex
+	entries map[string]*answerEntry
+}
+
+type answerEntry struct {
+	done chan struct{} // закрывается, когда ответ получен
+	resp AssistResponse
+	err  error
+}
+
+func newAnswerCache() *answerCache {
+	return &answerCache{entries: make(map[string]*answerEntry)}
+}
+
+// errAnswerAborted ответ ожидающим повторам, если первый запрос завершился паникой
+var errAnswerAborted = errors.New("запрос к модели прерван")
+
+// do выполняет fn или ждёт ответа уже выполняющегося запроса с тем же ключом, но не
+// дольше ctx. hit — ответ получен без вызова fn. Запись удаляется и ожидающие
+// освобождаются, даже если fn паникует
+func (c *answerCache) do(ctx context.Context, dialogID uint64, text string, fn func() (AssistResponse, error)) (resp AssistResponse, hit bool, err error) {
+	key := answerKey(dialogID, text)
+	if c == nil || key == "" {
+		resp, err = fn()
+		return resp, false, err
+	}
+
+	c.mu.Lock()
+	if e, ok := c.entries[key]; ok {
+		c.mu.Unlock()
+		select {
+		case <-e.done:
+			return e.resp, true, e.err
+		case <-ctx.Done():
+			return AssistResponse{}, false, ctx.Err()
+		}
+	}
+	e := &answerEntry{done: make(chan struct{}), err: errAnswerAborted}
+	c.entries[key] = e
+	c.mu.Unlock()
+
+	defer func() {
+		c.mu.Lock()
+		delete(c.entries, key)
+		c.mu.Unlock()
+		close(e.done)
+	}()
+	e.resp, e.err = fn()
+	return e.resp, false, e.err
+}
+
+// answerKey ключ (dialogID, вопрос без учёта регистра, лишних пробелов и финальной пунктуации)
+func answerKey(dialogID uint64, text string) string {
+	normalized := strings.Join(strings.Fields(strings.ToLower(text)), " ")
+	normalized = strings.TrimRight(normalized, ".!?… ")
+	if normalized == "" {
+		return ""
+	}
+	return strconv.FormatUint(dialogID, 10) + ":" + normalized
+}
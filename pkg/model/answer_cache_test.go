@@ -0,0 +1,109 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestAnswerCacheCoalescesDoubleTap(t *testing.T) {
+	cache := newAnswerCache()
+
+	var calls atomic.Int32
+	release := make(chan struct{})
+	fn := func() (AssistResponse, error) {
+		calls.Add(1)
+		<-release
+		return AssistResponse{Message: "ответ"}, nil
+	}
+
+	var wg sync.WaitGroup
+	results := make([]AssistResponse, 2)
+	for i, text := range []string{"Где мой заказ?", "  где   МОЙ заказ "} {
+		wg.Add(1)
+		go func(i int, text string) {
+			defer wg.Done()
+			results[i], _, _ = cache.do(context.Background(), 42, text, fn)
+		}(i, text)
+		time.Sleep(10 * time.Millisecond) // второй запрос приходит, пока первый выполняется
+	}
+	close(release)
+	wg.Wait()
+
+	if calls.Load() != 1 {
+		t.Fatalf("model called %d times, want 1", calls.Load())
+	}
+	if results[0].Message != "ответ" || results[1].Message != "ответ" {
+		t.Errorf("results differ: %+v", results)
+	}
+
+	// Тот же текст после ответа идёт к модели: «да» на другой вопрос ассистента
+	if _, hit, _ := cache.do(context.Background(), 42, "где мой заказ", fn); hit || calls.Load() != 2 {
+		t.Errorf("completed answer reused: hit=%v calls=%d", hit, calls.Load())
+	}
+	if _, hit, _ := cache.do(context.Background(), 43, "Где мой заказ?", fn); hit {
+		t.Error("answer of another dialog served from cache")
+	}
+}
+
+func TestAnswerCacheReleasesWaitersOnPanic(t *testing.T) {
+	cache := newAnswerCache()
+	started, release := make(chan struct{}), make(chan struct{})
+
+	go func() {
+		defer func() { _ = recover() }()
+		_, _, _ = cache.do(context.Background(), 42, "где мой заказ", func() (AssistResponse, error) {
+			close(started)
+			<-release
+			panic("провайдер")
+		})
+	}()
+	<-started
+
+	waiter := make(chan error, 1)
+	go func() {
+		_, _, err := cache.do(context.Background(), 42, "где мой заказ", func() (AssistResponse, error) {
+			return AssistResponse{}, nil
+		})
+		waiter <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+
+	select {
+	case err := <-waiter:
+		if !errors.Is(err, errAnswerAborted) {
+			t.Fatalf("ожидающий повтор получил %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("ожидающий повтор завис после паники")
+	}
+	if _, hit, _ := cache.do(context.Background(), 42, "где мой заказ", func() (AssistResponse, error) {
+		return AssistResponse{}, nil
+	}); hit {
+		t.Fatal("запись не удалена после паники")
+	}
+}
+
+func TestAnswerCacheWaiterHonoursContext(t *testing.T) {
+	cache := newAnswerCache()
+	started, release := make(chan struct{}), make(chan struct{})
+	defer close(release)
+	go func() {
+		_, _, _ = cache.do(context.Background(), 42, "где мой заказ", func() (AssistResponse, error) {
+			close(started)
+			<-release
+			return AssistResponse{}, nil
+		})
+	}()
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, _, err := cache.do(ctx, 42, "где мой заказ", nil); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("ожидание без учёта ctx: %v", err)
+	}
+}
@@ -0,0 +1,182 @@
+package model
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ikermy/AiR_Common/pkg/comdb"
+	"github.com/ikermy/AiR_Common/pkg/logger"
+	"github.com/ikermy/AiR_Common/pkg/mode"
+	"github.com/ikermy/AiR_Common/pkg/model/create"
+	"github.com/ikermy/AiR_Common/pkg/safego"
+)
+
+// batchPollLimit сколько незавершённых заданий проверять за один опрос
+const batchPollLimit = 100
+
+// BatchClient — пакетное API провайдера (реализуется create.OpenAIAgentClient и create.GoogleAgentClient).
+// target — эндпоинт для OpenAI ("/v1/embeddings", "/v1/responses") или модель для Gemini
+type BatchClient interface {
+	SubmitBatch(userID uint32, target string, requests []create.BatchRequest) (string, error)
+	GetBatch(userID uint32, batchID string) (create.BatchInfo, error)
+	BatchResults(userID uint32, batchID string) ([]create.BatchResult, error)
+}
+
+// BatchCleaner — BatchClient, у которого после обработки пакета остаются ресурсы провайдера
+// (загруженный входной файл OpenAI). Реализуется create.OpenAIAgentClient
+type BatchCleaner interface {
+	CleanupBatch(userID uint32, batchID string) error
+}
+
+// BatchStore — хранилище пакетных заданий (реализуется *comdb.DB)
+type BatchStore interface {
+	SaveBatchJob(job comdb.BatchJob) (uint64, error)
+	UpdateBatchJob(id uint64, status, errText string) error
+	ListPendingBatchJobs(limit int) ([]comdb.BatchJob, error)
+}
+
+// BatchHandler получает результаты завершённого задания. При BatchFailed results пустой,
+// причина — в job.Error. Если handler вернул ошибку, задание не отмечается завершённым
+// и передаётся в handler повторно при следующем опросе
+type BatchHandler func(job comdb.BatchJob, results []create.BatchResult) error
+
+// BatchManager отправляет неинтерактивные задачи (переэмбеддинг базы знаний, массовая
+// суммаризация диалогов) в пакетные API провайдеров и опрашивает их статус.
+// Задания хранятся в БД, поэтому опрос продолжается после перезапуска
+type BatchManager struct {
+	ctx     context.Context
+	store   BatchStore
+	handler BatchHandler
+
+	mu      sync.RWMutex
+	clients map[create.ProviderType]BatchClient
+}
+
+// NewBatchManager создаёт менеджер пакетных заданий. handler вызывается по каждому
+// заданию, перешедшему в BatchCompleted или BatchFailed
+func NewBatchManager(ctx context.Context, store BatchStore, handler BatchHandler) *BatchManager {
+	return &BatchManager{
+		ctx:     ctx,
+		store:   store,
+		handler: handler,
+		clients: make(map[create.ProviderType]BatchClient),
+	}
+}
+
+// RegisterClient подключает пакетное API провайдера
+func (b *BatchManager) RegisterClient(provider create.ProviderType, client BatchClient) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.clients[provider] = client
+}
+
+// Submit отправляет пакет провайдеру и сохраняет задание. kind — произвольная метка
+// назначения, по которой handler отличает задания друг от друга
+func (b *BatchManager) Submit(userID uint32, provider create.ProviderType, kind, target string, requests []create.BatchRequest) (uint64, error) {
+	client, err := b.client(provider)
+	if err != nil {
+		return 0, err
+	}
+
+	batchID, err := client.SubmitBatch(userID, target, requests)
+	if err != nil {
+		return 0, fmt.Errorf("ошибка отправки пакета %s: %w", provider, err)
+	}
+
+	return b.store.SaveBatchJob(comdb.BatchJob{
+		UserID:     userID,
+		Provider:   provider,
+		Kind:       kind,
+		ExternalID: batchID,
+		Status:     create.BatchPending,
+		Requests:   len(requests),
+	})
+}
+
+// Run запускает фоновый опрос заданий до отмены контекста
+func (b *BatchManager) Run() {
+	safego.Go("batch.poll", func() {
+		ticker := time.NewTicker(mode.BatchPollInterval)
+		defer ticker.Stop()
+		for {
+			b.Poll()
+			select {
+			case <-b.ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}, safego.WithRestart(-1, time.Second), safego.WithDone(b.ctx.Done()))
+}
+
+// Poll проверяет незавершённые задания и передаёт результаты завершённых в handler
+func (b *BatchManager) Poll() {
+	jobs, err := b.store.ListPendingBatchJobs(batchPollLimit)
+	if err != nil {
+		logger.Error("batch: ошибка чтения заданий: %v", err)
+		return
+	}
+	for _, job := range jobs {
+		b.pollJob(job)
+	}
+}
+
+func (b *BatchManager) pollJob(job comdb.BatchJob) {
+	client, err := b.client(job.Provider)
+	if err != nil {
+		return // провайдер не подключён в этом процессе — задание подождёт
+	}
+
+	info, err := client.GetBatch(job.UserID, job.ExternalID)
+	if err != nil {
+		logger.Error("batch: ошибка получения статуса %s: %v", job.ExternalID, err, job.UserID)
+		return
+	}
+
+	var results []create.BatchResult
+	if info.Status == create.BatchCompleted {
+		if results, err = client.BatchResults(job.UserID, job.ExternalID); err != nil {
+			logger.Error("batch: ошибка получения результатов %s: %v", job.ExternalID, err, job.UserID)
+			return
+		}
+	}
+
+	terminal := info.Status == create.BatchCompleted || info.Status == create.BatchFailed
+	if info.Status == job.Status && !terminal {
+		return
+	}
+
+	// Завершённое задание отмечается в БД только после успешной обработки: упавший
+	// handler получит результаты ещё раз, а не потеряет их
+	if terminal {
+		job.Status, job.Error = info.Status, info.Error
+		if b.handler != nil {
+			if err := b.handler(job, results); err != nil {
+				logger.Error("batch: ошибка обработки задания %d: %v", job.ID, err, job.UserID)
+				return
+			}
+		}
+	}
+	if err := b.store.UpdateBatchJob(job.ID, info.Status, info.Error); err != nil {
+		logger.Error("batch: не удалось обновить задание %d: %v", job.ID, err, job.UserID)
+		return
+	}
+
+	if cleaner, ok := client.(BatchCleaner); ok && terminal {
+		if err := cleaner.CleanupBatch(job.UserID, job.ExternalID); err != nil {
+			logger.Warn("batch: не удалось удалить файлы задания %s: %v", job.ExternalID, err, job.UserID)
+		}
+	}
+}
+
+func (b *BatchManager) client(provider create.ProviderType) (BatchClient, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	client, ok := b.clients[provider]
+	if !ok {
+		return nil, fmt.Errorf("провайдер %s не поддерживает пакетную обработку", provider)
+	}
+	return client, nil
+}
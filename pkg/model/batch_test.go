@@ -0,0 +1,124 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/ikermy/AiR_Common/pkg/comdb"
+	"github.com/ikermy/AiR_Common/pkg/model/create"
+)
+
+type fakeBatchClient struct {
+	status  string
+	cleaned int
+}
+
+func (f *fakeBatchClient) SubmitBatch(uint32, string, []create.BatchRequest) (string, error) {
+	return "batch_1", nil
+}
+
+func (f *fakeBatchClient) GetBatch(_ uint32, id string) (create.BatchInfo, error) {
+	return create.BatchInfo{ID: id, Status: f.status}, nil
+}
+
+func (f *fakeBatchClient) BatchResults(uint32, string) ([]create.BatchResult, error) {
+	return []create.BatchResult{{CustomID: "doc-1", Body: []byte(`{}`)}}, nil
+}
+
+func (f *fakeBatchClient) CleanupBatch(uint32, string) error {
+	f.cleaned++
+	return nil
+}
+
+type memBatchStore struct {
+	jobs map[uint64]*comdb.BatchJob
+}
+
+func (m *memBatchStore) SaveBatchJob(job comdb.BatchJob) (uint64, error) {
+	job.ID = uint64(len(m.jobs) + 1)
+	m.jobs[job.ID] = &job
+	return job.ID, nil
+}
+
+func (m *memBatchStore) UpdateBatchJob(id uint64, status, errText string) error {
+	m.jobs[id].Status, m.jobs[id].Error = status, errText
+	return nil
+}
+
+func (m *memBatchStore) ListPendingBatchJobs(int) ([]comdb.BatchJob, error) {
+	var out []comdb.BatchJob
+	for _, j := range m.jobs {
+		if j.Status != create.BatchCompleted && j.Status != create.BatchFailed {
+			out = append(out, *j)
+		}
+	}
+	return out, nil
+}
+
+func TestBatchManagerDeliversCompletedJobOnce(t *testing.T) {
+	store := &memBatchStore{jobs: map[uint64]*comdb.BatchJob{}}
+	client := &fakeBatchClient{status: create.BatchRunning}
+
+	var delivered []create.BatchResult
+	calls := 0
+	bm := NewBatchManager(context.Background(), store, func(job comdb.BatchJob, results []create.BatchResult) error {
+		calls++
+		delivered = results
+		return nil
+	})
+	bm.RegisterClient(create.ProviderOpenAI, client)
+
+	id, err := bm.Submit(1, create.ProviderOpenAI, "reembed", "/v1/embeddings", []create.BatchRequest{{CustomID: "doc-1"}})
+	if err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+
+	bm.Poll()
+	if calls != 0 || store.jobs[id].Status != create.BatchRunning {
+		t.Fatalf("running job: calls=%d status=%s", calls, store.jobs[id].Status)
+	}
+
+	client.status = create.BatchCompleted
+	bm.Poll()
+	bm.Poll()
+	if calls != 1 || len(delivered) != 1 || delivered[0].CustomID != "doc-1" {
+		t.Fatalf("completed job: calls=%d results=%v", calls, delivered)
+	}
+	if client.cleaned != 1 {
+		t.Fatalf("input file cleanup: %d", client.cleaned)
+	}
+}
+
+func TestBatchManagerRetriesFailedHandler(t *testing.T) {
+	store := &memBatchStore{jobs: map[uint64]*comdb.BatchJob{}}
+	client := &fakeBatchClient{status: create.BatchCompleted}
+
+	fail := true
+	calls := 0
+	bm := NewBatchManager(context.Background(), store, func(comdb.BatchJob, []create.BatchResult) error {
+		calls++
+		if fail {
+			return errors.New("нет связи с базой знаний")
+		}
+		return nil
+	})
+	bm.RegisterClient(create.ProviderOpenAI, client)
+
+	id, err := bm.Submit(1, create.ProviderOpenAI, "reembed", "/v1/embeddings", []create.BatchRequest{{CustomID: "doc-1"}})
+	if err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+
+	bm.Poll()
+	if calls != 1 || store.jobs[id].Status != create.BatchPending || client.cleaned != 0 {
+		t.Fatalf("failed handler: calls=%d status=%s cleaned=%d", calls, store.jobs[id].Status, client.cleaned)
+	}
+
+	fail = false
+	bm.Poll()
+	bm.Poll()
+	if calls != 2 || store.jobs[id].Status != create.BatchCompleted || client.cleaned != 1 {
+		t.Fatalf("retried handler: calls=%d status=%s cleaned=%d", calls, store.jobs[id].Status, client.cleaned)
+	}
+}
@@ -0,0 +1,625 @@
+// Package chatapi — диалоговая модель (model.Inter) для провайдеров с OpenAI-совместимым
+// Chat Completions API: OpenRouter, Ollama и подобные. Конфигурация агента хранится в БД и
+// передаётся с каждым запросом: промпт + подсказка MCP, функции MCP (через ActionHandler),
+// схема ответа AssistResponse в response_format. Провайдер задаётся create.ProviderType и
+// клиентом create.ChatClient (см. pkg/model/openrouter, pkg/model/ollama).
+package chatapi
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ikermy/AiR_Common/pkg/com"
+	"github.com/ikermy/AiR_Common/pkg/comdb"
+	"github.com/ikermy/AiR_Common/pkg/experiment"
+	"github.com/ikermy/AiR_Common/pkg/mode"
+	"github.com/ikermy/AiR_Common/pkg/model"
+	"github.com/ikermy/AiR_Common/pkg/model/create"
+	"github.com/ikermy/AiR_Common/pkg/model/provider_catalog"
+	"github.com/ikermy/AiR_Common/pkg/safego"
+)
+
+type DB = comdb.Exterior
+
+// modelsInfoTTL как долго хранится список моделей провайдера (supported_parameters)
+const modelsInfoTTL = time.Hour
+
+// Model управляет респондентами одного Chat Completions провайдера
+type Model struct {
+	ctx            context.Context
+	cancel         context.CancelFunc
+	provider       create.ProviderType
+	client         create.ChatClient
+	db             DB
+	responders     sync.Map // respId -> *RespModel
+	waitChannels   sync.Map
+	dialogCache    sync.Map // dialogID -> *DialogCache
+	UserModelTTl   time.Duration
+	actionHandler  model.ActionHandler
+	universalModel *create.UniversalModel
+	seeds          *model.Seeds         // nil — детерминированный режим выключен
+	experiments    *experiment.Registry // nil — без A/B-экспериментов
+	shutdownOnce   sync.Once
+
+	requestExtra func(modelData *create.UniversalModelData) map[string]any
+
+	modelsMu     sync.Mutex
+	modelsInfo   map[string]create.ChatModelInfo // id -> описание из GET /models
+	modelsLoaded time.Time
+}
+
+// RespModel респондент диалога
+type RespModel struct {
+	Ctx         context.Context
+	Cancel      context.CancelFunc
+	Chan        *model.Ch
+	ChanMap     map[uint64]*model.Ch
+	TTL         time.Time
+	Assist      model.Assistant
+	RespName    string
+	Services    Services
+	AgentConfig *AgentConfig
+}
+
+// GetChannel реализует интерфейс model.ChannelProvider
+func (r *RespModel) GetChannel() *model.Ch {
+	return r.Chan
+}
+
+// GetChannelMap реализует интерфейс model.ChannelProvider
+func (r *RespModel) GetChannelMap() map[uint64]*model.Ch {
+	return r.ChanMap
+}
+
+type Services struct {
+	Listener   atomic.Bool
+	Respondent atomic.Bool
+}
+
+// AgentConfig конфигурация модели пользователя для запросов
+type AgentConfig struct {
+	ModelId        uint64                   `json:"model_id"`
+	ModelName      string                   `json:"model_name"` // из user_gpt.AssistantId ("anthropic/claude-sonnet-4")
+	SystemPrompt   string                   `json:"system_prompt"`
+	PromptHint     string                   `json:"prompt_hint,omitempty"`    // добавлено к промпту модели: подсказка MCP, схема ответа
+	PromptVersion  uint32                   `json:"prompt_version,omitempty"` // версия SystemPrompt в create.PromptVersionStore
+	Tools          []create.ChatTool        `json:"tools,omitempty"`
+	ResponseFormat map[string]any           `json:"response_format,omitempty"` // nil — схема в промпте
+	ResponseFields []create.SchemaField     `json:"response_fields,omitempty"` // поля ассистента → AssistResponse.Extra
+	MetaAction     string                   `json:"meta_action"`
+	Operator       bool                     `json:"operator"`
+	Haunter        bool                     `json:"haunter"`
+	Extra          map[string]any           `json:"extra,omitempty"` // параметры провайдера из Option WithRequestExtra
+	Generation     *create.GenerationParams `json:"generation,omitempty"`
+}
+
+// DialogCache история диалога в памяти
+type DialogCache struct {
+	Messages []create.ChatMessage
+	ExpireAt time.Time
+}
+
+// Option настраивает Model
+type Option func(*Model)
+
+// WithRequestExtra задаёт параметры запроса конкретного провайдера по данным модели
+// пользователя (например, search_parameters Grok при включённом WebSearch)
+func WithRequestExtra(fn func(modelData *create.UniversalModelData) map[string]any) Option {
+	return func(m *Model) {
+		m.requestExtra = fn
+	}
+}
+
+// New создаёт модель провайдера provider; ключ пользователя client получает через свой keyResolver
+func New(parent context.Context, d DB, provider create.ProviderType, client create.ChatClient, actionHandler model.ActionHandler, opts ...Option) *Model {
+	ctx, cancel := context.WithCancel(parent)
+	m := &Model{
+		ctx:           ctx,
+		cancel:        cancel,
+		provider:      provider,
+		client:        client,
+		db:            d,
+		UserModelTTl:  mode.UserModelTTl,
+		actionHandler: actionHandler,
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	safego.Go("chatapi.periodicFlush", m.periodicFlush, safego.WithRestart(-1, time.Second), safego.WithDone(ctx.Done()))
+	return m
+}
+
+// SetUniversalModel устанавливает UniversalModel
+func (m *Model) SetUniversalModel(um *create.UniversalModel) {
+	m.universalModel = um
+}
+
+// SetExperiments включает A/B-эксперименты: вариант диалога переопределяет модель,
+// температуру и промпт запроса
+func (m *Model) SetExperiments(r *experiment.Registry) {
+	m.experiments = r
+}
+
+// SetSeeds включает детерминированный режим: seed диалога передаётся провайдеру
+// (YandexGPT и GigaChat получают только нулевую температуру)
+func (m *Model) SetSeeds(s *model.Seeds) {
+	m.seeds = s
+}
+
+// schemaRepair исправление ответа под схему той же моделью; nil — без исправления
+func (m *Model) schemaRepair(modelName string) model.SchemaRepairFunc {
+	if m.universalModel == nil || modelName == "" {
+		return nil
+	}
+	client, err := m.universalModel.Provider(m.provider)
+	if err != nil {
+		return nil
+	}
+	return model.LLMSchemaRepair(m.ctx, client, modelName)
+}
+
+// Provider провайдер модели
+func (m *Model) Provider() create.ProviderType {
+	return m.provider
+}
+
+// Реализация интерфейса model.Inter
+
+func (m *Model) NewMessage(operator model.Operator, msgType string, content *model.AssistResponse, name *string, files ...model.FileUpload) model.Message {
+	var nameStr string
+	if name != nil {
+		nameStr = *name
+	}
+	return model.Message{
+		Operator:  operator,
+		Type:      msgType,
+		Content:   *content,
+		Name:      nameStr,
+		Timestamp: time.Now(),
+		Files:     files,
+	}
+}
+
+func (m *Model) GetFileAsReader(_ uint32, url string) (io.Reader, error) {
+	if url == "" {
+		return nil, fmt.Errorf("не указан источник файла: отсутствуют URL")
+	}
+	req, err := http.NewRequestWithContext(m.ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка подготовки запроса загрузки файла: %w", err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка загрузки файла по URL: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		_ = resp.Body.Close()
+		return nil, fmt.Errorf("ошибка HTTP при загрузке файла: статус %d", resp.StatusCode)
+	}
+	return resp.Body, nil
+}
+
+func (m *Model) GetOrSetRespGPT(assist model.Assistant, dialogID, respId uint64, respName string) (*model.RespModel, error) {
+	if val, ok := m.responders.Load(respId); ok {
+		respModel := val.(*RespModel)
+		respModel.TTL = time.Now().Add(m.UserModelTTl)
+		// Диалог передан другому ассистенту (SwitchAssistant) — конфигурация агента перечитывается
+		if assist.AssistId != "" && respModel.Assist.AssistId != assist.AssistId {
+			agentConfig, err := m.loadAgentConfig(assist.UserID, assist.AssistId)
+			if err != nil {
+				return nil, err
+			}
+			respModel.AgentConfig = agentConfig
+			respModel.Assist = assist
+		}
+		m.preloadDialogHistoryIfNeeded(dialogID)
+		return m.convertToModelRespModel(respModel), nil
+	}
+
+	userCtx, cancel, ch, ttl := model.CreateBaseResponder(m.ctx, m.UserModelTTl, assist, dialogID, respName)
+	user := &RespModel{
+		Assist:   assist,
+		RespName: respName,
+		TTL:      ttl,
+		Chan:     ch,
+		Ctx:      userCtx,
+		Cancel:   cancel,
+	}
+	agentConfig, err := m.loadAgentConfig(assist.UserID, assist.AssistId)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	user.AgentConfig = agentConfig
+
+	m.responders.Store(respId, user)
+	model.NotifyWaitChannels(&m.waitChannels, respId)
+	m.preloadDialogHistoryIfNeeded(dialogID)
+
+	return m.convertToModelRespModel(user), nil
+}
+
+func (m *Model) GetCh(respId uint64) (*model.Ch, error) {
+	return model.GetChannel(
+		respId,
+		m.ctx,
+		&m.waitChannels,
+		&m.responders,
+		func(val any) (*model.Ch, error) {
+			return model.ExtractChannelWithPriority(val.(*RespModel))
+		},
+	)
+}
+
+func (m *Model) GetRespIdByDialogID(dialogID uint64) (uint64, error) {
+	return model.GetRespIdBydialogIDUniversal(dialogID, &m.responders)
+}
+
+// ============================================================================
+// AGENT CONFIG
+// ============================================================================
+
+// loadAgentConfig загружает модель ассистента assistID (пусто — первую модель провайдера)
+// и формирует промпт, функции и формат ответа
+func (m *Model) loadAgentConfig(userID uint32, assistID string) (*AgentConfig, error) {
+	if m.provider.RequiresAPIKey() {
+		apiKey, err := m.db.GetUserAPIKey(userID, m.provider)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка получения API-ключа %s для пользователя %d: %w", m.provider, userID, err)
+		}
+		if apiKey == "" {
+			return nil, fmt.Errorf("API ключ %s не настроен для пользователя %d: добавьте персональный ключ через настройки", m.provider, userID)
+		}
+	}
+	if m.universalModel == nil {
+		return nil, fmt.Errorf("UniversalModel не установлен")
+	}
+
+	userModels, err := m.db.GetAllUserModels(userID)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения моделей пользователя: %w", err)
+	}
+	found := create.FindAssistantModel(userModels, m.provider, assistID)
+	if found == nil {
+		return nil, fmt.Errorf("модель %s %q не найдена для userID %d", m.provider, assistID, userID)
+	}
+
+	config := &AgentConfig{ModelId: found.ModelId, ModelName: found.AssistId}
+	if config.ModelName == "" {
+		_, defaultName, err := m.db.DefaultProvidersModels(m.provider.String())
+		if err != nil {
+			return nil, fmt.Errorf("имя модели %s не задано и получить модель по умолчанию не удалось: %w", m.provider, err)
+		}
+		config.ModelName = defaultName
+	}
+
+	compressedData, _, err := create.ReadAssistantModel(m.db, userID, found)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения данных модели из БД: %w", err)
+	}
+	modelData, err := m.universalModel.DecompressModelData(compressedData, nil)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка распаковки данных модели: %w", err)
+	}
+	config.MetaAction = modelData.MetaAction
+	config.Operator = modelData.Operator
+	config.Haunter = modelData.Haunter
+	config.Generation = modelData.Generation
+	if m.requestExtra != nil {
+		config.Extra = m.requestExtra(modelData)
+	}
+
+	m.buildAgentConfiguration(userID, config, modelData)
+	config.PromptVersion = create.SavePromptVersion(m.db, userID, config.ModelId, modelData.Prompt, config.SystemPrompt)
+	return config, nil
+}
+
+// buildAgentConfiguration формирует system_prompt, функции MCP и response_format.
+// Функции и response_format передаются, только если модель их поддерживает
+// (supported_parameters из GET /models); иначе схема ответа добавляется в промпт.
+func (m *Model) buildAgentConfiguration(userID uint32, config *AgentConfig, modelData *create.UniversalModelData) {
+	info := m.modelInfo(userID, config.ModelName)
+	config.SystemPrompt = modelData.Prompt
+
+	if mcpProvider, ok := m.actionHandler.(model.MCPConfigProvider); ok {
+		if hint, err := mcpProvider.FetchSystemPrompt(m.ctx, userID, m.provider); err == nil {
+			config.SystemPrompt = modelData.Prompt + "\n\n" + hint
+			if info.Supports("tools") {
+				if mcpTools, err := mcpProvider.FetchToolsList(m.ctx, userID, m.provider); err == nil {
+					for _, t := range mcpTools {
+						config.Tools = append(config.Tools, create.ChatTool{
+							Type:     "function",
+							Function: create.ChatFunction{Name: t.Name, Description: t.Description, Parameters: t.InputSchema},
+						})
+					}
+				}
+			}
+		}
+	}
+
+	schema := modelData.ResponseSchema()
+	config.ResponseFields = modelData.ResponseFields
+	if info.Supports("response_format") {
+		config.ResponseFormat = schema.OpenAIResponseFormat()
+		config.PromptHint = strings.TrimPrefix(config.SystemPrompt, modelData.Prompt)
+		return
+	}
+	config.SystemPrompt += "\n\n" + schemaInstruction(schema)
+	config.PromptHint = strings.TrimPrefix(config.SystemPrompt, modelData.Prompt)
+}
+
+// modelInfo описание модели из списка провайдера; пустое — модель неизвестна
+// (тогда ChatModelInfo.Supports разрешает все параметры)
+func (m *Model) modelInfo(userID uint32, modelName string) create.ChatModelInfo {
+	m.modelsMu.Lock()
+	defer m.modelsMu.Unlock()
+	if m.modelsInfo == nil || time.Since(m.modelsLoaded) > modelsInfoTTL {
+		ctx, cancel := context.WithTimeout(m.ctx, 10*time.Second)
+		list, err := m.client.ListModels(ctx, userID)
+		cancel()
+		if err == nil {
+			m.modelsInfo = make(map[string]create.ChatModelInfo, len(list))
+			for _, info := range list {
+				m.modelsInfo[info.ID] = info
+			}
+			m.modelsLoaded = time.Now()
+		}
+	}
+	return m.modelsInfo[modelName]
+}
+
+// ListModels список моделей провайдера для пользователя
+func (m *Model) ListModels(ctx context.Context, userID uint32) ([]create.ChatModelInfo, error) {
+	return m.client.ListModels(ctx, userID)
+}
+
+// ============================================================================
+// DIALOG CACHE
+// ============================================================================
+
+// periodicFlush периодически очищает истекшие записи из dialogCache
+func (m *Model) periodicFlush() {
+	ticker := time.NewTicker(5 * time.Minute)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			now := time.Now()
+			m.dialogCache.Range(func(key, value any) bool {
+				if value.(*DialogCache).ExpireAt.Before(now) {
+					m.dialogCache.Delete(key)
+				}
+				return true
+			})
+		case <-m.ctx.Done():
+			return
+		}
+	}
+}
+
+func (m *Model) getOrCreateDialogCache(dialogID uint64) *DialogCache {
+	if val, ok := m.dialogCache.Load(dialogID); ok {
+		cache := val.(*DialogCache)
+		cache.ExpireAt = time.Now().Add(create.DialogLiveTimeout)
+		return cache
+	}
+	cache := &DialogCache{ExpireAt: time.Now().Add(create.DialogLiveTimeout)}
+	actual, _ := m.dialogCache.LoadOrStore(dialogID, cache)
+	return actual.(*DialogCache)
+}
+
+func (m *Model) getDialogHistoryFromCache(dialogID uint64) ([]create.ChatMessage, bool) {
+	if val, ok := m.dialogCache.Load(dialogID); ok {
+		cache := val.(*DialogCache)
+		if cache.ExpireAt.After(time.Now()) {
+			cache.ExpireAt = time.Now().Add(create.DialogLiveTimeout)
+			return cache.Messages, true
+		}
+		m.dialogCache.Delete(dialogID)
+	}
+	return nil, false
+}
+
+func (m *Model) addMessageToCache(dialogID uint64, message create.ChatMessage) {
+	cache := m.getOrCreateDialogCache(dialogID)
+	cache.Messages = append(cache.Messages, message)
+	if maxMessages := int(create.DialogHistoryLimit); len(cache.Messages) > maxMessages {
+		cache.Messages = cache.Messages[len(cache.Messages)-maxMessages:]
+	}
+}
+
+// InjectContext добавляет системное сообщение в кэш истории диалога.
+// Без кэша ничего не делает: история будет загружена из БД целиком.
+func (m *Model) InjectContext(dialogID uint64, text string) bool {
+	if _, found := m.getDialogHistoryFromCache(dialogID); !found {
+		return false
+	}
+	m.addMessageToCache(dialogID, create.ChatMessage{Role: "system", Content: text})
+	return true
+}
+
+// LastAnswer текст последнего ответа ассистента в кэше истории диалога (model.TurnRecorder)
+func (m *Model) LastAnswer(dialogID uint64) (string, bool) {
+	history, found := m.getDialogHistoryFromCache(dialogID)
+	if !found {
+		return "", false
+	}
+	for i := len(history) - 1; i >= 0; i-- {
+		if history[i].Role == "assistant" {
+			text, _ := history[i].Content.(string)
+			return text, true
+		}
+	}
+	return "", true
+}
+
+// RecordTurn добавляет в кэш истории ход, ответ на который взят из семантического кэша.
+// Без кэша ничего не делает
+func (m *Model) RecordTurn(dialogID uint64, question string, answer model.AssistResponse) bool {
+	if _, found := m.getDialogHistoryFromCache(dialogID); !found {
+		return false
+	}
+	m.addMessageToCache(dialogID, create.ChatMessage{Role: "user", Content: question})
+	m.addMessageToCache(dialogID, create.ChatMessage{Role: "assistant", Content: answer.Message})
+	return true
+}
+
+// preloadDialogHistoryIfNeeded загружает историю диалога из БД в кэш, если кэша нет
+func (m *Model) preloadDialogHistoryIfNeeded(dialogID uint64) {
+	if _, found := m.getDialogHistoryFromCache(dialogID); found {
+		return
+	}
+	safego.Go("chatapi.loadDialogHistory", func() {
+		history, err := m.loadDialogHistory(dialogID)
+		if err != nil {
+			history = nil
+		}
+		m.getOrCreateDialogCache(dialogID).Messages = history
+	})
+}
+
+// ============================================================================
+// LIFECYCLE
+// ============================================================================
+
+func (m *Model) SaveAllContextDuringExit() {
+	// История диалога сохраняется через БД, контекст на стороне провайдера не хранится
+}
+
+func (m *Model) CleanDialogData(dialogID uint64) {
+	if respId, err := m.GetRespIdByDialogID(dialogID); err == nil {
+		if value, ok := m.responders.Load(respId); ok {
+			respModel := value.(*RespModel)
+			if respModel.Cancel != nil {
+				respModel.Cancel()
+			}
+			model.CloseResponderChannelsUniversal(respModel)
+			m.responders.Delete(respId)
+		}
+	}
+	m.dialogCache.Delete(dialogID)
+}
+
+func (m *Model) DeleteTempFile(string) error {
+	return fmt.Errorf("%s не поддерживает временные файлы", m.provider)
+}
+
+func (m *Model) TranscribeAudio(uint32, []byte, string) (string, error) {
+	return "", fmt.Errorf("%s не поддерживает транскрипцию аудио", m.provider)
+}
+
+func (m *Model) Shutdown(shutCh chan<- com.LogMsg) {
+	mod := "ChatAPIModel/" + m.provider.String()
+	m.shutdownOnce.Do(func() {
+		shutCh <- com.LogMsg{Msg: "начало shutdown", Mod: mod, Log: 0, UID: 0}
+		if m.cancel != nil {
+			m.cancel()
+		}
+		model.CleanupAllRespondersUniversal(
+			&m.responders,
+			func(val any) {
+				if respModel, ok := val.(*RespModel); ok && respModel.Cancel != nil {
+					respModel.Cancel()
+				}
+			},
+			func(val any) {
+				if respModel, ok := val.(*RespModel); ok {
+					model.CloseResponderChannelsUniversal(respModel)
+				}
+			},
+		)
+		model.CleanupWaitChannelsUniversal(&m.waitChannels, &m.responders)
+	})
+	shutCh <- com.LogMsg{Msg: "модуль успешно завершил работу", Mod: mod, Log: 0, UID: 0}
+}
+
+func (m *Model) convertToModelRespModel(internal *RespModel) *model.RespModel {
+	if internal.ChanMap == nil {
+		internal.ChanMap = make(map[uint64]*model.Ch)
+	}
+	if internal.Chan != nil {
+		if _, exists := internal.ChanMap[internal.Chan.DialogID]; !exists {
+			internal.ChanMap[internal.Chan.DialogID] = internal.Chan
+		}
+	}
+	return &model.RespModel{
+		Ctx:      internal.Ctx,
+		Cancel:   internal.Cancel,
+		Chan:     internal.ChanMap,
+		TTL:      internal.TTL,
+		Assist:   internal.Assist,
+		RespName: internal.RespName,
+		Services: model.Services{
+			Listener:   &internal.Services.Listener,
+			Respondent: &internal.Services.Respondent,
+		},
+	}
+}
+
+// CleanUp периодически очищает просроченные RespModel
+func (m *Model) CleanUp() {
+	ticker := time.NewTicker(15 * time.Minute)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			now := time.Now()
+			m.responders.Range(func(key, value any) bool {
+				responder := value.(*RespModel)
+				if responder.TTL.Before(now) {
+					if responder.Cancel != nil {
+						responder.Cancel()
+					}
+					model.CloseResponderChannelsUniversal(responder)
+					m.responders.Delete(key)
+				}
+				return true
+			})
+		case <-m.ctx.Done():
+			return
+		}
+	}
+}
+
+// InvalidateUserAgentConfigCache удаляет респондентов пользователя, чтобы новые сессии
+// получили актуальную конфигурацию модели
+func (m *Model) InvalidateUserAgentConfigCache(userID uint32) {
+	m.responders.Range(func(key, value any) bool {
+		if value.(*RespModel).Assist.UserID == userID {
+			m.responders.Delete(key)
+		}
+		return true
+	})
+}
+
+// DisconnectUser отменяет контексты респондентов пользователя и удаляет их из кэша
+func (m *Model) DisconnectUser(userID uint32) {
+	m.responders.Range(func(key, value any) bool {
+		respModel := value.(*RespModel)
+		if respModel.Assist.UserID == userID {
+			if respModel.Cancel != nil {
+				respModel.Cancel()
+			}
+			m.responders.Delete(key)
+		}
+		return true
+	})
+}
+
+func (m *Model) UpdateModelsListByProvider(ctx context.Context, provider create.ProviderType, apiKey string) error {
+	if provider != m.provider {
+		return fmt.Errorf("неверный провайдер для модели %s: %s", m.provider, provider)
+	}
+	return provider_catalog.SyncProviderModels(ctx, m.db, provider, apiKey)
+}
+
+// schemaInstruction инструкция о формате ответа для моделей без response_format
+func schemaInstruction(schema *create.ResponseSchema) string {
+	return "## ФОРМАТ ОТВЕТА\nОтвечай только JSON-объектом по схеме, без markdown и пояснений:\n" + schema.PromptText()
+}
@@ -0,0 +1,232 @@
+package chatapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"slices"
+
+	"github.com/ikermy/AiR_Common/pkg/experiment"
+	"github.com/ikermy/AiR_Common/pkg/logger"
+	"github.com/ikermy/AiR_Common/pkg/model"
+	"github.com/ikermy/AiR_Common/pkg/model/create"
+)
+
+// Request выполняет запрос и возвращает разобранный AssistResponse
+func (m *Model) Request(userID uint32, dialogID uint64, text string, files ...model.FileUpload) (model.AssistResponse, error) {
+	response, _, err := m.answer(model.TraceContext(m.ctx, dialogID), userID, dialogID, text, files)
+	return response, err
+}
+
+// RequestStreaming выполняет запрос целиком (без потоковой выдачи провайдера) и передаёт
+// в onDelta событие token_usage, текст ответа и финальный JSON AssistResponse (done=true)
+func (m *Model) RequestStreaming(userID uint32, dialogID uint64, text string, onDelta func(delta string, done bool) error, files ...model.FileUpload) error {
+	return m.RequestStreamingContext(model.TraceContext(m.ctx, dialogID), userID, dialogID, text, onDelta, files...)
+}
+
+// RequestStreamingContext RequestStreaming, прерываемый отменой ctx
+func (m *Model) RequestStreamingContext(ctx context.Context, userID uint32, dialogID uint64, text string, onDelta func(delta string, done bool) error, files ...model.FileUpload) error {
+	response, usage, err := m.answer(model.TraceContext(ctx, dialogID), userID, dialogID, text, files)
+	if err != nil {
+		return err
+	}
+	if onDelta == nil {
+		return nil
+	}
+
+	if usage.TotalTokens > 0 {
+		usageJSON, _ := json.Marshal(map[string]any{
+			"type": "token_usage",
+			"usage": map[string]any{
+				"prompt_tokens":     usage.PromptTokens,
+				"completion_tokens": usage.CompletionTokens,
+				"total_tokens":      usage.TotalTokens,
+			},
+		})
+		if err := onDelta(string(usageJSON), false); err != nil {
+			return err
+		}
+	}
+	if response.Message != "" {
+		if err := onDelta(response.Message, false); err != nil {
+			return err
+		}
+	}
+	responseJSON, err := json.Marshal(response)
+	if err != nil {
+		return fmt.Errorf("ошибка сериализации ответа: %w", err)
+	}
+	return onDelta(string(responseJSON), true)
+}
+
+// answer выполняет запрос к модели с историей диалога; вызовы функций модели
+// выполняются через ActionHandler до получения текстового ответа. Запрос прерывается
+// отменой ctx или закрытием респондента
+func (m *Model) answer(ctx context.Context, userID uint32, dialogID uint64, text string, files []model.FileUpload) (model.AssistResponse, create.ChatUsage, error) {
+	if text == "" && len(files) == 0 {
+		return model.AssistResponse{}, create.ChatUsage{}, fmt.Errorf("пустое сообщение и нет файлов")
+	}
+	respId, err := m.GetRespIdByDialogID(dialogID)
+	if err != nil {
+		return model.AssistResponse{}, create.ChatUsage{}, err
+	}
+	val, ok := m.responders.Load(respId)
+	if !ok {
+		return model.AssistResponse{}, create.ChatUsage{}, fmt.Errorf("респондент %d не найден", respId)
+	}
+	respModel := val.(*RespModel)
+	if respModel.AgentConfig == nil {
+		return model.AssistResponse{}, create.ChatUsage{}, fmt.Errorf("конфигурация модели %s не загружена", m.provider)
+	}
+	config := respModel.AgentConfig
+	promptVersion := config.PromptVersion
+	if a, ok := m.experiments.Assign(userID, dialogID); ok {
+		config = applyVariant(config, a.Variant)
+		if a.Prompt != "" {
+			promptVersion = 0 // промпт варианта не версионируется
+		}
+	}
+
+	history, found := m.getDialogHistoryFromCache(dialogID)
+	if !found {
+		if history, err = m.loadDialogHistory(dialogID); err != nil {
+			history = nil
+		}
+		m.getOrCreateDialogCache(dialogID).Messages = history
+	}
+
+	userMessage := createUserMessage(text, files)
+	system := create.ChatMessage{Role: "system", Content: config.SystemPrompt}
+	dialog, err := fitContext(userID, dialogID, config, system, append(slices.Clip(history), userMessage))
+	if err != nil {
+		return model.AssistResponse{}, create.ChatUsage{}, err
+	}
+	messages := append([]create.ChatMessage{system}, dialog...)
+	m.addMessageToCache(dialogID, userMessage)
+
+	req := create.ChatRequest{
+		Model:          config.ModelName,
+		Messages:       messages,
+		Tools:          config.Tools,
+		ResponseFormat: config.ResponseFormat,
+		Extra:          config.Extra,
+	}
+	if seed, ok := m.seeds.Get(dialogID); ok {
+		config.Generation.Deterministic(seed).ApplyChat(&req)
+	} else {
+		config.Generation.ApplyChat(&req)
+	}
+	var run create.ToolRunner
+	if m.actionHandler != nil && len(config.Tools) > 0 {
+		run = func(ctx context.Context, name, arguments string) string {
+			return m.actionHandler.RunAction(model.WithDialogID(ctx, dialogID), name, arguments, m.provider, userID)
+		}
+	}
+
+	reqCtx, cancel := model.RequestContext(respModel.Ctx, ctx)
+	defer cancel()
+	resp, _, err := create.CompleteWithTools(reqCtx, m.client, userID, req, run)
+	if err != nil {
+		return model.AssistResponse{}, create.ChatUsage{}, fmt.Errorf("ошибка запроса к %s: %w", m.provider, err)
+	}
+
+	fullText := resp.Text()
+	response := model.ParseAssistResponse(userID, fullText, m.schemaRepair(req.Model), config.ResponseFields...)
+	if response.Message == "" && fullText != "" {
+		response.Message = fullText
+	}
+	m.addMessageToCache(dialogID, create.ChatMessage{Role: "assistant", Content: response.Message})
+	create.RecordPromptTurn(m.db, userID, dialogID, config.ModelId, promptVersion)
+
+	return response, resp.Usage, nil
+}
+
+// fitContext отбрасывает самые старые сообщения истории, если запрос с промптом и
+// инструментами не помещается в контекст модели (create.ChatInputLimit); кэш диалога
+// не меняется. Неизвестная модель не проверяется
+func fitContext(userID uint32, dialogID uint64, config *AgentConfig, system create.ChatMessage, dialog []create.ChatMessage) ([]create.ChatMessage, error) {
+	limit := create.ChatInputLimit(path.Base(config.ModelName))
+	if limit <= 0 {
+		return dialog, nil
+	}
+
+	fixed := create.ChatMessageTokens(system, config.ModelName)
+	if len(config.Tools) > 0 {
+		data, _ := json.Marshal(config.Tools)
+		fixed += create.EstimateTokens(string(data), config.ModelName)
+	}
+	sizes := make([]int, len(dialog))
+	for i, msg := range dialog {
+		sizes[i] = create.ChatMessageTokens(msg, config.ModelName)
+	}
+
+	drop, err := create.FitHistory(config.ModelName, limit, fixed, sizes)
+	if err != nil || drop == 0 {
+		return dialog, err
+	}
+	// Ответ инструмента без вызова и ответ ассистента без вопроса не отправляем
+	for drop < len(dialog)-1 && dialog[drop].Role != "user" {
+		drop++
+	}
+	logger.ForDialog(userID, dialogID).Warn("история не помещается в контекст модели, старые сообщения не отправлены",
+		"model", config.ModelName, "limit", limit, "dropped", drop)
+	return dialog[drop:], nil
+}
+
+// createUserMessage сообщение пользователя; изображения по URL передаются как image_url
+func createUserMessage(text string, files []model.FileUpload) create.ChatMessage {
+	var parts []any
+	for _, file := range files {
+		if file.HasURL() && file.IsImageMimeType() {
+			parts = append(parts, map[string]any{
+				"type":      "image_url",
+				"image_url": map[string]any{"url": file.URL},
+			})
+		}
+	}
+	if len(parts) == 0 {
+		return create.ChatMessage{Role: "user", Content: text}
+	}
+	if text != "" {
+		parts = append([]any{map[string]any{"type": "text", "text": text}}, parts...)
+	}
+	return create.ChatMessage{Role: "user", Content: parts}
+}
+
+// loadDialogHistory история диалога из БД в формате Chat Completions
+func (m *Model) loadDialogHistory(dialogID uint64) ([]create.ChatMessage, error) {
+	rawData, err := m.db.ReadDialog(dialogID, create.DialogHistoryLimit)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения диалога: %w", err)
+	}
+	if len(rawData) == 0 {
+		return nil, nil
+	}
+	turns, err := model.DialogTurns(rawData)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка парсинга истории: %w", err)
+	}
+
+	messages := make([]create.ChatMessage, 0, len(turns))
+	for _, turn := range turns {
+		messages = append(messages, create.ChatMessage{Role: turn.Role, Content: turn.Text})
+	}
+	return messages, nil
+}
+
+// applyVariant копия cfg с моделью, температурой и промптом варианта v. Промпт варианта
+// заменяет промпт модели; подсказка MCP и схема ответа (PromptHint) сохраняются
+func applyVariant(cfg *AgentConfig, v experiment.Variant) *AgentConfig {
+	out := *cfg
+	if v.ModelName != "" {
+		out.ModelName = v.ModelName
+	}
+	if v.Temperature != nil {
+		out.Generation = cfg.Generation.WithTemperature(*v.Temperature)
+	}
+	if v.Prompt != "" {
+		out.SystemPrompt = v.Prompt + cfg.PromptHint
+	}
+	return &out
+}
@@ -0,0 +1,195 @@
+package chatapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/ikermy/AiR_Common/pkg/experiment"
+	"github.com/ikermy/AiR_Common/pkg/logger"
+	"github.com/ikermy/AiR_Common/pkg/model"
+	"github.com/ikermy/AiR_Common/pkg/model/create"
+)
+
+type recordingHandler struct {
+	calls atomic.Int32
+	name  string
+	args  string
+	trace string
+}
+
+func (h *recordingHandler) RunAction(ctx context.Context, functionName, arguments string, provider create.ProviderType, _ uint32) string {
+	h.calls.Add(1)
+	h.name, h.args, h.trace = functionName, arguments, logger.TraceIDFrom(ctx)
+	if provider != create.ProviderOpenRouter {
+		return `{"error":"provider"}`
+	}
+	return `{"status":"ok","slots":["10:00"]}`
+}
+
+func TestRequest_ToolCallThenStructuredAnswer(t *testing.T) {
+	var round atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req create.ChatRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("тело запроса: %v", err)
+		}
+		if req.ResponseFormat["type"] != "json_schema" {
+			t.Errorf("response_format: %v", req.ResponseFormat)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if round.Add(1) == 1 {
+			_, _ = w.Write([]byte(`{"choices":[{"finish_reason":"tool_calls","message":{"role":"assistant","tool_calls":[
+				{"id":"call_1","type":"function","function":{"name":"free_slots","arguments":"{\"day\":\"пн\"}"}}]}}],
+				"usage":{"prompt_tokens":10,"completion_tokens":5,"total_tokens":15}}`))
+			return
+		}
+		last := req.Messages[len(req.Messages)-1]
+		if last.Role != "tool" || last.ToolCallID != "call_1" {
+			t.Errorf("результат функции не передан: %+v", last)
+		}
+		_, _ = w.Write([]byte(`{"choices":[{"finish_reason":"stop","message":{"role":"assistant",
+			"content":"{\"message\":\"Свободно в 10:00\",\"action\":{\"send_files\":[]},\"target\":false}"}}],
+			"usage":{"prompt_tokens":20,"completion_tokens":7,"total_tokens":27}}`))
+	}))
+	defer srv.Close()
+
+	handler := &recordingHandler{}
+	client := create.NewChatCompletionsClient("test", srv.URL)
+	m := New(context.Background(), nil, create.ProviderOpenRouter, client, handler)
+	defer m.cancel()
+
+	m.responders.Store(uint64(1), &RespModel{
+		Ctx:  context.Background(),
+		Chan: &model.Ch{DialogID: 7},
+		AgentConfig: &AgentConfig{
+			ModelName:      "test/model",
+			SystemPrompt:   "Ты администратор салона",
+			Tools:          []create.ChatTool{{Type: "function", Function: create.ChatFunction{Name: "free_slots"}}},
+			ResponseFormat: map[string]any{"type": "json_schema"},
+		},
+	})
+	m.getOrCreateDialogCache(7) // история уже в кэше — БД не нужна
+	logger.BindDialog(7, "turn-7")
+	defer logger.UnbindDialog(7)
+
+	var deltas []string
+	var final string
+	err := m.RequestStreaming(1, 7, "Есть запись на понедельник?", func(delta string, done bool) error {
+		if done {
+			final = delta
+		} else {
+			deltas = append(deltas, delta)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if handler.calls.Load() != 1 || handler.name != "free_slots" || handler.args != `{"day":"пн"}` {
+		t.Fatalf("вызов функции: %d %q %q", handler.calls.Load(), handler.name, handler.args)
+	}
+	if handler.trace != "turn-7" {
+		t.Fatalf("trace_id хода не передан в вызов функции: %q", handler.trace)
+	}
+
+	var resp model.AssistResponse
+	if err := json.Unmarshal([]byte(final), &resp); err != nil || resp.Message != "Свободно в 10:00" {
+		t.Fatalf("финальный ответ %q: %v", final, err)
+	}
+	if len(deltas) != 2 || deltas[1] != "Свободно в 10:00" {
+		t.Fatalf("дельты: %q", deltas)
+	}
+	var usage struct {
+		Type  string `json:"type"`
+		Usage struct {
+			TotalTokens int `json:"total_tokens"`
+		} `json:"usage"`
+	}
+	if err := json.Unmarshal([]byte(deltas[0]), &usage); err != nil || usage.Type != "token_usage" || usage.Usage.TotalTokens != 42 {
+		t.Fatalf("token_usage: %q", deltas[0])
+	}
+
+	history, _ := m.getDialogHistoryFromCache(7)
+	if len(history) != 2 || history[0].Role != "user" || history[1].Content != "Свободно в 10:00" {
+		t.Fatalf("история: %+v", history)
+	}
+}
+
+func TestRequest_ExtraParameters(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("тело запроса: %v", err)
+		}
+		search, ok := req["search_parameters"].(map[string]any)
+		if !ok || search["mode"] != "auto" || req["model"] != "grok-4" {
+			t.Errorf("search_parameters не переданы: %v", req)
+		}
+		_, _ = w.Write([]byte(`{"choices":[{"finish_reason":"stop","message":{"role":"assistant","content":"{\"message\":\"ok\"}"}}]}`))
+	}))
+	defer srv.Close()
+
+	m := New(context.Background(), nil, create.ProviderGrok, create.NewChatCompletionsClient("test", srv.URL), nil,
+		WithRequestExtra(create.GrokSearchParameters))
+	defer m.cancel()
+
+	extra := m.requestExtra(&create.UniversalModelData{WebSearch: true})
+	m.responders.Store(uint64(1), &RespModel{
+		Ctx:         context.Background(),
+		Chan:        &model.Ch{DialogID: 8},
+		AgentConfig: &AgentConfig{ModelName: "grok-4", Extra: extra},
+	})
+	m.getOrCreateDialogCache(8)
+
+	response, err := m.Request(1, 8, "Новости дня")
+	if err != nil || response.Message != "ok" {
+		t.Fatalf("ответ %+v: %v", response, err)
+	}
+	if create.GrokSearchParameters(&create.UniversalModelData{}) != nil {
+		t.Fatal("без WebSearch search_parameters не передаются")
+	}
+}
+
+func TestRequest_ExperimentVariant(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req create.ChatRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("тело запроса: %v", err)
+		}
+		if req.Model != "test/model-b" || req.Temperature == nil || *req.Temperature != 0.2 {
+			t.Errorf("вариант не применён: model=%s temperature=%v", req.Model, req.Temperature)
+		}
+		if req.Messages[0].Content != "Отвечай кратко\n\nподсказка MCP" {
+			t.Errorf("промпт варианта: %q", req.Messages[0].Content)
+		}
+		_, _ = w.Write([]byte(`{"choices":[{"finish_reason":"stop","message":{"role":"assistant","content":"{\"message\":\"ok\"}"}}]}`))
+	}))
+	defer srv.Close()
+
+	m := New(context.Background(), nil, create.ProviderOpenRouter, create.NewChatCompletionsClient("test", srv.URL), nil)
+	defer m.cancel()
+	temperature := 0.2
+	registry := experiment.NewRegistry()
+	if err := registry.Set(1, experiment.Experiment{Name: "tone", Variants: []experiment.Variant{
+		{Name: "b", ModelName: "test/model-b", Temperature: &temperature, Prompt: "Отвечай кратко", Weight: 1000000},
+		{Name: "a"},
+	}}); err != nil {
+		t.Fatal(err)
+	}
+	m.SetExperiments(registry)
+
+	config := &AgentConfig{ModelName: "test/model", SystemPrompt: "Ты администратор\n\nподсказка MCP", PromptHint: "\n\nподсказка MCP"}
+	m.responders.Store(uint64(1), &RespModel{Ctx: context.Background(), Chan: &model.Ch{DialogID: 9}, AgentConfig: config})
+	m.getOrCreateDialogCache(9)
+
+	if _, err := m.Request(1, 9, "Сколько стоит стрижка?"); err != nil {
+		t.Fatal(err)
+	}
+	if config.ModelName != "test/model" || config.Generation != nil {
+		t.Fatalf("общая конфигурация ассистента изменена: %+v", config)
+	}
+}
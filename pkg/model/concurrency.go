@@ -0,0 +1,191 @@
+package model
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/ikermy/AiR_Common/pkg/mode"
+	"github.com/ikermy/AiR_Common/pkg/model/create"
+)
+
+// ConcurrencyLimits лимиты одновременных запросов к провайдерам; 0 — без ограничения.
+// Всплеск из сотен диалогов не открывает сотни параллельных запросов к одному API:
+// лишние запросы ждут в очереди, пока не освободится место или не отменится их контекст
+type ConcurrencyLimits struct {
+	Global      int                         // всего по всем провайдерам
+	Each        int                         // на каждый провайдер, если нет в PerProvider
+	PerProvider map[create.ProviderType]int // лимит конкретного провайдера
+}
+
+// DefaultConcurrencyLimits лимиты из mode.ProviderMaxConcurrent и mode.ProviderMaxConcurrentEach
+func DefaultConcurrencyLimits() ConcurrencyLimits {
+	return ConcurrencyLimits{Global: mode.ProviderMaxConcurrent, Each: mode.ProviderMaxConcurrentEach}
+}
+
+func (l ConcurrencyLimits) provider(kind create.ProviderType) int {
+	if n, ok := l.PerProvider[kind]; ok {
+		return n
+	}
+	return l.Each
+}
+
+// WithConcurrencyLimits задаёт лимиты одновременных запросов к провайдерам вместо
+// DefaultConcurrencyLimits
+func WithConcurrencyLimits(l ConcurrencyLimits) RouterOption {
+	return func(r *Router, _ context.Context, _ DB) error {
+		if l.Global < 0 || l.Each < 0 {
+			return fmt.Errorf("лимит одновременных запросов не может быть отрицательным")
+		}
+		for kind, n := range l.PerProvider {
+			if n < 0 {
+				return fmt.Errorf("лимит одновременных запросов %s не может быть отрицательным", kind)
+			}
+		}
+		r.limiter = newConcurrencyLimiter(l)
+		return nil
+	}
+}
+
+// ConcurrencyStats состояние одного лимита одновременных запросов (для метрик)
+type ConcurrencyStats struct {
+	Limit     int           `json:"limit"`     // 0 — без ограничения
+	InFlight  int64         `json:"in_flight"` // выполняются сейчас
+	Waiting   int64         `json:"waiting"`   // ждут в очереди сейчас
+	Queued    uint64        `json:"queued"`    // всего запросов, ожидавших в очереди
+	Abandoned uint64        `json:"abandoned"` // не дождались очереди (отмена, тайм-аут)
+	WaitTime  time.Duration `json:"wait_time"` // суммарное время ожидания в очереди
+}
+
+// RouterConcurrencyStats лимиты одновременных запросов Router: общий и по провайдерам
+type RouterConcurrencyStats struct {
+	Global    ConcurrencyStats            `json:"global"`
+	Providers map[string]ConcurrencyStats `json:"providers"`
+}
+
+// semaphore ограничение одновременных запросов с учётом очереди; nil — без ограничения
+type semaphore struct {
+	slots chan struct{}
+
+	inFlight  atomic.Int64
+	waiting   atomic.Int64
+	queued    atomic.Uint64
+	abandoned atomic.Uint64
+	waitTime  atomic.Int64
+}
+
+func newSemaphore(limit int) *semaphore {
+	if limit <= 0 {
+		return nil
+	}
+	return &semaphore{slots: make(chan struct{}, limit)}
+}
+
+// acquire занимает место; ждёт в очереди, пока место не освободится или не отменится ctx
+func (s *semaphore) acquire(ctx context.Context) error {
+	if s == nil {
+		return nil
+	}
+	select {
+	case s.slots <- struct{}{}:
+		s.inFlight.Add(1)
+		return nil
+	default:
+	}
+
+	s.queued.Add(1)
+	s.waiting.Add(1)
+	start := time.Now()
+	defer func() {
+		s.waiting.Add(-1)
+		s.waitTime.Add(int64(time.Since(start)))
+	}()
+
+	select {
+	case s.slots <- struct{}{}:
+		s.inFlight.Add(1)
+		return nil
+	case <-ctx.Done():
+		s.abandoned.Add(1)
+		return ctx.Err()
+	}
+}
+
+func (s *semaphore) release() {
+	if s == nil {
+		return
+	}
+	s.inFlight.Add(-1)
+	<-s.slots
+}
+
+func (s *semaphore) stats() ConcurrencyStats {
+	if s == nil {
+		return ConcurrencyStats{}
+	}
+	return ConcurrencyStats{
+		Limit:     cap(s.slots),
+		InFlight:  s.inFlight.Load(),
+		Waiting:   s.waiting.Load(),
+		Queued:    s.queued.Load(),
+		Abandoned: s.abandoned.Load(),
+		WaitTime:  time.Duration(s.waitTime.Load()),
+	}
+}
+
+// concurrencyLimiter общий семафор и семафоры провайдеров
+type concurrencyLimiter struct {
+	global    *semaphore
+	providers map[create.ProviderType]*semaphore
+}
+
+func newConcurrencyLimiter(l ConcurrencyLimits) *concurrencyLimiter {
+	c := &concurrencyLimiter{global: newSemaphore(l.Global), providers: make(map[create.ProviderType]*semaphore)}
+	for _, kind := range create.AllProviders {
+		if sem := newSemaphore(l.provider(kind)); sem != nil {
+			c.providers[kind] = sem
+		}
+	}
+	if c.global == nil && len(c.providers) == 0 {
+		return nil
+	}
+	return c
+}
+
+// acquire занимает место у провайдера, затем общее: запрос к перегруженному провайдеру
+// не держит общее место, пока ждёт своей очереди. release освобождает оба
+func (c *concurrencyLimiter) acquire(ctx context.Context, kind create.ProviderType) (release func(), err error) {
+	if c == nil {
+		return func() {}, nil
+	}
+	provider := c.providers[kind]
+	if err := provider.acquire(ctx); err != nil {
+		return nil, fmt.Errorf("очередь запросов к %s: %w", kind, err)
+	}
+	if err := c.global.acquire(ctx); err != nil {
+		provider.release()
+		return nil, fmt.Errorf("очередь запросов к провайдерам: %w", err)
+	}
+	return func() {
+		c.global.release()
+		provider.release()
+	}, nil
+}
+
+func (c *concurrencyLimiter) stats() RouterConcurrencyStats {
+	out := RouterConcurrencyStats{Providers: map[string]ConcurrencyStats{}}
+	if c == nil {
+		return out
+	}
+	out.Global = c.global.stats()
+	for kind, sem := range c.providers {
+		out.Providers[kind.String()] = sem.stats()
+	}
+	return out
+}
+
+// ConcurrencyStats очереди и занятость лимитов одновременных запросов (для метрик)
+func (r *Router) ConcurrencyStats() RouterConcurrencyStats {
+	return r.limiter.stats()
+}
@@ -0,0 +1,78 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ikermy/AiR_Common/pkg/model/create"
+)
+
+func TestConcurrencyLimiter_QueuesOverLimit(t *testing.T) {
+	l := newConcurrencyLimiter(ConcurrencyLimits{Global: 2, PerProvider: map[create.ProviderType]int{create.ProviderGoogle: 1}})
+
+	release, err := l.acquire(context.Background(), create.ProviderGoogle)
+	if err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+
+	acquired := make(chan func(), 1)
+	go func() {
+		next, _ := l.acquire(context.Background(), create.ProviderGoogle)
+		acquired <- next
+	}()
+	for l.stats().Providers["google"].Waiting != 1 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// Другой провайдер проходит по общему лимиту, пока Google ждёт
+	other, err := l.acquire(context.Background(), create.ProviderOpenAI)
+	if err != nil {
+		t.Fatalf("acquire other provider: %v", err)
+	}
+	other()
+
+	release()
+	select {
+	case next := <-acquired:
+		next()
+	case <-time.After(time.Second):
+		t.Fatalf("queued request was not released")
+	}
+
+	st := l.stats()
+	if g := st.Providers["google"]; g.Queued != 1 || g.InFlight != 0 || g.Waiting != 0 || g.Limit != 1 {
+		t.Fatalf("unexpected provider stats: %+v", g)
+	}
+	if st.Global.InFlight != 0 || st.Global.Limit != 2 {
+		t.Fatalf("unexpected global stats: %+v", st.Global)
+	}
+}
+
+func TestConcurrencyLimiter_Abandoned(t *testing.T) {
+	l := newConcurrencyLimiter(ConcurrencyLimits{Global: 1})
+	release, _ := l.acquire(context.Background(), create.ProviderMistral)
+	defer release()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := l.acquire(ctx, create.ProviderGoogle); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline error, got %v", err)
+	}
+	if st := l.stats().Global; st.Abandoned != 1 || st.Waiting != 0 || st.WaitTime <= 0 {
+		t.Fatalf("unexpected stats: %+v", st)
+	}
+}
+
+func TestConcurrencyLimiter_Unlimited(t *testing.T) {
+	if l := newConcurrencyLimiter(ConcurrencyLimits{}); l != nil {
+		t.Fatalf("expected nil limiter without limits")
+	}
+	var l *concurrencyLimiter
+	release, err := l.acquire(context.Background(), create.ProviderGoogle)
+	if err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+	release()
+}
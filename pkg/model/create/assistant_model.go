@@ -0,0 +1,33 @@
+package create
+
+// UserModelReader чтение данных конкретной модели пользователя (реализует comdb.DB).
+// Необязательно для DB: без него данные читаются по провайдеру (ReadUserModelByProvider)
+type UserModelReader interface {
+	// ReadUserModelById получает сжатые данные модели modelID пользователя
+	ReadUserModelById(userID uint32, modelID uint64) ([]byte, *VecIds, error)
+}
+
+// FindAssistantModel модель провайдера provider с AssistId assistID.
+// Пустой assistID — первая модель провайдера (активные идут первыми); nil — модель не найдена.
+// У пользователя может быть несколько ассистентов одного провайдера, поэтому провайдеры
+// выбирают модель по Assistant.AssistId, а не первую попавшуюся
+func FindAssistantModel(models []UserModelRecord, provider ProviderType, assistID string) *UserModelRecord {
+	for i := range models {
+		if models[i].Provider != provider {
+			continue
+		}
+		if assistID == "" || models[i].AssistId == assistID {
+			return &models[i]
+		}
+	}
+	return nil
+}
+
+// ReadAssistantModel сжатые данные модели record. Без UserModelReader читается модель
+// провайдера — для пользователей с одним ассистентом провайдера это та же модель
+func ReadAssistantModel(db DB, userID uint32, record *UserModelRecord) ([]byte, *VecIds, error) {
+	if reader, ok := db.(UserModelReader); ok && record.ModelId != 0 {
+		return reader.ReadUserModelById(userID, record.ModelId)
+	}
+	return db.ReadUserModelByProvider(userID, record.Provider)
+}
@@ -0,0 +1,27 @@
+package create
+
+import "testing"
+
+func TestFindAssistantModel(t *testing.T) {
+	models := []UserModelRecord{
+		{ModelId: 1, Provider: ProviderOpenAI, AssistId: "gpt-5-mini"},
+		{ModelId: 2, Provider: ProviderGoogle, AssistId: "gemini-2.5-flash"},
+		{ModelId: 3, Provider: ProviderGoogle, AssistId: "gemini-2.5-pro"},
+	}
+	cases := []struct {
+		provider ProviderType
+		assistID string
+		want     uint64 // 0 — не найдена
+	}{
+		{ProviderGoogle, "gemini-2.5-pro", 3}, // не первая модель провайдера
+		{ProviderGoogle, "", 2},
+		{ProviderGoogle, "gpt-5-mini", 0}, // модель другого провайдера
+		{ProviderMistral, "", 0},
+	}
+	for _, c := range cases {
+		got := FindAssistantModel(models, c.provider, c.assistID)
+		if (got == nil) != (c.want == 0) || got != nil && got.ModelId != c.want {
+			t.Errorf("%s/%q: got %+v, want ModelId %d", c.provider, c.assistID, got, c.want)
+		}
+	}
+}
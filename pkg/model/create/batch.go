@@ -0,0 +1,442 @@
+package create
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strings"
+
+	"github.com/ikermy/AiR_Common/pkg/providererr"
+)
+
+// ============================================================================
+// BATCH API - Пакетная обработка для неинтерактивных задач
+// (переиндексация базы знаний, массовая суммаризация диалогов).
+// OpenAI: https://platform.openai.com/docs/guides/batch
+// Gemini: https://ai.google.dev/gemini-api/docs/batch-mode
+// ============================================================================
+
+// Нормализованные статусы пакетного задания (общие для всех провайдеров)
+const (
+	BatchPending   = "pending"
+	BatchRunning   = "running"
+	BatchCompleted = "completed"
+	BatchFailed    = "failed"
+)
+
+// BatchRequest один запрос пакета. Body — тело запроса в формате провайдера:
+// для OpenAI — тело эндпоинта (/v1/responses, /v1/embeddings),
+// для Gemini — GenerateContentRequest
+type BatchRequest struct {
+	CustomID string         `json:"custom_id"`
+	Body     map[string]any `json:"body"`
+}
+
+// BatchResult результат одного запроса пакета
+type BatchResult struct {
+	CustomID string          `json:"custom_id"`
+	Body     json.RawMessage `json:"body,omitempty"`
+	Error    string          `json:"error,omitempty"`
+}
+
+// BatchInfo состояние пакетного задания у провайдера
+type BatchInfo struct {
+	ID     string `json:"id"`
+	Status string `json:"status"` // BatchPending / BatchRunning / BatchCompleted / BatchFailed
+	Error  string `json:"error,omitempty"`
+}
+
+// ----------------------------------------------------------------------------
+// OpenAI Batch API
+// ----------------------------------------------------------------------------
+
+// SubmitBatch загружает запросы JSONL-файлом и создаёт пакет для эндпоинта target
+// (например "/v1/embeddings"). Возвращает id пакета
+func (c *OpenAIAgentClient) SubmitBatch(userID uint32, target string, requests []BatchRequest) (string, error) {
+	if len(requests) == 0 {
+		return "", fmt.Errorf("пакет не может быть пустым")
+	}
+
+	var jsonl bytes.Buffer
+	enc := json.NewEncoder(&jsonl)
+	for _, r := range requests {
+		line := map[string]any{
+			"custom_id": r.CustomID,
+			"method":    http.MethodPost,
+			"url":       target,
+			"body":      r.Body,
+		}
+		if err := enc.Encode(line); err != nil {
+			return "", fmt.Errorf("ошибка сериализации запроса %s: %w", r.CustomID, err)
+		}
+	}
+
+	fileID, err := c.uploadBatchFile(userID, jsonl.Bytes())
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := c.doRequest(c.ctx, http.MethodPost, "/batches", map[string]any{
+		"input_file_id":     fileID,
+		"endpoint":          target,
+		"completion_window": "24h",
+	}, userID)
+	if err != nil {
+		return "", fmt.Errorf("ошибка создания пакета: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	var batch struct {
+		ID string `json:"id"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&batch); err != nil {
+		return "", fmt.Errorf("ошибка парсинга ответа batches: %w", err)
+	}
+	return batch.ID, nil
+}
+
+// GetBatch возвращает состояние пакета
+func (c *OpenAIAgentClient) GetBatch(userID uint32, batchID string) (BatchInfo, error) {
+	batch, err := c.getBatch(userID, batchID)
+	if err != nil {
+		return BatchInfo{}, err
+	}
+
+	info := BatchInfo{ID: batch.ID, Status: openAIBatchStatus(batch.Status)}
+	if len(batch.Errors.Data) > 0 {
+		info.Error = batch.Errors.Data[0].Message
+	} else if info.Status == BatchFailed {
+		info.Error = "пакет завершился со статусом " + batch.Status
+	}
+	return info, nil
+}
+
+// BatchResults скачивает результаты завершённого пакета: успешные ответы и ошибки
+func (c *OpenAIAgentClient) BatchResults(userID uint32, batchID string) ([]BatchResult, error) {
+	batch, err := c.getBatch(userID, batchID)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []BatchResult
+	for _, fileID := range []string{batch.OutputFileID, batch.ErrorFileID} {
+		if fileID == "" {
+			continue
+		}
+		data, err := c.downloadUserFile(userID, fileID)
+		if err != nil {
+			return nil, err
+		}
+		parsed, err := parseOpenAIBatchOutput(data)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, parsed...)
+	}
+	return results, nil
+}
+
+// CleanupBatch удаляет загруженный SubmitBatch входной JSONL-файл завершённого пакета,
+// чтобы файлы не копились в хранилище аккаунта пользователя
+func (c *OpenAIAgentClient) CleanupBatch(userID uint32, batchID string) error {
+	batch, err := c.getBatch(userID, batchID)
+	if err != nil {
+		return err
+	}
+	if batch.InputFileID == "" {
+		return nil
+	}
+
+	resp, err := c.doRequest(c.ctx, http.MethodDelete, "/files/"+batch.InputFileID, nil, userID)
+	if err != nil {
+		return fmt.Errorf("ошибка удаления входного файла пакета: %w", err)
+	}
+	_ = resp.Body.Close()
+	return nil
+}
+
+type openAIBatch struct {
+	ID           string `json:"id"`
+	Status       string `json:"status"`
+	InputFileID  string `json:"input_file_id"`
+	OutputFileID string `json:"output_file_id"`
+	ErrorFileID  string `json:"error_file_id"`
+	Errors       struct {
+		Data []struct {
+			Message string `json:"message"`
+		} `json:"data"`
+	} `json:"errors"`
+}
+
+func (c *OpenAIAgentClient) getBatch(userID uint32, batchID string) (openAIBatch, error) {
+	resp, err := c.doRequest(c.ctx, http.MethodGet, "/batches/"+batchID, nil, userID)
+	if err != nil {
+		return openAIBatch{}, fmt.Errorf("ошибка получения пакета: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	var batch openAIBatch
+	if err := json.NewDecoder(resp.Body).Decode(&batch); err != nil {
+		return openAIBatch{}, fmt.Errorf("ошибка парсинга пакета: %w", err)
+	}
+	return batch, nil
+}
+
+// uploadBatchFile загружает JSONL с purpose=batch
+func (c *OpenAIAgentClient) uploadBatchFile(userID uint32, data []byte) (string, error) {
+	var buf bytes.Buffer
+	writer := multipart.NewWriter(&buf)
+	if err := writer.WriteField("purpose", "batch"); err != nil {
+		return "", fmt.Errorf("failed to write purpose field: %w", err)
+	}
+	part, err := writer.CreateFormFile("file", "batch.jsonl")
+	if err != nil {
+		return "", fmt.Errorf("failed to create form file: %w", err)
+	}
+	if _, err := part.Write(data); err != nil {
+		return "", fmt.Errorf("failed to write batch data: %w", err)
+	}
+	if err := writer.Close(); err != nil {
+		return "", fmt.Errorf("failed to close multipart writer: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(c.ctx, http.MethodPost, c.url+"/files", &buf)
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+c.resolveKey(userID))
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("request failed: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode >= 400 {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return "", providererr.New("OpenAI", resp.StatusCode, resp.Header, bodyBytes)
+	}
+
+	var file struct {
+		ID string `json:"id"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&file); err != nil {
+		return "", fmt.Errorf("failed to decode response: %w", err)
+	}
+	return file.ID, nil
+}
+
+// downloadUserFile скачивает файл по ключу пользователя (DownloadFileContent работает с глобальным ключом)
+func (c *OpenAIAgentClient) downloadUserFile(userID uint32, fileID string) ([]byte, error) {
+	resp, err := c.doRequest(c.ctx, http.MethodGet, fmt.Sprintf("/files/%s/content", fileID), nil, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	return io.ReadAll(resp.Body)
+}
+
+// openAIBatchStatus приводит статус OpenAI к общему
+func openAIBatchStatus(status string) string {
+	switch status {
+	case "validating":
+		return BatchPending
+	case "in_progress", "finalizing", "cancelling":
+		return BatchRunning
+	case "completed":
+		return BatchCompleted
+	default: // failed, expired, cancelled
+		return BatchFailed
+	}
+}
+
+// parseOpenAIBatchOutput разбирает JSONL результатов (output и error файлы имеют один формат)
+func parseOpenAIBatchOutput(data []byte) ([]BatchResult, error) {
+	var results []BatchResult
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var item struct {
+			CustomID string `json:"custom_id"`
+			Response *struct {
+				StatusCode int             `json:"status_code"`
+				Body       json.RawMessage `json:"body"`
+			} `json:"response"`
+			Error *struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if err := json.Unmarshal(line, &item); err != nil {
+			return nil, fmt.Errorf("ошибка парсинга результата пакета: %w", err)
+		}
+
+		result := BatchResult{CustomID: item.CustomID}
+		switch {
+		case item.Error != nil:
+			result.Error = item.Error.Message
+		case item.Response != nil && item.Response.StatusCode >= 400:
+			result.Error = fmt.Sprintf("HTTP %d: %s", item.Response.StatusCode, string(item.Response.Body))
+		case item.Response != nil:
+			result.Body = item.Response.Body
+		}
+		results = append(results, result)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка чтения результатов пакета: %w", err)
+	}
+	return results, nil
+}
+
+// ----------------------------------------------------------------------------
+// Gemini Batch Mode
+// ----------------------------------------------------------------------------
+
+// SubmitBatch создаёт пакет generateContent-запросов для модели target.
+// Запросы передаются inline; CustomID возвращается в metadata.key результата
+func (m *GoogleAgentClient) SubmitBatch(userID uint32, target string, requests []BatchRequest) (string, error) {
+	if len(requests) == 0 {
+		return "", fmt.Errorf("пакет не может быть пустым")
+	}
+
+	inline := make([]map[string]any, 0, len(requests))
+	for _, r := range requests {
+		inline = append(inline, map[string]any{
+			"request":  r.Body,
+			"metadata": map[string]any{"key": r.CustomID},
+		})
+	}
+	payload := map[string]any{
+		"batch": map[string]any{
+			"display_name": fmt.Sprintf("batch-%d-%d", userID, len(requests)),
+			"input_config": map[string]any{
+				"requests": map[string]any{"requests": inline},
+			},
+		},
+	}
+
+	url := fmt.Sprintf("%s/%s:batchGenerateContent?key=%s", m.url, GoogleModelPath(target), m.resolveKey(userID))
+	responseBody, err := executeGoogleAPIRequest(m.ctx, url, payload)
+	if err != nil {
+		return "", fmt.Errorf("ошибка создания пакета: %w", err)
+	}
+
+	var operation struct {
+		Name string `json:"name"`
+	}
+	if err := json.Unmarshal(responseBody, &operation); err != nil {
+		return "", fmt.Errorf("ошибка парсинга ответа batchGenerateContent: %w", err)
+	}
+	if operation.Name == "" {
+		return "", fmt.Errorf("API не вернул имя пакета")
+	}
+	return operation.Name, nil
+}
+
+// GetBatch возвращает состояние пакета (batches/{id})
+func (m *GoogleAgentClient) GetBatch(userID uint32, batchID string) (BatchInfo, error) {
+	batch, err := m.getBatch(userID, batchID)
+	if err != nil {
+		return BatchInfo{}, err
+	}
+
+	info := BatchInfo{ID: batch.Name, Status: geminiBatchStatus(batch.Metadata.State)}
+	if batch.Error != nil {
+		info.Status = BatchFailed
+		info.Error = batch.Error.Message
+	} else if info.Status == BatchFailed {
+		info.Error = "пакет завершился со статусом " + batch.Metadata.State
+	}
+	return info, nil
+}
+
+// BatchResults возвращает inline-результаты завершённого пакета
+func (m *GoogleAgentClient) BatchResults(userID uint32, batchID string) ([]BatchResult, error) {
+	batch, err := m.getBatch(userID, batchID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Результаты приходят в response; старые версии API клали их в metadata.output
+	responses := batch.Response.InlinedResponses.InlinedResponses
+	if len(responses) == 0 {
+		responses = batch.Metadata.Output.InlinedResponses.InlinedResponses
+	}
+
+	results := make([]BatchResult, 0, len(responses))
+	for _, r := range responses {
+		result := BatchResult{CustomID: r.Metadata.Key, Body: r.Response}
+		if r.Error != nil {
+			result.Error = r.Error.Message
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+type geminiInlinedResponses struct {
+	InlinedResponses struct {
+		InlinedResponses []struct {
+			Response json.RawMessage `json:"response,omitempty"`
+			Metadata struct {
+				Key string `json:"key"`
+			} `json:"metadata"`
+			Error *struct {
+				Message string `json:"message"`
+			} `json:"error,omitempty"`
+		} `json:"inlinedResponses"`
+	} `json:"inlinedResponses"`
+}
+
+type geminiBatch struct {
+	Name     string `json:"name"`
+	Metadata struct {
+		State  string                 `json:"state"`
+		Output geminiInlinedResponses `json:"output"`
+	} `json:"metadata"`
+	Response geminiInlinedResponses `json:"response"`
+	Error    *struct {
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
+
+func (m *GoogleAgentClient) getBatch(userID uint32, batchID string) (geminiBatch, error) {
+	if !strings.HasPrefix(batchID, "batches/") {
+		return geminiBatch{}, fmt.Errorf("некорректное имя пакета: %q", batchID)
+	}
+
+	url := fmt.Sprintf("%s/%s?key=%s", m.url, batchID, m.resolveKey(userID))
+	responseBody, err := executeGoogleAPIGetRequest(m.ctx, url)
+	if err != nil {
+		return geminiBatch{}, fmt.Errorf("ошибка получения пакета: %w", err)
+	}
+
+	var batch geminiBatch
+	if err := json.Unmarshal(responseBody, &batch); err != nil {
+		return geminiBatch{}, fmt.Errorf("ошибка парсинга пакета: %w", err)
+	}
+	return batch, nil
+}
+
+// geminiBatchStatus приводит BATCH_STATE_* к общему статусу
+func geminiBatchStatus(state string) string {
+	switch state {
+	case "BATCH_STATE_PENDING", "":
+		return BatchPending
+	case "BATCH_STATE_RUNNING":
+		return BatchRunning
+	case "BATCH_STATE_SUCCEEDED":
+		return BatchCompleted
+	default: // FAILED, CANCELLED, EXPIRED
+		return BatchFailed
+	}
+}
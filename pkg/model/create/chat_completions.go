@@ -0,0 +1,367 @@
+package create
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ikermy/AiR_Common/pkg/mode"
+	"github.com/ikermy/AiR_Common/pkg/providererr"
+)
+
+// chatToolRounds максимум раундов вызова функций в одном ответе CompleteWithTools
+const chatToolRounds = 8
+
+// ChatMessage сообщение Chat Completions API
+type ChatMessage struct {
+	Role       string         `json:"role"`              // "system", "user", "assistant", "tool"
+	Content    any            `json:"content,omitempty"` // string или массив content parts
+	Name       string         `json:"name,omitempty"`
+	ToolCalls  []ChatToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string         `json:"tool_call_id,omitempty"`
+}
+
+// ChatToolCall вызов функции в ответе модели
+type ChatToolCall struct {
+	ID       string `json:"id"`
+	Type     string `json:"type"` // "function"
+	Function struct {
+		Name      string `json:"name"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
+}
+
+// ChatTool описание функции для модели
+type ChatTool struct {
+	Type     string       `json:"type"` // "function"
+	Function ChatFunction `json:"function"`
+}
+
+// ChatFunction функция ChatTool; Parameters — JSON Schema аргументов
+type ChatFunction struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Parameters  any    `json:"parameters,omitempty"`
+}
+
+// ChatRequest запрос chat/completions
+type ChatRequest struct {
+	Model          string         `json:"model"`
+	Messages       []ChatMessage  `json:"messages"`
+	Tools          []ChatTool     `json:"tools,omitempty"`
+	ResponseFormat map[string]any `json:"response_format,omitempty"` // {"type":"json_schema",...}
+	Temperature    *float64       `json:"temperature,omitempty"`
+	TopP           *float64       `json:"top_p,omitempty"`
+	TopK           *int           `json:"-"` // только Ollama (options.top_k)
+	MaxTokens      int            `json:"max_tokens,omitempty"`
+	Stop           []string       `json:"stop,omitempty"` // кроме YandexGPT и GigaChat
+	Seed           *int64         `json:"seed,omitempty"` // кроме YandexGPT и GigaChat
+	Extra          map[string]any `json:"-"`              // параметры конкретного провайдера (search_parameters у Grok)
+}
+
+// MarshalJSON добавляет к полям запроса параметры Extra
+func (r ChatRequest) MarshalJSON() ([]byte, error) {
+	type plain ChatRequest
+	data, err := json.Marshal(plain(r))
+	if err != nil || len(r.Extra) == 0 {
+		return data, err
+	}
+	var fields map[string]any
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	for k, v := range r.Extra {
+		if _, ok := fields[k]; !ok {
+			fields[k] = v
+		}
+	}
+	return json.Marshal(fields)
+}
+
+// ChatUsage расход токенов
+type ChatUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+// ChatResponse ответ chat/completions (первый choice)
+type ChatResponse struct {
+	Message      ChatMessage `json:"message"`
+	FinishReason string      `json:"finish_reason"`
+	Usage        ChatUsage   `json:"usage"`
+}
+
+// Text текст ответа модели
+func (r ChatResponse) Text() string {
+	if s, ok := r.Message.Content.(string); ok {
+		return s
+	}
+	return ""
+}
+
+// ChatModelInfo модель из списка GET /models
+type ChatModelInfo struct {
+	ID            string `json:"id"`
+	Name          string `json:"name,omitempty"`
+	ContextLength int    `json:"context_length,omitempty"`
+	Pricing       struct {
+		Prompt     string `json:"prompt,omitempty"`     // стоимость токена запроса, USD
+		Completion string `json:"completion,omitempty"` // стоимость токена ответа, USD
+	} `json:"pricing"`
+	SupportedParameters []string `json:"supported_parameters,omitempty"` // "tools", "response_format", ...
+}
+
+// Supports модель принимает параметр запроса (если провайдер сообщает supported_parameters)
+func (i ChatModelInfo) Supports(param string) bool {
+	if len(i.SupportedParameters) == 0 {
+		return true
+	}
+	for _, p := range i.SupportedParameters {
+		if p == param {
+			return true
+		}
+	}
+	return false
+}
+
+// ToolRunner выполняет функцию модели и возвращает результат (JSON-строку)
+type ToolRunner func(ctx context.Context, name, arguments string) string
+
+// ChatClient клиент чат-модели в формате Chat Completions: ChatCompletionsClient или
+// адаптер собственного API провайдера (OllamaClient)
+type ChatClient interface {
+	Complete(ctx context.Context, userID uint32, req ChatRequest) (ChatResponse, error)
+	ListModels(ctx context.Context, userID uint32) ([]ChatModelInfo, error)
+}
+
+// ChatCompletionsClient клиент OpenAI-совместимого Chat Completions API (OpenRouter и т.п.)
+type ChatCompletionsClient struct {
+	name        string // имя провайдера для сообщений об ошибках
+	url         string
+	httpClient  *http.Client
+	headers     map[string]string // дополнительные заголовки каждого запроса
+	keyResolver func(userID uint32) string
+}
+
+// NewChatCompletionsClient создаёт клиент API по адресу baseURL (без завершающего /)
+func NewChatCompletionsClient(name, baseURL string) *ChatCompletionsClient {
+	return &ChatCompletionsClient{
+		name:       name,
+		url:        strings.TrimRight(baseURL, "/"),
+		httpClient: &http.Client{Timeout: 120 * time.Second},
+		headers:    make(map[string]string),
+	}
+}
+
+// SetKeyResolver устанавливает функцию-резолвер персонального API-ключа пользователя.
+func (c *ChatCompletionsClient) SetKeyResolver(fn func(userID uint32) string) {
+	c.keyResolver = fn
+}
+
+// SetHeader добавляет заголовок ко всем запросам; пустое значение не передаётся
+func (c *ChatCompletionsClient) SetHeader(key, value string) {
+	if value == "" {
+		delete(c.headers, key)
+		return
+	}
+	c.headers[key] = value
+}
+
+// resolveKey возвращает персональный API-ключ пользователя
+func (c *ChatCompletionsClient) resolveKey(userID uint32) string {
+	if c.keyResolver != nil && userID != 0 {
+		return c.keyResolver(userID)
+	}
+	return ""
+}
+
+// HasAPIKey возвращает true если для пользователя есть действующий API-ключ.
+func (c *ChatCompletionsClient) HasAPIKey(userID uint32) bool {
+	return c.resolveKey(userID) != ""
+}
+
+// do выполняет запрос к API и возвращает тело ответа
+func (c *ChatCompletionsClient) do(ctx context.Context, method, path string, body any, userID uint32) ([]byte, error) {
+	var data []byte
+	if body != nil {
+		var err error
+		if data, err = json.Marshal(body); err != nil {
+			return nil, fmt.Errorf("ошибка сериализации запроса: %w", err)
+		}
+	}
+
+	// Ошибка 429 повторяется по mode.Retry.RateLimit с учётом Retry-After
+	for attempt := 1; ; attempt++ {
+		var reqBody io.Reader
+		if data != nil {
+			reqBody = bytes.NewReader(data)
+		}
+		req, err := http.NewRequestWithContext(ctx, method, c.url+path, reqBody)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка создания HTTP запроса: %w", err)
+		}
+		if key := c.resolveKey(userID); key != "" {
+			req.Header.Set("Authorization", "Bearer "+key)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Accept", "application/json")
+		for k, v := range c.headers {
+			req.Header.Set(k, v)
+		}
+
+		resp, err := c.httpClient.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка HTTP запроса к %s: %w", c.name, err)
+		}
+		respBody, err := io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("ошибка чтения ответа %s: %w", c.name, err)
+		}
+		if resp.StatusCode == http.StatusTooManyRequests && !providererr.QuotaExhausted(resp.StatusCode, respBody) {
+			if delay, ok := mode.Retry.RateLimitDelay(attempt, mode.ParseRetryAfter(resp.Header.Get("Retry-After"))); ok {
+				select {
+				case <-ctx.Done():
+					return nil, ctx.Err()
+				case <-time.After(delay):
+				}
+				continue
+			}
+		}
+		if resp.StatusCode >= 400 {
+			return nil, providererr.New(c.name, resp.StatusCode, resp.Header, respBody)
+		}
+		return respBody, nil
+	}
+}
+
+// Complete выполняет один запрос chat/completions
+func (c *ChatCompletionsClient) Complete(ctx context.Context, userID uint32, req ChatRequest) (ChatResponse, error) {
+	body, err := c.do(ctx, http.MethodPost, "/chat/completions", req, userID)
+	if err != nil {
+		return ChatResponse{}, err
+	}
+	var out struct {
+		Choices []struct {
+			Message      ChatMessage `json:"message"`
+			FinishReason string      `json:"finish_reason"`
+		} `json:"choices"`
+		Usage ChatUsage `json:"usage"`
+		Error *struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(body, &out); err != nil {
+		return ChatResponse{}, fmt.Errorf("ошибка парсинга ответа %s: %w", c.name, err)
+	}
+	// OpenRouter возвращает ошибку вышестоящего провайдера со статусом 200
+	if out.Error != nil {
+		return ChatResponse{}, fmt.Errorf("%s: %s", c.name, out.Error.Message)
+	}
+	if len(out.Choices) == 0 {
+		return ChatResponse{}, fmt.Errorf("получен пустой ответ от %s", c.name)
+	}
+	return ChatResponse{Message: out.Choices[0].Message, FinishReason: out.Choices[0].FinishReason, Usage: out.Usage}, nil
+}
+
+// CompleteWithTools выполняет запрос, передавая вызовы функций модели в run, пока модель
+// не ответит текстом. Возвращает итоговый ответ и добавленные к req.Messages сообщения
+// (вызовы функций и их результаты), расход токенов суммируется по всем раундам.
+func CompleteWithTools(ctx context.Context, c ChatClient, userID uint32, req ChatRequest, run ToolRunner) (ChatResponse, []ChatMessage, error) {
+	messages := append([]ChatMessage(nil), req.Messages...)
+	start := len(messages)
+	var usage ChatUsage
+	for round := 0; ; round++ {
+		req.Messages = messages
+		resp, err := c.Complete(ctx, userID, req)
+		if err != nil {
+			return ChatResponse{}, messages[start:], err
+		}
+		usage.PromptTokens += resp.Usage.PromptTokens
+		usage.CompletionTokens += resp.Usage.CompletionTokens
+		usage.TotalTokens += resp.Usage.TotalTokens
+		if len(resp.Message.ToolCalls) == 0 || run == nil {
+			resp.Usage = usage
+			return resp, messages[start:], nil
+		}
+		if round >= chatToolRounds {
+			return ChatResponse{}, messages[start:], fmt.Errorf("превышено число вызовов функций (%d)", chatToolRounds)
+		}
+
+		messages = append(messages, resp.Message)
+		for _, call := range resp.Message.ToolCalls {
+			messages = append(messages, ChatMessage{
+				Role:       "tool",
+				ToolCallID: call.ID,
+				Name:       call.Function.Name,
+				Content:    run(ctx, call.Function.Name, call.Function.Arguments),
+			})
+		}
+	}
+}
+
+// ListModels список моделей GET /models
+func (c *ChatCompletionsClient) ListModels(ctx context.Context, userID uint32) ([]ChatModelInfo, error) {
+	body, err := c.do(ctx, http.MethodGet, "/models", nil, userID)
+	if err != nil {
+		return nil, err
+	}
+	var out struct {
+		Data []ChatModelInfo `json:"data"`
+	}
+	if err := json.Unmarshal(body, &out); err != nil {
+		return nil, fmt.Errorf("ошибка парсинга списка моделей %s: %w", c.name, err)
+	}
+	return out.Data, nil
+}
+
+// updateNamedAgent обновляет модель провайдера без агента на своей стороне: AssistId
+// хранит имя модели, остальные настройки передаются с каждым запросом
+func (m *UniversalModel) updateNamedAgent(userID uint32, provider ProviderType, updated *UniversalModelData) error {
+	record, err := m.db.GetModelByProviderAnyStatus(userID, provider)
+	if err != nil {
+		return fmt.Errorf("ошибка получения записи модели: %w", err)
+	}
+	if record == nil {
+		return fmt.Errorf("запись модели не найдена")
+	}
+	umcr := UMCR{AssistID: record.AssistId, AllIds: record.AllIds, Provider: provider}
+	if updated.GptType != nil && updated.GptType.Name != "" {
+		umcr.AssistID = updated.GptType.Name
+	}
+	if err := m.SaveModel(userID, umcr, updated); err != nil {
+		return fmt.Errorf("ошибка сохранения обновленной модели в БД: %w", err)
+	}
+	return nil
+}
+
+// chatProviderRequest выполняет ProviderRequest через ChatClient
+func chatProviderRequest(ctx context.Context, c ChatClient, userID uint32, req ProviderRequest) (ProviderResponse, error) {
+	messages := make([]ChatMessage, 0, len(req.Messages)+1)
+	if req.System != "" {
+		messages = append(messages, ChatMessage{Role: "system", Content: req.System})
+	}
+	for _, msg := range req.Messages {
+		messages = append(messages, ChatMessage{Role: msg.Role, Content: msg.Text})
+	}
+	resp, err := c.Complete(ctx, userID, ChatRequest{
+		Model:       req.Model,
+		Messages:    messages,
+		Temperature: req.Temperature,
+		MaxTokens:   req.MaxTokens,
+	})
+	if err != nil {
+		return ProviderResponse{}, err
+	}
+	return ProviderResponse{
+		Text:         resp.Text(),
+		InputTokens:  resp.Usage.PromptTokens,
+		OutputTokens: resp.Usage.CompletionTokens,
+	}, nil
+}
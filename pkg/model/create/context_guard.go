@@ -0,0 +1,116 @@
+package create
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ikermy/AiR_Common/pkg/mode"
+	"github.com/ikermy/AiR_Common/pkg/providererr"
+)
+
+// ContextTooLongError запрос не помещается в контекст модели даже без истории диалога:
+// слишком длинные промпт, база знаний или сообщение пользователя
+type ContextTooLongError struct {
+	Model  string
+	Tokens int // оценка EstimateTokens
+	Limit  int
+}
+
+func (e *ContextTooLongError) Error() string {
+	return fmt.Sprintf("запрос ~%d токенов не помещается в контекст модели %s (%d)", e.Tokens, e.Model, e.Limit)
+}
+
+// Is относит ошибку к providererr.ErrContextTooLong
+func (e *ContextTooLongError) Is(target error) bool {
+	return target == providererr.ErrContextTooLong
+}
+
+// FitHistory сколько первых сообщений истории отбросить, чтобы fixed (промпт, инструменты,
+// схема ответа) и история уложились в limit. sizes — токены сообщений истории по порядку;
+// последнее — текущее сообщение пользователя, его отбросить нельзя. limit <= 0 — лимит неизвестен
+func FitHistory(modelName string, limit, fixed int, sizes []int) (int, error) {
+	if limit <= 0 {
+		return 0, nil
+	}
+	total := fixed
+	for _, n := range sizes {
+		total += n
+	}
+	drop := 0
+	for total > limit && drop < len(sizes)-1 {
+		total -= sizes[drop]
+		drop++
+	}
+	if total > limit {
+		return drop, &ContextTooLongError{Model: modelName, Tokens: total, Limit: limit}
+	}
+	return drop, nil
+}
+
+// ChatInputLimit лимит входа модели Chat Completions: контекст ContextWindow без
+// mode.ContextOutputReserve на ответ; 0 — модель неизвестна
+func ChatInputLimit(modelName string) int {
+	window := ContextWindow(modelName)
+	if window <= mode.ContextOutputReserve {
+		return 0
+	}
+	return window - mode.ContextOutputReserve
+}
+
+// ChatMessageTokens оценка токенов сообщения Chat Completions вместе со служебными
+// токенами роли (~4 на сообщение)
+func ChatMessageTokens(msg ChatMessage, modelName string) int {
+	n := 4
+	switch c := msg.Content.(type) {
+	case string:
+		n += EstimateTokens(c, modelName)
+	case nil:
+	default:
+		data, _ := json.Marshal(c)
+		n += EstimateTokens(string(data), modelName)
+	}
+	if len(msg.ToolCalls) > 0 {
+		data, _ := json.Marshal(msg.ToolCalls)
+		n += EstimateTokens(string(data), modelName)
+	}
+	return n
+}
+
+// googleModelLimit кэшированный inputTokenLimit модели Gemini; retryAt не нулевой —
+// models.get не ответил, limit взят из ContextWindow до этого времени
+type googleModelLimit struct {
+	limit   int
+	retryAt time.Time
+}
+
+// googleModelLimits кэш лимитов моделей Gemini: имя модели → googleModelLimit
+var googleModelLimits sync.Map
+
+// InputTokenLimit лимит входных токенов модели Gemini из models.get (inputTokenLimit),
+// при ошибке — ContextWindow. Значение кэшируется на время работы процесса, ошибка —
+// на mode.GoogleModelLimitRetry, чтобы не запрашивать models.get перед каждым сообщением
+func (m *GoogleAgentClient) InputTokenLimit(userID uint32, modelName string) int {
+	if v, ok := googleModelLimits.Load(modelName); ok {
+		cached := v.(googleModelLimit)
+		if cached.retryAt.IsZero() || time.Now().Before(cached.retryAt) {
+			return cached.limit
+		}
+	}
+
+	url := fmt.Sprintf("%s/%s?key=%s", m.url, GoogleModelPath(modelName), m.resolveKey(userID))
+	responseBody, err := executeGoogleAPIGetRequest(m.ctx, url)
+	if err == nil {
+		var info struct {
+			InputTokenLimit int `json:"inputTokenLimit"`
+		}
+		if json.Unmarshal(responseBody, &info) == nil && info.InputTokenLimit > 0 {
+			googleModelLimits.Store(modelName, googleModelLimit{limit: info.InputTokenLimit})
+			return info.InputTokenLimit
+		}
+	}
+	limit := ContextWindow(modelName)
+	googleModelLimits.Store(modelName, googleModelLimit{limit: limit, retryAt: time.Now().Add(mode.GoogleModelLimitRetry)})
+	return limit
+}
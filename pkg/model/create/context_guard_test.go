@@ -0,0 +1,71 @@
+package create
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestFitHistory(t *testing.T) {
+	sizes := []int{100, 100, 100, 50}
+
+	if drop, err := FitHistory("m", 0, 1000, sizes); drop != 0 || err != nil {
+		t.Errorf("неизвестный лимит: drop=%d err=%v", drop, err)
+	}
+	if drop, err := FitHistory("m", 500, 100, sizes); drop != 0 || err != nil {
+		t.Errorf("помещается: drop=%d err=%v", drop, err)
+	}
+	if drop, err := FitHistory("m", 300, 100, sizes); drop != 2 || err != nil {
+		t.Errorf("обрезка: drop=%d err=%v, ожидалось 2", drop, err)
+	}
+
+	_, err := FitHistory("m", 120, 100, sizes)
+	var tooLong *ContextTooLongError
+	if !errors.As(err, &tooLong) || tooLong.Tokens != 150 || tooLong.Limit != 120 {
+		t.Errorf("ожидалась ContextTooLongError, получено %v", err)
+	}
+}
+
+func TestInputTokenLimit_Cache(t *testing.T) {
+	var calls atomic.Int32
+	var fail atomic.Bool
+	fail.Store(true)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		if fail.Load() {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte(`{"inputTokenLimit":500000}`))
+	}))
+	defer srv.Close()
+	client := &GoogleAgentClient{apiKey: "key", url: srv.URL + "/v1beta", ctx: context.Background()}
+	const modelName = "gemini-2.5-flash-cache-test"
+	t.Cleanup(func() { googleModelLimits.Delete(modelName) })
+
+	// Ошибка models.get — ContextWindow, повтор не раньше mode.GoogleModelLimitRetry
+	for i := 0; i < 3; i++ {
+		if got := client.InputTokenLimit(1, modelName); got != ContextWindow(modelName) {
+			t.Fatalf("при ошибке: %d", got)
+		}
+	}
+	if calls.Load() != 1 {
+		t.Fatalf("models.get после ошибки вызван %d раз", calls.Load())
+	}
+
+	// Срок ошибки истёк — лимит запрашивается снова и кэшируется без срока
+	googleModelLimits.Store(modelName, googleModelLimit{limit: 1, retryAt: time.Now().Add(-time.Second)})
+	fail.Store(false)
+	for i := 0; i < 3; i++ {
+		if got := client.InputTokenLimit(1, modelName); got != 500000 {
+			t.Fatalf("после повтора: %d", got)
+		}
+	}
+	if calls.Load() != 2 {
+		t.Fatalf("models.get вызван %d раз, want 2", calls.Load())
+	}
+}
@@ -0,0 +1,214 @@
+package create
+
+import (
+	"slices"
+	"strings"
+)
+
+// ============================================================================
+// GENERATION PARAMETERS
+// ============================================================================
+
+// GenerationParams параметры генерации ответов ассистента. nil — значение провайдера
+// по умолчанию. Параметр, которого у провайдера нет, не передаётся
+type GenerationParams struct {
+	Temperature     *float64 `json:"temperature,omitempty"`       // 0.0–2.0
+	TopP            *float64 `json:"top_p,omitempty"`             // 0.0–1.0
+	TopK            *int     `json:"top_k,omitempty"`             // только Gemini и Ollama
+	MaxOutputTokens *int     `json:"max_output_tokens,omitempty"` // лимит длины ответа в токенах
+	// Стоп-последовательности: генерация обрывается перед первой из них. Ответ ассистента —
+	// JSON, поэтому последовательность с разметкой JSON или именем поля ответа отклоняется
+	// ValidateModelData (stopBreaksJSON). Не поддерживаются OpenAI Responses API, YandexGPT и GigaChat
+	StopSequences []string `json:"stop_sequences,omitempty"`
+	// Seed делает выборку воспроизводимой (вместе с нулевой температурой). Передаётся
+	// Gemini, Mistral, OpenRouter, Grok и Ollama
+	Seed *int64 `json:"seed,omitempty"`
+}
+
+// Deterministic копия параметров для воспроизводимых ответов: seed и нулевая
+// температура, остальные параметры сохраняются. g может быть nil
+func (g *GenerationParams) Deterministic(seed int64) *GenerationParams {
+	var out GenerationParams
+	if g != nil {
+		out = *g
+	}
+	zero := 0.0
+	out.Temperature = &zero
+	out.Seed = &seed
+	return &out
+}
+
+// maxStopSequences лимит стоп-последовательностей Gemini и OpenAI-совместимых API
+const maxStopSequences = 4
+
+// Gemini generationConfig с заданными параметрами; nil — параметры не заданы.
+// Возвращается новая карта: её можно дополнять, не затрагивая g
+func (g *GenerationParams) Gemini() map[string]any {
+	if g == nil {
+		return nil
+	}
+	cfg := map[string]any{}
+	if g.Temperature != nil {
+		cfg["temperature"] = *g.Temperature
+	}
+	if g.TopP != nil {
+		cfg["topP"] = *g.TopP
+	}
+	if g.TopK != nil {
+		cfg["topK"] = *g.TopK
+	}
+	if g.MaxOutputTokens != nil {
+		cfg["maxOutputTokens"] = *g.MaxOutputTokens
+	}
+	if len(g.StopSequences) > 0 {
+		cfg["stopSequences"] = g.StopSequences
+	}
+	if g.Seed != nil {
+		cfg["seed"] = *g.Seed
+	}
+	if len(cfg) == 0 {
+		return nil
+	}
+	return cfg
+}
+
+// WithTemperature копия параметров с температурой t (вариант A/B-эксперимента). g может быть nil
+func (g *GenerationParams) WithTemperature(t float64) *GenerationParams {
+	var out GenerationParams
+	if g != nil {
+		out = *g
+	}
+	out.Temperature = &t
+	return &out
+}
+
+// Mistral completion_args агента; nil — параметры не заданы
+func (g *GenerationParams) Mistral() map[string]any {
+	if g == nil {
+		return nil
+	}
+	args := map[string]any{}
+	if g.Temperature != nil {
+		args["temperature"] = *g.Temperature
+	}
+	if g.TopP != nil {
+		args["top_p"] = *g.TopP
+	}
+	if g.MaxOutputTokens != nil {
+		args["max_tokens"] = *g.MaxOutputTokens
+	}
+	if len(g.StopSequences) > 0 {
+		args["stop"] = g.StopSequences
+	}
+	if g.Seed != nil {
+		args["random_seed"] = *g.Seed
+	}
+	if len(args) == 0 {
+		return nil
+	}
+	return args
+}
+
+// ApplyResponses добавляет параметры в запрос OpenAI Responses API. Reasoning-модели
+// отклоняют temperature и top_p, им передаётся только max_output_tokens.
+// Стоп-последовательностей и seed в Responses API нет
+func (g *GenerationParams) ApplyResponses(payload map[string]any, modelName string) {
+	if g == nil {
+		return
+	}
+	if !OpenAISupportsReasoning(modelName) {
+		if g.Temperature != nil {
+			payload["temperature"] = *g.Temperature
+		}
+		if g.TopP != nil {
+			payload["top_p"] = *g.TopP
+		}
+	}
+	if g.MaxOutputTokens != nil {
+		payload["max_output_tokens"] = *g.MaxOutputTokens
+	}
+}
+
+// ApplyChat переносит параметры в ChatRequest (OpenRouter, Grok, YandexGPT, GigaChat, Ollama)
+func (g *GenerationParams) ApplyChat(req *ChatRequest) {
+	if g == nil {
+		return
+	}
+	req.Temperature = g.Temperature
+	req.TopP = g.TopP
+	req.TopK = g.TopK
+	if g.MaxOutputTokens != nil {
+		req.MaxTokens = *g.MaxOutputTokens
+	}
+	req.Stop = g.StopSequences
+	req.Seed = g.Seed
+}
+
+// jsonLiterals значения JSON, которые модель пишет в полях ответа (blocked, operator)
+var jsonLiterals = []string{"true", "false", "null"}
+
+// stopBreaksJSON стоп-последовательность seq совпадает с разметкой JSON-ответа ассистента:
+// содержит разделитель или только пробелы (переводы строк между полями), входит в имя поля
+// ответа (fields — дополнительные поля) или в литерал JSON. Генерация обрывалась бы на ней
+// в каждом ответе, не закрыв JSON
+func stopBreaksJSON(seq string, fields []SchemaField) bool {
+	if strings.ContainsAny(seq, `{}[]":,\`) || strings.TrimSpace(seq) == "" {
+		return true
+	}
+	names := slices.Concat(reservedResponseFields, jsonLiterals)
+	for _, f := range fields {
+		names = append(names, f.Name)
+	}
+	for _, name := range names {
+		if strings.Contains(name, seq) {
+			return true
+		}
+	}
+	return false
+}
+
+// validateGeneration проверяет диапазоны параметров генерации; fields — дополнительные
+// поля ответа (для проверки стоп-последовательностей)
+func validateGeneration(g *GenerationParams, provider ProviderType, fields []SchemaField, add func(severity, field, code, format string, args ...any)) {
+	if g == nil {
+		return
+	}
+	if g.Temperature != nil && (*g.Temperature < 0 || *g.Temperature > 2) {
+		add(SeverityError, "generation.temperature", "out_of_range", "temperature %.2f вне диапазона 0–2", *g.Temperature)
+	}
+	if g.TopP != nil && (*g.TopP < 0 || *g.TopP > 1) {
+		add(SeverityError, "generation.top_p", "out_of_range", "top_p %.2f вне диапазона 0–1", *g.TopP)
+	}
+	if g.TopK != nil {
+		switch {
+		case *g.TopK < 1:
+			add(SeverityError, "generation.top_k", "out_of_range", "top_k должен быть не меньше 1")
+		case provider != ProviderGoogle && provider != ProviderOllama:
+			add(SeverityWarning, "generation.top_k", "unused", "top_k игнорируется для провайдера %s", provider)
+		}
+	}
+	if g.MaxOutputTokens != nil && *g.MaxOutputTokens < 1 {
+		add(SeverityError, "generation.max_output_tokens", "out_of_range", "max_output_tokens должен быть не меньше 1")
+	}
+	if len(g.StopSequences) > 0 {
+		broken := slices.IndexFunc(g.StopSequences, func(seq string) bool { return stopBreaksJSON(seq, fields) })
+		switch {
+		case slices.Contains(g.StopSequences, ""):
+			add(SeverityError, "generation.stop_sequences", "empty_value", "пустая стоп-последовательность")
+		case len(g.StopSequences) > maxStopSequences:
+			add(SeverityError, "generation.stop_sequences", "too_many", "стоп-последовательностей больше %d", maxStopSequences)
+		case broken >= 0:
+			add(SeverityError, "generation.stop_sequences", "breaks_json", "стоп-последовательность %q совпадает с разметкой JSON-ответа и обрывала бы его", g.StopSequences[broken])
+		case provider == ProviderOpenAI || provider == ProviderYandex || provider == ProviderGigaChat:
+			add(SeverityWarning, "generation.stop_sequences", "unused", "стоп-последовательности игнорируются для провайдера %s", provider)
+		}
+	}
+	if g.Seed != nil {
+		switch {
+		case *g.Seed < 0:
+			add(SeverityError, "generation.seed", "out_of_range", "seed не может быть отрицательным")
+		case provider == ProviderOpenAI || provider == ProviderYandex || provider == ProviderGigaChat:
+			add(SeverityWarning, "generation.seed", "unused", "seed игнорируется для провайдера %s", provider)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package create
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGenerationParams(t *testing.T) {
+	temp, topP, topK, maxTokens := 0.3, 0.8, 40, 512
+	g := &GenerationParams{Temperature: &temp, TopP: &topP, TopK: &topK, MaxOutputTokens: &maxTokens, StopSequences: []string{"END"}}
+
+	gemini := g.Gemini()
+	if gemini["temperature"] != 0.3 || gemini["topP"] != 0.8 || gemini["topK"] != 40 || gemini["maxOutputTokens"] != 512 {
+		t.Errorf("Gemini() = %v", gemini)
+	}
+	if stop, _ := gemini["stopSequences"].([]string); len(stop) != 1 {
+		t.Errorf("stopSequences = %v", gemini["stopSequences"])
+	}
+	if args := g.Mistral(); args["max_tokens"] != 512 || args["top_k"] != nil || args["stop"] == nil {
+		t.Errorf("Mistral() = %v", args)
+	}
+
+	payload := map[string]any{}
+	g.ApplyResponses(payload, "gpt-5-mini")
+	if payload["temperature"] != nil || payload["max_output_tokens"] != 512 {
+		t.Errorf("reasoning-модель получила %v", payload)
+	}
+
+	var req ChatRequest
+	g.ApplyChat(&req)
+	if req.Temperature == nil || *req.TopP != 0.8 || req.MaxTokens != 512 || len(req.Stop) != 1 {
+		t.Errorf("ApplyChat: %+v", req)
+	}
+
+	var nilParams *GenerationParams
+	if nilParams.Gemini() != nil || nilParams.Mistral() != nil {
+		t.Error("nil параметры должны давать nil")
+	}
+}
+
+func TestValidateModelData_Generation(t *testing.T) {
+	temp, topK := 2.5, 10
+	data := &UniversalModelData{
+		Prompt:     "p",
+		Provider:   ProviderMistral,
+		GptType:    &GptType{Name: "mistral-small-latest"},
+		Generation: &GenerationParams{Temperature: &temp, TopK: &topK, StopSequences: []string{"a", "b", "c", "d", "e"}},
+	}
+	codes := map[string]string{}
+	for _, i := range ValidateModelData(data) {
+		codes[i.Field] = i.Code
+	}
+	if codes["generation.temperature"] != "out_of_range" || codes["generation.top_k"] != "unused" || codes["generation.stop_sequences"] != "too_many" {
+		t.Errorf("замечания: %v", codes)
+	}
+}
+
+func TestValidateModelData_StopSequencesBreakJSON(t *testing.T) {
+	validate := func(stop string) string {
+		data := &UniversalModelData{
+			Prompt:         "p",
+			Provider:       ProviderMistral,
+			GptType:        &GptType{Name: "mistral-small-latest"},
+			ResponseFields: []SchemaField{{Name: "order_id", Type: "string"}},
+			Generation:     &GenerationParams{StopSequences: []string{"КОНЕЦ", stop}},
+		}
+		for _, i := range ValidateModelData(data) {
+			if i.Field == "generation.stop_sequences" {
+				return i.Code
+			}
+		}
+		return ""
+	}
+
+	for _, stop := range []string{"}", `"`, "\n\n", "\n", "  ", "mess", "order", "true", "null"} {
+		if code := validate(stop); code != "breaks_json" {
+			t.Errorf("%q: замечание %q, ожидалось breaks_json", stop, code)
+		}
+	}
+	if code := validate("###"); code != "" {
+		t.Errorf("допустимая последовательность: %q", code)
+	}
+}
+
+func TestGenerationParams_Deterministic(t *testing.T) {
+	temp, maxTokens := 0.9, 256
+	g := &GenerationParams{Temperature: &temp, MaxOutputTokens: &maxTokens}
+
+	d := g.Deterministic(42)
+	if *g.Temperature != 0.9 || g.Seed != nil {
+		t.Fatal("Deterministic изменил исходные параметры")
+	}
+	if gemini := d.Gemini(); gemini["seed"] != int64(42) || gemini["temperature"] != 0.0 || gemini["maxOutputTokens"] != 256 {
+		t.Errorf("Gemini() = %v", gemini)
+	}
+	if args := d.Mistral(); args["random_seed"] != int64(42) {
+		t.Errorf("Mistral() = %v", args)
+	}
+
+	payload := map[string]any{}
+	(*GenerationParams)(nil).Deterministic(7).ApplyResponses(payload, "gpt-4.1")
+	if payload["temperature"] != 0.0 || payload["seed"] != nil {
+		t.Errorf("ApplyResponses: %v", payload)
+	}
+
+	var req ChatRequest
+	d.ApplyChat(&req)
+	if req.Seed == nil || *req.Seed != 42 || *req.Temperature != 0 {
+		t.Errorf("ApplyChat: %+v", req)
+	}
+}
+
+func TestGenerateImage_GenerationConfig(t *testing.T) {
+	var payload struct {
+		GenerationConfig map[string]any `json:"generationConfig"`
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		payload.GenerationConfig = nil
+		_ = json.NewDecoder(r.Body).Decode(&payload)
+		_, _ = w.Write([]byte(`{"candidates":[{"content":{"parts":[{"inlineData":{"mimeType":"image/png","data":"iVBORw=="}}]}}]}`))
+	}))
+	defer srv.Close()
+	client := &GoogleAgentClient{apiKey: "key", url: srv.URL, ctx: context.Background()}
+
+	if _, _, err := client.GenerateImage("кот", "1:1", nil); err != nil {
+		t.Fatal(err)
+	}
+	if payload.GenerationConfig["temperature"] != 0.4 {
+		t.Fatalf("по умолчанию: %v", payload.GenerationConfig)
+	}
+
+	// Параметры выборки модели заменяют значения по умолчанию, лимит ответа чата не переносится
+	temperature, maxTokens := 1.2, 100
+	configured := (&GenerationParams{Temperature: &temperature, MaxOutputTokens: &maxTokens}).Gemini()
+	if _, _, err := client.GenerateImage("кот", "1:1", configured); err != nil {
+		t.Fatal(err)
+	}
+	if payload.GenerationConfig["temperature"] != 1.2 || payload.GenerationConfig["maxOutputTokens"] != nil {
+		t.Fatalf("из настроек модели: %v", payload.GenerationConfig)
+	}
+}
@@ -0,0 +1,398 @@
+package create
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ikermy/AiR_Common/pkg/mode"
+	"github.com/ikermy/AiR_Common/pkg/providererr"
+)
+
+// gigaChatTokenRefreshBefore за сколько до истечения access token запрашивается заново
+// (токен живёт 30 минут)
+const gigaChatTokenRefreshBefore = time.Minute
+
+// GigaChatClient клиент Sber GigaChat API. Ключ пользователя — ключ авторизации из личного
+// кабинета (Base64 от Client ID:Client Secret), при необходимости с префиксом scope:
+// "GIGACHAT_API_CORP:<ключ>"; без префикса используется mode.GigaChatScope.
+// Ключ обменивается на access token (OAuth), токен кэшируется до истечения срока.
+type GigaChatClient struct {
+	url         string
+	oauthURL    string
+	httpClient  *http.Client
+	tlsErr      error // ошибка загрузки mode.GigaChatCAFile, возвращается каждым запросом
+	keyResolver func(userID uint32) string
+
+	tokenMu    sync.Mutex
+	tokens     map[string]gigaChatToken // ключ авторизации -> access token (под tokenMu)
+	tokenLocks tokenLocks               // получение access token по ключу авторизации
+}
+
+type gigaChatToken struct {
+	token     string
+	expiresAt time.Time
+}
+
+// NewGigaChatClient создаёт клиент; ключ пользователя читается через keyResolver
+// (db.GetUserAPIKey(userID, ProviderGigaChat)). TLS настраивается по mode.GigaChatCAFile
+// и mode.GigaChatInsecureTLS.
+func NewGigaChatClient(baseURL, oauthURL string, keyResolver func(userID uint32) string) *GigaChatClient {
+	httpClient, err := gigaChatHTTPClient(mode.GigaChatCAFile, mode.GigaChatInsecureTLS)
+	return &GigaChatClient{
+		url:         strings.TrimRight(baseURL, "/"),
+		oauthURL:    oauthURL,
+		httpClient:  httpClient,
+		tlsErr:      err,
+		keyResolver: keyResolver,
+		tokens:      make(map[string]gigaChatToken),
+	}
+}
+
+// gigaChatHTTPClient HTTP-клиент с системными корнями и дополнительным CA из caFile
+func gigaChatHTTPClient(caFile string, insecure bool) (*http.Client, error) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	var loadErr error
+	if insecure {
+		tlsConfig.InsecureSkipVerify = true
+	} else if caFile != "" {
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		pem, err := os.ReadFile(caFile)
+		switch {
+		case err != nil:
+			loadErr = fmt.Errorf("ошибка чтения сертификата GigaChat %s: %w", caFile, err)
+		case !pool.AppendCertsFromPEM(pem):
+			loadErr = fmt.Errorf("файл %s не содержит PEM-сертификатов", caFile)
+		default:
+			tlsConfig.RootCAs = pool
+		}
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	return &http.Client{Timeout: 120 * time.Second, Transport: transport}, loadErr
+}
+
+// parseGigaChatKey разделяет "[scope:]ключ"; Base64-ключ не содержит двоеточий
+func parseGigaChatKey(key string) (scope, authKey string) {
+	key = strings.TrimSpace(key)
+	if s, k, ok := strings.Cut(key, ":"); ok {
+		return s, k
+	}
+	return mode.GigaChatScope, key
+}
+
+// HasAPIKey возвращает true если для пользователя задан ключ авторизации
+func (c *GigaChatClient) HasAPIKey(userID uint32) bool {
+	return c.keyResolver != nil && c.keyResolver(userID) != ""
+}
+
+// accessToken access token пользователя; кэшируется до истечения срока
+func (c *GigaChatClient) accessToken(ctx context.Context, userID uint32) (string, error) {
+	if c.tlsErr != nil {
+		return "", c.tlsErr
+	}
+	if !c.HasAPIKey(userID) {
+		return "", fmt.Errorf("ключ авторизации GigaChat не настроен для пользователя %d", userID)
+	}
+	scope, authKey := parseGigaChatKey(c.keyResolver(userID))
+	if token, ok := c.cachedToken(authKey); ok {
+		return token, nil
+	}
+	// Запрос OAuth идёт без tokenMu: ждут только запросы с тем же ключом
+	unlock := c.tokenLocks.lock(authKey)
+	defer unlock()
+	if token, ok := c.cachedToken(authKey); ok {
+		return token, nil
+	}
+
+	form := url.Values{"scope": {scope}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.oauthURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", fmt.Errorf("ошибка создания запроса OAuth: %w", err)
+	}
+	req.Header.Set("Authorization", "Basic "+authKey)
+	req.Header.Set("RqUID", newRqUID())
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("ошибка получения токена GigaChat: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("ошибка чтения ответа OAuth: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", providererr.New("OAuth GigaChat", resp.StatusCode, resp.Header, body)
+	}
+	var out struct {
+		AccessToken string `json:"access_token"`
+		ExpiresAt   int64  `json:"expires_at"` // Unix-время в миллисекундах
+	}
+	if err := json.Unmarshal(body, &out); err != nil {
+		return "", fmt.Errorf("ошибка парсинга ответа OAuth: %w", err)
+	}
+	if out.AccessToken == "" {
+		return "", fmt.Errorf("OAuth GigaChat вернул пустой токен")
+	}
+	c.tokenMu.Lock()
+	c.tokens[authKey] = gigaChatToken{token: out.AccessToken, expiresAt: time.UnixMilli(out.ExpiresAt)}
+	c.tokenMu.Unlock()
+	return out.AccessToken, nil
+}
+
+// cachedToken access token из кэша, если до истечения больше gigaChatTokenRefreshBefore
+func (c *GigaChatClient) cachedToken(authKey string) (string, bool) {
+	c.tokenMu.Lock()
+	defer c.tokenMu.Unlock()
+	if t, ok := c.tokens[authKey]; ok && time.Until(t.expiresAt) > gigaChatTokenRefreshBefore {
+		return t.token, true
+	}
+	return "", false
+}
+
+// dropToken удаляет token из кэша, если его ещё не заменил другой запрос
+func (c *GigaChatClient) dropToken(userID uint32, token string) {
+	_, authKey := parseGigaChatKey(c.keyResolver(userID))
+	c.tokenMu.Lock()
+	defer c.tokenMu.Unlock()
+	if t, ok := c.tokens[authKey]; ok && t.token == token {
+		delete(c.tokens, authKey)
+	}
+}
+
+// newRqUID идентификатор запроса OAuth (UUID v4)
+func newRqUID() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// do выполняет запрос к GigaChat API от имени пользователя. На 401 токен считается
+// отозванным: он удаляется из кэша и запрос повторяется один раз с новым
+func (c *GigaChatClient) do(ctx context.Context, method, path string, body any, userID uint32) ([]byte, error) {
+	var data []byte
+	if body != nil {
+		var err error
+		if data, err = json.Marshal(body); err != nil {
+			return nil, fmt.Errorf("ошибка сериализации запроса: %w", err)
+		}
+	}
+	for attempt := 0; ; attempt++ {
+		token, err := c.accessToken(ctx, userID)
+		if err != nil {
+			return nil, err
+		}
+		respBody, err := c.send(ctx, method, path, data, token)
+		var statusErr *providererr.StatusError
+		if attempt == 0 && errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusUnauthorized {
+			c.dropToken(userID, token)
+			continue
+		}
+		return respBody, err
+	}
+}
+
+// send выполняет один HTTP-запрос с access token
+func (c *GigaChatClient) send(ctx context.Context, method, path string, data []byte, token string) ([]byte, error) {
+	var reqBody io.Reader
+	if data != nil {
+		reqBody = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.url+path, reqBody)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка создания HTTP запроса: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка HTTP запроса к GigaChat: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения ответа GigaChat: %w", err)
+	}
+	if resp.StatusCode >= 400 {
+		return nil, providererr.New("GigaChat", resp.StatusCode, resp.Header, respBody)
+	}
+	return respBody, nil
+}
+
+// gigaChatMessage сообщение GigaChat: результат функции передаётся ролью "function"
+type gigaChatMessage struct {
+	Role         string                `json:"role"`
+	Content      string                `json:"content"`
+	Name         string                `json:"name,omitempty"`
+	FunctionCall *gigaChatFunctionCall `json:"function_call,omitempty"`
+}
+
+// gigaChatFunctionCall вызов функции; аргументы — JSON-объект, а не строка
+type gigaChatFunctionCall struct {
+	Name      string          `json:"name"`
+	Arguments json.RawMessage `json:"arguments"`
+}
+
+// Complete выполняет запрос chat/completions. Функции передаются в functions,
+// модель вызывает не больше одной функции за ответ.
+func (c *GigaChatClient) Complete(ctx context.Context, userID uint32, req ChatRequest) (ChatResponse, error) {
+	payload := map[string]any{
+		"model":    req.Model,
+		"messages": gigaChatMessages(req.Messages),
+		"stream":   false,
+	}
+	if req.Temperature != nil {
+		payload["temperature"] = *req.Temperature
+	}
+	if req.TopP != nil {
+		payload["top_p"] = *req.TopP
+	}
+	if req.MaxTokens > 0 {
+		payload["max_tokens"] = req.MaxTokens
+	}
+	if len(req.Tools) > 0 {
+		functions := make([]ChatFunction, 0, len(req.Tools))
+		for _, t := range req.Tools {
+			functions = append(functions, t.Function)
+		}
+		payload["functions"] = functions
+		payload["function_call"] = "auto"
+	}
+
+	body, err := c.do(ctx, http.MethodPost, "/chat/completions", payload, userID)
+	if err != nil {
+		return ChatResponse{}, err
+	}
+	var out struct {
+		Choices []struct {
+			Message      gigaChatMessage `json:"message"`
+			FinishReason string          `json:"finish_reason"`
+		} `json:"choices"`
+		Usage ChatUsage `json:"usage"`
+	}
+	if err := json.Unmarshal(body, &out); err != nil {
+		return ChatResponse{}, fmt.Errorf("ошибка парсинга ответа GigaChat: %w", err)
+	}
+	if len(out.Choices) == 0 {
+		return ChatResponse{}, fmt.Errorf("получен пустой ответ от GigaChat")
+	}
+	choice := out.Choices[0]
+
+	message := ChatMessage{Role: "assistant", Content: choice.Message.Content}
+	finish := choice.FinishReason
+	if call := choice.Message.FunctionCall; call != nil {
+		var tc ChatToolCall
+		tc.ID = "call_0"
+		tc.Type = "function"
+		tc.Function.Name = call.Name
+		tc.Function.Arguments = string(call.Arguments)
+		message.ToolCalls = []ChatToolCall{tc}
+		finish = "tool_calls"
+	}
+	return ChatResponse{Message: message, FinishReason: finish, Usage: out.Usage}, nil
+}
+
+// gigaChatMessages переводит историю Chat Completions в сообщения GigaChat: content parts
+// склеиваются в текст, каждый вызов функции — отдельное сообщение assistant с function_call,
+// результат — сообщение "function" с именем функции
+func gigaChatMessages(messages []ChatMessage) []gigaChatMessage {
+	result := make([]gigaChatMessage, 0, len(messages))
+	for _, msg := range messages {
+		switch {
+		case msg.Role == "tool":
+			content, _ := msg.Content.(string)
+			result = append(result, gigaChatMessage{Role: "function", Name: msg.Name, Content: content})
+		case len(msg.ToolCalls) > 0:
+			for _, call := range msg.ToolCalls {
+				args := json.RawMessage(call.Function.Arguments)
+				if !json.Valid(args) {
+					args = json.RawMessage("{}")
+				}
+				result = append(result, gigaChatMessage{
+					Role:         "assistant",
+					Content:      chatMessageText(msg.Content),
+					FunctionCall: &gigaChatFunctionCall{Name: call.Function.Name, Arguments: args},
+				})
+			}
+		default:
+			result = append(result, gigaChatMessage{Role: msg.Role, Content: chatMessageText(msg.Content)})
+		}
+	}
+	return result
+}
+
+// ListModels список моделей GET /models. Структурированный ответ по схеме GigaChat
+// не поддерживает — схема AssistResponse передаётся в промпте
+func (c *GigaChatClient) ListModels(ctx context.Context, userID uint32) ([]ChatModelInfo, error) {
+	body, err := c.do(ctx, http.MethodGet, "/models", nil, userID)
+	if err != nil {
+		return nil, err
+	}
+	var out struct {
+		Data []struct {
+			ID string `json:"id"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &out); err != nil {
+		return nil, fmt.Errorf("ошибка парсинга списка моделей GigaChat: %w", err)
+	}
+	result := make([]ChatModelInfo, 0, len(out.Data))
+	for _, item := range out.Data {
+		result = append(result, ChatModelInfo{ID: item.ID, Name: item.ID, SupportedParameters: []string{"tools"}})
+	}
+	return result, nil
+}
+
+// gigaChatProvider — агент на стороне GigaChat не создаётся: AssistId хранит имя модели
+// ("GigaChat-2-Max"), остальное передаётся с каждым запросом (pkg/model/gigachat)
+type gigaChatProvider struct{ m *UniversalModel }
+
+func (p gigaChatProvider) Provider() ProviderType { return ProviderGigaChat }
+
+func (p gigaChatProvider) Capabilities() Capabilities {
+	return Capabilities{}
+}
+
+func (p gigaChatProvider) CreateAgent(_ uint32, modelData *UniversalModelData, _ []Ids) (UMCR, error) {
+	return UMCR{AssistID: modelData.GptType.Name, Provider: ProviderGigaChat}, nil
+}
+
+func (p gigaChatProvider) UpdateAgent(userID uint32, _, updated *UniversalModelData) error {
+	return p.m.updateNamedAgent(userID, ProviderGigaChat, updated)
+}
+
+func (p gigaChatProvider) DeleteAgent(_ uint32, _ *UserModelRecord, _ bool, progressCallback func(string)) error {
+	if progressCallback != nil {
+		progressCallback("✅ Модель GigaChat успешно удалена")
+	}
+	return nil
+}
+
+func (p gigaChatProvider) Request(ctx context.Context, userID uint32, req ProviderRequest) (ProviderResponse, error) {
+	return chatProviderRequest(ctx, p.m.gigachatClient, userID, req)
+}
+
+func (p gigaChatProvider) Transcribe(context.Context, uint32, []byte, string) (string, error) {
+	return "", fmt.Errorf("GigaChat не поддерживает транскрипцию аудио")
+}
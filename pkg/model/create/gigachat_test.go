@@ -0,0 +1,74 @@
+package create
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestGigaChatDo_RefreshesTokenOnUnauthorized(t *testing.T) {
+	var issued, calls atomic.Int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("/oauth", func(w http.ResponseWriter, r *http.Request) {
+		n := issued.Add(1)
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"access_token": "token-" + string(rune('0'+n)), "expires_at": time.Now().Add(30 * time.Minute).UnixMilli(),
+		})
+	})
+	mux.HandleFunc("/api/models", func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		// Первый токен отозван на стороне API, хотя срок ещё не истёк
+		if r.Header.Get("Authorization") == "Bearer token-1" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte(`{}`))
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	c := &GigaChatClient{
+		url: srv.URL + "/api", oauthURL: srv.URL + "/oauth", httpClient: srv.Client(),
+		keyResolver: func(uint32) string { return "scope:key" }, tokens: make(map[string]gigaChatToken),
+	}
+	if _, err := c.do(context.Background(), http.MethodGet, "/models", nil, 1); err != nil {
+		t.Fatalf("do: %v", err)
+	}
+	if issued.Load() != 2 || calls.Load() != 2 {
+		t.Fatalf("issued=%d calls=%d, want 2 and 2", issued.Load(), calls.Load())
+	}
+	if token, _ := c.cachedToken("key"); token != "token-2" {
+		t.Fatalf("cached token = %q", token)
+	}
+}
+
+func TestGigaChatDo_UnauthorizedRetriedOnce(t *testing.T) {
+	var calls atomic.Int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("/oauth", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]any{"access_token": "t", "expires_at": time.Now().Add(time.Hour).UnixMilli()})
+	})
+	mux.HandleFunc("/api/models", func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusUnauthorized)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	c := &GigaChatClient{
+		url: srv.URL + "/api", oauthURL: srv.URL + "/oauth", httpClient: srv.Client(),
+		keyResolver: func(uint32) string { return "scope:key" }, tokens: make(map[string]gigaChatToken),
+	}
+	_, err := c.do(context.Background(), http.MethodGet, "/models", nil, 1)
+	if err == nil || !strings.Contains(err.Error(), "401") {
+		t.Fatalf("err = %v, want 401", err)
+	}
+	if calls.Load() != 2 {
+		t.Fatalf("calls = %d, want 2", calls.Load())
+	}
+}
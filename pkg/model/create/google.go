@@ -8,66 +8,16 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"slices"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/ikermy/AiR_Common/pkg/mode"
+	"github.com/ikermy/AiR_Common/pkg/providererr"
 )
 
-// GoogleSchemaJSON - JSON Schema для структурированных ответов Gemini Agent
-// Используется в response_schema для форсирования JSON формата ответов
-const GoogleSchemaJSON = `{
-	"type": "object",
-	"properties": {
-		"message": {
-			"type": "string",
-			"description": "Text message for user. Leave empty (\"\") if sending files with caption!"
-		},
-		"action": {
-			"type": "object",
-			"properties": {
-				"send_files": {
-					"type": "array",
-					"items": {
-						"type": "object",
-						"properties": {
-							"type": {
-								"type": "string",
-								"enum": ["photo", "video", "audio", "doc"],
-								"description": "File type"
-							},
-							"url": {
-								"type": "string",
-								"description": "File URL"
-							},
-							"file_name": {
-								"type": "string",
-								"description": "File name"
-							},
-							"caption": {
-								"type": "string",
-								"description": "File caption - use this field to message user when sending files"
-							}
-						},
-						"required": ["type", "url", "file_name", "caption"]
-					}
-				}
-			},
-			"required": ["send_files"]
-		},
-		"target": {
-			"type": "boolean",
-			"description": "Is dialog goal achieved"
-		},
-		"operator": {
-			"type": "boolean",
-			"description": "Is operator connection required"
-		}
-	},
-	"required": ["action", "target", "operator"]
-}`
-
 // GoogleAgentClient клиент для работы с Google Gemini API
 type GoogleAgentClient struct {
 	apiKey         string
@@ -77,6 +27,8 @@ type GoogleAgentClient struct {
 	promptFetcher  GooglePromptHintFetcher
 	toolsFetcher   GoogleFunctionDeclarationsFetcher
 	keyResolver    func(userID uint32) string // Резолвер персональных ключей; nil → глобальный apiKey
+	fileOwners     sync.Map                   // userID → struct{}: чьими ключами загружались файлы (FilesJanitor)
+	filesInUse     func() map[string]bool     // URI файлов в живых кэшах истории; FilesJanitor их не удаляет
 }
 
 // GooglePromptHintFetcher опционально получает prompt hint от внешнего MCP-источника.
@@ -117,6 +69,30 @@ type GoogleTool struct {
 	GoogleSearch         *struct{}             `json:"google_search,omitempty"`
 }
 
+// GoogleSafetySetting порог блокировки одной категории контента (safetySettings Gemini API)
+type GoogleSafetySetting struct {
+	Category  string `json:"category"`  // HARM_CATEGORY_*
+	Threshold string `json:"threshold"` // BLOCK_NONE, BLOCK_ONLY_HIGH, BLOCK_MEDIUM_AND_ABOVE, BLOCK_LOW_AND_ABOVE, OFF
+}
+
+// GoogleHarmCategories категории, для которых Gemini принимает safetySettings
+var GoogleHarmCategories = []string{
+	"HARM_CATEGORY_HARASSMENT",
+	"HARM_CATEGORY_HATE_SPEECH",
+	"HARM_CATEGORY_SEXUALLY_EXPLICIT",
+	"HARM_CATEGORY_DANGEROUS_CONTENT",
+	"HARM_CATEGORY_CIVIC_INTEGRITY",
+}
+
+// GoogleHarmThresholds допустимые пороги блокировки
+var GoogleHarmThresholds = []string{
+	"BLOCK_NONE",
+	"BLOCK_ONLY_HIGH",
+	"BLOCK_MEDIUM_AND_ABOVE",
+	"BLOCK_LOW_AND_ABOVE",
+	"OFF",
+}
+
 // NewGoogleAgentClient создаёт новый экземпляр GoogleAgentClient.
 // API-ключ не передаётся глобально — используется только персональный ключ
 // из БД через SetKeyResolver.
@@ -134,6 +110,12 @@ func (m *GoogleAgentClient) SetMCPConfigFetchers(promptFetcher GooglePromptHintF
 	m.toolsFetcher = toolsFetcher
 }
 
+// SetFilesInUse устанавливает источник URI файлов, на которые ссылается ещё живая история
+// диалогов: FilesJanitor не удаляет их независимо от возраста. Задаётся до запуска FilesJanitor
+func (m *GoogleAgentClient) SetFilesInUse(fn func() map[string]bool) {
+	m.filesInUse = fn
+}
+
 // SetKeyResolver устанавливает функцию-резолвер персонального API-ключа пользователя.
 func (m *GoogleAgentClient) SetKeyResolver(fn func(userID uint32) string) {
 	m.keyResolver = fn
@@ -195,7 +177,7 @@ func executeGoogleAPIRequest(ctx context.Context, url string, payload any) ([]by
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("API вернул статус %d: %s", resp.StatusCode, string(responseBody))
+		return nil, providererr.New("Google", resp.StatusCode, resp.Header, responseBody)
 	}
 
 	return responseBody, nil
@@ -216,7 +198,7 @@ func executeGoogleAPIGetRequest(ctx context.Context, url string) ([]byte, error)
 
 	if resp.StatusCode != http.StatusOK {
 		responseBody, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("API вернул статус %d: %s", resp.StatusCode, string(responseBody))
+		return nil, providererr.New("Google", resp.StatusCode, resp.Header, responseBody)
 	}
 
 	responseBody, err := io.ReadAll(resp.Body)
@@ -243,7 +225,7 @@ func executeGoogleAPIDeleteRequest(ctx context.Context, url string) error {
 
 	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusNotFound {
 		responseBody, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("API вернул статус %d: %s", resp.StatusCode, string(responseBody))
+		return providererr.New("Google", resp.StatusCode, resp.Header, responseBody)
 	}
 
 	return nil
@@ -327,7 +309,7 @@ func (m *GoogleAgentClient) createGoogleAgent(modelData *UniversalModelData, use
 		// Только без tools можем добавить response_schema при создании
 		payload["generation_config"] = map[string]any{
 			"response_mime_type": "application/json",
-			"response_schema":    ParseModelSchemaJSON(false), // false = БЕЗ additionalProperties для Google
+			"response_schema":    modelData.ResponseSchema().Gemini(),
 		}
 	}
 
@@ -362,8 +344,8 @@ func (m *GoogleAgentClient) createGoogleAgent(modelData *UniversalModelData, use
 	// Агентом является комбинация: model_name + system_instruction + tools
 	// Поэтому AssistID будет составным идентификатором: "models/{model_name}"
 
-	// Формируем AssistID как путь к модели
-	agentID := fmt.Sprintf("models/%s", modelData.GptType.Name)
+	// Формируем AssistID как путь к модели (models/{name} или tunedModels/{id})
+	agentID := GoogleModelPath(modelData.GptType.Name)
 
 	// Проверяем доступность модели через тестовый запрос
 	testURL := fmt.Sprintf("%s/%s:generateContent?key=%s", m.url, agentID, m.resolveKey(userID))
@@ -389,6 +371,9 @@ func (m *GoogleAgentClient) createGoogleAgent(modelData *UniversalModelData, use
 	if tools, ok := payload["tools"]; ok {
 		testPayload["tools"] = tools
 	}
+	if len(modelData.SafetySettings) > 0 {
+		testPayload["safetySettings"] = modelData.SafetySettings
+	}
 
 	responseBody, err := executeGoogleAPIRequest(m.ctx, testURL, testPayload)
 	if err != nil {
@@ -447,13 +432,25 @@ func (m *GoogleAgentClient) DeleteGoogleAgent(agentID string) error {
 		responseBody, _ := io.ReadAll(resp.Body)
 
 		if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusNotFound {
-			return fmt.Errorf("API вернул статус %d: %s", resp.StatusCode, string(responseBody))
+			return providererr.New("Google", resp.StatusCode, resp.Header, responseBody)
 		}
 	}
 
 	return nil
 }
 
+// mediaGenerationConfig generationConfig генерации изображения или видео: defaults, поверх
+// них — параметры выборки из настроек модели (temperature, topP, topK, seed). Лимит длины
+// ответа и стоп-последовательности настроены для текста чата и к медиа не применяются
+func mediaGenerationConfig(defaults, configured map[string]any) map[string]any {
+	for _, key := range []string{"temperature", "topP", "topK", "seed"} {
+		if v, ok := configured[key]; ok {
+			defaults[key] = v
+		}
+	}
+	return defaults
+}
+
 // ============================================================================
 // VIDEO GENERATION - Генерация видео через Google Veo/Imagen 3
 // Документация: https://ai.google.dev/gemini-api/docs/vision
@@ -464,8 +461,9 @@ func (m *GoogleAgentClient) DeleteGoogleAgent(agentID string) error {
 // - prompt: текстовое описание видео
 // - aspectRatio: "16:9", "9:16", "1:1" (по умолчанию "16:9")
 // - duration: длительность в секундах 4-8 (по умолчанию 4)
+// - generationConfig: generationConfig агента, nil — значения по умолчанию (mediaGenerationConfig)
 // Возвращает: данные видео, MIME тип, ошибку
-func (m *GoogleAgentClient) GenerateVideo(prompt string, aspectRatio string, duration int) ([]byte, string, error) {
+func (m *GoogleAgentClient) GenerateVideo(prompt string, aspectRatio string, duration int, generationConfig map[string]any) ([]byte, string, error) {
 	if prompt == "" {
 		return nil, "", fmt.Errorf("пустой промпт для генерации видео")
 	}
@@ -525,12 +523,12 @@ Please create a visually stunning video that captures the essence of the descrip
 				},
 			},
 		},
-		"generationConfig": map[string]any{
+		"generationConfig": mediaGenerationConfig(map[string]any{
 			"temperature":     0.9,
 			"topK":            40,
 			"topP":            0.95,
 			"maxOutputTokens": 2048,
-		},
+		}, generationConfig),
 	}
 
 	// URL для генерации
@@ -666,7 +664,8 @@ func parseAudioTranscriptionResponse(responseBody []byte) (string, error) {
 
 // TranscribeAudio транскрибирует аудио файл в текст
 // Google Gemini поддерживает: MP3, WAV, FLAC, AAC, OGG, и другие форматы
-// Для файлов до 20MB использует inline_data (base64)
+// Файлы до mode.GoogleInlineDataLimit передаются в inline_data (base64), большие
+// загружаются в File API и удаляются после транскрибации
 func (m *GoogleAgentClient) TranscribeAudio(audioData []byte, mimeType string) (string, error) {
 	if len(audioData) == 0 {
 		return "", fmt.Errorf("пустые аудиоданные")
@@ -677,6 +676,18 @@ func (m *GoogleAgentClient) TranscribeAudio(audioData []byte, mimeType string) (
 		mimeType = "audio/mpeg" // По умолчанию MP3
 	}
 
+	if len(audioData) > mode.GoogleInlineDataLimit {
+		file, err := m.UploadFile(0, audioData, mimeType, "audio")
+		if err != nil {
+			return "", fmt.Errorf("ошибка загрузки аудио в File API: %w", err)
+		}
+		defer func() { _ = m.DeleteAudioFile(file.Name) }()
+		if file, err = m.WaitFileActive(0, file); err != nil {
+			return "", err
+		}
+		return m.TranscribeAudioFile(file.URI)
+	}
+
 	// Кодируем аудио в base64
 	audioBase64 := base64.StdEncoding.EncodeToString(audioData)
 
@@ -767,15 +778,7 @@ func (m *GoogleAgentClient) DeleteAudioFile(fileName string) error {
 		return fmt.Errorf("пустое имя файла")
 	}
 
-	deleteURL := fmt.Sprintf("https://generativelanguage.googleapis.com/v1beta/%s?key=%s", fileName, m.apiKey)
-
-	if err := executeGoogleAPIDeleteRequest(m.ctx, deleteURL); err != nil {
-		return fmt.Errorf("ошибка при вызове API: %w", err)
-	}
-
-	//logger.Debug("Аудио файл %s успешно удалён", fileName)
-
-	return nil
+	return m.DeleteFile(0, fileName)
 }
 
 // ============================================================================
@@ -1064,8 +1067,9 @@ func (m *UniversalModel) createGoogleModel(userID uint32, modelData *UniversalMo
 
 // GenerateImage генерирует изображение через Google Gemini API с Imagen 3
 // ВАЖНО: Google Gemini 2.0+ поддерживает встроенную генерацию изображений
+// generationConfig — generationConfig агента, nil — значения по умолчанию (mediaGenerationConfig)
 // Возвращает: imageData (PNG bytes), mimeType, error
-func (m *GoogleAgentClient) GenerateImage(prompt string, aspectRatio string) ([]byte, string, error) {
+func (m *GoogleAgentClient) GenerateImage(prompt string, aspectRatio string, generationConfig map[string]any) ([]byte, string, error) {
 	if prompt == "" {
 		return nil, "", fmt.Errorf("prompt не может быть пустым")
 	}
@@ -1095,9 +1099,9 @@ func (m *GoogleAgentClient) GenerateImage(prompt string, aspectRatio string) ([]
 				},
 			},
 		},
-		"generationConfig": map[string]any{
+		"generationConfig": mediaGenerationConfig(map[string]any{
 			"temperature": 0.4,
-		},
+		}, generationConfig),
 	}
 
 	responseBody, err := executeGoogleAPIRequest(m.ctx, imageURL, payload)
@@ -1145,3 +1149,262 @@ func (m *GoogleAgentClient) GenerateImage(prompt string, aspectRatio string) ([]
 	// Если изображение не найдено, возвращаем ошибку
 	return nil, "", fmt.Errorf("модель не сгенерировала изображение. Возможно, нужно использовать другой промпт или модель не поддерживает генерацию изображений")
 }
+
+// ============================================================================
+// CONTEXT CACHING - Явный кэш контекста Gemini (cachedContents)
+// Документация: https://ai.google.dev/gemini-api/docs/caching
+// ============================================================================
+
+// GoogleCachedContent описывает созданный кэш контекста
+type GoogleCachedContent struct {
+	Name       string    `json:"name"`       // cachedContents/{id} — передаётся в generateContent как cachedContent
+	Model      string    `json:"model"`      // models/{modelName}
+	ExpireTime time.Time `json:"expireTime"` // момент удаления кэша на стороне Google
+}
+
+// CreateCachedContent загружает system instruction, документы базы знаний и tools в кэш
+// контекста модели. Кэш нельзя изменить — при смене содержимого создаётся новый.
+// Google отклоняет кэш меньше минимального размера (порядка 1-4K токенов в зависимости от модели).
+func (m *GoogleAgentClient) CreateCachedContent(userID uint32, modelName string, systemInstruction map[string]any, contents []map[string]any, tools []map[string]any, ttl time.Duration) (GoogleCachedContent, error) {
+	if modelName == "" {
+		return GoogleCachedContent{}, fmt.Errorf("имя модели не может быть пустым")
+	}
+
+	payload := map[string]any{
+		"model": GoogleModelPath(modelName),
+		"ttl":   fmt.Sprintf("%ds", int(ttl.Seconds())),
+	}
+	if systemInstruction != nil {
+		payload["systemInstruction"] = systemInstruction
+	}
+	if len(contents) > 0 {
+		payload["contents"] = contents
+	}
+	if len(tools) > 0 {
+		payload["tools"] = tools
+	}
+
+	url := fmt.Sprintf("%s/cachedContents?key=%s", m.url, m.resolveKey(userID))
+	responseBody, err := executeGoogleAPIRequest(m.ctx, url, payload)
+	if err != nil {
+		return GoogleCachedContent{}, fmt.Errorf("ошибка создания кэша контекста: %w", err)
+	}
+
+	var cached GoogleCachedContent
+	if err := json.Unmarshal(responseBody, &cached); err != nil {
+		return GoogleCachedContent{}, fmt.Errorf("ошибка парсинга ответа cachedContents: %w", err)
+	}
+	if cached.Name == "" {
+		return GoogleCachedContent{}, fmt.Errorf("API не вернул имя кэша контекста")
+	}
+	if cached.ExpireTime.IsZero() {
+		cached.ExpireTime = time.Now().Add(ttl)
+	}
+
+	return cached, nil
+}
+
+// DeleteCachedContent удаляет кэш контекста до истечения TTL
+func (m *GoogleAgentClient) DeleteCachedContent(userID uint32, name string) error {
+	if !strings.HasPrefix(name, "cachedContents/") {
+		return fmt.Errorf("некорректное имя кэша контекста: %q", name)
+	}
+
+	url := fmt.Sprintf("%s/%s?key=%s", m.url, name, m.resolveKey(userID))
+	if err := executeGoogleAPIDeleteRequest(m.ctx, url); err != nil {
+		return fmt.Errorf("ошибка удаления кэша контекста: %w", err)
+	}
+
+	return nil
+}
+
+// ============================================================================
+// TUNED MODELS - Дообученные модели Gemini (tunedModels)
+// Документация: https://ai.google.dev/gemini-api/docs/model-tuning
+// ============================================================================
+
+// Состояния дообученной модели
+const (
+	GoogleTunedModelCreating = "CREATING" // идёт обучение
+	GoogleTunedModelActive   = "ACTIVE"   // модель готова к generateContent
+	GoogleTunedModelFailed   = "FAILED"   // обучение завершилось ошибкой
+)
+
+// GoogleModelPath путь модели в API: tunedModels/{id} остаётся как есть,
+// имя базовой модели получает префикс models/. GptType.Name может ссылаться на любую из них.
+func GoogleModelPath(modelName string) string {
+	if strings.HasPrefix(modelName, "tunedModels/") {
+		return modelName
+	}
+	return "models/" + strings.TrimPrefix(modelName, "models/")
+}
+
+// GoogleTuningExample пример обучающей выборки: вход и ожидаемый ответ модели
+type GoogleTuningExample struct {
+	TextInput string `json:"text_input"`
+	Output    string `json:"output"`
+}
+
+// GoogleTuningParams гиперпараметры обучения; нулевые значения — выбор Google
+type GoogleTuningParams struct {
+	EpochCount   int     `json:"epoch_count,omitempty"`
+	BatchSize    int     `json:"batch_size,omitempty"`
+	LearningRate float64 `json:"learning_rate,omitempty"`
+}
+
+// GoogleTuningSnapshot метрики одного шага обучения
+type GoogleTuningSnapshot struct {
+	Step        int       `json:"step"`
+	Epoch       int       `json:"epoch"`
+	MeanLoss    float64   `json:"meanLoss"`
+	ComputeTime time.Time `json:"computeTime"`
+}
+
+// GoogleTunedModel описывает дообученную модель
+type GoogleTunedModel struct {
+	Name        string    `json:"name"`        // tunedModels/{id} — указывается в GptType.Name
+	DisplayName string    `json:"displayName"` // имя для удобства идентификации
+	BaseModel   string    `json:"baseModel"`   // models/{modelName}
+	State       string    `json:"state"`       // GoogleTunedModelCreating, GoogleTunedModelActive, GoogleTunedModelFailed
+	CreateTime  time.Time `json:"createTime"`
+	UpdateTime  time.Time `json:"updateTime"`
+	TuningTask  struct {
+		StartTime    time.Time              `json:"startTime"`
+		CompleteTime time.Time              `json:"completeTime"`
+		Snapshots    []GoogleTuningSnapshot `json:"snapshots"`
+	} `json:"tuningTask"`
+}
+
+// CreateTunedModel запускает дообучение baseModel на примерах и возвращает имя
+// будущей модели (tunedModels/{id}). Обучение идёт асинхронно — готовность
+// отслеживается через GetTunedModel или WaitTunedModel.
+func (m *GoogleAgentClient) CreateTunedModel(userID uint32, baseModel, displayName string, examples []GoogleTuningExample, params GoogleTuningParams) (string, error) {
+	if baseModel == "" {
+		return "", fmt.Errorf("имя базовой модели не может быть пустым")
+	}
+	if len(examples) == 0 {
+		return "", fmt.Errorf("обучающая выборка не может быть пустой")
+	}
+
+	payload := map[string]any{
+		"display_name": displayName,
+		"base_model":   GoogleModelPath(baseModel),
+		"tuning_task": map[string]any{
+			"hyperparameters": params,
+			"training_data": map[string]any{
+				"examples": map[string]any{"examples": examples},
+			},
+		},
+	}
+
+	url := fmt.Sprintf("%s/tunedModels?key=%s", m.url, m.resolveKey(userID))
+	responseBody, err := executeGoogleAPIRequest(m.ctx, url, payload)
+	if err != nil {
+		return "", fmt.Errorf("ошибка запуска дообучения: %w", err)
+	}
+
+	// Ответ — long-running operation: tunedModels/{id}/operations/{op}
+	var operation struct {
+		Name     string `json:"name"`
+		Metadata struct {
+			TunedModel string `json:"tunedModel"`
+		} `json:"metadata"`
+	}
+	if err := json.Unmarshal(responseBody, &operation); err != nil {
+		return "", fmt.Errorf("ошибка парсинга ответа tunedModels: %w", err)
+	}
+	if name := operation.Metadata.TunedModel; name != "" {
+		return name, nil
+	}
+	if name, _, ok := strings.Cut(operation.Name, "/operations/"); ok {
+		return name, nil
+	}
+
+	return "", fmt.Errorf("API не вернул имя дообученной модели: %s", string(responseBody))
+}
+
+// GetTunedModel возвращает дообученную модель с текущим состоянием и метриками обучения
+func (m *GoogleAgentClient) GetTunedModel(userID uint32, name string) (GoogleTunedModel, error) {
+	if !strings.HasPrefix(name, "tunedModels/") {
+		return GoogleTunedModel{}, fmt.Errorf("некорректное имя дообученной модели: %q", name)
+	}
+
+	url := fmt.Sprintf("%s/%s?key=%s", m.url, name, m.resolveKey(userID))
+	responseBody, err := executeGoogleAPIGetRequest(m.ctx, url)
+	if err != nil {
+		return GoogleTunedModel{}, fmt.Errorf("ошибка получения дообученной модели: %w", err)
+	}
+
+	var tuned GoogleTunedModel
+	if err := json.Unmarshal(responseBody, &tuned); err != nil {
+		return GoogleTunedModel{}, fmt.Errorf("ошибка парсинга дообученной модели: %w", err)
+	}
+
+	return tuned, nil
+}
+
+// ListTunedModels возвращает все дообученные модели, доступные по ключу пользователя
+func (m *GoogleAgentClient) ListTunedModels(userID uint32) ([]GoogleTunedModel, error) {
+	var models []GoogleTunedModel
+	pageToken := ""
+	for {
+		query := url.Values{"pageSize": {"100"}, "key": {m.resolveKey(userID)}}
+		if pageToken != "" {
+			query.Set("pageToken", pageToken)
+		}
+
+		responseBody, err := executeGoogleAPIGetRequest(m.ctx, m.url+"/tunedModels?"+query.Encode())
+		if err != nil {
+			return nil, fmt.Errorf("ошибка получения списка дообученных моделей: %w", err)
+		}
+
+		var page struct {
+			TunedModels   []GoogleTunedModel `json:"tunedModels"`
+			NextPageToken string             `json:"nextPageToken"`
+		}
+		if err := json.Unmarshal(responseBody, &page); err != nil {
+			return nil, fmt.Errorf("ошибка парсинга списка дообученных моделей: %w", err)
+		}
+
+		models = append(models, page.TunedModels...)
+		if page.NextPageToken == "" {
+			return models, nil
+		}
+		pageToken = page.NextPageToken
+	}
+}
+
+// WaitTunedModel опрашивает модель каждые interval, пока обучение не завершится.
+// onProgress (может быть nil) получает модель после каждого опроса — по Snapshots
+// видно текущий шаг и loss. Возвращает ошибку, если обучение завершилось FAILED.
+func (m *GoogleAgentClient) WaitTunedModel(userID uint32, name string, interval time.Duration, onProgress func(GoogleTunedModel)) (GoogleTunedModel, error) {
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		tuned, err := m.GetTunedModel(userID, name)
+		if err != nil {
+			return GoogleTunedModel{}, err
+		}
+		if onProgress != nil {
+			onProgress(tuned)
+		}
+
+		switch tuned.State {
+		case GoogleTunedModelActive:
+			return tuned, nil
+		case GoogleTunedModelFailed:
+			return tuned, fmt.Errorf("дообучение модели %s завершилось ошибкой", name)
+		}
+
+		select {
+		case <-m.ctx.Done():
+			return tuned, m.ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
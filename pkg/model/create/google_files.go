@@ -0,0 +1,336 @@
+package create
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ikermy/AiR_Common/pkg/logger"
+	"github.com/ikermy/AiR_Common/pkg/mode"
+	"github.com/ikermy/AiR_Common/pkg/providererr"
+)
+
+// ============================================================================
+// FILE API - Загрузка файлов в Google File API по протоколу resumable
+// Документация: https://ai.google.dev/api/files
+// ============================================================================
+
+// GoogleFile файл в Google File API
+type GoogleFile struct {
+	Name           string    `json:"name"` // files/abc-123
+	DisplayName    string    `json:"displayName,omitempty"`
+	MimeType       string    `json:"mimeType"`
+	SizeBytes      string    `json:"sizeBytes,omitempty"`
+	CreateTime     time.Time `json:"createTime"`
+	ExpirationTime time.Time `json:"expirationTime"`
+	State          string    `json:"state"` // PROCESSING, ACTIVE, FAILED
+	URI            string    `json:"uri"`
+}
+
+// googleUploadURL адрес загрузки: .../v1beta → .../upload/v1beta/files
+func (m *GoogleAgentClient) googleUploadURL() string {
+	base := strings.TrimSuffix(m.url, "/")
+	if i := strings.LastIndex(base, "/"); i != -1 {
+		return base[:i] + "/upload" + base[i:] + "/files"
+	}
+	return base + "/upload/files"
+}
+
+// UploadFile загружает файл в File API по протоколу resumable: сессия загрузки,
+// затем части по mode.GoogleUploadChunkSize. Часть, не дошедшая из-за сети или 5xx,
+// повторяется с позиции, которую подтвердил сервер (команда query)
+func (m *GoogleAgentClient) UploadFile(userID uint32, data []byte, mimeType, displayName string) (GoogleFile, error) {
+	if len(data) == 0 {
+		return GoogleFile{}, fmt.Errorf("пустой файл")
+	}
+	apiKey := m.resolveKey(userID)
+	if apiKey == "" {
+		return GoogleFile{}, fmt.Errorf("нет API ключа Google для пользователя %d", userID)
+	}
+
+	sessionURL, err := m.startResumableUpload(apiKey, int64(len(data)), mimeType, displayName)
+	if err != nil {
+		return GoogleFile{}, err
+	}
+	m.fileOwners.Store(userID, struct{}{})
+
+	var offset int64
+	total := int64(len(data))
+	for attempt := 0; offset < total; {
+		end := min(offset+int64(mode.GoogleUploadChunkSize), total)
+		command := "upload"
+		if end == total {
+			command = "upload, finalize"
+		}
+
+		body, status, err := m.uploadChunk(sessionURL, command, offset, data[offset:end])
+		switch {
+		case err == nil && status == http.StatusOK:
+			if end == total {
+				var result struct {
+					File GoogleFile `json:"file"`
+				}
+				if err := json.Unmarshal(body, &result); err != nil {
+					return GoogleFile{}, fmt.Errorf("ошибка парсинга ответа загрузки: %w", err)
+				}
+				return result.File, nil
+			}
+			offset, attempt = end, 0
+			continue
+		case err == nil && status < http.StatusInternalServerError:
+			return GoogleFile{}, providererr.New("Google", status, nil, body)
+		}
+
+		// Сеть или 5xx: после паузы продолжаем с подтверждённой сервером позиции
+		attempt++
+		if attempt >= mode.Retry.Transient.Attempts() {
+			if err == nil {
+				err = providererr.New("Google", status, nil, body)
+			}
+			return GoogleFile{}, fmt.Errorf("загрузка файла прервана на %d из %d байт: %w", offset, total, err)
+		}
+		select {
+		case <-m.ctx.Done():
+			return GoogleFile{}, m.ctx.Err()
+		case <-time.After(mode.Retry.Transient.Backoff(attempt)):
+		}
+		if received, qErr := m.queryUploadOffset(sessionURL); qErr == nil {
+			offset = received
+		}
+	}
+	return GoogleFile{}, fmt.Errorf("загрузка файла не завершена")
+}
+
+// startResumableUpload открывает сессию загрузки и возвращает её URL
+func (m *GoogleAgentClient) startResumableUpload(apiKey string, size int64, mimeType, displayName string) (string, error) {
+	meta, _ := json.Marshal(map[string]any{"file": map[string]string{"display_name": displayName}})
+	req, err := http.NewRequestWithContext(m.ctx, http.MethodPost, m.googleUploadURL()+"?key="+apiKey, bytes.NewReader(meta))
+	if err != nil {
+		return "", fmt.Errorf("ошибка создания запроса: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Goog-Upload-Protocol", "resumable")
+	req.Header.Set("X-Goog-Upload-Command", "start")
+	req.Header.Set("X-Goog-Upload-Header-Content-Length", strconv.FormatInt(size, 10))
+	req.Header.Set("X-Goog-Upload-Header-Content-Type", mimeType)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("ошибка HTTP запроса: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return "", providererr.New("Google", resp.StatusCode, resp.Header, body)
+	}
+	sessionURL := resp.Header.Get("X-Goog-Upload-URL")
+	if sessionURL == "" {
+		return "", fmt.Errorf("API не вернул URL сессии загрузки")
+	}
+	return sessionURL, nil
+}
+
+// uploadChunk отправляет часть файла с позиции offset
+func (m *GoogleAgentClient) uploadChunk(sessionURL, command string, offset int64, chunk []byte) ([]byte, int, error) {
+	req, err := http.NewRequestWithContext(m.ctx, http.MethodPost, sessionURL, bytes.NewReader(chunk))
+	if err != nil {
+		return nil, 0, fmt.Errorf("ошибка создания запроса: %w", err)
+	}
+	req.Header.Set("X-Goog-Upload-Command", command)
+	req.Header.Set("X-Goog-Upload-Offset", strconv.FormatInt(offset, 10))
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, 0, fmt.Errorf("ошибка HTTP запроса: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, 0, fmt.Errorf("ошибка чтения ответа: %w", err)
+	}
+	return body, resp.StatusCode, nil
+}
+
+// queryUploadOffset сколько байт сессии загрузки сервер уже получил
+func (m *GoogleAgentClient) queryUploadOffset(sessionURL string) (int64, error) {
+	req, err := http.NewRequestWithContext(m.ctx, http.MethodPost, sessionURL, nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("X-Goog-Upload-Command", "query")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("API вернул статус %d", resp.StatusCode)
+	}
+	return strconv.ParseInt(resp.Header.Get("X-Goog-Upload-Size-Received"), 10, 64)
+}
+
+// GetFile состояние файла в File API
+func (m *GoogleAgentClient) GetFile(userID uint32, name string) (GoogleFile, error) {
+	body, err := executeGoogleAPIGetRequest(m.ctx, fmt.Sprintf("%s/%s?key=%s", m.url, name, m.resolveKey(userID)))
+	if err != nil {
+		return GoogleFile{}, fmt.Errorf("ошибка получения файла %s: %w", name, err)
+	}
+	var file GoogleFile
+	if err := json.Unmarshal(body, &file); err != nil {
+		return GoogleFile{}, fmt.Errorf("ошибка парсинга файла %s: %w", name, err)
+	}
+	return file, nil
+}
+
+// WaitFileActive ждёт окончания обработки файла (видео обрабатывается до минуты и дольше);
+// файл в состоянии PROCESSING нельзя передавать в generateContent
+func (m *GoogleAgentClient) WaitFileActive(userID uint32, file GoogleFile) (GoogleFile, error) {
+	deadline := time.Now().Add(mode.GoogleFileActiveTimeout)
+	for file.State == "PROCESSING" {
+		if time.Now().After(deadline) {
+			return file, fmt.Errorf("файл %s не обработан за %v", file.Name, mode.GoogleFileActiveTimeout)
+		}
+		select {
+		case <-m.ctx.Done():
+			return file, m.ctx.Err()
+		case <-time.After(2 * time.Second):
+		}
+		var err error
+		if file, err = m.GetFile(userID, file.Name); err != nil {
+			return file, err
+		}
+	}
+	if file.State == "FAILED" {
+		return file, fmt.Errorf("File API не смог обработать файл %s", file.Name)
+	}
+	return file, nil
+}
+
+// ListFiles возвращает все файлы, загруженные по ключу пользователя
+func (m *GoogleAgentClient) ListFiles(userID uint32) ([]GoogleFile, error) {
+	var files []GoogleFile
+	pageToken := ""
+	for {
+		query := url.Values{"pageSize": {"100"}, "key": {m.resolveKey(userID)}}
+		if pageToken != "" {
+			query.Set("pageToken", pageToken)
+		}
+
+		responseBody, err := executeGoogleAPIGetRequest(m.ctx, m.url+"/files?"+query.Encode())
+		if err != nil {
+			return nil, fmt.Errorf("ошибка получения списка файлов: %w", err)
+		}
+
+		var page struct {
+			Files         []GoogleFile `json:"files"`
+			NextPageToken string       `json:"nextPageToken"`
+		}
+		if err := json.Unmarshal(responseBody, &page); err != nil {
+			return nil, fmt.Errorf("ошибка парсинга списка файлов: %w", err)
+		}
+
+		files = append(files, page.Files...)
+		if page.NextPageToken == "" {
+			return files, nil
+		}
+		pageToken = page.NextPageToken
+	}
+}
+
+// DeleteFile удаляет файл из File API; уже удалённый файл (404) не считается ошибкой
+func (m *GoogleAgentClient) DeleteFile(userID uint32, name string) error {
+	if name == "" {
+		return fmt.Errorf("пустое имя файла")
+	}
+	url := fmt.Sprintf("%s/%s?key=%s", m.url, name, m.resolveKey(userID))
+	if err := executeGoogleAPIDeleteRequest(m.ctx, url); err != nil {
+		return fmt.Errorf("ошибка удаления файла %s: %w", name, err)
+	}
+	return nil
+}
+
+// DeleteFilesOlderThan удаляет файлы пользователя, загруженные раньше чем age назад.
+// Возвращает число удалённых файлов и первую ошибку удаления
+func (m *GoogleAgentClient) DeleteFilesOlderThan(userID uint32, age time.Duration) (int, error) {
+	return m.deleteFilesOlderThan(userID, age, nil)
+}
+
+// deleteFilesOlderThan DeleteFilesOlderThan, не трогающий файлы с URI из keep
+func (m *GoogleAgentClient) deleteFilesOlderThan(userID uint32, age time.Duration, keep map[string]bool) (int, error) {
+	files, err := m.ListFiles(userID)
+	if err != nil {
+		return 0, err
+	}
+
+	cutoff := time.Now().Add(-age)
+	deleted := 0
+	var firstErr error
+	for _, f := range files {
+		if f.CreateTime.IsZero() || f.CreateTime.After(cutoff) || keep[f.URI] {
+			continue
+		}
+		if err := m.DeleteFile(userID, f.Name); err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		deleted++
+	}
+	return deleted, firstErr
+}
+
+// FilesJanitor раз в mode.GoogleFilesJanitorInterval удаляет файлы старше
+// mode.GoogleFilesRetention, кроме файлов из истории живых диалогов (SetFilesInUse).
+// Проверяются ключи пользователей, загружавших файлы с момента запуска, и глобальный ключ;
+// более старые файлы Google удаляет сам через 48 часов.
+// Блокируется до отмены контекста клиента
+func (m *GoogleAgentClient) FilesJanitor() {
+	if mode.GoogleFilesRetention <= 0 {
+		return
+	}
+	ticker := time.NewTicker(mode.GoogleFilesJanitorInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-m.ctx.Done():
+			return
+		case <-ticker.C:
+			m.cleanupFiles(mode.GoogleFilesRetention)
+		}
+	}
+}
+
+// cleanupFiles один проход очистки по всем известным ключам
+func (m *GoogleAgentClient) cleanupFiles(retention time.Duration) {
+	if m.apiKey != "" {
+		m.fileOwners.LoadOrStore(uint32(0), struct{}{})
+	}
+	var keep map[string]bool
+	if m.filesInUse != nil {
+		keep = m.filesInUse()
+	}
+	m.fileOwners.Range(func(key, _ any) bool {
+		userID := key.(uint32)
+		if m.resolveKey(userID) == "" {
+			m.fileOwners.Delete(userID)
+			return true
+		}
+		deleted, err := m.deleteFilesOlderThan(userID, retention, keep)
+		if err != nil {
+			logger.Warn("Очистка Google File API: %v", err, userID)
+		}
+		if deleted > 0 {
+			logger.Info("Очистка Google File API: удалено файлов: %d", deleted, userID)
+		}
+		return m.ctx.Err() == nil
+	})
+}
@@ -0,0 +1,138 @@
+package create
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ikermy/AiR_Common/pkg/mode"
+)
+
+func TestUploadFile_ResumesAfterChunkFailure(t *testing.T) {
+	data := bytes.Repeat([]byte("a"), mode.GoogleUploadChunkSize+1000)
+
+	var (
+		mu       sync.Mutex
+		received []byte
+		failed   bool
+	)
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if r.URL.Path == "/upload/v1beta/files" {
+			if r.Header.Get("X-Goog-Upload-Protocol") != "resumable" || r.Header.Get("X-Goog-Upload-Command") != "start" {
+				t.Errorf("неожиданные заголовки start: %v", r.Header)
+			}
+			if r.Header.Get("X-Goog-Upload-Header-Content-Length") != strconv.Itoa(len(data)) {
+				t.Errorf("Content-Length = %s", r.Header.Get("X-Goog-Upload-Header-Content-Length"))
+			}
+			w.Header().Set("X-Goog-Upload-URL", srv.URL+"/session")
+			return
+		}
+
+		switch cmd := r.Header.Get("X-Goog-Upload-Command"); cmd {
+		case "query":
+			w.Header().Set("X-Goog-Upload-Size-Received", strconv.Itoa(len(received)))
+		case "upload", "upload, finalize":
+			offset, _ := strconv.Atoi(r.Header.Get("X-Goog-Upload-Offset"))
+			chunk, _ := io.ReadAll(r.Body)
+			if offset != len(received) {
+				t.Errorf("offset = %d, получено %d", offset, len(received))
+			}
+			// Последняя часть первый раз не доходит
+			if cmd == "upload, finalize" && !failed {
+				failed = true
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			received = append(received, chunk...)
+			if cmd == "upload, finalize" {
+				_, _ = w.Write([]byte(`{"file":{"name":"files/abc","mimeType":"audio/ogg","state":"ACTIVE","uri":"https://example.com/files/abc"}}`))
+			}
+		default:
+			t.Errorf("неизвестная команда %q", cmd)
+		}
+	}))
+	defer srv.Close()
+
+	client := &GoogleAgentClient{apiKey: "key", url: srv.URL + "/v1beta", ctx: context.Background()}
+	file, err := client.UploadFile(0, data, "audio/ogg", "voice.ogg")
+	if err != nil {
+		t.Fatalf("UploadFile: %v", err)
+	}
+	if file.Name != "files/abc" || file.URI == "" {
+		t.Errorf("file = %+v", file)
+	}
+	if !failed {
+		t.Error("сбой части не был смоделирован")
+	}
+	if !bytes.Equal(received, data) {
+		t.Errorf("сервер получил %d байт из %d", len(received), len(data))
+	}
+}
+
+func TestDeleteFilesOlderThan(t *testing.T) {
+	old := time.Now().Add(-3 * time.Hour).UTC().Format(time.RFC3339)
+	fresh := time.Now().Add(-time.Minute).UTC().Format(time.RFC3339)
+
+	var mu sync.Mutex
+	var deleted []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case r.Method == http.MethodGet && r.URL.Query().Get("pageToken") == "":
+			_, _ = w.Write([]byte(`{"files":[{"name":"files/old1","createTime":"` + old + `"},{"name":"files/new","createTime":"` + fresh + `"}],"nextPageToken":"p2"}`))
+		case r.Method == http.MethodGet:
+			_, _ = w.Write([]byte(`{"files":[{"name":"files/old2","createTime":"` + old + `"}]}`))
+		case r.Method == http.MethodDelete:
+			deleted = append(deleted, r.URL.Path)
+			w.WriteHeader(http.StatusOK)
+		}
+	}))
+	defer srv.Close()
+
+	client := &GoogleAgentClient{apiKey: "key", url: srv.URL + "/v1beta", ctx: context.Background()}
+	n, err := client.DeleteFilesOlderThan(0, time.Hour)
+	if err != nil {
+		t.Fatalf("DeleteFilesOlderThan: %v", err)
+	}
+	if n != 2 || len(deleted) != 2 || deleted[0] != "/v1beta/files/old1" || deleted[1] != "/v1beta/files/old2" {
+		t.Errorf("удалено %d: %v", n, deleted)
+	}
+}
+
+func TestCleanupFiles_KeepsFilesInUse(t *testing.T) {
+	old := time.Now().Add(-3 * time.Hour).UTC().Format(time.RFC3339)
+
+	var mu sync.Mutex
+	var deleted []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch r.Method {
+		case http.MethodGet:
+			_, _ = w.Write([]byte(`{"files":[{"name":"files/live","uri":"https://g/files/live","createTime":"` + old + `"},` +
+				`{"name":"files/gone","uri":"https://g/files/gone","createTime":"` + old + `"}]}`))
+		case http.MethodDelete:
+			deleted = append(deleted, r.URL.Path)
+		}
+	}))
+	defer srv.Close()
+
+	client := &GoogleAgentClient{apiKey: "key", url: srv.URL + "/v1beta", ctx: context.Background()}
+	client.SetFilesInUse(func() map[string]bool { return map[string]bool{"https://g/files/live": true} })
+	client.cleanupFiles(time.Hour)
+
+	if len(deleted) != 1 || deleted[0] != "/v1beta/files/gone" {
+		t.Fatalf("deleted: %v", deleted)
+	}
+}
@@ -0,0 +1,70 @@
+package create
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCreateGoogleAgent_SafetySettings(t *testing.T) {
+	var payload map[string]json.RawMessage
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1beta/models/gemini-2.5-flash:generateContent" {
+			t.Errorf("путь %s", r.URL.Path)
+		}
+		payload = nil
+		_ = json.NewDecoder(r.Body).Decode(&payload)
+		_, _ = w.Write([]byte(`{"candidates":[]}`))
+	}))
+	defer srv.Close()
+	client := &GoogleAgentClient{apiKey: "key", url: srv.URL + "/v1beta", ctx: context.Background()}
+
+	data := &UniversalModelData{
+		Prompt:   "Ты консультант клиники",
+		Provider: ProviderGoogle,
+		GptType:  &GptType{Name: "gemini-2.5-flash"},
+		SafetySettings: []GoogleSafetySetting{
+			{Category: "HARM_CATEGORY_SEXUALLY_EXPLICIT", Threshold: "BLOCK_ONLY_HIGH"},
+			{Category: "HARM_CATEGORY_DANGEROUS_CONTENT", Threshold: "BLOCK_NONE"},
+		},
+	}
+	if _, err := client.createGoogleAgent(data, 1, nil); err != nil {
+		t.Fatal(err)
+	}
+	var settings []GoogleSafetySetting
+	if err := json.Unmarshal(payload["safetySettings"], &settings); err != nil {
+		t.Fatalf("safetySettings: %s", payload["safetySettings"])
+	}
+	if len(settings) != 2 || settings[0] != data.SafetySettings[0] || settings[1] != data.SafetySettings[1] {
+		t.Fatalf("safetySettings: %+v", settings)
+	}
+
+	// Без настроек действуют пороги Google по умолчанию
+	data.SafetySettings = nil
+	if _, err := client.createGoogleAgent(data, 1, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := payload["safetySettings"]; ok {
+		t.Fatalf("лишние safetySettings: %s", payload["safetySettings"])
+	}
+}
+
+func TestUniversalModelData_SafetySettingsJSON(t *testing.T) {
+	var data UniversalModelData
+	raw := `{"safety_settings":[{"category":"HARM_CATEGORY_HARASSMENT","threshold":"BLOCK_LOW_AND_ABOVE"}]}`
+	if err := json.Unmarshal([]byte(raw), &data); err != nil {
+		t.Fatal(err)
+	}
+	if len(data.SafetySettings) != 1 || data.SafetySettings[0].Threshold != "BLOCK_LOW_AND_ABOVE" {
+		t.Fatalf("SafetySettings: %+v", data.SafetySettings)
+	}
+
+	out, _ := json.Marshal(UniversalModelData{})
+	var fields map[string]any
+	_ = json.Unmarshal(out, &fields)
+	if _, ok := fields["safety_settings"]; ok {
+		t.Fatal("пустые safety_settings сериализуются")
+	}
+}
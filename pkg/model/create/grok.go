@@ -0,0 +1,63 @@
+package create
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ikermy/AiR_Common/pkg/mode"
+)
+
+// NewGrokClient создаёт клиент xAI Grok (OpenAI-совместимый API); ключ пользователя
+// читается через keyResolver (db.GetUserAPIKey(userID, ProviderGrok))
+func NewGrokClient(keyResolver func(userID uint32) string) *ChatCompletionsClient {
+	c := NewChatCompletionsClient("Grok", mode.GrokBaseURL)
+	c.SetKeyResolver(keyResolver)
+	return c
+}
+
+// GrokSearchParameters параметры Live Search для флага WebSearch: модель сама решает,
+// нужен ли поиск, источники ответа возвращаются в citations
+func GrokSearchParameters(modelData *UniversalModelData) map[string]any {
+	if modelData == nil || !modelData.WebSearch {
+		return nil
+	}
+	return map[string]any{
+		"search_parameters": map[string]any{
+			"mode":             "auto",
+			"return_citations": true,
+		},
+	}
+}
+
+// grokProvider — агент на стороне xAI не создаётся: AssistId хранит имя модели ("grok-4"),
+// остальное передаётся с каждым запросом (pkg/model/grok). WebSearch — Live Search xAI
+type grokProvider struct{ m *UniversalModel }
+
+func (p grokProvider) Provider() ProviderType { return ProviderGrok }
+
+func (p grokProvider) Capabilities() Capabilities {
+	return Capabilities{WebSearch: true}
+}
+
+func (p grokProvider) CreateAgent(_ uint32, modelData *UniversalModelData, _ []Ids) (UMCR, error) {
+	return UMCR{AssistID: modelData.GptType.Name, Provider: ProviderGrok}, nil
+}
+
+func (p grokProvider) UpdateAgent(userID uint32, _, updated *UniversalModelData) error {
+	return p.m.updateNamedAgent(userID, ProviderGrok, updated)
+}
+
+func (p grokProvider) DeleteAgent(_ uint32, _ *UserModelRecord, _ bool, progressCallback func(string)) error {
+	if progressCallback != nil {
+		progressCallback("✅ Модель Grok успешно удалена")
+	}
+	return nil
+}
+
+func (p grokProvider) Request(ctx context.Context, userID uint32, req ProviderRequest) (ProviderResponse, error) {
+	return chatProviderRequest(ctx, p.m.grokClient, userID, req)
+}
+
+func (p grokProvider) Transcribe(context.Context, uint32, []byte, string) (string, error) {
+	return "", fmt.Errorf("Grok не поддерживает транскрипцию аудио")
+}
@@ -10,60 +10,9 @@ import (
 	"slices"
 
 	"github.com/ikermy/AiR_Common/pkg/mode"
+	"github.com/ikermy/AiR_Common/pkg/providererr"
 )
 
-// MistralSchemaJSON - JSON Schema для структурированных ответов Mistral Agent
-const MistralSchemaJSON = `{
-	"type": "object",
-	"properties": {
-		"message": {
-			"type": "string",
-			"description": "Текстовое сообщение для пользователя"
-		},
-		"action": {
-			"type": "object",
-			"properties": {
-				"send_files": {
-					"type": "array",
-					"items": {
-						"type": "object",
-						"properties": {
-							"type": {
-								"type": "string",
-								"enum": ["photo", "video", "audio", "doc"],
-								"description": "Тип файла"
-							},
-							"Url": {
-								"type": "string",
-								"description": "URL файла"
-							},
-							"file_name": {
-								"type": "string",
-								"description": "Имя файла"
-							},
-							"caption": {
-								"type": "string",
-								"description": "Подпись к файлу"
-							}
-						},
-						"required": ["type", "Url", "file_name", "caption"]
-					}
-				}
-			},
-			"required": ["send_files"]
-		},
-		"target": {
-			"type": "boolean",
-			"description": "Достигнута ли цель диалога"
-		},
-		"operator": {
-			"type": "boolean",
-			"description": "Требуется ли подключение оператора"
-		}
-	},
-	"required": ["message", "action", "target", "operator"]
-}`
-
 // MistralLibrary представляет библиотеку документов Mistral
 type MistralLibrary struct {
 	ID          string `json:"id"`
@@ -334,7 +283,7 @@ func (m *MistralAgentClient) createMistralAgent(modelData *UniversalModelData, u
 	}
 
 	enhancedPrompt += "IMPORTANT: Your response MUST be valid JSON (you may wrap in ```json):\n" +
-		MistralSchemaJSON + "\n\n" +
+		modelData.ResponseSchema().PromptText() + "\n\n" +
 		"Always return response strictly in this JSON format. You may use markdown: ```json\\n{...}\\n```"
 
 	payload := map[string]any{
@@ -391,6 +340,9 @@ func (m *MistralAgentClient) createMistralAgent(modelData *UniversalModelData, u
 	if len(tools) > 0 {
 		payload["tools"] = tools
 	}
+	if args := modelData.Generation.Mistral(); args != nil {
+		payload["completion_args"] = args
+	}
 
 	body, err := json.Marshal(payload)
 	if err != nil {
@@ -417,7 +369,7 @@ func (m *MistralAgentClient) createMistralAgent(modelData *UniversalModelData, u
 	}
 
 	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
-		return UMCR{}, fmt.Errorf("API вернул статус %d: %s", resp.StatusCode, string(responseBody))
+		return UMCR{}, providererr.New("Mistral", resp.StatusCode, resp.Header, responseBody)
 	}
 
 	var response map[string]any
@@ -506,7 +458,7 @@ func (m *MistralAgentClient) executeMistralRequest(method, url string, body []by
 	}
 
 	if !isSuccess {
-		return nil, fmt.Errorf("API вернул статус %d: %s", resp.StatusCode, string(responseBody))
+		return nil, providererr.New("Mistral", resp.StatusCode, resp.Header, responseBody)
 	}
 
 	return responseBody, nil
@@ -558,3 +510,68 @@ func (m *MistralAgentClient) DeleteDocumentFromLibrary(libraryID, documentID str
 
 	return m.executeMistralDeleteRequest(url)
 }
+
+// ============================================================================
+// EMBEDDING API - Генерация эмбеддингов mistral-embed
+// Документация: https://docs.mistral.ai/capabilities/embeddings/
+// ============================================================================
+
+// MistralEmbeddingModel модель эмбеддингов Mistral (1024 dimensions)
+const MistralEmbeddingModel = "mistral-embed"
+
+// GenerateMistralEmbedding - публичная функция для генерации эмбеддингов через Mistral API.
+// Позволяет Mistral-пользователям наполнять общее векторное хранилище без ключа Google
+func GenerateMistralEmbedding(ctx context.Context, apiKey, text string) ([]float32, error) {
+	if text == "" {
+		return nil, fmt.Errorf("текст не может быть пустым")
+	}
+
+	payload := map[string]any{
+		"model": MistralEmbeddingModel,
+		"input": []string{text},
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка сериализации запроса: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, mode.MistralBaseURL+"/embeddings", bytes.NewBuffer(body))
+	if err != nil {
+		return nil, fmt.Errorf("ошибка создания запроса: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+apiKey)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка HTTP запроса: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	responseBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения ответа: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, providererr.New("Mistral", resp.StatusCode, resp.Header, responseBody)
+	}
+
+	var embedResp struct {
+		Data []struct {
+			Embedding []float32 `json:"embedding"`
+		} `json:"data"`
+	}
+
+	if err := json.Unmarshal(responseBody, &embedResp); err != nil {
+		return nil, fmt.Errorf("ошибка парсинга ответа: %w", err)
+	}
+
+	if len(embedResp.Data) == 0 || len(embedResp.Data[0].Embedding) == 0 {
+		return nil, fmt.Errorf("API вернул пустой эмбеддинг")
+	}
+
+	return embedResp.Data[0].Embedding, nil
+}
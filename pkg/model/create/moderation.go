@@ -0,0 +1,104 @@
+package create
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+)
+
+// ============================================================================
+// MODERATION - Проверка текста встроенными средствами провайдеров.
+// Возвращают список нарушенных категорий; пустой список — текст допустим
+// ============================================================================
+
+// OpenAIModerationModel модель OpenAI moderations API
+const OpenAIModerationModel = "omni-moderation-latest"
+
+// GoogleSafetyModel модель, через которую проверяется текст фильтрами безопасности Gemini
+const GoogleSafetyModel = "gemini-2.5-flash-lite"
+
+// Moderate проверяет текст через OpenAI moderations API
+// Документация: https://platform.openai.com/docs/guides/moderation
+func (c *OpenAIAgentClient) Moderate(ctx context.Context, userID uint32, text string) ([]string, error) {
+	resp, err := c.doRequest(ctx, http.MethodPost, "/moderations", map[string]any{
+		"model": OpenAIModerationModel,
+		"input": text,
+	}, userID)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка запроса модерации: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	var result struct {
+		Results []struct {
+			Categories map[string]bool `json:"categories"`
+		} `json:"results"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("ошибка парсинга ответа модерации: %w", err)
+	}
+
+	var categories []string
+	for _, r := range result.Results {
+		for category, flagged := range r.Categories {
+			if flagged {
+				categories = append(categories, category)
+			}
+		}
+	}
+	sort.Strings(categories)
+	return categories, nil
+}
+
+// SafetyCheck проверяет текст фильтрами безопасности Gemini: у Gemini нет отдельного
+// moderations API, поэтому текст отправляется в generateContent с самыми строгими
+// safetySettings и разбираются оценки безопасности промпта
+func (m *GoogleAgentClient) SafetyCheck(userID uint32, text string) ([]string, error) {
+	settings := make([]GoogleSafetySetting, 0, len(GoogleHarmCategories))
+	for _, category := range GoogleHarmCategories {
+		settings = append(settings, GoogleSafetySetting{Category: category, Threshold: "BLOCK_LOW_AND_ABOVE"})
+	}
+	payload := map[string]any{
+		"contents": []map[string]any{
+			{"role": "user", "parts": []map[string]any{{"text": text}}},
+		},
+		"safetySettings":   settings,
+		"generationConfig": map[string]any{"maxOutputTokens": 1},
+	}
+
+	url := fmt.Sprintf("%s/%s:generateContent?key=%s", m.url, GoogleModelPath(GoogleSafetyModel), m.resolveKey(userID))
+	responseBody, err := executeGoogleAPIRequest(m.ctx, url, payload)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка проверки безопасности: %w", err)
+	}
+
+	type safetyRating struct {
+		Category    string `json:"category"`
+		Probability string `json:"probability"`
+		Blocked     bool   `json:"blocked"`
+	}
+	var result struct {
+		PromptFeedback struct {
+			BlockReason   string         `json:"blockReason"`
+			SafetyRatings []safetyRating `json:"safetyRatings"`
+		} `json:"promptFeedback"`
+	}
+	if err := json.Unmarshal(responseBody, &result); err != nil {
+		return nil, fmt.Errorf("ошибка парсинга ответа Gemini: %w", err)
+	}
+
+	var categories []string
+	for _, r := range result.PromptFeedback.SafetyRatings {
+		if r.Blocked || r.Probability == "MEDIUM" || r.Probability == "HIGH" {
+			categories = append(categories, r.Category)
+		}
+	}
+	// Промпт заблокирован не по категории вреда (BLOCKLIST, PROHIBITED_CONTENT и т.п.)
+	if len(categories) == 0 && result.PromptFeedback.BlockReason != "" {
+		categories = append(categories, result.PromptFeedback.BlockReason)
+	}
+	sort.Strings(categories)
+	return categories, nil
+}
@@ -0,0 +1,375 @@
+package create
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ikermy/AiR_Common/pkg/mode"
+	"github.com/ikermy/AiR_Common/pkg/providererr"
+)
+
+// OllamaClient клиент собственного HTTP API Ollama (/api/chat, /api/embed, /api/tags).
+// Запросы и ответы приводятся к формату Chat Completions, поэтому клиент подходит
+// для chatapi.Model. API-ключ не используется.
+type OllamaClient struct {
+	url        string
+	httpClient *http.Client
+}
+
+// NewOllamaClient создаёт клиент сервера Ollama по адресу baseURL (mode.OllamaBaseURL)
+func NewOllamaClient(baseURL string) *OllamaClient {
+	return &OllamaClient{
+		url: strings.TrimRight(baseURL, "/"),
+		// Локальная модель на CPU может отвечать минутами
+		httpClient: &http.Client{Timeout: 5 * time.Minute},
+	}
+}
+
+// ollamaMessage сообщение /api/chat
+type ollamaMessage struct {
+	Role      string           `json:"role"`
+	Content   string           `json:"content"`
+	Images    []string         `json:"images,omitempty"` // base64 без префикса data:
+	ToolCalls []ollamaToolCall `json:"tool_calls,omitempty"`
+	ToolName  string           `json:"tool_name,omitempty"`
+}
+
+// ollamaToolCall вызов функции; аргументы — JSON-объект, а не строка
+type ollamaToolCall struct {
+	Function struct {
+		Name      string          `json:"name"`
+		Arguments json.RawMessage `json:"arguments"`
+	} `json:"function"`
+}
+
+type ollamaChatRequest struct {
+	Model    string          `json:"model"`
+	Messages []ollamaMessage `json:"messages"`
+	Tools    []ChatTool      `json:"tools,omitempty"`
+	Format   any             `json:"format,omitempty"` // "json" или JSON Schema
+	Stream   bool            `json:"stream"`
+	Options  map[string]any  `json:"options,omitempty"`
+}
+
+type ollamaChatResponse struct {
+	Message         ollamaMessage `json:"message"`
+	DoneReason      string        `json:"done_reason"`
+	PromptEvalCount int           `json:"prompt_eval_count"`
+	EvalCount       int           `json:"eval_count"`
+	Error           string        `json:"error"`
+}
+
+// do выполняет запрос к Ollama и возвращает тело ответа
+func (c *OllamaClient) do(ctx context.Context, method, path string, body any) ([]byte, error) {
+	var reqBody io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка сериализации запроса: %w", err)
+		}
+		reqBody = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.url+path, reqBody)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка создания HTTP запроса: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка HTTP запроса к Ollama: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения ответа Ollama: %w", err)
+	}
+	if resp.StatusCode >= 400 {
+		return nil, providererr.New("Ollama", resp.StatusCode, resp.Header, respBody)
+	}
+	return respBody, nil
+}
+
+// Complete выполняет один запрос /api/chat без потоковой выдачи
+func (c *OllamaClient) Complete(ctx context.Context, _ uint32, req ChatRequest) (ChatResponse, error) {
+	messages, err := c.convertMessages(ctx, req.Messages)
+	if err != nil {
+		return ChatResponse{}, err
+	}
+	body := ollamaChatRequest{
+		Model:    req.Model,
+		Messages: messages,
+		Tools:    req.Tools,
+		Format:   ollamaFormat(req.ResponseFormat),
+	}
+	if req.Temperature != nil || req.TopP != nil || req.TopK != nil || req.MaxTokens > 0 || len(req.Stop) > 0 || req.Seed != nil {
+		body.Options = make(map[string]any)
+		if req.Temperature != nil {
+			body.Options["temperature"] = *req.Temperature
+		}
+		if req.TopP != nil {
+			body.Options["top_p"] = *req.TopP
+		}
+		if req.TopK != nil {
+			body.Options["top_k"] = *req.TopK
+		}
+		if len(req.Stop) > 0 {
+			body.Options["stop"] = req.Stop
+		}
+		if req.Seed != nil {
+			body.Options["seed"] = *req.Seed
+		}
+		if req.MaxTokens > 0 {
+			body.Options["num_predict"] = req.MaxTokens
+		}
+	}
+
+	data, err := c.do(ctx, http.MethodPost, "/api/chat", body)
+	if err != nil {
+		return ChatResponse{}, err
+	}
+	var out ollamaChatResponse
+	if err := json.Unmarshal(data, &out); err != nil {
+		return ChatResponse{}, fmt.Errorf("ошибка парсинга ответа Ollama: %w", err)
+	}
+	if out.Error != "" {
+		return ChatResponse{}, fmt.Errorf("Ollama: %s", out.Error)
+	}
+
+	message := ChatMessage{Role: "assistant", Content: out.Message.Content}
+	for i, call := range out.Message.ToolCalls {
+		var tc ChatToolCall
+		// Ollama не присваивает вызовам идентификаторы — результат сопоставляется по имени функции
+		tc.ID = fmt.Sprintf("call_%d", i)
+		tc.Type = "function"
+		tc.Function.Name = call.Function.Name
+		tc.Function.Arguments = string(call.Function.Arguments)
+		message.ToolCalls = append(message.ToolCalls, tc)
+	}
+	finish := out.DoneReason
+	if len(message.ToolCalls) > 0 {
+		finish = "tool_calls"
+	}
+	return ChatResponse{
+		Message:      message,
+		FinishReason: finish,
+		Usage: ChatUsage{
+			PromptTokens:     out.PromptEvalCount,
+			CompletionTokens: out.EvalCount,
+			TotalTokens:      out.PromptEvalCount + out.EvalCount,
+		},
+	}, nil
+}
+
+// convertMessages переводит историю диалога Chat Completions в формат /api/chat:
+// content parts склеиваются в текст, изображения по URL скачиваются в base64,
+// аргументы вызовов функций передаются объектом, результат функции — через tool_name
+func (c *OllamaClient) convertMessages(ctx context.Context, messages []ChatMessage) ([]ollamaMessage, error) {
+	result := make([]ollamaMessage, 0, len(messages))
+	for _, msg := range messages {
+		om := ollamaMessage{Role: msg.Role}
+		if msg.Role == "tool" {
+			om.ToolName = msg.Name
+		}
+		switch content := msg.Content.(type) {
+		case string:
+			om.Content = content
+		case []any:
+			var texts []string
+			for _, part := range content {
+				p, ok := part.(map[string]any)
+				if !ok {
+					continue
+				}
+				switch p["type"] {
+				case "text":
+					if s, ok := p["text"].(string); ok {
+						texts = append(texts, s)
+					}
+				case "image_url":
+					imageURL, _ := p["image_url"].(map[string]any)
+					u, _ := imageURL["url"].(string)
+					image, err := c.loadImage(ctx, u)
+					if err != nil {
+						return nil, err
+					}
+					om.Images = append(om.Images, image)
+				}
+			}
+			om.Content = strings.Join(texts, "\n")
+		}
+		for _, call := range msg.ToolCalls {
+			var tc ollamaToolCall
+			tc.Function.Name = call.Function.Name
+			tc.Function.Arguments = json.RawMessage(call.Function.Arguments)
+			if !json.Valid(tc.Function.Arguments) {
+				tc.Function.Arguments = json.RawMessage("{}")
+			}
+			om.ToolCalls = append(om.ToolCalls, tc)
+		}
+		result = append(result, om)
+	}
+	return result, nil
+}
+
+// loadImage возвращает изображение в base64: data URL раскодируется на месте,
+// остальные адреса скачиваются (обычно внутренний S3)
+func (c *OllamaClient) loadImage(ctx context.Context, u string) (string, error) {
+	if strings.HasPrefix(u, "data:") {
+		if idx := strings.Index(u, ";base64,"); idx >= 0 {
+			return u[idx+len(";base64,"):], nil
+		}
+		return "", fmt.Errorf("неподдерживаемый data URL изображения")
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return "", fmt.Errorf("ошибка создания запроса изображения: %w", err)
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("ошибка загрузки изображения: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("ошибка загрузки изображения: статус %d", resp.StatusCode)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("ошибка чтения изображения: %w", err)
+	}
+	return base64.StdEncoding.EncodeToString(data), nil
+}
+
+// ollamaFormat переводит response_format в параметр format: схема json_schema
+// передаётся как есть, json_object — как "json"
+func ollamaFormat(responseFormat map[string]any) any {
+	switch responseFormat["type"] {
+	case "json_schema":
+		if js, ok := responseFormat["json_schema"].(map[string]any); ok {
+			if schema, ok := js["schema"]; ok {
+				return schema
+			}
+		}
+		return "json"
+	case "json_object":
+		return "json"
+	}
+	return nil
+}
+
+// ListModels список установленных моделей (GET /api/tags). Поддержка функций берётся из
+// capabilities /api/show; format (структурированный ответ) Ollama поддерживает для всех моделей.
+func (c *OllamaClient) ListModels(ctx context.Context, _ uint32) ([]ChatModelInfo, error) {
+	data, err := c.do(ctx, http.MethodGet, "/api/tags", nil)
+	if err != nil {
+		return nil, err
+	}
+	var out struct {
+		Models []struct {
+			Name string `json:"name"`
+		} `json:"models"`
+	}
+	if err := json.Unmarshal(data, &out); err != nil {
+		return nil, fmt.Errorf("ошибка парсинга списка моделей Ollama: %w", err)
+	}
+
+	result := make([]ChatModelInfo, 0, len(out.Models))
+	for _, item := range out.Models {
+		info := ChatModelInfo{ID: item.Name, Name: item.Name}
+		if caps, err := c.modelCapabilities(ctx, item.Name); err == nil {
+			info.SupportedParameters = []string{"response_format"}
+			for _, capability := range caps {
+				if capability == "tools" {
+					info.SupportedParameters = append(info.SupportedParameters, "tools")
+				}
+			}
+		}
+		result = append(result, info)
+	}
+	return result, nil
+}
+
+// modelCapabilities возможности модели из /api/show ("completion", "tools", "vision", ...)
+func (c *OllamaClient) modelCapabilities(ctx context.Context, name string) ([]string, error) {
+	data, err := c.do(ctx, http.MethodPost, "/api/show", map[string]string{"model": name})
+	if err != nil {
+		return nil, err
+	}
+	var out struct {
+		Capabilities []string `json:"capabilities"`
+	}
+	if err := json.Unmarshal(data, &out); err != nil {
+		return nil, fmt.Errorf("ошибка парсинга описания модели Ollama: %w", err)
+	}
+	return out.Capabilities, nil
+}
+
+// Embed возвращает эмбеддинги текстов (POST /api/embed); пустая модель — mode.OllamaEmbedModel
+func (c *OllamaClient) Embed(ctx context.Context, modelName string, input []string) ([][]float32, error) {
+	if len(input) == 0 {
+		return nil, nil
+	}
+	if modelName == "" {
+		modelName = mode.OllamaEmbedModel
+	}
+	data, err := c.do(ctx, http.MethodPost, "/api/embed", map[string]any{"model": modelName, "input": input})
+	if err != nil {
+		return nil, err
+	}
+	var out struct {
+		Embeddings [][]float32 `json:"embeddings"`
+	}
+	if err := json.Unmarshal(data, &out); err != nil {
+		return nil, fmt.Errorf("ошибка парсинга эмбеддингов Ollama: %w", err)
+	}
+	if len(out.Embeddings) != len(input) {
+		return nil, fmt.Errorf("Ollama вернул %d эмбеддингов вместо %d", len(out.Embeddings), len(input))
+	}
+	return out.Embeddings, nil
+}
+
+// OllamaModels список моделей, установленных на сервере Ollama
+func (m *UniversalModel) OllamaModels(ctx context.Context) ([]ChatModelInfo, error) {
+	return m.ollamaClient.ListModels(ctx, 0)
+}
+
+// ollamaProvider — как и OpenRouter, агент на стороне Ollama не создаётся: AssistId хранит
+// имя локальной модели ("llama3.1:8b", "qwen2.5:14b"). Веб-поиска, файлов и vector store
+// у локальной установки нет (pkg/model/ollama)
+type ollamaProvider struct{ m *UniversalModel }
+
+func (p ollamaProvider) Provider() ProviderType { return ProviderOllama }
+
+func (p ollamaProvider) Capabilities() Capabilities {
+	return Capabilities{}
+}
+
+func (p ollamaProvider) CreateAgent(_ uint32, modelData *UniversalModelData, _ []Ids) (UMCR, error) {
+	return UMCR{AssistID: modelData.GptType.Name, Provider: ProviderOllama}, nil
+}
+
+func (p ollamaProvider) UpdateAgent(userID uint32, _, updated *UniversalModelData) error {
+	return p.m.updateNamedAgent(userID, ProviderOllama, updated)
+}
+
+func (p ollamaProvider) DeleteAgent(_ uint32, _ *UserModelRecord, _ bool, progressCallback func(string)) error {
+	if progressCallback != nil {
+		progressCallback("✅ Модель Ollama успешно удалена")
+	}
+	return nil
+}
+
+func (p ollamaProvider) Request(ctx context.Context, userID uint32, req ProviderRequest) (ProviderResponse, error) {
+	return chatProviderRequest(ctx, p.m.ollamaClient, userID, req)
+}
+
+func (p ollamaProvider) Transcribe(context.Context, uint32, []byte, string) (string, error) {
+	return "", fmt.Errorf("Ollama не поддерживает транскрипцию аудио")
+}
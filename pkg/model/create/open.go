@@ -12,6 +12,8 @@ import (
 	"reflect"
 	"strings"
 	"time"
+
+	"github.com/ikermy/AiR_Common/pkg/providererr"
 )
 
 // ============================================================================
@@ -74,7 +76,7 @@ func generateOpenAIEmbedding(ctx context.Context, apiKey, text, model string, di
 
 	if resp.StatusCode != http.StatusOK {
 		//logger.Error("generateOpenAIEmbedding: API вернул %d: %s", resp.StatusCode, string(responseBody))
-		return nil, fmt.Errorf("API вернул %d: %s", resp.StatusCode, string(responseBody))
+		return nil, providererr.New("OpenAI", resp.StatusCode, resp.Header, responseBody)
 	}
 
 	var embedResp struct {
@@ -216,7 +218,7 @@ func (c *OpenAIAgentClient) doRequest(ctx context.Context, method, path string,
 	if resp.StatusCode >= 400 {
 		bodyBytes, _ := io.ReadAll(resp.Body)
 		func() { _ = resp.Body.Close() }()
-		return nil, fmt.Errorf("OpenAI API error: HTTP %d: %s", resp.StatusCode, string(bodyBytes))
+		return nil, providererr.New("OpenAI", resp.StatusCode, resp.Header, bodyBytes)
 	}
 
 	return resp, nil
@@ -284,7 +286,7 @@ func (c *OpenAIAgentClient) TranscribeAudio(ctx context.Context, audioData []byt
 
 	if resp.StatusCode >= 400 {
 		bodyBytes, _ := io.ReadAll(resp.Body)
-		return "", fmt.Errorf("OpenAI API error: HTTP %d: %s", resp.StatusCode, string(bodyBytes))
+		return "", providererr.New("OpenAI", resp.StatusCode, resp.Header, bodyBytes)
 	}
 
 	var result struct {
@@ -468,6 +470,19 @@ func (c *OpenAIAgentClient) createResponseInternal(
 		}
 	}
 
+	// Параметры генерации ассистента (AgentConfig.Generation)
+	if raw, ok := configMap["generation"].(map[string]any); ok {
+		var generation GenerationParams
+		if data, err := json.Marshal(raw); err == nil && json.Unmarshal(data, &generation) == nil {
+			generation.ApplyResponses(payload, modelName)
+		}
+	}
+
+	// Глубина рассуждений: параметр принимают только reasoning-модели
+	if effort, ok := configMap["reasoning"].(string); ok && effort != "" && OpenAISupportsReasoning(modelName) {
+		payload["reasoning"] = map[string]any{"effort": effort}
+	}
+
 	// ✅ ТЕСТИРОВАНИЕ: Priority Processing для Responses API
 	// Доступные значения service_tier:
 	// - "default" (стандартная обработка, гибкое ценообразование)
@@ -850,84 +865,6 @@ func (c *OpenAIAgentClient) createResponseInternal(
 	return result, fullText, nil
 }
 
-// GenerateModelSchema генерирует JSON Schema с учётом параметров модели
-func GenerateModelSchema(hasMetaAction bool, hasOperator bool) map[string]any {
-	// Формируем список required полей
-	requiredFields := []string{"message", "action", "target"}
-
-	// operator добавляем в required только если он включен
-	if hasOperator {
-		requiredFields = append(requiredFields, "operator")
-	}
-
-	schema := map[string]any{
-		"type": "object",
-		"properties": map[string]any{
-			"message": map[string]any{
-				"type": "string",
-			},
-			"action": map[string]any{
-				"type": "object",
-				"properties": map[string]any{
-					"send_files": map[string]any{
-						"type": "array",
-						"items": map[string]any{
-							"type": "object",
-							"properties": map[string]any{
-								"type": map[string]any{
-									"type": "string",
-									"enum": []string{"photo", "video", "audio", "doc"},
-								},
-								"Url": map[string]any{
-									"type": "string",
-								},
-								"file_name": map[string]any{
-									"type": "string",
-								},
-								"caption": map[string]any{
-									"type": "string",
-								},
-							},
-							"required":             []string{"type", "Url", "file_name", "caption"},
-							"additionalProperties": false,
-						},
-					},
-				},
-				"required":             []string{"send_files"},
-				"additionalProperties": false,
-			},
-		},
-		"required":             requiredFields,
-		"additionalProperties": false,
-	}
-
-	// Настраиваем поле target
-	if hasMetaAction {
-		// Если есть MetaAction - target может быть true или false
-		schema["properties"].(map[string]any)["target"] = map[string]any{
-			"type": "boolean",
-		}
-	} else {
-		// Если нет MetaAction - target ВСЕГДА false
-		schema["properties"].(map[string]any)["target"] = map[string]any{
-			"type": "boolean",
-			"enum": []any{false},
-		}
-	}
-
-	// Настраиваем поле operator ТОЛЬКО если оно включено
-	if hasOperator {
-		// Если Operator включен - operator может быть true или false
-		schema["properties"].(map[string]any)["operator"] = map[string]any{
-			"type": "boolean",
-		}
-	}
-	// Если operator выключен - НЕ добавляем его в schema вообще!
-	// Значение operator: false будет добавлено на стороне кода при парсинге ответа
-
-	return schema
-}
-
 // createModel Создаю новую модель OpenAI Assistant
 func (m *UniversalModel) createModel(_ uint32, modelData *UniversalModelData, _ []Ids) (UMCR, error) {
 	// modelData уже распарсена и типизирована, используем напрямую
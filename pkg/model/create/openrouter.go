@@ -0,0 +1,57 @@
+package create
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ikermy/AiR_Common/pkg/mode"
+)
+
+// NewOpenRouterClient создаёт клиент OpenRouter; ключ пользователя читается через keyResolver
+// (db.GetUserAPIKey(userID, ProviderOpenRouter))
+func NewOpenRouterClient(keyResolver func(userID uint32) string) *ChatCompletionsClient {
+	c := NewChatCompletionsClient("OpenRouter", mode.OpenRouterBaseURL)
+	c.SetKeyResolver(keyResolver)
+	c.SetHeader("HTTP-Referer", mode.OpenRouterAppURL)
+	c.SetHeader("X-Title", mode.OpenRouterAppName)
+	return c
+}
+
+// OpenRouterModels список моделей OpenRouter, доступных пользователю
+func (m *UniversalModel) OpenRouterModels(ctx context.Context, userID uint32) ([]ChatModelInfo, error) {
+	return m.openrouterClient.ListModels(ctx, userID)
+}
+
+// openRouterProvider — агент OpenRouter не создаётся на стороне провайдера: AssistId хранит
+// имя модели ("anthropic/claude-sonnet-4", "openai/gpt-4o"), промпт, функции и схема ответа
+// передаются с каждым запросом (pkg/model/openrouter)
+type openRouterProvider struct{ m *UniversalModel }
+
+func (p openRouterProvider) Provider() ProviderType { return ProviderOpenRouter }
+
+func (p openRouterProvider) Capabilities() Capabilities {
+	return Capabilities{}
+}
+
+func (p openRouterProvider) CreateAgent(_ uint32, modelData *UniversalModelData, _ []Ids) (UMCR, error) {
+	return UMCR{AssistID: modelData.GptType.Name, Provider: ProviderOpenRouter}, nil
+}
+
+func (p openRouterProvider) UpdateAgent(userID uint32, _, updated *UniversalModelData) error {
+	return p.m.updateNamedAgent(userID, ProviderOpenRouter, updated)
+}
+
+func (p openRouterProvider) DeleteAgent(_ uint32, _ *UserModelRecord, _ bool, progressCallback func(string)) error {
+	if progressCallback != nil {
+		progressCallback("✅ Модель OpenRouter успешно удалена")
+	}
+	return nil
+}
+
+func (p openRouterProvider) Request(ctx context.Context, userID uint32, req ProviderRequest) (ProviderResponse, error) {
+	return chatProviderRequest(ctx, p.m.openrouterClient, userID, req)
+}
+
+func (p openRouterProvider) Transcribe(context.Context, uint32, []byte, string) (string, error) {
+	return "", fmt.Errorf("OpenRouter не поддерживает транскрипцию аудио")
+}
@@ -0,0 +1,169 @@
+package create
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ikermy/AiR_Common/pkg/logger"
+	"github.com/ikermy/AiR_Common/pkg/safego"
+)
+
+// PromptVersion версия системного промпта модели: Prompt — промпт модели (UniversalModelData.Prompt),
+// Rendered — итоговый текст, отправленный провайдеру (с подсказками MCP и т.п.)
+type PromptVersion struct {
+	ModelID   uint64    `json:"model_id"`
+	Version   uint32    `json:"version"` // с 1 в пределах модели
+	UserID    uint32    `json:"user_id"`
+	Prompt    string    `json:"prompt"`
+	Rendered  string    `json:"rendered"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// PromptTurn версия промпта, обслужившая ход диалога
+type PromptTurn struct {
+	DialogID  uint64    `json:"dialog_id"`
+	ModelID   uint64    `json:"model_id"`
+	Version   uint32    `json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// PromptVersionStore хранилище версий промптов (реализует comdb.DB).
+// Необязательно для DB: модели проверяют его приведением типа.
+type PromptVersionStore interface {
+	// SavePromptVersion сохраняет версию, если Rendered отличается от последней; возвращает её номер
+	SavePromptVersion(userID uint32, modelID uint64, prompt, rendered string) (uint32, error)
+	// GetPromptVersion возвращает версию модели (nil, если нет)
+	GetPromptVersion(modelID uint64, version uint32) (*PromptVersion, error)
+	// RecordPromptTurn отмечает, какой версией промпта обслужен ход диалога
+	RecordPromptTurn(dialogID, modelID uint64, version uint32) error
+}
+
+// SavePromptVersion сохраняет промпт модели как версию, если db реализует PromptVersionStore.
+// Провайдеры вызывают его при загрузке конфигурации модели; 0 — версии не ведутся
+// или сохранить не удалось, ходы тогда не отмечаются
+func SavePromptVersion(db any, userID uint32, modelID uint64, prompt, rendered string) uint32 {
+	store, ok := db.(PromptVersionStore)
+	if !ok || modelID == 0 || rendered == "" {
+		return 0
+	}
+	v, err := store.SavePromptVersion(userID, modelID, prompt, rendered)
+	if err != nil {
+		logger.Warn("ошибка сохранения версии промпта модели %d: %v", modelID, err, userID)
+		return 0
+	}
+	return v
+}
+
+// RecordPromptTurn в фоне отмечает версию промпта, которой обслужен ход диалога.
+// version 0 (версии не ведутся или промпт подменён вариантом эксперимента) — без отметки
+func RecordPromptTurn(db any, userID uint32, dialogID, modelID uint64, version uint32) {
+	store, ok := db.(PromptVersionStore)
+	if !ok || version == 0 {
+		return
+	}
+	safego.Go("create.RecordPromptTurn", func() {
+		if err := store.RecordPromptTurn(dialogID, modelID, version); err != nil {
+			logger.Warn("ошибка отметки версии промпта диалога %d: %v", dialogID, err, userID)
+		}
+	}, safego.WithUserID(userID))
+}
+
+// Операции строки в PromptDiffLine
+const (
+	DiffEqual  = ' '
+	DiffInsert = '+'
+	DiffDelete = '-'
+)
+
+// PromptDiffLine строка построчного сравнения промптов
+type PromptDiffLine struct {
+	Op   byte   `json:"op"` // DiffEqual, DiffInsert, DiffDelete
+	Text string `json:"text"`
+}
+
+// String строка в формате unified diff без заголовков
+func (l PromptDiffLine) String() string {
+	return string(l.Op) + l.Text
+}
+
+// DiffPromptText построчное сравнение промптов a и b (наибольшая общая подпоследовательность)
+func DiffPromptText(a, b string) []PromptDiffLine {
+	al, bl := strings.Split(a, "\n"), strings.Split(b, "\n")
+	// lcs[i][j] — длина НОП al[i:] и bl[j:]
+	lcs := make([][]int, len(al)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(bl)+1)
+	}
+	for i := len(al) - 1; i >= 0; i-- {
+		for j := len(bl) - 1; j >= 0; j-- {
+			if al[i] == bl[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	diff := make([]PromptDiffLine, 0, max(len(al), len(bl)))
+	i, j := 0, 0
+	for i < len(al) && j < len(bl) {
+		switch {
+		case al[i] == bl[j]:
+			diff = append(diff, PromptDiffLine{Op: DiffEqual, Text: al[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			diff = append(diff, PromptDiffLine{Op: DiffDelete, Text: al[i]})
+			i++
+		default:
+			diff = append(diff, PromptDiffLine{Op: DiffInsert, Text: bl[j]})
+			j++
+		}
+	}
+	for ; i < len(al); i++ {
+		diff = append(diff, PromptDiffLine{Op: DiffDelete, Text: al[i]})
+	}
+	for ; j < len(bl); j++ {
+		diff = append(diff, PromptDiffLine{Op: DiffInsert, Text: bl[j]})
+	}
+	return diff
+}
+
+// RollbackPrompt восстанавливает промпт модели провайдера из версии version: обновляет модель
+// (UpdateModelEveryWhere) и сохраняет восстановленный промпт как новую версию, история не переписывается.
+// Итоговый текст с подсказками MCP новая версия получит при следующей загрузке конфигурации модели.
+func (m *UniversalModel) RollbackPrompt(userID uint32, provider ProviderType, version uint32) (uint32, error) {
+	store, ok := m.db.(PromptVersionStore)
+	if !ok {
+		return 0, fmt.Errorf("хранилище не поддерживает версии промптов")
+	}
+	record, err := m.db.GetModelByProviderAnyStatus(userID, provider)
+	if err != nil {
+		return 0, fmt.Errorf("ошибка получения модели: %w", err)
+	}
+	if record == nil {
+		return 0, fmt.Errorf("модель провайдера %s не найдена для пользователя %d", provider, userID)
+	}
+	target, err := store.GetPromptVersion(record.ModelId, version)
+	if err != nil {
+		return 0, err
+	}
+	if target == nil || target.UserID != userID {
+		return 0, fmt.Errorf("версия промпта %d модели %d не найдена", version, record.ModelId)
+	}
+
+	data, err := m.ReadModel(userID, &provider)
+	if err != nil {
+		return 0, fmt.Errorf("ошибка чтения модели: %w", err)
+	}
+	if data == nil {
+		return 0, fmt.Errorf("данные модели провайдера %s не найдены для пользователя %d", provider, userID)
+	}
+	data.Provider = provider
+	data.Prompt = target.Prompt
+	if err := m.UpdateModelEveryWhere(userID, data); err != nil {
+		return 0, fmt.Errorf("ошибка обновления промпта модели: %w", err)
+	}
+	return store.SavePromptVersion(userID, record.ModelId, target.Prompt, target.Rendered)
+}
@@ -0,0 +1,85 @@
+package create
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDiffPromptText(t *testing.T) {
+	cases := []struct {
+		name string
+		a, b string
+		want string // строки PromptDiffLine.String() через "|"
+	}{
+		{"одинаковые", "роль\nтон", "роль\nтон", " роль| тон"},
+		{"вставка в середину", "роль\nтон", "роль\nцены\nтон", " роль|+цены| тон"},
+		{"удаление", "роль\nцены\nтон", "роль\nтон", " роль|-цены| тон"},
+		{"замена строки", "роль\nтон: сухой", "роль\nтон: дружелюбный", " роль|-тон: сухой|+тон: дружелюбный"},
+		{"добавление в конец", "роль", "роль\nподпись", " роль|+подпись"},
+		{"пустой исходный", "", "роль", "-|+роль"},
+	}
+	for _, c := range cases {
+		diff := DiffPromptText(c.a, c.b)
+		lines := make([]string, len(diff))
+		for i, l := range diff {
+			lines[i] = l.String()
+		}
+		if got := strings.Join(lines, "|"); got != c.want {
+			t.Errorf("%s: %q, want %q", c.name, got, c.want)
+		}
+	}
+}
+
+// memPromptStore — PromptVersionStore в памяти
+type memPromptStore struct {
+	saveErr error
+	saved   []string
+	turns   chan PromptTurn
+}
+
+func (s *memPromptStore) SavePromptVersion(_ uint32, _ uint64, _, rendered string) (uint32, error) {
+	if s.saveErr != nil {
+		return 0, s.saveErr
+	}
+	s.saved = append(s.saved, rendered)
+	return uint32(len(s.saved)), nil
+}
+
+func (s *memPromptStore) GetPromptVersion(uint64, uint32) (*PromptVersion, error) { return nil, nil }
+
+func (s *memPromptStore) RecordPromptTurn(dialogID, modelID uint64, version uint32) error {
+	s.turns <- PromptTurn{DialogID: dialogID, ModelID: modelID, Version: version}
+	return nil
+}
+
+func TestSavePromptVersionAndRecordTurn(t *testing.T) {
+	store := &memPromptStore{turns: make(chan PromptTurn, 1)}
+
+	if v := SavePromptVersion(store, 1, 10, "роль", "роль\n\nподсказка"); v != 1 {
+		t.Fatalf("версия %d, want 1", v)
+	}
+	if v := SavePromptVersion(struct{}{}, 1, 10, "роль", "роль"); v != 0 {
+		t.Fatalf("хранилище без версий: %d", v)
+	}
+	if v := SavePromptVersion(&memPromptStore{saveErr: errors.New("нет связи")}, 1, 10, "роль", "роль"); v != 0 {
+		t.Fatalf("ошибка сохранения: %d", v)
+	}
+
+	RecordPromptTurn(store, 1, 5, 10, 0) // вариант эксперимента — без отметки
+	RecordPromptTurn(store, 1, 5, 10, 1)
+	select {
+	case turn := <-store.turns:
+		if turn.DialogID != 5 || turn.ModelID != 10 || turn.Version != 1 {
+			t.Fatalf("отметка хода: %+v", turn)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("ход не отмечен")
+	}
+	select {
+	case turn := <-store.turns:
+		t.Fatalf("лишняя отметка: %+v", turn)
+	default:
+	}
+}
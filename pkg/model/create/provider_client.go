@@ -0,0 +1,441 @@
+package create
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/ikermy/AiR_Common/pkg/mode"
+	"github.com/ikermy/AiR_Common/pkg/providererr"
+)
+
+// Capabilities возможности провайдера; по ним ValidateModelData предупреждает о флагах,
+// которые провайдер не выполнит
+type Capabilities struct {
+	Interpreter  bool `json:"interpreter"`   // исполнение кода
+	Image        bool `json:"image"`         // генерация изображений
+	WebSearch    bool `json:"web_search"`    // веб-поиск
+	Video        bool `json:"video"`         // генерация видео
+	Realtime     bool `json:"realtime"`      // голосовой режим реального времени
+	Transcribe   bool `json:"transcribe"`    // распознавание речи
+	VectorSearch bool `json:"vector_search"` // поиск по загруженным файлам
+}
+
+// ProviderMessage сообщение ProviderRequest
+type ProviderMessage struct {
+	Role string `json:"role"` // "user" или "assistant"
+	Text string `json:"text"`
+}
+
+// ProviderRequest одиночный запрос к модели провайдера без агента и истории диалога
+// (классификация, сводки, служебные вызовы)
+type ProviderRequest struct {
+	Model       string            `json:"model"`
+	System      string            `json:"system,omitempty"`
+	Messages    []ProviderMessage `json:"messages"`
+	Temperature *float64          `json:"temperature,omitempty"`
+	MaxTokens   int               `json:"max_tokens,omitempty"`
+}
+
+// ProviderResponse ответ на ProviderRequest
+type ProviderResponse struct {
+	Text         string `json:"text"`
+	InputTokens  int    `json:"input_tokens,omitempty"`
+	OutputTokens int    `json:"output_tokens,omitempty"`
+}
+
+// ProviderClient провайдер моделей для UniversalModel: жизненный цикл агента, одиночные запросы
+// и транскрипция. Новый провайдер добавляется реализацией ProviderClient и RegisterProviderSpec.
+// Диалоги с историей, RAG и инструментами обслуживает model.Inter провайдера.
+type ProviderClient interface {
+	Provider() ProviderType
+	Capabilities() Capabilities
+	// CreateAgent создаёт агента (или конфигурацию) провайдера для модели пользователя
+	CreateAgent(userID uint32, modelData *UniversalModelData, fileIDs []Ids) (UMCR, error)
+	// UpdateAgent обновляет агента и сохраняет модель в БД
+	UpdateAgent(userID uint32, existing, updated *UniversalModelData) error
+	// DeleteAgent удаляет агента и ресурсы провайдера (запись user_models удаляет UniversalModel)
+	DeleteAgent(userID uint32, record *UserModelRecord, deleteFiles bool, progressCallback func(string)) error
+	Request(ctx context.Context, userID uint32, req ProviderRequest) (ProviderResponse, error)
+	Transcribe(ctx context.Context, userID uint32, audioData []byte, fileName string) (string, error)
+}
+
+// ProviderSpec запись реестра провайдеров. Провайдер регистрируется один раз
+// (RegisterProviderSpec): по реестру UniversalModel создаёт клиентов, ValidateModelData
+// узнаёт возможности, а model.Router — порядок и имена провайдеров
+type ProviderSpec struct {
+	Name string // имя для пользователя и логов: "OpenAI", "YandexGPT"
+	// New клиент провайдера для m; при m == nil используются только Provider и Capabilities
+	New func(m *UniversalModel) ProviderClient
+}
+
+var (
+	specsMu sync.RWMutex
+	specs   []ProviderSpec // в порядке регистрации
+	// capabilities возможности зарегистрированных провайдеров: ProviderType -> Capabilities
+	capabilities sync.Map
+)
+
+// Встроенные провайдеры
+func init() {
+	for _, spec := range []ProviderSpec{
+		{Name: "OpenAI", New: func(m *UniversalModel) ProviderClient { return openAIProvider{m: m} }},
+		{Name: "Mistral", New: func(m *UniversalModel) ProviderClient { return mistralProvider{m: m} }},
+		{Name: "Google", New: func(m *UniversalModel) ProviderClient { return googleProvider{m: m} }},
+		{Name: "OpenRouter", New: func(m *UniversalModel) ProviderClient { return openRouterProvider{m: m} }},
+		{Name: "Ollama", New: func(m *UniversalModel) ProviderClient { return ollamaProvider{m: m} }},
+		{Name: "YandexGPT", New: func(m *UniversalModel) ProviderClient { return yandexProvider{m: m} }},
+		{Name: "GigaChat", New: func(m *UniversalModel) ProviderClient { return gigaChatProvider{m: m} }},
+		{Name: "Grok", New: func(m *UniversalModel) ProviderClient { return grokProvider{m: m} }},
+	} {
+		RegisterProviderSpec(spec)
+	}
+}
+
+// RegisterProviderSpec добавляет провайдера в реестр (или заменяет зарегистрированного
+// с тем же ProviderType). Вызывается до create.New, обычно из init пакета провайдера
+func RegisterProviderSpec(spec ProviderSpec) {
+	p := spec.New(nil)
+	specsMu.Lock()
+	defer specsMu.Unlock()
+	capabilities.Store(p.Provider(), p.Capabilities())
+	for i := range specs {
+		if specs[i].New(nil).Provider() == p.Provider() {
+			specs[i] = spec
+			return
+		}
+	}
+	specs = append(specs, spec)
+}
+
+// providerSpecs копия реестра провайдеров
+func providerSpecs() []ProviderSpec {
+	specsMu.RLock()
+	defer specsMu.RUnlock()
+	return append([]ProviderSpec(nil), specs...)
+}
+
+// RegisteredProviders провайдеры реестра в порядке регистрации
+func RegisteredProviders() []ProviderType {
+	all := providerSpecs()
+	kinds := make([]ProviderType, 0, len(all))
+	for _, spec := range all {
+		kinds = append(kinds, spec.New(nil).Provider())
+	}
+	return kinds
+}
+
+// ProviderName имя провайдера из реестра; для незарегистрированного — ProviderType.String()
+func ProviderName(provider ProviderType) string {
+	for _, spec := range providerSpecs() {
+		if spec.New(nil).Provider() == provider {
+			return spec.Name
+		}
+	}
+	return provider.String()
+}
+
+// ProviderCapabilities возможности провайдера; false — провайдер не зарегистрирован
+func ProviderCapabilities(provider ProviderType) (Capabilities, bool) {
+	c, ok := capabilities.Load(provider)
+	if !ok {
+		return Capabilities{}, false
+	}
+	return c.(Capabilities), true
+}
+
+// RegisterProvider добавляет провайдера (или заменяет встроенного) только в m
+func (m *UniversalModel) RegisterProvider(p ProviderClient) {
+	m.providersMu.Lock()
+	m.providers[p.Provider()] = p
+	m.providersMu.Unlock()
+	capabilities.Store(p.Provider(), p.Capabilities())
+}
+
+// Provider клиент провайдера
+func (m *UniversalModel) Provider(provider ProviderType) (ProviderClient, error) {
+	m.providersMu.RLock()
+	p, ok := m.providers[provider]
+	m.providersMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("неизвестный провайдер: %s", provider)
+	}
+	return p, nil
+}
+
+// ============================================================================
+// OpenAI
+// ============================================================================
+
+type openAIProvider struct{ m *UniversalModel }
+
+func (p openAIProvider) Provider() ProviderType { return ProviderOpenAI }
+
+func (p openAIProvider) Capabilities() Capabilities {
+	return Capabilities{Interpreter: true, Image: true, WebSearch: true, Realtime: true, Transcribe: true, VectorSearch: true}
+}
+
+func (p openAIProvider) CreateAgent(userID uint32, modelData *UniversalModelData, fileIDs []Ids) (UMCR, error) {
+	return p.m.createModel(userID, modelData, fileIDs)
+}
+
+func (p openAIProvider) UpdateAgent(userID uint32, existing, updated *UniversalModelData) error {
+	return p.m.updateOpenAIModelInPlace(userID, existing, updated)
+}
+
+func (p openAIProvider) DeleteAgent(userID uint32, record *UserModelRecord, deleteFiles bool, progressCallback func(string)) error {
+	return p.m.deleteModel(userID, record, deleteFiles, progressCallback)
+}
+
+func (p openAIProvider) Request(ctx context.Context, userID uint32, req ProviderRequest) (ProviderResponse, error) {
+	messages := make([]map[string]string, 0, len(req.Messages)+1)
+	if req.System != "" {
+		messages = append(messages, map[string]string{"role": "system", "content": req.System})
+	}
+	for _, msg := range req.Messages {
+		messages = append(messages, map[string]string{"role": msg.Role, "content": msg.Text})
+	}
+	body := map[string]any{"model": req.Model, "messages": messages}
+	if req.Temperature != nil {
+		body["temperature"] = *req.Temperature
+	}
+	if req.MaxTokens > 0 {
+		body["max_completion_tokens"] = req.MaxTokens
+	}
+
+	resp, err := p.m.openaiClient.doRequest(ctx, http.MethodPost, "/chat/completions", body, userID)
+	if err != nil {
+		return ProviderResponse{}, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	return parseChatCompletion(resp.Body)
+}
+
+func (p openAIProvider) Transcribe(ctx context.Context, _ uint32, audioData []byte, fileName string) (string, error) {
+	return p.m.openaiClient.TranscribeAudio(ctx, audioData, fileName)
+}
+
+// parseChatCompletion разбирает ответ chat/completions (OpenAI, Mistral и совместимые API)
+func parseChatCompletion(r io.Reader) (ProviderResponse, error) {
+	var out struct {
+		Choices []struct {
+			Message struct {
+				Content string `json:"content"`
+			} `json:"message"`
+		} `json:"choices"`
+		Usage struct {
+			PromptTokens     int `json:"prompt_tokens"`
+			CompletionTokens int `json:"completion_tokens"`
+		} `json:"usage"`
+	}
+	if err := json.NewDecoder(r).Decode(&out); err != nil {
+		return ProviderResponse{}, fmt.Errorf("ошибка парсинга ответа: %w", err)
+	}
+	if len(out.Choices) == 0 {
+		return ProviderResponse{}, fmt.Errorf("получен пустой ответ от модели")
+	}
+	return ProviderResponse{
+		Text:         out.Choices[0].Message.Content,
+		InputTokens:  out.Usage.PromptTokens,
+		OutputTokens: out.Usage.CompletionTokens,
+	}, nil
+}
+
+// ============================================================================
+// Mistral
+// ============================================================================
+
+type mistralProvider struct{ m *UniversalModel }
+
+func (p mistralProvider) Provider() ProviderType { return ProviderMistral }
+
+func (p mistralProvider) Capabilities() Capabilities {
+	return Capabilities{Interpreter: true, Image: true, WebSearch: true, Transcribe: true, VectorSearch: true}
+}
+
+func (p mistralProvider) CreateAgent(userID uint32, modelData *UniversalModelData, fileIDs []Ids) (UMCR, error) {
+	return p.m.createMistralModel(userID, modelData, fileIDs)
+}
+
+func (p mistralProvider) UpdateAgent(userID uint32, existing, updated *UniversalModelData) error {
+	return p.m.updateMistralModelInPlace(userID, existing, updated)
+}
+
+func (p mistralProvider) DeleteAgent(userID uint32, record *UserModelRecord, deleteFiles bool, progressCallback func(string)) error {
+	return p.m.deleteMistralModel(userID, record, deleteFiles, progressCallback)
+}
+
+func (p mistralProvider) Request(_ context.Context, userID uint32, req ProviderRequest) (ProviderResponse, error) {
+	messages := make([]map[string]string, 0, len(req.Messages)+1)
+	if req.System != "" {
+		messages = append(messages, map[string]string{"role": "system", "content": req.System})
+	}
+	for _, msg := range req.Messages {
+		messages = append(messages, map[string]string{"role": msg.Role, "content": msg.Text})
+	}
+	body := map[string]any{"model": req.Model, "messages": messages}
+	if req.Temperature != nil {
+		body["temperature"] = *req.Temperature
+	}
+	if req.MaxTokens > 0 {
+		body["max_tokens"] = req.MaxTokens
+	}
+	data, err := json.Marshal(body)
+	if err != nil {
+		return ProviderResponse{}, fmt.Errorf("ошибка сериализации запроса: %w", err)
+	}
+
+	respBody, err := p.m.mistralClient.executeMistralRequest(http.MethodPost, mode.MistralBaseURL+"/chat/completions", data, nil, userID)
+	if err != nil {
+		return ProviderResponse{}, err
+	}
+	return parseChatCompletion(bytes.NewReader(respBody))
+}
+
+func (p mistralProvider) Transcribe(ctx context.Context, userID uint32, audioData []byte, fileName string) (string, error) {
+	if len(audioData) == 0 {
+		return "", fmt.Errorf("пустые аудиоданные")
+	}
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	if err := writer.WriteField("model", "voxtral-mini-latest"); err != nil {
+		return "", fmt.Errorf("ошибка добавления поля model: %w", err)
+	}
+	part, err := writer.CreateFormFile("file", fileName)
+	if err != nil {
+		return "", fmt.Errorf("ошибка создания form file для аудио: %w", err)
+	}
+	if _, err := part.Write(audioData); err != nil {
+		return "", fmt.Errorf("ошибка записи аудио данных: %w", err)
+	}
+	if err := writer.Close(); err != nil {
+		return "", fmt.Errorf("ошибка закрытия writer: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, mode.MistralBaseURL+"/audio/transcriptions", &body)
+	if err != nil {
+		return "", fmt.Errorf("ошибка создания HTTP запроса: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+p.m.mistralClient.resolveKey(userID))
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("ошибка HTTP запроса: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("ошибка чтения ответа: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", providererr.New("Mistral", resp.StatusCode, resp.Header, respBody)
+	}
+	var out struct {
+		Text string `json:"text"`
+	}
+	if err := json.Unmarshal(respBody, &out); err != nil {
+		return "", fmt.Errorf("ошибка парсинга ответа: %w", err)
+	}
+	return out.Text, nil
+}
+
+// ============================================================================
+// Google
+// ============================================================================
+
+type googleProvider struct{ m *UniversalModel }
+
+func (p googleProvider) Provider() ProviderType { return ProviderGoogle }
+
+func (p googleProvider) Capabilities() Capabilities {
+	return Capabilities{Interpreter: true, Image: true, WebSearch: true, Video: true, Realtime: true, Transcribe: true, VectorSearch: true}
+}
+
+func (p googleProvider) CreateAgent(userID uint32, modelData *UniversalModelData, fileIDs []Ids) (UMCR, error) {
+	return p.m.createGoogleModel(userID, modelData, fileIDs)
+}
+
+func (p googleProvider) UpdateAgent(userID uint32, existing, updated *UniversalModelData) error {
+	return p.m.updateGoogleModelInPlace(userID, existing, updated)
+}
+
+func (p googleProvider) DeleteAgent(userID uint32, record *UserModelRecord, deleteFiles bool, progressCallback func(string)) error {
+	return p.m.deleteGoogleModel(userID, record, deleteFiles, progressCallback)
+}
+
+func (p googleProvider) Request(ctx context.Context, userID uint32, req ProviderRequest) (ProviderResponse, error) {
+	contents := make([]map[string]any, 0, len(req.Messages))
+	for _, msg := range req.Messages {
+		role := "user"
+		if msg.Role == "assistant" {
+			role = "model"
+		}
+		contents = append(contents, map[string]any{"role": role, "parts": []map[string]any{{"text": msg.Text}}})
+	}
+	payload := map[string]any{"contents": contents}
+	if req.System != "" {
+		payload["system_instruction"] = map[string]any{"parts": []map[string]any{{"text": req.System}}}
+	}
+	genConfig := map[string]any{}
+	if req.Temperature != nil {
+		genConfig["temperature"] = *req.Temperature
+	}
+	if req.MaxTokens > 0 {
+		genConfig["maxOutputTokens"] = req.MaxTokens
+	}
+	if len(genConfig) > 0 {
+		payload["generationConfig"] = genConfig
+	}
+
+	url := fmt.Sprintf("%s/%s:generateContent?key=%s", p.m.googleClient.url, GoogleModelPath(req.Model), p.m.googleClient.resolveKey(userID))
+	respBody, err := executeGoogleAPIRequest(ctx, url, payload)
+	if err != nil {
+		return ProviderResponse{}, fmt.Errorf("ошибка при вызове API: %w", err)
+	}
+
+	var out struct {
+		Candidates []struct {
+			Content struct {
+				Parts []struct {
+					Text string `json:"text"`
+				} `json:"parts"`
+			} `json:"content"`
+		} `json:"candidates"`
+		UsageMetadata struct {
+			PromptTokenCount     int `json:"promptTokenCount"`
+			CandidatesTokenCount int `json:"candidatesTokenCount"`
+		} `json:"usageMetadata"`
+	}
+	if err := json.Unmarshal(respBody, &out); err != nil {
+		return ProviderResponse{}, fmt.Errorf("ошибка парсинга ответа: %w", err)
+	}
+	if len(out.Candidates) == 0 {
+		return ProviderResponse{}, fmt.Errorf("получен пустой ответ от модели")
+	}
+	var text strings.Builder
+	for _, part := range out.Candidates[0].Content.Parts {
+		text.WriteString(part.Text)
+	}
+	return ProviderResponse{
+		Text:         text.String(),
+		InputTokens:  out.UsageMetadata.PromptTokenCount,
+		OutputTokens: out.UsageMetadata.CandidatesTokenCount,
+	}, nil
+}
+
+func (p googleProvider) Transcribe(_ context.Context, _ uint32, audioData []byte, fileName string) (string, error) {
+	mimeType := mime.TypeByExtension(filepath.Ext(fileName))
+	if i := strings.IndexByte(mimeType, ';'); i >= 0 {
+		mimeType = mimeType[:i]
+	}
+	return p.m.googleClient.TranscribeAudio(audioData, mimeType)
+}
@@ -0,0 +1,45 @@
+package create
+
+import (
+	"context"
+	"testing"
+)
+
+// stubProvider — провайдер вне встроенного списка
+type stubProvider struct{ ProviderClient }
+
+func (stubProvider) Provider() ProviderType     { return ProviderType(250) }
+func (stubProvider) Capabilities() Capabilities { return Capabilities{WebSearch: true} }
+
+func (stubProvider) Request(context.Context, uint32, ProviderRequest) (ProviderResponse, error) {
+	return ProviderResponse{Text: "ok"}, nil
+}
+
+func TestRegisterProviderSpec(t *testing.T) {
+	kinds := RegisteredProviders()
+	if len(kinds) < len(AllProviders) || kinds[0] != ProviderOpenAI || ProviderName(ProviderYandex) != "YandexGPT" {
+		t.Fatalf("builtin providers: %v", kinds)
+	}
+
+	RegisterProviderSpec(ProviderSpec{Name: "Stub", New: func(*UniversalModel) ProviderClient { return stubProvider{} }})
+	RegisterProviderSpec(ProviderSpec{Name: "Stub v2", New: func(*UniversalModel) ProviderClient { return stubProvider{} }})
+	kinds = RegisteredProviders()
+	if len(kinds) != len(AllProviders)+1 || kinds[len(kinds)-1] != ProviderType(250) || ProviderName(ProviderType(250)) != "Stub v2" {
+		t.Fatalf("registered provider: %v %q", kinds, ProviderName(ProviderType(250)))
+	}
+	if caps, ok := ProviderCapabilities(ProviderType(250)); !ok || !caps.WebSearch {
+		t.Fatalf("capabilities: %+v %v", caps, ok)
+	}
+
+	m := &UniversalModel{providers: make(map[ProviderType]ProviderClient)}
+	for _, spec := range providerSpecs() {
+		m.RegisterProvider(spec.New(m))
+	}
+	p, err := m.Provider(ProviderType(250))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp, _ := p.Request(context.Background(), 1, ProviderRequest{}); resp.Text != "ok" {
+		t.Fatalf("request: %+v", resp)
+	}
+}
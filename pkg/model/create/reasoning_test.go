@@ -0,0 +1,68 @@
+package create
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestReasoningEffort(t *testing.T) {
+	cases := []struct {
+		effort ReasoningEffort
+		valid  bool
+		budget int
+	}{
+		{ReasoningLow, true, 1024},
+		{ReasoningMedium, true, 8192},
+		{ReasoningHigh, true, 24576},
+		{"", false, 0},
+		{"max", false, 0},
+	}
+	for _, c := range cases {
+		if c.effort.IsValid() != c.valid || c.effort.ThinkingBudget() != c.budget {
+			t.Errorf("%q: IsValid=%v ThinkingBudget=%d", c.effort, c.effort.IsValid(), c.effort.ThinkingBudget())
+		}
+	}
+
+	for model, want := range map[string]bool{
+		"o3-mini": true, "O4-mini": true, "gpt-5-mini": true, "gpt-4o": false, "gpt-4.1-nano": false,
+	} {
+		if got := OpenAISupportsReasoning(model); got != want {
+			t.Errorf("OpenAISupportsReasoning(%q) = %v", model, got)
+		}
+	}
+}
+
+func TestCreateResponse_ReasoningEffort(t *testing.T) {
+	var payload map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		payload = nil
+		_ = json.NewDecoder(r.Body).Decode(&payload)
+		_, _ = w.Write([]byte("data: [DONE]\n\n"))
+	}))
+	defer srv.Close()
+	c := &OpenAIAgentClient{url: srv.URL, ctx: context.Background(), httpClient: srv.Client()}
+
+	type agentConfig struct {
+		ModelName string          `json:"model_name"`
+		Reasoning ReasoningEffort `json:"reasoning,omitempty"`
+	}
+
+	_, _, _ = c.createResponseInternal(context.Background(), "вопрос", &agentConfig{ModelName: "gpt-5-mini", Reasoning: ReasoningHigh}, nil, nil, 1, 0)
+	if reasoning, _ := payload["reasoning"].(map[string]any); reasoning["effort"] != "high" {
+		t.Fatalf("reasoning: %v", payload["reasoning"])
+	}
+
+	// Модель без рассуждений ответила бы 400 — параметр не передаётся
+	_, _, _ = c.createResponseInternal(context.Background(), "вопрос", &agentConfig{ModelName: "gpt-4o", Reasoning: ReasoningHigh}, nil, nil, 1, 0)
+	if _, ok := payload["reasoning"]; ok {
+		t.Fatalf("reasoning для gpt-4o: %v", payload["reasoning"])
+	}
+
+	_, _, _ = c.createResponseInternal(context.Background(), "вопрос", &agentConfig{ModelName: "gpt-5-mini"}, nil, nil, 1, 0)
+	if _, ok := payload["reasoning"]; ok {
+		t.Fatalf("reasoning без настройки: %v", payload["reasoning"])
+	}
+}
@@ -0,0 +1,247 @@
+package create
+
+import (
+	"encoding/json"
+	"slices"
+	"sync"
+)
+
+// ============================================================================
+// RESPONSE SCHEMA - Единственное описание структурированного ответа модели
+// (model.AssistResponse). Провайдеро-специфичные варианты строятся из него:
+// Gemini response_schema, OpenAI json_schema (strict) и текст схемы для промпта
+// ============================================================================
+
+// SchemaField дополнительное поле ответа модели, объявленное приложением
+type SchemaField struct {
+	Name        string `json:"name"`
+	Type        string `json:"type"` // "string", "boolean", "number" или "integer"
+	Description string `json:"description,omitempty"`
+	Enum        []any  `json:"enum,omitempty"`
+	Required    bool   `json:"required,omitempty"` // модель обязана вернуть поле; необязательное в OpenAI strict допускает null
+}
+
+// schemaFieldTypes допустимые типы дополнительных полей
+var schemaFieldTypes = []string{"string", "boolean", "number", "integer"}
+
+// reservedResponseFields поля model.AssistResponse, которые нельзя переопределить
+var reservedResponseFields = []string{"message", "action", "target", "operator", "confidence", "sentiment", "citations", "blocked", "block_reasons", "extra"}
+
+var (
+	responseFieldsMu sync.RWMutex
+	responseFields   []SchemaField
+)
+
+// RegisterResponseField добавляет поле во все схемы ответа (вызывать при старте приложения).
+// Значение поля попадает в model.AssistResponse.Extra
+func RegisterResponseField(field SchemaField) {
+	responseFieldsMu.Lock()
+	defer responseFieldsMu.Unlock()
+	for i, f := range responseFields {
+		if f.Name == field.Name {
+			responseFields[i] = field
+			return
+		}
+	}
+	responseFields = append(responseFields, field)
+}
+
+// ResponseFields поля, объявленные через RegisterResponseField
+func ResponseFields() []SchemaField {
+	responseFieldsMu.RLock()
+	defer responseFieldsMu.RUnlock()
+	return append([]SchemaField(nil), responseFields...)
+}
+
+// IsReservedResponseField поле принадлежит model.AssistResponse и не может быть дополнительным
+func IsReservedResponseField(name string) bool {
+	return slices.Contains(reservedResponseFields, name)
+}
+
+// ResponseSchema схема ответа модели
+type ResponseSchema struct {
+	MetaAction bool          // у модели есть цель: target может быть true, иначе всегда false
+	Operator   bool          // подключение оператора включено; выключенное поле operator не описывается в OpenAI-схеме
+	Fields     []SchemaField // дополнительные поля
+}
+
+// NewResponseSchema схема ответа с полями из RegisterResponseField
+func NewResponseSchema(metaAction, operator bool) *ResponseSchema {
+	return &ResponseSchema{MetaAction: metaAction, Operator: operator, Fields: ResponseFields()}
+}
+
+// DefaultResponseSchema схема со всеми системными полями (target и operator — обычные boolean)
+func DefaultResponseSchema() *ResponseSchema {
+	return NewResponseSchema(true, true)
+}
+
+// ResponseSchema схема ответа модели с полями ассистента (ResponseFields)
+func (d *UniversalModelData) ResponseSchema() *ResponseSchema {
+	s := NewResponseSchema(d.MetaAction != "", d.Operator)
+	for _, f := range d.ResponseFields {
+		s.WithField(f)
+	}
+	return s
+}
+
+// WithField добавляет поле только в эту схему; поле с тем же именем заменяется
+func (s *ResponseSchema) WithField(field SchemaField) *ResponseSchema {
+	for i, f := range s.Fields {
+		if f.Name == field.Name {
+			s.Fields[i] = field
+			return s
+		}
+	}
+	s.Fields = append(s.Fields, field)
+	return s
+}
+
+// Gemini схема для generationConfig.response_schema: без additionalProperties
+// и enum у boolean (Gemini их не поддерживает)
+func (s *ResponseSchema) Gemini() map[string]any {
+	return s.build(false)
+}
+
+// OpenAI схема для json_schema в strict-режиме: все поля перечислены в required,
+// дополнительные свойства запрещены
+func (s *ResponseSchema) OpenAI() map[string]any {
+	return s.build(true)
+}
+
+// OpenAIResponseFormat response_format для Responses API и совместимых chat/completions
+func (s *ResponseSchema) OpenAIResponseFormat() map[string]any {
+	return map[string]any{
+		"type": "json_schema",
+		"json_schema": map[string]any{
+			"name":   "assist_response",
+			"strict": true,
+			"schema": s.OpenAI(),
+		},
+	}
+}
+
+// PromptText схема для текста промпта — для моделей и режимов без response_schema
+func (s *ResponseSchema) PromptText() string {
+	data, err := json.MarshalIndent(s.Gemini(), "", "\t")
+	if err != nil {
+		return "{}"
+	}
+	return string(data)
+}
+
+func (s *ResponseSchema) build(strict bool) map[string]any {
+	fileProperties := map[string]any{
+		"type":      map[string]any{"type": "string", "enum": []string{"photo", "video", "audio", "doc"}, "description": "File type"},
+		"url":       map[string]any{"type": "string", "description": "File URL"},
+		"file_name": map[string]any{"type": "string", "description": "File name"},
+		"caption":   map[string]any{"type": "string", "description": "File caption - use this field to message user when sending files"},
+	}
+	fileItem := map[string]any{
+		"type":       "object",
+		"properties": fileProperties,
+		"required":   []string{"type", "url", "file_name", "caption"},
+	}
+	buttonItem := map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"text":    map[string]any{"type": "string", "description": "Button label"},
+			"payload": map[string]any{"type": "string", "description": "Value sent back as the user's reply when pressed (empty - text is sent)"},
+			"url":     map[string]any{"type": "string", "description": "Link opened by the button (empty for reply buttons)"},
+		},
+		"required": []string{"text", "payload", "url"},
+	}
+	action := map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"send_files":    map[string]any{"type": "array", "items": fileItem},
+			"buttons":       map[string]any{"type": "array", "items": buttonItem, "description": "Inline buttons under the message. Empty array if not needed"},
+			"quick_replies": map[string]any{"type": "array", "items": buttonItem, "description": "Suggested short user replies. Empty array if not needed"},
+		},
+		"required": []string{"send_files"},
+	}
+	if strict {
+		// strict-режим требует перечислить все свойства в required
+		action["required"] = []string{"send_files", "buttons", "quick_replies"}
+	}
+
+	target := map[string]any{"type": "boolean", "description": "Is dialog goal achieved"}
+	if strict && !s.MetaAction {
+		target["enum"] = []any{false} // без цели target всегда false
+	}
+	properties := map[string]any{
+		"message": map[string]any{"type": "string", "description": "Text message for user. Leave empty (\"\") if sending files with caption!"},
+		"action":  action,
+		"target":  target,
+	}
+	required := []string{"message", "action", "target"}
+	if s.Operator || !strict {
+		properties["operator"] = map[string]any{"type": "boolean", "description": "Is operator connection required"}
+		required = append(required, "operator")
+	}
+
+	for _, f := range s.Fields {
+		property := map[string]any{"type": f.Type}
+		if strict && !f.Required {
+			property["type"] = []string{f.Type, "null"}
+		}
+		if f.Description != "" {
+			property["description"] = f.Description
+		}
+		if len(f.Enum) > 0 {
+			property["enum"] = f.Enum
+			if strict && !f.Required {
+				property["enum"] = append(append([]any(nil), f.Enum...), nil)
+			}
+		}
+		properties[f.Name] = property
+		if strict || f.Required {
+			required = append(required, f.Name)
+		}
+	}
+
+	schema := map[string]any{
+		"type":       "object",
+		"properties": properties,
+		"required":   required,
+	}
+	if strict {
+		fileItem["additionalProperties"] = false
+		buttonItem["additionalProperties"] = false
+		action["additionalProperties"] = false
+		schema["additionalProperties"] = false
+	}
+	return schema
+}
+
+// ============================================================================
+// Совместимость с прежним API
+// ============================================================================
+
+// GoogleSchemaJSON JSON Schema для структурированных ответов Gemini Agent.
+// Поля RegisterResponseField, объявленные после инициализации пакета, в неё не попадают.
+//
+// Deprecated: используйте NewResponseSchema(...).PromptText() или Gemini().
+var GoogleSchemaJSON = DefaultResponseSchema().PromptText()
+
+// MistralSchemaJSON JSON Schema для структурированных ответов Mistral Agent.
+//
+// Deprecated: используйте NewResponseSchema(...).PromptText().
+var MistralSchemaJSON = DefaultResponseSchema().PromptText()
+
+// GenerateModelSchema генерирует JSON Schema с учётом параметров модели (OpenAI strict).
+//
+// Deprecated: используйте NewResponseSchema(hasMetaAction, hasOperator).OpenAI().
+func GenerateModelSchema(hasMetaAction bool, hasOperator bool) map[string]any {
+	return NewResponseSchema(hasMetaAction, hasOperator).OpenAI()
+}
+
+// ParseModelSchemaJSON схема со всеми системными полями: с additionalProperties — для
+// json_schema OpenAI, без них — для response_schema Google.
+//
+// Deprecated: используйте DefaultResponseSchema().OpenAI() или Gemini().
+func ParseModelSchemaJSON(includeAdditionalProperties bool) map[string]any {
+	if includeAdditionalProperties {
+		return DefaultResponseSchema().OpenAI()
+	}
+	return DefaultResponseSchema().Gemini()
+}
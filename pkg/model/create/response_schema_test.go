@@ -0,0 +1,121 @@
+package create
+
+import (
+	"encoding/json"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestResponseSchemaOpenAIStrict(t *testing.T) {
+	schema := NewResponseSchema(false, false).
+		WithField(SchemaField{Name: "mood", Type: "string", Enum: []any{"good", "bad"}}).
+		OpenAI()
+
+	properties := schema["properties"].(map[string]any)
+	if _, ok := properties["operator"]; ok {
+		t.Fatal("выключенный operator не должен описываться в OpenAI-схеме")
+	}
+	if enum := properties["target"].(map[string]any)["enum"]; enum == nil {
+		t.Fatal("без цели target должен быть ограничен false")
+	}
+	required := schema["required"].([]string)
+	for name := range properties {
+		if !slices.Contains(required, name) {
+			t.Errorf("strict-схема: поле %s не в required", name)
+		}
+	}
+	if schema["additionalProperties"] != false {
+		t.Fatal("strict-схема должна запрещать дополнительные свойства")
+	}
+	mood := properties["mood"].(map[string]any)
+	if types, ok := mood["type"].([]string); !ok || !slices.Contains(types, "null") {
+		t.Fatalf("необязательное поле должно допускать null: %v", mood["type"])
+	}
+}
+
+func TestResponseSchemaGemini(t *testing.T) {
+	schema := NewResponseSchema(false, false).Gemini()
+	data, _ := json.Marshal(schema)
+	if strings.Contains(string(data), "additionalProperties") || strings.Contains(string(data), `"enum":[false]`) {
+		t.Fatalf("Gemini-схема содержит неподдерживаемые ключевые слова: %s", data)
+	}
+	properties := schema["properties"].(map[string]any)
+	if _, ok := properties["operator"]; !ok {
+		t.Fatal("Gemini-схема описывает operator всегда")
+	}
+}
+
+func TestRegisterResponseField(t *testing.T) {
+	defer func() { responseFields = nil }()
+
+	RegisterResponseField(SchemaField{Name: "lead_score", Type: "integer", Description: "old"})
+	RegisterResponseField(SchemaField{Name: "lead_score", Type: "integer", Description: "Lead score 0-10", Required: true})
+
+	schema := DefaultResponseSchema()
+	if len(schema.Fields) != 1 || schema.Fields[0].Description != "Lead score 0-10" {
+		t.Fatalf("поле не заменено: %+v", schema.Fields)
+	}
+	if !strings.Contains(schema.PromptText(), "lead_score") {
+		t.Fatal("поле приложения не попало в текст схемы")
+	}
+	if required := schema.Gemini()["required"].([]string); !slices.Contains(required, "lead_score") {
+		t.Fatalf("обязательное поле не в required: %v", required)
+	}
+}
+
+func TestUniversalModelDataResponseFields(t *testing.T) {
+	data := &UniversalModelData{
+		Provider: ProviderOpenAI,
+		GptType:  &GptType{Name: "gpt-4o"},
+		Prompt:   "prompt",
+		ResponseFields: []SchemaField{
+			{Name: "order_id", Type: "string", Required: true},
+		},
+	}
+	properties := data.ResponseSchema().OpenAI()["properties"].(map[string]any)
+	if _, ok := properties["order_id"]; !ok {
+		t.Fatalf("поле ассистента не попало в схему: %v", properties)
+	}
+	if DefaultResponseSchema().Fields != nil {
+		t.Fatal("поле ассистента не должно попадать в общие схемы")
+	}
+	if issues := ValidateModelData(data); issues.HasErrors() {
+		t.Fatalf("ошибки валидации: %v", issues)
+	}
+
+	data.ResponseFields = append(data.ResponseFields,
+		SchemaField{Name: "message", Type: "string"},
+		SchemaField{Name: "order_id", Type: "string"},
+		SchemaField{Name: "score", Type: "float"},
+	)
+	codes := map[string]bool{}
+	for _, issue := range ValidateModelData(data) {
+		codes[issue.Code] = true
+	}
+	for _, code := range []string{"reserved_name", "duplicate_name", "unknown_type"} {
+		if !codes[code] {
+			t.Errorf("нет проблемы %s: %v", code, codes)
+		}
+	}
+}
+
+func TestDeprecatedSchemaWrappers(t *testing.T) {
+	var parsed map[string]any
+	if err := json.Unmarshal([]byte(GoogleSchemaJSON), &parsed); err != nil || parsed["properties"] == nil {
+		t.Fatalf("GoogleSchemaJSON: %v", err)
+	}
+	if MistralSchemaJSON == "" {
+		t.Fatal("MistralSchemaJSON is empty")
+	}
+	if ParseModelSchemaJSON(true)["additionalProperties"] != false {
+		t.Fatal("OpenAI schema must forbid additional properties")
+	}
+	if _, ok := ParseModelSchemaJSON(false)["additionalProperties"]; ok {
+		t.Fatal("Gemini schema must not set additionalProperties")
+	}
+	schema := GenerateModelSchema(true, false)
+	if _, ok := schema["properties"].(map[string]any)["operator"]; ok {
+		t.Fatal("operator described while disabled")
+	}
+}
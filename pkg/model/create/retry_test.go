@@ -0,0 +1,66 @@
+package create
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ikermy/AiR_Common/pkg/mode"
+)
+
+func TestChatCompletionsClient_RetriesRateLimit(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		if calls.Add(1) == 1 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		_, _ = w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"ok"}}]}`))
+	}))
+	defer srv.Close()
+
+	c := NewChatCompletionsClient("test", srv.URL)
+	resp, err := c.Complete(context.Background(), 0, ChatRequest{Model: "m"})
+	if err != nil || resp.Text() != "ok" || calls.Load() != 2 {
+		t.Fatalf("ответ %q, ошибка %v, запросов %d", resp.Text(), err, calls.Load())
+	}
+
+	// Провайдер просит ждать дольше MaxRetryAfter — ошибка возвращается без повтора
+	calls.Store(0)
+	srv.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		calls.Add(1)
+		w.Header().Set("Retry-After", "3600")
+		w.WriteHeader(http.StatusTooManyRequests)
+	})
+	if _, err := c.Complete(context.Background(), 0, ChatRequest{Model: "m"}); err == nil || calls.Load() != 1 {
+		t.Fatalf("ошибка %v, запросов %d", err, calls.Load())
+	}
+}
+
+func TestRetryPolicy(t *testing.T) {
+	rule := mode.RetryRule{MaxAttempts: 4, BaseDelay: time.Second, MaxDelay: 3 * time.Second}
+	for attempt, want := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 3: 3 * time.Second} {
+		if got := rule.Backoff(attempt); got != want {
+			t.Errorf("Backoff(%d) = %v, want %v", attempt, got, want)
+		}
+	}
+
+	p := mode.RetryPolicy{RateLimit: mode.RetryRule{MaxAttempts: 2, BaseDelay: time.Second}, MaxRetryAfter: time.Minute}
+	if d, ok := p.RateLimitDelay(1, -1); !ok || d != time.Second {
+		t.Errorf("без подсказки: %v %v", d, ok)
+	}
+	if d, ok := p.RateLimitDelay(1, 10*time.Second); !ok || d != 10*time.Second {
+		t.Errorf("Retry-After: %v %v", d, ok)
+	}
+	if _, ok := p.RateLimitDelay(2, 0); ok {
+		t.Error("попытки исчерпаны")
+	}
+
+	if mode.ParseRetryAfter("") != -1 || mode.ParseRetryAfter("7") != 7*time.Second {
+		t.Error("ParseRetryAfter")
+	}
+}
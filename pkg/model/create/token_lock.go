@@ -0,0 +1,41 @@
+package create
+
+import "sync"
+
+// tokenLocks сериализует получение токена по одному ключу: запросы с разными ключами
+// не ждут друг друга, пока идёт обмен с сервером авторизации
+type tokenLocks struct {
+	mu    sync.Mutex
+	locks map[string]*tokenLock
+}
+
+// tokenLock блокировка ключа; refs — сколько запросов её держат или ждут
+type tokenLock struct {
+	mu   sync.Mutex
+	refs int
+}
+
+// lock захватывает блокировку key; unlock освобождает её
+func (l *tokenLocks) lock(key string) (unlock func()) {
+	l.mu.Lock()
+	if l.locks == nil {
+		l.locks = make(map[string]*tokenLock)
+	}
+	k := l.locks[key]
+	if k == nil {
+		k = &tokenLock{}
+		l.locks[key] = k
+	}
+	k.refs++
+	l.mu.Unlock()
+
+	k.mu.Lock()
+	return func() {
+		k.mu.Unlock()
+		l.mu.Lock()
+		if k.refs--; k.refs == 0 {
+			delete(l.locks, key)
+		}
+		l.mu.Unlock()
+	}
+}
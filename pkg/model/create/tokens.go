@@ -0,0 +1,139 @@
+package create
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// tokenRates средняя длина токена словаря в символах: латиница и прочие алфавиты
+// (кириллица, греческий) токенизируются по-разному
+type tokenRates struct {
+	latin, other float64
+}
+
+var (
+	// cl100k_base (gpt-4, gpt-3.5): кириллица ~2 символа на токен
+	ratesCL100k = tokenRates{latin: 4.5, other: 2.2}
+	// o200k_base (gpt-4o, gpt-4.1, gpt-5, o-серия), SentencePiece Gemini и Tekken Mistral
+	ratesO200k = tokenRates{latin: 4.8, other: 3.6}
+)
+
+func tokenRatesFor(modelName string) tokenRates {
+	name := strings.ToLower(modelName)
+	if strings.HasPrefix(name, "gpt-3.5") || (strings.HasPrefix(name, "gpt-4") && !strings.HasPrefix(name, "gpt-4o") && !strings.HasPrefix(name, "gpt-4.")) {
+		return ratesCL100k
+	}
+	return ratesO200k
+}
+
+// EstimateTokens оценивает число токенов text без обращения к API. Текст делится на части
+// так же, как перед BPE в tiktoken: слова с ведущим пробелом, группы до 3 цифр,
+// знаки препинания и пробельные последовательности; длина слова в токенах берётся по
+// средней длине токена словаря модели (cl100k_base или o200k_base). Погрешность на
+// обычном тексте — около 10%, для точного подсчёта у Gemini есть countTokens.
+// У Mistral эндпоинта подсчёта нет, поэтому для его моделей это единственный способ
+// (словарь Tekken по средней длине токена близок к o200k_base)
+func EstimateTokens(text, modelName string) int {
+	if text == "" {
+		return 0
+	}
+	rates := tokenRatesFor(modelName)
+
+	tokens := 0.0
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		switch {
+		case unicode.IsLetter(r) || (r == ' ' && i+size < len(text) && isLetterAt(text, i+size)):
+			// слово вместе с ведущим пробелом
+			start := i
+			if r == ' ' {
+				i += size
+			}
+			latin, other := 0, 0
+			for i < len(text) {
+				r, size = utf8.DecodeRuneInString(text[i:])
+				if !unicode.IsLetter(r) && !unicode.IsMark(r) {
+					break
+				}
+				if r < utf8.RuneSelf {
+					latin++
+				} else {
+					other++
+				}
+				i += size
+			}
+			if i == start {
+				i += size
+			}
+			tokens += max(1, float64(latin)/rates.latin+float64(other)/rates.other)
+		case unicode.IsDigit(r):
+			n := 0
+			for i < len(text) {
+				r, size = utf8.DecodeRuneInString(text[i:])
+				if !unicode.IsDigit(r) {
+					break
+				}
+				n++
+				i += size
+			}
+			tokens += float64((n + 2) / 3)
+		case unicode.IsSpace(r):
+			for i < len(text) {
+				r, size = utf8.DecodeRuneInString(text[i:])
+				if !unicode.IsSpace(r) || (r == ' ' && i+size < len(text) && isLetterAt(text, i+size)) {
+					break
+				}
+				i += size
+			}
+			tokens++
+		default:
+			// знаки препинания и символы: однобайтовые обычно сливаются попарно,
+			// эмодзи и редкие символы занимают несколько байтовых токенов
+			n := 0.0
+			for i < len(text) {
+				r, size = utf8.DecodeRuneInString(text[i:])
+				if unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsSpace(r) {
+					break
+				}
+				if size == 1 {
+					n += 0.5
+				} else {
+					n += float64(size) / 2
+				}
+				i += size
+			}
+			tokens += max(1, n)
+		}
+	}
+	return int(tokens + 0.5)
+}
+
+func isLetterAt(s string, i int) bool {
+	r, _ := utf8.DecodeRuneInString(s[i:])
+	return unicode.IsLetter(r)
+}
+
+// CountTokens точное число токенов text для модели Gemini (models/{model}:countTokens)
+func (m *GoogleAgentClient) CountTokens(userID uint32, modelName, text string) (int, error) {
+	payload := map[string]any{
+		"contents": []map[string]any{
+			{"role": "user", "parts": []map[string]any{{"text": text}}},
+		},
+	}
+	url := fmt.Sprintf("%s/%s:countTokens?key=%s", m.url, GoogleModelPath(modelName), m.resolveKey(userID))
+
+	responseBody, err := executeGoogleAPIRequest(m.ctx, url, payload)
+	if err != nil {
+		return 0, fmt.Errorf("ошибка подсчёта токенов: %w", err)
+	}
+	var result struct {
+		TotalTokens int `json:"totalTokens"`
+	}
+	if err := json.Unmarshal(responseBody, &result); err != nil {
+		return 0, fmt.Errorf("ошибка парсинга ответа countTokens: %w", err)
+	}
+	return result.TotalTokens, nil
+}
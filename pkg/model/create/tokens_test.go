@@ -0,0 +1,44 @@
+package create
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestEstimateTokens(t *testing.T) {
+	cases := []struct {
+		text, model string
+		min, max    int // диапазон вокруг числа токенов tiktoken
+	}{
+		{"", "gpt-4o", 0, 0},
+		{"Hello, world!", "gpt-4o", 4, 4},
+		{"Привет, как дела?", "gpt-4o", 5, 7},
+		{"Привет, как дела?", "gpt-4", 7, 11}, // cl100k_base дробит кириллицу мельче
+		{"1234567", "gpt-4o", 3, 3},
+		{strings.Repeat("The quick brown fox jumps over the lazy dog. ", 100), "gpt-4o", 900, 1100},
+	}
+	for _, c := range cases {
+		if got := EstimateTokens(c.text, c.model); got < c.min || got > c.max {
+			t.Errorf("EstimateTokens(%.30q, %s) = %d, ожидалось %d..%d", c.text, c.model, got, c.min, c.max)
+		}
+	}
+}
+
+func TestGoogleCountTokens(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1beta/models/gemini-2.5-flash:countTokens" {
+			t.Errorf("путь %s", r.URL.Path)
+		}
+		_, _ = w.Write([]byte(`{"totalTokens": 42}`))
+	}))
+	defer srv.Close()
+
+	client := &GoogleAgentClient{apiKey: "key", url: srv.URL + "/v1beta", ctx: context.Background()}
+	n, err := client.CountTokens(0, "gemini-2.5-flash", "text")
+	if err != nil || n != 42 {
+		t.Errorf("CountTokens = %d, %v", n, err)
+	}
+}
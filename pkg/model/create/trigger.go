@@ -0,0 +1,178 @@
+package create
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/ikermy/AiR_Common/pkg/mode"
+)
+
+// Префиксы триггеров (Metas.Triggers, EscalationRule.Keywords). Триггер без префикса —
+// слово или фраза без учёта регистра (ё = е); знаки препинания и пробелы между словами
+// не важны: "не работает" совпадает с "Не  работает!"
+const (
+	TriggerRegexPrefix = "re:" // "re:не\s+(работает|грузится)" — регулярное выражение без учёта регистра
+	TriggerFuzzyPrefix = "~"   // "~доставка" — слова с опечатками (расстояние Левенштейна)
+)
+
+// TriggerMatcher скомпилированный набор триггеров: строится один раз на набор, проверки
+// не компилируют выражений и не нормализуют фразы триггеров
+type TriggerMatcher struct {
+	phrases []compiledTrigger // подстрока нормализованного текста
+	fuzzy   []compiledTrigger // последовательность слов с опечатками
+	regexps []compiledTrigger
+}
+
+type compiledTrigger struct {
+	source string
+	phrase string
+	words  [][]rune
+	re     *regexp.Regexp
+}
+
+// CompileTriggers компилирует триггеры. Ошибка — некорректное регулярное выражение;
+// пустые триггеры пропускаются
+func CompileTriggers(triggers []string) (*TriggerMatcher, error) {
+	m := &TriggerMatcher{}
+	for _, t := range triggers {
+		switch {
+		case strings.HasPrefix(t, TriggerRegexPrefix):
+			expr := strings.TrimPrefix(t, TriggerRegexPrefix)
+			if expr == "" {
+				continue
+			}
+			re, err := regexp.Compile("(?i)" + expr)
+			if err != nil {
+				return nil, fmt.Errorf("триггер %q: %w", t, err)
+			}
+			m.regexps = append(m.regexps, compiledTrigger{source: t, re: re})
+		case strings.HasPrefix(t, TriggerFuzzyPrefix):
+			words := strings.Fields(NormalizeTriggerText(strings.TrimPrefix(t, TriggerFuzzyPrefix)))
+			if len(words) == 0 {
+				continue
+			}
+			c := compiledTrigger{source: t}
+			for _, w := range words {
+				c.words = append(c.words, []rune(w))
+			}
+			m.fuzzy = append(m.fuzzy, c)
+		default:
+			if phrase := NormalizeTriggerText(t); phrase != "" {
+				m.phrases = append(m.phrases, compiledTrigger{source: t, phrase: phrase})
+			}
+		}
+	}
+	return m, nil
+}
+
+// Empty в наборе нет ни одного триггера
+func (m *TriggerMatcher) Empty() bool {
+	return m == nil || len(m.phrases)+len(m.fuzzy)+len(m.regexps) == 0
+}
+
+// Match возвращает первый сработавший триггер в исходной записи
+func (m *TriggerMatcher) Match(text string) (string, bool) {
+	if m.Empty() {
+		return "", false
+	}
+	for _, c := range m.regexps {
+		if c.re.MatchString(text) {
+			return c.source, true
+		}
+	}
+	norm := NormalizeTriggerText(text)
+	for _, c := range m.phrases {
+		if strings.Contains(norm, c.phrase) {
+			return c.source, true
+		}
+	}
+	if len(m.fuzzy) > 0 {
+		fields := strings.Fields(norm)
+		words := make([][]rune, len(fields))
+		for i, w := range fields {
+			words[i] = []rune(w)
+		}
+		for _, c := range m.fuzzy {
+			if fuzzyPhrase(words, c.words) {
+				return c.source, true
+			}
+		}
+	}
+	return "", false
+}
+
+// NormalizeTriggerText нижний регистр, ё → е, всё кроме букв и цифр — одиночный пробел
+func NormalizeTriggerText(text string) string {
+	var b strings.Builder
+	b.Grow(len(text))
+	space := true
+	for _, r := range text {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			r = unicode.ToLower(r)
+			if r == 'ё' {
+				r = 'е'
+			}
+			b.WriteRune(r)
+			space = false
+		case !space:
+			b.WriteByte(' ')
+			space = true
+		}
+	}
+	return strings.TrimSuffix(b.String(), " ")
+}
+
+// fuzzyPhrase слова фразы идут в тексте подряд, каждое — с допустимым числом опечаток
+func fuzzyPhrase(text, phrase [][]rune) bool {
+	for i := 0; i+len(phrase) <= len(text); i++ {
+		ok := true
+		for j, w := range phrase {
+			if levenshtein(text[i+j], w, fuzzyDistance(w)) > fuzzyDistance(w) {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			return true
+		}
+	}
+	return false
+}
+
+// fuzzyDistance допустимое число опечаток в слове: одна на 4 буквы, не больше
+// mode.TriggerFuzzyDistance. Короткие слова сравниваются точно
+func fuzzyDistance(word []rune) int {
+	return min(len(word)/4, mode.TriggerFuzzyDistance)
+}
+
+// levenshtein расстояние Левенштейна; больше limit — возвращается limit+1 без полного расчёта
+func levenshtein(a, b []rune, limit int) int {
+	if d := len(a) - len(b); d > limit || -d > limit {
+		return limit + 1
+	}
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		rowMin := cur[0]
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+			rowMin = min(rowMin, cur[j])
+		}
+		if rowMin > limit {
+			return limit + 1
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
@@ -0,0 +1,67 @@
+package create
+
+import "testing"
+
+func TestTriggerMatcher(t *testing.T) {
+	m, err := CompileTriggers([]string{"Возврат", "не работает", `re:заказ\s*№?\s*\d{5}`, "~доставка курьером", ""})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		text, want string
+	}{
+		{"Хочу ВОЗВРАТ денег", "Возврат"},
+		{"Приложение НЕ   работает!!!", "не работает"},
+		{"где заказ № 12345?", `re:заказ\s*№?\s*\d{5}`},
+		{"когда будет доствка курьиром", "~доставка курьером"}, // опечатка в каждом слове
+		{"доставка почтой", ""},
+		{"работает не всё", ""},
+	}
+	for _, c := range cases {
+		got, ok := m.Match(c.text)
+		if ok != (c.want != "") || got != c.want {
+			t.Errorf("Match(%q) = %q, %v; ожидалось %q", c.text, got, ok, c.want)
+		}
+	}
+}
+
+func TestTriggerMatcher_ShortWordsExact(t *testing.T) {
+	m, _ := CompileTriggers([]string{"~чек"})
+	if _, ok := m.Match("чек пришёл"); !ok {
+		t.Fatal("точное совпадение не найдено")
+	}
+	if _, ok := m.Match("чей это"); ok {
+		t.Fatal("короткое слово совпало с опечаткой")
+	}
+}
+
+func TestCompileTriggers_InvalidRegex(t *testing.T) {
+	if _, err := CompileTriggers([]string{"re:(("}); err == nil {
+		t.Fatal("ожидалась ошибка выражения")
+	}
+	if m, _ := CompileTriggers([]string{"ёлка"}); !m.Empty() {
+		if _, ok := m.Match("Елка в зале"); !ok {
+			t.Fatal("ё и е должны совпадать")
+		}
+	}
+}
+
+func TestValidateModelData_Rules(t *testing.T) {
+	data := &UniversalModelData{
+		Prompt:   "p",
+		Provider: ProviderMistral,
+		GptType:  &GptType{Name: "mistral-small-latest"},
+		Triggers: []string{"re:[a-"},
+		Rules: []EscalationRule{
+			{Name: "faq", Keywords: []string{"часы"}, Actions: []string{RuleReply}},
+			{Name: "bad", Keywords: []string{"x"}, Actions: []string{"sms"}},
+		},
+	}
+	codes := map[string]string{}
+	for _, i := range ValidateModelData(data) {
+		codes[i.Field] = i.Code
+	}
+	if codes["trig"] != "invalid_trigger" || codes["rules.faq"] != "action_incomplete" || codes["rules.bad"] != "unknown_action" {
+		t.Errorf("замечания: %v", codes)
+	}
+}
@@ -8,6 +8,8 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/ikermy/AiR_Common/pkg/mode"
@@ -49,11 +51,63 @@ const (
 	DialogLiveTimeout      = 180 * time.Second // Тайм-аут времени жизни диалога + секунд до сброса локальной истории сообщений
 	TxChanBuffer           = 100               // Буфер канала ответов ассистента критично для режима Streaming
 	RxChanBuffer           = 10                // Буфер канала сообщений от пользователя критично для режима когда отключенное игнорирование вопросов пользователя
+	ChanSendTimeout        = 1 * time.Second   // Ожидание свободного места в TxCh/RxCh, после которого сообщение считается потерянным
 	MaxFunctionCalls       = 10                // Лимит для предотвращения бесконечных циклов
 	SimilarEmbeddingsLimit = 3                 // Макс. количество похожих эмбеддингов для возврата при поиске в БД (можно увеличить при необходимости, но влияет на производительность
+	TypingInterval         = 4 * time.Second   // Период повтора события "typing" (индикатор набора в Telegram гаснет через 5 сек)
 	ApplayRAGTimeaut       = 15 * time.Second  // Тайм-аут для применения RAG (поиск в документах) к ответу модели, чтобы не задерживать ответ слишком долго
 )
 
+// ============================================================================
+// REASONING
+// ============================================================================
+
+// ReasoningEffort глубина рассуждений модели: выше — точнее, но медленнее и дороже.
+// OpenAI получает reasoning.effort, Gemini — thinkingConfig.thinkingBudget.
+type ReasoningEffort string
+
+const (
+	ReasoningLow    ReasoningEffort = "low"
+	ReasoningMedium ReasoningEffort = "medium"
+	ReasoningHigh   ReasoningEffort = "high"
+)
+
+// openAIReasoningPrefixes модели OpenAI, принимающие reasoning.effort (остальные отвечают 400)
+var openAIReasoningPrefixes = []string{"o1", "o3", "o4", "gpt-5"}
+
+// IsValid проверяет, что значение известно
+func (r ReasoningEffort) IsValid() bool {
+	switch r {
+	case ReasoningLow, ReasoningMedium, ReasoningHigh:
+		return true
+	}
+	return false
+}
+
+// ThinkingBudget бюджет токенов рассуждения Gemini; 0 — не задавать (решает модель)
+func (r ReasoningEffort) ThinkingBudget() int {
+	switch r {
+	case ReasoningLow:
+		return 1024
+	case ReasoningMedium:
+		return 8192
+	case ReasoningHigh:
+		return 24576
+	}
+	return 0
+}
+
+// OpenAISupportsReasoning модель OpenAI принимает параметр reasoning
+func OpenAISupportsReasoning(modelName string) bool {
+	name := strings.ToLower(modelName)
+	for _, prefix := range openAIReasoningPrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
 // ============================================================================
 // PROVIDER TYPE
 // ============================================================================
@@ -62,9 +116,14 @@ const (
 type ProviderType uint8
 
 const (
-	ProviderOpenAI  ProviderType = 1
-	ProviderMistral ProviderType = 2
-	ProviderGoogle  ProviderType = 3
+	ProviderOpenAI     ProviderType = 1
+	ProviderMistral    ProviderType = 2
+	ProviderGoogle     ProviderType = 3
+	ProviderOpenRouter ProviderType = 4 // агрегатор моделей, OpenAI-совместимый Chat Completions API
+	ProviderOllama     ProviderType = 5 // локальные модели Ollama (on-prem, без API-ключа)
+	ProviderYandex     ProviderType = 6 // YandexGPT (Yandex Cloud Foundation Models), данные в РФ
+	ProviderGigaChat   ProviderType = 7 // Sber GigaChat, данные в РФ
+	ProviderGrok       ProviderType = 8 // xAI Grok, OpenAI-совместимый Chat Completions API
 )
 
 // AllProviders содержит все зарегистрированные провайдеры в порядке добавления.
@@ -73,6 +132,11 @@ var AllProviders = []ProviderType{
 	ProviderOpenAI,
 	ProviderMistral,
 	ProviderGoogle,
+	ProviderOpenRouter,
+	ProviderOllama,
+	ProviderYandex,
+	ProviderGigaChat,
+	ProviderGrok,
 }
 
 // String возвращает строковое представление типа провайдера
@@ -84,6 +148,16 @@ func (p ProviderType) String() string {
 		return "mistral"
 	case ProviderGoogle:
 		return "google"
+	case ProviderOpenRouter:
+		return "openrouter"
+	case ProviderOllama:
+		return "ollama"
+	case ProviderYandex:
+		return "yandex"
+	case ProviderGigaChat:
+		return "gigachat"
+	case ProviderGrok:
+		return "grok"
 	default:
 		return "unknown"
 	}
@@ -98,11 +172,27 @@ func FromString(s string) (ProviderType, error) {
 		return ProviderMistral, nil
 	case "google":
 		return ProviderGoogle, nil
+	case "openrouter":
+		return ProviderOpenRouter, nil
+	case "ollama":
+		return ProviderOllama, nil
+	case "yandex":
+		return ProviderYandex, nil
+	case "gigachat":
+		return ProviderGigaChat, nil
+	case "grok":
+		return ProviderGrok, nil
 	default:
 		return 0, fmt.Errorf("неизвестный провайдер: %s", s)
 	}
 }
 
+// RequiresAPIKey провайдеру нужен персональный API-ключ пользователя; локальная
+// установка Ollama работает без ключа
+func (p ProviderType) RequiresAPIKey() bool {
+	return p != ProviderOllama
+}
+
 // FromUint8 преобразует uint8 в ProviderType
 func (p ProviderType) FromUint8(value uint8) ProviderType {
 	return ProviderType(value)
@@ -265,11 +355,19 @@ type UMCR struct {
 }
 
 type UniversalModel struct {
-	ctx           context.Context
-	openaiClient  *OpenAIAgentClient  // Клиент для работы с OpenAI
-	mistralClient *MistralAgentClient // Клиент для работы с Mistral
-	googleClient  *GoogleAgentClient  // Клиент для работы с Google
-	db            DB
+	ctx              context.Context
+	openaiClient     *OpenAIAgentClient     // Клиент для работы с OpenAI
+	mistralClient    *MistralAgentClient    // Клиент для работы с Mistral
+	googleClient     *GoogleAgentClient     // Клиент для работы с Google
+	openrouterClient *ChatCompletionsClient // Клиент для работы с OpenRouter
+	ollamaClient     *OllamaClient          // Клиент локального сервера Ollama
+	yandexClient     *YandexGPTClient       // Клиент для работы с YandexGPT
+	gigachatClient   *GigaChatClient        // Клиент для работы с GigaChat
+	grokClient       *ChatCompletionsClient // Клиент для работы с xAI Grok
+	db               DB
+
+	providersMu sync.RWMutex
+	providers   map[ProviderType]ProviderClient // Lifecycle агентов по провайдерам (RegisterProvider)
 }
 
 // New создаёт новый экземпляр UniversalModel для управления моделями
@@ -325,6 +423,37 @@ func New(ctx context.Context, db DB) *UniversalModel {
 		return ""
 	})
 
+	m.openrouterClient = NewOpenRouterClient(func(userID uint32) string {
+		if key, err := db.GetUserAPIKey(userID, ProviderOpenRouter); err == nil {
+			return key
+		}
+		return ""
+	})
+	m.ollamaClient = NewOllamaClient(mode.OllamaBaseURL)
+	m.yandexClient = NewYandexGPTClient(mode.YandexLLMURL, mode.YandexIAMURL, func(userID uint32) string {
+		if key, err := db.GetUserAPIKey(userID, ProviderYandex); err == nil {
+			return key
+		}
+		return ""
+	})
+	m.gigachatClient = NewGigaChatClient(mode.GigaChatBaseURL, mode.GigaChatOAuthURL, func(userID uint32) string {
+		if key, err := db.GetUserAPIKey(userID, ProviderGigaChat); err == nil {
+			return key
+		}
+		return ""
+	})
+	m.grokClient = NewGrokClient(func(userID uint32) string {
+		if key, err := db.GetUserAPIKey(userID, ProviderGrok); err == nil {
+			return key
+		}
+		return ""
+	})
+
+	m.providers = make(map[ProviderType]ProviderClient)
+	for _, spec := range providerSpecs() {
+		m.RegisterProvider(spec.New(m))
+	}
+
 	return m
 }
 
@@ -354,6 +483,10 @@ func (m *UniversalModel) ProvidersWithApiKeys(userID uint32) ProvidersAvailabili
 		Unavailable: make([]string, 0),
 	}
 	for _, p := range AllProviders {
+		if !p.RequiresAPIKey() {
+			result.Available = append(result.Available, p.String())
+			continue
+		}
 		key, err := m.db.GetUserAPIKey(userID, p)
 		if err == nil && key != "" {
 			result.Available = append(result.Available, p.String())
@@ -403,7 +536,7 @@ type UniversalModelData struct {
 	Name        string       `json:"name"`                   // Имя модели только для удобства идентификации
 	Prompt      string       `json:"prompt"`                 // Промпт модели
 	MetaAction  string       `json:"mact"`                   // Заданная цель модели (уведомление о достижении целы) вызывается меткой в структуре ответа "target"
-	Triggers    []string     `json:"trig"`                   // Триггеры модели
+	Triggers    []string     `json:"trig"`                   // Триггеры модели (CompileTriggers: фраза, "re:выражение", "~слово")
 	FileIds     []Ids        `json:"fileIds"`                // ID файлов для загрузки в векторное хранилище?
 	VecIds      VecIds       `json:"vecIds"`                 // ID файлов в векторном хранилище
 	Operator    bool         `json:"operator"`               // Вызов ответом от модели "operator" флаг переключения на оператора
@@ -415,15 +548,26 @@ type UniversalModelData struct {
 	WebSearch   bool         `json:"web_search"`             // Веб-поиск — нативный инструмент провайдера (google_search / web_search)
 	Realtime    bool         `json:"realtime"`               // Голосовой режим реального времени (только OpenAI Realtime API)
 	RealtimeVAD *RealtimeVAD `json:"realtime_vad,omitempty"` // Параметры VAD и генерации для Realtime режима
+	// Глубина рассуждений (OpenAI o-series/gpt-5, Gemini 2.5+); пусто — по умолчанию модели
+	Reasoning ReasoningEffort `json:"reasoning,omitempty"`
+	// Параметры генерации (temperature, top_p, top_k, лимит длины ответа); nil — по умолчанию провайдера
+	Generation *GenerationParams `json:"generation,omitempty"`
 	// Google-специфичные возможности
-	Video bool `json:"video"` // Генерация видео (Google Veo/Imagen 3) — нативный инструмент провайдера
+	Video          bool                  `json:"video"`                     // Генерация видео (Google Veo/Imagen 3) — нативный инструмент провайдера
+	ContextCache   bool                  `json:"context_cache"`             // Явный кэш контекста Gemini: промпт и база знаний загружаются один раз (cachedContents)
+	SafetySettings []GoogleSafetySetting `json:"safety_settings,omitempty"` // Пороги блокировки по категориям контента; пусто — пороги Google по умолчанию
 	// GOAuth — флаги доступа к Google OAuth сервисам (Calendar, Sheets).
 	// Используется MCP-сервером. Провайдеры получают инструменты только через FetchToolsList.
 	GOAuth GOAuth `json:"g_oauth"`
 	//////////////////////////////////
-	Espero   EsperoConfig `json:"espero"` // Настройки ожидания из ModelDataRequest.Espero
-	GptType  *GptType     `json:"gpttype"`
-	Provider ProviderType `json:"provider"` // "openai=1", "mistral=2..."
+	Espero         EsperoConfig      `json:"espero"`                    // Настройки ожидания из ModelDataRequest.Espero
+	FollowUp       *FollowUpConfig   `json:"follow_up,omitempty"`       // Напоминание при молчании пользователя
+	OperatorPolicy *OperatorConfig   `json:"operator_policy,omitempty"` // Таймаут и повторы ожидания оператора
+	Rules          []EscalationRule  `json:"rules,omitempty"`           // Правила эскалации по вопросу и ответу
+	Moderation     *ModerationConfig `json:"moderation,omitempty"`      // Модерация вопросов и ответов (Mistral moderations)
+	ResponseFields []SchemaField     `json:"response_fields,omitempty"` // Дополнительные поля ответа (lead_score, order_id) → AssistResponse.Extra
+	GptType        *GptType          `json:"gpttype"`
+	Provider       ProviderType      `json:"provider"` // "openai=1", "mistral=2..."
 }
 
 // RealtimeVAD универсальные параметры голосовой активности (VAD) и генерации.
@@ -484,6 +628,57 @@ type EsperoConfig struct {
 	Ignore bool   `json:"ignore"` // Игнорировать ожидание
 }
 
+// FollowUpConfig правило напоминания: если пользователь молчит After минут после
+// последнего сообщения ассистента, отправляется Message или ответ модели на Prompt
+type FollowUpConfig struct {
+	After   uint16 `json:"after"`            // Минут тишины до напоминания (0 — выключено)
+	Message string `json:"message"`          // Фиксированный текст напоминания
+	Prompt  string `json:"prompt,omitempty"` // Если задан — текст генерирует модель по этому промпту (Message — запасной)
+	Max     uint8  `json:"max"`              // Напоминаний подряд без ответа пользователя (0 — одно)
+}
+
+// OperatorConfig ожидание первого ответа оператора: Timeout секунд на попытку,
+// затем вопрос повторно отправляется оператору Retries раз и только потом отвечает AI
+type OperatorConfig struct {
+	Timeout    uint16 `json:"timeout"`              // Секунд на попытку (0 — mode.OperatorResponseTimeout)
+	Retries    uint8  `json:"retries"`              // Повторных попыток до перехода к AI (0 — без повторов)
+	Transcript uint8  `json:"transcript,omitempty"` // Сообщений истории для оператора (0 — mode.OperatorTranscriptTurns)
+}
+
+// ModerationConfig проверка текста модерацией провайдера: при нарушении пользователь
+// получает AssistResponse с Blocked и категориями вместо ответа модели
+type ModerationConfig struct {
+	Input  bool `json:"input"`  // Проверять вопросы пользователя до отправки модели
+	Output bool `json:"output"` // Проверять ответы модели до отправки пользователю
+}
+
+// Действия правила эскалации
+const (
+	RuleEscalate = "escalate" // передать диалог оператору
+	RuleTag      = "tag"      // пометить диалог тегом Tag
+	RuleWebhook  = "webhook"  // POST на Webhook
+	RuleMeta     = "meta"     // Meta-событие Meta (по умолчанию "trigger")
+	RuleReply    = "reply"    // готовый ответ Reply вместо ответа модели
+)
+
+// RuleActions известные действия правил
+var RuleActions = []string{RuleEscalate, RuleTag, RuleWebhook, RuleMeta, RuleReply}
+
+// EscalationRule правило: все заданные условия должны выполниться, тогда выполняются Actions.
+// Правила с ConfidenceBelow проверяются по ответу модели, остальные — по вопросу пользователя.
+type EscalationRule struct {
+	Name            string   `json:"name"`
+	Keywords        []string `json:"keywords,omitempty"`         // Любой из триггеров в вопросе (CompileTriggers: фраза, "re:", "~")
+	Regex           string   `json:"regex,omitempty"`            // Регулярное выражение по вопросу
+	SentimentBelow  *float64 `json:"sentiment_below,omitempty"`  // Тональность вопроса ниже порога (-1..1)
+	ConfidenceBelow *float64 `json:"confidence_below,omitempty"` // Уверенность модели в ответе ниже порога (0..1)
+	Actions         []string `json:"actions"`                    // RuleEscalate, RuleTag, RuleWebhook, RuleMeta, RuleReply
+	Tag             string   `json:"tag,omitempty"`
+	Webhook         string   `json:"webhook,omitempty"`
+	Meta            string   `json:"meta,omitempty"`
+	Reply           string   `json:"reply,omitempty"`
+}
+
 // UserModelsResponse представляет ответ со всеми моделями пользователя
 type UserModelsResponse struct {
 	Models         map[string]*UniversalModelData `json:"models"`          // Модели по провайдерам ("openai", "mistral")
@@ -502,16 +697,18 @@ func (m *UniversalModel) CreateModel(userID uint32, provider ProviderType, model
 		return UMCR{}, fmt.Errorf("modelData.GptType.Name не может быть пустым")
 	}
 
-	switch provider {
-	case ProviderOpenAI:
-		return m.createModel(userID, modelData, fileIDs)
-	case ProviderMistral:
-		return m.createMistralModel(userID, modelData, fileIDs)
-	case ProviderGoogle:
-		return m.createGoogleModel(userID, modelData, fileIDs)
-	default:
-		return UMCR{}, fmt.Errorf("неизвестный провайдер: %s", provider)
+	// Ошибки конфигурации отсекаем до обращения к API провайдера
+	check := *modelData
+	check.Provider = provider
+	if err := ValidateModelData(&check).Err(); err != nil {
+		return UMCR{}, err
 	}
+
+	client, err := m.Provider(provider)
+	if err != nil {
+		return UMCR{}, err
+	}
+	return client.CreateAgent(userID, modelData, fileIDs)
 }
 
 // SaveModel сохраняет модель в БД в универсальном формате
@@ -679,28 +876,13 @@ func (m *UniversalModel) DeleteModel(userID uint32, provider ProviderType, delet
 		return fmt.Errorf("модель с провайдером %s не найдена для пользователя", provider.String())
 	}
 
-	// В зависимости от провайдера удаляем модель
-	switch modelRecord.Provider {
-	case ProviderOpenAI:
-		err = m.deleteModel(userID, modelRecord, deleteFiles, progressCallback)
-		if err != nil {
-			return err
-		}
-
-	case ProviderMistral:
-		err = m.deleteMistralModel(userID, modelRecord, deleteFiles, progressCallback)
-		if err != nil {
-			return err
-		}
-
-	case ProviderGoogle:
-		err = m.deleteGoogleModel(userID, modelRecord, deleteFiles, progressCallback)
-		if err != nil {
-			return err
-		}
-
-	default:
-		return fmt.Errorf("неизвестный провайдер: %s", modelRecord.Provider)
+	// Удаляем агента у провайдера
+	client, err := m.Provider(modelRecord.Provider)
+	if err != nil {
+		return err
+	}
+	if err = client.DeleteAgent(userID, modelRecord, deleteFiles, progressCallback); err != nil {
+		return err
 	}
 
 	// Удаляем связь из user_models
@@ -827,20 +1009,19 @@ func (m *UniversalModel) UpdateModelEveryWhere(userID uint32, data *UniversalMod
 		return fmt.Errorf("нельзя изменить провайдера модели (было: %s, стало: %s)", existing.Provider, data.Provider)
 	}
 
-	// Обновляем в зависимости от провайдера
-	switch data.Provider {
-	case ProviderOpenAI:
-		return m.updateOpenAIModelInPlace(userID, existing, data)
-
-	case ProviderMistral:
-		return m.updateMistralModelInPlace(userID, existing, data)
-
-	case ProviderGoogle:
-		return m.updateGoogleModelInPlace(userID, existing, data)
+	check := *data
+	if check.GptType == nil {
+		check.GptType = existing.GptType
+	}
+	if err := ValidateModelData(&check).Err(); err != nil {
+		return err
+	}
 
-	default:
-		return fmt.Errorf("неизвестный провайдер: %s", data.Provider)
+	client, err := m.Provider(data.Provider)
+	if err != nil {
+		return err
 	}
+	return client.UpdateAgent(userID, existing, data)
 }
 
 // ============================================================================
@@ -1167,111 +1348,3 @@ func applyRealtimeVADDefaults(vad *RealtimeVAD) *RealtimeVAD {
 
 	return vad
 }
-
-// ParseModelSchemaJSON парсит статическую JSON Schema в map[string]any
-// для использования в response_schema (Google) и json_schema.schema (OpenAI)
-// Универсальный метод для обоих провайдеров
-// ПРИМЕЧАНИЕ: Эта статическая схема используется только для некоторых случаев.
-// OpenAI модели используют динамическую схему из generateModelSchema (open.go)
-func ParseModelSchemaJSON(includeAdditionalProperties bool) map[string]any {
-	// Базовая схема БЕЗ additionalProperties (для Google)
-	var modelSchemaJSON string
-
-	if includeAdditionalProperties {
-		// Для OpenAI - С additionalProperties
-		modelSchemaJSON = `{
-		"type": "object",
-		"properties": {
-			"message": {
-				"type": "string"
-			},
-			"action": {
-				"type": "object",
-				"properties": {
-					"send_files": {
-						"type": "array",
-						"default": [],
-						"items": {
-							"type": "object",
-							"properties": {
-								"type": {
-									"type": "string",
-									"enum": ["photo", "video", "audio", "doc"]
-								},
-								"url": {
-									"type": "string"
-								},
-								"file_name": {
-									"type": "string"
-								},
-								"caption": {
-									"type": "string"
-								}
-							},
-							"required": ["type", "url", "file_name", "caption"],
-							"additionalProperties": false
-						}
-					}
-				},
-				"required": ["send_files"],
-				"additionalProperties": false
-			},
-			"target": { "type": "boolean" },
-			"operator": { "type": "boolean" }
-		},
-		"required": ["message", "action", "target", "operator"],
-		"additionalProperties": false
-	}`
-	} else {
-		// Для Google - БЕЗ additionalProperties (Google не поддерживает это поле)
-		modelSchemaJSON = `{
-		"type": "object",
-		"properties": {
-			"message": {
-				"type": "string"
-			},
-			"action": {
-				"type": "object",
-				"properties": {
-					"send_files": {
-						"type": "array",
-						"default": [],
-						"items": {
-							"type": "object",
-							"properties": {
-								"type": {
-									"type": "string",
-									"enum": ["photo", "video", "audio", "doc"]
-								},
-								"url": {
-									"type": "string"
-								},
-								"file_name": {
-									"type": "string"
-								},
-								"caption": {
-									"type": "string"
-								}
-							},
-							"required": ["type", "url", "file_name", "caption"]
-						}
-					}
-				},
-				"required": ["send_files"]
-			},
-			"target": { "type": "boolean" },
-			"operator": { "type": "boolean" }
-		},
-		"required": ["message", "action", "target", "operator"]
-	}`
-	}
-
-	var schema map[string]any
-	err := json.Unmarshal([]byte(modelSchemaJSON), &schema)
-	if err != nil {
-		// Это не должно произойти, т.к. modelSchemaJSON - валидный JSON
-		//logger.Error("[ParseModelSchemaJSON] Ошибка парсинга ModelSchemaJSON: %v", err)
-		return map[string]any{} // Возвращаем пустую схему в крайнем случае
-	}
-	return schema
-}
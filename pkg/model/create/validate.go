@@ -0,0 +1,247 @@
+package create
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Уровни ValidationIssue
+const (
+	SeverityWarning = "warning" // модель будет создана, но флаг не сработает или сработает не так
+	SeverityError   = "error"   // провайдер отклонит модель или она не сможет отвечать
+)
+
+// ValidationIssue замечание ValidateModelData
+type ValidationIssue struct {
+	Severity string `json:"severity"` // SeverityWarning, SeverityError
+	Field    string `json:"field"`    // поле UniversalModelData (json-имя)
+	Code     string `json:"code"`     // машинный код замечания
+	Message  string `json:"message"`
+}
+
+func (i ValidationIssue) Error() string {
+	return fmt.Sprintf("%s: %s", i.Field, i.Message)
+}
+
+// ValidationIssues замечания проверки модели
+type ValidationIssues []ValidationIssue
+
+// HasErrors есть замечания уровня SeverityError
+func (v ValidationIssues) HasErrors() bool {
+	for _, i := range v {
+		if i.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// Err ошибка со всеми SeverityError (nil, если их нет)
+func (v ValidationIssues) Err() error {
+	var msgs []string
+	for _, i := range v {
+		if i.Severity == SeverityError {
+			msgs = append(msgs, i.Error())
+		}
+	}
+	if len(msgs) == 0 {
+		return nil
+	}
+	return fmt.Errorf("некорректная конфигурация модели: %s", strings.Join(msgs, "; "))
+}
+
+// contextWindows размер контекста моделей в токенах по префиксу имени (длинные префиксы первыми)
+var contextWindows = []struct {
+	prefix string
+	tokens int
+}{
+	{"gemini-1.5-pro", 2_097_152},
+	{"gemini-", 1_048_576},
+	{"gpt-4.1", 1_047_576},
+	{"gpt-5", 400_000},
+	{"gpt-4o", 128_000},
+	{"o3", 200_000},
+	{"o4", 200_000},
+	{"gpt-3.5", 16_385},
+	{"mistral-large", 131_072},
+	{"mistral-medium", 131_072},
+	{"mistral-small", 131_072},
+	{"magistral", 40_000},
+	{"codestral", 262_144},
+	{"ministral", 131_072},
+	{"open-mistral-nemo", 131_072},
+	{"pixtral", 131_072},
+}
+
+// promptShareLimit доля контекста, которую может занимать промпт: остальное — история и RAG
+const promptShareLimit = 0.5
+
+// ContextWindow размер контекста модели в токенах; 0 — модель неизвестна
+func ContextWindow(modelName string) int {
+	name := strings.ToLower(modelName)
+	for _, w := range contextWindows {
+		if strings.HasPrefix(name, w.prefix) {
+			return w.tokens
+		}
+	}
+	return 0
+}
+
+// ValidateModelData проверяет конфигурацию модели до обращения к API провайдера:
+// длину промпта относительно контекста модели, несовместимые флаги и возможности,
+// которых нет у провайдера. Ничего не меняет и не вызывает сеть (dry-run).
+func ValidateModelData(modelData *UniversalModelData) ValidationIssues {
+	var issues ValidationIssues
+	add := func(severity, field, code, format string, args ...any) {
+		issues = append(issues, ValidationIssue{Severity: severity, Field: field, Code: code, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if modelData == nil {
+		add(SeverityError, "", "nil", "modelData не может быть nil")
+		return issues
+	}
+	if modelData.GptType == nil || modelData.GptType.Name == "" {
+		add(SeverityError, "gpttype", "model_required", "не задано имя модели провайдера")
+	}
+	provider := modelData.Provider
+	caps, ok := ProviderCapabilities(provider)
+	if !ok {
+		add(SeverityError, "provider", "unknown_provider", "неизвестный провайдер: %s", provider)
+		return issues
+	}
+	if modelData.GptType != nil && strings.HasPrefix(modelData.GptType.Name, "tunedModels/") && provider != ProviderGoogle {
+		add(SeverityError, "gpttype", "tuned_model_provider", "дообученная модель %s доступна только провайдеру Google", modelData.GptType.Name)
+	}
+
+	// Промпт
+	if strings.TrimSpace(modelData.Prompt) == "" {
+		add(SeverityWarning, "prompt", "prompt_empty", "пустой промпт: модель ответит без инструкций")
+	} else if modelData.GptType != nil {
+		if window := ContextWindow(modelData.GptType.Name); window > 0 {
+			tokens := EstimateTokens(modelData.Prompt, modelData.GptType.Name)
+			switch {
+			case tokens >= window:
+				add(SeverityError, "prompt", "prompt_too_long", "промпт ~%d токенов не помещается в контекст %s (%d)", tokens, modelData.GptType.Name, window)
+			case float64(tokens) > float64(window)*promptShareLimit:
+				add(SeverityWarning, "prompt", "prompt_large", "промпт ~%d токенов занимает больше половины контекста %s (%d)", tokens, modelData.GptType.Name, window)
+			}
+		}
+	}
+
+	// Возможности, которых нет у провайдера (ProviderClient.Capabilities)
+	for _, c := range []struct {
+		enabled, supported bool
+		field, what        string
+	}{
+		{modelData.Interpreter, caps.Interpreter, "interpreter", "Code Interpreter"},
+		{modelData.Image, caps.Image, "image", "генерация изображений"},
+		{modelData.WebSearch, caps.WebSearch, "web_search", "веб-поиск"},
+		{modelData.Video, caps.Video, "video", "генерация видео"},
+		{modelData.Realtime, caps.Realtime, "realtime", "голосовой режим реального времени"},
+		{len(modelData.FileIds) > 0, caps.VectorSearch, "fileIds", "поиск по файлам"},
+	} {
+		if c.enabled && !c.supported {
+			add(SeverityWarning, c.field, "unsupported", "возможность «%s» недоступна для провайдера %s", c.what, provider)
+		}
+	}
+	if modelData.RealtimeVAD != nil && !modelData.Realtime {
+		add(SeverityWarning, "realtime_vad", "unused", "параметры VAD заданы, но голосовой режим выключен")
+	}
+	if modelData.RealtimeVAD != nil && modelData.RealtimeVAD.Google != nil && provider != ProviderGoogle {
+		add(SeverityWarning, "realtime_vad.google", "unused", "параметры Google VAD игнорируются для провайдера %s", provider)
+	}
+
+	// Глубина рассуждений: OpenAI reasoning.effort, Gemini thinkingConfig
+	if modelData.Reasoning != "" {
+		switch {
+		case !modelData.Reasoning.IsValid():
+			add(SeverityError, "reasoning", "unknown_value", "неизвестная глубина рассуждений %q", modelData.Reasoning)
+		case provider != ProviderOpenAI && provider != ProviderGoogle:
+			add(SeverityWarning, "reasoning", "unused", "reasoning игнорируется для провайдера %s", provider)
+		case provider == ProviderOpenAI && modelData.GptType != nil && !OpenAISupportsReasoning(modelData.GptType.Name):
+			add(SeverityWarning, "reasoning", "unsupported", "модель %s не поддерживает reasoning, параметр не будет передан", modelData.GptType.Name)
+		}
+	}
+
+	validateGeneration(modelData.Generation, provider, modelData.ResponseFields, add)
+
+	// Модерация выполняется только для Mistral (moderations API)
+	if m := modelData.Moderation; m != nil && (m.Input || m.Output) && provider != ProviderMistral {
+		add(SeverityWarning, "moderation", "unsupported", "модерация недоступна для провайдера %s", provider)
+	}
+
+	// Пороги безопасности Gemini: неизвестную категорию или порог API отклонит
+	if len(modelData.SafetySettings) > 0 && provider != ProviderGoogle {
+		add(SeverityWarning, "safety_settings", "unused", "safety_settings игнорируются для провайдера %s", provider)
+	}
+	for _, setting := range modelData.SafetySettings {
+		if !slices.Contains(GoogleHarmCategories, setting.Category) {
+			add(SeverityError, "safety_settings", "unknown_category", "неизвестная категория %q", setting.Category)
+		}
+		if !slices.Contains(GoogleHarmThresholds, setting.Threshold) {
+			add(SeverityError, "safety_settings", "unknown_threshold", "неизвестный порог %q для %s", setting.Threshold, setting.Category)
+		}
+	}
+
+	// Триггеры: некорректное выражение "re:" правило не сработает никогда
+	if _, err := CompileTriggers(modelData.Triggers); err != nil {
+		add(SeverityError, "trig", "invalid_trigger", "%v", err)
+	}
+
+	// Правила и триггеры с действиями
+	for _, r := range modelData.Rules {
+		field := "rules." + r.Name
+		if _, err := CompileTriggers(r.Keywords); err != nil {
+			add(SeverityError, field, "invalid_trigger", "%v", err)
+		}
+		if r.Regex != "" {
+			if _, err := regexp.Compile(r.Regex); err != nil {
+				add(SeverityError, field, "invalid_regex", "некорректное выражение %q: %v", r.Regex, err)
+			}
+		}
+		if len(r.Actions) == 0 {
+			add(SeverityWarning, field, "no_actions", "у правила %q нет действий", r.Name)
+		}
+		for _, action := range r.Actions {
+			switch {
+			case !slices.Contains(RuleActions, action):
+				add(SeverityError, field, "unknown_action", "неизвестное действие %q правила %q", action, r.Name)
+			case action == RuleTag && r.Tag == "", action == RuleWebhook && r.Webhook == "", action == RuleReply && r.Reply == "":
+				add(SeverityWarning, field, "action_incomplete", "действие %q правила %q не задано и будет пропущено", action, r.Name)
+			}
+		}
+	}
+
+	// Дополнительные поля ответа: имя не должно совпадать с полями AssistResponse
+	seen := make(map[string]bool, len(modelData.ResponseFields))
+	for _, f := range modelData.ResponseFields {
+		switch {
+		case f.Name == "":
+			add(SeverityError, "response_fields", "name_required", "не задано имя дополнительного поля ответа")
+		case IsReservedResponseField(f.Name):
+			add(SeverityError, "response_fields", "reserved_name", "поле %q зарезервировано ответом модели", f.Name)
+		case seen[f.Name]:
+			add(SeverityError, "response_fields", "duplicate_name", "поле %q объявлено повторно", f.Name)
+		}
+		seen[f.Name] = true
+		if !slices.Contains(schemaFieldTypes, f.Type) {
+			add(SeverityError, "response_fields", "unknown_type", "неизвестный тип %q поля %q (string, boolean, number, integer)", f.Type, f.Name)
+		}
+	}
+
+	// Несовместимые флаги Google: инструменты MCP (S3, календарь, таблицы) передаются как
+	// function_declarations, с ними Gemini не принимает code_execution, а любые инструменты
+	// отключают response_schema
+	if provider == ProviderGoogle {
+		mcpTools := modelData.S3 || modelData.GOAuth.Enabled()
+		if modelData.Interpreter && mcpTools {
+			add(SeverityWarning, "interpreter", "incompatible", "Google не совмещает Code Interpreter с функциями S3/Google OAuth: интерпретатор будет отключён")
+		}
+		if modelData.WebSearch || modelData.Interpreter || mcpTools {
+			add(SeverityWarning, "tools", "schema_disabled", "с инструментами Google не применяет response_schema: формат ответа задаётся только инструкцией")
+		}
+	}
+	return issues
+}
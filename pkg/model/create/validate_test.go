@@ -0,0 +1,139 @@
+package create
+
+import (
+	"strings"
+	"testing"
+)
+
+// promptOfTokens промпт, который EstimateTokens оценивает не меньше чем в n токенов модели modelName
+func promptOfTokens(n int, modelName string) string {
+	chunk := strings.Repeat("инструкция ", 1000)
+	per := EstimateTokens(chunk, modelName)
+	return strings.Repeat(chunk, n/per+1)
+}
+
+func TestValidateModelData(t *testing.T) {
+	mistral := func(edit func(d *UniversalModelData)) *UniversalModelData {
+		d := &UniversalModelData{Prompt: "Ты консультант", Provider: ProviderMistral, GptType: &GptType{Name: "mistral-small-latest"}}
+		if edit != nil {
+			edit(d)
+		}
+		return d
+	}
+	google := func(edit func(d *UniversalModelData)) *UniversalModelData {
+		d := &UniversalModelData{Prompt: "Ты консультант", Provider: ProviderGoogle, GptType: &GptType{Name: "gemini-2.5-flash"}}
+		edit(d)
+		return d
+	}
+
+	cases := []struct {
+		name     string
+		data     *UniversalModelData
+		severity string
+		field    string
+		code     string
+	}{
+		{"nil", nil, SeverityError, "", "nil"},
+		{"без имени модели", mistral(func(d *UniversalModelData) { d.GptType = nil }), SeverityError, "gpttype", "model_required"},
+		{"неизвестный провайдер", mistral(func(d *UniversalModelData) { d.Provider = ProviderType(240) }), SeverityError, "provider", "unknown_provider"},
+		{"дообученная модель не Google", mistral(func(d *UniversalModelData) { d.GptType.Name = "tunedModels/shop" }), SeverityError, "gpttype", "tuned_model_provider"},
+		{"пустой промпт", mistral(func(d *UniversalModelData) { d.Prompt = " \n" }), SeverityWarning, "prompt", "prompt_empty"},
+		{"промпт больше половины контекста", mistral(func(d *UniversalModelData) {
+			d.GptType.Name = "magistral-medium"
+			d.Prompt = promptOfTokens(25_000, "magistral-medium")
+		}), SeverityWarning, "prompt", "prompt_large"},
+		{"промпт не помещается в контекст", mistral(func(d *UniversalModelData) {
+			d.GptType.Name = "magistral-medium"
+			d.Prompt = promptOfTokens(45_000, "magistral-medium")
+		}), SeverityError, "prompt", "prompt_too_long"},
+		{"возможность не поддерживается", mistral(func(d *UniversalModelData) { d.Video = true }), SeverityWarning, "video", "unsupported"},
+		{"VAD без голосового режима", mistral(func(d *UniversalModelData) { d.RealtimeVAD = &RealtimeVAD{} }), SeverityWarning, "realtime_vad", "unused"},
+		{"неизвестная глубина рассуждений", mistral(func(d *UniversalModelData) { d.Reasoning = "max" }), SeverityError, "reasoning", "unknown_value"},
+		{"рассуждения не у OpenAI и Google", mistral(func(d *UniversalModelData) { d.Reasoning = ReasoningHigh }), SeverityWarning, "reasoning", "unused"},
+		{"рассуждения у модели без reasoning", &UniversalModelData{
+			Prompt: "p", Provider: ProviderOpenAI, GptType: &GptType{Name: "gpt-4o-mini"}, Reasoning: ReasoningLow,
+		}, SeverityWarning, "reasoning", "unsupported"},
+		{"модерация не у Mistral", google(func(d *UniversalModelData) { d.Moderation = &ModerationConfig{Input: true} }), SeverityWarning, "moderation", "unsupported"},
+		{"safety_settings не у Google", mistral(func(d *UniversalModelData) {
+			d.SafetySettings = []GoogleSafetySetting{{Category: "HARM_CATEGORY_HARASSMENT", Threshold: "BLOCK_NONE"}}
+		}), SeverityWarning, "safety_settings", "unused"},
+		{"неизвестная категория safety", google(func(d *UniversalModelData) {
+			d.SafetySettings = []GoogleSafetySetting{{Category: "HARM_CATEGORY_SPAM", Threshold: "BLOCK_NONE"}}
+		}), SeverityError, "safety_settings", "unknown_category"},
+		{"неизвестный порог safety", google(func(d *UniversalModelData) {
+			d.SafetySettings = []GoogleSafetySetting{{Category: "HARM_CATEGORY_HARASSMENT", Threshold: "BLOCK_ALL"}}
+		}), SeverityError, "safety_settings", "unknown_threshold"},
+		{"некорректное выражение правила", mistral(func(d *UniversalModelData) {
+			d.Rules = []EscalationRule{{Name: "r", Regex: "(", Actions: []string{RuleEscalate}}}
+		}), SeverityError, "rules.r", "invalid_regex"},
+		{"правило без действий", mistral(func(d *UniversalModelData) {
+			d.Rules = []EscalationRule{{Name: "r", Keywords: []string{"жалоба"}}}
+		}), SeverityWarning, "rules.r", "no_actions"},
+		{"поле ответа без имени", mistral(func(d *UniversalModelData) {
+			d.ResponseFields = []SchemaField{{Type: "string"}}
+		}), SeverityError, "response_fields", "name_required"},
+		{"зарезервированное поле ответа", mistral(func(d *UniversalModelData) {
+			d.ResponseFields = []SchemaField{{Name: "message", Type: "string"}}
+		}), SeverityError, "response_fields", "reserved_name"},
+		{"повторное поле ответа", mistral(func(d *UniversalModelData) {
+			d.ResponseFields = []SchemaField{{Name: "order_id", Type: "string"}, {Name: "order_id", Type: "string"}}
+		}), SeverityError, "response_fields", "duplicate_name"},
+		{"неизвестный тип поля ответа", mistral(func(d *UniversalModelData) {
+			d.ResponseFields = []SchemaField{{Name: "items", Type: "array"}}
+		}), SeverityError, "response_fields", "unknown_type"},
+		{"Google: интерпретатор с функциями MCP", google(func(d *UniversalModelData) {
+			d.Interpreter, d.S3 = true, true
+		}), SeverityWarning, "interpreter", "incompatible"},
+		{"Google: инструменты отключают схему", google(func(d *UniversalModelData) { d.WebSearch = true }), SeverityWarning, "tools", "schema_disabled"},
+	}
+	for _, c := range cases {
+		issues := ValidateModelData(c.data)
+		found := false
+		for _, i := range issues {
+			if i.Field == c.field && i.Code == c.code && i.Severity == c.severity {
+				found = true
+				break
+			}
+		}
+		if !found {
+			t.Errorf("%s: нет замечания %s %s/%s среди %v", c.name, c.severity, c.field, c.code, issues)
+		}
+		if c.severity == SeverityError && (!issues.HasErrors() || issues.Err() == nil) {
+			t.Errorf("%s: ошибка не отражена в HasErrors/Err", c.name)
+		}
+	}
+}
+
+func TestValidateModelData_Clean(t *testing.T) {
+	for _, data := range []*UniversalModelData{
+		{Prompt: "Ты консультант", Provider: ProviderMistral, GptType: &GptType{Name: "mistral-small-latest"},
+			Moderation: &ModerationConfig{Input: true}, ResponseFields: []SchemaField{{Name: "order_id", Type: "string"}}},
+		{Prompt: "Ты консультант", Provider: ProviderOpenAI, GptType: &GptType{Name: "gpt-5-mini"},
+			Reasoning: ReasoningMedium, Interpreter: true, WebSearch: true},
+		{Prompt: "Ты консультант", Provider: ProviderGoogle, GptType: &GptType{Name: "tunedModels/shop"},
+			SafetySettings: []GoogleSafetySetting{{Category: "HARM_CATEGORY_HARASSMENT", Threshold: "BLOCK_NONE"}}},
+	} {
+		if issues := ValidateModelData(data); len(issues) != 0 || issues.Err() != nil {
+			t.Errorf("%s %s: лишние замечания %v", data.Provider, data.GptType.Name, issues)
+		}
+	}
+}
+
+func TestContextWindow(t *testing.T) {
+	cases := []struct {
+		model string
+		want  int
+	}{
+		{"gemini-1.5-pro-002", 2_097_152}, // длинный префикс раньше общего gemini-
+		{"Gemini-2.5-Flash", 1_048_576},
+		{"gpt-4.1-mini", 1_047_576},
+		{"gpt-4o", 128_000},
+		{"magistral-small-latest", 40_000},
+		{"llama3", 0},
+	}
+	for _, c := range cases {
+		if got := ContextWindow(c.model); got != c.want {
+			t.Errorf("ContextWindow(%q) = %d, want %d", c.model, got, c.want)
+		}
+	}
+}
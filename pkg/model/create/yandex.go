@@ -0,0 +1,435 @@
+package create
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ikermy/AiR_Common/pkg/providererr"
+)
+
+// yandexIAMRefreshBefore за сколько до истечения IAM-токен запрашивается заново
+const yandexIAMRefreshBefore = 10 * time.Minute
+
+// YandexGPTModels модели YandexGPT (у Foundation Models API нет метода списка моделей)
+var YandexGPTModels = []string{
+	"yandexgpt/latest",
+	"yandexgpt/rc",
+	"yandexgpt-lite/latest",
+	"yandexgpt-lite/rc",
+}
+
+// YandexGPTClient клиент Yandex Cloud Foundation Models API (completion, textEmbedding).
+// Ключ пользователя хранится в БД в виде "<folder_id>:<credential>": API-ключ сервисного
+// аккаунта (AQVN...) передаётся как Api-Key, иначе credential — OAuth-токен, который
+// обменивается на IAM-токен и обновляется до истечения срока.
+type YandexGPTClient struct {
+	url         string
+	iamURL      string
+	httpClient  *http.Client
+	keyResolver func(userID uint32) string
+
+	iamMu     sync.Mutex
+	iamTokens map[string]yandexIAMToken // OAuth-токен -> IAM-токен (под iamMu)
+	iamLocks  tokenLocks                // обмен OAuth-токена на IAM-токен
+}
+
+type yandexIAMToken struct {
+	token     string
+	expiresAt time.Time
+}
+
+// NewYandexGPTClient создаёт клиент; ключ пользователя читается через keyResolver
+// (db.GetUserAPIKey(userID, ProviderYandex))
+func NewYandexGPTClient(baseURL, iamURL string, keyResolver func(userID uint32) string) *YandexGPTClient {
+	return &YandexGPTClient{
+		url:         strings.TrimRight(baseURL, "/"),
+		iamURL:      iamURL,
+		httpClient:  &http.Client{Timeout: 120 * time.Second},
+		keyResolver: keyResolver,
+		iamTokens:   make(map[string]yandexIAMToken),
+	}
+}
+
+// ParseYandexKey разбирает ключ "<folder_id>:<credential>"
+func ParseYandexKey(key string) (folderID, credential string, err error) {
+	folderID, credential, ok := strings.Cut(strings.TrimSpace(key), ":")
+	if !ok || folderID == "" || credential == "" {
+		return "", "", fmt.Errorf("ключ YandexGPT должен иметь вид <folder_id>:<API-ключ или OAuth-токен>")
+	}
+	return folderID, credential, nil
+}
+
+// HasAPIKey возвращает true если для пользователя задан корректный ключ
+func (c *YandexGPTClient) HasAPIKey(userID uint32) bool {
+	if c.keyResolver == nil || userID == 0 {
+		return false
+	}
+	_, _, err := ParseYandexKey(c.keyResolver(userID))
+	return err == nil
+}
+
+// credentials каталог и заголовок Authorization для пользователя
+func (c *YandexGPTClient) credentials(ctx context.Context, userID uint32) (folderID, auth string, err error) {
+	if c.keyResolver == nil || userID == 0 {
+		return "", "", fmt.Errorf("API ключ YandexGPT не настроен для пользователя %d", userID)
+	}
+	folderID, credential, err := ParseYandexKey(c.keyResolver(userID))
+	if err != nil {
+		return "", "", err
+	}
+	if strings.HasPrefix(credential, "AQVN") {
+		return folderID, "Api-Key " + credential, nil
+	}
+	token, err := c.iamToken(ctx, credential)
+	if err != nil {
+		return "", "", err
+	}
+	return folderID, "Bearer " + token, nil
+}
+
+// iamToken IAM-токен для OAuth-токена; кэшируется до истечения срока минус yandexIAMRefreshBefore
+func (c *YandexGPTClient) iamToken(ctx context.Context, oauthToken string) (string, error) {
+	if token, ok := c.cachedIAMToken(oauthToken); ok {
+		return token, nil
+	}
+	// Запрос к IAM идёт без iamMu: ждут только запросы с тем же OAuth-токеном
+	unlock := c.iamLocks.lock(oauthToken)
+	defer unlock()
+	if token, ok := c.cachedIAMToken(oauthToken); ok {
+		return token, nil
+	}
+
+	data, err := json.Marshal(map[string]string{"yandexPassportOauthToken": oauthToken})
+	if err != nil {
+		return "", fmt.Errorf("ошибка сериализации запроса IAM: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.iamURL, bytes.NewReader(data))
+	if err != nil {
+		return "", fmt.Errorf("ошибка создания запроса IAM: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("ошибка получения IAM-токена: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("ошибка чтения ответа IAM: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", providererr.New("IAM", resp.StatusCode, resp.Header, body)
+	}
+	var out struct {
+		IamToken  string    `json:"iamToken"`
+		ExpiresAt time.Time `json:"expiresAt"`
+	}
+	if err := json.Unmarshal(body, &out); err != nil {
+		return "", fmt.Errorf("ошибка парсинга ответа IAM: %w", err)
+	}
+	if out.IamToken == "" {
+		return "", fmt.Errorf("IAM API вернул пустой токен")
+	}
+	c.iamMu.Lock()
+	c.iamTokens[oauthToken] = yandexIAMToken{token: out.IamToken, expiresAt: out.ExpiresAt}
+	c.iamMu.Unlock()
+	return out.IamToken, nil
+}
+
+// cachedIAMToken IAM-токен из кэша, если до истечения больше yandexIAMRefreshBefore
+func (c *YandexGPTClient) cachedIAMToken(oauthToken string) (string, bool) {
+	c.iamMu.Lock()
+	defer c.iamMu.Unlock()
+	if t, ok := c.iamTokens[oauthToken]; ok && time.Until(t.expiresAt) > yandexIAMRefreshBefore {
+		return t.token, true
+	}
+	return "", false
+}
+
+// do выполняет запрос к Foundation Models API от имени пользователя
+func (c *YandexGPTClient) do(ctx context.Context, path string, userID uint32, build func(folderID string) any) ([]byte, error) {
+	folderID, auth, err := c.credentials(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(build(folderID))
+	if err != nil {
+		return nil, fmt.Errorf("ошибка сериализации запроса: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url+path, bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("ошибка создания HTTP запроса: %w", err)
+	}
+	req.Header.Set("Authorization", auth)
+	req.Header.Set("x-folder-id", folderID)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка HTTP запроса к YandexGPT: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения ответа YandexGPT: %w", err)
+	}
+	if resp.StatusCode >= 400 {
+		return nil, providererr.New("YandexGPT", resp.StatusCode, resp.Header, body)
+	}
+	return body, nil
+}
+
+// yandexModelURI полный URI модели: "yandexgpt/latest" -> "gpt://<folder>/yandexgpt/latest"
+func yandexModelURI(scheme, folderID, name string) string {
+	if strings.Contains(name, "://") {
+		return name
+	}
+	return scheme + "://" + folderID + "/" + name
+}
+
+// yandexMessage сообщение completion: текст, вызовы функций или их результаты
+type yandexMessage struct {
+	Role           string                `json:"role"`
+	Text           string                `json:"text,omitempty"`
+	ToolCallList   *yandexToolCallList   `json:"toolCallList,omitempty"`
+	ToolResultList *yandexToolResultList `json:"toolResultList,omitempty"`
+}
+
+type yandexToolCallList struct {
+	ToolCalls []yandexToolCall `json:"toolCalls"`
+}
+
+// yandexToolCall вызов функции; аргументы — JSON-объект, а не строка
+type yandexToolCall struct {
+	FunctionCall struct {
+		Name      string          `json:"name"`
+		Arguments json.RawMessage `json:"arguments"`
+	} `json:"functionCall"`
+}
+
+type yandexToolResultList struct {
+	ToolResults []yandexToolResult `json:"toolResults"`
+}
+
+type yandexToolResult struct {
+	FunctionResult struct {
+		Name    string `json:"name"`
+		Content string `json:"content"`
+	} `json:"functionResult"`
+}
+
+// Complete выполняет запрос completion; ответ приводится к формату Chat Completions
+func (c *YandexGPTClient) Complete(ctx context.Context, userID uint32, req ChatRequest) (ChatResponse, error) {
+	messages := yandexMessages(req.Messages)
+	body, err := c.do(ctx, "/completion", userID, func(folderID string) any {
+		payload := map[string]any{
+			"modelUri": yandexModelURI("gpt", folderID, req.Model),
+			"messages": messages,
+		}
+		options := map[string]any{"stream": false}
+		if req.Temperature != nil {
+			options["temperature"] = *req.Temperature
+		}
+		if req.MaxTokens > 0 {
+			options["maxTokens"] = strconv.Itoa(req.MaxTokens)
+		}
+		payload["completionOptions"] = options
+		if len(req.Tools) > 0 {
+			tools := make([]map[string]any, 0, len(req.Tools))
+			for _, t := range req.Tools {
+				tools = append(tools, map[string]any{"function": t.Function})
+			}
+			payload["tools"] = tools
+		}
+		switch req.ResponseFormat["type"] {
+		case "json_schema":
+			if js, ok := req.ResponseFormat["json_schema"].(map[string]any); ok {
+				payload["jsonSchema"] = map[string]any{"schema": js["schema"]}
+			}
+		case "json_object":
+			payload["jsonObject"] = true
+		}
+		return payload
+	})
+	if err != nil {
+		return ChatResponse{}, err
+	}
+
+	var out struct {
+		Result struct {
+			Alternatives []struct {
+				Message yandexMessage `json:"message"`
+				Status  string        `json:"status"`
+			} `json:"alternatives"`
+			Usage struct {
+				InputTextTokens  int `json:"inputTextTokens,string"`
+				CompletionTokens int `json:"completionTokens,string"`
+				TotalTokens      int `json:"totalTokens,string"`
+			} `json:"usage"`
+		} `json:"result"`
+	}
+	if err := json.Unmarshal(body, &out); err != nil {
+		return ChatResponse{}, fmt.Errorf("ошибка парсинга ответа YandexGPT: %w", err)
+	}
+	if len(out.Result.Alternatives) == 0 {
+		return ChatResponse{}, fmt.Errorf("получен пустой ответ от YandexGPT")
+	}
+	alt := out.Result.Alternatives[0]
+
+	message := ChatMessage{Role: "assistant", Content: alt.Message.Text}
+	finish := "stop"
+	if alt.Message.ToolCallList != nil {
+		for i, call := range alt.Message.ToolCallList.ToolCalls {
+			var tc ChatToolCall
+			// YandexGPT не присваивает вызовам идентификаторы — результат сопоставляется по имени функции
+			tc.ID = fmt.Sprintf("call_%d", i)
+			tc.Type = "function"
+			tc.Function.Name = call.FunctionCall.Name
+			tc.Function.Arguments = string(call.FunctionCall.Arguments)
+			message.ToolCalls = append(message.ToolCalls, tc)
+		}
+		if len(message.ToolCalls) > 0 {
+			finish = "tool_calls"
+		}
+	}
+	return ChatResponse{
+		Message:      message,
+		FinishReason: finish,
+		Usage: ChatUsage{
+			PromptTokens:     out.Result.Usage.InputTextTokens,
+			CompletionTokens: out.Result.Usage.CompletionTokens,
+			TotalTokens:      out.Result.Usage.TotalTokens,
+		},
+	}, nil
+}
+
+// yandexMessages переводит историю Chat Completions в сообщения completion: content parts
+// склеиваются в текст (изображения YandexGPT не принимает), подряд идущие результаты
+// функций объединяются в одно сообщение toolResultList
+func yandexMessages(messages []ChatMessage) []yandexMessage {
+	result := make([]yandexMessage, 0, len(messages))
+	for _, msg := range messages {
+		if msg.Role == "tool" {
+			var tr yandexToolResult
+			tr.FunctionResult.Name = msg.Name
+			tr.FunctionResult.Content, _ = msg.Content.(string)
+			if n := len(result); n > 0 && result[n-1].ToolResultList != nil {
+				result[n-1].ToolResultList.ToolResults = append(result[n-1].ToolResultList.ToolResults, tr)
+			} else {
+				result = append(result, yandexMessage{Role: "user", ToolResultList: &yandexToolResultList{ToolResults: []yandexToolResult{tr}}})
+			}
+			continue
+		}
+
+		ym := yandexMessage{Role: msg.Role, Text: chatMessageText(msg.Content)}
+		if len(msg.ToolCalls) > 0 {
+			calls := &yandexToolCallList{}
+			for _, call := range msg.ToolCalls {
+				var tc yandexToolCall
+				tc.FunctionCall.Name = call.Function.Name
+				tc.FunctionCall.Arguments = json.RawMessage(call.Function.Arguments)
+				if !json.Valid(tc.FunctionCall.Arguments) {
+					tc.FunctionCall.Arguments = json.RawMessage("{}")
+				}
+				calls.ToolCalls = append(calls.ToolCalls, tc)
+			}
+			ym.ToolCallList = calls
+		}
+		result = append(result, ym)
+	}
+	return result
+}
+
+// chatMessageText текст сообщения: строка или склеенные text-части
+func chatMessageText(content any) string {
+	switch v := content.(type) {
+	case string:
+		return v
+	case []any:
+		var texts []string
+		for _, part := range v {
+			if p, ok := part.(map[string]any); ok && p["type"] == "text" {
+				if s, ok := p["text"].(string); ok {
+					texts = append(texts, s)
+				}
+			}
+		}
+		return strings.Join(texts, "\n")
+	}
+	return ""
+}
+
+// ListModels модели YandexGPT; все поддерживают функции и jsonSchema
+func (c *YandexGPTClient) ListModels(context.Context, uint32) ([]ChatModelInfo, error) {
+	result := make([]ChatModelInfo, 0, len(YandexGPTModels))
+	for _, name := range YandexGPTModels {
+		result = append(result, ChatModelInfo{ID: name, Name: name, SupportedParameters: []string{"tools", "response_format"}})
+	}
+	return result, nil
+}
+
+// Embed эмбеддинг текста (textEmbedding): query=true — модель запросов text-search-query,
+// иначе модель документов text-search-doc (для индексации RAG)
+func (c *YandexGPTClient) Embed(ctx context.Context, userID uint32, text string, query bool) ([]float32, error) {
+	modelName := "text-search-doc/latest"
+	if query {
+		modelName = "text-search-query/latest"
+	}
+	body, err := c.do(ctx, "/textEmbedding", userID, func(folderID string) any {
+		return map[string]any{"modelUri": yandexModelURI("emb", folderID, modelName), "text": text}
+	})
+	if err != nil {
+		return nil, err
+	}
+	var out struct {
+		Embedding []float32 `json:"embedding"`
+	}
+	if err := json.Unmarshal(body, &out); err != nil {
+		return nil, fmt.Errorf("ошибка парсинга эмбеддинга YandexGPT: %w", err)
+	}
+	if len(out.Embedding) == 0 {
+		return nil, fmt.Errorf("YandexGPT вернул пустой эмбеддинг")
+	}
+	return out.Embedding, nil
+}
+
+// yandexProvider — агент на стороне Yandex Cloud не создаётся: AssistId хранит имя модели
+// ("yandexgpt/latest"), остальное передаётся с каждым запросом (pkg/model/yandex)
+type yandexProvider struct{ m *UniversalModel }
+
+func (p yandexProvider) Provider() ProviderType { return ProviderYandex }
+
+func (p yandexProvider) Capabilities() Capabilities {
+	return Capabilities{}
+}
+
+func (p yandexProvider) CreateAgent(_ uint32, modelData *UniversalModelData, _ []Ids) (UMCR, error) {
+	return UMCR{AssistID: modelData.GptType.Name, Provider: ProviderYandex}, nil
+}
+
+func (p yandexProvider) UpdateAgent(userID uint32, _, updated *UniversalModelData) error {
+	return p.m.updateNamedAgent(userID, ProviderYandex, updated)
+}
+
+func (p yandexProvider) DeleteAgent(_ uint32, _ *UserModelRecord, _ bool, progressCallback func(string)) error {
+	if progressCallback != nil {
+		progressCallback("✅ Модель YandexGPT успешно удалена")
+	}
+	return nil
+}
+
+func (p yandexProvider) Request(ctx context.Context, userID uint32, req ProviderRequest) (ProviderResponse, error) {
+	return chatProviderRequest(ctx, p.m.yandexClient, userID, req)
+}
+
+func (p yandexProvider) Transcribe(context.Context, uint32, []byte, string) (string, error) {
+	return "", fmt.Errorf("YandexGPT не поддерживает транскрипцию аудио")
+}
@@ -0,0 +1,54 @@
+package create
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestYandexIAMToken_SlowExchangeDoesNotBlockOtherKeys(t *testing.T) {
+	release := make(chan struct{})
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		var in map[string]string
+		_ = json.NewDecoder(r.Body).Decode(&in)
+		if in["yandexPassportOauthToken"] == "slow" {
+			<-release
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"iamToken": "iam-" + in["yandexPassportOauthToken"], "expiresAt": time.Now().Add(time.Hour),
+		})
+	}))
+	defer srv.Close()
+	c := NewYandexGPTClient(srv.URL, srv.URL, nil)
+
+	slow := make(chan string, 2)
+	for range 2 {
+		go func() {
+			token, _ := c.iamToken(context.Background(), "slow")
+			slow <- token
+		}()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if token, err := c.iamToken(ctx, "fast"); err != nil || token != "iam-fast" {
+		t.Fatalf("fast token = %q, %v", token, err)
+	}
+
+	close(release)
+	for range 2 {
+		if token := <-slow; token != "iam-slow" {
+			t.Fatalf("slow token = %q", token)
+		}
+	}
+	// Второй запрос с тем же ключом дождался первого и взял токен из кэша
+	if n := calls.Load(); n != 2 {
+		t.Fatalf("IAM calls = %d, want 2", n)
+	}
+}
@@ -46,6 +46,12 @@ func WrapDBWithMasterKeyDecryption(ctx context.Context, db comdb.Exterior, mkPro
 	}
 }
 
+// Unwrap возвращает исходную БД: через неё проверяются необязательные хранилища
+// (снимки диалогов и т.п.), которых нет в comdb.Exterior
+func (d *masterKeyDecryptingDB) Unwrap() comdb.Exterior {
+	return d.Exterior
+}
+
 // GetUserAPIKey перехватывает стандартный метод и прозрачно расшифровывает $mk$-ключи.
 //
 //   - Ключ не зашифрован $mk$ (plaintext или $app$) → возвращает как есть (comdb обработает $app$).
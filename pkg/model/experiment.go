@@ -0,0 +1,21 @@
+package model
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ikermy/AiR_Common/pkg/experiment"
+)
+
+// WithExperiments применяет варианты A/B-экспериментов r во всех провайдерах (Experimenter):
+// модель, температура и промпт варианта переопределяют настройки ассистента в запросе диалога.
+// Тот же реестр передаётся в startpoint.WithExperiments — он помечает диалоги вариантом
+func WithExperiments(r *experiment.Registry) RouterOption {
+	return func(router *Router, _ context.Context, _ DB) error {
+		if r == nil {
+			return fmt.Errorf("реестр экспериментов не может быть nil")
+		}
+		router.experiments = r
+		return nil
+	}
+}
@@ -0,0 +1,140 @@
+package model
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/ikermy/AiR_Common/pkg/logger"
+	"github.com/ikermy/AiR_Common/pkg/mode"
+)
+
+// ScanFile файл на проверку: содержимое загрузки пользователя или только URL
+// (файлы, которые модель отправляет в send_files)
+type ScanFile struct {
+	Name    string
+	URL     string
+	Content io.Reader // nil — сканер проверяет по URL
+}
+
+// ScanResult результат проверки файла
+type ScanResult struct {
+	Infected bool
+	Threat   string // название угрозы от сканера
+}
+
+// FileScanner антивирусная проверка файлов (ClamAV, облачный сканер)
+type FileScanner interface {
+	Scan(ctx context.Context, file ScanFile) (ScanResult, error)
+}
+
+// FileScanFunc функция-адаптер FileScanner
+type FileScanFunc func(ctx context.Context, file ScanFile) (ScanResult, error)
+
+func (f FileScanFunc) Scan(ctx context.Context, file ScanFile) (ScanResult, error) {
+	return f(ctx, file)
+}
+
+// InfectedFileError файл отклонён сканером (или сканер не смог его проверить)
+type InfectedFileError struct {
+	Name   string
+	Threat string // пусто — файл не удалось проверить
+}
+
+func (e *InfectedFileError) Error() string {
+	if e.Threat == "" {
+		return fmt.Sprintf("файл %q не прошёл антивирусную проверку", e.Name)
+	}
+	return fmt.Sprintf("файл %q отклонён: обнаружена угроза %s", e.Name, e.Threat)
+}
+
+// WithFileScanner проверяет сканером каждый файл пользователя до отправки провайдеру
+// и каждый файл из send_files ответа модели. Заражённые и непроверенные файлы
+// отклоняются с записью "audit" в журнале
+func WithFileScanner(scanner FileScanner) RouterOption {
+	return func(r *Router, _ context.Context, _ DB) error {
+		if scanner == nil {
+			return fmt.Errorf("сканер файлов не может быть nil")
+		}
+		r.scanner = scanner
+		return nil
+	}
+}
+
+// scanFile проверяет файл; ошибка сканера считается отказом (fail closed)
+func (r *Router) scanFile(userID uint32, dialogID uint64, source string, file ScanFile) error {
+	ctx, cancel := context.WithTimeout(r.ctx, mode.FileScanTimeout)
+	defer cancel()
+
+	result, err := r.scanner.Scan(ctx, file)
+	if err == nil && !result.Infected {
+		return nil
+	}
+	log := logger.ForDialog(userID, dialogID).With("audit", "file_scan", "source", source, "file", file.Name, "url", file.URL)
+	if err != nil {
+		log.Error("файл отклонён: ошибка антивирусной проверки", "err", err)
+		return &InfectedFileError{Name: file.Name}
+	}
+	log.Warn("файл отклонён: обнаружена угроза", "threat", result.Threat)
+	return &InfectedFileError{Name: file.Name, Threat: result.Threat}
+}
+
+// scanUploads проверяет файлы пользователя. Содержимое читается в буфер, чтобы
+// после проверки files[i].Content остался читаемым для провайдера
+func (r *Router) scanUploads(userID uint32, dialogID uint64, files []FileUpload) error {
+	if r.scanner == nil {
+		return nil
+	}
+	for i := range files {
+		f := &files[i]
+		file := ScanFile{Name: f.Name, URL: f.URL}
+		if f.Content != nil {
+			data, err := io.ReadAll(f.Content)
+			if err != nil {
+				return fmt.Errorf("ошибка чтения файла %q: %w", f.Name, err)
+			}
+			f.Content = bytes.NewReader(data)
+			file.Content = bytes.NewReader(data)
+		}
+		if err := r.scanFile(userID, dialogID, "upload", file); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// scanResponseFiles убирает из send_files файлы, которые не прошли проверку
+func (r *Router) scanResponseFiles(userID uint32, dialogID uint64, resp AssistResponse) AssistResponse {
+	if r.scanner == nil || len(resp.Action.SendFiles) == 0 {
+		return resp
+	}
+	clean := make([]File, 0, len(resp.Action.SendFiles))
+	for _, f := range resp.Action.SendFiles {
+		if r.scanFile(userID, dialogID, "model", ScanFile{Name: f.FileName, URL: f.URL}) == nil {
+			clean = append(clean, f)
+		}
+	}
+	resp.Action.SendFiles = clean
+	return resp
+}
+
+// scanDeltas оборачивает onDelta: финальная дельта (AssistResponse целиком) уходит
+// без файлов, не прошедших проверку
+func (r *Router) scanDeltas(userID uint32, dialogID uint64, onDelta func(delta string, done bool) error) func(delta string, done bool) error {
+	if r.scanner == nil || onDelta == nil {
+		return onDelta
+	}
+	return func(delta string, done bool) error {
+		if !done {
+			return onDelta(delta, false)
+		}
+		var resp AssistResponse
+		if err := json.Unmarshal([]byte(delta), &resp); err != nil || len(resp.Action.SendFiles) == 0 {
+			return onDelta(delta, true)
+		}
+		jsonData, _ := json.Marshal(r.scanResponseFiles(userID, dialogID, resp))
+		return onDelta(string(jsonData), true)
+	}
+}
@@ -0,0 +1,47 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestFileScannerUploadsAndResponse(t *testing.T) {
+	scanner := FileScanFunc(func(_ context.Context, f ScanFile) (ScanResult, error) {
+		if f.Content != nil {
+			data, _ := io.ReadAll(f.Content)
+			if strings.Contains(string(data), "EICAR") {
+				return ScanResult{Infected: true, Threat: "Eicar-Test-Signature"}, nil
+			}
+		}
+		if strings.Contains(f.URL, "broken") {
+			return ScanResult{}, errors.New("сканер недоступен")
+		}
+		return ScanResult{}, nil
+	})
+	r := &Router{ctx: context.Background(), scanner: scanner}
+
+	files := []FileUpload{{Name: "ok.txt", Content: strings.NewReader("привет")}}
+	if err := r.scanUploads(1, 2, files); err != nil {
+		t.Fatalf("scanUploads() error = %v", err)
+	}
+	if data, _ := io.ReadAll(files[0].Content); string(data) != "привет" {
+		t.Fatalf("содержимое после проверки = %q", data)
+	}
+
+	err := r.scanUploads(1, 2, []FileUpload{{Name: "virus.txt", Content: strings.NewReader("X5O EICAR")}})
+	var infected *InfectedFileError
+	if !errors.As(err, &infected) || infected.Threat != "Eicar-Test-Signature" {
+		t.Fatalf("ожидалась InfectedFileError, получено %v", err)
+	}
+
+	resp := r.scanResponseFiles(1, 2, AssistResponse{Action: Action{SendFiles: []File{
+		{FileName: "a.pdf", URL: "https://example.test/a.pdf"},
+		{FileName: "b.pdf", URL: "https://example.test/broken.pdf"},
+	}}})
+	if len(resp.Action.SendFiles) != 1 || resp.Action.SendFiles[0].FileName != "a.pdf" {
+		t.Fatalf("непроверенный файл не убран: %+v", resp.Action.SendFiles)
+	}
+}
@@ -0,0 +1,161 @@
+package model
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/ikermy/AiR_Common/pkg/mode"
+	"github.com/ikermy/AiR_Common/pkg/safego"
+)
+
+// extMimeTypes расширения, которых нет или которые неточны в системной таблице mime
+var extMimeTypes = map[string]string{
+	".jpg":  "image/jpeg",
+	".jpeg": "image/jpeg",
+	".png":  "image/png",
+	".gif":  "image/gif",
+	".webp": "image/webp",
+	".heic": "image/heic",
+	".mp4":  "video/mp4",
+	".mov":  "video/quicktime",
+	".webm": "video/webm",
+	".mkv":  "video/x-matroska",
+	".avi":  "video/x-msvideo",
+	".mp3":  "audio/mpeg",
+	".ogg":  "audio/ogg",
+	".oga":  "audio/ogg",
+	".opus": "audio/opus",
+	".wav":  "audio/wav",
+	".m4a":  "audio/mp4",
+	".flac": "audio/flac",
+	".pdf":  "application/pdf",
+	".svg":  "image/svg+xml",
+}
+
+// DetectMimeType MIME-тип файла по сигнатуре содержимого head (первые байты, может быть nil)
+// и расширению name (имя файла или URL). Сигнатура важнее расширения, кроме
+// неопределённых результатов (text/plain, octet-stream, zip-контейнеры docx/xlsx)
+func DetectMimeType(name string, head []byte) string {
+	byExt := mimeByExtension(name)
+	if len(head) == 0 {
+		return byExt
+	}
+	sniffed, _, _ := strings.Cut(http.DetectContentType(head), ";")
+	switch sniffed {
+	case "application/octet-stream", "text/plain", "application/zip":
+		if byExt != "" {
+			return byExt
+		}
+	}
+	return sniffed
+}
+
+// DetectFileType тип файла для send_files: photo, video, audio или doc.
+// SVG и прочие форматы, которые мессенджеры не показывают как фото, — doc
+func DetectFileType(name string, head []byte) FileType {
+	return fileTypeByMime(DetectMimeType(name, head))
+}
+
+func fileTypeByMime(mimeType string) FileType {
+	switch {
+	case mimeType == "image/svg+xml":
+		return Doc
+	case strings.HasPrefix(mimeType, "image/"):
+		return Photo
+	case strings.HasPrefix(mimeType, "video/"):
+		return Video
+	case strings.HasPrefix(mimeType, "audio/"):
+		return Audio
+	default:
+		return Doc
+	}
+}
+
+// mimeByExtension MIME по расширению имени или пути URL; "" — расширение неизвестно
+func mimeByExtension(name string) string {
+	if u, err := url.Parse(name); err == nil && u.Scheme != "" {
+		name = u.Path
+	}
+	ext := strings.ToLower(path.Ext(name))
+	if ext == "" {
+		return ""
+	}
+	if t, ok := extMimeTypes[ext]; ok {
+		return t
+	}
+	t, _, _ := strings.Cut(mime.TypeByExtension(ext), ";")
+	return t
+}
+
+// CorrectFileTypes исправляет type в send_files по сигнатуре содержимого (первые байты
+// файла по URL) и расширению file_name или URL: модель определяет тип по тексту промпта
+// и ошибается (pdf как photo, неизвестный тип). Сигнатуры читаются параллельно, все вместе
+// не дольше mode.FileSniffTimeout; недоступный файл определяется по расширению.
+// Файлы без сигнатуры и известного расширения сохраняют тип модели, если он допустим, иначе — doc
+func CorrectFileTypes(ctx context.Context, files []File) []File {
+	if len(files) == 0 {
+		return files
+	}
+	ctx, cancel := context.WithTimeout(ctx, mode.FileSniffTimeout)
+	defer cancel()
+
+	heads := make([][]byte, len(files))
+	var wg sync.WaitGroup
+	for i := range files {
+		wg.Add(1)
+		safego.Go("model.CorrectFileTypes", func() {
+			defer wg.Done()
+			heads[i] = fetchFileHead(ctx, files[i].URL)
+		})
+	}
+	wg.Wait()
+
+	for i := range files {
+		name := files[i].FileName
+		if mimeByExtension(name) == "" {
+			name = files[i].URL
+		}
+		if mimeType := DetectMimeType(name, heads[i]); mimeType != "" {
+			files[i].Type = fileTypeByMime(mimeType)
+			continue
+		}
+		switch files[i].Type {
+		case Photo, Video, Audio, Doc:
+		default:
+			files[i].Type = Doc
+		}
+	}
+	return files
+}
+
+// sniffLen байт, которые учитывает http.DetectContentType
+const sniffLen = 512
+
+// fetchFileHead первые sniffLen байт файла по http(s) URL (запрос Range); nil — файл недоступен
+func fetchFileHead(ctx context.Context, rawURL string) []byte {
+	if !strings.HasPrefix(rawURL, "http://") && !strings.HasPrefix(rawURL, "https://") {
+		return nil
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=0-%d", sniffLen-1))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent {
+		return nil
+	}
+	head, _ := io.ReadAll(io.LimitReader(resp.Body, sniffLen))
+	return head
+}
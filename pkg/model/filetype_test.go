@@ -0,0 +1,87 @@
+package model
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+)
+
+func TestDetectFileType(t *testing.T) {
+	png := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+	pdf := []byte("%PDF-1.7\n")
+	for _, tc := range []struct {
+		name string
+		head []byte
+		want FileType
+	}{
+		{"photo.jpg", nil, Photo},
+		{"https://example.test/clip.MOV?sig=1", nil, Video},
+		{"voice.ogg", nil, Audio},
+		{"report.docx", nil, Doc},
+		{"logo.svg", nil, Doc},
+		{"image.pdf", png, Photo}, // сигнатура важнее расширения
+		{"file", pdf, Doc},
+		{"", nil, Doc},
+	} {
+		if got := DetectFileType(tc.name, tc.head); got != tc.want {
+			t.Errorf("DetectFileType(%q) = %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+// fakeFiles отвечает на запросы http.DefaultClient содержимым files по URL; остальные URL недоступны
+func fakeFiles(t *testing.T, files map[string][]byte) {
+	prev := http.DefaultClient.Transport
+	http.DefaultClient.Transport = roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		data, ok := files[r.URL.String()]
+		if !ok {
+			return nil, errors.New("недоступен")
+		}
+		if r.Header.Get("Range") != "bytes=0-511" {
+			t.Errorf("Range: %q", r.Header.Get("Range"))
+		}
+		return &http.Response{StatusCode: http.StatusPartialContent, Body: io.NopCloser(bytes.NewReader(data)), Request: r}, nil
+	})
+	t.Cleanup(func() { http.DefaultClient.Transport = prev })
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
+
+func TestCorrectFileTypes(t *testing.T) {
+	fakeFiles(t, nil)
+	files := CorrectFileTypes(context.Background(), []File{
+		{Type: Photo, URL: "https://example.test/a.pdf"},
+		{Type: "picture", URL: "https://example.test/b", FileName: "b.png"},
+		{Type: Video, URL: "https://example.test/stream"},
+		{Type: "pdf", URL: "https://example.test/c"},
+	})
+	for i, want := range []FileType{Doc, Photo, Video, Doc} {
+		if files[i].Type != want {
+			t.Errorf("files[%d].Type = %q, want %q", i, files[i].Type, want)
+		}
+	}
+}
+
+func TestCorrectFileTypes_Signature(t *testing.T) {
+	fakeFiles(t, map[string][]byte{
+		"https://example.test/scan.pdf":    []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"),
+		"https://example.test/download":    []byte("%PDF-1.7\n"),
+		"https://example.test/report.docx": []byte("PK\x03\x04\x14\x00\x06\x00"),
+	})
+	files := CorrectFileTypes(context.Background(), []File{
+		{Type: Doc, URL: "https://example.test/scan.pdf"},      // расширение врёт — по сигнатуре фото
+		{Type: Photo, URL: "https://example.test/download"},    // без расширения — по сигнатуре документ
+		{Type: Photo, URL: "https://example.test/report.docx"}, // zip-контейнер — по расширению
+		{Type: Photo, URL: "https://example.test/missing.mp4"}, // недоступен — по расширению
+	})
+	for i, want := range []FileType{Photo, Doc, Doc, Video} {
+		if files[i].Type != want {
+			t.Errorf("files[%d].Type = %q, want %q", i, files[i].Type, want)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+// Package gigachat подключает Sber GigaChat для клиентов, которым требуется обработка данных
+// в РФ. Диалоги обслуживает chatapi.Model: функции MCP передаются в functions, схема ответа
+// AssistResponse — в промпте. Ключ пользователя — ключ авторизации из личного кабинета
+// (при необходимости "GIGACHAT_API_CORP:<ключ>"); сертификат НУЦ Минцифры — GIGACHAT_CA_FILE.
+//
+//	router := model.NewModelRouter(ctx, db, gigachat.NewAsRouterOption())
+package gigachat
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ikermy/AiR_Common/pkg/mode"
+	"github.com/ikermy/AiR_Common/pkg/model"
+	"github.com/ikermy/AiR_Common/pkg/model/chatapi"
+	"github.com/ikermy/AiR_Common/pkg/model/create"
+)
+
+// New создаёт модель GigaChat; ключ пользователя читается из БД
+func New(ctx context.Context, d chatapi.DB, actionHandler model.ActionHandler) *chatapi.Model {
+	client := create.NewGigaChatClient(mode.GigaChatBaseURL, mode.GigaChatOAuthURL, func(userID uint32) string {
+		if key, err := d.GetUserAPIKey(userID, create.ProviderGigaChat); err == nil {
+			return key
+		}
+		return ""
+	})
+	return chatapi.New(ctx, d, create.ProviderGigaChat, client, actionHandler)
+}
+
+// NewAsRouterOption создаёт модель GigaChat и возвращает её как опцию для ModelRouter
+func NewAsRouterOption() model.RouterOption {
+	return func(r *model.Router, ctx context.Context, db model.DB) error {
+		routerDB, ok := db.(chatapi.DB)
+		if !ok {
+			return fmt.Errorf("DB не соответствует интерфейсу chatapi.DB")
+		}
+		m := New(ctx, routerDB, model.NewUniversalActionHandler(ctx))
+		m.SetUniversalModel(create.New(ctx, routerDB))
+		return model.WithGigaChatModel(m)(r, ctx, db)
+	}
+}
@@ -0,0 +1,101 @@
+package gigachat
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ikermy/AiR_Common/pkg/mode"
+	"github.com/ikermy/AiR_Common/pkg/model/create"
+)
+
+func TestGigaChatClient_OAuthCAAndFunctionCall(t *testing.T) {
+	var oauthCalls, round atomic.Int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("/oauth", func(w http.ResponseWriter, r *http.Request) {
+		oauthCalls.Add(1)
+		if r.Header.Get("Authorization") != "Basic a2V5" || r.Header.Get("RqUID") == "" {
+			t.Errorf("OAuth заголовки: %q %q", r.Header.Get("Authorization"), r.Header.Get("RqUID"))
+		}
+		if err := r.ParseForm(); err != nil || r.PostForm.Get("scope") != "GIGACHAT_API_CORP" {
+			t.Errorf("scope: %q %v", r.PostForm.Get("scope"), err)
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"access_token": "tok",
+			"expires_at":   time.Now().Add(30 * time.Minute).UnixMilli(),
+		})
+	})
+	mux.HandleFunc("/chat/completions", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer tok" {
+			t.Errorf("Authorization: %q", r.Header.Get("Authorization"))
+		}
+		var req struct {
+			Functions []map[string]any `json:"functions"`
+			Messages  []map[string]any `json:"messages"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("тело запроса: %v", err)
+		}
+		if len(req.Functions) != 1 || req.Functions[0]["name"] != "free_slots" {
+			t.Errorf("functions: %v", req.Functions)
+		}
+		if round.Add(1) == 1 {
+			_, _ = w.Write([]byte(`{"choices":[{"finish_reason":"function_call","message":{"role":"assistant","content":"",
+				"function_call":{"name":"free_slots","arguments":{"day":"пн"}}}}],
+				"usage":{"prompt_tokens":10,"completion_tokens":5,"total_tokens":15}}`))
+			return
+		}
+		call := req.Messages[len(req.Messages)-2]["function_call"].(map[string]any)
+		if args, ok := call["arguments"].(map[string]any); !ok || args["day"] != "пн" {
+			t.Errorf("function_call: %v", call)
+		}
+		result := req.Messages[len(req.Messages)-1]
+		if result["role"] != "function" || result["name"] != "free_slots" {
+			t.Errorf("результат функции: %v", result)
+		}
+		_, _ = w.Write([]byte(`{"choices":[{"finish_reason":"stop","message":{"role":"assistant","content":"Свободно в 10:00"}}],
+			"usage":{"prompt_tokens":20,"completion_tokens":7,"total_tokens":27}}`))
+	})
+	srv := httptest.NewTLSServer(mux)
+	defer srv.Close()
+
+	// Самоподписанный сертификат тестового сервера в роли Russian Trusted Root CA
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	if err := os.WriteFile(caFile, certPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	prevCA := mode.GigaChatCAFile
+	mode.GigaChatCAFile = caFile
+	defer func() { mode.GigaChatCAFile = prevCA }()
+
+	client := create.NewGigaChatClient(srv.URL, srv.URL+"/oauth", func(uint32) string { return "GIGACHAT_API_CORP:a2V5" })
+	req := create.ChatRequest{
+		Model:    "GigaChat-2-Max",
+		Messages: []create.ChatMessage{{Role: "user", Content: "Есть запись на понедельник?"}},
+		Tools:    []create.ChatTool{{Type: "function", Function: create.ChatFunction{Name: "free_slots"}}},
+	}
+	var gotArgs string
+	run := func(_ context.Context, _, arguments string) string {
+		gotArgs = arguments
+		return `{"slots":["10:00"]}`
+	}
+
+	resp, _, err := create.CompleteWithTools(context.Background(), client, 1, req, run)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gotArgs != `{"day":"пн"}` || resp.Text() != "Свободно в 10:00" || resp.Usage.TotalTokens != 42 {
+		t.Fatalf("ответ: %q %q %+v", gotArgs, resp.Text(), resp.Usage)
+	}
+	if oauthCalls.Load() != 1 {
+		t.Fatalf("access token должен кэшироваться, запросов: %d", oauthCalls.Load())
+	}
+}
@@ -0,0 +1,147 @@
+package google
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ikermy/AiR_Common/pkg/mode"
+	"github.com/ikermy/AiR_Common/pkg/model/create"
+	"github.com/ikermy/AiR_Common/pkg/safego"
+)
+
+const (
+	// contextCacheRefreshMargin — кэш пересоздаётся заранее, чтобы запрос не сослался на удалённый
+	contextCacheRefreshMargin = 2 * time.Minute
+	// contextCacheRetryDelay — пауза после неудачного создания (например, контекст меньше минимума Google)
+	contextCacheRetryDelay = 15 * time.Minute
+)
+
+// ContextCache хранит состояние кэша контекста Gemini (cachedContents) одной модели
+type ContextCache struct {
+	mu          sync.Mutex
+	Name        string    // cachedContents/{id}; пусто — кэш не создан
+	ExpireAt    time.Time // время истечения кэша на стороне Google
+	Hash        string    // hash промпта, tools и документов; при изменении кэш пересоздаётся
+	HasDocs     bool      // база знаний загружена в кэш — RAG-контекст в запрос не добавляется
+	FailedUntil time.Time // после ошибки создания повторная попытка не раньше этого времени
+}
+
+// activeContextCache возвращает кэш контекста модели, создавая или обновляя его при необходимости.
+// Пустое имя означает, что кэш недоступен и запрос идёт с полным system_instruction и tools.
+func (m *Model) activeContextCache(userID uint32, cfg *GoogleAgentConfig) (name string, hasDocs bool) {
+	if cfg == nil || !cfg.ContextCache || m.client == nil {
+		return "", false
+	}
+
+	v, _ := m.contextCaches.LoadOrStore(cfg.ModelId, &ContextCache{})
+	c := v.(*ContextCache)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	hash := m.contextCacheHash(cfg)
+	if c.Hash == hash {
+		if c.Name != "" && c.ExpireAt.Sub(now) > contextCacheRefreshMargin {
+			return c.Name, c.HasDocs
+		}
+		if now.Before(c.FailedUntil) {
+			return "", false
+		}
+	}
+
+	// Содержимое изменилось — старый кэш больше не используется
+	if c.Name != "" && c.Hash != hash {
+		m.deleteContextCacheAsync(userID, c.Name)
+	}
+	c.Name, c.Hash, c.HasDocs = "", hash, false
+
+	contents, hasDocs := m.contextCacheContents(cfg)
+	cached, err := m.client.CreateCachedContent(userID, cfg.ModelName, cfg.SystemInstruction, contents, cfg.Tools, mode.GoogleContextCacheTTL)
+	if err != nil {
+		//logger.Warn("Кэш контекста для modelId=%d не создан: %v", cfg.ModelId, err, userID)
+		c.FailedUntil = now.Add(contextCacheRetryDelay)
+		return "", false
+	}
+
+	c.Name, c.ExpireAt, c.HasDocs, c.FailedUntil = cached.Name, cached.ExpireTime, hasDocs, time.Time{}
+	return c.Name, c.HasDocs
+}
+
+// contextCacheHasDocs сообщает, загружена ли база знаний модели в действующий кэш контекста
+func (m *Model) contextCacheHasDocs(cfg *GoogleAgentConfig) bool {
+	if cfg == nil || !cfg.ContextCache {
+		return false
+	}
+	v, ok := m.contextCaches.Load(cfg.ModelId)
+	if !ok {
+		return false
+	}
+	c := v.(*ContextCache)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.Name != "" && c.HasDocs && c.Hash == m.contextCacheHash(cfg) &&
+		c.ExpireAt.Sub(time.Now()) > contextCacheRefreshMargin
+}
+
+// contextCacheContents формирует содержимое кэша из документов базы знаний модели
+func (m *Model) contextCacheContents(cfg *GoogleAgentConfig) ([]map[string]any, bool) {
+	if !cfg.HasVector {
+		return nil, false
+	}
+	docs, err := m.db.ListModelEmbeddings(cfg.ModelId, create.ProviderGoogle)
+	if err != nil || len(docs) == 0 {
+		return nil, false
+	}
+
+	chunks := make([]string, 0, len(docs))
+	for _, doc := range docs {
+		if doc.Content != "" {
+			chunks = append(chunks, doc.Content)
+		}
+	}
+	if len(chunks) == 0 {
+		return nil, false
+	}
+
+	return []map[string]any{{
+		"role": "user",
+		"parts": []map[string]any{{
+			"text": "Knowledge base:\n" + strings.Join(chunks, "\n\n---\n\n"),
+		}},
+	}}, true
+}
+
+// contextCacheHash вычисляет hash содержимого кэша: модель, промпт, tools и состав документов
+func (m *Model) contextCacheHash(cfg *GoogleAgentConfig) string {
+	ids := append([]string(nil), cfg.VectorIds...)
+	sort.Strings(ids)
+	data, _ := json.Marshal([]any{cfg.ModelName, cfg.SystemInstruction, cfg.Tools, ids})
+	return m.hashText(string(data))
+}
+
+// dropContextCache забывает кэш контекста модели и удаляет его в Google
+func (m *Model) dropContextCache(userID uint32, modelId uint64) {
+	v, ok := m.contextCaches.LoadAndDelete(modelId)
+	if !ok {
+		return
+	}
+	c := v.(*ContextCache)
+	c.mu.Lock()
+	name := c.Name
+	c.mu.Unlock()
+	if name != "" {
+		m.deleteContextCacheAsync(userID, name)
+	}
+}
+
+// deleteContextCacheAsync удаляет кэш контекста в фоне: при ошибке он истечёт по TTL
+func (m *Model) deleteContextCacheAsync(userID uint32, name string) {
+	safego.Go("google.deleteContextCache", func() {
+		if err := m.client.DeleteCachedContent(userID, name); err != nil {
+			//logger.Warn("Ошибка удаления кэша контекста %s: %v", name, err, userID)
+		}
+	}, safego.WithUserID(userID))
+}
@@ -0,0 +1,193 @@
+package google
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ikermy/AiR_Common/pkg/model/create"
+)
+
+// cachedContentsServer — cachedContents API: запоминает созданные и удалённые кэши
+type cachedContentsServer struct {
+	mu      sync.Mutex
+	created []map[string]any
+	deleted []string
+	fail    bool
+	expire  time.Duration
+}
+
+func (s *cachedContentsServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch {
+	case r.Method == http.MethodPost && r.URL.Path == "/cachedContents":
+		if s.fail {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"error":{"message":"Cached content is too small"}}`))
+			return
+		}
+		var payload map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&payload)
+		s.created = append(s.created, payload)
+		_, _ = fmt.Fprintf(w, `{"name":"cachedContents/c%d","expireTime":%q}`,
+			len(s.created), time.Now().Add(s.expire).Format(time.RFC3339))
+	case r.Method == http.MethodDelete && strings.HasPrefix(r.URL.Path, "/cachedContents/"):
+		s.deleted = append(s.deleted, strings.TrimPrefix(r.URL.Path, "/"))
+		_, _ = w.Write([]byte(`{}`))
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (s *cachedContentsServer) counts() (created, deleted int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.created), len(s.deleted)
+}
+
+// embeddingsDB — DB с документами базы знаний модели
+type embeddingsDB struct {
+	DB
+	docs []create.VectorDocument
+}
+
+func (d *embeddingsDB) ListModelEmbeddings(uint64, create.ProviderType) ([]create.VectorDocument, error) {
+	return d.docs, nil
+}
+
+// redirectTransport отправляет запросы к mode.GoogleAgentsURL на тестовый сервер
+type redirectTransport struct {
+	target *url.URL
+}
+
+func (rt redirectTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	r = r.Clone(r.Context())
+	r.URL.Scheme, r.URL.Host = rt.target.Scheme, rt.target.Host
+	r.URL.Path = strings.TrimPrefix(r.URL.Path, "/v1beta")
+	return http.DefaultTransport.RoundTrip(r)
+}
+
+func newContextCacheModel(t *testing.T, srv *cachedContentsServer) *Model {
+	ts := httptest.NewServer(srv)
+	t.Cleanup(ts.Close)
+	target, _ := url.Parse(ts.URL)
+	prev := http.DefaultClient.Transport
+	http.DefaultClient.Transport = redirectTransport{target: target}
+	t.Cleanup(func() { http.DefaultClient.Transport = prev })
+	return &Model{client: create.NewGoogleAgentClient(context.Background())}
+}
+
+// waitContextCacheDeleted ждёт фонового удаления n кэшей
+func waitContextCacheDeleted(t *testing.T, srv *cachedContentsServer, n int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		_, deleted := srv.counts()
+		if deleted == n {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("удалено кэшей: %d, want %d", deleted, n)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestActiveContextCache_ReuseAndRecreate(t *testing.T) {
+	srv := &cachedContentsServer{expire: time.Hour}
+	m := newContextCacheModel(t, srv)
+	cfg := &GoogleAgentConfig{
+		ModelId:           1,
+		ModelName:         "gemini-2.5-flash",
+		SystemInstruction: map[string]any{"parts": []any{map[string]any{"text": "Ты консультант"}}},
+		ContextCache:      true,
+	}
+
+	if name, _ := m.activeContextCache(1, &GoogleAgentConfig{ModelId: 1}); name != "" {
+		t.Fatalf("кэш без ContextCache: %q", name)
+	}
+
+	name, hasDocs := m.activeContextCache(1, cfg)
+	if name != "cachedContents/c1" || hasDocs {
+		t.Fatalf("первый запрос: %q hasDocs=%v", name, hasDocs)
+	}
+	if name, _ = m.activeContextCache(1, cfg); name != "cachedContents/c1" {
+		t.Fatalf("повторный запрос: %q", name)
+	}
+	if created, _ := srv.counts(); created != 1 {
+		t.Fatalf("кэш создан %d раз, want 1", created)
+	}
+	if srv.created[0]["model"] != "models/gemini-2.5-flash" || srv.created[0]["systemInstruction"] == nil {
+		t.Fatalf("payload: %v", srv.created[0])
+	}
+
+	// Новый промпт — новый кэш, старый удаляется
+	cfg.SystemInstruction = map[string]any{"parts": []any{map[string]any{"text": "Ты продавец"}}}
+	if name, _ = m.activeContextCache(1, cfg); name != "cachedContents/c2" {
+		t.Fatalf("после смены промпта: %q", name)
+	}
+	waitContextCacheDeleted(t, srv, 1)
+	if srv.deleted[0] != "cachedContents/c1" {
+		t.Fatalf("удалён %q", srv.deleted[0])
+	}
+}
+
+func TestActiveContextCache_RefreshBeforeExpiry(t *testing.T) {
+	srv := &cachedContentsServer{expire: contextCacheRefreshMargin / 2}
+	m := newContextCacheModel(t, srv)
+	cfg := &GoogleAgentConfig{ModelId: 2, ModelName: "gemini-2.5-flash", ContextCache: true}
+
+	m.activeContextCache(1, cfg)
+	if name, _ := m.activeContextCache(1, cfg); name != "cachedContents/c2" {
+		t.Fatalf("истекающий кэш не пересоздан: %q", name)
+	}
+}
+
+func TestActiveContextCache_FailureBackoff(t *testing.T) {
+	srv := &cachedContentsServer{fail: true}
+	m := newContextCacheModel(t, srv)
+	cfg := &GoogleAgentConfig{ModelId: 3, ModelName: "gemini-2.5-flash", ContextCache: true}
+
+	for i := 0; i < 3; i++ {
+		if name, _ := m.activeContextCache(1, cfg); name != "" {
+			t.Fatalf("кэш при ошибке: %q", name)
+		}
+	}
+	if created, _ := srv.counts(); created != 0 {
+		t.Fatalf("создано %d", created)
+	}
+	v, _ := m.contextCaches.Load(cfg.ModelId)
+	if until := v.(*ContextCache).FailedUntil; time.Until(until) < contextCacheRetryDelay-time.Minute {
+		t.Fatalf("FailedUntil: %s", until)
+	}
+}
+
+func TestActiveContextCache_KnowledgeBase(t *testing.T) {
+	srv := &cachedContentsServer{expire: time.Hour}
+	m := newContextCacheModel(t, srv)
+	m.db = &embeddingsDB{docs: []create.VectorDocument{{Content: "Доставка 2 дня"}, {Content: "Возврат 14 дней"}}}
+	cfg := &GoogleAgentConfig{ModelId: 4, ModelName: "gemini-2.5-flash", ContextCache: true, HasVector: true}
+
+	name, hasDocs := m.activeContextCache(1, cfg)
+	if name == "" || !hasDocs || !m.contextCacheHasDocs(cfg) {
+		t.Fatalf("база знаний не в кэше: %q hasDocs=%v", name, hasDocs)
+	}
+	data, _ := json.Marshal(srv.created[0]["contents"])
+	if !strings.Contains(string(data), "Доставка 2 дня") || !strings.Contains(string(data), "Возврат 14 дней") {
+		t.Fatalf("contents: %s", data)
+	}
+
+	m.dropContextCache(1, cfg.ModelId)
+	if m.contextCacheHasDocs(cfg) {
+		t.Fatal("кэш не забыт после dropContextCache")
+	}
+	waitContextCacheDeleted(t, srv, 1)
+}
@@ -0,0 +1,76 @@
+package google
+
+import (
+	"encoding/json"
+
+	"github.com/ikermy/AiR_Common/pkg/logger"
+	"github.com/ikermy/AiR_Common/pkg/mode"
+	"github.com/ikermy/AiR_Common/pkg/model/create"
+)
+
+// fitContext проверяет, что промпт, инструменты и история помещаются в inputTokenLimit
+// модели: самые старые сообщения отбрасываются (только в этом запросе, кэш диалога не
+// меняется), а если не помещается и одно сообщение пользователя — create.ContextTooLongError.
+// cached — промпт и инструменты лежат в кэше контекста и в запрос не входят
+func (m *Model) fitContext(userID uint32, dialogID uint64, modelName string, config *GoogleAgentConfig, cached bool, history []GoogleContent) ([]GoogleContent, error) {
+	if m.client == nil || len(history) == 0 {
+		return history, nil
+	}
+	limit := m.client.InputTokenLimit(userID, modelName)
+	if limit <= 0 {
+		return history, nil
+	}
+
+	fixed := create.EstimateTokens(config.responseSchema().PromptText(), modelName)
+	if !cached {
+		fixed += jsonTokens(config.SystemInstruction, modelName) + jsonTokens(config.Tools, modelName)
+	}
+	sizes := make([]int, len(history))
+	for i, content := range history {
+		sizes[i] = contentTokens(content, modelName)
+	}
+
+	drop, err := create.FitHistory(modelName, limit, fixed, sizes)
+	if err != nil {
+		return history, err
+	}
+	if drop == 0 {
+		return history, nil
+	}
+	// История должна начинаться с сообщения пользователя
+	for drop < len(history)-1 && history[drop].Role != "user" {
+		drop++
+	}
+	logger.ForDialog(userID, dialogID).Warn("история не помещается в контекст модели, старые сообщения не отправлены",
+		"model", modelName, "limit", limit, "dropped", drop)
+	return history[drop:], nil
+}
+
+// contentTokens оценка токенов сообщения: текст по EstimateTokens, вложения
+// по mode.GoogleMediaPartTokens, вызовы функций по их JSON
+func contentTokens(content GoogleContent, modelName string) int {
+	n := 0
+	for _, part := range content.Parts {
+		switch {
+		case part["text"] != nil:
+			text, _ := part["text"].(string)
+			n += create.EstimateTokens(text, modelName)
+		case part["inline_data"] != nil, part["inlineData"] != nil, part["fileData"] != nil, part["file_data"] != nil:
+			n += mode.GoogleMediaPartTokens
+		default:
+			n += jsonTokens(part, modelName)
+		}
+	}
+	return n
+}
+
+func jsonTokens(v any, modelName string) int {
+	if v == nil {
+		return 0
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return 0
+	}
+	return create.EstimateTokens(string(data), modelName)
+}
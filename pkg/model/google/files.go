@@ -8,6 +8,7 @@ import (
 	"strings"
 
 	"github.com/ikermy/AiR_Common/pkg/model/create"
+	"github.com/ikermy/AiR_Common/pkg/providererr"
 )
 
 // DeleteTempFile ============================================================================
@@ -75,7 +76,7 @@ func (m *Model) downloadFileFromGoogle(fileURI string) ([]byte, error) {
 
 	if resp.StatusCode != http.StatusOK {
 		responseBody, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("API вернул статус %d: %s", resp.StatusCode, string(responseBody))
+		return nil, providererr.New("Google", resp.StatusCode, resp.Header, responseBody)
 	}
 	content, err := io.ReadAll(resp.Body)
 	if err != nil {
@@ -4,16 +4,21 @@ import (
 	"context"
 	"crypto/sha256"
 	"fmt"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 
+	"github.com/ikermy/AiR_Common/pkg/audio"
 	"github.com/ikermy/AiR_Common/pkg/com"
 	"github.com/ikermy/AiR_Common/pkg/comdb"
+	"github.com/ikermy/AiR_Common/pkg/experiment"
 	"github.com/ikermy/AiR_Common/pkg/mode"
 	"github.com/ikermy/AiR_Common/pkg/model"
 	"github.com/ikermy/AiR_Common/pkg/model/create"
 	"github.com/ikermy/AiR_Common/pkg/model/provider_catalog"
+	"github.com/ikermy/AiR_Common/pkg/safego"
+	"github.com/ikermy/AiR_Common/pkg/storage"
 )
 
 type Inter interface {
@@ -32,10 +37,16 @@ type Model struct {
 	waitChannels     sync.Map
 	dialogCache      sync.Map // dialogID -> *DialogCache (локальный кэш истории диалогов)
 	embeddingCache   sync.Map // hash(text) -> *CachedEmbedding (кэш эмбеддингов для RAG)
+	contextCaches    sync.Map // modelId -> *ContextCache (явный кэш контекста Gemini)
 	realtimeSessions sync.Map // respId -> *GoogleRealtimeSession (параллельные голосовые сессии)
 	UserModelTTl     time.Duration
 	actionHandler    model.ActionHandler
 	universalModel   *create.UniversalModel
+	experiments      *experiment.Registry
+	injection        *model.InjectionDetector // nil — фрагменты RAG не проверяются
+	media            storage.MediaStore       // nil — сгенерированные файлы сохраняются через save_image
+	images           model.ImageOptions       // обработка сгенерированных изображений перед сохранением
+	seeds            *model.Seeds             // nil — детерминированный режим выключен
 	shutdownOnce     sync.Once
 }
 
@@ -72,6 +83,8 @@ type GoogleAgentConfig struct {
 	ModelId           uint64           `json:"model_id"` // ID модели в БД для связи с vector_embeddings
 	ModelName         string           `json:"model_name"`
 	SystemInstruction map[string]any   `json:"system_instruction"`
+	PromptVersion     uint32           `json:"prompt_version,omitempty"` // версия SystemInstruction в create.PromptVersionStore
+	PromptHint        string           `json:"prompt_hint,omitempty"`    // подсказка MCP, добавленная к промпту модели
 	GenerationConfig  map[string]any   `json:"generation_config"`
 	Tools             []map[string]any `json:"tools"`
 	VectorIds         []string         `json:"vector_id,omitempty"`  // ID векторных хранилищ в Google Vector Store
@@ -91,12 +104,33 @@ type GoogleAgentConfig struct {
 	S3          bool `json:"s3"`          // S3 хранилище
 	Interpreter bool `json:"interpreter"` // Code Interpreter
 
+	// Явный кэш контекста (cachedContents): промпт, tools и база знаний передаются ссылкой
+	ContextCache bool `json:"context_cache"`
+
+	// Пороги блокировки контента по категориям (safetySettings)
+	SafetySettings []create.GoogleSafetySetting `json:"safety_settings,omitempty"`
+
+	// Дополнительные поля ответа ассистента (AssistResponse.Extra)
+	ResponseFields []create.SchemaField `json:"response_fields,omitempty"`
+
+	// Глубина рассуждений (thinkingConfig.thinkingBudget)
+	Reasoning create.ReasoningEffort `json:"reasoning,omitempty"`
+
 	// Голосовой режим реального времени (Google Multimodal Live API)
 	RealtimeEnabled bool                `json:"realtime_enabled"`       // Голосовой режим включён
 	RealtimeModel   string              `json:"realtime_model"`         // Имя realtime-модели (gemini-2.0-flash-lite)
 	RealtimeVAD     *create.RealtimeVAD `json:"realtime_vad,omitempty"` // Параметры VAD и голоса
 }
 
+// responseSchema схема ответа с дополнительными полями ассистента
+func (c *GoogleAgentConfig) responseSchema() *create.ResponseSchema {
+	schema := create.DefaultResponseSchema()
+	for _, f := range c.ResponseFields {
+		schema.WithField(f)
+	}
+	return schema
+}
+
 // DialogCache кэширует историю диалога в памяти для быстрого доступа
 type DialogCache struct {
 	dialogID uint64
@@ -164,11 +198,15 @@ func New(parent context.Context, d DB, actionHandler model.ActionHandler) *Model
 		client:        googleClient,
 		db:            d,
 		UserModelTTl:  mode.UserModelTTl,
+		images:        model.DefaultImageOptions(),
 		actionHandler: actionHandler,
 	}
 
 	// Запускаем periodicFlush в фоновой горутине для очистки истекших диалогов из кэша
-	go m.periodicFlush()
+	safego.Go("google.periodicFlush", m.periodicFlush, safego.WithRestart(-1, time.Second), safego.WithDone(ctx.Done()))
+	// Удаляем из Google File API файлы старше mode.GoogleFilesRetention, кроме файлов живой истории
+	googleClient.SetFilesInUse(m.filesInUse)
+	safego.Go("google.filesJanitor", googleClient.FilesJanitor, safego.WithRestart(-1, time.Minute), safego.WithDone(ctx.Done()))
 
 	return m
 }
@@ -194,6 +232,44 @@ func (m *Model) SetClient(client *create.GoogleAgentClient) {
 	m.client = client
 }
 
+// SetExperiments включает A/B-эксперименты: вариант диалога переопределяет модель,
+// температуру и промпт запроса
+func (m *Model) SetExperiments(r *experiment.Registry) {
+	m.experiments = r
+}
+
+// schemaRepair исправление ответа под схему той же моделью; nil — без исправления
+func (m *Model) schemaRepair(modelName string) model.SchemaRepairFunc {
+	if m.universalModel == nil || modelName == "" {
+		return nil
+	}
+	client, err := m.universalModel.Provider(create.ProviderGoogle)
+	if err != nil {
+		return nil
+	}
+	return model.LLMSchemaRepair(m.ctx, client, modelName)
+}
+
+// SetInjectionDetector включает очистку фрагментов RAG от prompt injection
+func (m *Model) SetInjectionDetector(d *model.InjectionDetector) {
+	m.injection = d
+}
+
+// SetSeeds включает детерминированный режим: seed диалога передаётся в generationConfig
+func (m *Model) SetSeeds(s *model.Seeds) {
+	m.seeds = s
+}
+
+// SetMediaStore сохраняет сгенерированные изображения и видео в хранилище по presigned URL
+func (m *Model) SetMediaStore(store storage.MediaStore) {
+	m.media = store
+}
+
+// SetImageOptions задаёт обработку сгенерированных изображений перед сохранением
+func (m *Model) SetImageOptions(opts model.ImageOptions) {
+	m.images = opts
+}
+
 // SetUniversalModel устанавливает UniversalModel
 func (m *Model) SetUniversalModel(um *create.UniversalModel) {
 	m.universalModel = um
@@ -243,17 +319,10 @@ func (m *Model) loadAgentConfig(userID uint32, respModel *GoogleRespModel) error
 		return fmt.Errorf("ошибка получения моделей пользователя: %w", err)
 	}
 
-	// Ищем активную модель Google
-	var found *create.UserModelRecord
-	for i := range userModels {
-		if userModels[i].Provider == create.ProviderGoogle {
-			found = &userModels[i]
-			break
-		}
-	}
-
+	// Модель ассистента респондента (у пользователя их может быть несколько)
+	found := create.FindAssistantModel(userModels, create.ProviderGoogle, respModel.Assist.AssistId)
 	if found == nil {
-		return fmt.Errorf("модель Google не найдена для userID %d", userID)
+		return fmt.Errorf("модель Google %q не найдена для userID %d", respModel.Assist.AssistId, userID)
 	}
 
 	// Инициализируем базовую конфигурацию.
@@ -277,7 +346,7 @@ func (m *Model) loadAgentConfig(userID uint32, respModel *GoogleRespModel) error
 	}
 
 	// Загружаем полные данные модели из БД для получения всех параметров
-	compressedData, _, err := m.db.ReadUserModelByProvider(userID, create.ProviderGoogle)
+	compressedData, _, err := create.ReadAssistantModel(m.db, userID, found)
 	if err != nil {
 		//logger.Warn("Ошибка чтения данных модели из БД: %v, используем конфигурацию по умолчанию", err, userID)
 	} else if compressedData != nil {
@@ -292,6 +361,7 @@ func (m *Model) loadAgentConfig(userID uint32, respModel *GoogleRespModel) error
 				if mcpProvider, ok := m.actionHandler.(model.MCPConfigProvider); ok {
 					if hint, fetchErr := mcpProvider.FetchSystemPrompt(m.ctx, userID, create.ProviderGoogle); fetchErr == nil && hint != "" {
 						promptText = modelData.Prompt + "\n\n" + hint
+						agentConfig.PromptHint = hint
 					}
 				}
 				if promptText != "" {
@@ -302,6 +372,7 @@ func (m *Model) loadAgentConfig(userID uint32, respModel *GoogleRespModel) error
 							},
 						},
 					}
+					agentConfig.PromptVersion = create.SavePromptVersion(m.db, userID, found.ModelId, modelData.Prompt, promptText)
 					//} else {
 					//	logger.Warn("Prompt пустой в БД!", userID)
 				}
@@ -316,6 +387,11 @@ func (m *Model) loadAgentConfig(userID uint32, respModel *GoogleRespModel) error
 				agentConfig.MetaAction = modelData.MetaAction
 				agentConfig.S3 = modelData.S3
 				agentConfig.Interpreter = modelData.Interpreter
+				agentConfig.ContextCache = modelData.ContextCache
+				agentConfig.SafetySettings = modelData.SafetySettings
+				agentConfig.ResponseFields = modelData.ResponseFields
+				agentConfig.Reasoning = modelData.Reasoning
+				agentConfig.GenerationConfig = modelData.Generation.Gemini()
 				agentConfig.RealtimeEnabled = modelData.Realtime
 				agentConfig.RealtimeVAD = modelData.RealtimeVAD
 				// RealtimeModel: берём из RealtimeVAD.Google.VoiceName нет — это фиксированная модель.
@@ -460,22 +536,39 @@ func (m *Model) Shutdown(shutCh chan<- com.LogMsg) {
 	}
 }
 
-// TranscribeAudio транскрибирует аудио в текст (обёртка для клиента)
+// TranscribeAudio транскрибирует аудио в текст (обёртка для клиента).
+// Router передаёт имя файла, а не MIME-тип — тип определяется по содержимому и расширению
 func (m *Model) TranscribeAudio(_ uint32, audioData []byte, mimeType string) (string, error) {
 	if m.client == nil {
 		return "", fmt.Errorf("google клиент не инициализирован")
 	}
+	if !strings.Contains(mimeType, "/") {
+		if format := audio.Detect(audioData, mimeType); format != audio.Unknown {
+			mimeType = format.MimeType()
+		} else {
+			mimeType = ""
+		}
+	}
 
 	return m.client.TranscribeAudio(audioData, mimeType)
 }
 
-// GenerateVideo генерирует видео по описанию (обёртка для клиента)
+// CountTokens точное число токенов через countTokens Gemini (model.TokenCounter)
+func (m *Model) CountTokens(userID uint32, modelName, text string) (int, error) {
+	if m.client == nil {
+		return 0, fmt.Errorf("google клиент не инициализирован")
+	}
+	return m.client.CountTokens(userID, modelName, text)
+}
+
+// GenerateVideo генерирует видео по описанию (обёртка для клиента) с параметрами
+// генерации по умолчанию: агент здесь неизвестен
 func (m *Model) GenerateVideo(prompt string, aspectRatio string, duration int) ([]byte, string, error) {
 	if m.client == nil {
 		return nil, "", fmt.Errorf("google клиент не инициализирован")
 	}
 
-	return m.client.GenerateVideo(prompt, aspectRatio, duration)
+	return m.client.GenerateVideo(prompt, aspectRatio, duration, nil)
 }
 
 // GetOrSetRespGPT получает или создаёт респондента (адаптер для совместимости с Inter)
@@ -484,6 +577,14 @@ func (m *Model) GetOrSetRespGPT(assist model.Assistant, dialogID, respId uint64,
 	if val, ok := m.responders.Load(respId); ok {
 		respModel := val.(*GoogleRespModel)
 		respModel.TTL = time.Now().Add(m.UserModelTTl) // Обновляем TTL
+		// Диалог передан другому ассистенту (SwitchAssistant) — конфигурация агента перечитывается
+		if assist.AssistId != "" && respModel.Assist.AssistId != assist.AssistId {
+			next := &GoogleRespModel{Assist: assist}
+			if err := m.loadAgentConfig(assist.UserID, next); err != nil {
+				return nil, fmt.Errorf("ошибка загрузки конфигурации агента: %w", err)
+			}
+			respModel.AgentConfig = next.AgentConfig
+		}
 		respModel.Assist = assist
 		respModel.RespName = respName
 
@@ -587,12 +688,6 @@ func (m *Model) GetRespIdByDialogID(dialogID uint64) (uint64, error) {
 	return foundRespId, nil
 }
 
-// SaveAllContextDuringExit сохраняет все контексты при выходе
-func (m *Model) SaveAllContextDuringExit() {
-	// Google не использует SaveContext (история в БД через ReadDialog)
-	// Поэтому этот метод пустой
-}
-
 // CleanDialogData очищает данные диалога
 func (m *Model) CleanDialogData(dialogID uint64) {
 	// Получаем respId по dialogID
@@ -699,6 +794,48 @@ func (m *Model) addMessageToCache(dialogID uint64, content GoogleContent) {
 	}
 }
 
+// InjectContext добавляет сведения в кэш истории диалога сообщением роли user
+// (у Gemini нет системной роли в contents). Без кэша ничего не делает.
+func (m *Model) InjectContext(dialogID uint64, text string) bool {
+	if _, found := m.getDialogHistoryFromCache(dialogID); !found {
+		return false
+	}
+	m.addMessageToCache(dialogID, GoogleContent{Role: "user", Parts: []map[string]any{{"text": text}}})
+	return true
+}
+
+// LastAnswer текст последнего ответа модели в кэше истории диалога (model.TurnRecorder)
+func (m *Model) LastAnswer(dialogID uint64) (string, bool) {
+	contents, found := m.getDialogHistoryFromCache(dialogID)
+	if !found {
+		return "", false
+	}
+	for i := len(contents) - 1; i >= 0; i-- {
+		if contents[i].Role != "model" {
+			continue
+		}
+		var sb strings.Builder
+		for _, part := range contents[i].Parts {
+			if text, ok := part["text"].(string); ok {
+				sb.WriteString(text)
+			}
+		}
+		return sb.String(), true
+	}
+	return "", true
+}
+
+// RecordTurn добавляет в кэш истории ход, ответ на который взят из семантического кэша.
+// Без кэша ничего не делает
+func (m *Model) RecordTurn(dialogID uint64, question string, answer model.AssistResponse) bool {
+	if _, found := m.getDialogHistoryFromCache(dialogID); !found {
+		return false
+	}
+	m.addMessageToCache(dialogID, GoogleContent{Role: "user", Parts: []map[string]any{{"text": question}}})
+	m.addMessageToCache(dialogID, m.createModelMessage(answer))
+	return true
+}
+
 // getDialogHistoryFromCache получает историю диалога из кэша
 func (m *Model) getDialogHistoryFromCache(dialogID uint64) ([]GoogleContent, bool) {
 	if cacheIface, ok := m.dialogCache.Load(dialogID); ok {
@@ -851,6 +988,9 @@ func (m *Model) InvalidateUserAgentConfigCache(userID uint32) {
 	m.responders.Range(func(key, value any) bool {
 		respModel := value.(*GoogleRespModel)
 		if respModel.Assist.UserID == userID {
+			if respModel.AgentConfig != nil {
+				m.dropContextCache(userID, respModel.AgentConfig.ModelId)
+			}
 			m.responders.Delete(key)
 			invalidatedCount++
 		}
@@ -895,3 +1035,33 @@ func (m *Model) UpdateModelsListByProvider(ctx context.Context, provider create.
 	}
 	return provider_catalog.SyncProviderModels(ctx, m.db, create.ProviderGoogle, apiKey)
 }
+
+// filesInUse URI файлов File API, на которые ссылается история диалогов в кэше:
+// пока кэш жив, она уходит в generateContent, и удалённый файл сломал бы запрос
+func (m *Model) filesInUse() map[string]bool {
+	uris := make(map[string]bool)
+	m.dialogCache.Range(func(key, _ any) bool {
+		contents, _ := m.getDialogHistoryFromCache(key.(uint64))
+		for _, content := range contents {
+			for _, part := range content.Parts {
+				if uri := fileDataURI(part); uri != "" {
+					uris[uri] = true
+				}
+			}
+		}
+		return true
+	})
+	return uris
+}
+
+// fileDataURI fileUri части fileData (map[string]string до сохранения, map[string]any после JSON)
+func fileDataURI(part map[string]any) string {
+	switch data := part["fileData"].(type) {
+	case map[string]string:
+		return data["fileUri"]
+	case map[string]any:
+		uri, _ := data["fileUri"].(string)
+		return uri
+	}
+	return ""
+}
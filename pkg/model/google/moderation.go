@@ -0,0 +1,17 @@
+package google
+
+import (
+	"context"
+
+	"github.com/ikermy/AiR_Common/pkg/model"
+)
+
+// Moderate реализует model.Moderator через фильтры безопасности Gemini.
+// Запрос выполняется в контексте клиента, ctx не используется
+func (m *Model) Moderate(_ context.Context, userID uint32, text string) (model.ModerationResult, error) {
+	categories, err := m.client.SafetyCheck(userID, text)
+	if err != nil {
+		return model.ModerationResult{}, err
+	}
+	return model.ModerationResult{Flagged: len(categories) > 0, Categories: categories}, nil
+}
@@ -11,6 +11,7 @@ import (
 	"github.com/gorilla/websocket"
 	"github.com/ikermy/AiR_Common/pkg/model"
 	"github.com/ikermy/AiR_Common/pkg/model/create"
+	"github.com/ikermy/AiR_Common/pkg/safego"
 )
 
 // ============================================================================
@@ -101,13 +102,13 @@ func (m *Model) SubscribeEvents(respId uint64) (<-chan model.RealtimeEvent, erro
 	// Race-guard: pump мог уже завершиться до подписки — отправляем синтетическую ошибку.
 	select {
 	case <-rs.ctx.Done():
-		go func() {
+		safego.Go("google.realtime.subscribeGuard", func() {
 			ch <- model.RealtimeEvent{
 				Type: "error",
 				Text: "google session terminated before subscription",
 				Err:  fmt.Errorf("session terminated before subscription"),
 			}
-		}()
+		})
 	default:
 	}
 
@@ -218,13 +219,14 @@ func (m *Model) StartRealtimeSession(userID uint32, dialogID, respId uint64) err
 	//	respId, rm.AgentConfig.ModelName, userID)
 
 	// Горутина-сторож: закрывает WS при отмене контекста → разблокирует ReadMessage()
-	go func() {
+	safego.Go("google.realtime.watchdog", func() {
 		<-rs.ctx.Done()
 		_ = rs.googleConn.Close()
-	}()
+	})
 
-	go m.pumpFromGoogle(rs)
-	go m.pumpToGoogle(rs)
+	// При панике в pump сессия закрывается, чтобы не оставлять её наполовину рабочей
+	safego.Go("google.pumpFromGoogle", func() { m.pumpFromGoogle(rs) }, safego.WithUserID(userID), safego.WithOnPanic(func(any) { rs.cancel() }))
+	safego.Go("google.pumpToGoogle", func() { m.pumpToGoogle(rs) }, safego.WithUserID(userID), safego.WithOnPanic(func(any) { rs.cancel() }))
 
 	return nil
 }
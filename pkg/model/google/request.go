@@ -3,16 +3,24 @@ package google
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io"
+	"maps"
 	"net/http"
+	"slices"
 	"strings"
 	"time"
 
+	"github.com/ikermy/AiR_Common/pkg/experiment"
+	"github.com/ikermy/AiR_Common/pkg/logger"
+	"github.com/ikermy/AiR_Common/pkg/mode"
 	"github.com/ikermy/AiR_Common/pkg/model"
 	"github.com/ikermy/AiR_Common/pkg/model/create"
+	"github.com/ikermy/AiR_Common/pkg/providererr"
+	"github.com/ikermy/AiR_Common/pkg/safego"
 )
 
 // DialogMessage представляет сообщение из истории диалога (формат БД)
@@ -114,13 +122,16 @@ func (m *Model) ConvertDialogToGoogleFormat(dialogID uint64) ([]GoogleContent, e
 	return contents, nil
 }
 
-// createUserMessage создаёт сообщение пользователя в формате Google
-func (m *Model) createUserMessage(text string, files []model.FileUpload) GoogleContent {
+// createUserMessage создаёт сообщение пользователя в формате Google.
+// Вложения передаются в inline_data, пока их суммарный размер не превышает
+// mode.GoogleInlineDataLimit; файлы сверх лимита загружаются в File API (resumable)
+// и передаются по fileUri
+func (m *Model) createUserMessage(userID uint32, text string, files []model.FileUpload) GoogleContent {
 	parts := []map[string]any{
 		{"text": text},
 	}
+	inlineSize := 0 // байт вложений, уже переданных в inline_data
 
-	// Добавляем поддержку файлов (изображений)
 	for _, file := range files {
 		// Если это изображение с URL - используем fileUri
 		if file.HasURL() && file.IsImageMimeType() {
@@ -130,20 +141,42 @@ func (m *Model) createUserMessage(text string, files []model.FileUpload) GoogleC
 					"fileUri":  file.URL,
 				},
 			})
-		} else if file.Content != nil {
-			// Для файлов без URL - читаем байты и используем inline_data
-			data, err := io.ReadAll(file.Content)
+			continue
+		}
+		if file.Content == nil {
+			continue
+		}
+
+		data, err := io.ReadAll(file.Content)
+		if err != nil {
+			//logger.Warn("Не удалось прочитать содержимое файла %s: %v, пропускаем", file.Name, err)
+			continue
+		}
+		if inlineSize+len(data) > mode.GoogleInlineDataLimit {
+			if m.client == nil {
+				logger.Warn("Файл %s слишком велик для inline_data, File API недоступен", file.Name, userID)
+				continue
+			}
+			uploaded, err := m.uploadUserFile(userID, file, data)
 			if err != nil {
-				//logger.Warn("Не удалось прочитать содержимое файла %s: %v, пропускаем", file.Name, err)
+				logger.Warn("Файл %s не загружен в File API и слишком велик для inline_data: %v", file.Name, err, userID)
 				continue
 			}
 			parts = append(parts, map[string]any{
-				"inline_data": map[string]string{
-					"mime_type": file.MimeType,
-					"data":      base64.StdEncoding.EncodeToString(data),
+				"fileData": map[string]string{
+					"mimeType": uploaded.MimeType,
+					"fileUri":  uploaded.URI,
 				},
 			})
+			continue
 		}
+		inlineSize += len(data)
+		parts = append(parts, map[string]any{
+			"inline_data": map[string]string{
+				"mime_type": file.MimeType,
+				"data":      base64.StdEncoding.EncodeToString(data),
+			},
+		})
 	}
 
 	return GoogleContent{
@@ -152,6 +185,22 @@ func (m *Model) createUserMessage(text string, files []model.FileUpload) GoogleC
 	}
 }
 
+// uploadUserFile загружает файл пользователя в File API и ждёт окончания его обработки
+func (m *Model) uploadUserFile(userID uint32, file model.FileUpload, data []byte) (create.GoogleFile, error) {
+	mimeType := file.MimeType
+	if mimeType == "" {
+		mimeType = model.DetectMimeType(file.Name, data)
+	}
+	uploaded, err := m.client.UploadFile(userID, data, mimeType, file.Name)
+	if err != nil {
+		return uploaded, err
+	}
+	if uploaded.MimeType == "" {
+		uploaded.MimeType = mimeType
+	}
+	return m.client.WaitFileActive(userID, uploaded)
+}
+
 // createModelMessage создаёт сообщение модели в формате Google Gemini
 func (m *Model) createModelMessage(assistResponse model.AssistResponse) GoogleContent {
 	// Извлекаем текстовое сообщение
@@ -178,12 +227,11 @@ func (m *Model) sendToGeminiAPI(modelName string, payload map[string]any, userID
 		return nil, fmt.Errorf("ошибка сериализации запроса: %v", err)
 	}
 
-	url := fmt.Sprintf("%s/models/%s:generateContent?key=%s",
-		m.client.GetUrl(), modelName, m.client.GetAPIKeyForUser(userID))
+	url := fmt.Sprintf("%s/%s:generateContent?key=%s",
+		m.client.GetUrl(), create.GoogleModelPath(modelName), m.client.GetAPIKeyForUser(userID))
 
-	// Попытка запроса с автоматическим retry для ошибки 429
-	maxRetries := 2
-	for attempt := 0; attempt <= maxRetries; attempt++ {
+	// Ошибка 429 повторяется по mode.Retry.RateLimit
+	for attempt := 1; ; attempt++ {
 		req, err := http.NewRequestWithContext(m.ctx, http.MethodPost, url, bytes.NewBuffer(body))
 		if err != nil {
 			return nil, fmt.Errorf("ошибка создания запроса: %v", err)
@@ -208,71 +256,46 @@ func (m *Model) sendToGeminiAPI(modelName string, payload map[string]any, userID
 		}
 
 		// Обработка ошибки 429 (quota exceeded)
-		if resp.StatusCode == 429 && attempt < maxRetries {
-			// Пытаемся извлечь retryDelay из ответа
-			var errorResp struct {
-				Error struct {
-					Details []map[string]any `json:"details"`
-				} `json:"error"`
-			}
-
-			retryDelay := 5 * time.Second // По умолчанию 5 секунд
-
-			if json.Unmarshal(responseBody, &errorResp) == nil {
-				for _, detail := range errorResp.Error.Details {
-					if detail["@type"] == "type.googleapis.com/google.rpc.RetryInfo" {
-						if retryDelayStr, ok := detail["retryDelay"].(string); ok {
-							// Парсим "11s" или "27.077507321s" в time.Duration
-							if duration, err := time.ParseDuration(retryDelayStr); err == nil {
-								retryDelay = duration
-							}
-						}
-					}
+		if resp.StatusCode == http.StatusTooManyRequests && !providererr.QuotaExhausted(resp.StatusCode, responseBody) {
+			if delay, ok := mode.Retry.RateLimitDelay(attempt, googleRetryDelay(resp.Header, responseBody)); ok {
+				if err := m.sleep(m.ctx, delay); err != nil {
+					return nil, err
 				}
+				continue // Повторяем запрос
 			}
-
-			//logger.Warn("Квота Google API превышена (429), retry через %v (попытка %d/%d)",
-			//	retryDelay, attempt+1, maxRetries)
-
-			time.Sleep(retryDelay)
-			continue // Повторяем запрос
 		}
 
-		// Другие ошибки или последняя попытка
-		return nil, fmt.Errorf("API вернул статус %d: %s", resp.StatusCode, string(responseBody))
+		return nil, providererr.New("Google", resp.StatusCode, resp.Header, responseBody)
 	}
-
-	return nil, fmt.Errorf("превышено количество попыток retry")
 }
 
 // sendToGeminiAPIStreaming отправляет запрос к Google Gemini API с поддержкой SSE стриминга
 // Использует endpoint streamGenerateContent для получения ответа в режиме реального времени
 // onDelta вызывается для каждого delta-события, onComplete - для финального ответа с токенами
-// Возвращает: fullText, usageMetadata, functionCalls, error
-func (m *Model) sendToGeminiAPIStreaming(modelName string, payload map[string]any, onDelta func(delta string) error, userID uint32) (string, map[string]any, []map[string]any, error) {
+// Возвращает: fullText, usageMetadata, functionCalls, citations, error
+func (m *Model) sendToGeminiAPIStreaming(ctx context.Context, modelName string, payload map[string]any, onDelta func(delta string) error, userID uint32) (string, map[string]any, []map[string]any, []model.Citation, error) {
 	body, err := json.Marshal(payload)
 	if err != nil {
-		return "", nil, nil, fmt.Errorf("ошибка сериализации запроса: %v", err)
+		return "", nil, nil, nil, fmt.Errorf("ошибка сериализации запроса: %v", err)
 	}
 
 	// Используем streamGenerateContent для SSE
 	// m.client.GetUrl() уже содержит версию API (v1beta), поэтому не добавляем её повторно
-	url := fmt.Sprintf("%s/models/%s:streamGenerateContent?alt=sse&key=%s",
-		m.client.GetUrl(), modelName, m.client.GetAPIKeyForUser(userID))
+	url := fmt.Sprintf("%s/%s:streamGenerateContent?alt=sse&key=%s",
+		m.client.GetUrl(), create.GoogleModelPath(modelName), m.client.GetAPIKeyForUser(userID))
 
-	// Попытка запроса с автоматическим retry для ошибки 429
-	maxRetries := 2
-	for attempt := 0; attempt <= maxRetries; attempt++ {
-		req, err := http.NewRequestWithContext(m.ctx, http.MethodPost, url, bytes.NewBuffer(body))
+	// Ошибка 429 повторяется по mode.Retry.RateLimit
+	for attempt := 1; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewBuffer(body))
 		if err != nil {
-			return "", nil, nil, fmt.Errorf("ошибка создания запроса: %v", err)
+			return "", nil, nil, nil, fmt.Errorf("ошибка создания запроса: %v", err)
 		}
 
 		req.Header.Set("Content-Type", "application/json")
 
 		resp, err := http.DefaultClient.Do(req)
 		if err != nil {
-			return "", nil, nil, fmt.Errorf("ошибка HTTP запроса: %v", err)
+			return "", nil, nil, nil, fmt.Errorf("ошибка HTTP запроса: %v", err)
 		}
 
 		if resp.StatusCode != http.StatusOK {
@@ -280,40 +303,21 @@ func (m *Model) sendToGeminiAPIStreaming(modelName string, payload map[string]an
 			_ = resp.Body.Close()
 
 			// Обработка ошибки 429 (quota exceeded)
-			if resp.StatusCode == 429 && attempt < maxRetries {
-				var errorResp struct {
-					Error struct {
-						Details []map[string]any `json:"details"`
-					} `json:"error"`
-				}
-
-				retryDelay := 5 * time.Second
-
-				if json.Unmarshal(responseBody, &errorResp) == nil {
-					for _, detail := range errorResp.Error.Details {
-						if detail["@type"] == "type.googleapis.com/google.rpc.RetryInfo" {
-							if retryDelayStr, ok := detail["retryDelay"].(string); ok {
-								if duration, err := time.ParseDuration(retryDelayStr); err == nil {
-									retryDelay = duration
-								}
-							}
-						}
+			if resp.StatusCode == http.StatusTooManyRequests && !providererr.QuotaExhausted(resp.StatusCode, responseBody) {
+				if delay, ok := mode.Retry.RateLimitDelay(attempt, googleRetryDelay(resp.Header, responseBody)); ok {
+					if err := m.sleep(ctx, delay); err != nil {
+						return "", nil, nil, nil, err
 					}
+					continue
 				}
-
-				//logger.Warn("Квота Google API превышена (429), retry через %v (попытка %d/%d)",
-				//	retryDelay, attempt+1, maxRetries, userID)
-
-				time.Sleep(retryDelay)
-				continue
 			}
 
-			return "", nil, nil, fmt.Errorf("API вернул статус %d: %s", resp.StatusCode, string(responseBody))
+			return "", nil, nil, nil, providererr.New("Google", resp.StatusCode, resp.Header, responseBody)
 		}
 
 		// Обрабатываем SSE поток в отдельной функции, чтобы defer корректно
 		// закрывал тело ответа в конце каждой итерации, а не в конце внешней функции.
-		fullText, usageMetadata, functionCalls, err := func(body io.ReadCloser) (string, map[string]any, []map[string]any, error) {
+		fullText, usageMetadata, functionCalls, citations, err := func(body io.ReadCloser) (string, map[string]any, []map[string]any, []model.Citation, error) {
 			defer func() { _ = body.Close() }()
 
 			scanner := bufio.NewScanner(body)
@@ -325,6 +329,7 @@ func (m *Model) sendToGeminiAPIStreaming(modelName string, payload map[string]an
 			var fullText strings.Builder
 			var usageMetadata map[string]any
 			var functionCalls []map[string]any
+			var citations []model.Citation
 
 			eventCount := 0
 
@@ -353,7 +358,11 @@ func (m *Model) sendToGeminiAPIStreaming(modelName string, payload map[string]an
 								FunctionCall map[string]any `json:"functionCall,omitempty"`
 							} `json:"parts"`
 						} `json:"content"`
+						GroundingMetadata *groundingMetadata `json:"groundingMetadata,omitempty"`
 					} `json:"candidates"`
+					PromptFeedback struct {
+						BlockReason string `json:"blockReason"`
+					} `json:"promptFeedback"`
 					UsageMetadata map[string]any `json:"usageMetadata,omitempty"`
 				}
 
@@ -362,6 +371,11 @@ func (m *Model) sendToGeminiAPIStreaming(modelName string, payload map[string]an
 					continue
 				}
 
+				// Вопрос отклонён фильтром Gemini: кандидатов не будет
+				if reason := sseEvent.PromptFeedback.BlockReason; reason != "" {
+					return "", nil, nil, nil, providererr.Blocked("Google", reason)
+				}
+
 				// Извлекаем текстовую дельту
 				if len(sseEvent.Candidates) > 0 && len(sseEvent.Candidates[0].Content.Parts) > 0 {
 					for _, part := range sseEvent.Candidates[0].Content.Parts {
@@ -373,7 +387,7 @@ func (m *Model) sendToGeminiAPIStreaming(modelName string, payload map[string]an
 							if onDelta != nil {
 								if err := onDelta(part.Text); err != nil {
 									//logger.Warn("[SSE] Ошибка в onDelta callback: %v", err, userID)
-									return "", nil, nil, err
+									return "", nil, nil, nil, err
 								}
 							}
 						}
@@ -416,7 +430,7 @@ func (m *Model) sendToGeminiAPIStreaming(modelName string, payload map[string]an
 							if onDelta != nil {
 								if err := onDelta(functionCallEvent); err != nil {
 									//logger.Warn("[SSE] Ошибка при отправке function_call: %v", err, userID)
-									return "", nil, nil, err
+									return "", nil, nil, nil, err
 								}
 								//logger.Debug("📨 [SSE] Function call отправлен клиенту: name=%s, args_len=%d",
 								//	functionName, len(argsJSON), userID)
@@ -425,6 +439,11 @@ func (m *Model) sendToGeminiAPIStreaming(modelName string, payload map[string]an
 					}
 				}
 
+				// Источники google_search приходят в groundingMetadata (обычно в последнем чанке)
+				if len(sseEvent.Candidates) > 0 {
+					citations = appendGroundingCitations(citations, sseEvent.Candidates[0].GroundingMetadata)
+				}
+
 				// Сохраняем метаданные использования токенов (приходят в последнем чанке)
 				if sseEvent.UsageMetadata != nil {
 					usageMetadata = sseEvent.UsageMetadata
@@ -432,19 +451,50 @@ func (m *Model) sendToGeminiAPIStreaming(modelName string, payload map[string]an
 			}
 
 			if err := scanner.Err(); err != nil {
-				return "", nil, nil, fmt.Errorf("ошибка чтения SSE потока: %w", err)
+				return "", nil, nil, nil, fmt.Errorf("ошибка чтения SSE потока: %w", err)
 			}
 
-			return fullText.String(), usageMetadata, functionCalls, nil
+			return fullText.String(), usageMetadata, functionCalls, citations, nil
 		}(resp.Body)
 		if err != nil {
-			return "", nil, nil, err
+			return "", nil, nil, nil, err
 		}
 
-		return fullText, usageMetadata, functionCalls, nil
+		return fullText, usageMetadata, functionCalls, citations, nil
 	}
+}
 
-	return "", nil, nil, fmt.Errorf("превышено количество попыток retry")
+// googleRetryDelay время ожидания из ответа 429: RetryInfo.retryDelay ("11s", "27.07s")
+// или заголовок Retry-After; -1 — провайдер его не сообщил
+func googleRetryDelay(header http.Header, body []byte) time.Duration {
+	var errorResp struct {
+		Error struct {
+			Details []map[string]any `json:"details"`
+		} `json:"error"`
+	}
+	if json.Unmarshal(body, &errorResp) == nil {
+		for _, detail := range errorResp.Error.Details {
+			if detail["@type"] != "type.googleapis.com/google.rpc.RetryInfo" {
+				continue
+			}
+			if s, ok := detail["retryDelay"].(string); ok {
+				if d, err := time.ParseDuration(s); err == nil {
+					return d
+				}
+			}
+		}
+	}
+	return mode.ParseRetryAfter(header.Get("Retry-After"))
+}
+
+// sleep пауза перед повтором запроса, прерывается при остановке модели
+func (m *Model) sleep(ctx context.Context, d time.Duration) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(d):
+		return nil
+	}
 }
 
 // parseGeminiResponseWithFunctionHandling парсит ответ и обрабатывает function calls через multi-turn conversation
@@ -462,6 +512,7 @@ func (m *Model) parseGeminiResponseWithFunctionHandling(responseBody []byte, his
 					FunctionCall map[string]any `json:"functionCall,omitempty"`
 				} `json:"parts"`
 			} `json:"content"`
+			GroundingMetadata *groundingMetadata `json:"groundingMetadata,omitempty"`
 		} `json:"candidates"`
 	}
 
@@ -505,7 +556,7 @@ func (m *Model) parseGeminiResponseWithFunctionHandling(responseBody []byte, his
 
 		// Обрабатываем все функции и собираем результаты
 		for _, fc := range functionCalls {
-			result, err := m.handleFunctionCall(fc, provider, userID)
+			result, err := m.handleFunctionCall(m.ctx, fc, provider, userID)
 			if err != nil {
 				//logger.Warn("Ошибка обработки function call: %v", err)
 				continue
@@ -544,7 +595,7 @@ func (m *Model) parseGeminiResponseWithFunctionHandling(responseBody []byte, his
 		//logger.Debug("Модель вернула текст и вызвала функции")
 		for _, fc := range functionCalls {
 			//result, err := m.handleFunctionCall(fc, provider, userID)
-			_, err := m.handleFunctionCall(fc, provider, userID)
+			_, err := m.handleFunctionCall(m.ctx, fc, provider, userID)
 			if err != nil {
 				//logger.Warn("Ошибка обработки function call: %v", err)
 				continue
@@ -564,90 +615,21 @@ func (m *Model) parseGeminiResponseWithFunctionHandling(responseBody []byte, his
 		return emptyResponse, fmt.Errorf("получен пустой текст от модели")
 	}
 
-	// Пытаемся распарсить как JSON (если модель вернула структурированный ответ)
-	var assistResp model.AssistResponse
-	var rawResp map[string]any
-
-	// Сначала пытаемся распарсить ПЕРВУЮ текстовую часть как JSON
-	// (модель может отправить текст + JSON в разных частях)
-	parsedJSON := false
-	if len(textParts) > 0 {
-		// Проверяем первую часть
-		err := json.Unmarshal([]byte(textParts[0]), &rawResp)
-		if err == nil {
-			parsedJSON = true
-		} else {
-			// Пытаемся найти JSON в markdown блоке первой части
-			jsonText := extractJSONFromMarkdown(textParts[0])
-			err = json.Unmarshal([]byte(jsonText), &rawResp)
-			if err == nil {
-				parsedJSON = true
-			}
-		}
-	}
-
-	// Если не удалось распарсить первую часть, пытаемся весь объединенный текст
-	if !parsedJSON {
-		jsonText := fullText
-		err := json.Unmarshal([]byte(jsonText), &rawResp)
-		if err != nil {
-			jsonText = extractJSONFromMarkdown(fullText)
-			err = json.Unmarshal([]byte(jsonText), &rawResp)
-		}
-		if err == nil {
-			parsedJSON = true
-		}
+	// Модель может отправить текст и JSON в разных частях — сначала проверяем первую часть.
+	// Ответ проверяется по схеме; невалидный JSON исправляется моделью, иначе — текстовый режим
+	raw := fullText
+	if _, err := model.ValidateAssistResponse(textParts[0]); err == nil {
+		raw = textParts[0]
 	}
+	assistResp := model.ParseAssistResponse(userID, raw, m.schemaRepair(modelName))
 
-	if parsedJSON {
-		// Успешно распарсили как JSON объект
-		// Извлекаем поля из JSON (модель может использовать "message" вместо "Message")
-		if msg, ok := rawResp["message"].(string); ok {
-			assistResp.Message = msg
-		}
-
-		// Парсим action если есть
-		if actionData, ok := rawResp["action"].(map[string]any); ok {
-			if sendFiles, ok := actionData["send_files"].([]any); ok {
-				for _, fileIface := range sendFiles {
-					if fileMap, ok := fileIface.(map[string]any); ok {
-						file := model.File{
-							Type:     model.FileType(getStringField(fileMap, "type")),
-							URL:      getStringField(fileMap, "url"),
-							FileName: getStringField(fileMap, "file_name"),
-							Caption:  getStringField(fileMap, "caption"),
-						}
-						assistResp.Action.SendFiles = append(assistResp.Action.SendFiles, file)
-					}
-				}
-				//logger.Debug("Всего добавлено файлов в assistResp: %d", len(assistResp.Action.SendFiles))
-			}
-		}
-
-		// Парсим target и operator
-		if target, ok := rawResp["target"].(bool); ok {
-			assistResp.Meta = target
-		}
-		if operator, ok := rawResp["operator"].(bool); ok {
-			assistResp.Operator = operator
-		}
-	} else {
-		// Если не JSON, создаём простой ответ
-		assistResp = model.AssistResponse{
-			Message: fullText,
-			Action: model.Action{
-				SendFiles: []model.File{},
-			},
-			Meta:     false,
-			Operator: false,
-		}
-	}
+	assistResp.Citations = appendGroundingCitations(nil, apiResp.Candidates[0].GroundingMetadata)
 
 	return assistResp, nil
 }
 
 // handleFunctionCall обрабатывает вызов функции от модели
-func (m *Model) handleFunctionCall(functionCall map[string]any, provider create.ProviderType, userID uint32) (map[string]any, error) {
+func (m *Model) handleFunctionCall(ctx context.Context, functionCall map[string]any, provider create.ProviderType, userID uint32) (map[string]any, error) {
 	functionName, ok := functionCall["name"].(string)
 	if !ok {
 		return nil, fmt.Errorf("function call не содержит имени")
@@ -665,7 +647,7 @@ func (m *Model) handleFunctionCall(functionCall map[string]any, provider create.
 
 	// Все функции обрабатываются через action handler
 	if m.actionHandler != nil {
-		result := m.actionHandler.RunAction(m.ctx, functionName, string(argsJSON), provider, userID)
+		result := m.actionHandler.RunAction(ctx, functionName, string(argsJSON), provider, userID)
 
 		var resultMap map[string]any
 		if err := json.Unmarshal([]byte(result), &resultMap); err != nil {
@@ -729,8 +711,8 @@ func (m *Model) processVideoGeneration(userID uint32, userText string, response
 	//logger.Debug("processVideoGeneration: параметры - prompt='%s', aspect=%s, duration=%d", prompt, aspectRatio, duration)
 
 	// Генерируем видео через клиент
-	//videoData, mimeType, err := m.client.GenerateVideo(prompt, aspectRatio, duration)
-	videoData, _, err := m.client.GenerateVideo(prompt, aspectRatio, duration)
+	//videoData, mimeType, err := m.client.GenerateVideo(prompt, aspectRatio, duration, agentConfig.GenerationConfig)
+	videoData, _, err := m.client.GenerateVideo(prompt, aspectRatio, duration, agentConfig.GenerationConfig)
 	if err != nil {
 		//logger.Error("processVideoGeneration: ошибка генерации видео: %v", err)
 		response.Message += fmt.Sprintf("\n\n⚠️ К сожалению, не удалось сгенерировать видео: %v", err)
@@ -739,39 +721,17 @@ func (m *Model) processVideoGeneration(userID uint32, userText string, response
 
 	//logger.Debug("processVideoGeneration: видео успешно сгенерировано: %d bytes, %s", len(videoData), mimeType)
 
-	// Сохраняем видео через save_image (используем тот же механизм)
-	// TODO: Можно создать отдельный save_video_data endpoint
+	// Сохраняем видео в хранилище (или через save_image, если хранилище не подключено)
 	fileName := fmt.Sprintf("video_%d_%d.mp4", userID, time.Now().Unix())
+	videoURL, fileName, saveErr := model.SaveGeneratedMedia(m.ctx, m.media, m.actionHandler, m.images, provider, userID, fileName, "video/mp4", videoData)
 
-	// Кодируем в base64 для передачи
-	videoBase64 := base64.StdEncoding.EncodeToString(videoData)
-
-	args := fmt.Sprintf(`{"image_data":"%s","file_name":"%s"}`,
-		videoBase64, fileName)
-
-	result := m.actionHandler.RunAction(m.ctx, "save_image", args, provider, userID)
-
-	// Парсим результат сохранения
-	var saveResult struct {
-		Success bool   `json:"success"`
-		URL     string `json:"url"`
-		Error   string `json:"error"`
-	}
-
-	// Пробуем распарсить как JSON
-	if err := json.Unmarshal([]byte(result), &saveResult); err != nil {
-		// Если не JSON, возможно это просто URL
-		saveResult.URL = strings.TrimSpace(result)
-		saveResult.Success = saveResult.URL != "" && !strings.Contains(saveResult.URL, "error")
-	}
-
-	if saveResult.Success && saveResult.URL != "" {
-		//logger.Debug("processVideoGeneration: видео сохранено: URL=%s", saveResult.URL)
+	if saveErr == nil {
+		//logger.Debug("processVideoGeneration: видео сохранено: URL=%s", videoURL)
 
 		// Добавляем в send_files
 		response.Action.SendFiles = append(response.Action.SendFiles, model.File{
 			Type:     "video",
-			URL:      saveResult.URL,
+			URL:      videoURL,
 			FileName: fileName,
 			Caption:  fmt.Sprintf("🎬 Сгенерированное видео: %s", prompt),
 		})
@@ -779,7 +739,7 @@ func (m *Model) processVideoGeneration(userID uint32, userText string, response
 		// Обновляем сообщение
 		response.Message += "\n\n✅ Видео успешно создано!"
 	} else {
-		//logger.Error("processVideoGeneration: ошибка сохранения видео: %s", saveResult.Error)
+		//logger.Error("processVideoGeneration: ошибка сохранения видео: %v", saveErr)
 		response.Message += "\n\n⚠️ Видео сгенерировано, но не удалось сохранить."
 	}
 
@@ -843,12 +803,31 @@ func (m *Model) isVideoEnabled(config *GoogleAgentConfig) bool {
 	return strings.Contains(sysInstr, "ГЕНЕРАЦИЯ ВИДЕО") || strings.Contains(sysInstr, "VIDEO GENERATION")
 }
 
-// getStringField извлекает строковое значение из map
-func getStringField(m map[string]any, key string) string {
-	if val, ok := m[key].(string); ok {
-		return val
+// groundingMetadata источники, найденные инструментом google_search
+type groundingMetadata struct {
+	GroundingChunks []struct {
+		Web *struct {
+			URI   string `json:"uri"`
+			Title string `json:"title"`
+		} `json:"web,omitempty"`
+	} `json:"groundingChunks,omitempty"`
+}
+
+// appendGroundingCitations добавляет веб-источники из groundingMetadata, пропуская повторы
+func appendGroundingCitations(citations []model.Citation, meta *groundingMetadata) []model.Citation {
+	if meta == nil {
+		return citations
 	}
-	return ""
+	for _, chunk := range meta.GroundingChunks {
+		if chunk.Web == nil || chunk.Web.URI == "" {
+			continue
+		}
+		if slices.ContainsFunc(citations, func(c model.Citation) bool { return c.URL == chunk.Web.URI }) {
+			continue
+		}
+		citations = append(citations, model.Citation{URL: chunk.Web.URI, Title: chunk.Web.Title})
+	}
+	return citations
 }
 
 // processImageGeneration автоматически генерирует изображение если модель включила Image
@@ -895,7 +874,7 @@ func (m *Model) processImageGeneration(userID uint32, userText string, response
 	//logger.Debug("processImageGeneration: параметры - prompt='%s', aspect=%s", prompt, aspectRatio)
 
 	// Генерируем изображение через Google Imagen API
-	imageData, mimeType, err := m.client.GenerateImage(prompt, aspectRatio)
+	imageData, mimeType, err := m.client.GenerateImage(prompt, aspectRatio, agentConfig.GenerationConfig)
 	if err != nil {
 		//logger.Error("processImageGeneration: ошибка генерации изображения: %v", err)
 		response.Message += fmt.Sprintf("\n\n⚠️ К сожалению, не удалось сгенерировать изображение: %v", err)
@@ -912,31 +891,11 @@ func (m *Model) processImageGeneration(userID uint32, userText string, response
 
 	fileName := fmt.Sprintf("image_%d_%d.%s", userID, time.Now().Unix(), ext)
 
-	// Кодируем в base64 для передачи в save_image
-	imageBase64 := base64.StdEncoding.EncodeToString(imageData)
-
-	// Сохраняем через action handler
-	args := fmt.Sprintf(`{"image_data":"%s","file_name":"%s"}`,
-		imageBase64, fileName)
+	// Сохраняем в хранилище (или через save_image, если хранилище не подключено)
+	imageURL, fileName, saveErr := model.SaveGeneratedMedia(m.ctx, m.media, m.actionHandler, m.images, provider, userID, fileName, mimeType, imageData)
 
-	result := m.actionHandler.RunAction(m.ctx, "save_image", args, provider, userID)
-
-	// Парсим результат сохранения
-	var saveResult struct {
-		Success bool   `json:"success"`
-		URL     string `json:"url"`
-		Error   string `json:"error"`
-	}
-
-	// Пробуем распарсить как JSON
-	if err := json.Unmarshal([]byte(result), &saveResult); err != nil {
-		// Если не JSON, возможно это просто URL
-		saveResult.URL = strings.TrimSpace(result)
-		saveResult.Success = saveResult.URL != "" && !strings.Contains(saveResult.URL, "error")
-	}
-
-	if saveResult.Success && saveResult.URL != "" {
-		//logger.Debug("processImageGeneration: изображение сохранено: URL=%s", saveResult.URL)
+	if saveErr == nil {
+		//logger.Debug("processImageGeneration: изображение сохранено: URL=%s", imageURL)
 
 		// Удаляем все fake URL из send_files (example.com, placeholder и т.д.)
 		cleanedFiles := []model.File{}
@@ -955,7 +914,7 @@ func (m *Model) processImageGeneration(userID uint32, userText string, response
 		// Добавляем реальное изображение
 		response.Action.SendFiles = append(response.Action.SendFiles, model.File{
 			Type:     "photo",
-			URL:      saveResult.URL,
+			URL:      imageURL,
 			FileName: fileName,
 			Caption:  response.Message, // Используем message модели как caption
 		})
@@ -967,7 +926,7 @@ func (m *Model) processImageGeneration(userID uint32, userText string, response
 
 		//logger.Debug("processImageGeneration: добавлено реальное изображение в send_files")
 	} else {
-		//logger.Error("processImageGeneration: ошибка сохранения изображения: %s", saveResult.Error)
+		//logger.Error("processImageGeneration: ошибка сохранения изображения: %v", saveErr)
 		response.Message += "\n\n⚠️ Изображение сгенерировано, но не удалось сохранить."
 	}
 
@@ -1014,36 +973,6 @@ func (m *Model) extractImagePrompt(userText, modelResponse string) string {
 	return prompt
 }
 
-// extractJSONFromMarkdown извлекает JSON из markdown блока ```json...``` если он есть
-// Возвращает очищенный JSON для парсинга (без markdown)
-func extractJSONFromMarkdown(text string) string {
-	// Проверяем наличие markdown блока
-	if strings.HasPrefix(strings.TrimSpace(text), "```") {
-		// Удаляем открывающий блок ```json или ```
-		lines := strings.Split(text, "\n")
-		if len(lines) > 0 {
-			// Пропускаем первую строку если это ```json или ```
-			start := 0
-			if strings.HasPrefix(strings.TrimSpace(lines[0]), "```") {
-				start = 1
-			}
-
-			// Пропускаем последнюю строку если это ```
-			end := len(lines)
-			if end > start && strings.TrimSpace(lines[end-1]) == "```" {
-				end--
-			}
-
-			// Объединяем оставшиеся строки
-			if start < end {
-				return strings.Join(lines[start:end], "\n")
-			}
-		}
-	}
-
-	return text
-}
-
 type ragResp struct {
 	contextText string
 	err         error
@@ -1144,7 +1073,7 @@ func (m *Model) applyRAG(userID uint32, dialogID uint64, text string, ch chan<-
 	result.responderLoadDuration = time.Since(responderStart)
 
 	// === 3. Проверяем нужен ли RAG ===
-	if !resp.AgentConfig.HasVector || len(resp.AgentConfig.VectorIds) == 0 || text == "" {
+	if !resp.AgentConfig.HasVector || len(resp.AgentConfig.VectorIds) == 0 || text == "" || m.contextCacheHasDocs(resp.AgentConfig) {
 		//logger.Debug("applyRAG: RAG не требуется (HasVector=%v, VectorIds=%d, text=%q)",
 		//	resp.AgentConfig.HasVector, len(resp.AgentConfig.VectorIds), text != "", userID)
 		// Отправляем результат без RAG контекста
@@ -1189,12 +1118,14 @@ func (m *Model) applyRAG(userID uint32, dialogID uint64, text string, ch chan<-
 	}
 
 	// === 6. Формируем обогащённый контекст ===
-	if len(relevantDocs) > 0 {
-		var relevantChunks []string
-		for _, doc := range relevantDocs {
-			relevantChunks = append(relevantChunks, doc.Content)
-		}
+	// Фрагменты базы знаний — недоверенный текст: строки с prompt injection удаляются
+	var relevantChunks []string
+	for _, doc := range relevantDocs {
+		relevantChunks = append(relevantChunks, doc.Content)
+	}
+	relevantChunks = m.injection.SanitizeChunks(userID, relevantChunks)
 
+	if len(relevantChunks) > 0 {
 		contextText := strings.Join(relevantChunks, "\n\n---\n\n")
 		enhancedText := fmt.Sprintf(`Relevant knowledge base context:
 %s
@@ -1222,6 +1153,14 @@ User query: %s`, contextText, text)
 // Использует Google Gemini streamGenerateContent API для получения ответов в реальном времени
 // onDelta вызывается для каждого delta-события, в финальной дельте передаются данные о токенах
 func (m *Model) RequestStreaming(userID uint32, dialogID uint64, text string, onDelta func(delta string, done bool) error, files ...model.FileUpload) error {
+	return m.RequestStreamingContext(model.TraceContext(m.ctx, dialogID), userID, dialogID, text, onDelta, files...)
+}
+
+// RequestStreamingContext RequestStreaming, прерываемый отменой ctx
+func (m *Model) RequestStreamingContext(ctx context.Context, userID uint32, dialogID uint64, text string, onDelta func(delta string, done bool) error, files ...model.FileUpload) error {
+	ctx, cancel := model.RequestContext(m.ctx, model.TraceContext(ctx, dialogID))
+	defer cancel()
+
 	if text == "" && len(files) == 0 {
 		return fmt.Errorf("пустое сообщение и нет файлов")
 	}
@@ -1231,13 +1170,19 @@ func (m *Model) RequestStreaming(userID uint32, dialogID uint64, text string, on
 	// всех тяжёлых операций (загрузка истории, получение респондента, эмбеддинги)
 	// ============================================================================
 	ragCh := make(chan ragResp, 1)
-	go m.applyRAG(userID, dialogID, text, ragCh)
+	safego.Go("google.applyRAG", func() { m.applyRAG(userID, dialogID, text, ragCh) },
+		safego.WithUserID(userID), safego.WithOnPanic(func(rec any) {
+			select {
+			case ragCh <- ragResp{err: fmt.Errorf("паника в applyRAG: %v", rec)}:
+			default:
+			}
+		}))
 
 	// Ждём результат RAG из горутины
 	// Он содержит: history, resp, contextText и метрики производительности
 	var ragResult ragResp
 	select {
-	case <-m.ctx.Done():
+	case <-ctx.Done():
 		return fmt.Errorf("контекст отменён")
 	case ragResult = <-ragCh:
 		if ragResult.err != nil {
@@ -1271,7 +1216,7 @@ func (m *Model) RequestStreaming(userID uint32, dialogID uint64, text string, on
 			if m.actionHandler == nil {
 				result = `{"error": "action handler not initialized"}`
 			} else {
-				result = m.actionHandler.RunAction(m.ctx, functionName, arguments, resp.Assist.Provider, userID)
+				result = m.actionHandler.RunAction(model.WithDialogID(ctx, dialogID), functionName, arguments, resp.Assist.Provider, userID)
 			}
 
 			//logger.Debug("🔧 [Google] Выполнена функция %s → %s", functionName, result, userID)
@@ -1288,11 +1233,20 @@ func (m *Model) RequestStreaming(userID uint32, dialogID uint64, text string, on
 	// Обновляем TTL респондента
 	resp.TTL = time.Now().Add(m.UserModelTTl)
 
+	// Вариант A/B-эксперимента диалога
+	assignment, hasVariant := m.experiments.Assign(userID, dialogID)
+
+	// Явный кэш контекста не используется, если вариант подменяет промпт или модель
+	cacheName, cacheHasDocs := "", false
+	if !hasVariant || (assignment.Prompt == "" && assignment.ModelName == "") {
+		cacheName, cacheHasDocs = m.activeContextCache(userID, resp.AgentConfig)
+	}
+
 	// Формируем enhancedText
 	enhancedText := text
 
-	// Если RAG нашёл контекст - используем его
-	if ragResult.contextText != "" {
+	// Если RAG нашёл контекст - используем его (база знаний в кэше контекста его заменяет)
+	if ragResult.contextText != "" && !cacheHasDocs {
 		enhancedText = ragResult.contextText
 		//logger.Info("[USER:%d] RAG: добавлено контекста (%d символов)", userID, len(ragResult.contextText))
 	}
@@ -1300,7 +1254,7 @@ func (m *Model) RequestStreaming(userID uint32, dialogID uint64, text string, on
 	// Ждём результат RAG из горутины (он может прийти раньше, позже или вообще не прийти если что-то пошло по дуге)
 	ragContent := ""
 	select {
-	case <-m.ctx.Done():
+	case <-ctx.Done():
 	case ragRes := <-ragCh:
 		if ragRes.err != nil {
 			//logger.Warn("RAG error: %v, продолжаем без RAG", ragRes.err, userID)
@@ -1312,12 +1266,12 @@ func (m *Model) RequestStreaming(userID uint32, dialogID uint64, text string, on
 	}
 
 	// Если RAG результат получен - добавляем его в начало enhancedText
-	if ragContent != "" {
+	if ragContent != "" && !cacheHasDocs {
 		enhancedText = ragContent + "\n" + enhancedText
 	}
 
 	// Добавляем новое сообщение пользователя (с обогащённым текстом если был RAG)
-	userMessage := m.createUserMessage(enhancedText, files)
+	userMessage := m.createUserMessage(userID, enhancedText, files)
 	history = append(history, userMessage)
 
 	// Сохраняем в кэш
@@ -1327,18 +1281,50 @@ func (m *Model) RequestStreaming(userID uint32, dialogID uint64, text string, on
 	// Сначала добавляем конфигурацию агента
 	payload := map[string]any{}
 
-	if resp.AgentConfig.SystemInstruction != nil {
+	// system_instruction и tools запрещено передавать вместе с cachedContent — они уже в кэше
+	if cacheName != "" {
+		payload["cachedContent"] = cacheName
+	} else if resp.AgentConfig.SystemInstruction != nil {
 		payload["system_instruction"] = resp.AgentConfig.SystemInstruction
 	}
 
+	// Копия: ниже в generationConfig добавляются схема ответа и thinkingConfig,
+	// а конфигурация агента общая для всех диалогов
 	if resp.AgentConfig.GenerationConfig != nil {
-		payload["generationConfig"] = resp.AgentConfig.GenerationConfig
+		payload["generationConfig"] = maps.Clone(resp.AgentConfig.GenerationConfig)
+	}
+
+	if len(resp.AgentConfig.SafetySettings) > 0 {
+		payload["safetySettings"] = resp.AgentConfig.SafetySettings
+	}
+
+	modelName := resp.AgentConfig.ModelName
+	variantPrompt := false
+	if hasVariant {
+		modelName = applyVariant(payload, resp.AgentConfig, assignment.Variant)
+		variantPrompt = assignment.Prompt != ""
+	}
+
+	// Детерминированный режим: seed и нулевая температура поверх параметров ассистента и варианта эксперимента
+	if seed, ok := m.seeds.Get(dialogID); ok {
+		if payload["generationConfig"] == nil {
+			payload["generationConfig"] = map[string]any{}
+		}
+		maps.Copy(payload["generationConfig"].(map[string]any), (*create.GenerationParams)(nil).Deterministic(seed).Gemini())
+	}
+
+	// Промпт, инструменты и история должны поместиться в контекст модели
+	history, err := m.fitContext(userID, dialogID, modelName, resp.AgentConfig, cacheName != "", history)
+	if err != nil {
+		return err
 	}
 
 	hasTools := len(resp.AgentConfig.Tools) > 0
 
 	if hasTools {
-		payload["tools"] = resp.AgentConfig.Tools
+		if cacheName == "" {
+			payload["tools"] = resp.AgentConfig.Tools
+		}
 
 		if genConfig, ok := payload["generationConfig"].(map[string]any); ok {
 			delete(genConfig, "response_schema")
@@ -1348,7 +1334,7 @@ func (m *Model) RequestStreaming(userID uint32, dialogID uint64, text string, on
 		// Добавляем JSON reminder в начало истории
 		// Инструкции по инструментам (calendar, sheets и пр.) приходят от MCP через FetchSystemPrompt —
 		// здесь только базовое напоминание о формате JSON-ответа.
-		jsonReminderText := "IMPORTANT: All responses MUST be strictly in JSON format according to schema:\n" + create.GoogleSchemaJSON + "\n\nNever respond with plain text!"
+		jsonReminderText := "IMPORTANT: All responses MUST be strictly in JSON format according to schema:\n" + resp.AgentConfig.responseSchema().PromptText() + "\n\nNever respond with plain text!"
 
 		jsonReminderMessage := GoogleContent{
 			Role: "user",
@@ -1384,13 +1370,22 @@ func (m *Model) RequestStreaming(userID uint32, dialogID uint64, text string, on
 
 		genConfig := payload["generationConfig"].(map[string]any)
 		genConfig["response_mime_type"] = "application/json"
-		genConfig["response_schema"] = create.ParseModelSchemaJSON(false) // false = БЕЗ additionalProperties для Google
+		genConfig["response_schema"] = resp.AgentConfig.responseSchema().Gemini()
+	}
+
+	if budget := resp.AgentConfig.Reasoning.ThinkingBudget(); budget > 0 {
+		genConfig, _ := payload["generationConfig"].(map[string]any)
+		if genConfig == nil {
+			genConfig = map[string]any{}
+			payload["generationConfig"] = genConfig
+		}
+		genConfig["thinkingConfig"] = map[string]any{"thinkingBudget": budget}
 	}
 
 	payload["contents"] = history
 
 	// Вызываем стриминг API
-	fullText, usageMetadata, functionCalls, err := m.sendToGeminiAPIStreaming(resp.AgentConfig.ModelName, payload, func(delta string) error {
+	fullText, usageMetadata, functionCalls, citations, err := m.sendToGeminiAPIStreaming(ctx, modelName, payload, func(delta string) error {
 		if onDelta != nil {
 			return onDelta(delta, false) // done=false для промежуточных дельт
 		}
@@ -1517,7 +1512,7 @@ func (m *Model) RequestStreaming(userID uint32, dialogID uint64, text string, on
 			//logger.Debug("onToolCall не указан, используем синхронную обработку функций", userID)
 
 			for _, fc := range functionCalls {
-				result, err := m.handleFunctionCall(fc, resp.Assist.Provider, resp.Assist.UserID)
+				result, err := m.handleFunctionCall(model.WithDialogID(ctx, dialogID), fc, resp.Assist.Provider, resp.Assist.UserID)
 				if err != nil {
 					//logger.Warn("Ошибка обработки function call: %v", userID, err)
 					continue
@@ -1547,7 +1542,7 @@ func (m *Model) RequestStreaming(userID uint32, dialogID uint64, text string, on
 
 		// Повторяем запрос к Gemini (модель должна вернуть текст с результатами)
 		//logger.Debug("Отправляем повторный запрос к Gemini с результатами функций", userID)
-		fullText, usageMetadata, _, err = m.sendToGeminiAPIStreaming(resp.AgentConfig.ModelName, payload, func(delta string) error {
+		fullText, usageMetadata, _, citations, err = m.sendToGeminiAPIStreaming(ctx, modelName, payload, func(delta string) error {
 			if onDelta != nil {
 				return onDelta(delta, false)
 			}
@@ -1561,58 +1556,15 @@ func (m *Model) RequestStreaming(userID uint32, dialogID uint64, text string, on
 		//logger.Debug("Получен финальный ответ после выполнения функций: len=%d", userID, len(fullText))
 	}
 
-	// Очищаем fullText от markdown-обёрток (Google иногда добавляет ```json ... ```)
-	cleanedText := fullText
-	cleanedText = strings.TrimSpace(cleanedText)
-
-	// Удаляем ```json в начале и ``` в конце
-	if strings.HasPrefix(cleanedText, "```json") {
-		cleanedText = strings.TrimPrefix(cleanedText, "```json")
-		cleanedText = strings.TrimSpace(cleanedText)
-	} else if strings.HasPrefix(cleanedText, "```") {
-		cleanedText = strings.TrimPrefix(cleanedText, "```")
-		cleanedText = strings.TrimSpace(cleanedText)
-	}
-
-	if strings.HasSuffix(cleanedText, "```") {
-		cleanedText = strings.TrimSuffix(cleanedText, "```")
-		cleanedText = strings.TrimSpace(cleanedText)
-	}
-
-	// Парсим финальный ответ из накопленного текста
-	var assistResponse model.AssistResponse
-
-	// Google Gemini может возвращать как JSON (с системным промптом), так и обычный текст
-	if len(cleanedText) > 0 && (cleanedText[0] == '{' || cleanedText[0] == '"') {
-		// Пытаемся распарсить как JSON
-		if err := unmarshalGoogleAssistResponse(cleanedText, &assistResponse); err != nil {
-			//logger.Warn("Не удалось распарсить JSON ответ (длина=%d, ошибка=%v), используем как текст",
-			//	len(cleanedText), err, userID)
-			// JSON невалидный - используем как обычный текст
-			assistResponse = model.AssistResponse{
-				Message: cleanedText,
-				Action: model.Action{
-					SendFiles: []model.File{},
-				},
-				Meta:     false,
-				Operator: false,
-			}
-		}
-	} else {
-		// Это обычный текст, не JSON
-		assistResponse = model.AssistResponse{
-			Message: cleanedText,
-			Action: model.Action{
-				SendFiles: []model.File{},
-			},
-			Meta:     false,
-			Operator: false,
-		}
-	}
+	// Google Gemini может возвращать как JSON (с системным промптом), так и обычный текст.
+	// Ответ проверяется по схеме; невалидный JSON исправляется моделью, иначе — текстовый режим
+	cleanedText := strings.TrimSpace(fullText)
+	assistResponse := model.ParseAssistResponse(userID, cleanedText, m.schemaRepair(modelName), resp.AgentConfig.ResponseFields...)
 
 	if assistResponse.Message == "" && cleanedText != "" {
 		assistResponse.Message = cleanedText
 	}
+	assistResponse.Citations = citations
 
 	// Обработка автоматической генерации видео и изображений (если включены)
 	if userID > 0 && text != "" {
@@ -1631,6 +1583,11 @@ func (m *Model) RequestStreaming(userID uint32, dialogID uint64, text string, on
 	modelMessage := m.createModelMessage(assistResponse)
 	m.addMessageToCache(dialogID, modelMessage)
 
+	// Отмечаем версию промпта, которой обслужен ход (для разбора регрессий)
+	if cfg := resp.AgentConfig; !variantPrompt {
+		create.RecordPromptTurn(m.db, userID, dialogID, cfg.ModelId, cfg.PromptVersion)
+	}
+
 	// Сериализуем финальный ответ обратно в JSON для отправки клиенту
 	responseJSON, err := json.Marshal(assistResponse)
 	if err != nil {
@@ -1716,24 +1673,26 @@ func (m *Model) RequestStreaming(userID uint32, dialogID uint64, text string, on
 	return nil
 }
 
-// unmarshalGoogleAssistResponse разбирает структурированный ответ Gemini,
-// включая случай, когда JSON пришёл как экранированная JSON-строка.
-func unmarshalGoogleAssistResponse(text string, response *model.AssistResponse) error {
-	cleaned := strings.TrimSpace(text)
-	if strings.HasPrefix(cleaned, "```") {
-		if strings.HasPrefix(cleaned, "```json") || strings.HasPrefix(cleaned, "```JSON") {
-			cleaned = strings.TrimSpace(cleaned[7:])
-		} else {
-			cleaned = strings.TrimSpace(cleaned[3:])
+// applyVariant переопределяет в payload температуру и промпт варианта v, возвращает имя модели.
+// generationConfig копируется: конфигурация агента общая для всех диалогов.
+func applyVariant(payload map[string]any, cfg *GoogleAgentConfig, v experiment.Variant) string {
+	if v.Temperature != nil {
+		genConfig := make(map[string]any, len(cfg.GenerationConfig)+1)
+		for k, val := range cfg.GenerationConfig {
+			genConfig[k] = val
 		}
-		cleaned = strings.TrimSpace(strings.TrimSuffix(cleaned, "```"))
+		genConfig["temperature"] = *v.Temperature
+		payload["generationConfig"] = genConfig
 	}
-	if err := json.Unmarshal([]byte(cleaned), response); err == nil {
-		return nil
+	if v.Prompt != "" {
+		text := v.Prompt
+		if cfg.PromptHint != "" {
+			text += "\n\n" + cfg.PromptHint
+		}
+		payload["system_instruction"] = map[string]any{"parts": []map[string]any{{"text": text}}}
 	}
-	var encoded string
-	if err := json.Unmarshal([]byte(cleaned), &encoded); err != nil {
-		return err
+	if v.ModelName != "" {
+		return v.ModelName
 	}
-	return json.Unmarshal([]byte(encoded), response)
+	return cfg.ModelName
 }
@@ -1,18 +1,38 @@
 package google
 
 import (
+	"encoding/json"
+	"strings"
 	"testing"
 
+	"github.com/ikermy/AiR_Common/pkg/mode"
 	"github.com/ikermy/AiR_Common/pkg/model"
 )
 
-func TestUnmarshalGoogleAssistResponseJSONEncodedString(t *testing.T) {
-	input := `"{\"message\":\"Котик прекрасен\",\"action\":{\"send_files\":[{\"type\":\"photo\",\"url\":\"https://example.test/cat.jpg\",\"file_name\":\"котик.jpg\"}]}}"`
-	var response model.AssistResponse
-	if err := unmarshalGoogleAssistResponse(input, &response); err != nil {
+func TestAppendGroundingCitationsSkipsDuplicates(t *testing.T) {
+	var meta groundingMetadata
+	data := `{"groundingChunks":[{"web":{"uri":"https://a.test","title":"A"}},{"web":{"uri":"https://a.test","title":"A"}},{"retrievedContext":{}},{"web":{"uri":"https://b.test"}}]}`
+	if err := json.Unmarshal([]byte(data), &meta); err != nil {
 		t.Fatal(err)
 	}
-	if response.Message != "Котик прекрасен" || len(response.Action.SendFiles) != 1 {
-		t.Fatalf("unexpected response: %+v", response)
+	citations := appendGroundingCitations(nil, &meta)
+	if len(citations) != 2 || citations[0].Title != "A" || citations[1].URL != "https://b.test" {
+		t.Fatalf("unexpected citations: %+v", citations)
+	}
+}
+
+func TestCreateUserMessageInlinesSmallFiles(t *testing.T) {
+	prev := mode.GoogleInlineDataLimit
+	mode.GoogleInlineDataLimit = 10
+	t.Cleanup(func() { mode.GoogleInlineDataLimit = prev })
+
+	m := &Model{} // без клиента File API недоступен
+	msg := m.createUserMessage(1, "файлы", []model.FileUpload{
+		{Name: "a.pdf", MimeType: "application/pdf", Content: strings.NewReader("123456")},
+		{Name: "b.pdf", MimeType: "application/pdf", Content: strings.NewReader("123456")}, // сверх лимита запроса
+		{Name: "c.ogg", MimeType: "audio/ogg", Content: strings.NewReader("1234")},
+	})
+	if len(msg.Parts) != 3 || msg.Parts[1]["inline_data"] == nil || msg.Parts[2]["inline_data"] == nil {
+		t.Fatalf("parts: %+v", msg.Parts)
 	}
 }
@@ -0,0 +1,169 @@
+package google
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/ikermy/AiR_Common/pkg/comdb"
+	"github.com/ikermy/AiR_Common/pkg/logger"
+	"github.com/ikermy/AiR_Common/pkg/model"
+	"github.com/ikermy/AiR_Common/pkg/model/create"
+)
+
+// SnapshotStore — хранилище снимков диалогов для тёплого перезапуска (реализуется *comdb.DB).
+// Если БД модели его не реализует, снимки не сохраняются
+type SnapshotStore interface {
+	SaveDialogSnapshots(snaps []comdb.DialogSnapshot) error
+	LoadDialogSnapshots(provider string) ([]comdb.DialogSnapshot, error)
+}
+
+var _ SnapshotStore = (*comdb.DB)(nil)
+
+// snapshotStore хранилище снимков из БД модели; обёртки БД (model.WrapDBWithMasterKeyDecryption)
+// раскрываются через Unwrap
+func (m *Model) snapshotStore() (SnapshotStore, bool) {
+	var db any = m.db
+	if w, ok := db.(interface{ Unwrap() comdb.Exterior }); ok {
+		db = w.Unwrap()
+	}
+	store, ok := db.(SnapshotStore)
+	return store, ok
+}
+
+// dialogSnapshot состояние диалога в памяти: респондент с конфигурацией агента и кэш истории.
+// Сохраняется SaveAllContextDuringExit, восстанавливается RestoreContextAfterStart
+type dialogSnapshot struct {
+	RespID      uint64             `json:"resp_id"`
+	Assist      model.Assistant    `json:"assist"`
+	RespName    string             `json:"resp_name"`
+	TTL         time.Time          `json:"ttl"`
+	AgentConfig *GoogleAgentConfig `json:"agent_config,omitempty"`
+	Contents    []GoogleContent    `json:"contents,omitempty"` // nil — истории в кэше не было
+	CacheExpire time.Time          `json:"cache_expire,omitempty"`
+}
+
+// snapshots снимки всех диалогов активных респондентов
+func (m *Model) snapshots(now time.Time) []comdb.DialogSnapshot {
+	var out []comdb.DialogSnapshot
+	m.responders.Range(func(key, value any) bool {
+		respId := key.(uint64)
+		resp := value.(*GoogleRespModel)
+		if !now.Before(resp.TTL) {
+			return true
+		}
+		for dialogID := range resp.ChanMap {
+			snap := dialogSnapshot{
+				RespID:      respId,
+				Assist:      resp.Assist,
+				RespName:    resp.RespName,
+				TTL:         resp.TTL,
+				AgentConfig: resp.AgentConfig,
+			}
+			if cacheIface, ok := m.dialogCache.Load(dialogID); ok {
+				cache := cacheIface.(*DialogCache)
+				if now.Before(cache.ExpireAt) {
+					snap.Contents, _ = m.getDialogHistoryFromCache(dialogID)
+					snap.CacheExpire = cache.ExpireAt
+				}
+			}
+			data, err := json.Marshal(snap)
+			if err != nil {
+				logger.ForDialog(resp.Assist.UserID, dialogID).Warn("не удалось сохранить снимок диалога", "err", err)
+				continue
+			}
+			out = append(out, comdb.DialogSnapshot{
+				DialogID: dialogID,
+				UserID:   resp.Assist.UserID,
+				Provider: create.ProviderGoogle.String(),
+				Data:     string(data),
+				ExpireAt: resp.TTL,
+			})
+		}
+		return true
+	})
+	return out
+}
+
+// SaveAllContextDuringExit сохраняет респондентов и кэш истории диалогов в БД, чтобы новый
+// процесс продолжил их без одновременного чтения истории всех активных диалогов
+func (m *Model) SaveAllContextDuringExit() {
+	store, ok := m.snapshotStore()
+	if !ok {
+		return
+	}
+	snaps := m.snapshots(time.Now())
+	if len(snaps) == 0 {
+		return
+	}
+	if err := store.SaveDialogSnapshots(snaps); err != nil {
+		logger.Warn("GoogleModel: не удалось сохранить %d снимков диалогов: %v", len(snaps), err)
+		return
+	}
+	logger.Info("GoogleModel: сохранено снимков диалогов: %d", len(snaps))
+}
+
+// RestoreContextAfterStart восстанавливает респондентов и кэш истории из снимков
+// SaveAllContextDuringExit (model.ContextRestorer). Вызывается до приёма сообщений
+// (startpoint.New). Загруженные снимки удаляются из БД: после аварийного завершения
+// следующий запуск не восстановит устаревшую историю повторно
+func (m *Model) RestoreContextAfterStart() {
+	store, ok := m.snapshotStore()
+	if !ok {
+		return
+	}
+	snaps, err := store.LoadDialogSnapshots(create.ProviderGoogle.String())
+	if err != nil {
+		logger.Warn("GoogleModel: не удалось загрузить снимки диалогов: %v", err)
+		return
+	}
+	if n := m.restoreSnapshots(snaps, time.Now()); n > 0 {
+		logger.Info("GoogleModel: восстановлено диалогов из снимков: %d", n)
+	}
+}
+
+// restoreSnapshots восстанавливает диалоги; возвращает число восстановленных.
+// Уже созданные респонденты и кэши не перезаписываются
+func (m *Model) restoreSnapshots(snaps []comdb.DialogSnapshot, now time.Time) int {
+	restored := 0
+	for _, s := range snaps {
+		var snap dialogSnapshot
+		if err := json.Unmarshal([]byte(s.Data), &snap); err != nil {
+			logger.ForDialog(s.UserID, s.DialogID).Warn("снимок диалога повреждён", "err", err)
+			continue
+		}
+		if !now.Before(snap.TTL) {
+			continue
+		}
+
+		if val, ok := m.responders.Load(snap.RespID); ok {
+			resp := val.(*GoogleRespModel)
+			if _, exists := resp.ChanMap[s.DialogID]; !exists {
+				_, _, ch, _ := model.CreateBaseResponder(m.ctx, 0, snap.Assist, s.DialogID, snap.RespName)
+				resp.ChanMap[s.DialogID] = ch
+			}
+		} else {
+			ctx, cancel, ch, _ := model.CreateBaseResponder(m.ctx, 0, snap.Assist, s.DialogID, snap.RespName)
+			m.responders.Store(snap.RespID, &GoogleRespModel{
+				Ctx:         ctx,
+				Cancel:      cancel,
+				Chan:        ch,
+				ChanMap:     map[uint64]*model.Ch{s.DialogID: ch},
+				TTL:         snap.TTL,
+				Assist:      snap.Assist,
+				RespName:    snap.RespName,
+				AgentConfig: snap.AgentConfig,
+			})
+			model.NotifyWaitChannels(&m.waitChannels, snap.RespID)
+		}
+
+		if snap.Contents != nil && now.Before(snap.CacheExpire) {
+			m.dialogCache.LoadOrStore(s.DialogID, &DialogCache{
+				dialogID: s.DialogID,
+				Contents: snap.Contents,
+				ExpireAt: snap.CacheExpire,
+			})
+		}
+		restored++
+	}
+	return restored
+}
@@ -0,0 +1,78 @@
+package google
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ikermy/AiR_Common/pkg/model"
+)
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	now := time.Now()
+	old := &Model{ctx: context.Background()}
+	ch := &model.Ch{DialogID: 5}
+	old.responders.Store(uint64(3), &GoogleRespModel{
+		ChanMap:     map[uint64]*model.Ch{5: ch},
+		Chan:        ch,
+		TTL:         now.Add(time.Hour),
+		Assist:      model.Assistant{UserID: 7, AssistId: "a1"},
+		RespName:    "Анна",
+		AgentConfig: &GoogleAgentConfig{ModelName: "gemini-2.5-flash"},
+	})
+	old.responders.Store(uint64(4), &GoogleRespModel{
+		ChanMap: map[uint64]*model.Ch{6: {DialogID: 6}},
+		TTL:     now.Add(-time.Minute), // истёк — не сохраняется
+	})
+	old.dialogCache.Store(uint64(5), &DialogCache{
+		dialogID: 5,
+		Contents: []GoogleContent{{Role: "user", Parts: []map[string]any{{"text": "привет"}}}},
+		ExpireAt: now.Add(10 * time.Minute),
+	})
+
+	snaps := old.snapshots(now)
+	if len(snaps) != 1 || snaps[0].DialogID != 5 || snaps[0].UserID != 7 {
+		t.Fatalf("unexpected snapshots: %+v", snaps)
+	}
+
+	fresh := &Model{ctx: context.Background()}
+	if n := fresh.restoreSnapshots(snaps, now); n != 1 {
+		t.Fatalf("expected 1 restored dialog, got %d", n)
+	}
+	val, ok := fresh.responders.Load(uint64(3))
+	if !ok {
+		t.Fatalf("responder not restored")
+	}
+	resp := val.(*GoogleRespModel)
+	if resp.Assist.UserID != 7 || resp.RespName != "Анна" || resp.AgentConfig == nil ||
+		resp.AgentConfig.ModelName != "gemini-2.5-flash" || resp.ChanMap[5] == nil {
+		t.Fatalf("unexpected restored responder: %+v", resp)
+	}
+	history, found := fresh.getDialogHistoryFromCache(5)
+	if !found || len(history) != 1 || history[0].Parts[0]["text"] != "привет" {
+		t.Fatalf("dialog cache not restored: %+v", history)
+	}
+
+	// Снимок, пролежавший дольше TTL респондента, не восстанавливается
+	late := &Model{ctx: context.Background()}
+	if n := late.restoreSnapshots(snaps, now.Add(2*time.Hour)); n != 0 {
+		t.Fatalf("expired snapshot restored")
+	}
+}
+
+func TestFilesInUse(t *testing.T) {
+	m := &Model{ctx: context.Background()}
+	m.dialogCache.Store(uint64(1), &DialogCache{Contents: []GoogleContent{{Role: "user", Parts: []map[string]any{
+		{"text": "смотри файл"},
+		{"fileData": map[string]string{"mimeType": "application/pdf", "fileUri": "https://g/files/a"}},
+	}}}})
+	// История, восстановленная из JSON-снимка
+	m.dialogCache.Store(uint64(2), &DialogCache{Contents: []GoogleContent{{Role: "user", Parts: []map[string]any{
+		{"fileData": map[string]any{"mimeType": "video/mp4", "fileUri": "https://g/files/b"}},
+	}}}})
+
+	uris := m.filesInUse()
+	if len(uris) != 2 || !uris["https://g/files/a"] || !uris["https://g/files/b"] {
+		t.Fatalf("files in use: %v", uris)
+	}
+}
@@ -0,0 +1,40 @@
+// Package grok подключает xAI Grok через OpenAI-совместимый Chat Completions API.
+// Диалоги обслуживает chatapi.Model; флаг WebSearch модели пользователя включает
+// Live Search xAI (search_parameters), функции MCP и response_format — как у OpenRouter.
+//
+//	router := model.NewModelRouter(ctx, db, grok.NewAsRouterOption())
+package grok
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ikermy/AiR_Common/pkg/model"
+	"github.com/ikermy/AiR_Common/pkg/model/chatapi"
+	"github.com/ikermy/AiR_Common/pkg/model/create"
+)
+
+// New создаёт модель Grok; API-ключ пользователя читается из БД
+func New(ctx context.Context, d chatapi.DB, actionHandler model.ActionHandler) *chatapi.Model {
+	client := create.NewGrokClient(func(userID uint32) string {
+		if key, err := d.GetUserAPIKey(userID, create.ProviderGrok); err == nil {
+			return key
+		}
+		return ""
+	})
+	return chatapi.New(ctx, d, create.ProviderGrok, client, actionHandler,
+		chatapi.WithRequestExtra(create.GrokSearchParameters))
+}
+
+// NewAsRouterOption создаёт модель Grok и возвращает её как опцию для ModelRouter
+func NewAsRouterOption() model.RouterOption {
+	return func(r *model.Router, ctx context.Context, db model.DB) error {
+		routerDB, ok := db.(chatapi.DB)
+		if !ok {
+			return fmt.Errorf("DB не соответствует интерфейсу chatapi.DB")
+		}
+		m := New(ctx, routerDB, model.NewUniversalActionHandler(ctx))
+		m.SetUniversalModel(create.New(ctx, routerDB))
+		return model.WithGrokModel(m)(r, ctx, db)
+	}
+}
@@ -7,6 +7,8 @@ import (
 	"strings"
 	"sync"
 	"time"
+
+	"github.com/ikermy/AiR_Common/pkg/logger"
 )
 
 // StreamingToSync буферизирует вызов RequestStreaming и возвращает AssistResponse.
@@ -48,6 +50,27 @@ func StreamingToSync(
 	return resp, nil
 }
 
+// TraceContext ctx с trace_id текущего хода диалога (logger.DialogTrace), если в ctx его ещё нет.
+// Провайдеры оборачивают им контекст на входе Request/RequestStreaming, чтобы вызовы
+// инструментов и HTTP-запросы хода несли trace_id и без контекста вызывающего
+func TraceContext(ctx context.Context, dialogID uint64) context.Context {
+	if logger.TraceIDFrom(ctx) != "" {
+		return ctx
+	}
+	return logger.WithTraceID(ctx, logger.DialogTrace(dialogID))
+}
+
+// RequestContext контекст одного запроса к провайдеру: отменяется вместе с ctx вызывающего
+// (тайм-аут ожидания ответа) и с base — контекстом модели при её остановке
+func RequestContext(base, ctx context.Context) (context.Context, context.CancelFunc) {
+	reqCtx, cancel := context.WithCancel(ctx)
+	stop := context.AfterFunc(base, cancel)
+	return reqCtx, func() {
+		stop()
+		cancel()
+	}
+}
+
 // ============================================================================
 // CHANNEL PROVIDER INTERFACE
 // ============================================================================
@@ -361,3 +384,50 @@ func ParseDialogHistory(rawData []byte) ([]DialogMessageBase, error) {
 	// Все попытки провалились - возвращаем пустой результат
 	return []DialogMessageBase{}, nil
 }
+
+// DialogTurn — реплика истории в нейтральном для провайдеров виде
+type DialogTurn struct {
+	Role string // "user" или "assistant"
+	Text string
+}
+
+// DialogTurns переводит историю диалога из БД в последовательность реплик.
+// История в БД не зависит от провайдера, поэтому после смены провайдера модели
+// её можно передать новому провайдеру в его формате запроса
+func DialogTurns(rawData []byte) ([]DialogTurn, error) {
+	parsed, err := ParseDialogHistory(rawData)
+	if err != nil {
+		return nil, err
+	}
+
+	turns := make([]DialogTurn, 0, len(parsed))
+	for _, msg := range parsed {
+		// creator: 1 = AI, 2 = User
+		role := "user"
+		switch creator := msg.Creator.(type) {
+		case float64:
+			if creator == 1 {
+				role = "assistant"
+			}
+		case string:
+			role = creator
+		}
+
+		var text string
+		switch v := msg.Message.(type) {
+		case map[string]any:
+			if s, ok := v["message"].(string); ok {
+				text = s
+			} else {
+				data, _ := json.Marshal(v)
+				text = string(data)
+			}
+		case string:
+			text = v
+		}
+		if text != "" {
+			turns = append(turns, DialogTurn{Role: role, Text: text})
+		}
+	}
+	return turns, nil
+}
@@ -0,0 +1,213 @@
+package model
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/gif" // декодер GIF для image.Decode
+	"image/jpeg"
+	"image/png"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/ikermy/AiR_Common/pkg/mode"
+)
+
+// ErrImageTooLarge изображение больше ImageOptions.MaxPixels
+var ErrImageTooLarge = errors.New("изображение слишком большое для обработки")
+
+// ImageOptions обработка изображений перед отправкой: уменьшение, сжатие, смена формата.
+// Нулевое значение ничего не меняет
+type ImageOptions struct {
+	MaxDimension int    // наибольшая сторона в пикселях, 0 — без уменьшения
+	JPEGQuality  int    // качество JPEG 1-100, 0 — jpeg.DefaultQuality
+	Format       string // "jpeg" или "png"; пусто — исходный формат
+	MaxPixels    int    // изображения больше (ширина×высота) не обрабатываются; 0 — mode.ImageMaxPixels
+}
+
+// DefaultImageOptions настройки из mode.Image*
+func DefaultImageOptions() ImageOptions {
+	return ImageOptions{
+		MaxDimension: mode.ImageMaxDimension,
+		JPEGQuality:  mode.ImageJPEGQuality,
+		Format:       mode.ImageFormat,
+		MaxPixels:    mode.ImageMaxPixels,
+	}
+}
+
+// Enabled включена ли обработка
+func (o ImageOptions) Enabled() bool {
+	return o.MaxDimension > 0 || o.Format != ""
+}
+
+// WithImageProcessing заменяет обработку изображений пользователя перед отправкой
+// провайдеру и сгенерированных изображений перед сохранением (по умолчанию DefaultImageOptions)
+func WithImageProcessing(opts ImageOptions) RouterOption {
+	return func(r *Router, _ context.Context, _ DB) error {
+		if opts.Format != "" && opts.Format != "jpeg" && opts.Format != "png" {
+			return fmt.Errorf("неподдерживаемый формат изображений %q", opts.Format)
+		}
+		r.images = opts
+		return nil
+	}
+}
+
+// ProcessImage уменьшает изображение до opts.MaxDimension и перекодирует в opts.Format.
+// Возвращает новые данные и MIME-тип. Форматы без декодера в стандартной библиотеке
+// (WebP, HEIC) и анимированные GIF без смены формата возвращаются как есть.
+// Изображение больше opts.MaxPixels не декодируется (ErrImageTooLarge): размеры берутся
+// из заголовка, поэтому «бомба» из маленького файла не раздувается в памяти
+func ProcessImage(data []byte, mimeType string, opts ImageOptions) ([]byte, string, error) {
+	if !opts.Enabled() {
+		return data, mimeType, nil
+	}
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return data, mimeType, nil
+	}
+	maxPixels := opts.MaxPixels
+	if maxPixels <= 0 {
+		maxPixels = mode.ImageMaxPixels
+	}
+	if maxPixels > 0 && int64(cfg.Width)*int64(cfg.Height) > int64(maxPixels) {
+		return data, mimeType, fmt.Errorf("%w: %dx%d", ErrImageTooLarge, cfg.Width, cfg.Height)
+	}
+
+	target := opts.Format
+	if target == "" {
+		target = format
+	}
+	resize := opts.MaxDimension > 0 && max(cfg.Width, cfg.Height) > opts.MaxDimension
+	if !resize && target == format {
+		return data, mimeType, nil
+	}
+	if format == "gif" && target == "gif" {
+		return data, mimeType, nil // анимацию не перекодируем
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return data, mimeType, fmt.Errorf("ошибка декодирования изображения: %w", err)
+	}
+	if resize {
+		img = resizeImage(img, opts.MaxDimension)
+	}
+
+	var buf bytes.Buffer
+	switch target {
+	case "png", "gif":
+		target = "png"
+		err = png.Encode(&buf, img)
+	default:
+		target = "jpeg"
+		quality := opts.JPEGQuality
+		if quality <= 0 || quality > 100 {
+			quality = jpeg.DefaultQuality
+		}
+		err = jpeg.Encode(&buf, flattenAlpha(img), &jpeg.Options{Quality: quality})
+	}
+	if err != nil {
+		return data, mimeType, fmt.Errorf("ошибка кодирования изображения: %w", err)
+	}
+	return buf.Bytes(), "image/" + target, nil
+}
+
+// ImageFileName имя файла с расширением под MIME-тип после ProcessImage
+func ImageFileName(name, mimeType string) string {
+	ext := ""
+	switch mimeType {
+	case "image/jpeg":
+		ext = ".jpg"
+	case "image/png":
+		ext = ".png"
+	default:
+		return name
+	}
+	old := path.Ext(name)
+	if strings.EqualFold(old, ext) || (ext == ".jpg" && strings.EqualFold(old, ".jpeg")) {
+		return name
+	}
+	return strings.TrimSuffix(name, old) + ext
+}
+
+// processImageUploads обрабатывает изображения пользователя с содержимым;
+// при ошибке файл остаётся исходным
+func (r *Router) processImageUploads(files []FileUpload) {
+	if !r.images.Enabled() {
+		return
+	}
+	for i := range files {
+		f := &files[i]
+		if f.Content == nil || fileTypeByMime(f.MimeType) != Photo {
+			continue
+		}
+		data, err := io.ReadAll(f.Content)
+		if err != nil {
+			f.Content = bytes.NewReader(data)
+			continue
+		}
+		out, mimeType, err := ProcessImage(data, f.MimeType, r.images)
+		if err != nil {
+			out, mimeType = data, f.MimeType
+		}
+		f.Content = bytes.NewReader(out)
+		f.MimeType = mimeType
+		f.Name = ImageFileName(f.Name, mimeType)
+	}
+}
+
+// resizeImage уменьшает изображение усреднением по площади, наибольшая сторона — maxDim
+func resizeImage(src image.Image, maxDim int) image.Image {
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	dw, dh := maxDim, h*maxDim/w
+	if h > w {
+		dw, dh = w*maxDim/h, maxDim
+	}
+	dw, dh = max(dw, 1), max(dh, 1)
+
+	rgba := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(rgba, rgba.Bounds(), src, b.Min, draw.Src)
+
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		y0, y1 := y*h/dh, max((y+1)*h/dh, y*h/dh+1)
+		for x := 0; x < dw; x++ {
+			x0, x1 := x*w/dw, max((x+1)*w/dw, x*w/dw+1)
+			var sum [4]int
+			for sy := y0; sy < y1; sy++ {
+				row := rgba.Pix[sy*rgba.Stride:]
+				for sx := x0; sx < x1; sx++ {
+					p := row[sx*4 : sx*4+4]
+					sum[0] += int(p[0])
+					sum[1] += int(p[1])
+					sum[2] += int(p[2])
+					sum[3] += int(p[3])
+				}
+			}
+			n := (y1 - y0) * (x1 - x0)
+			i := dst.PixOffset(x, y)
+			for c := 0; c < 4; c++ {
+				dst.Pix[i+c] = uint8(sum[c] / n)
+			}
+		}
+	}
+	return dst
+}
+
+// flattenAlpha накладывает изображение на белый фон: у JPEG нет прозрачности
+func flattenAlpha(img image.Image) image.Image {
+	if o, ok := img.(interface{ Opaque() bool }); ok && o.Opaque() {
+		return img
+	}
+	b := img.Bounds()
+	dst := image.NewRGBA(b)
+	draw.Draw(dst, b, image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(dst, b, img, b.Min, draw.Over)
+	return dst
+}
@@ -0,0 +1,72 @@
+package model
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+)
+
+func testPNG(t *testing.T, w, h int) []byte {
+	t.Helper()
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.NRGBA{R: uint8(x), G: uint8(y), B: 200, A: 128})
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestProcessImage(t *testing.T) {
+	src := testPNG(t, 400, 200)
+
+	out, mimeType, err := ProcessImage(src, "image/png", ImageOptions{MaxDimension: 100, Format: "jpeg", JPEGQuality: 70})
+	if err != nil {
+		t.Fatalf("ProcessImage: %v", err)
+	}
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(out))
+	if err != nil {
+		t.Fatalf("результат не декодируется: %v", err)
+	}
+	if mimeType != "image/jpeg" || format != "jpeg" || cfg.Width != 100 || cfg.Height != 50 {
+		t.Errorf("получено %s %s %dx%d", mimeType, format, cfg.Width, cfg.Height)
+	}
+
+	// Маленькое изображение без смены формата не перекодируется
+	same, mimeType, _ := ProcessImage(src, "image/png", ImageOptions{MaxDimension: 1000})
+	if !bytes.Equal(same, src) || mimeType != "image/png" {
+		t.Error("изображение в пределах лимита изменено")
+	}
+
+	// Слишком большое изображение не декодируется
+	if out, _, err := ProcessImage(src, "image/png", ImageOptions{MaxDimension: 100, MaxPixels: 400 * 199}); !errors.Is(err, ErrImageTooLarge) || !bytes.Equal(out, src) {
+		t.Errorf("большое изображение: %v", err)
+	}
+
+	// Неизвестный формат возвращается как есть
+	webp := []byte("RIFF\x00\x00\x00\x00WEBPVP8 ")
+	if out, _, err := ProcessImage(webp, "image/webp", ImageOptions{MaxDimension: 10}); err != nil || !bytes.Equal(out, webp) {
+		t.Errorf("WebP изменён: %v", err)
+	}
+}
+
+func TestImageFileName(t *testing.T) {
+	cases := map[[2]string]string{
+		{"photo.png", "image/jpeg"}:  "photo.jpg",
+		{"photo.jpeg", "image/jpeg"}: "photo.jpeg",
+		{"photo", "image/png"}:       "photo.png",
+		{"photo.webp", "image/webp"}: "photo.webp",
+	}
+	for in, want := range cases {
+		if got := ImageFileName(in[0], in[1]); got != want {
+			t.Errorf("ImageFileName(%q, %q) = %q, want %q", in[0], in[1], got, want)
+		}
+	}
+}
@@ -7,7 +7,9 @@ import (
 
 	"github.com/ikermy/AiR_Common/pkg/com"
 	"github.com/ikermy/AiR_Common/pkg/comdb"
+	"github.com/ikermy/AiR_Common/pkg/experiment"
 	"github.com/ikermy/AiR_Common/pkg/model/create"
+	"github.com/ikermy/AiR_Common/pkg/storage"
 )
 
 // DB алиас для интерфейса БД
@@ -49,13 +51,24 @@ type OpenAIManager interface {
 	ListUserDocuments(userID uint32) ([]create.VectorDocument, error)
 }
 
+// LibraryManager — провайдер с библиотекой документов, в которую загружаются файлы пользователя
+type LibraryManager interface {
+	UploadFileToProvider(userID uint32, fileName string, fileData []byte) (string, error)
+	DeleteDocumentFromLibrary(userID uint32, documentID string) error
+	AddFileToLibrary(userID uint32, fileID, fileName string) error
+}
+
+// UniversalModelUser — провайдер, которому нужен общий UniversalModel Router
+type UniversalModelUser interface {
+	SetUniversalModel(m *create.UniversalModel)
+}
+
 // MistralManager расширяет Inter для Mistral-специфичных методов работы с библиотеками
 type MistralManager interface {
 	Inter
+	LibraryManager
 	CreateModel(userID uint32, provider create.ProviderType, modelData *create.UniversalModelData, fileIDs []create.Ids) (create.UMCR, error)
-	UploadFileToProvider(userID uint32, fileName string, fileData []byte) (string, error)
-	DeleteDocumentFromLibrary(userID uint32, documentID string) error
-	AddFileToLibrary(userID uint32, fileID, fileName string) error
+	RecognizeDocument(userID uint32, fileName string, fileData []byte) (string, error)
 }
 
 // GoogleManager расширяет Inter для Google-специфичных методов
@@ -68,6 +81,90 @@ type GoogleManager interface {
 	ListUserDocuments(userID uint32) ([]create.VectorDocument, error)
 }
 
+// ContextRestorer — провайдер, восстанавливающий после перезапуска состояние диалогов в памяти
+// (респонденты, кэш истории), сохранённое SaveAllContextDuringExit
+type ContextRestorer interface {
+	RestoreContextAfterStart()
+}
+
+// Embedder — провайдер, наполняющий общее векторное хранилище MariaDB своими эмбеддингами
+type Embedder interface {
+	UploadDocumentWithEmbedding(userID uint32, docName, content string, metadata create.DocumentMetadata) (string, error)
+	SearchSimilarDocuments(userID uint32, query string, limit int) ([]create.VectorDocument, error)
+	DeleteDocument(userID uint32, docID string) error
+	ListUserDocuments(userID uint32) ([]create.VectorDocument, error)
+}
+
+// DialogPinner — модель, закрепляющая диалог за провайдером (смена ассистента на ассистента
+// другого провайдера посреди диалога)
+type DialogPinner interface {
+	PinDialog(dialogID uint64, kind create.ProviderType) error
+}
+
+// ContextInjector — провайдер с локальным кэшем истории, в который можно добавить
+// сведения о диалоге, прошедшие мимо модели (например, разговор с оператором)
+type ContextInjector interface {
+	InjectContext(dialogID uint64, text string) bool
+}
+
+// TurnRecorder — провайдер с локальной историей диалога, которому семантический кэш
+// может отдать ответ вместо запроса: по последнему ответу модели определяется состояние
+// диалога, а ход из кэша записывается в историю (в БД его сохраняет startpoint)
+type TurnRecorder interface {
+	// LastAnswer последний ответ модели в истории ("" — ответов ещё нет);
+	// ok=false — истории диалога нет в памяти
+	LastAnswer(dialogID uint64) (answer string, ok bool)
+	RecordTurn(dialogID uint64, question string, answer AssistResponse) bool
+}
+
+// InjectionGuard — провайдер, добавляющий в запрос фрагменты базы знаний (RAG);
+// фрагменты очищаются детектором prompt injection
+type InjectionGuard interface {
+	SetInjectionDetector(d *InjectionDetector)
+}
+
+// MediaStorer — провайдер, генерирующий изображения или видео; сохраняет их
+// в хранилище по presigned URL (WithMediaStore)
+type MediaStorer interface {
+	SetMediaStore(store storage.MediaStore)
+}
+
+// ImageProcessor — провайдер, обрабатывающий сгенерированные изображения перед сохранением
+// (WithImageProcessing)
+type ImageProcessor interface {
+	SetImageOptions(opts ImageOptions)
+}
+
+// Deterministic — провайдер, поддерживающий детерминированный режим диалогов
+// (WithDeterministicSeeds)
+type Deterministic interface {
+	SetSeeds(s *Seeds)
+}
+
+// Experimenter — провайдер, применяющий варианты A/B-экспериментов к запросам диалога
+// (WithExperiments)
+type Experimenter interface {
+	SetExperiments(r *experiment.Registry)
+}
+
+// ContextStreamer — провайдер, streaming-запрос которого отменяется вместе с ctx:
+// HTTP-запрос к API прерывается, а не дорабатывает в фоне после тайм-аута ожидания ответа
+type ContextStreamer interface {
+	RequestStreamingContext(ctx context.Context, userID uint32, dialogID uint64, text string, onDelta func(delta string, done bool) error, files ...FileUpload) error
+}
+
+// Moderator проверяет текст модерацией провайдера
+type Moderator interface {
+	Moderate(ctx context.Context, userID uint32, text string) (ModerationResult, error)
+}
+
+// TokenCounter точный подсчёт токенов на стороне провайдера (Gemini countTokens).
+// Mistral его не реализует: в API Mistral нет эндпоинта подсчёта токенов, а токенизатор
+// Tekken доступен только в Python-библиотеке mistral-common
+type TokenCounter interface {
+	CountTokens(userID uint32, modelName, text string) (int, error)
+}
+
 // ActionHandler интерфейс для обработки функций ассистента
 type ActionHandler interface {
 	RunAction(ctx context.Context, functionName, arguments string, provider create.ProviderType, userID uint32) string
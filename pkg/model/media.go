@@ -0,0 +1,67 @@
+package model
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/ikermy/AiR_Common/pkg/model/create"
+	"github.com/ikermy/AiR_Common/pkg/storage"
+)
+
+// WithMediaStore сохраняет сгенерированные изображения и видео в хранилище по presigned URL.
+// Без опции файлы передаются действию save_image в base64 через ActionHandler
+func WithMediaStore(store storage.MediaStore) RouterOption {
+	return func(r *Router, _ context.Context, _ DB) error {
+		if store == nil {
+			return fmt.Errorf("хранилище медиафайлов не может быть nil")
+		}
+		r.media = store
+		return nil
+	}
+}
+
+// SaveGeneratedMedia сохраняет сгенерированный файл и возвращает ссылку для send_files:
+// в store, если он задан, иначе через действие save_image. Изображения перед сохранением
+// обрабатываются по images; savedName — имя файла с расширением нового формата
+func SaveGeneratedMedia(ctx context.Context, store storage.MediaStore, handler ActionHandler, images ImageOptions,
+	provider create.ProviderType, userID uint32, fileName, contentType string, data []byte) (url, savedName string, err error) {
+	if fileTypeByMime(contentType) == Photo {
+		if out, mimeType, err := ProcessImage(data, contentType, images); err == nil {
+			data, contentType, fileName = out, mimeType, ImageFileName(fileName, mimeType)
+		}
+	}
+	if store != nil {
+		url, err := storage.Put(ctx, store, storage.MediaKey(userID, fileName), contentType, data)
+		return url, fileName, err
+	}
+	if handler == nil {
+		return "", fileName, fmt.Errorf("не задано хранилище медиафайлов и обработчик действий")
+	}
+
+	args, _ := json.Marshal(map[string]string{
+		"image_data": base64.StdEncoding.EncodeToString(data),
+		"file_name":  fileName,
+	})
+	result := handler.RunAction(ctx, "save_image", string(args), provider, userID)
+
+	var saveResult struct {
+		Success *bool  `json:"success"`
+		URL     string `json:"url"`
+		Error   string `json:"error"`
+	}
+	if err := json.Unmarshal([]byte(result), &saveResult); err != nil {
+		// Обработчик может вернуть просто URL
+		url := strings.TrimSpace(result)
+		if url == "" || strings.Contains(url, "error") {
+			return "", fileName, fmt.Errorf("save_image: %s", result)
+		}
+		return url, fileName, nil
+	}
+	if saveResult.URL == "" || (saveResult.Success != nil && !*saveResult.Success) {
+		return "", fileName, fmt.Errorf("save_image: %s", saveResult.Error)
+	}
+	return saveResult.URL, fileName, nil
+}
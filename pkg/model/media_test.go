@@ -0,0 +1,50 @@
+package model
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/ikermy/AiR_Common/pkg/model/create"
+)
+
+type saveImageHandler string
+
+func (h saveImageHandler) RunAction(_ context.Context, _, _ string, _ create.ProviderType, _ uint32) string {
+	return string(h)
+}
+
+func TestSaveGeneratedMediaFallback(t *testing.T) {
+	for _, tc := range []struct {
+		result, want string
+		wantErr      bool
+	}{
+		{`{"success":true,"url":"https://example.test/a.png"}`, "https://example.test/a.png", false},
+		{`{"url":"https://example.test/b.png"}`, "https://example.test/b.png", false},
+		{"https://example.test/c.png\n", "https://example.test/c.png", false},
+		{`{"success":false,"error":"quota"}`, "", true},
+		{"error: storage unavailable", "", true},
+	} {
+		got, _, err := SaveGeneratedMedia(context.Background(), nil, saveImageHandler(tc.result), ImageOptions{}, create.ProviderGoogle, 1, "a.png", "image/png", []byte("png"))
+		if got != tc.want || (err != nil) != tc.wantErr {
+			t.Errorf("result %q: got %q, err %v", tc.result, got, err)
+		}
+	}
+}
+
+// argsHandler — ActionHandler, запоминающий аргументы save_image
+type argsHandler struct{ args string }
+
+func (h *argsHandler) RunAction(_ context.Context, _, arguments string, _ create.ProviderType, _ uint32) string {
+	h.args = arguments
+	return `{"success":true,"url":"https://example.test/a.jpg"}`
+}
+
+func TestSaveGeneratedMediaImageOptions(t *testing.T) {
+	h := &argsHandler{}
+	_, name, err := SaveGeneratedMedia(context.Background(), nil, h, ImageOptions{Format: "jpeg"}, create.ProviderMistral, 1,
+		"a.png", "image/png", testPNG(t, 20, 10))
+	if err != nil || name != "a.jpg" || !strings.Contains(h.args, `"file_name":"a.jpg"`) {
+		t.Fatalf("name=%q err=%v args=%.80s", name, err, h.args)
+	}
+}
@@ -0,0 +1,45 @@
+package mistral
+
+import (
+	"github.com/ikermy/AiR_Common/pkg/model/create"
+)
+
+// checkContext create.ContextTooLongError, если промпт агента и сообщение content вместе
+// не помещаются в контекст модели (create.ChatInputLimit): историю conversation хранит
+// Mistral, поэтому до отправки проверяется только то, что не зависит от неё.
+// Неизвестная модель не проверяется
+func (r *RespModel) checkContext(content any) error {
+	limit := create.ChatInputLimit(r.ModelName)
+	if limit <= 0 {
+		return nil
+	}
+	_, err := create.FitHistory(r.ModelName, limit, r.PromptTokens, []int{messageTokens("user", content, r.ModelName)})
+	return err
+}
+
+// fitHistory отбрасывает самые старые сообщения истории, переносимой в новый conversation,
+// если вместе с промптом агента и текущим content они не помещаются в контекст модели.
+// История после обрезки начинается с сообщения пользователя
+func (r *RespModel) fitHistory(history []Message, content any) []Message {
+	limit := create.ChatInputLimit(r.ModelName)
+	if limit <= 0 || len(history) == 0 {
+		return history
+	}
+
+	sizes := make([]int, len(history)+1)
+	for i, msg := range history {
+		sizes[i] = messageTokens(msg.Type, msg.Content, r.ModelName)
+	}
+	sizes[len(history)] = messageTokens("user", content, r.ModelName)
+
+	// Текущее сообщение без истории проверено checkContext: ошибку здесь не возвращаем
+	drop, _ := create.FitHistory(r.ModelName, limit, r.PromptTokens, sizes)
+	for drop < len(history) && history[drop].Type != "user" {
+		drop++
+	}
+	return history[drop:]
+}
+
+func messageTokens(role string, content any, modelName string) int {
+	return create.ChatMessageTokens(create.ChatMessage{Role: role, Content: content}, modelName)
+}
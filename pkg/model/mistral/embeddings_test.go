@@ -0,0 +1,132 @@
+package mistral
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/ikermy/AiR_Common/pkg/model/create"
+)
+
+// embeddingsDB — DB с векторным хранилищем в памяти
+type embeddingsDB struct {
+	DB
+	saved    []create.VectorDocument
+	provider create.ProviderType
+	modelId  uint64
+	query    []float32
+}
+
+func (d *embeddingsDB) GetAllUserModels(uint32) ([]create.UserModelRecord, error) {
+	return []create.UserModelRecord{{ModelId: 1, Provider: create.ProviderOpenAI}, {ModelId: 2, Provider: create.ProviderMistral}}, nil
+}
+
+func (d *embeddingsDB) SaveEmbedding(_ uint32, modelId uint64, provider create.ProviderType, docID, docName, content string, embedding []float32, _ create.DocumentMetadata) error {
+	d.modelId, d.provider = modelId, provider
+	d.saved = append(d.saved, create.VectorDocument{ID: docID, Name: docName, Content: content, Embedding: embedding})
+	return nil
+}
+
+func (d *embeddingsDB) CountModelEmbeddings(uint64) (int, error) { return len(d.saved), nil }
+
+func (d *embeddingsDB) SearchSimilarEmbeddings(modelId uint64, provider create.ProviderType, query []float32, _ int) ([]create.VectorDocument, error) {
+	d.modelId, d.provider, d.query = modelId, provider, query
+	return d.saved, nil
+}
+
+// embeddingsServer — /v1/embeddings Mistral: вектор 1024 из длины текста
+func embeddingsServer(t *testing.T, calls *atomic.Int32) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		if r.URL.Path != "/v1/embeddings" || r.Header.Get("Authorization") != "Bearer user-key" {
+			t.Errorf("запрос %s, Authorization %q", r.URL.Path, r.Header.Get("Authorization"))
+		}
+		var req struct {
+			Model string   `json:"model"`
+			Input []string `json:"input"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		if req.Model != create.MistralEmbeddingModel || len(req.Input) != 1 {
+			t.Errorf("payload: %+v", req)
+		}
+		vector := make([]float32, 1024)
+		vector[0] = float32(len([]rune(req.Input[0])))
+		_ = json.NewEncoder(w).Encode(map[string]any{"data": []map[string]any{{"embedding": vector}}})
+	}))
+	t.Cleanup(srv.Close)
+	redirectMistral(t, srv)
+}
+
+// redirectMistral отправляет запросы http.DefaultClient к API Mistral на тестовый сервер
+func redirectMistral(t *testing.T, srv *httptest.Server) {
+	target, _ := url.Parse(srv.URL)
+	prev := http.DefaultClient.Transport
+	http.DefaultClient.Transport = roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		if !strings.HasSuffix(r.URL.Host, "mistral.ai") {
+			t.Errorf("запрос не к Mistral: %s", r.URL)
+		}
+		r = r.Clone(r.Context())
+		r.URL.Scheme, r.URL.Host = target.Scheme, target.Host
+		return http.DefaultTransport.RoundTrip(r)
+	})
+	t.Cleanup(func() { http.DefaultClient.Transport = prev })
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
+
+func TestEmbeddings_UploadAndSearch(t *testing.T) {
+	var calls atomic.Int32
+	embeddingsServer(t, &calls)
+
+	db := &embeddingsDB{}
+	client := NewMistralAgentClient(context.Background())
+	client.SetKeyResolver(func(uint32) string { return "user-key" })
+	m := &Model{ctx: context.Background(), client: client, db: db}
+
+	// Пустая база — поиск без запроса к API
+	docs, err := m.SearchSimilarDocuments(7, "доставка", 3)
+	if err != nil || len(docs) != 0 || calls.Load() != 0 {
+		t.Fatalf("пустая база: %v %v, запросов %d", docs, err, calls.Load())
+	}
+
+	docID, err := m.UploadDocumentWithEmbedding(7, "faq.txt", "Доставка 2 дня", create.DocumentMetadata{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(docID, "mistral_doc_7_") || db.modelId != 2 || db.provider != create.ProviderMistral ||
+		len(db.saved[0].Embedding) != 1024 {
+		t.Fatalf("сохранено: %s modelId=%d provider=%s dim=%d", docID, db.modelId, db.provider, len(db.saved[0].Embedding))
+	}
+
+	docs, err = m.SearchSimilarDocuments(7, "доставка", 3)
+	if err != nil || len(docs) != 1 || calls.Load() != 2 {
+		t.Fatalf("поиск: %v %v, запросов %d", docs, err, calls.Load())
+	}
+	if db.provider != create.ProviderMistral || len(db.query) != 1024 || db.query[0] != 8 {
+		t.Fatalf("запрос к хранилищу: provider=%s dim=%d", db.provider, len(db.query))
+	}
+}
+
+func TestGenerateMistralEmbedding_Errors(t *testing.T) {
+	if _, err := create.GenerateMistralEmbedding(context.Background(), "key", ""); err == nil {
+		t.Fatal("пустой текст: ожидали ошибку")
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		_, _ = w.Write([]byte(`{"message":"Unauthorized"}`))
+	}))
+	defer srv.Close()
+	redirectMistral(t, srv)
+
+	if _, err := create.GenerateMistralEmbedding(context.Background(), "bad", "текст"); err == nil || !strings.Contains(err.Error(), "401") {
+		t.Fatalf("ошибка API: %v", err)
+	}
+}
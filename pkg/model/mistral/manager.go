@@ -3,10 +3,16 @@ package mistral
 import (
 	"encoding/json"
 	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/ikermy/AiR_Common/pkg/model/create"
 )
 
+// documentProcessingTimeout сколько UploadFileToProvider ждёт индексации документа
+const documentProcessingTimeout = 2 * time.Minute
+
 // CreateModel создаёт новую модель Mistral
 // Делегирует вызов к UniversalModel из пакета create
 func (m *Model) CreateModel(userID uint32, provider create.ProviderType, modelData *create.UniversalModelData, fileIDs []create.Ids) (create.UMCR, error) {
@@ -26,24 +32,50 @@ func (m *Model) UploadFileToProvider(userID uint32, fileName string, fileData []
 		return "", fmt.Errorf("не удалось получить/создать библиотеку: %w", err)
 	}
 
-	// 2. Загрузить документ в библиотеку через Mistral API
+	// 2. Сканы и изображения библиотека индексирует как мусор — загружаем распознанный текст.
+	// При ошибке OCR загружаем исходный файл: документ хотя бы останется в библиотеке
+	if needsOCR(fileName, fileData) {
+		if text, err := m.client.OCRDocument(fileName, fileData, userID); err == nil && text != "" {
+			fileName = strings.TrimSuffix(fileName, filepath.Ext(fileName)) + ".md"
+			fileData = []byte(text)
+		}
+	}
+
+	// 3. Загрузить документ в библиотеку через Mistral API
 	documentID, err := m.client.UploadDocumentToLibrary(libraryID, fileName, fileData)
 	if err != nil {
 		return "", fmt.Errorf("не удалось загрузить документ в библиотеку: %w", err)
 	}
 
+	// Ждём индексации: документ со статусом failed агент не увидит, удаляем его сразу.
+	// Если библиотека не успела за documentProcessingTimeout — документ остаётся, он доиндексируется
+	if status, err := m.client.WaitForDocumentProcessing(libraryID, documentID, 0, documentProcessingTimeout); err != nil && status == MistralDocumentFailed {
+		_ = m.client.DeleteDocumentFromLibrary(libraryID, documentID)
+		return "", fmt.Errorf("библиотека не смогла обработать документ %s: %w", fileName, err)
+	}
+
 	//logger.Debug("Документ %s успешно загружен в библиотеку %s (ID: %s)", fileName, libraryID, documentID, userID)
 
-	// 3. Сохранить информацию о файле в БД (в FileIds)
+	// 4. Сохранить информацию о файле в БД (в FileIds)
 	if err := m.addFileToDatabase(userID, documentID, fileName); err != nil {
 		//logger.Error("Ошибка сохранения информации о файле %s в БД: %v", fileName, err, userID)
 		// Не возвращаем ошибку - файл уже загружен в Mistral, просто логируем
 	}
 
-	// 4. Вернуть documentID
+	// 5. Вернуть documentID
 	return documentID, nil
 }
 
+// RecognizeDocument распознаёт PDF или изображение через Mistral OCR.
+// Используется перед UploadDocumentWithEmbedding, чтобы в векторное хранилище попал текст скана.
+func (m *Model) RecognizeDocument(userID uint32, fileName string, fileData []byte) (string, error) {
+	text, err := m.client.OCRDocument(fileName, fileData, userID)
+	if err != nil {
+		return "", fmt.Errorf("не удалось распознать документ: %w", err)
+	}
+	return text, nil
+}
+
 // DeleteDocumentFromLibrary удаляет документ из библиотеки пользователя Mistral
 // Один пользователь = одна библиотека
 // Если после удаления файла библиотека пустая - удаляет саму библиотеку
@@ -10,8 +10,10 @@ import (
 	"mime/multipart"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/ikermy/AiR_Common/pkg/mode"
+	"github.com/ikermy/AiR_Common/pkg/providererr"
 )
 
 // MistralAgentClient - обертка для работы с агентами и обычными моделями
@@ -21,6 +23,26 @@ type MistralAgentClient struct {
 	apiKey      string
 	url         string
 	keyResolver func(userID uint32) string // Резолвер персональных ключей; nil → глобальный apiKey
+
+	completionArgs map[string]any // completion_args запросов к conversation (WithCompletionArgs)
+}
+
+// WithCompletionArgs копия клиента, передающая args в completion_args запросов к conversation
+// поверх параметров агента (например, температура варианта A/B-эксперимента). Пустые args — сам клиент
+func (m *MistralAgentClient) WithCompletionArgs(args map[string]any) *MistralAgentClient {
+	if len(args) == 0 {
+		return m
+	}
+	c := *m
+	c.completionArgs = args
+	return &c
+}
+
+// addCompletionArgs добавляет completion_args клиента в payload запроса к conversation
+func (m *MistralAgentClient) addCompletionArgs(payload map[string]any) {
+	if len(m.completionArgs) > 0 {
+		payload["completion_args"] = m.completionArgs
+	}
 }
 
 // SetKeyResolver устанавливает функцию-резолвер персонального API-ключа пользователя.
@@ -100,6 +122,13 @@ type MistralLibrary struct {
 	CreatedAt   string `json:"created_at,omitempty"` // API возвращает строку, а не int64
 }
 
+// Статусы обработки документа в библиотеке
+const (
+	MistralDocumentProcessing = "processing"
+	MistralDocumentProcessed  = "processed"
+	MistralDocumentFailed     = "failed"
+)
+
 // MistralDocument представляет документ в библиотеке
 type MistralDocument struct {
 	ID        string `json:"id"`
@@ -145,7 +174,7 @@ func (m *MistralAgentClient) CreateLibrary(name, description string) (*MistralLi
 	}
 
 	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
-		return nil, fmt.Errorf("API вернул статус %d: %s", resp.StatusCode, string(responseBody))
+		return nil, providererr.New("Mistral", resp.StatusCode, resp.Header, responseBody)
 	}
 
 	var library MistralLibrary
@@ -176,7 +205,7 @@ func (m *MistralAgentClient) DeleteLibrary(libraryID string) error {
 
 	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusNotFound {
 		responseBody, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("API вернул статус %d: %s", resp.StatusCode, string(responseBody))
+		return providererr.New("Mistral", resp.StatusCode, resp.Header, responseBody)
 	}
 
 	return nil
@@ -202,17 +231,17 @@ func (m *MistralAgentClient) UploadDocumentToLibrary(libraryID, fileName string,
 		return "", fmt.Errorf("ошибка закрытия multipart writer: %v", err)
 	}
 
-	req, err := http.NewRequestWithContext(m.ctx, http.MethodPost, url, body)
-	if err != nil {
-		return "", fmt.Errorf("ошибка создания POST запроса: %v", err)
-	}
-
-	req.Header.Set("Authorization", "Bearer "+m.apiKey)
-	req.Header.Set("Content-Type", mw.FormDataContentType())
-
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := m.doLibraryRequest(func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(m.ctx, http.MethodPost, url, bytes.NewReader(body.Bytes()))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", "Bearer "+m.apiKey)
+		req.Header.Set("Content-Type", mw.FormDataContentType())
+		return req, nil
+	})
 	if err != nil {
-		return "", fmt.Errorf("ошибка HTTP запроса: %v", err)
+		return "", err
 	}
 	defer func() { _ = resp.Body.Close() }()
 
@@ -222,7 +251,7 @@ func (m *MistralAgentClient) UploadDocumentToLibrary(libraryID, fileName string,
 	}
 
 	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
-		return "", fmt.Errorf("API вернул статус %d: %s", resp.StatusCode, string(responseBody))
+		return "", providererr.New("Mistral", resp.StatusCode, resp.Header, responseBody)
 	}
 
 	var document MistralDocument
@@ -253,7 +282,7 @@ func (m *MistralAgentClient) DeleteDocumentFromLibrary(libraryID, documentID str
 
 	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusNotFound {
 		responseBody, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("API вернул статус %d: %s", resp.StatusCode, string(responseBody))
+		return providererr.New("Mistral", resp.StatusCode, resp.Header, responseBody)
 	}
 
 	return nil
@@ -264,16 +293,16 @@ func (m *MistralAgentClient) DeleteDocumentFromLibrary(libraryID, documentID str
 func (m *MistralAgentClient) GetDocumentStatus(libraryID, documentID string) (string, error) {
 	url := fmt.Sprintf("https://api.mistral.ai/v1/libraries/%s/documents/%s", libraryID, documentID)
 
-	req, err := http.NewRequestWithContext(m.ctx, http.MethodGet, url, nil)
-	if err != nil {
-		return "", fmt.Errorf("ошибка создания GET запроса: %v", err)
-	}
-
-	req.Header.Set("Authorization", "Bearer "+m.apiKey)
-
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := m.doLibraryRequest(func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(m.ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", "Bearer "+m.apiKey)
+		return req, nil
+	})
 	if err != nil {
-		return "", fmt.Errorf("ошибка HTTP запроса: %v", err)
+		return "", err
 	}
 	defer func() { _ = resp.Body.Close() }()
 
@@ -283,7 +312,7 @@ func (m *MistralAgentClient) GetDocumentStatus(libraryID, documentID string) (st
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("API вернул статус %d: %s", resp.StatusCode, string(responseBody))
+		return "", providererr.New("Mistral", resp.StatusCode, resp.Header, responseBody)
 	}
 
 	var document MistralDocument
@@ -294,6 +323,82 @@ func (m *MistralAgentClient) GetDocumentStatus(libraryID, documentID string) (st
 	return document.Status, nil
 }
 
+// WaitForDocumentProcessing опрашивает статус документа каждые interval, пока библиотека
+// не закончит его индексацию. timeout <= 0 — ждём до отмены контекста клиента.
+// Возвращает последний полученный статус; ошибка — если индексация завершилась failed
+// или истёк timeout
+func (m *MistralAgentClient) WaitForDocumentProcessing(libraryID, documentID string, interval, timeout time.Duration) (string, error) {
+	if interval <= 0 {
+		interval = 2 * time.Second
+	}
+
+	ctx := m.ctx
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(m.ctx, timeout)
+		defer cancel()
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		status, err := m.GetDocumentStatus(libraryID, documentID)
+		if err != nil {
+			return "", err
+		}
+
+		switch status {
+		case MistralDocumentProcessed:
+			return status, nil
+		case MistralDocumentFailed:
+			return status, fmt.Errorf("обработка документа %s завершилась ошибкой", documentID)
+		}
+
+		select {
+		case <-ctx.Done():
+			return status, fmt.Errorf("документ %s не обработан: %w", documentID, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+// doLibraryRequest выполняет запрос к Libraries API, повторяя его при 429 (mode.Retry.RateLimit).
+// newReq вызывается на каждую попытку — тело запроса нельзя прочитать дважды
+func (m *MistralAgentClient) doLibraryRequest(newReq func() (*http.Request, error)) (*http.Response, error) {
+	for attempt := 1; ; attempt++ {
+		req, err := newReq()
+		if err != nil {
+			return nil, fmt.Errorf("ошибка создания запроса: %v", err)
+		}
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка HTTP запроса: %v", err)
+		}
+		if resp.StatusCode != http.StatusTooManyRequests {
+			return resp, nil
+		}
+		// Тело читается заранее: 429 из-за квоты не повторяется, тело остаётся вызывающему
+		body, err := io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		resp.Body = io.NopCloser(bytes.NewReader(body))
+		if err != nil || providererr.QuotaExhausted(resp.StatusCode, body) {
+			return resp, nil
+		}
+		delay, ok := mode.Retry.RateLimitDelay(attempt, mode.ParseRetryAfter(resp.Header.Get("Retry-After")))
+		if !ok {
+			return resp, nil
+		}
+
+		select {
+		case <-m.ctx.Done():
+			return nil, m.ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
+
 // DownloadFile скачивает файл (изображение) по file_id через Mistral Files API
 // Документация: https://docs.mistral.ai/api/#tag/files/operation/files_api_routes_download_file
 func (m *MistralAgentClient) DownloadFile(fileID string) ([]byte, error) {
@@ -314,7 +419,7 @@ func (m *MistralAgentClient) DownloadFile(fileID string) ([]byte, error) {
 
 	if resp.StatusCode != http.StatusOK {
 		bodyText, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("API вернул статус %d: %s", resp.StatusCode, string(bodyText))
+		return nil, providererr.New("Mistral", resp.StatusCode, resp.Header, bodyText)
 	}
 
 	fileBytes, err := io.ReadAll(resp.Body)
@@ -366,6 +471,7 @@ func (m *MistralAgentClient) StartConversation(agentID string, inputs any, userI
 		"stream":   false,
 	}
 
+	m.addCompletionArgs(payload)
 	body, err := json.Marshal(payload)
 	if err != nil {
 		return ConversationResponse{}, fmt.Errorf("ошибка сериализации запроса: %v", err)
@@ -391,7 +497,7 @@ func (m *MistralAgentClient) StartConversation(agentID string, inputs any, userI
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return ConversationResponse{}, fmt.Errorf("API вернул статус %d: %s", resp.StatusCode, string(responseBody))
+		return ConversationResponse{}, providererr.New("Mistral", resp.StatusCode, resp.Header, responseBody)
 	}
 
 	// RAW ответ для отладки
@@ -415,6 +521,7 @@ func (m *MistralAgentClient) ContinueConversation(conversationID string, inputs
 		"store":  true,
 	}
 
+	m.addCompletionArgs(payload)
 	body, err := json.Marshal(payload)
 	if err != nil {
 		return ConversationResponse{}, fmt.Errorf("ошибка сериализации запроса: %v", err)
@@ -440,7 +547,7 @@ func (m *MistralAgentClient) ContinueConversation(conversationID string, inputs
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return ConversationResponse{}, fmt.Errorf("API вернул статус %d: %s", resp.StatusCode, string(responseBody))
+		return ConversationResponse{}, providererr.New("Mistral", resp.StatusCode, resp.Header, responseBody)
 	}
 
 	// RAW ответ для отладки
@@ -475,6 +582,7 @@ func (m *MistralAgentClient) SendFunctionResult(conversationID string, toolCallI
 		"handoff_execution": "server",
 	}
 
+	m.addCompletionArgs(payload)
 	body, err := json.Marshal(payload)
 	if err != nil {
 		return ConversationResponse{}, fmt.Errorf("ошибка сериализации запроса: %v", err)
@@ -500,7 +608,7 @@ func (m *MistralAgentClient) SendFunctionResult(conversationID string, toolCallI
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return ConversationResponse{}, fmt.Errorf("API вернул статус %d: %s", resp.StatusCode, string(responseBody))
+		return ConversationResponse{}, providererr.New("Mistral", resp.StatusCode, resp.Header, responseBody)
 	}
 
 	//logger.Debug("SendFunctionResult: сырой ответ от API: %s", string(responseBody))
@@ -557,7 +665,7 @@ func (m *MistralAgentClient) PatchAgent(agentID string, tools []map[string]any)
 // StartConversationStreaming начинает новый диалог с агентом в streaming режиме
 // onDelta вызывается для каждого delta события с текстом или JSON событиями function calls
 // Возвращает ConversationResponse с накопленными данными и usage токенов
-func (m *MistralAgentClient) StartConversationStreaming(agentID string, inputs any, onDelta func(string) error, userID uint32) (ConversationResponse, error) {
+func (m *MistralAgentClient) StartConversationStreaming(ctx context.Context, agentID string, inputs any, onDelta func(string) error, userID uint32) (ConversationResponse, error) {
 	conversationsURL := mode.MistralConversationsURL
 
 	payload := map[string]any{
@@ -567,12 +675,13 @@ func (m *MistralAgentClient) StartConversationStreaming(agentID string, inputs a
 		"handoff_execution": "client", // Клиент выполняет function tools — Mistral возвращает function.call события
 	}
 
+	m.addCompletionArgs(payload)
 	body, err := json.Marshal(payload)
 	if err != nil {
 		return ConversationResponse{}, fmt.Errorf("ошибка сериализации запроса: %v", err)
 	}
 
-	req, err := http.NewRequestWithContext(m.ctx, http.MethodPost, conversationsURL, bytes.NewBuffer(body))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, conversationsURL, bytes.NewBuffer(body))
 	if err != nil {
 		return ConversationResponse{}, fmt.Errorf("ошибка создания запроса: %v", err)
 	}
@@ -589,7 +698,7 @@ func (m *MistralAgentClient) StartConversationStreaming(agentID string, inputs a
 
 	if resp.StatusCode != http.StatusOK {
 		bodyText, _ := io.ReadAll(resp.Body)
-		return ConversationResponse{}, fmt.Errorf("API вернул статус %d: %s", resp.StatusCode, string(bodyText))
+		return ConversationResponse{}, providererr.New("Mistral", resp.StatusCode, resp.Header, bodyText)
 	}
 
 	// Читаем SSE поток
@@ -597,7 +706,7 @@ func (m *MistralAgentClient) StartConversationStreaming(agentID string, inputs a
 }
 
 // ContinueConversationStreaming продолжает диалог в streaming режиме
-func (m *MistralAgentClient) ContinueConversationStreaming(conversationID string, inputs any, onDelta func(string) error, userID uint32) (ConversationResponse, error) {
+func (m *MistralAgentClient) ContinueConversationStreaming(ctx context.Context, conversationID string, inputs any, onDelta func(string) error, userID uint32) (ConversationResponse, error) {
 	conversationsURL := fmt.Sprintf("%s/%s", mode.MistralConversationsURL, conversationID)
 
 	payload := map[string]any{
@@ -607,12 +716,13 @@ func (m *MistralAgentClient) ContinueConversationStreaming(conversationID string
 		"handoff_execution": "client", // Клиент выполняет function tools — Mistral возвращает function.call события
 	}
 
+	m.addCompletionArgs(payload)
 	body, err := json.Marshal(payload)
 	if err != nil {
 		return ConversationResponse{}, fmt.Errorf("ошибка сериализации запроса: %v", err)
 	}
 
-	req, err := http.NewRequestWithContext(m.ctx, http.MethodPost, conversationsURL, bytes.NewBuffer(body))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, conversationsURL, bytes.NewBuffer(body))
 	if err != nil {
 		return ConversationResponse{}, fmt.Errorf("ошибка создания запроса: %v", err)
 	}
@@ -629,7 +739,7 @@ func (m *MistralAgentClient) ContinueConversationStreaming(conversationID string
 
 	if resp.StatusCode != http.StatusOK {
 		bodyText, _ := io.ReadAll(resp.Body)
-		return ConversationResponse{}, fmt.Errorf("API вернул статус %d: %s", resp.StatusCode, string(bodyText))
+		return ConversationResponse{}, providererr.New("Mistral", resp.StatusCode, resp.Header, bodyText)
 	}
 
 	return m.readStreamingResponse(resp.Body, onDelta)
@@ -637,7 +747,7 @@ func (m *MistralAgentClient) ContinueConversationStreaming(conversationID string
 
 // SendMultipleFunctionResultsStreaming отправляет результаты НЕСКОЛЬКИХ функций в streaming режиме
 // functionResults - массив объектов с полями: tool_call_id, result, object, type
-func (m *MistralAgentClient) SendMultipleFunctionResultsStreaming(conversationID string, functionResults []map[string]any, onDelta func(string) error, userID uint32) (ConversationResponse, error) {
+func (m *MistralAgentClient) SendMultipleFunctionResultsStreaming(ctx context.Context, conversationID string, functionResults []map[string]any, onDelta func(string) error, userID uint32) (ConversationResponse, error) {
 	conversationsURL := fmt.Sprintf("%s/%s", mode.MistralConversationsURL, conversationID)
 
 	payload := map[string]any{
@@ -647,6 +757,7 @@ func (m *MistralAgentClient) SendMultipleFunctionResultsStreaming(conversationID
 		// handoff_execution не указываем: мы уже выполнили функции на клиенте и возвращаем результаты
 	}
 
+	m.addCompletionArgs(payload)
 	body, err := json.Marshal(payload)
 	if err != nil {
 		return ConversationResponse{}, fmt.Errorf("ошибка сериализации запроса: %v", err)
@@ -655,7 +766,7 @@ func (m *MistralAgentClient) SendMultipleFunctionResultsStreaming(conversationID
 	//logger.Debug("SendMultipleFunctionResultsStreaming: отправка %d результатов функций для conversation=%s",
 	//	len(functionResults), conversationID)
 
-	req, err := http.NewRequestWithContext(m.ctx, http.MethodPost, conversationsURL, bytes.NewBuffer(body))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, conversationsURL, bytes.NewBuffer(body))
 	if err != nil {
 		return ConversationResponse{}, fmt.Errorf("ошибка создания запроса: %v", err)
 	}
@@ -672,7 +783,7 @@ func (m *MistralAgentClient) SendMultipleFunctionResultsStreaming(conversationID
 
 	if resp.StatusCode != http.StatusOK {
 		bodyText, _ := io.ReadAll(resp.Body)
-		return ConversationResponse{}, fmt.Errorf("API вернул статус %d: %s", resp.StatusCode, string(bodyText))
+		return ConversationResponse{}, providererr.New("Mistral", resp.StatusCode, resp.Header, bodyText)
 	}
 
 	return m.readStreamingResponse(resp.Body, onDelta)
@@ -891,7 +1002,7 @@ func (m *MistralAgentClient) DeleteFile(fileID string) error {
 	// Проверяем статус ответа
 	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
 		responseBody, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("API вернул статус %d: %s", resp.StatusCode, string(responseBody))
+		return providererr.New("Mistral", resp.StatusCode, resp.Header, responseBody)
 	}
 
 	return nil
@@ -0,0 +1,86 @@
+package mistral
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ikermy/AiR_Common/pkg/experiment"
+	"github.com/ikermy/AiR_Common/pkg/model/create"
+)
+
+func TestDoLibraryRequestRetriesOn429(t *testing.T) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	client := NewMistralAgentClient(context.Background())
+	resp, err := client.doLibraryRequest(func() (*http.Request, error) {
+		return http.NewRequest(http.MethodGet, srv.URL, nil)
+	})
+	if err != nil {
+		t.Fatalf("doLibraryRequest: %v", err)
+	}
+	_ = resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK || calls != 2 {
+		t.Errorf("status %d after %d calls, want 200 after 2", resp.StatusCode, calls)
+	}
+}
+
+func TestConversationClientVariantTemperature(t *testing.T) {
+	temperature := 0.1
+	registry := experiment.NewRegistry()
+	if err := registry.Set(1, experiment.Experiment{Name: "tone", Variants: []experiment.Variant{
+		{Name: "cold", Temperature: &temperature, Weight: 1000000},
+		{Name: "default"},
+	}}); err != nil {
+		t.Fatal(err)
+	}
+	m := &Model{client: NewMistralAgentClient(context.Background())}
+
+	if m.conversationClient(&RespModel{}, 1, 5) != m.client {
+		t.Fatal("client changed without experiments")
+	}
+	m.SetExperiments(registry)
+	if m.conversationClient(&RespModel{}, 2, 5) != m.client {
+		t.Fatal("client changed for a user without experiment")
+	}
+
+	payload := map[string]any{"inputs": "привет"}
+	m.conversationClient(&RespModel{}, 1, 5).addCompletionArgs(payload)
+	args, ok := payload["completion_args"].(map[string]any)
+	if !ok || args["temperature"] != 0.1 {
+		t.Fatalf("completion_args: %+v", payload)
+	}
+	m.client.addCompletionArgs(payload)
+	if m.client.completionArgs != nil {
+		t.Fatal("shared client modified")
+	}
+
+	// Параметры модели передаются и агенту, созданному до их настройки; температура варианта — поверх них
+	maxTokens, modelTemperature := 300, 0.7
+	resp := &RespModel{Generation: &create.GenerationParams{Temperature: &modelTemperature, MaxOutputTokens: &maxTokens}}
+	payload = map[string]any{}
+	m.conversationClient(resp, 2, 5).addCompletionArgs(payload)
+	if args, _ := payload["completion_args"].(map[string]any); args["temperature"] != 0.7 || args["max_tokens"] != 300 {
+		t.Fatalf("completion_args модели: %+v", payload)
+	}
+	payload = map[string]any{}
+	m.conversationClient(resp, 1, 5).addCompletionArgs(payload)
+	if args, _ := payload["completion_args"].(map[string]any); args["temperature"] != 0.1 || args["max_tokens"] != 300 {
+		t.Fatalf("completion_args варианта: %+v", payload)
+	}
+	if *resp.Generation.Temperature != 0.7 {
+		t.Fatal("параметры модели изменены вариантом")
+	}
+}
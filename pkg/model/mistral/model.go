@@ -15,10 +15,13 @@ import (
 
 	"github.com/ikermy/AiR_Common/pkg/com"
 	"github.com/ikermy/AiR_Common/pkg/comdb"
+	"github.com/ikermy/AiR_Common/pkg/experiment"
 	"github.com/ikermy/AiR_Common/pkg/mode"
 	"github.com/ikermy/AiR_Common/pkg/model"
 	"github.com/ikermy/AiR_Common/pkg/model/create"
 	"github.com/ikermy/AiR_Common/pkg/model/provider_catalog"
+	"github.com/ikermy/AiR_Common/pkg/providererr"
+	"github.com/ikermy/AiR_Common/pkg/storage"
 )
 
 // Model реализует интерфейс model.UniversalModel для работы с Mistral AI
@@ -34,6 +37,9 @@ type Model struct {
 	shutdownOnce   sync.Once
 	router         model.RouterInterface  // Ссылка на router
 	universalModel *create.UniversalModel // Для доступа к DecompressModelData
+	media          storage.MediaStore     // nil — сгенерированные изображения сохраняются через save_image
+	images         model.ImageOptions     // обработка сгенерированных изображений перед сохранением
+	experiments    *experiment.Registry   // nil — без A/B-экспериментов
 }
 
 type DB comdb.Exterior
@@ -48,9 +54,17 @@ type RespModel struct {
 	Assist         model.Assistant
 	RespName       string
 	Services       Services
-	ConversationId string // ID conversation для Mistral Conversations API
-	Haunter        bool   // Модель используется для поиска лидов
-	ToolsSynced    bool   // true — агент уже синхронизирован с MCP tools в этой сессии
+	ConversationId string                   // ID conversation для Mistral Conversations API
+	Haunter        bool                     // Модель используется для поиска лидов
+	ToolsSynced    bool                     // true — агент уже синхронизирован с MCP tools в этой сессии
+	Moderation     create.ModerationConfig  // Модерация вопросов и ответов (из настроек модели)
+	ResponseFields []create.SchemaField     // Дополнительные поля ответа ассистента (AssistResponse.Extra)
+	ModelName      string                   // Модель агента: ей исправляется ответ не по схеме
+	ModelId        uint64                   // Модель пользователя в БД
+	PromptVersion  uint32                   // Версия промпта агента в create.PromptVersionStore
+	PromptTokens   int                      // Оценка токенов промпта агента для проверки контекста (checkContext)
+	Generation     *create.GenerationParams // Параметры генерации модели (completion_args каждого запроса)
+	Imported       []Message                // История из БД для нового conversation (диалог начат другим провайдером)
 	//LibraryId string // ID библиотеки Mistral для document_library (кэш из БД)
 }
 
@@ -104,6 +118,7 @@ func New(parent context.Context, actionHandler model.ActionHandler, db DB, route
 		responders:    sync.Map{},
 		waitChannels:  sync.Map{},
 		UserModelTTl:  mode.UserModelTTl,
+		images:        model.DefaultImageOptions(),
 		actionHandler: actionHandler,
 		router:        router,
 	}
@@ -201,6 +216,15 @@ func (m *Model) GetOrSetRespGPT(assist model.Assistant, dialogID, respId uint64,
 	if val, ok := m.responders.Load(respId); ok {
 		respModel := val.(*RespModel)
 		respModel.TTL = time.Now().Add(m.UserModelTTl) // Обновляем TTL при каждом обращении
+		// Диалог передан другому агенту (SwitchAssistant): conversation прежнего агента не
+		// продолжается — новый начинается с историей диалога из БД
+		if assist.AssistId != "" && respModel.Assist.AssistId != assist.AssistId {
+			respModel.Assist = assist
+			respModel.ConversationId = ""
+			respModel.ToolsSynced = false
+			respModel.Imported = m.importDialogHistory(dialogID)
+			m.loadModelParams(respModel)
+		}
 		return m.convertToModelRespModel(respModel), nil
 	}
 
@@ -273,18 +297,15 @@ func (m *Model) GetOrSetRespGPT(assist model.Assistant, dialogID, respId uint64,
 		}
 	}
 
-	// Загружаем параметры модели из БД (включая Haunter)
-	compressedData, _, err := m.db.ReadUserModelByProvider(assist.UserID, create.ProviderMistral)
-	if err != nil {
-		//logger.Warn("Ошибка чтения данных модели из БД: %v, используем конфигурацию по умолчанию", err, assist.userID)
-	} else if compressedData != nil && m.universalModel != nil {
-		if modelData, decompErr := m.universalModel.DecompressModelData(compressedData, nil); decompErr == nil {
-			user.Haunter = modelData.Haunter
-			//} else {
-			//	logger.Warn("Ошибка распаковки параметров модели: %v", decompErr, assist.userID)
-		}
+	// Conversation нет, а история есть — диалог вёлся другим провайдером до смены модели.
+	// Новый conversation будет начат с этой историей (restartConversationInputs)
+	if user.ConversationId == "" {
+		user.Imported = m.importDialogHistory(dialogID)
 	}
 
+	// Загружаем параметры модели из БД (включая Haunter)
+	m.loadModelParams(user)
+
 	//// Загружаем LibraryId ОДИН РАЗ при создании (избегаем запросов к БД при каждом сообщении)
 	//if libraryID, err := m.loadLibraryIdFromDB(assist.userID); err == nil {
 	//	user.LibraryId = libraryID
@@ -302,6 +323,51 @@ func (m *Model) GetOrSetRespGPT(assist model.Assistant, dialogID, respId uint64,
 	return m.convertToModelRespModel(user), nil
 }
 
+// readAssistantModel сжатые данные модели агента assist (у пользователя может быть
+// несколько агентов Mistral); nil — модель не найдена
+func (m *Model) readAssistantModel(assist model.Assistant) ([]byte, uint64, error) {
+	userModels, err := m.db.GetAllUserModels(assist.UserID)
+	if err != nil {
+		return nil, 0, fmt.Errorf("ошибка получения моделей пользователя: %w", err)
+	}
+	found := create.FindAssistantModel(userModels, create.ProviderMistral, assist.AssistId)
+	if found == nil {
+		return nil, 0, nil
+	}
+	compressedData, _, err := create.ReadAssistantModel(m.db, assist.UserID, found)
+	return compressedData, found.ModelId, err
+}
+
+// loadModelParams загружает параметры модели агента респондента (Haunter, модерация)
+func (m *Model) loadModelParams(user *RespModel) {
+	compressedData, modelID, err := m.readAssistantModel(user.Assist)
+	if err != nil {
+		//logger.Warn("Ошибка чтения данных модели из БД: %v, используем конфигурацию по умолчанию", err, assist.userID)
+		return
+	}
+	if compressedData == nil || m.universalModel == nil {
+		return
+	}
+	if modelData, decompErr := m.universalModel.DecompressModelData(compressedData, nil); decompErr == nil {
+		user.Haunter = modelData.Haunter
+		user.ResponseFields = modelData.ResponseFields
+		user.Generation = modelData.Generation
+		if modelData.GptType != nil {
+			user.ModelName = modelData.GptType.Name
+		}
+		user.Moderation = create.ModerationConfig{}
+		if modelData.Moderation != nil {
+			user.Moderation = *modelData.Moderation
+		}
+		// Инструкции агента с подсказкой MCP хранит Mistral: версия ведётся по промпту модели
+		user.ModelId = modelID
+		user.PromptVersion = create.SavePromptVersion(m.db, user.Assist.UserID, modelID, modelData.Prompt, modelData.Prompt)
+		user.PromptTokens = create.EstimateTokens(modelData.Prompt, user.ModelName)
+		//} else {
+		//	logger.Warn("Ошибка распаковки параметров модели: %v", decompErr, assist.userID)
+	}
+}
+
 // GetCh получает канал для респондента (реализация model.UniversalModel)
 func (m *Model) GetCh(respId uint64) (*model.Ch, error) {
 	return model.GetChannel(
@@ -379,6 +445,24 @@ func (m *Model) CleanDialogData(dialogID uint64) {
 	})
 }
 
+// importDialogHistory читает историю диалога из БД в формате локального контекста
+func (m *Model) importDialogHistory(dialogID uint64) []Message {
+	rawData, err := m.db.ReadDialog(dialogID, create.DialogHistoryLimit)
+	if err != nil || len(rawData) == 0 {
+		return nil
+	}
+	turns, err := model.DialogTurns(rawData)
+	if err != nil {
+		return nil
+	}
+
+	messages := make([]Message, 0, len(turns))
+	for _, turn := range turns {
+		messages = append(messages, Message{Type: turn.Role, Content: turn.Text})
+	}
+	return messages
+}
+
 // saveConversationId сохраняет conversation_id в БД (или удаляет если пустой)
 func (m *Model) saveConversationId(dialogID uint64, conversationId string) {
 	if conversationId == "" {
@@ -486,7 +570,7 @@ func (m *Model) transcribeAudioFile(audioData []byte, fileName string) (string,
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("ошибка API Mistral (статус %d): %s", resp.StatusCode, string(responseBody))
+		return "", providererr.New("Mistral", resp.StatusCode, resp.Header, responseBody)
 	}
 
 	// Парсим ответ
@@ -645,11 +729,51 @@ func (m *Model) cleanupWaitChannels() {
 	}
 }
 
+// SetMediaStore сохраняет сгенерированные изображения в хранилище по presigned URL
+func (m *Model) SetMediaStore(store storage.MediaStore) {
+	m.media = store
+}
+
+// SetImageOptions задаёт обработку сгенерированных изображений перед сохранением
+func (m *Model) SetImageOptions(opts model.ImageOptions) {
+	m.images = opts
+}
+
+// SetExperiments включает A/B-эксперименты. Модель и промпт задаются агентом Mistral,
+// поэтому вариант меняет только температуру: completion_args каждого запроса к conversation
+func (m *Model) SetExperiments(r *experiment.Registry) {
+	m.experiments = r
+}
+
+// conversationClient клиент запросов диалога с completion_args: параметры генерации модели
+// респондента и температура варианта эксперимента поверх них. Параметры передаются в каждом
+// запросе, а не только при создании агента: иначе агенты, созданные до их настройки, работали бы
+// со значениями Mistral по умолчанию
+func (m *Model) conversationClient(respModel *RespModel, userID uint32, dialogID uint64) *MistralAgentClient {
+	generation := respModel.Generation
+	if a, ok := m.experiments.Assign(userID, dialogID); ok && a.Temperature != nil {
+		generation = generation.WithTemperature(*a.Temperature)
+	}
+	return m.client.WithCompletionArgs(generation.Mistral())
+}
+
 // SetUniversalModel устанавливает UniversalModel для доступа к DecompressModelData
 func (m *Model) SetUniversalModel(um *create.UniversalModel) {
 	m.universalModel = um
 }
 
+// schemaRepair исправление ответа под схему той же моделью; nil — без исправления
+func (m *Model) schemaRepair(modelName string) model.SchemaRepairFunc {
+	if m.universalModel == nil || modelName == "" {
+		return nil
+	}
+	client, err := m.universalModel.Provider(create.ProviderMistral)
+	if err != nil {
+		return nil
+	}
+	return model.LLMSchemaRepair(m.ctx, client, modelName)
+}
+
 // InvalidateUserAgentConfigCache инвалидирует кэш конфигурации модели для пользователя
 func (m *Model) InvalidateUserAgentConfigCache(userID uint32) {
 	var invalidatedCount int
@@ -0,0 +1,106 @@
+package mistral
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+
+	"github.com/ikermy/AiR_Common/pkg/mode"
+	"github.com/ikermy/AiR_Common/pkg/model"
+	"github.com/ikermy/AiR_Common/pkg/providererr"
+)
+
+// MistralModerationModel модель модерации Mistral
+const MistralModerationModel = "mistral-moderation-latest"
+
+// BlockedMessage текст ответа пользователю вместо заблокированного сообщения
+const BlockedMessage = "Сообщение не может быть обработано: оно нарушает правила использования."
+
+// Moderate проверяет текст через Mistral moderations API
+// Документация: https://docs.mistral.ai/capabilities/guardrailing/
+func (m *MistralAgentClient) Moderate(ctx context.Context, text string, userID uint32) (model.ModerationResult, error) {
+	payload := map[string]any{
+		"model": MistralModerationModel,
+		"input": []string{text},
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return model.ModerationResult{}, fmt.Errorf("ошибка сериализации запроса: %v", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, mode.MistralModerationsURL, bytes.NewBuffer(body))
+	if err != nil {
+		return model.ModerationResult{}, fmt.Errorf("ошибка создания запроса: %v", err)
+	}
+
+	req.Header.Set("Authorization", "Bearer "+m.resolveKey(userID))
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return model.ModerationResult{}, fmt.Errorf("ошибка HTTP запроса: %v", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	responseBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return model.ModerationResult{}, fmt.Errorf("ошибка чтения ответа: %v", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return model.ModerationResult{}, providererr.New("Mistral", resp.StatusCode, resp.Header, responseBody)
+	}
+
+	var result struct {
+		Results []struct {
+			Categories map[string]bool `json:"categories"`
+		} `json:"results"`
+	}
+	if err := json.Unmarshal(responseBody, &result); err != nil {
+		return model.ModerationResult{}, fmt.Errorf("ошибка парсинга JSON: %v", err)
+	}
+
+	var moderation model.ModerationResult
+	for _, r := range result.Results {
+		for category, flagged := range r.Categories {
+			if flagged {
+				moderation.Categories = append(moderation.Categories, category)
+			}
+		}
+	}
+	sort.Strings(moderation.Categories)
+	moderation.Flagged = len(moderation.Categories) > 0
+
+	return moderation, nil
+}
+
+// Moderate реализует model.Moderator
+func (m *Model) Moderate(ctx context.Context, userID uint32, text string) (model.ModerationResult, error) {
+	return m.client.Moderate(ctx, text, userID)
+}
+
+// blockedByModeration проверяет текст, если модерация включена флагом enabled.
+// Ошибка модерации не блокирует диалог: ответ важнее недоступной проверки.
+func (m *Model) blockedByModeration(enabled bool, text string, userID uint32) (model.AssistResponse, bool) {
+	if !enabled || text == "" || m.client == nil {
+		return model.AssistResponse{}, false
+	}
+
+	result, err := m.client.Moderate(m.ctx, text, userID)
+	if err != nil || !result.Flagged {
+		//logger.Warn("Ошибка модерации: %v", err, userID)
+		return model.AssistResponse{}, false
+	}
+
+	return model.AssistResponse{
+		Message:      BlockedMessage,
+		Action:       model.Action{SendFiles: []model.File{}},
+		Blocked:      true,
+		BlockReasons: result.Categories,
+	}, true
+}
@@ -0,0 +1,127 @@
+package mistral
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/ikermy/AiR_Common/pkg/mode"
+	"github.com/ikermy/AiR_Common/pkg/providererr"
+)
+
+// MistralOCRModel модель распознавания документов Mistral OCR
+const MistralOCRModel = "mistral-ocr-latest"
+
+// ocrImageTypes изображения, которые распознаются через OCR по расширению файла
+var ocrImageTypes = map[string]string{
+	".png":  "image/png",
+	".jpg":  "image/jpeg",
+	".jpeg": "image/jpeg",
+	".webp": "image/webp",
+	".gif":  "image/gif",
+	".tif":  "image/tiff",
+	".tiff": "image/tiff",
+	".bmp":  "image/bmp",
+}
+
+// ocrMimeType возвращает MIME-тип файла, который понимает Mistral OCR; ok=false — OCR не применим
+func ocrMimeType(fileName string) (mimeType string, ok bool) {
+	ext := strings.ToLower(filepath.Ext(fileName))
+	if ext == ".pdf" {
+		return "application/pdf", true
+	}
+	mimeType, ok = ocrImageTypes[ext]
+	return mimeType, ok
+}
+
+// needsOCR определяет файлы, которые библиотека Mistral проиндексирует как мусор:
+// изображения и PDF без текстового слоя (сканы — в них нет шрифтов, только картинки)
+func needsOCR(fileName string, fileData []byte) bool {
+	mimeType, ok := ocrMimeType(fileName)
+	if !ok {
+		return false
+	}
+	if mimeType != "application/pdf" {
+		return true
+	}
+	return !bytes.Contains(fileData, []byte("/Font"))
+}
+
+// ocrPage страница результата OCR
+type ocrPage struct {
+	Index    int    `json:"index"`
+	Markdown string `json:"markdown"`
+}
+
+// OCRDocument распознаёт PDF или изображение через Mistral OCR и возвращает текст в Markdown
+// Документация: https://docs.mistral.ai/capabilities/document_ai/basic_ocr/
+func (m *MistralAgentClient) OCRDocument(fileName string, fileData []byte, userID uint32) (string, error) {
+	mimeType, ok := ocrMimeType(fileName)
+	if !ok {
+		return "", fmt.Errorf("формат файла %s не поддерживается OCR", fileName)
+	}
+
+	dataURL := fmt.Sprintf("data:%s;base64,%s", mimeType, base64.StdEncoding.EncodeToString(fileData))
+	document := map[string]any{"type": "document_url", "document_url": dataURL}
+	if mimeType != "application/pdf" {
+		document = map[string]any{"type": "image_url", "image_url": dataURL}
+	}
+
+	payload := map[string]any{
+		"model":    MistralOCRModel,
+		"document": document,
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return "", fmt.Errorf("ошибка сериализации запроса: %v", err)
+	}
+
+	req, err := http.NewRequestWithContext(m.ctx, http.MethodPost, mode.MistralOCRURL, bytes.NewBuffer(body))
+	if err != nil {
+		return "", fmt.Errorf("ошибка создания запроса: %v", err)
+	}
+
+	req.Header.Set("Authorization", "Bearer "+m.resolveKey(userID))
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("ошибка HTTP запроса: %v", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	responseBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("ошибка чтения ответа: %v", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return "", providererr.New("Mistral", resp.StatusCode, resp.Header, responseBody)
+	}
+
+	var result struct {
+		Pages []ocrPage `json:"pages"`
+	}
+	if err := json.Unmarshal(responseBody, &result); err != nil {
+		return "", fmt.Errorf("ошибка парсинга JSON: %v", err)
+	}
+
+	return joinOCRPages(result.Pages), nil
+}
+
+// joinOCRPages склеивает распознанные страницы, пропуская пустые
+func joinOCRPages(pages []ocrPage) string {
+	texts := make([]string, 0, len(pages))
+	for _, page := range pages {
+		if text := strings.TrimSpace(page.Markdown); text != "" {
+			texts = append(texts, text)
+		}
+	}
+	return strings.Join(texts, "\n\n")
+}
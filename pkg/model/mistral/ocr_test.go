@@ -0,0 +1,28 @@
+package mistral
+
+import "testing"
+
+func TestNeedsOCR(t *testing.T) {
+	cases := []struct {
+		name string
+		data string
+		want bool
+	}{
+		{"scan.pdf", "%PDF-1.4 /XObject /Image", true},
+		{"text.pdf", "%PDF-1.4 /Font /F1", false},
+		{"photo.JPG", "", true},
+		{"notes.txt", "text", false},
+	}
+	for _, c := range cases {
+		if got := needsOCR(c.name, []byte(c.data)); got != c.want {
+			t.Errorf("needsOCR(%q) = %v, want %v", c.name, got, c.want)
+		}
+	}
+}
+
+func TestJoinOCRPagesSkipsEmpty(t *testing.T) {
+	got := joinOCRPages([]ocrPage{{Markdown: "# Стр. 1"}, {Markdown: "  "}, {Markdown: "Стр. 3\n"}})
+	if got != "# Стр. 1\n\nСтр. 3" {
+		t.Fatalf("unexpected text: %q", got)
+	}
+}
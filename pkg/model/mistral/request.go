@@ -1,14 +1,17 @@
 package mistral
 
 import (
-	"encoding/base64"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"path"
 	"strings"
 	"time"
 
 	"github.com/ikermy/AiR_Common/pkg/model"
 	"github.com/ikermy/AiR_Common/pkg/model/create"
+	"github.com/ikermy/AiR_Common/pkg/providererr"
 )
 
 // createConversationInputs создаёт структуру inputs для Mistral Conversations API StartConversation
@@ -24,6 +27,43 @@ func createConversationInputs(content any) []map[string]any {
 	}
 }
 
+// restartConversationInputs формирует inputs для нового conversation диалога.
+// История хранится на стороне Mistral, но при сбросе conversation (ошибки API, смена tools)
+// она теряется — переносим в новый conversation последние сообщения локального контекста.
+// Перед ними идёт история, импортированная из БД, если диалог начинал другой провайдер.
+// Последнее сообщение контекста — текущий content, поэтому в историю оно не входит.
+// Старые сообщения, не помещающиеся в контекст модели, отбрасываются (fitHistory).
+func restartConversationInputs(respModel *RespModel, content any) []map[string]any {
+	history := append([]Message(nil), respModel.Imported...)
+	if respModel.Context != nil && len(respModel.Context.Messages) > 1 {
+		history = append(history, respModel.Context.Messages[:len(respModel.Context.Messages)-1]...)
+	}
+	// Импортированная история могла успеть получить текущий вопрос из очереди сохранения
+	if n := len(history); n > 0 && history[n-1].Type == "user" && history[n-1].Content == content {
+		history = history[:n-1]
+	}
+	if limit := int(create.DialogHistoryLimit); len(history) > limit {
+		history = history[len(history)-limit:]
+	}
+	history = respModel.fitHistory(history, content)
+
+	inputs := make([]map[string]any, 0, len(history)+1)
+	for _, msg := range history {
+		role := "user"
+		if msg.Type == "assistant" {
+			role = "assistant"
+		}
+		inputs = append(inputs, map[string]any{
+			"role":    role,
+			"content": msg.Content,
+			"object":  "entry",
+			"type":    "message.input",
+		})
+	}
+
+	return append(inputs, createConversationInputs(content)...)
+}
+
 // prepareUserContent подготавливает userContent для отправки в Mistral API
 // Возвращает либо простой текст, либо структурированный контент с изображениями
 // Консолидирует дублирующееся преобразование text+files в userContent
@@ -61,6 +101,7 @@ func prepareUserContent(text string, files []model.FileUpload) any {
 // Request выполняет запрос к Mistral модели, используя историю диалога как контекст
 func (m *Model) Request(userID uint32, dialogID uint64, text string, files ...model.FileUpload) (model.AssistResponse, error) {
 	var emptyResponse model.AssistResponse
+	ctx := model.TraceContext(m.ctx, dialogID)
 
 	if text == "" && len(files) == 0 {
 		return emptyResponse, fmt.Errorf("пустое сообщение и нет файлов")
@@ -89,6 +130,17 @@ func (m *Model) Request(userID uint32, dialogID uint64, text string, files ...mo
 
 	// Обновляем TTL респондера при каждом запросе
 	respModel.TTL = time.Now().Add(m.UserModelTTl)
+	client := m.conversationClient(respModel, userID, dialogID)
+
+	// Заблокированный вопрос не попадает ни в conversation, ни в локальный контекст
+	if blocked, ok := m.blockedByModeration(respModel.Moderation.Input, text, respModel.Assist.UserID); ok {
+		return blocked, nil
+	}
+
+	// Сообщение, которое не помещается в контекст модели даже без истории, не отправляем
+	if err := respModel.checkContext(prepareUserContent(text, files)); err != nil {
+		return emptyResponse, err
+	}
 
 	// Добавляем текущее сообщение в локальный контекст (для сохранения в БД)
 	userMessage := Message{
@@ -108,10 +160,10 @@ func (m *Model) Request(userID uint32, dialogID uint64, text string, files ...mo
 
 	if respModel.ConversationId == "" {
 		// Первый запрос - создаём новый conversation
-		inputs := createConversationInputs(userContent)
+		inputs := restartConversationInputs(respModel, userContent)
 
 		//logger.Debug("Создание нового conversation для агента %s", respModel.Assist.AssistId, userID)
-		convResp, err = m.client.StartConversation(respModel.Assist.AssistId, inputs, respModel.Assist.UserID)
+		convResp, err = client.StartConversation(respModel.Assist.AssistId, inputs, respModel.Assist.UserID)
 		if err != nil {
 			return emptyResponse, fmt.Errorf("ошибка создания conversation: %w", err)
 		}
@@ -125,7 +177,7 @@ func (m *Model) Request(userID uint32, dialogID uint64, text string, files ...mo
 	} else {
 		// Продолжаем существующий conversation
 		// Отправляем userContent (может содержать изображения)
-		convResp, err = m.client.ContinueConversation(respModel.ConversationId, userContent, respModel.Assist.UserID)
+		convResp, err = client.ContinueConversation(respModel.ConversationId, userContent, respModel.Assist.UserID)
 		if err != nil {
 			// Проверяем на ошибку 400 с кодом 3230 - рассинхронизация вызовов функций
 			if strings.Contains(err.Error(), "400") && strings.Contains(err.Error(), "Not the same number of function calls and responses") {
@@ -136,9 +188,9 @@ func (m *Model) Request(userID uint32, dialogID uint64, text string, files ...mo
 				m.saveConversationId(respModel.Chan.DialogID, "")
 
 				// Создаём новый conversation с текущим сообщением пользователя
-				inputs := createConversationInputs(userContent)
+				inputs := restartConversationInputs(respModel, userContent)
 
-				convResp, err = m.client.StartConversation(respModel.Assist.AssistId, inputs, respModel.Assist.UserID)
+				convResp, err = client.StartConversation(respModel.Assist.AssistId, inputs, respModel.Assist.UserID)
 				if err != nil {
 					return emptyResponse, fmt.Errorf("ошибка создания нового conversation после 400/3230: %w", err)
 				}
@@ -154,9 +206,9 @@ func (m *Model) Request(userID uint32, dialogID uint64, text string, files ...mo
 				respModel.ConversationId = ""
 
 				// Создаём новый conversation с текущим сообщением
-				inputs := createConversationInputs(userContent)
+				inputs := restartConversationInputs(respModel, userContent)
 
-				convResp, err = m.client.StartConversation(respModel.Assist.AssistId, inputs, respModel.Assist.UserID)
+				convResp, err = client.StartConversation(respModel.Assist.AssistId, inputs, respModel.Assist.UserID)
 				if err != nil {
 					return emptyResponse, fmt.Errorf("ошибка создания нового conversation после 404: %w", err)
 				}
@@ -174,9 +226,9 @@ func (m *Model) Request(userID uint32, dialogID uint64, text string, files ...mo
 				respModel.ConversationId = ""
 
 				// Создаём новый conversation с текущим сообщением
-				inputs := createConversationInputs(userContent)
+				inputs := restartConversationInputs(respModel, userContent)
 
-				convResp, err = m.client.StartConversation(respModel.Assist.AssistId, inputs, respModel.Assist.UserID)
+				convResp, err = client.StartConversation(respModel.Assist.AssistId, inputs, respModel.Assist.UserID)
 				if err != nil {
 					return emptyResponse, fmt.Errorf("ошибка создания нового conversation после 503: %w", err)
 				}
@@ -194,14 +246,9 @@ func (m *Model) Request(userID uint32, dialogID uint64, text string, files ...mo
 				m.saveConversationId(respModel.Chan.DialogID, "")
 
 				// Создаём новый conversation
-				inputs := []map[string]any{
-					{
-						"type":    "user",
-						"content": userContent,
-					},
-				}
+				inputs := restartConversationInputs(respModel, userContent)
 
-				convResp, err = m.client.StartConversation(respModel.Assist.AssistId, inputs, respModel.Assist.UserID)
+				convResp, err = client.StartConversation(respModel.Assist.AssistId, inputs, respModel.Assist.UserID)
 				if err != nil {
 					return emptyResponse, fmt.Errorf("ошибка создания нового conversation после 500: %w", err)
 				}
@@ -210,6 +257,21 @@ func (m *Model) Request(userID uint32, dialogID uint64, text string, files ...mo
 				respModel.ConversationId = convResp.ConversationID
 				m.saveConversationId(respModel.Chan.DialogID, respModel.ConversationId)
 				//logger.Debug("Создан новый conversation после 500: %s", respModel.ConversationId, userID)
+			} else if errors.Is(err, providererr.ErrContextTooLong) {
+				// История conversation переросла контекст модели: начинаем новый с той частью
+				// локального контекста, которая помещается (fitHistory)
+				respModel.ConversationId = ""
+				m.saveConversationId(respModel.Chan.DialogID, "")
+
+				inputs := restartConversationInputs(respModel, userContent)
+
+				convResp, err = client.StartConversation(respModel.Assist.AssistId, inputs, respModel.Assist.UserID)
+				if err != nil {
+					return emptyResponse, fmt.Errorf("ошибка создания нового conversation после переполнения контекста: %w", err)
+				}
+
+				respModel.ConversationId = convResp.ConversationID
+				m.saveConversationId(respModel.Chan.DialogID, respModel.ConversationId)
 			} else {
 				return emptyResponse, fmt.Errorf("ошибка продолжения conversation: %w", err)
 			}
@@ -231,7 +293,7 @@ func (m *Model) Request(userID uint32, dialogID uint64, text string, files ...mo
 	//logger.Debug("Mistral RAW ответ: Message='%s', HasFunc=%v, FuncName='%s'", response.Message, response.HasFunc, response.FuncName, userID)
 
 	// Обрабатываем ответ
-	assistResponse := m.processResponse(response, userID, respModel.Assist.Provider)
+	assistResponse := m.processResponse(response, userID, respModel)
 
 	// Обрабатываем цепочку вызовов функций (если есть)
 	// ВАЖНО: Mistral может вызывать несколько функций подряд (например: get_current_time -> sheets_write_range)
@@ -241,7 +303,7 @@ func (m *Model) Request(userID uint32, dialogID uint64, text string, files ...mo
 		functionCallCount++
 		//logger.Debug("Mistral вызвал функцию #%d: %s с аргументами: %s", functionCallCount, response.FuncName, response.FuncArgs, userID)
 
-		funcResult := m.actionHandler.RunAction(m.ctx, response.FuncName, response.FuncArgs, respModel.Assist.Provider, respModel.Assist.UserID)
+		funcResult := m.actionHandler.RunAction(model.WithDialogID(ctx, dialogID), response.FuncName, response.FuncArgs, respModel.Assist.Provider, respModel.Assist.UserID)
 		//logger.Debug("Результат функции #%d %s: %s", functionCallCount, response.FuncName, funcResult, userID)
 
 		// Сохраняем результат функции в контекст для истории
@@ -261,7 +323,7 @@ func (m *Model) Request(userID uint32, dialogID uint64, text string, files ...mo
 		if respModel.ConversationId != "" {
 			// Используем Conversations API с правильным форматом для function result
 			// Отправляем результат с type: "function.result" и tool_call_id согласно документации Mistral
-			convResp, err := m.client.SendFunctionResult(respModel.ConversationId, response.ToolCallID, funcResult, respModel.Assist.UserID)
+			convResp, err := client.SendFunctionResult(respModel.ConversationId, response.ToolCallID, funcResult, respModel.Assist.UserID)
 			if err != nil {
 				// Проверяем на ошибку 400 - невалидный tool_call_id или рассинхронизация вызовов функций
 				if strings.Contains(err.Error(), "400") && (strings.Contains(err.Error(), "Unexpected tool call id") || strings.Contains(err.Error(), "Not the same number of function calls and responses")) {
@@ -272,9 +334,9 @@ func (m *Model) Request(userID uint32, dialogID uint64, text string, files ...mo
 					m.saveConversationId(respModel.Chan.DialogID, "")
 
 					// Создаём новый conversation с контекстом последнего сообщения пользователя
-					inputs := createConversationInputs(fmt.Sprintf("Результат выполнения функции %s: %s", response.FuncName, funcResult))
+					inputs := restartConversationInputs(respModel, fmt.Sprintf("Результат выполнения функции %s: %s", response.FuncName, funcResult))
 
-					newConvResp, newErr := m.client.StartConversation(respModel.Assist.AssistId, inputs, respModel.Assist.UserID)
+					newConvResp, newErr := client.StartConversation(respModel.Assist.AssistId, inputs, respModel.Assist.UserID)
 					if newErr != nil {
 						return emptyResponse, fmt.Errorf("ошибка восстановления после рассинхронизации функций: %w", newErr)
 					}
@@ -313,9 +375,9 @@ func (m *Model) Request(userID uint32, dialogID uint64, text string, files ...mo
 			//logger.Warn("conversation_id пустой после ошибки, создаём новый conversation для отправки результата функции", userID)
 
 			// Создаём новый conversation с результатом функции
-			inputs := createConversationInputs(fmt.Sprintf("Результат выполнения функции %s: %s", response.FuncName, funcResult))
+			inputs := restartConversationInputs(respModel, fmt.Sprintf("Результат выполнения функции %s: %s", response.FuncName, funcResult))
 
-			newConvResp, err := m.client.StartConversation(respModel.Assist.AssistId, inputs, respModel.Assist.UserID)
+			newConvResp, err := client.StartConversation(respModel.Assist.AssistId, inputs, respModel.Assist.UserID)
 			if err != nil {
 				//logger.Error("Ошибка создания нового conversation после функции: %v", err, userID)
 				// Оставляем текущий assistResponse
@@ -334,7 +396,7 @@ func (m *Model) Request(userID uint32, dialogID uint64, text string, files ...mo
 			//logger.Debug("RAW ответ агента: Message='%s', HasFunc=%v, FuncName='%s'", finalResponse.Message, finalResponse.HasFunc, finalResponse.FuncName, userID)
 
 			response = finalResponse
-			assistResponse = m.processResponse(finalResponse, userID, respModel.Assist.Provider)
+			assistResponse = m.processResponse(finalResponse, userID, respModel)
 
 			// Если это НЕ вызов функции, выходим из цикла - получен финальный ответ
 			if !finalResponse.HasFunc {
@@ -354,6 +416,10 @@ func (m *Model) Request(userID uint32, dialogID uint64, text string, files ...mo
 		//logger.Warn("Достигнут лимит вызовов функций (%d), прерываем цепочку", create.MaxFunctionCalls, userID)
 	}
 
+	if blocked, ok := m.blockedByModeration(respModel.Moderation.Output, assistResponse.Message, respModel.Assist.UserID); ok {
+		return blocked, nil
+	}
+
 	// Добавляем ответ ассистента в контекст только если он не пустой
 	if assistResponse.Message != "" {
 		assistantMessage := Message{
@@ -364,6 +430,7 @@ func (m *Model) Request(userID uint32, dialogID uint64, text string, files ...mo
 
 		respModel.Context.Messages = append(respModel.Context.Messages, assistantMessage)
 		respModel.Context.LastUsed = time.Now()
+		create.RecordPromptTurn(m.db, userID, dialogID, respModel.ModelId, respModel.PromptVersion)
 		//} else {
 		//	logger.Warn("Получен пустой ответ от ассистента, не добавляем в контекст", userID)
 	}
@@ -371,46 +438,21 @@ func (m *Model) Request(userID uint32, dialogID uint64, text string, files ...mo
 	return assistResponse, nil
 }
 
-// processResponse обрабатывает ответ от Mistral
-func (m *Model) processResponse(response Response, userID uint32, provider create.ProviderType) model.AssistResponse {
+// processResponse обрабатывает ответ от Mistral: JSON разбирается по схеме ответа
+// (model.ParseAssistResponse) с дополнительными полями ассистента respModel
+func (m *Model) processResponse(response Response, userID uint32, respModel *RespModel) model.AssistResponse {
 	messageText := strings.TrimSpace(response.Message)
-
-	// СНАЧАЛА парсим JSON из ответа (если есть) чтобы получить красивые имена файлов
-	var userFileNames []string // Красивые имена из send_files
-
-	// Убираем markdown блок ```json ... ``` если есть
-	if strings.HasPrefix(messageText, "```json") || strings.HasPrefix(messageText, "```JSON") {
-		messageText = strings.TrimPrefix(messageText, "```json")
-		messageText = strings.TrimPrefix(messageText, "```JSON")
-		messageText = strings.TrimSpace(messageText)
-
-		if idx := strings.LastIndex(messageText, "```"); idx != -1 {
-			messageText = messageText[:idx]
-			messageText = strings.TrimSpace(messageText)
-		}
-		//logger.Debug("processResponse: удалён markdown блок, извлечён чистый JSON")
+	if strings.HasPrefix(trimJSONFence(messageText), "{") {
+		messageText = escapeJSONControlChars(messageText)
 	}
-
-	// Попытка распарсить ответ как JSON для получения красивых имён файлов
-	if messageText != "" && (strings.HasPrefix(messageText, "{") || strings.HasPrefix(messageText, "[")) {
-		var structuredResponse struct {
-			Action struct {
-				SendFiles []struct {
-					FileName string `json:"file_name"`
-				} `json:"send_files"`
-			} `json:"action"`
-		}
-
-		if err := json.Unmarshal([]byte(messageText), &structuredResponse); err == nil {
-			// Извлекаем имена файлов из send_files
-			for _, file := range structuredResponse.Action.SendFiles {
-				if file.FileName != "" {
-					userFileNames = append(userFileNames, file.FileName)
-				}
-			}
-			//if len(userFileNames) > 0 {
-			//	logger.Debug("processResponse: извлечено %d имён файлов из JSON: %v", len(userFileNames), userFileNames)
-			//}
+	assistResponse := model.ParseAssistResponse(userID, messageText, m.schemaRepair(respModel.ModelName), respModel.ResponseFields...)
+	provider := respModel.Assist.Provider
+
+	// Красивые имена сгенерированных изображений из send_files
+	var userFileNames []string
+	for _, file := range assistResponse.Action.SendFiles {
+		if file.FileName != "" {
+			userFileNames = append(userFileNames, file.FileName)
 		}
 	}
 
@@ -456,18 +498,10 @@ func (m *Model) processResponse(response Response, userID uint32, provider creat
 				fileName = fmt.Sprintf("image_%s.%s", uniquePrefix, img.FileType)
 			}
 
-			// Вызываем save_image через action handler с base64 данными
-			args := fmt.Sprintf(`{"image_data":"%s","file_name":"%s"}`,
-				base64Encode(imageData), fileName)
-
-			result := m.actionHandler.RunAction(m.ctx, "save_image", args, provider, userID)
-
-			var saveResult struct {
-				URL   string `json:"url"`
-				Error string `json:"error"`
-			}
-
-			if err := json.Unmarshal([]byte(result), &saveResult); err == nil && saveResult.URL != "" {
+			// Сохраняем в хранилище (или через save_image, если хранилище не подключено)
+			imageURL, savedName, err := model.SaveGeneratedMedia(m.ctx, m.media, m.actionHandler, m.images, provider, userID, fileName,
+				model.DetectMimeType(fileName, imageData), imageData)
+			if err == nil {
 				// Определяем тип файла
 				var fileType model.FileType
 				if img.FileType == "gif" {
@@ -478,12 +512,12 @@ func (m *Model) processResponse(response Response, userID uint32, provider creat
 
 				savedFiles = append(savedFiles, model.File{
 					Type:     fileType,
-					URL:      saveResult.URL,
-					FileName: fileName,
+					URL:      imageURL,
+					FileName: savedName,
 					Caption:  "", // Caption будет взят из JSON send_files при замене URL
 				})
 
-				//logger.Debug("processResponse: изображение сохранено, URL=%s", saveResult.URL)
+				//logger.Debug("processResponse: изображение сохранено, URL=%s", imageURL)
 			}
 		}
 
@@ -498,126 +532,100 @@ func (m *Model) processResponse(response Response, userID uint32, provider creat
 		// и заменить URL в send_files на реальные
 	}
 
-	// Попытка распарсить ответ как JSON (агенты Mistral могут возвращать структурированные ответы)
-	// messageText уже обработан (убран markdown) в начале функции
-	if messageText != "" && (strings.HasPrefix(messageText, "{") || strings.HasPrefix(messageText, "[")) {
-		//logger.Debug("processResponse: обнаружен JSON в ответе, пытаемся распарсить")
-
-		// Удаляем реальные переносы строк и табуляции (НЕ экранированные)
-		messageText = strings.ReplaceAll(messageText, "\n", "")
-		messageText = strings.ReplaceAll(messageText, "\r", "")
-		messageText = strings.ReplaceAll(messageText, "\t", "")
-
-		// Заменяем экранированные последовательности на пробелы внутри JSON-строк
-		messageText = strings.ReplaceAll(messageText, "\\n", " ")
-		messageText = strings.ReplaceAll(messageText, "\\t", " ")
-
-		var structuredResponse struct {
-			Message string `json:"message"`
-			Action  struct {
-				SendFiles []model.File `json:"send_files"`
-			} `json:"action"`
-			Target   bool `json:"target"`
-			Operator bool `json:"operator"`
+	if len(assistResponse.Action.SendFiles) > 0 {
+		// Сгенерированные изображения уже сохранены выше: URL из send_files
+		// (временные ссылки Mistral) заменяются на сохранённые
+		savedFilesByName := make(map[string]model.File)
+		for _, file := range savedFiles {
+			savedFilesByName[file.FileName] = file
+			// Также добавляем базовое имя (без расширения) для лучшего сопоставления
+			if idx := strings.LastIndex(file.FileName, "."); idx != -1 {
+				savedFilesByName[file.FileName[:idx]] = file
+			}
 		}
 
-		if err := json.Unmarshal([]byte(messageText), &structuredResponse); err == nil {
-			// Успешно распарсили JSON - извлекаем данные
-			//logger.Debug("processResponse: JSON успешно распарсен, message='%s', target=%v, operator=%v, files=%d",
-			//	structuredResponse.Message, structuredResponse.Target, structuredResponse.Operator, len(structuredResponse.Action.SendFiles))
+		for i := range assistResponse.Action.SendFiles {
+			fileFromJSON := &assistResponse.Action.SendFiles[i]
 
-			// ВАЖНО: Используем ТОЛЬКО извлечённое message, а не весь JSON!
-			assistResponse := model.AssistResponse{
-				Message:  structuredResponse.Message, // Только текст сообщения, БЕЗ JSON!
-				Meta:     structuredResponse.Target,
-				Operator: structuredResponse.Operator,
+			searchName := fileFromJSON.FileName
+			if idx := strings.LastIndex(searchName, "."); idx != -1 {
+				searchName = searchName[:idx]
 			}
 
-			// Обрабатываем action.send_files если есть
-			if len(structuredResponse.Action.SendFiles) > 0 {
-				// ВАЖНО: Если есть сгенерированные изображения (response.GeneratedImages),
-				// они уже были скачаны и сохранены выше.
-				// Нужно заменить URL из send_files (временные Azure URL) на реальные сохранённые URL.
-
-				// Создаём мапу сохранённых файлов для быстрого поиска по имени
-				savedFilesByName := make(map[string]model.File)
-				if len(savedFiles) > 0 {
-					for _, file := range savedFiles {
-						// Используем и оригинальное имя И имя без расширения для поиска
-						savedFilesByName[file.FileName] = file
-
-						// Также добавляем базовое имя (без расширения) для лучшего сопоставления
-						if idx := strings.LastIndex(file.FileName, "."); idx != -1 {
-							baseName := file.FileName[:idx]
-							savedFilesByName[baseName] = file
-						}
-					}
-				}
-
-				// Проходим по файлам из JSON и заменяем URL если файл был сохранён
-				for i := range structuredResponse.Action.SendFiles {
-					fileFromJSON := &structuredResponse.Action.SendFiles[i]
-
-					// Получаем базовое имя из JSON (без расширения для поиска)
-					searchName := fileFromJSON.FileName
-					if idx := strings.LastIndex(searchName, "."); idx != -1 {
-						searchName = searchName[:idx]
-					}
-
-					// Ищем сохранённый файл по имени или базовому имени
-					if savedFile, found := savedFilesByName[fileFromJSON.FileName]; found {
-						// Файл найден по полному имени
-						fileFromJSON.URL = savedFile.URL
-						// Используем имя из JSON, но URL реальный
-					} else if savedFile, found := savedFilesByName[searchName]; found {
-						// Файл найден по базовому имени
-						fileFromJSON.URL = savedFile.URL
-					} else if fileFromJSON.Type == model.Photo && len(savedFiles) > 0 {
-						// Это photo и есть сохранённые файлы - используем первый сохранённый
-						fileFromJSON.URL = savedFiles[0].URL
-						// Оставляем file_name из JSON для красивого отображения
-					}
+			savedFile, found := savedFilesByName[fileFromJSON.FileName]
+			if !found {
+				savedFile, found = savedFilesByName[searchName]
+			}
+			if !found && fileFromJSON.Type == model.Photo && len(savedFiles) > 0 {
+				// Это photo и есть сохранённые файлы - используем первый сохранённый
+				savedFile, found = savedFiles[0], true
+			}
+			if found {
+				fileFromJSON.URL = savedFile.URL
+				// При обработке формат мог смениться (ImageOptions.Format): расширение — как у сохранённого файла
+				if fileFromJSON.FileName == "" {
+					fileFromJSON.FileName = savedFile.FileName
+				} else {
+					fileFromJSON.FileName = strings.TrimSuffix(fileFromJSON.FileName, path.Ext(fileFromJSON.FileName)) + path.Ext(savedFile.FileName)
 				}
-
-				assistResponse.Action.SendFiles = structuredResponse.Action.SendFiles
-				//logger.Debug("processResponse: добавлено %d файлов в Action", len(structuredResponse.Action.SendFiles))
-			} else if len(savedFiles) > 0 {
-				// В JSON нет send_files, но есть сохранённые изображения - используем их
-				assistResponse.Action.SendFiles = savedFiles
-				//logger.Debug("processResponse: использованы сохранённые файлы (%d шт)", len(savedFiles))
 			}
-
-			return assistResponse
-			//} else {
-			//	logger.Warn("processResponse: ошибка парсинга JSON: %v", err)
 		}
-		// Если парсинг не удался, продолжаем обработку как обычный текст
-	}
-
-	// Обычный текстовый ответ (не JSON)
-	assistResponse := model.AssistResponse{
-		Message:  response.Message,
-		Meta:     false,
-		Operator: false,
-	}
-
-	// Если есть вызов функции, но нет сообщения, используем пустую строку
-	if response.HasFunc && response.Message == "" {
-		assistResponse.Message = ""
+	} else if len(savedFiles) > 0 {
+		// В JSON нет send_files, но есть сохранённые изображения - используем их
+		assistResponse.Action.SendFiles = savedFiles
 	}
 
 	return assistResponse
 }
 
-// base64Encode кодирует данные в base64
-func base64Encode(data []byte) string {
-	return base64.StdEncoding.EncodeToString(data)
+// trimJSONFence убирает обрамление ```json ... ``` для проверки, JSON ли ответ
+func trimJSONFence(text string) string {
+	text = strings.TrimPrefix(text, "```json")
+	text = strings.TrimPrefix(text, "```JSON")
+	return strings.TrimSpace(strings.TrimPrefix(text, "```"))
+}
+
+// escapeJSONControlChars экранирует переносы строк и табуляции внутри строк JSON:
+// агенты Mistral вставляют их в message без экранирования
+func escapeJSONControlChars(text string) string {
+	var b strings.Builder
+	b.Grow(len(text))
+	inString, escaped := false, false
+	for _, r := range text {
+		switch {
+		case escaped:
+			escaped = false
+		case inString && r == '\\':
+			escaped = true
+		case r == '"':
+			inString = !inString
+		case inString && r == '\n':
+			b.WriteString(`\n`)
+			continue
+		case inString && r == '\r':
+			b.WriteString(`\r`)
+			continue
+		case inString && r == '\t':
+			b.WriteString(`\t`)
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
 }
 
 // RequestStreaming выполняет запрос с потоковой передачей (TRUE STREAMING)
 // Использует Mistral Conversations API в streaming режиме с Server-Sent Events (SSE)
 // Поддерживает вызов функций и подсчет токенов
 func (m *Model) RequestStreaming(userID uint32, dialogID uint64, text string, onDelta func(delta string, done bool) error, files ...model.FileUpload) error {
+	return m.RequestStreamingContext(model.TraceContext(m.ctx, dialogID), userID, dialogID, text, onDelta, files...)
+}
+
+// RequestStreamingContext RequestStreaming, прерываемый отменой ctx
+func (m *Model) RequestStreamingContext(ctx context.Context, userID uint32, dialogID uint64, text string, onDelta func(delta string, done bool) error, files ...model.FileUpload) error {
+	ctx, cancel := model.RequestContext(m.ctx, model.TraceContext(ctx, dialogID))
+	defer cancel()
+
 	if text == "" && len(files) == 0 {
 		return fmt.Errorf("пустое сообщение и нет файлов")
 	}
@@ -643,6 +651,12 @@ func (m *Model) RequestStreaming(userID uint32, dialogID uint64, text string, on
 
 	// Обновляем TTL респондера при каждом запросе
 	respModel.TTL = time.Now().Add(m.UserModelTTl)
+	client := m.conversationClient(respModel, userID, dialogID)
+
+	// Заблокированный вопрос не попадает ни в conversation, ни в локальный контекст
+	if blocked, ok := m.blockedByModeration(respModel.Moderation.Input, text, respModel.Assist.UserID); ok {
+		return sendFinalResponse(blocked, onDelta)
+	}
 
 	// Синхронизируем инструменты агента один раз за сессию.
 	// PATCHит агент актуальными MCP-tools и сбрасывает ConversationId если конфигурация изменилась.
@@ -650,6 +664,10 @@ func (m *Model) RequestStreaming(userID uint32, dialogID uint64, text string, on
 		m.syncAgentTools(respModel)
 	}
 
+	if err := respModel.checkContext(prepareUserContent(text, files)); err != nil {
+		return err
+	}
+
 	// Добавляем текущее сообщение в локальный контекст
 	userMessage := Message{
 		Type:      "user",
@@ -662,26 +680,7 @@ func (m *Model) RequestStreaming(userID uint32, dialogID uint64, text string, on
 	// Формируем userContent для отправки в API
 	userContent := prepareUserContent(text, files)
 
-	// Wrapper для onDelta - обрабатывает как текстовые дельты, так и JSON события function calls
-	wrappedOnDelta := func(delta string) error {
-		if onDelta == nil {
-			return nil
-		}
-
-		// Проверяем, является ли delta JSON событием (начинается с '{')
-		if len(delta) > 0 && delta[0] == '{' {
-			var event map[string]any
-			if err := json.Unmarshal([]byte(delta), &event); err == nil {
-				if eventType, ok := event["type"].(string); ok && eventType == "function_call" {
-					// Это событие вызова функции - отправляем как есть (уже JSON)
-					return onDelta(delta, false)
-				}
-			}
-		}
-
-		// Обычная текстовая дельта
-		return onDelta(delta, false)
-	}
+	wrappedOnDelta := streamDeltas(onDelta, respModel.Moderation.Output)
 
 	// Используем Conversations API в streaming режиме
 	var convResp ConversationResponse
@@ -689,9 +688,9 @@ func (m *Model) RequestStreaming(userID uint32, dialogID uint64, text string, on
 
 	if respModel.ConversationId == "" {
 		// Первый запрос - создаём новый conversation
-		inputs := createConversationInputs(userContent)
+		inputs := restartConversationInputs(respModel, userContent)
 
-		convResp, err = m.client.StartConversationStreaming(respModel.Assist.AssistId, inputs, wrappedOnDelta, respModel.Assist.UserID)
+		convResp, err = client.StartConversationStreaming(ctx, respModel.Assist.AssistId, inputs, wrappedOnDelta, respModel.Assist.UserID)
 		if err != nil {
 			return fmt.Errorf("ошибка создания streaming conversation: %w", err)
 		}
@@ -701,7 +700,7 @@ func (m *Model) RequestStreaming(userID uint32, dialogID uint64, text string, on
 		m.saveConversationId(respModel.Chan.DialogID, respModel.ConversationId)
 	} else {
 		// Продолжаем существующий conversation
-		convResp, err = m.client.ContinueConversationStreaming(respModel.ConversationId, userContent, wrappedOnDelta, respModel.Assist.UserID)
+		convResp, err = client.ContinueConversationStreaming(ctx, respModel.ConversationId, userContent, wrappedOnDelta, respModel.Assist.UserID)
 		if err != nil {
 			// Обработка ошибок - сброс и пересоздание conversation
 			if strings.Contains(err.Error(), "400") || strings.Contains(err.Error(), "404") ||
@@ -712,9 +711,9 @@ func (m *Model) RequestStreaming(userID uint32, dialogID uint64, text string, on
 				m.saveConversationId(respModel.Chan.DialogID, "")
 
 				// Создаём новый conversation
-				inputs := createConversationInputs(userContent)
+				inputs := restartConversationInputs(respModel, userContent)
 
-				convResp, err = m.client.StartConversationStreaming(respModel.Assist.AssistId, inputs, wrappedOnDelta, respModel.Assist.UserID)
+				convResp, err = client.StartConversationStreaming(ctx, respModel.Assist.AssistId, inputs, wrappedOnDelta, respModel.Assist.UserID)
 				if err != nil {
 					return fmt.Errorf("ошибка создания нового streaming conversation: %w", err)
 				}
@@ -762,7 +761,13 @@ func (m *Model) RequestStreaming(userID uint32, dialogID uint64, text string, on
 	// Если нет function calls в первом ответе - это обычный текстовый ответ
 	if len(functionCalls) == 0 {
 		response := ParseConversationResponse(convResp)
-		assistResponse := m.processResponse(response, userID, respModel.Assist.Provider)
+		assistResponse := m.processResponse(response, userID, respModel)
+
+		// Дельты уже ушли клиенту, но финальный ответ (done=true) заменяется заблокированным
+		if blocked, ok := m.blockedByModeration(respModel.Moderation.Output, assistResponse.Message, respModel.Assist.UserID); ok {
+			sendTotalUsage()
+			return sendFinalResponse(blocked, onDelta)
+		}
 
 		// Сохраняем ответ в контекст
 		if assistResponse.Message != "" {
@@ -773,6 +778,7 @@ func (m *Model) RequestStreaming(userID uint32, dialogID uint64, text string, on
 			}
 			respModel.Context.Messages = append(respModel.Context.Messages, assistantMessage)
 			respModel.Context.LastUsed = time.Now()
+			create.RecordPromptTurn(m.db, userID, dialogID, respModel.ModelId, respModel.PromptVersion)
 		}
 
 		// Отправляем финальный ответ
@@ -807,7 +813,7 @@ func (m *Model) RequestStreaming(userID uint32, dialogID uint64, text string, on
 			//logger.Debug("Вызов функции #%d в раунде %d: %s с аргументами: %s",
 			//	i+1, functionCallRound, funcCall.Name, funcCall.Arguments, userID)
 
-			funcResult := m.actionHandler.RunAction(m.ctx, funcCall.Name, funcCall.Arguments, respModel.Assist.Provider, respModel.Assist.UserID)
+			funcResult := m.actionHandler.RunAction(model.WithDialogID(ctx, dialogID), funcCall.Name, funcCall.Arguments, respModel.Assist.Provider, respModel.Assist.UserID)
 			//logger.Debug("Результат функции %s: %s", funcCall.Name, funcResult, userID)
 
 			// Сохраняем результат функции
@@ -829,7 +835,8 @@ func (m *Model) RequestStreaming(userID uint32, dialogID uint64, text string, on
 
 		// Отправляем ВСЕ результаты функций одним запросом
 		if respModel.ConversationId != "" && len(functionResults) > 0 {
-			finalConvResp, err := m.client.SendMultipleFunctionResultsStreaming(
+			finalConvResp, err := client.SendMultipleFunctionResultsStreaming(
+				ctx,
 				respModel.ConversationId,
 				functionResults,
 				wrappedOnDelta,
@@ -871,13 +878,18 @@ func (m *Model) RequestStreaming(userID uint32, dialogID uint64, text string, on
 
 			// Парсим ответ для проверки наличия контента
 			response := ParseConversationResponse(finalConvResp)
-			assistResponse := m.processResponse(response, userID, respModel.Assist.Provider)
+			assistResponse := m.processResponse(response, userID, respModel)
 
 			// Если нет больше function calls И есть текстовый ответ - это финальный ответ
 			if len(functionCalls) == 0 {
 				//logger.Debug("Раунд #%d завершен: получен финальный ответ (len=%d)",
 				//	functionCallRound, len(assistResponse.Message), userID)
 
+				if blocked, ok := m.blockedByModeration(respModel.Moderation.Output, assistResponse.Message, respModel.Assist.UserID); ok {
+					sendTotalUsage()
+					return sendFinalResponse(blocked, onDelta)
+				}
+
 				// Сохраняем ответ в контекст если есть
 				if assistResponse.Message != "" {
 					assistantMessage := Message{
@@ -887,6 +899,7 @@ func (m *Model) RequestStreaming(userID uint32, dialogID uint64, text string, on
 					}
 					respModel.Context.Messages = append(respModel.Context.Messages, assistantMessage)
 					respModel.Context.LastUsed = time.Now()
+					create.RecordPromptTurn(m.db, userID, dialogID, respModel.ModelId, respModel.PromptVersion)
 				}
 
 				// Отправляем финальный ответ (даже если пустой)
@@ -922,7 +935,7 @@ func (m *Model) RequestStreaming(userID uint32, dialogID uint64, text string, on
 	// Если вышли из цикла без финального ответа - отправляем пустой ответ
 	//logger.Warn("Получен пустой ответ от ассистента, не добавляем в контекст", userID)
 
-	emptyResponse := m.processResponse(Response{}, userID, respModel.Assist.Provider)
+	emptyResponse := m.processResponse(Response{}, userID, respModel)
 	responseJSON, _ := json.Marshal(emptyResponse)
 
 	sendTotalUsage()
@@ -936,6 +949,48 @@ func (m *Model) RequestStreaming(userID uint32, dialogID uint64, text string, on
 	return nil
 }
 
+// sendFinalResponse отправляет клиенту финальный ответ (done=true)
+// streamDeltas wrapper для onDelta - обрабатывает как текстовые дельты, так и JSON события
+// function calls. При модерации ответов (moderated) текстовые дельты клиенту не отправляются:
+// ответ уходит финальной дельтой только после проверки
+func streamDeltas(onDelta func(delta string, done bool) error, moderated bool) func(delta string) error {
+	return func(delta string) error {
+		if onDelta == nil {
+			return nil
+		}
+
+		// Проверяем, является ли delta JSON событием (начинается с '{')
+		if len(delta) > 0 && delta[0] == '{' {
+			var event map[string]any
+			if err := json.Unmarshal([]byte(delta), &event); err == nil {
+				if eventType, ok := event["type"].(string); ok && eventType == "function_call" {
+					// Это событие вызова функции - отправляем как есть (уже JSON)
+					return onDelta(delta, false)
+				}
+			}
+		}
+
+		// Обычная текстовая дельта
+		if moderated {
+			return nil
+		}
+		return onDelta(delta, false)
+	}
+}
+
+func sendFinalResponse(response model.AssistResponse, onDelta func(delta string, done bool) error) error {
+	responseJSON, err := json.Marshal(response)
+	if err != nil {
+		return fmt.Errorf("ошибка сериализации ответа: %w", err)
+	}
+	if onDelta != nil {
+		if err := onDelta(string(responseJSON), true); err != nil {
+			//logger.Warn("Ошибка в onDelta callback: %v", err)
+		}
+	}
+	return nil
+}
+
 // syncAgentTools синхронизирует набор инструментов Mistral Agent с текущим MCP-сервером.
 // Вызывается один раз за сессию (ToolsSynced == false) перед первым запросом.
 // Если инструменты изменились — вызывает PATCH /v1/agents/{id} и сбрасывает ConversationId,
@@ -970,7 +1025,7 @@ func (m *Model) syncAgentTools(respModel *RespModel) {
 
 	// Нативные built-in инструменты агента (code_interpreter, image_generation, web_search, document_library)
 	if m.universalModel != nil {
-		if compressedData, _, err := m.db.ReadUserModelByProvider(respModel.Assist.UserID, create.ProviderMistral); err == nil && compressedData != nil {
+		if compressedData, _, err := m.readAssistantModel(respModel.Assist); err == nil && compressedData != nil {
 			if modelData, err := m.universalModel.DecompressModelData(compressedData, nil); err == nil {
 				if modelData.Interpreter {
 					tools = append(tools, map[string]any{"type": "code_interpreter"})
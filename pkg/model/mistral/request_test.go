@@ -0,0 +1,164 @@
+package mistral
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/ikermy/AiR_Common/pkg/model/create"
+)
+
+func TestRestartConversationInputsPrependsImportedHistory(t *testing.T) {
+	resp := &RespModel{
+		Imported: []Message{
+			{Type: "user", Content: "Здравствуйте"},
+			{Type: "assistant", Content: "Добрый день!"},
+			{Type: "user", Content: "Сколько стоит?"}, // текущий вопрос уже успел попасть в БД
+		},
+		Context: &DialogContext{Messages: []Message{{Type: "user", Content: "Сколько стоит?"}}},
+	}
+
+	inputs := restartConversationInputs(resp, "Сколько стоит?")
+	if len(inputs) != 3 {
+		t.Fatalf("got %d inputs, want 3: %v", len(inputs), inputs)
+	}
+	if inputs[1]["role"] != "assistant" || inputs[2]["content"] != "Сколько стоит?" {
+		t.Errorf("unexpected inputs: %v", inputs)
+	}
+}
+
+func TestRestartConversationInputsCarriesLocalContext(t *testing.T) {
+	// Без истории — только текущее сообщение
+	inputs := restartConversationInputs(&RespModel{Context: &DialogContext{Messages: []Message{{Type: "user", Content: "Привет"}}}}, "Привет")
+	if len(inputs) != 1 || inputs[0]["role"] != "user" || inputs[0]["content"] != "Привет" {
+		t.Fatalf("без истории: %v", inputs)
+	}
+
+	// Последнее сообщение контекста — текущий вопрос, в историю не попадает
+	resp := &RespModel{Context: &DialogContext{Messages: []Message{
+		{Type: "user", Content: "Есть доставка?"},
+		{Type: "assistant", Content: "Да, 2 дня"},
+		{Type: "user", Content: "А в Казань?"},
+	}}}
+	inputs = restartConversationInputs(resp, "А в Казань?")
+	if len(inputs) != 3 || inputs[0]["content"] != "Есть доставка?" || inputs[1]["role"] != "assistant" ||
+		inputs[2]["content"] != "А в Казань?" {
+		t.Fatalf("с историей: %v", inputs)
+	}
+	for _, in := range inputs {
+		if in["object"] != "entry" || in["type"] != "message.input" {
+			t.Fatalf("формат entry: %v", in)
+		}
+	}
+
+	// История обрезается до DialogHistoryLimit последних сообщений
+	limit := int(create.DialogHistoryLimit)
+	long := &RespModel{Context: &DialogContext{}}
+	for i := 0; i < limit+5; i++ {
+		long.Context.Messages = append(long.Context.Messages, Message{Type: "user", Content: fmt.Sprint(i)})
+	}
+	inputs = restartConversationInputs(long, "вопрос")
+	if len(inputs) != limit+1 || inputs[0]["content"] != "4" { // limit+4 сообщений истории без текущего
+		t.Fatalf("лимит истории: %d inputs, первый %v", len(inputs), inputs[0]["content"])
+	}
+}
+
+func TestRestartConversationInputsFitsContextWindow(t *testing.T) {
+	const modelName = "magistral-medium"
+	limit := create.ChatInputLimit(modelName)
+	chunk := strings.Repeat("доставка по городу ", 2000)
+	size := messageTokens("user", chunk, modelName)
+	current := messageTokens("user", "вопрос", modelName)
+
+	resp := &RespModel{ModelName: modelName, Context: &DialogContext{}}
+	for i := 0; i < 5; i++ {
+		role := "user"
+		if i%2 == 1 {
+			role = "assistant"
+		}
+		resp.Context.Messages = append(resp.Context.Messages, Message{Type: role, Content: fmt.Sprint(i, chunk)})
+	}
+	resp.Context.Messages = append(resp.Context.Messages, Message{Type: "user", Content: "вопрос"})
+
+	// Помещаются три последних сообщения истории
+	resp.PromptTokens = limit - 3*size - current - 10
+	inputs := restartConversationInputs(resp, "вопрос")
+	if len(inputs) != 4 || !strings.HasPrefix(inputs[0]["content"].(string), "2") {
+		t.Fatalf("три сообщения: %d inputs", len(inputs))
+	}
+
+	// Помещаются два, но история не начинается с ответа ассистента
+	resp.PromptTokens = limit - 2*size - current - 10
+	inputs = restartConversationInputs(resp, "вопрос")
+	if len(inputs) != 2 || inputs[0]["role"] != "user" || !strings.HasPrefix(inputs[0]["content"].(string), "4") {
+		t.Fatalf("два сообщения: %d inputs", len(inputs))
+	}
+
+	if err := resp.checkContext("вопрос"); err != nil {
+		t.Fatalf("вопрос помещается: %v", err)
+	}
+	resp.PromptTokens = limit
+	var tooLong *create.ContextTooLongError
+	if err := resp.checkContext("вопрос"); !errors.As(err, &tooLong) {
+		t.Fatalf("ожидалась ContextTooLongError, получено %v", err)
+	}
+	if err := (&RespModel{ModelName: "unknown", PromptTokens: 1 << 30}).checkContext("вопрос"); err != nil {
+		t.Fatalf("неизвестная модель: %v", err)
+	}
+}
+
+func TestStreamDeltasHeldBackUntilModeration(t *testing.T) {
+	var sent []string
+	onDelta := func(delta string, done bool) error {
+		sent = append(sent, delta)
+		return nil
+	}
+	call := `{"type":"function_call","name":"lookup"}`
+
+	moderated := streamDeltas(onDelta, true)
+	for _, d := range []string{"Плохой ", call, "ответ"} {
+		if err := moderated(d); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(sent) != 1 || sent[0] != call {
+		t.Fatalf("moderated stream leaked text deltas: %q", sent)
+	}
+
+	sent = nil
+	plain := streamDeltas(onDelta, false)
+	_ = plain("Привет")
+	_ = plain(call)
+	if len(sent) != 2 {
+		t.Fatalf("plain stream: %q", sent)
+	}
+}
+
+func TestProcessResponseExtra(t *testing.T) {
+	m := &Model{}
+	respModel := &RespModel{ResponseFields: []create.SchemaField{{Name: "order_id", Type: "string"}}}
+	resp := m.processResponse(Response{Message: "```json\n{\"message\":\"Готово\",\"order_id\":\"A-1\",\"mood\":\"good\"}\n```"}, 1, respModel)
+	if resp.Message != "Готово" || resp.Extra["order_id"] != "A-1" || len(resp.Extra) != 1 {
+		t.Fatalf("response: %+v", resp)
+	}
+}
+
+func TestProcessResponseSchema(t *testing.T) {
+	m := &Model{}
+	// Неэкранированный перенос строки внутри message
+	resp := m.processResponse(Response{Message: "{\"message\":\"Первая\nвторая\",\"target\":true,\"operator\":true}"}, 1, &RespModel{})
+	if resp.Message != "Первая\nвторая" || !resp.Meta || !resp.Operator {
+		t.Fatalf("unescaped newline: %+v", resp)
+	}
+
+	// Файл без URL не проходит схему: текстовый режим сохраняет флаги
+	resp = m.processResponse(Response{Message: `{"message":"Держите","action":{"send_files":[{"type":"photo"}]},"operator":true}`}, 1, &RespModel{})
+	if resp.Message != "Держите" || len(resp.Action.SendFiles) != 0 || !resp.Operator {
+		t.Fatalf("fallback: %+v", resp)
+	}
+
+	if resp = m.processResponse(Response{Message: "Просто текст"}, 1, &RespModel{}); resp.Message != "Просто текст" {
+		t.Fatalf("plain text: %+v", resp)
+	}
+}
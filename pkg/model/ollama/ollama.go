@@ -0,0 +1,57 @@
+// Package ollama подключает локальные модели Ollama для установок без внешней сети.
+// Диалоги обслуживает chatapi.Model поверх собственного API Ollama (/api/chat):
+// история диалога и вызовы функций MCP приводятся к формату Chat Completions,
+// структурированный ответ — через параметр format. API-ключ не нужен; адрес сервера
+// задаётся OLLAMA_URL. Веб-поиска, файлов и vector store у провайдера нет.
+//
+//	router := model.NewModelRouter(ctx, db, ollama.NewAsRouterOption())
+package ollama
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ikermy/AiR_Common/pkg/mode"
+	"github.com/ikermy/AiR_Common/pkg/model"
+	"github.com/ikermy/AiR_Common/pkg/model/chatapi"
+	"github.com/ikermy/AiR_Common/pkg/model/create"
+)
+
+// Model диалоговая модель Ollama с эмбеддингами локального сервера
+type Model struct {
+	*chatapi.Model
+	ctx    context.Context
+	client *create.OllamaClient
+}
+
+// New создаёт модель Ollama для сервера mode.OllamaBaseURL
+func New(ctx context.Context, d chatapi.DB, actionHandler model.ActionHandler) *Model {
+	client := create.NewOllamaClient(mode.OllamaBaseURL)
+	return &Model{
+		Model:  chatapi.New(ctx, d, create.ProviderOllama, client, actionHandler),
+		ctx:    ctx,
+		client: client,
+	}
+}
+
+// GenerateEmbedding эмбеддинг текста моделью mode.OllamaEmbedModel
+func (m *Model) GenerateEmbedding(text string) ([]float32, error) {
+	embeddings, err := m.client.Embed(m.ctx, "", []string{text})
+	if err != nil {
+		return nil, err
+	}
+	return embeddings[0], nil
+}
+
+// NewAsRouterOption создаёт модель Ollama и возвращает её как опцию для ModelRouter
+func NewAsRouterOption() model.RouterOption {
+	return func(r *model.Router, ctx context.Context, db model.DB) error {
+		routerDB, ok := db.(chatapi.DB)
+		if !ok {
+			return fmt.Errorf("DB не соответствует интерфейсу chatapi.DB")
+		}
+		m := New(ctx, routerDB, model.NewUniversalActionHandler(ctx))
+		m.SetUniversalModel(create.New(ctx, routerDB))
+		return model.WithOllamaModel(m)(r, ctx, db)
+	}
+}
@@ -0,0 +1,87 @@
+package ollama
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/ikermy/AiR_Common/pkg/model/create"
+)
+
+func TestOllamaClient_ToolCallRoundTrip(t *testing.T) {
+	var round atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/chat" {
+			t.Errorf("путь %s", r.URL.Path)
+		}
+		var req map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("тело запроса: %v", err)
+		}
+		if req["stream"] != false {
+			t.Errorf("stream: %v", req["stream"])
+		}
+		if _, ok := req["format"].(map[string]any); !ok {
+			t.Errorf("format должен быть JSON Schema: %v", req["format"])
+		}
+		messages := req["messages"].([]any)
+		w.Header().Set("Content-Type", "application/json")
+		if round.Add(1) == 1 {
+			_, _ = w.Write([]byte(`{"message":{"role":"assistant","content":"","tool_calls":[
+				{"function":{"name":"free_slots","arguments":{"day":"пн"}}}]},
+				"done_reason":"stop","prompt_eval_count":10,"eval_count":5}`))
+			return
+		}
+		call := messages[len(messages)-2].(map[string]any)
+		args := call["tool_calls"].([]any)[0].(map[string]any)["function"].(map[string]any)["arguments"]
+		if a, ok := args.(map[string]any); !ok || a["day"] != "пн" {
+			t.Errorf("аргументы должны передаваться объектом: %v", args)
+		}
+		result := messages[len(messages)-1].(map[string]any)
+		if result["role"] != "tool" || result["tool_name"] != "free_slots" {
+			t.Errorf("результат функции: %v", result)
+		}
+		_, _ = w.Write([]byte(`{"message":{"role":"assistant","content":"{\"message\":\"Свободно в 10:00\"}"},
+			"done_reason":"stop","prompt_eval_count":20,"eval_count":7}`))
+	}))
+	defer srv.Close()
+
+	client := create.NewOllamaClient(srv.URL)
+	req := create.ChatRequest{
+		Model: "llama3.1:8b",
+		Messages: []create.ChatMessage{
+			{Role: "system", Content: "Ты администратор салона"},
+			{Role: "user", Content: []any{map[string]any{"type": "text", "text": "Есть запись на понедельник?"}}},
+		},
+		Tools: []create.ChatTool{{Type: "function", Function: create.ChatFunction{Name: "free_slots"}}},
+		ResponseFormat: map[string]any{
+			"type":        "json_schema",
+			"json_schema": map[string]any{"name": "assist_response", "schema": map[string]any{"type": "object"}},
+		},
+	}
+	var gotArgs string
+	run := func(_ context.Context, _, arguments string) string {
+		gotArgs = arguments
+		return `{"slots":["10:00"]}`
+	}
+
+	resp, added, err := create.CompleteWithTools(context.Background(), client, 1, req, run)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gotArgs != `{"day":"пн"}` {
+		t.Fatalf("аргументы функции: %q", gotArgs)
+	}
+	if resp.Text() != `{"message":"Свободно в 10:00"}` {
+		t.Fatalf("ответ: %q", resp.Text())
+	}
+	if resp.Usage.TotalTokens != 42 {
+		t.Fatalf("токены: %+v", resp.Usage)
+	}
+	if len(added) != 2 || added[1].Role != "tool" {
+		t.Fatalf("добавленные сообщения: %+v", added)
+	}
+}
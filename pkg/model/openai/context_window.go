@@ -0,0 +1,45 @@
+package openai
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/ikermy/AiR_Common/pkg/logger"
+	"github.com/ikermy/AiR_Common/pkg/model/create"
+)
+
+// fitContext отбрасывает самые старые сообщения истории, которая передаётся в instructions,
+// если промпт, инструменты, история и вопрос input не помещаются в контекст модели
+// (create.ChatInputLimit); кэш диалога не меняется. Последнее сообщение history — текущий
+// вопрос: если не помещается и он один — create.ContextTooLongError. Неизвестная модель не проверяется
+func fitContext(userID uint32, dialogID uint64, config *AgentConfig, input string, history []ChatMessage) ([]ChatMessage, error) {
+	limit := create.ChatInputLimit(config.ModelName)
+	if limit <= 0 || len(history) == 0 {
+		return history, nil
+	}
+
+	fixed := create.EstimateTokens(config.SystemPrompt, config.ModelName)
+	if len(config.Tools) > 0 {
+		data, _ := json.Marshal(config.Tools)
+		fixed += create.EstimateTokens(string(data), config.ModelName)
+	}
+	last := len(history) - 1
+	sizes := make([]int, len(history))
+	for i, msg := range history[:last] {
+		// Строка истории в instructions: "N. Роль: текст"
+		sizes[i] = create.EstimateTokens(fmt.Sprintf("%d. Пользователь: %v\n", i+1, msg.Content), config.ModelName)
+	}
+	sizes[last] = create.EstimateTokens(input, config.ModelName)
+
+	drop, err := create.FitHistory(config.ModelName, limit, fixed, sizes)
+	if err != nil || drop == 0 {
+		return history, err
+	}
+	// История в instructions должна начинаться с вопроса пользователя
+	for drop < last && history[drop].Role != "user" {
+		drop++
+	}
+	logger.ForDialog(userID, dialogID).Warn("история не помещается в контекст модели, старые сообщения не отправлены",
+		"model", config.ModelName, "limit", limit, "dropped", drop)
+	return history[drop:], nil
+}
@@ -14,10 +14,12 @@ import (
 
 	"github.com/ikermy/AiR_Common/pkg/com"
 	"github.com/ikermy/AiR_Common/pkg/comdb"
+	"github.com/ikermy/AiR_Common/pkg/experiment"
 	"github.com/ikermy/AiR_Common/pkg/mode"
 	"github.com/ikermy/AiR_Common/pkg/model"
 	"github.com/ikermy/AiR_Common/pkg/model/create"
 	"github.com/ikermy/AiR_Common/pkg/model/provider_catalog"
+	"github.com/ikermy/AiR_Common/pkg/safego"
 )
 
 type DB = comdb.Exterior
@@ -35,6 +37,9 @@ type Model struct {
 	UserModelTTl     time.Duration
 	actionHandler    model.ActionHandler
 	universalModel   *create.UniversalModel
+	injection        *model.InjectionDetector // nil — фрагменты RAG не проверяются
+	seeds            *model.Seeds             // nil — детерминированный режим выключен
+	experiments      *experiment.Registry     // nil — без A/B-экспериментов
 	shutdownOnce     sync.Once
 }
 
@@ -66,13 +71,16 @@ func (r *RespModel) GetChannelMap() map[uint64]*model.Ch {
 // AgentConfig хранит конфигурацию агента для OpenAI модели
 // В отличие от Assistants API, конфигурация хранится в БД и передается с каждым запросом
 type AgentConfig struct {
-	ModelId        uint64         `json:"model_id"`   // ID модели в БД
-	ModelName      string         `json:"model_name"` // Имя модели из user_gpt.AssistantId (gpt-5-mini и т.д.)
-	SystemPrompt   string         `json:"system_prompt"`
-	Tools          []any          `json:"tools"`
-	ResponseFormat map[string]any `json:"response_format"`
-	VectorStoreIds []string       `json:"vector_store_ids,omitempty"`
-	FileIds        []any          `json:"file_ids,omitempty"`
+	ModelId        uint64               `json:"model_id"`   // ID модели в БД
+	ModelName      string               `json:"model_name"` // Имя модели из user_gpt.AssistantId (gpt-5-mini и т.д.)
+	SystemPrompt   string               `json:"system_prompt"`
+	PromptHint     string               `json:"prompt_hint,omitempty"`    // подсказка MCP, добавленная к промпту модели
+	PromptVersion  uint32               `json:"prompt_version,omitempty"` // версия SystemPrompt в create.PromptVersionStore
+	Tools          []any                `json:"tools"`
+	ResponseFormat map[string]any       `json:"response_format"`
+	ResponseFields []create.SchemaField `json:"response_fields,omitempty"` // поля ассистента → AssistResponse.Extra
+	VectorStoreIds []string             `json:"vector_store_ids,omitempty"`
+	FileIds        []any                `json:"file_ids,omitempty"`
 
 	// Дополнительные возможности
 	Search      bool   `json:"search"`      // Поиск по векторному хранилищу
@@ -83,6 +91,9 @@ type AgentConfig struct {
 	WebSearch   bool   `json:"web_search"`  // Веб-поиск
 	Image       bool   `json:"image"`       // Генерация изображений
 
+	Reasoning  create.ReasoningEffort   `json:"reasoning,omitempty"`  // Глубина рассуждений (reasoning.effort)
+	Generation *create.GenerationParams `json:"generation,omitempty"` // temperature, top_p, max_output_tokens
+
 	// Голосовой режим реального времени (OpenAI Realtime API)
 	RealtimeEnabled bool                `json:"realtime_enabled"`       // Голосовой режим включён для этой модели
 	RealtimeModel   string              `json:"realtime_model"`         // Имя realtime-модели
@@ -150,7 +161,7 @@ func New(parent context.Context, d DB, actionHandler model.ActionHandler) *Model
 	}
 
 	// Запускаем periodicFlush в фоновой горутине для очистки истекших диалогов из кэша
-	go m.periodicFlush()
+	safego.Go("openai.periodicFlush", m.periodicFlush, safego.WithRestart(-1, time.Second), safego.WithDone(ctx.Done()))
 
 	return m
 }
@@ -186,6 +197,35 @@ func (m *Model) SetUniversalModel(um *create.UniversalModel) {
 	m.universalModel = um
 }
 
+// schemaRepair исправление ответа под схему той же моделью; nil — без исправления
+func (m *Model) schemaRepair(modelName string) model.SchemaRepairFunc {
+	if m.universalModel == nil || modelName == "" {
+		return nil
+	}
+	client, err := m.universalModel.Provider(create.ProviderOpenAI)
+	if err != nil {
+		return nil
+	}
+	return model.LLMSchemaRepair(m.ctx, client, modelName)
+}
+
+// SetInjectionDetector включает очистку фрагментов RAG от prompt injection
+func (m *Model) SetInjectionDetector(d *model.InjectionDetector) {
+	m.injection = d
+}
+
+// SetExperiments включает A/B-эксперименты: вариант диалога переопределяет модель,
+// температуру и промпт запроса
+func (m *Model) SetExperiments(r *experiment.Registry) {
+	m.experiments = r
+}
+
+// SetSeeds включает детерминированный режим. Seed в Responses API не передаётся,
+// ответ диалога запрашивается с нулевой температурой (кроме reasoning-моделей)
+func (m *Model) SetSeeds(s *model.Seeds) {
+	m.seeds = s
+}
+
 // Реализация интерфейса model.Inter
 func (m *Model) NewMessage(operator model.Operator, msgType string, content *model.AssistResponse, name *string, files ...model.FileUpload) model.Message {
 	var nameStr string
@@ -241,6 +281,17 @@ func (m *Model) GetOrSetRespGPT(assist model.Assistant, dialogID, respId uint64,
 		respModel := val.(*RespModel)
 		respModel.TTL = time.Now().Add(m.UserModelTTl) // Обновляем TTL
 
+		// Диалог передан другому ассистенту (SwitchAssistant) — конфигурация агента перечитывается
+		if assist.AssistId != "" && respModel.Assist.AssistId != assist.AssistId {
+			agentConfig, haunter, err := m.loadAgentConfig(assist.UserID, &RespModel{Assist: assist})
+			if err != nil {
+				return nil, fmt.Errorf("ошибка загрузки конфигурации агента: %w", err)
+			}
+			respModel.AgentConfig = agentConfig
+			respModel.Haunter = haunter
+			respModel.Assist = assist
+		}
+
 		// АВТОМАТИЧЕСКАЯ предзагрузка истории диалога (если кэш пустой)
 		m.preloadDialogHistoryIfNeeded(dialogID, assist.UserID)
 
@@ -306,7 +357,7 @@ func (m *Model) GetRespIdByDialogID(dialogID uint64) (uint64, error) {
 // loadAgentConfig загружает конфигурацию агента для OpenAI модели из БД
 // По образцу Google провайдера - конфигурация хранится в БД, а не в OpenAI API
 // Возвращает конфигурацию агента и haunter флаг явно
-func (m *Model) loadAgentConfig(userID uint32, _ *RespModel) (*AgentConfig, bool, error) {
+func (m *Model) loadAgentConfig(userID uint32, respModel *RespModel) (*AgentConfig, bool, error) {
 	// Получаем API-ключ напрямую через DB: это обеспечивает правильную обработку $mk$-ключей —
 	// если MasterKey недоступен, ошибка и уведомление пропагируются явно, а не теряются в HasAPIKey.
 	apiKey, err := m.db.GetUserAPIKey(userID, create.ProviderOpenAI)
@@ -323,17 +374,10 @@ func (m *Model) loadAgentConfig(userID uint32, _ *RespModel) (*AgentConfig, bool
 		return nil, false, fmt.Errorf("ошибка получения моделей пользователя: %w", err)
 	}
 
-	// Ищем активную модель OpenAI
-	var found *create.UserModelRecord
-	for i := range userModels {
-		if userModels[i].Provider == create.ProviderOpenAI {
-			found = &userModels[i]
-			break
-		}
-	}
-
+	// Модель ассистента респондента (у пользователя их может быть несколько)
+	found := create.FindAssistantModel(userModels, create.ProviderOpenAI, respModel.Assist.AssistId)
 	if found == nil {
-		return nil, false, fmt.Errorf("модель OpenAI не найдена для userID %d", userID)
+		return nil, false, fmt.Errorf("модель OpenAI %q не найдена для userID %d", respModel.Assist.AssistId, userID)
 	}
 
 	// Инициализируем базовую конфигурацию.
@@ -356,7 +400,7 @@ func (m *Model) loadAgentConfig(userID uint32, _ *RespModel) (*AgentConfig, bool
 	var haunter bool
 
 	// Загружаем полные данные модели из БД
-	compressedData, _, err := m.db.ReadUserModelByProvider(userID, create.ProviderOpenAI)
+	compressedData, _, err := create.ReadAssistantModel(m.db, userID, found)
 	if err != nil {
 		//logger.Warn("Ошибка чтения данных модели из БД: %v, используем конфигурацию по умолчанию", err, userID)
 	} else if compressedData != nil {
@@ -375,6 +419,8 @@ func (m *Model) loadAgentConfig(userID uint32, _ *RespModel) (*AgentConfig, bool
 				agentConfig.RealtimeEnabled = modelData.Realtime
 				agentConfig.Image = modelData.Image
 				agentConfig.RealtimeVAD = modelData.RealtimeVAD
+				agentConfig.Reasoning = modelData.Reasoning
+				agentConfig.Generation = modelData.Generation
 
 				haunter = modelData.Haunter
 			}
@@ -423,6 +469,7 @@ func (m *Model) buildAgentConfiguration(userID uint32, config *AgentConfig, comp
 	if mcpProvider, ok := m.actionHandler.(model.MCPConfigProvider); ok {
 		if hint, err := mcpProvider.FetchSystemPrompt(m.ctx, userID, create.ProviderOpenAI); err == nil {
 			config.SystemPrompt = modelData.Prompt + "\n\n" + hint
+			config.PromptHint = hint
 			mcpAvailable = true
 		}
 		// При ошибке — MCP недоступен, используем plain prompt
@@ -431,6 +478,7 @@ func (m *Model) buildAgentConfiguration(userID uint32, config *AgentConfig, comp
 	if !mcpAvailable {
 		config.SystemPrompt = modelData.Prompt
 	}
+	config.PromptVersion = create.SavePromptVersion(m.db, userID, config.ModelId, modelData.Prompt, config.SystemPrompt)
 
 	// =========================================================================
 	// TOOLS — нативные OpenAI инструменты (всегда локально).
@@ -477,18 +525,8 @@ func (m *Model) buildAgentConfiguration(userID uint32, config *AgentConfig, comp
 	config.Tools = tools
 
 	// Формируем response format с динамической схемой
-	hasMetaAction := config.MetaAction != ""
-	hasOperator := config.Operator
-	dynamicSchema := create.GenerateModelSchema(hasMetaAction, hasOperator)
-
-	config.ResponseFormat = map[string]any{
-		"type": "json_schema",
-		"json_schema": map[string]any{
-			"name":   "assist_response",
-			"strict": true,
-			"schema": dynamicSchema,
-		},
-	}
+	config.ResponseFormat = modelData.ResponseSchema().OpenAIResponseFormat()
+	config.ResponseFields = modelData.ResponseFields
 
 	config.RealtimeModel = create.RealtimeOpenAIModel
 
@@ -579,6 +617,42 @@ func (m *Model) addMessageToCache(dialogID uint64, message ChatMessage) {
 	}
 }
 
+// InjectContext добавляет системное сообщение в кэш истории диалога.
+// Без кэша ничего не делает: история будет загружена из БД целиком.
+func (m *Model) InjectContext(dialogID uint64, text string) bool {
+	if _, found := m.getDialogHistoryFromCache(dialogID); !found {
+		return false
+	}
+	m.addMessageToCache(dialogID, ChatMessage{Role: "system", Content: text})
+	return true
+}
+
+// LastAnswer текст последнего ответа ассистента в кэше истории диалога (model.TurnRecorder)
+func (m *Model) LastAnswer(dialogID uint64) (string, bool) {
+	history, found := m.getDialogHistoryFromCache(dialogID)
+	if !found {
+		return "", false
+	}
+	for i := len(history) - 1; i >= 0; i-- {
+		if history[i].Role == "assistant" {
+			text, _ := history[i].Content.(string)
+			return text, true
+		}
+	}
+	return "", true
+}
+
+// RecordTurn добавляет в кэш истории ход, ответ на который взят из семантического кэша.
+// Без кэша ничего не делает
+func (m *Model) RecordTurn(dialogID uint64, question string, answer model.AssistResponse) bool {
+	if _, found := m.getDialogHistoryFromCache(dialogID); !found {
+		return false
+	}
+	m.addMessageToCache(dialogID, ChatMessage{Role: "user", Content: question})
+	m.addMessageToCache(dialogID, ChatMessage{Role: "assistant", Content: answer.Message})
+	return true
+}
+
 // preloadDialogHistoryIfNeeded автоматически загружает историю диалога если кэш пустой
 // Вызывается неявно в GetOrSetRespGPT для обеспечения контекста с первого сообщения
 func (m *Model) preloadDialogHistoryIfNeeded(dialogID uint64, _ uint32) {
@@ -589,7 +663,7 @@ func (m *Model) preloadDialogHistoryIfNeeded(dialogID uint64, _ uint32) {
 	}
 
 	// Загружаем историю из БД в фоновой горутине для неблокирующей работы
-	go func() {
+	safego.Go("openai.loadDialogHistory", func() {
 		history, err := m.ConvertDialogToOpenAIFormat(dialogID)
 		if err != nil {
 			// Пустая история - это нормально для нового диалога
@@ -612,7 +686,7 @@ func (m *Model) preloadDialogHistoryIfNeeded(dialogID uint64, _ uint32) {
 		//} else {
 		//	logger.Debug("Диалог %d начат с пустой историей (новый диалог)", dialogID)
 		//}
-	}()
+	})
 }
 
 // ============================================================================
@@ -0,0 +1,16 @@
+package openai
+
+import (
+	"context"
+
+	"github.com/ikermy/AiR_Common/pkg/model"
+)
+
+// Moderate реализует model.Moderator через OpenAI moderations API
+func (m *Model) Moderate(ctx context.Context, userID uint32, text string) (model.ModerationResult, error) {
+	categories, err := m.client.Moderate(ctx, userID, text)
+	if err != nil {
+		return model.ModerationResult{}, err
+	}
+	return model.ModerationResult{Flagged: len(categories) > 0, Categories: categories}, nil
+}
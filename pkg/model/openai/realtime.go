@@ -10,6 +10,7 @@ import (
 	"github.com/gorilla/websocket"
 	"github.com/ikermy/AiR_Common/pkg/model"
 	"github.com/ikermy/AiR_Common/pkg/model/create"
+	"github.com/ikermy/AiR_Common/pkg/safego"
 )
 
 // RealtimeEvent — алиас типа из пакета model для удобства внутри пакета openai
@@ -107,13 +108,13 @@ func (m *Model) SubscribeEvents(respId uint64) (<-chan model.RealtimeEvent, erro
 	select {
 	case <-rs.ctx.Done():
 		//logger.Debug("[OpenAI SubscribeEvents] ctx ALREADY DONE respId=%d rs.ctx.Err=%v m.ctx.Err=%v", respId, rs.ctx.Err(), m.ctx.Err())
-		go func() {
+		safego.Go("openai.realtime.subscribeGuard", func() {
 			ch <- model.RealtimeEvent{
 				Type: "error",
 				Text: "openai session terminated before subscription",
 				Err:  fmt.Errorf("session terminated before subscription"),
 			}
-		}()
+		})
 	default:
 	}
 
@@ -236,13 +237,14 @@ func (m *Model) StartRealtimeSession(userID uint32, dialogID, respId uint64) err
 	//	respId, rm.AgentConfig.RealtimeModel, userID)
 
 	// Горутина-сторож: закрывает WS при отмене контекста → разблокирует ReadMessage()
-	go func() {
+	safego.Go("openai.realtime.watchdog", func() {
 		<-rs.ctx.Done()
 		_ = rs.openaiConn.Close()
-	}()
+	})
 
-	go m.pumpFromOpenAI(rs)
-	go m.pumpToOpenAI(rs)
+	// При панике в pump сессия закрывается, чтобы не оставлять её наполовину рабочей
+	safego.Go("openai.pumpFromOpenAI", func() { m.pumpFromOpenAI(rs) }, safego.WithUserID(userID), safego.WithOnPanic(func(any) { rs.cancel() }))
+	safego.Go("openai.pumpToOpenAI", func() { m.pumpToOpenAI(rs) }, safego.WithUserID(userID), safego.WithOnPanic(func(any) { rs.cancel() }))
 
 	return nil
 }
@@ -1,13 +1,16 @@
 package openai
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"strings"
 	"time"
 
+	"github.com/ikermy/AiR_Common/pkg/experiment"
 	"github.com/ikermy/AiR_Common/pkg/model"
 	"github.com/ikermy/AiR_Common/pkg/model/create"
+	"github.com/ikermy/AiR_Common/pkg/safego"
 )
 
 // applyRAG выполняет все тяжёлые подготовительные операции параллельно в фоновой горутине:
@@ -40,7 +43,7 @@ func (m *Model) applyRAG(userID uint32, dialogID uint64, text string, ch chan<-
 	responderCh := make(chan responderResult, 1)
 
 	// Горутина загрузки истории
-	go func() {
+	safego.Go("openai.applyRAG.history", func() {
 		start := time.Now()
 		var history []ChatMessage
 
@@ -69,10 +72,12 @@ func (m *Model) applyRAG(userID uint32, dialogID uint64, text string, ch chan<-
 		}
 
 		historyCh <- historyResult{history: history, dur: time.Since(start)}
-	}()
+	}, safego.WithUserID(userID), safego.WithOnPanic(func(any) {
+		historyCh <- historyResult{history: []ChatMessage{}}
+	}))
 
 	// Горутина поиска respModel
-	go func() {
+	safego.Go("openai.applyRAG.responder", func() {
 		start := time.Now()
 		var found *RespModel
 
@@ -86,7 +91,9 @@ func (m *Model) applyRAG(userID uint32, dialogID uint64, text string, ch chan<-
 		})
 
 		responderCh <- responderResult{resp: found, dur: time.Since(start)}
-	}()
+	}, safego.WithUserID(userID), safego.WithOnPanic(func(any) {
+		responderCh <- responderResult{}
+	}))
 
 	// Собираем результаты (обе горутины уже запущены параллельно)
 	hRes := <-historyCh
@@ -154,12 +161,14 @@ func (m *Model) applyRAG(userID uint32, dialogID uint64, text string, ch chan<-
 	}
 
 	// === 8. Формируем обогащённый контекст ===
-	if len(relevantDocs) > 0 {
-		var relevantChunks []string
-		for _, doc := range relevantDocs {
-			relevantChunks = append(relevantChunks, doc.Content)
-		}
+	// Фрагменты базы знаний — недоверенный текст: строки с prompt injection удаляются
+	var relevantChunks []string
+	for _, doc := range relevantDocs {
+		relevantChunks = append(relevantChunks, doc.Content)
+	}
+	relevantChunks = m.injection.SanitizeChunks(userID, relevantChunks)
 
+	if len(relevantChunks) > 0 {
 		contextText := strings.Join(relevantChunks, "\n\n---\n\n")
 		result.contextText = fmt.Sprintf("Релевантная информация из базы знаний:\n%s\n\n---\n\nВопрос пользователя: %s",
 			contextText, text)
@@ -188,6 +197,14 @@ func (m *Model) Request(userID uint32, dialogID uint64, text string, files ...mo
 // RequestStreaming выполняет запрос с потоковой передачей delta-событий
 // Использует Responses API (новый подход OpenAI с поддержкой file_search, code_interpreter, web_search)
 func (m *Model) RequestStreaming(userID uint32, dialogID uint64, text string, onDelta func(delta string, done bool) error, files ...model.FileUpload) error {
+	return m.RequestStreamingContext(model.TraceContext(m.ctx, dialogID), userID, dialogID, text, onDelta, files...)
+}
+
+// RequestStreamingContext RequestStreaming, прерываемый отменой ctx
+func (m *Model) RequestStreamingContext(ctx context.Context, userID uint32, dialogID uint64, text string, onDelta func(delta string, done bool) error, files ...model.FileUpload) error {
+	ctx, cancel := model.RequestContext(m.ctx, model.TraceContext(ctx, dialogID))
+	defer cancel()
+
 	if text == "" && len(files) == 0 {
 		return fmt.Errorf("пустое сообщение и нет файлов")
 	}
@@ -197,13 +214,19 @@ func (m *Model) RequestStreaming(userID uint32, dialogID uint64, text string, on
 	// всех тяжёлых операций (загрузка истории, поиск respModel, эмбеддинги, поиск в БД)
 	// ============================================================================
 	ragCh := make(chan openaiRagResp, 1)
-	go m.applyRAG(userID, dialogID, text, ragCh)
+	safego.Go("openai.applyRAG", func() { m.applyRAG(userID, dialogID, text, ragCh) },
+		safego.WithUserID(userID), safego.WithOnPanic(func(rec any) {
+			select {
+			case ragCh <- openaiRagResp{err: fmt.Errorf("паника в applyRAG: %v", rec)}:
+			default:
+			}
+		}))
 
 	// Ждём результат из горутины — содержит history, respModel, contextText и метрики
 	var ragResult openaiRagResp
 	select {
-	case <-m.ctx.Done():
-		return fmt.Errorf("контекст отменён")
+	case <-ctx.Done():
+		return fmt.Errorf("контекст отменён: %w", ctx.Err())
 	case ragResult = <-ragCh:
 		if ragResult.err != nil {
 			return fmt.Errorf("ошибка в applyRAG: %w", ragResult.err)
@@ -233,6 +256,12 @@ func (m *Model) RequestStreaming(userID uint32, dialogID uint64, text string, on
 	history = append(history, userMessage)
 	m.addMessageToCache(dialogID, userMessage)
 
+	// Промпт, инструменты, история и вопрос с RAG-контекстом должны поместиться в контекст модели
+	history, err := fitContext(userID, dialogID, respModel.AgentConfig, enhancedText, history)
+	if err != nil {
+		return err
+	}
+
 	// Формируем input для Responses API
 	// Responses API принимает одно input сообщение, история добавляется в instructions
 	var conversationContext strings.Builder
@@ -323,7 +352,7 @@ func (m *Model) RequestStreaming(userID uint32, dialogID uint64, text string, on
 			var result string
 			if m.actionHandler != nil {
 				//logger.Debug("[onToolCall] Вызываю action handler для функции '%s'...", functionName, userID)
-				result = m.actionHandler.RunAction(m.ctx, functionName, arguments, create.ProviderOpenAI, userID)
+				result = m.actionHandler.RunAction(model.WithDialogID(ctx, dialogID), functionName, arguments, create.ProviderOpenAI, userID)
 				//logger.Debug("✅ [onToolCall] Получен результат от action handler для '%s': %s",
 				//	functionName, result, userID)
 			} else {
@@ -345,11 +374,26 @@ func (m *Model) RequestStreaming(userID uint32, dialogID uint64, text string, on
 		return toolOutputs, nil
 	}
 
+	// Вариант эксперимента и детерминированный режим: копия конфигурации, общая конфигурация ассистента не меняется
+	agentConfig := respModel.AgentConfig
+	promptVersion := agentConfig.PromptVersion
+	if a, ok := m.experiments.Assign(userID, dialogID); ok {
+		agentConfig = applyVariant(agentConfig, a.Variant, conversationContext.String())
+		if a.Prompt != "" {
+			promptVersion = 0 // промпт варианта не версионируется
+		}
+	}
+	if seed, ok := m.seeds.Get(dialogID); ok {
+		deterministic := *agentConfig
+		deterministic.Generation = agentConfig.Generation.Deterministic(seed)
+		agentConfig = &deterministic
+	}
+
 	// Вызываем Responses API с обработчиком функций
 	_, fullText, err := m.client.CreateResponse(
-		m.ctx,
+		ctx,
 		input,
-		respModel.AgentConfig,
+		agentConfig,
 		wrappedOnDelta,
 		onToolCall,
 		userID,
@@ -365,22 +409,9 @@ func (m *Model) RequestStreaming(userID uint32, dialogID uint64, text string, on
 	// Логируем полученный текст для отладки
 	//logger.Debug("CreateResponse вернул fullText (длина=%d): '%s'", len(fullText), fullText, userID)
 
-	// Responses API с response_format возвращает JSON как текст
-	// Парсим JSON чтобы извлечь реальную структуру AssistResponse
-	var assistResponse model.AssistResponse
-	if err := unmarshalAssistResponse(fullText, &assistResponse); err != nil {
-		// Если парсинг не удался - возможно модель вернула просто текст
-		//logger.Warn("Не удалось распарсить JSON ответ (длина=%d, ошибка=%v), fullText='%s'",
-		//	len(fullText), err, fullText, userID)
-		assistResponse = model.AssistResponse{
-			Message: fullText,
-			Action: model.Action{
-				SendFiles: []model.File{},
-			},
-			Meta:     false,
-			Operator: false,
-		}
-	}
+	// Responses API с response_format возвращает JSON как текст.
+	// Ответ проверяется по схеме; невалидный JSON исправляется моделью, иначе — текстовый режим
+	assistResponse := model.ParseAssistResponse(userID, fullText, m.schemaRepair(respModel.AgentConfig.ModelName), respModel.AgentConfig.ResponseFields...)
 
 	// Если Message пустое, но есть fullText - используем fullText
 	if assistResponse.Message == "" && fullText != "" {
@@ -400,6 +431,9 @@ func (m *Model) RequestStreaming(userID uint32, dialogID uint64, text string, on
 	}
 	m.addMessageToCache(dialogID, assistantMessage)
 
+	// Отмечаем версию промпта, которой обслужен ход (для разбора регрессий)
+	create.RecordPromptTurn(m.db, userID, dialogID, agentConfig.ModelId, promptVersion)
+
 	// Вызываем callback с done=true и полным JSON
 	if onDelta != nil {
 		if err := onDelta(string(responseJSON), true); err != nil {
@@ -410,33 +444,6 @@ func (m *Model) RequestStreaming(userID uint32, dialogID uint64, text string, on
 	return nil
 }
 
-// unmarshalAssistResponse принимает JSON-объект, JSON, обёрнутый в markdown,
-// и JSON-строку, содержащую объект. Последний вариант встречается, когда
-// Responses API возвращает структурированный ответ как текстовое значение.
-func unmarshalAssistResponse(text string, response *model.AssistResponse) error {
-	cleaned := strings.TrimSpace(text)
-	if strings.HasPrefix(cleaned, "```json") {
-		cleaned = strings.TrimSpace(strings.TrimPrefix(cleaned, "```json"))
-	} else if strings.HasPrefix(cleaned, "```JSON") {
-		cleaned = strings.TrimSpace(strings.TrimPrefix(cleaned, "```JSON"))
-	} else if strings.HasPrefix(cleaned, "```") {
-		cleaned = strings.TrimSpace(strings.TrimPrefix(cleaned, "```"))
-	}
-	if strings.HasSuffix(cleaned, "```") {
-		cleaned = strings.TrimSpace(strings.TrimSuffix(cleaned, "```"))
-	}
-
-	if err := json.Unmarshal([]byte(cleaned), response); err == nil {
-		return nil
-	}
-
-	var encoded string
-	if err := json.Unmarshal([]byte(cleaned), &encoded); err != nil {
-		return err
-	}
-	return json.Unmarshal([]byte(encoded), response)
-}
-
 // createUserMessageWithFiles создает сообщение пользователя с поддержкой файлов
 // Для Chat Completions API: изображения через image_url, документы через file_search в tools
 func (m *Model) createUserMessageWithFiles(text string, files []model.FileUpload, _ uint32) ChatMessage {
@@ -549,3 +556,23 @@ func (m *Model) ConvertDialogToOpenAIFormat(dialogID uint64) ([]ChatMessage, err
 
 	return messages, nil
 }
+
+// applyVariant копия cfg с моделью, температурой и промптом варианта v. Промпт варианта
+// заменяет промпт модели; подсказка MCP и история диалога (history) сохраняются
+func applyVariant(cfg *AgentConfig, v experiment.Variant, history string) *AgentConfig {
+	out := *cfg
+	if v.ModelName != "" {
+		out.ModelName = v.ModelName
+	}
+	if v.Temperature != nil {
+		out.Generation = cfg.Generation.WithTemperature(*v.Temperature)
+	}
+	if v.Prompt != "" {
+		out.SystemPrompt = v.Prompt
+		if cfg.PromptHint != "" {
+			out.SystemPrompt += "\n\n" + cfg.PromptHint
+		}
+		out.SystemPrompt += history
+	}
+	return &out
+}
@@ -0,0 +1,40 @@
+// Package openrouter подключает OpenRouter — один API-ключ даёт доступ к моделям многих
+// провайдеров ("anthropic/claude-sonnet-4", "meta-llama/llama-3.3-70b-instruct", ...).
+// Диалоги обслуживает chatapi.Model: структурированный ответ через response_format,
+// вызовы функций MCP — через model.ActionHandler.
+//
+//	router := model.NewModelRouter(ctx, db, openrouter.NewAsRouterOption())
+package openrouter
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ikermy/AiR_Common/pkg/model"
+	"github.com/ikermy/AiR_Common/pkg/model/chatapi"
+	"github.com/ikermy/AiR_Common/pkg/model/create"
+)
+
+// New создаёт модель OpenRouter; API-ключ пользователя читается из БД
+func New(ctx context.Context, d chatapi.DB, actionHandler model.ActionHandler) *chatapi.Model {
+	client := create.NewOpenRouterClient(func(userID uint32) string {
+		if key, err := d.GetUserAPIKey(userID, create.ProviderOpenRouter); err == nil {
+			return key
+		}
+		return ""
+	})
+	return chatapi.New(ctx, d, create.ProviderOpenRouter, client, actionHandler)
+}
+
+// NewAsRouterOption создаёт модель OpenRouter и возвращает её как опцию для ModelRouter
+func NewAsRouterOption() model.RouterOption {
+	return func(r *model.Router, ctx context.Context, db model.DB) error {
+		routerDB, ok := db.(chatapi.DB)
+		if !ok {
+			return fmt.Errorf("DB не соответствует интерфейсу chatapi.DB")
+		}
+		m := New(ctx, routerDB, model.NewUniversalActionHandler(ctx))
+		m.SetUniversalModel(create.New(ctx, routerDB))
+		return model.WithOpenRouterModel(m)(r, ctx, db)
+	}
+}
@@ -0,0 +1,96 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/ikermy/AiR_Common/pkg/model/create"
+)
+
+// dialogsProvider — провайдер, у которого есть респонденты диалогов dialogs
+type dialogsProvider struct {
+	Inter
+	dialogs map[uint64]bool
+}
+
+func (p *dialogsProvider) GetRespIdByDialogID(dialogID uint64) (uint64, error) {
+	if p.dialogs[dialogID] {
+		return 1, nil
+	}
+	return 0, fmt.Errorf("нет диалога %d", dialogID)
+}
+
+func (p *dialogsProvider) CleanDialogData(uint64) {}
+
+func TestRouter_PinDialog(t *testing.T) {
+	openai := &dialogsProvider{dialogs: map[uint64]bool{5: true}}
+	google := &dialogsProvider{dialogs: map[uint64]bool{5: true}}
+	r := &Router{models: map[create.ProviderType]Inter{create.ProviderOpenAI: openai, create.ProviderGoogle: google}}
+
+	if _, kind, _ := r.dialogProvider(5); kind != create.ProviderOpenAI {
+		t.Fatalf("without pin the first provider serves the dialog, got %v", kind)
+	}
+	if err := r.PinDialog(5, create.ProviderMistral); err == nil {
+		t.Fatalf("pin to missing provider must fail")
+	}
+	if err := r.PinDialog(5, create.ProviderGoogle); err != nil {
+		t.Fatal(err)
+	}
+	if _, kind, _ := r.dialogProvider(5); kind != create.ProviderGoogle {
+		t.Fatalf("pinned dialog must go to google, got %v", kind)
+	}
+
+	r.CleanDialogData(5)
+	if _, kind, _ := r.dialogProvider(5); kind != create.ProviderOpenAI {
+		t.Fatalf("pin must be cleared with dialog data, got %v", kind)
+	}
+}
+
+// embedderProvider — провайдер с векторным хранилищем
+type embedderProvider struct {
+	dialogsProvider
+	Embedder
+	docs []create.VectorDocument
+}
+
+func (p *embedderProvider) ListUserDocuments(uint32) ([]create.VectorDocument, error) {
+	return p.docs, nil
+}
+
+func TestRouter_ProvidersByRegistry(t *testing.T) {
+	r := &Router{}
+	for _, opt := range []RouterOption{
+		WithGrokModel(&dialogsProvider{}),
+		WithProvider(create.ProviderMistral, &embedderProvider{docs: []create.VectorDocument{{ID: "m"}}}),
+		WithOpenAIModel(&embedderProvider{docs: []create.VectorDocument{{ID: "o"}}}),
+	} {
+		if err := opt(r, nil, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := WithProvider(create.ProviderGoogle, nil)(r, nil, nil); err == nil {
+		t.Fatal("nil model accepted")
+	}
+
+	if got := strings.Join(r.GetAvailableProviders(), ","); got != "OpenAI,Mistral,Grok" {
+		t.Fatalf("providers: %s", got)
+	}
+	if !r.HasGrok() || r.HasGoogle() {
+		t.Fatal("Has* must follow registered models")
+	}
+	if _, err := r.getModel(create.ProviderGoogle); err == nil || !strings.Contains(err.Error(), "Google") {
+		t.Fatalf("missing provider: %v", err)
+	}
+
+	docs, _ := r.ListUserDocuments(1, "")
+	if len(docs) != 2 || docs[0].ID != "o" || docs[1].ID != "m" {
+		t.Fatalf("documents of all providers: %+v", docs)
+	}
+	if _, err := r.ListUserDocuments(1, "grok"); err == nil {
+		t.Fatal("provider without Embedder must fail")
+	}
+	if _, err := r.UploadFileToProvider(1, create.ProviderOpenAI, "a.txt", nil); err == nil {
+		t.Fatal("provider without library must fail")
+	}
+}
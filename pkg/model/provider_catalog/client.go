@@ -9,6 +9,7 @@ import (
 	"net/url"
 	"strings"
 
+	"github.com/ikermy/AiR_Common/pkg/mode"
 	"github.com/ikermy/AiR_Common/pkg/model/create"
 )
 
@@ -58,7 +59,7 @@ func (c *Client) FetchModelNames(ctx context.Context, provider create.ProviderTy
 	if !provider.IsValid() {
 		return nil, fmt.Errorf("некорректный provider: %d", provider)
 	}
-	if strings.TrimSpace(apiKey) == "" {
+	if provider.RequiresAPIKey() && strings.TrimSpace(apiKey) == "" {
 		return nil, fmt.Errorf("пустой API-ключ для провайдера %s", provider.String())
 	}
 
@@ -69,6 +70,17 @@ func (c *Client) FetchModelNames(ctx context.Context, provider create.ProviderTy
 		return client.fetchMistralModels(ctx, apiKey)
 	case create.ProviderGoogle:
 		return client.fetchGoogleModels(ctx, apiKey)
+	case create.ProviderOpenRouter:
+		return client.fetchOpenRouterModels(ctx, apiKey)
+	case create.ProviderOllama:
+		return client.fetchOllamaModels(ctx)
+	case create.ProviderYandex:
+		// У Foundation Models API нет метода списка моделей
+		return append([]string(nil), create.YandexGPTModels...), nil
+	case create.ProviderGigaChat:
+		return client.fetchGigaChatModels(ctx, apiKey)
+	case create.ProviderGrok:
+		return client.fetchGrokModels(ctx, apiKey)
 	default:
 		return nil, fmt.Errorf("неподдерживаемый провайдер: %s", provider.String())
 	}
@@ -144,6 +156,83 @@ func (c *Client) fetchGoogleModels(ctx context.Context, apiKey string) ([]string
 	})
 }
 
+func (c *Client) fetchOpenRouterModels(ctx context.Context, apiKey string) ([]string, error) {
+	return c.fetchListModels(ctx, "https://openrouter.ai/api/v1/models", apiKey, func(body []byte) ([]string, error) {
+		var payload struct {
+			Data []struct {
+				ID string `json:"id"`
+			} `json:"data"`
+		}
+		if err := json.Unmarshal(body, &payload); err != nil {
+			return nil, fmt.Errorf("ошибка разбора ответа OpenRouter: %w", err)
+		}
+		result := make([]string, 0, len(payload.Data))
+		for _, item := range payload.Data {
+			if name := strings.TrimSpace(item.ID); name != "" {
+				result = append(result, name)
+			}
+		}
+		return result, nil
+	})
+}
+
+func (c *Client) fetchGrokModels(ctx context.Context, apiKey string) ([]string, error) {
+	return c.fetchListModels(ctx, "https://api.x.ai/v1/models", apiKey, func(body []byte) ([]string, error) {
+		var payload struct {
+			Data []struct {
+				ID string `json:"id"`
+			} `json:"data"`
+		}
+		if err := json.Unmarshal(body, &payload); err != nil {
+			return nil, fmt.Errorf("ошибка разбора ответа Grok: %w", err)
+		}
+		result := make([]string, 0, len(payload.Data))
+		for _, item := range payload.Data {
+			if name := strings.TrimSpace(item.ID); name != "" {
+				result = append(result, name)
+			}
+		}
+		return result, nil
+	})
+}
+
+func (c *Client) fetchOllamaModels(ctx context.Context) ([]string, error) {
+	// Локальный сервер Ollama без авторизации: список установленных моделей
+	return c.fetchListModels(ctx, strings.TrimRight(mode.OllamaBaseURL, "/")+"/api/tags", "", func(body []byte) ([]string, error) {
+		var payload struct {
+			Models []struct {
+				Name string `json:"name"`
+			} `json:"models"`
+		}
+		if err := json.Unmarshal(body, &payload); err != nil {
+			return nil, fmt.Errorf("ошибка разбора ответа Ollama: %w", err)
+		}
+		result := make([]string, 0, len(payload.Models))
+		for _, item := range payload.Models {
+			if name := strings.TrimSpace(item.Name); name != "" {
+				result = append(result, name)
+			}
+		}
+		return result, nil
+	})
+}
+
+func (c *Client) fetchGigaChatModels(ctx context.Context, apiKey string) ([]string, error) {
+	// Ключ авторизации GigaChat сначала обменивается на access token — запрос выполняет create.GigaChatClient
+	gc := create.NewGigaChatClient(mode.GigaChatBaseURL, mode.GigaChatOAuthURL, func(uint32) string { return apiKey })
+	models, err := gc.ListModels(ctx, 0)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]string, 0, len(models))
+	for _, item := range models {
+		if name := strings.TrimSpace(item.ID); name != "" {
+			result = append(result, name)
+		}
+	}
+	return result, nil
+}
+
 func (c *Client) fetchListModels(ctx context.Context, url, apiKey string, parser func([]byte) ([]string, error)) ([]string, error) {
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
@@ -0,0 +1,39 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrQueueFull очередь не освободилась за отведённое время
+var ErrQueueFull = errors.New("очередь переполнена")
+
+// SendTimeout отправляет v в c: сначала без ожидания, затем с ожиданием не дольше timeout.
+// ctx может быть nil. Отправка в закрытый канал паникует, как и обычная запись в канал.
+func SendTimeout[T any](ctx context.Context, c chan<- T, v T, timeout time.Duration) error {
+	select {
+	case c <- v:
+		return nil
+	default:
+	}
+	if timeout <= 0 {
+		return ErrQueueFull
+	}
+
+	var done <-chan struct{}
+	if ctx != nil {
+		done = ctx.Done()
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case c <- v:
+		return nil
+	case <-done:
+		return ctx.Err()
+	case <-timer.C:
+		return ErrQueueFull
+	}
+}
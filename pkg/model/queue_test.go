@@ -0,0 +1,66 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSendTimeout_OverflowAfterTimeout(t *testing.T) {
+	c := make(chan int, 1)
+	if err := SendTimeout(context.Background(), c, 1, 20*time.Millisecond); err != nil {
+		t.Fatalf("first send: %v", err)
+	}
+	start := time.Now()
+	if err := SendTimeout(context.Background(), c, 2, 20*time.Millisecond); !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("expected ErrQueueFull, got %v", err)
+	}
+	if time.Since(start) < 20*time.Millisecond {
+		t.Fatalf("send must wait for timeout before overflow")
+	}
+}
+
+func TestSendTimeout_WaitsForReader(t *testing.T) {
+	c := make(chan int, 1)
+	c <- 1
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		<-c
+	}()
+	if err := SendTimeout(context.Background(), c, 2, time.Second); err != nil {
+		t.Fatalf("send must succeed once reader frees space: %v", err)
+	}
+}
+
+func TestSendTimeout_ContextCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := SendTimeout(ctx, make(chan int), 1, time.Second); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+}
+
+func TestCh_OnOverflow(t *testing.T) {
+	var dirs []ChanDir
+	ch := &Ch{
+		TxCh:        make(chan Message, 1),
+		RxCh:        make(chan Message, 1),
+		SendTimeout: 10 * time.Millisecond,
+		OnOverflow:  func(dir ChanDir, _ Message) { dirs = append(dirs, dir) },
+	}
+
+	_ = ch.SendToTx(Message{})
+	if err := ch.SendToTx(Message{}); !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("expected TxCh overflow, got %v", err)
+	}
+	_ = ch.SendToRx(Message{})
+	if err := ch.SendToRx(Message{}); !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("expected RxCh overflow, got %v", err)
+	}
+
+	if tx, rx := ch.Dropped(); tx != 1 || rx != 1 || len(dirs) != 2 || dirs[0] != DirTx || dirs[1] != DirRx {
+		t.Fatalf("unexpected overflow state: tx=%d rx=%d dirs=%v", tx, rx, dirs)
+	}
+}
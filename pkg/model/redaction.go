@@ -0,0 +1,160 @@
+package model
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"strings"
+
+	"github.com/ikermy/AiR_Common/pkg/logger"
+	"github.com/ikermy/AiR_Common/pkg/model/create"
+	"github.com/ikermy/AiR_Common/pkg/redact"
+)
+
+// redactRequest прячет персональные данные в вопросе и текстовых файлах до отправки провайдеру.
+// Бинарные файлы (изображения, PDF и т.п.) передаются как есть
+func (r *Router) redactRequest(dialogID uint64, text string, files []FileUpload) (string, []FileUpload) {
+	if r.redactor == nil {
+		return text, files
+	}
+	text = r.redactor.Redact(dialogID, text)
+
+	for i := range files {
+		if files[i].Content == nil || !isTextMimeType(files[i].MimeType) {
+			continue
+		}
+		content, err := io.ReadAll(files[i].Content)
+		if err != nil {
+			// Содержимое уже частично прочитано — отправлять его нельзя ни целиком, ни в исходном виде
+			logger.Warn("ошибка чтения файла %s для маскирования: %v", files[i].Name, err)
+			files[i].Content = strings.NewReader("")
+			continue
+		}
+		files[i].Content = strings.NewReader(r.redactor.Redact(dialogID, string(content)))
+	}
+	return text, files
+}
+
+// restoreResponse возвращает в ответ модели персональные данные вместо токенов
+func (r *Router) restoreResponse(dialogID uint64, resp AssistResponse) AssistResponse {
+	if r.redactor == nil {
+		return resp
+	}
+	resp.Message = r.redactor.Restore(dialogID, resp.Message)
+	for _, buttons := range [][]Button{resp.Action.Buttons, resp.Action.QuickReplies} {
+		for i := range buttons {
+			buttons[i].Text = r.redactor.Restore(dialogID, buttons[i].Text)
+			buttons[i].Payload = r.redactor.Restore(dialogID, buttons[i].Payload)
+		}
+	}
+	for i := range resp.Action.SendFiles {
+		resp.Action.SendFiles[i].Caption = r.redactor.Restore(dialogID, resp.Action.SendFiles[i].Caption)
+	}
+	for key, value := range resp.Extra {
+		resp.Extra[key] = restoreValue(r.redactor, dialogID, value)
+	}
+	return resp
+}
+
+// restoreValue восстанавливает строки во вложенных значениях Extra
+func restoreValue(redactor *redact.Redactor, dialogID uint64, value any) any {
+	switch v := value.(type) {
+	case string:
+		return redactor.Restore(dialogID, v)
+	case []any:
+		for i := range v {
+			v[i] = restoreValue(redactor, dialogID, v[i])
+		}
+	case map[string]any:
+		for key := range v {
+			v[key] = restoreValue(redactor, dialogID, v[key])
+		}
+	}
+	return value
+}
+
+type dialogKey struct{}
+
+// WithDialogID контекст вызова инструмента с диалогом, в котором модель его вызвала.
+// Провайдеры передают такой контекст в ActionHandler.RunAction
+func WithDialogID(ctx context.Context, dialogID uint64) context.Context {
+	return context.WithValue(ctx, dialogKey{}, dialogID)
+}
+
+// DialogIDFromContext диалог из контекста WithDialogID
+func DialogIDFromContext(ctx context.Context) (uint64, bool) {
+	if ctx == nil {
+		return 0, false
+	}
+	id, ok := ctx.Value(dialogKey{}).(uint64)
+	return id, ok
+}
+
+// RedactActions оборачивает обработчик инструментов для WithRedactor: аргументы вызова
+// получают исходные значения вместо токенов диалога, а персональные данные в результате
+// маскируются до возврата модели. Диалог берётся из контекста (WithDialogID); без него
+// вызов передаётся как есть. MCPConfigProvider обёртки сохраняется
+func RedactActions(h ActionHandler, redactor *redact.Redactor) ActionHandler {
+	if h == nil || redactor == nil {
+		return h
+	}
+	actions := redactingActions{ActionHandler: h, redactor: redactor}
+	if mcp, ok := h.(MCPConfigProvider); ok {
+		return redactingMCPActions{MCPConfigProvider: mcp, actions: actions}
+	}
+	return actions
+}
+
+type redactingActions struct {
+	ActionHandler
+	redactor *redact.Redactor
+}
+
+func (a redactingActions) RunAction(ctx context.Context, functionName, arguments string, provider create.ProviderType, userID uint32) string {
+	dialogID, ok := DialogIDFromContext(ctx)
+	if !ok {
+		return a.ActionHandler.RunAction(ctx, functionName, arguments, provider, userID)
+	}
+	result := a.ActionHandler.RunAction(ctx, functionName, a.redactor.Restore(dialogID, arguments), provider, userID)
+	return a.redactor.Redact(dialogID, result)
+}
+
+type redactingMCPActions struct {
+	MCPConfigProvider
+	actions redactingActions
+}
+
+func (a redactingMCPActions) RunAction(ctx context.Context, functionName, arguments string, provider create.ProviderType, userID uint32) string {
+	return a.actions.RunAction(ctx, functionName, arguments, provider, userID)
+}
+
+// restoreDeltas оборачивает onDelta: текстовые дельты и финальный ответ получают
+// исходные значения. Токен, разрезанный между дельтами, останется токеном в промежуточном
+// выводе, но финальная дельта (AssistResponse целиком) восстанавливается полностью
+func (r *Router) restoreDeltas(dialogID uint64, onDelta func(delta string, done bool) error) func(delta string, done bool) error {
+	if r.redactor == nil || onDelta == nil {
+		return onDelta
+	}
+	return func(delta string, done bool) error {
+		if !done {
+			return onDelta(r.redactor.Restore(dialogID, delta), false)
+		}
+		var resp AssistResponse
+		if err := json.Unmarshal([]byte(delta), &resp); err != nil {
+			return onDelta(delta, true)
+		}
+		jsonData, _ := json.Marshal(r.restoreResponse(dialogID, resp))
+		return onDelta(string(jsonData), true)
+	}
+}
+
+func isTextMimeType(mimeType string) bool {
+	switch {
+	case strings.HasPrefix(mimeType, "text/"):
+		return true
+	case mimeType == "application/json", mimeType == "application/xml", mimeType == "application/x-yaml":
+		return true
+	default:
+		return false
+	}
+}
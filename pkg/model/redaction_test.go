@@ -0,0 +1,79 @@
+package model
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/ikermy/AiR_Common/pkg/model/create"
+	"github.com/ikermy/AiR_Common/pkg/redact"
+)
+
+// recordingActions — ActionHandler, запоминающий аргументы вызова
+type recordingActions struct {
+	args   string
+	result string
+}
+
+func (a *recordingActions) RunAction(_ context.Context, _, arguments string, _ create.ProviderType, _ uint32) string {
+	a.args = arguments
+	return a.result
+}
+
+// recordingMCP — recordingActions с инструментами MCP
+type recordingMCP struct {
+	recordingActions
+}
+
+func (*recordingMCP) FetchToolsList(context.Context, uint32, create.ProviderType) ([]MCPToolDefinition, error) {
+	return nil, nil
+}
+
+func (*recordingMCP) FetchSystemPrompt(context.Context, uint32, create.ProviderType) (string, error) {
+	return "", nil
+}
+
+func TestRestoreResponse_ExtraAndCaptions(t *testing.T) {
+	redactor := redact.New()
+	r := &Router{redactor: redactor}
+	token := redactor.Redact(5, "ivan@example.com")
+
+	resp := r.restoreResponse(5, AssistResponse{
+		Message: "Письмо уйдёт на " + token,
+		Action:  Action{SendFiles: []File{{URL: "https://x/y.pdf", Caption: "Счёт для " + token}}},
+		Extra:   map[string]any{"email": token, "contacts": []any{token, map[string]any{"to": token}}},
+	})
+	if !strings.Contains(resp.Message, "ivan@example.com") || resp.Action.SendFiles[0].Caption != "Счёт для ivan@example.com" {
+		t.Fatalf("message/caption: %+v", resp)
+	}
+	contacts := resp.Extra["contacts"].([]any)
+	if resp.Extra["email"] != "ivan@example.com" || contacts[0] != "ivan@example.com" ||
+		contacts[1].(map[string]any)["to"] != "ivan@example.com" {
+		t.Fatalf("extra: %+v", resp.Extra)
+	}
+}
+
+func TestRedactActions(t *testing.T) {
+	redactor := redact.New()
+	token := redactor.Redact(5, "ivan@example.com")
+	inner := &recordingMCP{recordingActions{result: `{"manager":"anna@example.com"}`}}
+
+	h := RedactActions(inner, redactor)
+	if _, ok := h.(MCPConfigProvider); !ok {
+		t.Fatal("MCPConfigProvider lost")
+	}
+
+	result := h.RunAction(WithDialogID(context.Background(), 5), "send_mail", `{"to":"`+token+`"}`, create.ProviderOpenAI, 1)
+	if inner.args != `{"to":"ivan@example.com"}` {
+		t.Fatalf("tool args not restored: %s", inner.args)
+	}
+	if strings.Contains(result, "anna@example.com") {
+		t.Fatalf("tool result not redacted: %s", result)
+	}
+
+	// Без диалога в контексте вызов передаётся как есть
+	h.RunAction(context.Background(), "send_mail", `{"to":"`+token+`"}`, create.ProviderOpenAI, 1)
+	if inner.args != `{"to":"`+token+`"}` {
+		t.Fatalf("args changed without dialog: %s", inner.args)
+	}
+}
@@ -0,0 +1,277 @@
+package model
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/ikermy/AiR_Common/pkg/logger"
+	"github.com/ikermy/AiR_Common/pkg/mode"
+	"github.com/ikermy/AiR_Common/pkg/model/create"
+)
+
+// SchemaError ответ модели не соответствует схеме AssistResponse
+type SchemaError struct {
+	Problems []string
+}
+
+func (e *SchemaError) Error() string {
+	return "ответ не соответствует схеме: " + strings.Join(e.Problems, "; ")
+}
+
+// SchemaRepairFunc просит модель исправить ответ под схему; возвращает исправленный JSON
+type SchemaRepairFunc func(userID uint32, invalid string, problems []string) (string, error)
+
+// ValidateAssistResponse строго проверяет ответ модели по схеме ответа (create.ResponseSchema).
+// Принимает JSON-объект, JSON в markdown-блоке и JSON-строку, содержащую объект (так
+// Responses API иногда возвращает структурированный ответ). Необязательные поля могут
+// отсутствовать, но присутствующие должны иметь верный тип; файлы — допустимый тип и http(s) URL.
+// В Extra попадают только поля RegisterResponseField и fields (поля ассистента,
+// UniversalModelData.ResponseFields); прочие ключи, придуманные моделью, отбрасываются
+func ValidateAssistResponse(text string, fields ...create.SchemaField) (AssistResponse, error) {
+	raw, err := assistResponseObject(text)
+	if err != nil {
+		return AssistResponse{}, &SchemaError{Problems: []string{err.Error()}}
+	}
+
+	var problems []string
+	if v, ok := raw["message"]; !ok {
+		problems = append(problems, "нет поля message")
+	} else if _, ok := v.(string); !ok {
+		problems = append(problems, "message не строка")
+	}
+	for _, key := range []string{"target", "operator"} {
+		if v, ok := raw[key]; ok {
+			if _, ok := v.(bool); !ok {
+				problems = append(problems, key+" не boolean")
+			}
+		}
+	}
+	if v, ok := raw["confidence"]; ok {
+		if c, ok := v.(float64); !ok || c < 0 || c > 1 {
+			problems = append(problems, "confidence не число от 0 до 1")
+		}
+	}
+	if v, ok := raw["action"]; ok {
+		problems = append(problems, validateAction(v)...)
+	}
+	if len(problems) > 0 {
+		return AssistResponse{}, &SchemaError{Problems: problems}
+	}
+
+	data, _ := json.Marshal(raw)
+	var resp AssistResponse
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return AssistResponse{}, &SchemaError{Problems: []string{err.Error()}}
+	}
+	resp.Extra = ResponseExtra(raw, fields...)
+	return resp, nil
+}
+
+// ResponseExtra значения дополнительных полей ответа raw: полей RegisterResponseField и fields.
+// nil — таких полей в ответе нет
+func ResponseExtra(raw map[string]any, fields ...create.SchemaField) map[string]any {
+	var extra map[string]any
+	for _, f := range append(create.ResponseFields(), fields...) {
+		v, ok := raw[f.Name]
+		if !ok || v == nil || create.IsReservedResponseField(f.Name) {
+			continue
+		}
+		if extra == nil {
+			extra = make(map[string]any)
+		}
+		extra[f.Name] = v
+	}
+	return extra
+}
+
+func validateAction(v any) []string {
+	action, ok := v.(map[string]any)
+	if !ok {
+		return []string{"action не объект"}
+	}
+	problems := validateButtons("buttons", action["buttons"])
+	problems = append(problems, validateButtons("quick_replies", action["quick_replies"])...)
+
+	filesValue, ok := action["send_files"]
+	if !ok || filesValue == nil {
+		return problems
+	}
+	files, ok := filesValue.([]any)
+	if !ok {
+		return append(problems, "action.send_files не массив")
+	}
+
+	for i, item := range files {
+		file, ok := item.(map[string]any)
+		if !ok {
+			problems = append(problems, fmt.Sprintf("send_files[%d] не объект", i))
+			continue
+		}
+		switch t, _ := file["type"].(string); FileType(t) {
+		case Photo, Video, Audio, Doc:
+		default:
+			problems = append(problems, fmt.Sprintf("send_files[%d].type %q не photo/video/audio/doc", i, t))
+		}
+		// Прежние схемы OpenAI и Mistral называли поле "Url"
+		url, ok := file["url"].(string)
+		if !ok {
+			url, _ = file["Url"].(string)
+		}
+		if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+			problems = append(problems, fmt.Sprintf("send_files[%d].url %q не http(s) URL", i, url))
+		}
+		for _, key := range []string{"file_name", "caption"} {
+			if v, ok := file[key]; ok {
+				if _, ok := v.(string); !ok {
+					problems = append(problems, fmt.Sprintf("send_files[%d].%s не строка", i, key))
+				}
+			}
+		}
+	}
+	return problems
+}
+
+// validateButtons проверяет action.buttons и action.quick_replies: у кнопки непустой текст,
+// URL — если задан — http(s)
+func validateButtons(key string, v any) []string {
+	if v == nil {
+		return nil
+	}
+	buttons, ok := v.([]any)
+	if !ok {
+		return []string{"action." + key + " не массив"}
+	}
+
+	var problems []string
+	for i, item := range buttons {
+		button, ok := item.(map[string]any)
+		if !ok {
+			problems = append(problems, fmt.Sprintf("%s[%d] не объект", key, i))
+			continue
+		}
+		if text, _ := button["text"].(string); strings.TrimSpace(text) == "" {
+			problems = append(problems, fmt.Sprintf("%s[%d].text пустой", key, i))
+		}
+		if v, ok := button["payload"]; ok {
+			if _, ok := v.(string); !ok {
+				problems = append(problems, fmt.Sprintf("%s[%d].payload не строка", key, i))
+			}
+		}
+		if v, ok := button["url"]; ok {
+			url, ok := v.(string)
+			if !ok || (url != "" && !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://")) {
+				problems = append(problems, fmt.Sprintf("%s[%d].url %v не http(s) URL", key, i, v))
+			}
+		}
+	}
+	return problems
+}
+
+// assistResponseObject достаёт JSON-объект ответа из текста модели
+func assistResponseObject(text string) (map[string]any, error) {
+	cleaned := trimMarkdownFence(text)
+
+	var raw map[string]any
+	if err := json.Unmarshal([]byte(cleaned), &raw); err == nil {
+		return raw, nil
+	}
+	var encoded string
+	if err := json.Unmarshal([]byte(cleaned), &encoded); err == nil {
+		if err := json.Unmarshal([]byte(trimMarkdownFence(encoded)), &raw); err == nil {
+			return raw, nil
+		}
+	}
+	return nil, fmt.Errorf("ответ не JSON-объект")
+}
+
+// trimMarkdownFence убирает обрамление ```json ... ```
+func trimMarkdownFence(text string) string {
+	cleaned := strings.TrimSpace(text)
+	if !strings.HasPrefix(cleaned, "```") {
+		return cleaned
+	}
+	cleaned = strings.TrimPrefix(cleaned, "```")
+	if nl := strings.IndexByte(cleaned, '\n'); nl != -1 && !strings.ContainsAny(cleaned[:nl], "{[\"") {
+		cleaned = cleaned[nl+1:] // язык блока: json, JSON и т.п.
+	}
+	cleaned = strings.TrimSpace(cleaned)
+	return strings.TrimSpace(strings.TrimSuffix(cleaned, "```"))
+}
+
+// ParseAssistResponse разбирает ответ модели: строгая проверка по схеме, при ошибке —
+// одна попытка исправления моделью (repair может быть nil), затем текстовый режим.
+// Обычный текст без JSON сразу уходит в текстовый режим — исправлять в нём нечего.
+// fields — дополнительные поля ассистента (см. ValidateAssistResponse)
+func ParseAssistResponse(userID uint32, text string, repair SchemaRepairFunc, fields ...create.SchemaField) AssistResponse {
+	resp, err := ValidateAssistResponse(text, fields...)
+	if err == nil {
+		return resp
+	}
+
+	raw, jsonErr := assistResponseObject(text)
+	if jsonErr != nil && !looksLikeJSON(text) {
+		return plainTextResponse(text)
+	}
+
+	if repair != nil {
+		var problems []string
+		if se, ok := err.(*SchemaError); ok {
+			problems = se.Problems
+		}
+		repaired, repairErr := repair(userID, text, problems)
+		if repairErr == nil {
+			if resp, err := ValidateAssistResponse(repaired, fields...); err == nil {
+				return resp
+			}
+		}
+		logger.Warn("schema: ответ модели не исправлен (%v), текстовый режим", err, userID)
+	}
+
+	// Текстовый режим: из объекта берутся текст сообщения и флаги цели и оператора
+	// (иначе просьба позвать оператора теряется), файлы отбрасываются
+	resp = plainTextResponse(text)
+	if msg, ok := raw["message"].(string); ok && msg != "" {
+		resp.Message = msg
+	}
+	resp.Meta, _ = raw["target"].(bool)
+	resp.Operator, _ = raw["operator"].(bool)
+	return resp
+}
+
+func looksLikeJSON(text string) bool {
+	cleaned := trimMarkdownFence(text)
+	return strings.HasPrefix(cleaned, "{") || strings.HasPrefix(cleaned, `"{`)
+}
+
+func plainTextResponse(text string) AssistResponse {
+	return AssistResponse{Message: text, Action: Action{SendFiles: []File{}}}
+}
+
+// schemaRepairPrompt системный промпт исправления ответа
+const schemaRepairPrompt = "Fix the JSON from the user message to match this JSON Schema. " +
+	"Keep the original meaning and text. Return only the JSON object, without markdown.\n\nSchema:\n"
+
+// LLMSchemaRepair исправление ответа одиночным запросом к модели провайдера
+// (обычно той же модели, что дала ответ)
+func LLMSchemaRepair(ctx context.Context, client create.ProviderClient, modelName string) SchemaRepairFunc {
+	schema := create.DefaultResponseSchema().PromptText()
+	return func(userID uint32, invalid string, problems []string) (string, error) {
+		reqCtx, cancel := context.WithTimeout(ctx, mode.SchemaRepairTimeout)
+		defer cancel()
+
+		resp, err := client.Request(reqCtx, userID, create.ProviderRequest{
+			Model:  modelName,
+			System: schemaRepairPrompt + schema,
+			Messages: []create.ProviderMessage{{
+				Role: "user",
+				Text: "Problems: " + strings.Join(problems, "; ") + "\n\nJSON:\n" + invalid,
+			}},
+		})
+		if err != nil {
+			return "", fmt.Errorf("ошибка исправления ответа по схеме: %w", err)
+		}
+		return resp.Text, nil
+	}
+}
@@ -0,0 +1,134 @@
+package model
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/ikermy/AiR_Common/pkg/model/create"
+)
+
+func TestValidateAssistResponseJSONEncodedString(t *testing.T) {
+	input := `"{\"message\":\"Котик прекрасен\",\"action\":{\"send_files\":[{\"type\":\"photo\",\"url\":\"https://example.test/cat.jpg\",\"file_name\":\"котик.jpg\"}]}}"`
+
+	response, err := ValidateAssistResponse(input)
+	if err != nil {
+		t.Fatalf("ValidateAssistResponse() error = %v", err)
+	}
+	if response.Message != "Котик прекрасен" || len(response.Action.SendFiles) != 1 {
+		t.Fatalf("unexpected response: %+v", response)
+	}
+	if response.Action.SendFiles[0].FileName != "котик.jpg" {
+		t.Fatalf("FileName = %q, want %q", response.Action.SendFiles[0].FileName, "котик.jpg")
+	}
+}
+
+func TestValidateAssistResponseMarkdown(t *testing.T) {
+	response, err := ValidateAssistResponse("```json\n{\"message\":\"ok\",\"target\":true}\n```")
+	if err != nil {
+		t.Fatalf("ValidateAssistResponse() error = %v", err)
+	}
+	if response.Message != "ok" || !response.Meta {
+		t.Fatalf("unexpected response: %+v", response)
+	}
+}
+
+func TestValidateAssistResponseOpenAIUrlField(t *testing.T) {
+	response, err := ValidateAssistResponse(`{"message":"x","action":{"send_files":[{"type":"doc","Url":"https://example.test/a.pdf","file_name":"a.pdf","caption":""}]},"target":false}`)
+	if err != nil {
+		t.Fatalf("ValidateAssistResponse() error = %v", err)
+	}
+	if response.Action.SendFiles[0].URL != "https://example.test/a.pdf" {
+		t.Fatalf("URL = %q", response.Action.SendFiles[0].URL)
+	}
+}
+
+func TestValidateAssistResponseProblems(t *testing.T) {
+	_, err := ValidateAssistResponse(`{"message":1,"action":{"send_files":[{"type":"gif","url":"/tmp/a","file_name":"a"},"bad"]},"operator":"yes","confidence":2}`)
+	var se *SchemaError
+	if !errors.As(err, &se) {
+		t.Fatalf("ожидалась SchemaError, получено %v", err)
+	}
+	for _, want := range []string{"message не строка", "send_files[0].type", "send_files[0].url", "send_files[1] не объект", "operator не boolean", "confidence"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("нет проблемы %q в %q", want, err.Error())
+		}
+	}
+}
+
+func TestParseAssistResponseRepair(t *testing.T) {
+	invalid := `{"message":"Файл","action":{"send_files":[{"type":"pdf","url":"https://example.test/a.pdf"}]}}`
+	var gotProblems []string
+	repair := func(_ uint32, text string, problems []string) (string, error) {
+		gotProblems = problems
+		return strings.Replace(text, `"pdf"`, `"doc"`, 1), nil
+	}
+
+	response := ParseAssistResponse(1, invalid, repair)
+	if len(gotProblems) != 1 || len(response.Action.SendFiles) != 1 || response.Action.SendFiles[0].Type != Doc {
+		t.Fatalf("ответ не исправлен: %+v, проблемы %v", response, gotProblems)
+	}
+}
+
+func TestParseAssistResponseFallback(t *testing.T) {
+	repairCalls := 0
+	failing := func(uint32, string, []string) (string, error) {
+		repairCalls++
+		return "", errors.New("недоступно")
+	}
+
+	// Невалидный JSON: после неудачного исправления остаётся текст сообщения без файлов
+	response := ParseAssistResponse(1, `{"message":"Держите","action":{"send_files":[{"type":"photo","url":""}]},"operator":true}`, failing)
+	if response.Message != "Держите" || len(response.Action.SendFiles) != 0 || !response.Operator || repairCalls != 1 {
+		t.Fatalf("unexpected fallback: %+v (repair=%d)", response, repairCalls)
+	}
+
+	// Обычный текст не исправляется
+	response = ParseAssistResponse(1, "Просто ответ", failing)
+	if response.Message != "Просто ответ" || repairCalls != 1 {
+		t.Fatalf("unexpected plain text: %+v (repair=%d)", response, repairCalls)
+	}
+}
+
+func TestValidateAssistResponseExtraFields(t *testing.T) {
+	create.RegisterResponseField(create.SchemaField{Name: "lead_score", Type: "integer"})
+
+	response, err := ValidateAssistResponse(`{"message":"ok","lead_score":7}`)
+	if err != nil {
+		t.Fatalf("ValidateAssistResponse() error = %v", err)
+	}
+	if response.Extra["lead_score"] != float64(7) {
+		t.Fatalf("Extra = %v", response.Extra)
+	}
+}
+
+func TestValidateAssistResponseAssistantFields(t *testing.T) {
+	// Поля ассистента (UniversalModelData.ResponseFields) не регистрируются глобально;
+	// поля вне схемы (mood) отбрасываются
+	response, err := ValidateAssistResponse(`{"message":"ok","order_id":"A-1","target":false,"note":null,"mood":"good"}`,
+		create.SchemaField{Name: "order_id", Type: "string"}, create.SchemaField{Name: "note", Type: "string"})
+	if err != nil {
+		t.Fatalf("ValidateAssistResponse() error = %v", err)
+	}
+	if response.Extra["order_id"] != "A-1" || len(response.Extra) != 1 {
+		t.Fatalf("Extra = %v", response.Extra)
+	}
+}
+
+func TestValidateAssistResponseButtons(t *testing.T) {
+	response, err := ValidateAssistResponse(`{"message":"Выберите","action":{"send_files":[],"buttons":[{"text":"Сайт","payload":"","url":"https://example.test"}],"quick_replies":[{"text":"Да","payload":"yes","url":""}]}}`)
+	if err != nil {
+		t.Fatalf("ValidateAssistResponse() error = %v", err)
+	}
+	if len(response.Action.Buttons) != 1 || response.Action.Buttons[0].URL != "https://example.test" ||
+		len(response.Action.QuickReplies) != 1 || response.Action.QuickReplies[0].Payload != "yes" {
+		t.Fatalf("unexpected action: %+v", response.Action)
+	}
+
+	_, err = ValidateAssistResponse(`{"message":"x","action":{"buttons":[{"text":"","url":"javascript:alert(1)"}],"quick_replies":"да"}}`)
+	for _, want := range []string{"buttons[0].text", "buttons[0].url", "action.quick_replies не массив"} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("нет проблемы %q в %v", want, err)
+		}
+	}
+}
@@ -4,16 +4,24 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"hash/fnv"
 	"io"
-	"log"
+	"strconv"
 	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
 
+	"github.com/ikermy/AiR_Common/pkg/audio"
 	"github.com/ikermy/AiR_Common/pkg/com"
+	"github.com/ikermy/AiR_Common/pkg/experiment"
+	"github.com/ikermy/AiR_Common/pkg/logger"
 	"github.com/ikermy/AiR_Common/pkg/mode"
 	"github.com/ikermy/AiR_Common/pkg/model/create"
 	"github.com/ikermy/AiR_Common/pkg/model/provider_catalog"
+	"github.com/ikermy/AiR_Common/pkg/redact"
+	"github.com/ikermy/AiR_Common/pkg/safego"
+	"github.com/ikermy/AiR_Common/pkg/storage"
 )
 
 // ============================================================================
@@ -22,12 +30,23 @@ import (
 
 // Router маршрутизирует запросы к разным провайдерам моделей
 type Router struct {
-	openai        Inter
-	mistral       Inter
-	google        Inter
+	models        map[create.ProviderType]Inter // модели провайдеров (WithProvider)
 	modelsManager *create.UniversalModel
 	ctx           context.Context
 	db            DB
+	semantic      *SemanticCache       // nil — семантический кэш выключен
+	answers       *answerCache         // nil — одновременные повторы вопроса не объединяются
+	redactor      *redact.Redactor     // nil — персональные данные уходят провайдерам как есть
+	injection     *InjectionDetector   // nil — prompt injection не ищется
+	fileLimits    FileSizeLimits       // лимиты размера файлов пользователя (пустые — без проверки)
+	images        ImageOptions         // обработка изображений пользователя перед отправкой провайдеру
+	scanner       FileScanner          // nil — файлы не проверяются антивирусом
+	media         storage.MediaStore   // nil — сгенерированные файлы сохраняются действием save_image
+	seeds         *Seeds               // nil — детерминированный режим выключен
+	experiments   *experiment.Registry // nil — без A/B-экспериментов
+	limiter       *concurrencyLimiter  // nil — одновременные запросы к провайдерам не ограничены
+	pinned        sync.Map             // key: uint64 (dialogID), value: create.ProviderType (PinDialog)
+	assistants    sync.Map             // key: uint64 (dialogID), value: string (ассистент диалога для семантического кэша)
 }
 
 // RouterOption определяет опцию для настройки Router
@@ -41,8 +60,11 @@ type RouterOption func(*Router, context.Context, DB) error
 //	    mistral.NewAsRouterOption())
 func NewModelRouter(ctx context.Context, db DB, options ...RouterOption) *Router {
 	router := &Router{
-		ctx: ctx,
-		db:  db,
+		ctx:        ctx,
+		db:         db,
+		fileLimits: DefaultFileSizeLimits(),
+		images:     DefaultImageOptions(),
+		limiter:    newConcurrencyLimiter(DefaultConcurrencyLimits()),
 	}
 
 	// Применяем опции ПЕРЕД созданием modelsManager, чтобы WithMasterKeyProvider
@@ -50,53 +72,141 @@ func NewModelRouter(ctx context.Context, db DB, options ...RouterOption) *Router
 	// Каждая опция получает актуальный router.db (возможно уже обёрнутый предыдущей опцией).
 	for _, option := range options {
 		if err := option(router, ctx, router.db); err != nil {
-			log.Fatalf("ошибка применения опции: %v", err)
+			logger.Fatalf("ошибка применения опции: %v", err)
 		}
 	}
 
 	if managerDB, ok := router.db.(create.DB); ok {
 		router.modelsManager = create.New(ctx, managerDB)
 	} else {
-		log.Fatalf("DB не реализует create.DB, невозможна инициализация ModelRouter")
+		logger.Fatalf("DB не реализует create.DB, невозможна инициализация ModelRouter")
 	}
 
-	if router.google != nil {
-		if googleModel, ok := router.google.(interface{ SetUniversalModel(*create.UniversalModel) }); ok {
-			if router.modelsManager == nil {
-				log.Fatal("КРИТИЧЕСКАЯ ОШИБКА: modelsManager == nil, не можем установить UniversalModel!")
+	router.forEachProvider(func(p Inter) {
+		if u, ok := p.(UniversalModelUser); ok {
+			u.SetUniversalModel(router.modelsManager)
+		}
+	})
+
+	if router.media != nil {
+		router.forEachProvider(func(p Inter) {
+			if s, ok := p.(MediaStorer); ok {
+				s.SetMediaStore(router.media)
 			}
-			googleModel.SetUniversalModel(router.modelsManager)
-		} else {
-			log.Fatal("КРИТИЧЕСКАЯ ОШИБКА: Google модель не реализует метод SetUniversalModel!")
+		})
+	}
+
+	router.forEachProvider(func(p Inter) {
+		if ip, ok := p.(ImageProcessor); ok {
+			ip.SetImageOptions(router.images)
 		}
+	})
+
+	if router.injection != nil {
+		router.forEachProvider(func(p Inter) {
+			if guard, ok := p.(InjectionGuard); ok {
+				guard.SetInjectionDetector(router.injection)
+			}
+		})
+	}
+
+	if router.seeds != nil {
+		router.forEachProvider(func(p Inter) {
+			if d, ok := p.(Deterministic); ok {
+				d.SetSeeds(router.seeds)
+			}
+		})
+	}
+
+	if router.experiments != nil {
+		router.forEachProvider(func(p Inter) {
+			if e, ok := p.(Experimenter); ok {
+				e.SetExperiments(router.experiments)
+			}
+		})
 	}
 
-	if router.openai == nil && router.mistral == nil && router.google == nil {
-		log.Fatal("не инициализирован ни один провайдер моделей " +
-			"(используйте openai.NewAsRouterOption(), mistral.NewAsRouterOption() или google.NewAsRouterOption())")
+	if len(router.providers()) == 0 {
+		logger.Fatalf("не инициализирован ни один провайдер моделей " +
+			"(используйте NewAsRouterOption() пакета провайдера, например openai.NewAsRouterOption())")
 	}
 
 	return router
 }
 
-// WithOpenAIModel добавляет реализацию OpenAI модели
-func WithOpenAIModel(model Inter) RouterOption {
+// WithProvider добавляет модель провайдера kind. Провайдер должен быть в реестре
+// create.RegisterProviderSpec: по нему Router определяет порядок и имя провайдера
+func WithProvider(kind create.ProviderType, model Inter) RouterOption {
 	return func(r *Router, _ context.Context, _ DB) error {
 		if model == nil {
-			return fmt.Errorf("OpenAI модель не может быть nil")
+			return fmt.Errorf("%s модель не может быть nil", create.ProviderName(kind))
 		}
-		r.openai = model
+		if r.models == nil {
+			r.models = make(map[create.ProviderType]Inter)
+		}
+		r.models[kind] = model
 		return nil
 	}
 }
 
+// WithOpenAIModel добавляет реализацию OpenAI модели
+func WithOpenAIModel(model Inter) RouterOption {
+	return WithProvider(create.ProviderOpenAI, model)
+}
+
 // WithMistralModel добавляет реализацию Mistral модели
 func WithMistralModel(model Inter) RouterOption {
+	return WithProvider(create.ProviderMistral, model)
+}
+
+// WithSemanticCache включает семантический кэш ответов: на вопрос, близкий к уже
+// заданному, отвечает сохранённым ответом без запроса к модели. Кэшируются только
+// текстовые вопросы без файлов
+func WithSemanticCache(cache *SemanticCache) RouterOption {
 	return func(r *Router, _ context.Context, _ DB) error {
-		if model == nil {
-			return fmt.Errorf("Mistral модель не может быть nil")
+		if cache == nil {
+			return fmt.Errorf("семантический кэш не может быть nil")
+		}
+		r.semantic = cache
+		return nil
+	}
+}
+
+// WithRedactor включает маскирование персональных данных: телефоны, email, номера карт
+// и свои выражения в вопросе и текстовых файлах заменяются токенами до отправки провайдеру,
+// а в ответе модели (текст, кнопки, Extra, подписи файлов) токены заменяются обратно.
+// Чтобы инструменты получали исходные значения, провайдерам передаётся обработчик
+// RedactActions с тем же redactor.
+//
+// Соответствия токенов хранятся только в памяти процесса и удаляются с CleanDialogData:
+// после перезапуска токены из истории диалога у провайдера останутся токенами в ответах
+func WithRedactor(redactor *redact.Redactor) RouterOption {
+	return func(r *Router, _ context.Context, _ DB) error {
+		if redactor == nil {
+			return fmt.Errorf("redactor не может быть nil")
+		}
+		r.redactor = redactor
+		return nil
+	}
+}
+
+// WithInjectionDetector включает поиск prompt injection: фрагменты базы знаний очищаются
+// перед добавлением в контекст модели, а подозрительные вопросы пользователей логируются
+func WithInjectionDetector(d *InjectionDetector) RouterOption {
+	return func(r *Router, _ context.Context, _ DB) error {
+		if d == nil {
+			return fmt.Errorf("детектор prompt injection не может быть nil")
 		}
-		r.mistral = model
+		r.injection = d
+		return nil
+	}
+}
+
+// WithFileSizeLimits заменяет лимиты размера файлов пользователя (по умолчанию
+// DefaultFileSizeLimits); пустые лимиты выключают проверку
+func WithFileSizeLimits(limits FileSizeLimits) RouterOption {
+	return func(r *Router, _ context.Context, _ DB) error {
+		r.fileLimits = limits
 		return nil
 	}
 }
@@ -125,84 +235,103 @@ func WithMasterKeyProvider(mkProvider MasterKeyProvider) RouterOption {
 
 // WithGoogleModel добавляет реализацию Google модели
 func WithGoogleModel(model Inter) RouterOption {
-	return func(r *Router, _ context.Context, _ DB) error {
-		if model == nil {
-			return fmt.Errorf("Google модель не может быть nil")
-		}
-		r.google = model
-		return nil
-	}
+	return WithProvider(create.ProviderGoogle, model)
+}
+
+// WithOpenRouterModel добавляет реализацию OpenRouter модели
+func WithOpenRouterModel(model Inter) RouterOption {
+	return WithProvider(create.ProviderOpenRouter, model)
+}
+
+// WithOllamaModel добавляет реализацию локальной модели Ollama
+func WithOllamaModel(model Inter) RouterOption {
+	return WithProvider(create.ProviderOllama, model)
+}
+
+// WithYandexModel добавляет реализацию модели YandexGPT
+func WithYandexModel(model Inter) RouterOption {
+	return WithProvider(create.ProviderYandex, model)
+}
+
+// WithGigaChatModel добавляет реализацию модели GigaChat
+func WithGigaChatModel(model Inter) RouterOption {
+	return WithProvider(create.ProviderGigaChat, model)
+}
+
+// WithGrokModel добавляет реализацию модели xAI Grok
+func WithGrokModel(model Inter) RouterOption {
+	return WithProvider(create.ProviderGrok, model)
 }
 
 // HasOpenAI проверяет, инициализирован ли провайдер OpenAI
-func (r *Router) HasOpenAI() bool { return r.openai != nil }
+func (r *Router) HasOpenAI() bool { return r.models[create.ProviderOpenAI] != nil }
 
 // HasMistral проверяет, инициализирован ли провайдер Mistral
-func (r *Router) HasMistral() bool { return r.mistral != nil }
+func (r *Router) HasMistral() bool { return r.models[create.ProviderMistral] != nil }
 
 // HasGoogle проверяет, инициализирован ли провайдер Google
-func (r *Router) HasGoogle() bool { return r.google != nil }
+func (r *Router) HasGoogle() bool { return r.models[create.ProviderGoogle] != nil }
+
+// HasOpenRouter проверяет, инициализирован ли провайдер OpenRouter
+func (r *Router) HasOpenRouter() bool { return r.models[create.ProviderOpenRouter] != nil }
+
+// HasOllama проверяет, инициализирован ли провайдер Ollama
+func (r *Router) HasOllama() bool { return r.models[create.ProviderOllama] != nil }
+
+// HasYandex проверяет, инициализирован ли провайдер YandexGPT
+func (r *Router) HasYandex() bool { return r.models[create.ProviderYandex] != nil }
+
+// HasGigaChat проверяет, инициализирован ли провайдер GigaChat
+func (r *Router) HasGigaChat() bool { return r.models[create.ProviderGigaChat] != nil }
+
+// HasGrok проверяет, инициализирован ли провайдер Grok
+func (r *Router) HasGrok() bool { return r.models[create.ProviderGrok] != nil }
 
 // GetAvailableProviders возвращает список доступных провайдеров
 func (r *Router) GetAvailableProviders() []string {
-	providers := make([]string, 0, 3)
-	if r.openai != nil {
-		providers = append(providers, "OpenAI")
-	}
-	if r.mistral != nil {
-		providers = append(providers, "Mistral")
-	}
-	if r.google != nil {
-		providers = append(providers, "Google")
+	kinds := r.providerKinds()
+	providers := make([]string, 0, len(kinds))
+	for _, pt := range kinds {
+		providers = append(providers, create.ProviderName(pt.kind))
 	}
 	return providers
 }
 
-// forEachProvider вызывает fn для каждого инициализированного провайдера.
-// Порядок: OpenAI → Mistral → Google.
+// providers инициализированные провайдеры в порядке реестра create.RegisteredProviders
+func (r *Router) providers() []Inter {
+	kinds := r.providerKinds()
+	all := make([]Inter, 0, len(kinds))
+	for _, pt := range kinds {
+		all = append(all, pt.p)
+	}
+	return all
+}
+
+// forEachProvider вызывает fn для каждого инициализированного провайдера
+// в порядке реестра create.RegisteredProviders
 func (r *Router) forEachProvider(fn func(Inter)) {
-	for _, p := range []Inter{r.openai, r.mistral, r.google} {
-		if p != nil {
-			fn(p)
-		}
+	for _, p := range r.providers() {
+		fn(p)
 	}
 }
 
 // getModel возвращает модель по типу провайдера
 func (r *Router) getModel(provider create.ProviderType) (Inter, error) {
-	switch provider {
-	case create.ProviderOpenAI:
-		if r.openai == nil {
-			return nil, fmt.Errorf("модель OpenAI не инициализирована")
-		}
-		return r.openai, nil
-	case create.ProviderMistral:
-		if r.mistral == nil {
-			return nil, fmt.Errorf("модель Mistral не инициализирована")
-		}
-		return r.mistral, nil
-	case create.ProviderGoogle:
-		if r.google == nil {
-			return nil, fmt.Errorf("модель Google не инициализирована")
-		}
-		return r.google, nil
-	default:
+	if p := r.models[provider]; p != nil {
+		return p, nil
+	}
+	if _, ok := create.ProviderCapabilities(provider); !ok {
 		return nil, fmt.Errorf("неизвестный провайдер: %v", provider)
 	}
+	return nil, fmt.Errorf("модель %s не инициализирована", create.ProviderName(provider))
 }
 
 // GetProviderModel возвращает модель конкретного провайдера (для тестирования)
 func (r *Router) GetProviderModel(provider create.ProviderType) any {
-	switch provider {
-	case create.ProviderOpenAI:
-		return r.openai
-	case create.ProviderMistral:
-		return r.mistral
-	case create.ProviderGoogle:
-		return r.google
-	default:
-		return nil
+	if p := r.models[provider]; p != nil {
+		return p
 	}
+	return nil
 }
 
 // ============================================================================
@@ -211,14 +340,8 @@ func (r *Router) GetProviderModel(provider create.ProviderType) any {
 
 // NewMessage делегирует к первому доступному провайдеру
 func (r *Router) NewMessage(operator Operator, msgType string, content *AssistResponse, name *string, files ...FileUpload) Message {
-	if r.openai != nil {
-		return r.openai.NewMessage(operator, msgType, content, name, files...)
-	}
-	if r.mistral != nil {
-		return r.mistral.NewMessage(operator, msgType, content, name, files...)
-	}
-	if r.google != nil {
-		return r.google.NewMessage(operator, msgType, content, name, files...)
+	if all := r.providers(); len(all) > 0 {
+		return all[0].NewMessage(operator, msgType, content, name, files...)
 	}
 	// Fallback — только если ни один провайдер не инициализирован
 	return Message{
@@ -251,15 +374,16 @@ func (r *Router) GetOrSetRespGPT(assist Assistant, dialogID, respId uint64, resp
 		return nil, fmt.Errorf("не удалось получить модель для провайдера %s (UserID=%d): %w",
 			assist.Provider, assist.UserID, err)
 	}
-	return m.GetOrSetRespGPT(assist, dialogID, respId, respName)
+	resp, err := m.GetOrSetRespGPT(assist, dialogID, respId, respName)
+	if err == nil {
+		r.assistants.Store(dialogID, semanticAssistant(assist))
+	}
+	return resp, err
 }
 
 // GetCh ищет канал по respId во всех провайдерах
 func (r *Router) GetCh(respId uint64) (*Ch, error) {
-	for _, p := range []Inter{r.openai, r.mistral, r.google} {
-		if p == nil {
-			continue
-		}
+	for _, p := range r.providers() {
 		if ch, err := p.GetCh(respId); err == nil {
 			return ch, nil
 		}
@@ -269,10 +393,7 @@ func (r *Router) GetCh(respId uint64) (*Ch, error) {
 
 // GetRespIdBydialogID ищет respId по dialogID во всех провайдерах
 func (r *Router) GetRespIdByDialogID(dialogID uint64) (uint64, error) {
-	for _, p := range []Inter{r.openai, r.mistral, r.google} {
-		if p == nil {
-			continue
-		}
+	for _, p := range r.providers() {
 		if id, err := p.GetRespIdByDialogID(dialogID); err == nil {
 			return id, nil
 		}
@@ -285,22 +406,168 @@ func (r *Router) SaveAllContextDuringExit() {
 	r.forEachProvider(func(p Inter) { p.SaveAllContextDuringExit() })
 }
 
+// RestoreContextAfterStart восстанавливает контексты, сохранённые SaveAllContextDuringExit
+// прежним процессом, у провайдеров, реализующих ContextRestorer
+func (r *Router) RestoreContextAfterStart() {
+	r.forEachProvider(func(p Inter) {
+		if cr, ok := p.(ContextRestorer); ok {
+			cr.RestoreContextAfterStart()
+		}
+	})
+}
+
 // Request направляет запрос к провайдеру, которому принадлежит диалог
 func (r *Router) Request(userID uint32, dialogID uint64, text string, files ...FileUpload) (AssistResponse, error) {
-	for _, p := range []Inter{r.openai, r.mistral, r.google} {
-		if p == nil {
-			continue
+	p, kind, ok := r.dialogProvider(dialogID)
+	if !ok {
+		return AssistResponse{}, fmt.Errorf("модель не найдена для DialogID %d", dialogID)
+	}
+	if err := r.fileLimits.Check(files); err != nil {
+		return AssistResponse{}, err
+	}
+	if err := r.scanUploads(userID, dialogID, files); err != nil {
+		return AssistResponse{}, err
+	}
+	r.processImageUploads(files)
+	r.flagInjection(userID, dialogID, text)
+	text, files = r.redactRequest(dialogID, text, files)
+	if len(files) > 0 {
+		resp, err := r.requestProvider(p, kind, userID, dialogID, text, files...)
+		return r.scanResponseFiles(userID, dialogID, r.restoreResponse(dialogID, resp)), err
+	}
+
+	resp, _, err := r.answers.do(r.ctx, dialogID, text, func() (AssistResponse, error) {
+		return r.requestProvider(p, kind, userID, dialogID, text)
+	})
+	return r.scanResponseFiles(userID, dialogID, r.restoreResponse(dialogID, resp)), err
+}
+
+// requestProvider запрос к провайдеру диалога через семантический кэш
+func (r *Router) requestProvider(p Inter, kind create.ProviderType, userID uint32, dialogID uint64, text string, files ...FileUpload) (AssistResponse, error) {
+	scope, cached, embedding, hit := r.lookupSemanticCache(p, kind, userID, dialogID, text, files)
+	if hit {
+		return cached, nil
+	}
+
+	release, err := r.limiter.acquire(r.ctx, kind)
+	if err != nil {
+		return AssistResponse{}, err
+	}
+	resp, err := p.Request(userID, dialogID, text, files...)
+	release()
+	if err != nil {
+		logger.ForDialog(userID, dialogID).Warn("ошибка запроса к провайдеру", "err", err)
+		return resp, err
+	}
+	if embedding != nil {
+		r.semantic.Store(scope, embedding, resp)
+	}
+	return resp, nil
+}
+
+// PinDialog закрепляет диалог за провайдером kind: после смены ассистента респондент
+// диалога остаётся и у прежнего провайдера до истечения TTL, но запросы идут новому
+func (r *Router) PinDialog(dialogID uint64, kind create.ProviderType) error {
+	if _, err := r.getModel(kind); err != nil {
+		return err
+	}
+	r.pinned.Store(dialogID, kind)
+	return nil
+}
+
+// dialogProvider провайдер, который ведёт диалог
+func (r *Router) dialogProvider(dialogID uint64) (Inter, create.ProviderType, bool) {
+	if v, ok := r.pinned.Load(dialogID); ok {
+		kind := v.(create.ProviderType)
+		if p, err := r.getModel(kind); err == nil {
+			if _, err := p.GetRespIdByDialogID(dialogID); err == nil {
+				return p, kind, true
+			}
 		}
-		if _, err := p.GetRespIdByDialogID(dialogID); err == nil {
-			return p.Request(userID, dialogID, text, files...)
+	}
+	for _, pt := range r.providerKinds() {
+		if _, err := pt.p.GetRespIdByDialogID(dialogID); err == nil {
+			return pt.p, pt.kind, true
+		}
+	}
+	return nil, 0, false
+}
+
+type providerKind struct {
+	p    Inter
+	kind create.ProviderType
+}
+
+// providerKinds инициализированные провайдеры в порядке реестра create.RegisteredProviders
+func (r *Router) providerKinds() []providerKind {
+	kinds := make([]providerKind, 0, len(r.models))
+	for _, kind := range create.RegisteredProviders() {
+		if p := r.models[kind]; p != nil {
+			kinds = append(kinds, providerKind{p, kind})
 		}
 	}
-	return AssistResponse{}, fmt.Errorf("модель не найдена для DialogID %d", dialogID)
+	return kinds
+}
+
+// lookupSemanticCache ищет ответ в семантическом кэше и при попадании записывает ход
+// в историю провайдера. embedding != nil — ответ модели можно закэшировать в scope.
+// Кэш не применяется к вопросам с файлами, диалогам с неизвестным ассистентом и
+// провайдерам без локальной истории (TurnRecorder): записать ход из кэша им некуда
+func (r *Router) lookupSemanticCache(p Inter, kind create.ProviderType, userID uint32, dialogID uint64, text string,
+	files []FileUpload) (scope SemanticScope, cached AssistResponse, embedding []float32, hit bool) {
+	if r.semantic == nil || len(files) > 0 || strings.TrimSpace(text) == "" {
+		return scope, cached, nil, false
+	}
+	recorder, ok := p.(TurnRecorder)
+	if !ok {
+		return scope, cached, nil, false
+	}
+	assistant, ok := r.assistants.Load(dialogID)
+	if !ok {
+		return scope, cached, nil, false
+	}
+	last, ok := recorder.LastAnswer(dialogID)
+	if !ok {
+		return scope, cached, nil, false
+	}
+
+	scope = SemanticScope{UserID: userID, Provider: kind, Assistant: assistant.(string), State: semanticState(last)}
+	cached, embedding, hit = r.semantic.Lookup(scope, text)
+	if hit && !recorder.RecordTurn(dialogID, text, cached) {
+		hit = false
+	}
+	return scope, cached, embedding, hit
+}
+
+// semanticAssistant ассистент в SemanticScope
+func semanticAssistant(assist Assistant) string {
+	if assist.AssistId != "" {
+		return assist.AssistId
+	}
+	return assist.AssistName
+}
+
+// semanticState состояние диалога в SemanticScope — хэш последнего ответа модели
+func semanticState(lastAnswer string) string {
+	if lastAnswer == "" {
+		return ""
+	}
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(lastAnswer))
+	return strconv.FormatUint(h.Sum64(), 16)
+}
+
+// invalidateSemanticCache сбрасывает кэш ответов после изменения моделей пользователя
+func (r *Router) invalidateSemanticCache(userID uint32) {
+	if r.semantic != nil {
+		r.semantic.Invalidate(userID)
+	}
 }
 
 // tryProviderStreaming пытается выполнить streaming запрос к провайдеру.
 // Возвращает (true, err) если провайдер найден, (false, nil) если нет.
-func (r *Router) tryProviderStreaming(provider Inter, userID uint32, dialogID uint64, text string,
+// Отмена ctx прерывает запрос, только если провайдер реализует ContextStreamer
+func (r *Router) tryProviderStreaming(ctx context.Context, provider Inter, userID uint32, dialogID uint64, text string,
 	onDelta func(delta string, done bool) error, files ...FileUpload) (bool, error) {
 	if provider == nil {
 		return false, nil
@@ -308,6 +575,9 @@ func (r *Router) tryProviderStreaming(provider Inter, userID uint32, dialogID ui
 	if _, err := provider.GetRespIdByDialogID(dialogID); err != nil {
 		return false, nil
 	}
+	if streamer, ok := provider.(ContextStreamer); ok {
+		return true, streamer.RequestStreamingContext(ctx, userID, dialogID, text, onDelta, files...)
+	}
 	if streamer, ok := provider.(interface {
 		RequestStreaming(userID uint32, dialogID uint64, text string,
 			onDelta func(delta string, done bool) error, files ...FileUpload) error
@@ -331,17 +601,111 @@ func (r *Router) tryProviderStreaming(provider Inter, userID uint32, dialogID ui
 // RequestStreaming направляет streaming запрос к провайдеру диалога
 func (r *Router) RequestStreaming(userID uint32, dialogID uint64, text string,
 	onDelta func(delta string, done bool) error, files ...FileUpload) error {
-	for _, p := range []Inter{r.openai, r.mistral, r.google} {
-		if found, err := r.tryProviderStreaming(p, userID, dialogID, text, onDelta, files...); found {
-			return err
+	return r.RequestStreamingContext(r.ctx, userID, dialogID, text, onDelta, files...)
+}
+
+// RequestStreamingContext streaming запрос к провайдеру диалога, который прерывается
+// отменой ctx. Повтор того же вопроса, пришедший во время запроса, ждёт его и получает
+// ту же ошибку отмены
+func (r *Router) RequestStreamingContext(ctx context.Context, userID uint32, dialogID uint64, text string,
+	onDelta func(delta string, done bool) error, files ...FileUpload) error {
+	p, kind, ok := r.dialogProvider(dialogID)
+	if !ok {
+		return fmt.Errorf("модель не найдена для DialogID %d", dialogID)
+	}
+	if err := r.fileLimits.Check(files); err != nil {
+		return err
+	}
+	if err := r.scanUploads(userID, dialogID, files); err != nil {
+		return err
+	}
+	r.processImageUploads(files)
+	r.flagInjection(userID, dialogID, text)
+	text, files = r.redactRequest(dialogID, text, files)
+	onDelta = r.scanDeltas(userID, dialogID, r.restoreDeltas(dialogID, onDelta))
+	if len(files) > 0 || onDelta == nil {
+		return r.streamProvider(ctx, p, kind, userID, dialogID, text, onDelta, files...)
+	}
+
+	// Повтор вопроса получает только финальную дельту с ответом первого запроса
+	resp, hit, err := r.answers.do(ctx, dialogID, text, func() (AssistResponse, error) {
+		var final AssistResponse
+		err := r.streamProvider(ctx, p, kind, userID, dialogID, text, func(delta string, done bool) error {
+			if done {
+				_ = json.Unmarshal([]byte(delta), &final)
+			}
+			return onDelta(delta, done)
+		})
+		return final, err
+	})
+	if !hit || err != nil {
+		return err
+	}
+	jsonData, _ := json.Marshal(resp)
+	return onDelta(string(jsonData), true)
+}
+
+// streamProvider streaming запрос к провайдеру диалога через семантический кэш
+func (r *Router) streamProvider(ctx context.Context, p Inter, kind create.ProviderType, userID uint32, dialogID uint64, text string,
+	onDelta func(delta string, done bool) error, files ...FileUpload) error {
+	scope, cached, embedding, hit := r.lookupSemanticCache(p, kind, userID, dialogID, text, files)
+	if hit {
+		if onDelta == nil {
+			return nil
+		}
+		jsonData, _ := json.Marshal(cached)
+		return onDelta(string(jsonData), true)
+	}
+
+	// Финальная дельта всех провайдеров — AssistResponse целиком, её и кэшируем
+	if embedding != nil && onDelta != nil {
+		next := onDelta
+		onDelta = func(delta string, done bool) error {
+			if done {
+				var resp AssistResponse
+				if json.Unmarshal([]byte(delta), &resp) == nil {
+					r.semantic.Store(scope, embedding, resp)
+				}
+			}
+			return next(delta, done)
 		}
 	}
-	return fmt.Errorf("модель не найдена для DialogID %d", dialogID)
+
+	release, err := r.limiter.acquire(ctx, kind)
+	if err != nil {
+		logger.ForDialog(userID, dialogID).Warn("запрос не дождался очереди к провайдеру",
+			logger.Provider(kind.String()), "err", err)
+		return err
+	}
+	defer release()
+
+	_, err = r.tryProviderStreaming(ctx, p, userID, dialogID, text, onDelta, files...)
+	if err != nil {
+		logger.ForDialog(userID, dialogID).Warn("ошибка streaming запроса к провайдеру",
+			logger.Provider(kind.String()), "err", err)
+	}
+	return err
 }
 
 // CleanDialogData очищает данные диалога у всех провайдеров
 func (r *Router) CleanDialogData(dialogID uint64) {
 	r.forEachProvider(func(p Inter) { p.CleanDialogData(dialogID) })
+	r.pinned.Delete(dialogID)
+	r.assistants.Delete(dialogID)
+	r.redactor.Forget(dialogID)
+	r.seeds.Clear(dialogID)
+}
+
+// InjectContext добавляет text в историю диалога у провайдера, который его ведёт.
+// Возвращает false, если ни один провайдер не держит историю диалога локально.
+func (r *Router) InjectContext(dialogID uint64, text string) bool {
+	injected := false
+	r.forEachProvider(func(p Inter) {
+		if ci, ok := p.(ContextInjector); ok && ci.InjectContext(dialogID, text) {
+			injected = true
+		}
+	})
+	return injected
 }
 
 // GetActiveUserModel получает активную модель пользователя
@@ -352,48 +716,27 @@ func (r *Router) GetActiveUserModel(userID uint32) (*create.UniversalModelData,
 	return r.modelsManager.GetActiveUserModel(userID)
 }
 
-// GetActiveUserManager возвращает менеджера активного провайдера пользователя.
-// Использует comma-ok form для безопасного type assertion.
+// GetActiveUserManager возвращает менеджера активного провайдера пользователя
 func (r *Router) GetActiveUserManager(userID uint32) (Inter, error) {
 	provider, err := r.db.GetActiveProvider(userID)
 	if err != nil {
 		return nil, fmt.Errorf("ошибка получения активного провайдера для UserID %d: %w", userID, err)
 	}
 
-	switch provider {
-	case create.ProviderOpenAI:
-		if r.openai == nil {
-			return nil, fmt.Errorf("OpenAI провайдер не инициализирован")
-		}
-		manager, ok := r.openai.(OpenAIManager)
-		if !ok {
-			return nil, fmt.Errorf("OpenAI провайдер не реализует OpenAIManager")
-		}
-		return manager, nil
-
-	case create.ProviderMistral:
-		if r.mistral == nil {
-			return nil, fmt.Errorf("Mistral провайдер не инициализирован")
-		}
-		manager, ok := r.mistral.(MistralManager)
-		if !ok {
-			return nil, fmt.Errorf("Mistral провайдер не реализует MistralManager")
-		}
-		return manager, nil
-
-	case create.ProviderGoogle:
-		if r.google == nil {
-			return nil, fmt.Errorf("Google провайдер не инициализирован")
-		}
-		manager, ok := r.google.(GoogleManager)
-		if !ok {
-			return nil, fmt.Errorf("Google провайдер не реализует GoogleManager")
-		}
-		return manager, nil
+	return r.getModel(provider)
+}
 
-	default:
-		return nil, fmt.Errorf("неизвестный провайдер: %s", provider)
+// Moderate проверяет текст модерацией активного провайдера пользователя (реализует Moderator)
+func (r *Router) Moderate(ctx context.Context, userID uint32, text string) (ModerationResult, error) {
+	manager, err := r.GetActiveUserManager(userID)
+	if err != nil {
+		return ModerationResult{}, err
+	}
+	moderator, ok := manager.(Moderator)
+	if !ok {
+		return ModerationResult{}, fmt.Errorf("активный провайдер пользователя %d не поддерживает модерацию", userID)
 	}
+	return moderator.Moderate(ctx, userID, text)
 }
 
 // TranscribeAudio транскрибирует аудио через активный провайдер пользователя
@@ -402,6 +745,8 @@ func (r *Router) TranscribeAudio(userID uint32, audioData []byte, fileName strin
 	if err != nil {
 		return "", fmt.Errorf("ошибка получения активного менеджера для UserID %d: %w", userID, err)
 	}
+	// AMR, CAF и другие форматы, которые не принимают провайдеры, перекодируются в MP3
+	audioData, fileName = audio.ForTranscription(r.ctx, audioData, fileName)
 	return manager.TranscribeAudio(userID, audioData, fileName)
 }
 
@@ -418,10 +763,7 @@ func (r *Router) GetRealtimeProvider(userID uint32) (RealtimeProvider, bool) {
 
 // getRealtimeProviderByRespId возвращает первый RealtimeProvider, у которого есть сессия с данным respId.
 func (r *Router) getRealtimeProviderByRespId(respId uint64) (RealtimeProvider, bool) {
-	for _, p := range []Inter{r.openai, r.mistral, r.google} {
-		if p == nil {
-			continue
-		}
+	for _, p := range r.providers() {
 		rp, ok := p.(RealtimeProvider)
 		if !ok {
 			continue
@@ -466,9 +808,12 @@ func (r *Router) Shutdown(shutCh chan<- com.LogMsg) {
 	r.forEachProvider(func(p Inter) { p.Shutdown(shutCh) })
 }
 
-// CleanUp запускает фоновую очистку у всех провайдеров
+// CleanUp запускает фоновую очистку у всех провайдеров; после паники очистка перезапускается
 func (r *Router) CleanUp() {
-	r.forEachProvider(func(p Inter) { go p.CleanUp() })
+	for _, pt := range r.providerKinds() {
+		safego.Go("router.CleanUp."+create.ProviderName(pt.kind), pt.p.CleanUp,
+			safego.WithRestart(-1, time.Minute), safego.WithDone(r.ctx.Done()))
+	}
 }
 
 // ============================================================================
@@ -477,7 +822,7 @@ func (r *Router) CleanUp() {
 
 // CreateModel создаёт новую модель у указанного провайдера
 func (r *Router) CreateModel(userID uint32, provider create.ProviderType, modelData *create.UniversalModelData, fileIDs []create.Ids) (create.UMCR, error) {
-	go r.syncProviderModelsCatalog(userID, provider)
+	safego.Go("router.syncProviderModelsCatalog", func() { r.syncProviderModelsCatalog(userID, provider) }, safego.WithUserID(userID))
 
 	if _, err := r.getModel(provider); err != nil {
 		return create.UMCR{}, err
@@ -504,7 +849,7 @@ func (r *Router) syncProviderModelsCatalog(userID uint32, provider create.Provid
 		return
 	}
 
-	if strings.TrimSpace(apiKey) == "" {
+	if provider.RequiresAPIKey() && strings.TrimSpace(apiKey) == "" {
 		return
 	}
 
@@ -550,164 +895,105 @@ func (r *Router) UpdateModelsListByProvider(ctx context.Context, provider create
 	return m.UpdateModelsListByProvider(ctx, provider, apiKey)
 }
 
-// UploadFileToProvider загружает файл в указанный провайдер (только Mistral)
+// libraryManager провайдер с загрузкой файлов в библиотеку документов (LibraryManager)
+func (r *Router) libraryManager(provider create.ProviderType) (LibraryManager, error) {
+	p, err := r.getModel(provider)
+	if err != nil {
+		return nil, err
+	}
+	manager, ok := p.(LibraryManager)
+	if !ok {
+		return nil, fmt.Errorf("%s провайдер не поддерживает библиотеки файлов", create.ProviderName(provider))
+	}
+	return manager, nil
+}
+
+// UploadFileToProvider загружает файл в указанный провайдер (LibraryManager)
 func (r *Router) UploadFileToProvider(userID uint32, provider create.ProviderType, fileName string, fileData []byte) (string, error) {
-	switch provider {
-	case create.ProviderOpenAI:
-		return "", fmt.Errorf("OpenAI провайдер не поддерживает загрузку файлов")
-	case create.ProviderMistral:
-		if r.mistral == nil {
-			return "", fmt.Errorf("Mistral провайдер не инициализирован")
-		}
-		if manager, ok := r.mistral.(MistralManager); ok {
-			return manager.UploadFileToProvider(userID, fileName, fileData)
-		}
-		return "", fmt.Errorf("Mistral провайдер не поддерживает загрузку файлов")
-	case create.ProviderGoogle:
-		return "", fmt.Errorf("Google провайдер не поддерживает загрузку файлов")
-	default:
-		return "", fmt.Errorf("неизвестный провайдер: %s", provider)
+	manager, err := r.libraryManager(provider)
+	if err != nil {
+		return "", err
 	}
+	return manager.UploadFileToProvider(userID, fileName, fileData)
 }
 
 // DeleteTempFile удаляет загруженный временный файл через Mistral провайдер
 func (r *Router) DeleteTempFile(fileID string) error {
-	if r.mistral == nil {
-		return fmt.Errorf("Mistral провайдер не инициализирован")
+	p, err := r.getModel(create.ProviderMistral)
+	if err != nil {
+		return err
 	}
-	manager, ok := r.mistral.(MistralManager)
+	manager, ok := p.(MistralManager)
 	if !ok {
 		return fmt.Errorf("Mistral провайдер не поддерживает удаление временных файлов")
 	}
 	return manager.DeleteTempFile(fileID)
 }
 
-// DeleteFileFromProvider удаляет файл из указанного провайдера (только Mistral)
+// DeleteFileFromProvider удаляет файл из указанного провайдера (LibraryManager)
 func (r *Router) DeleteFileFromProvider(userID uint32, provider create.ProviderType, fileID string) error {
-	switch provider {
-	case create.ProviderOpenAI:
-		return fmt.Errorf("OpenAI провайдер не поддерживает удаление файлов")
-	case create.ProviderMistral:
-		if r.mistral == nil {
-			return fmt.Errorf("Mistral провайдер не инициализирован")
-		}
-		if manager, ok := r.mistral.(MistralManager); ok {
-			return manager.DeleteDocumentFromLibrary(userID, fileID)
-		}
-		return fmt.Errorf("Mistral провайдер не поддерживает удаление файлов")
-	case create.ProviderGoogle:
-		return fmt.Errorf("Google провайдер не поддерживает удаление файлов")
-	default:
-		return fmt.Errorf("неизвестный провайдер: %s", provider)
+	manager, err := r.libraryManager(provider)
+	if err != nil {
+		return err
 	}
+	return manager.DeleteDocumentFromLibrary(userID, fileID)
 }
 
-// AddFileFromFromProvider добавляет файл в хранилище провайдера (только Mistral)
+// AddFileFromFromProvider добавляет файл в хранилище провайдера (LibraryManager)
 func (r *Router) AddFileFromFromProvider(provider create.ProviderType, userID uint32, fileID, fileName string) error {
-	switch provider {
-	case create.ProviderOpenAI:
-		return fmt.Errorf("OpenAI провайдер не поддерживает добавление файлов")
-	case create.ProviderMistral:
-		if r.mistral == nil {
-			return fmt.Errorf("Mistral провайдер не инициализирован")
-		}
-		if manager, ok := r.mistral.(MistralManager); ok {
-			return manager.AddFileToLibrary(userID, fileID, fileName)
-		}
-		return fmt.Errorf("Mistral провайдер не поддерживает добавление файлов")
-	case create.ProviderGoogle:
-		return fmt.Errorf("Google провайдер не поддерживает добавление файлов")
-	default:
-		return fmt.Errorf("неизвестный провайдер: %s", provider)
+	manager, err := r.libraryManager(provider)
+	if err != nil {
+		return err
 	}
+	return manager.AddFileToLibrary(userID, fileID, fileName)
 }
 
 // ============================================================================
-// VECTOR EMBEDDING МЕТОДЫ (OpenAI + Google)
+// VECTOR EMBEDDING МЕТОДЫ (OpenAI + Google + Mistral)
 // ============================================================================
 
-// UploadDocumentWithEmbedding загружает документ с генерацией эмбеддинга
-func (r *Router) UploadDocumentWithEmbedding(userID uint32, provider, docName, content string, metadata create.DocumentMetadata) (string, error) {
+// embedder провайдер provider с эмбеддингами в общем векторном хранилище (Embedder)
+func (r *Router) embedder(provider string) (Embedder, error) {
 	providerType, err := create.FromString(provider)
 	if err != nil {
-		return "", fmt.Errorf("неверный provider: %w", err)
+		return nil, fmt.Errorf("неверный provider: %w", err)
 	}
-	switch providerType {
-	case create.ProviderGoogle:
-		if r.google == nil {
-			return "", fmt.Errorf("Google провайдер не инициализирован")
-		}
-		if manager, ok := r.google.(GoogleManager); ok {
-			return manager.UploadDocumentWithEmbedding(userID, docName, content, metadata)
-		}
-		return "", fmt.Errorf("Google провайдер не поддерживает загрузку документов с эмбеддингами")
-	case create.ProviderOpenAI:
-		if r.openai == nil {
-			return "", fmt.Errorf("OpenAI провайдер не инициализирован")
-		}
-		if manager, ok := r.openai.(OpenAIManager); ok {
-			return manager.UploadDocumentWithEmbedding(userID, docName, content, metadata)
-		}
-		return "", fmt.Errorf("OpenAI провайдер не поддерживает загрузку документов с эмбеддингами")
-	default:
-		return "", fmt.Errorf("провайдер %s не поддерживает эмбеддинги", provider)
+	p, err := r.getModel(providerType)
+	if err != nil {
+		return nil, err
+	}
+	embedder, ok := p.(Embedder)
+	if !ok {
+		return nil, fmt.Errorf("провайдер %s не поддерживает эмбеддинги", provider)
+	}
+	return embedder, nil
+}
+
+// UploadDocumentWithEmbedding загружает документ с генерацией эмбеддинга
+func (r *Router) UploadDocumentWithEmbedding(userID uint32, provider, docName, content string, metadata create.DocumentMetadata) (string, error) {
+	embedder, err := r.embedder(provider)
+	if err != nil {
+		return "", err
 	}
+	return embedder.UploadDocumentWithEmbedding(userID, docName, content, metadata)
 }
 
 // SearchSimilarDocuments ищет похожие документы в Vector Store
 func (r *Router) SearchSimilarDocuments(userID uint32, provider, query string, limit int) ([]create.VectorDocument, error) {
-	providerType, err := create.FromString(provider)
+	embedder, err := r.embedder(provider)
 	if err != nil {
-		return nil, fmt.Errorf("неверный provider: %w", err)
-	}
-	switch providerType {
-	case create.ProviderGoogle:
-		if r.google == nil {
-			return nil, fmt.Errorf("Google провайдер не инициализирован")
-		}
-		if manager, ok := r.google.(GoogleManager); ok {
-			return manager.SearchSimilarDocuments(userID, query, limit)
-		}
-		return nil, fmt.Errorf("Google провайдер не поддерживает поиск документов")
-	case create.ProviderOpenAI:
-		if r.openai == nil {
-			return nil, fmt.Errorf("OpenAI провайдер не инициализирован")
-		}
-		if manager, ok := r.openai.(OpenAIManager); ok {
-			return manager.SearchSimilarDocuments(userID, query, limit)
-		}
-		return nil, fmt.Errorf("OpenAI провайдер не поддерживает поиск документов")
-	default:
-		return nil, fmt.Errorf("провайдер %s не поддерживает эмбеддинги", provider)
+		return nil, err
 	}
+	return embedder.SearchSimilarDocuments(userID, query, limit)
 }
 
 // DeleteDocument удаляет документ из Vector Store
 func (r *Router) DeleteDocument(userID uint32, provider, docID string) error {
-	providerType, err := create.FromString(provider)
+	embedder, err := r.embedder(provider)
 	if err != nil {
-		return fmt.Errorf("неверный provider: %w", err)
-	}
-	switch providerType {
-	case create.ProviderGoogle:
-		if r.google == nil {
-			return fmt.Errorf("Google провайдер не инициализирован")
-		}
-		if manager, ok := r.google.(GoogleManager); ok {
-			return manager.DeleteDocument(userID, docID)
-		}
-		return fmt.Errorf("Google провайдер не поддерживает удаление документов")
-	case create.ProviderOpenAI:
-		if r.openai == nil {
-			return fmt.Errorf("OpenAI провайдер не инициализирован")
-		}
-		if manager, ok := r.openai.(OpenAIManager); ok {
-			return manager.DeleteDocument(userID, docID)
-		}
-		return fmt.Errorf("OpenAI провайдер не поддерживает удаление документов")
-	default:
-		return fmt.Errorf("провайдер %s не поддерживает эмбеддинги", provider)
+		return err
 	}
+	return embedder.DeleteDocument(userID, docID)
 }
 
 // ListUserDocuments возвращает список документов пользователя.
@@ -715,47 +1001,21 @@ func (r *Router) DeleteDocument(userID uint32, provider, docID string) error {
 func (r *Router) ListUserDocuments(userID uint32, provider string) ([]create.VectorDocument, error) {
 	if provider == "" {
 		var allDocs []create.VectorDocument
-		if r.google != nil {
-			if manager, ok := r.google.(GoogleManager); ok {
-				if docs, err := manager.ListUserDocuments(userID); err == nil && docs != nil {
-					allDocs = append(allDocs, docs...)
-				}
-			}
-		}
-		if r.openai != nil {
-			if manager, ok := r.openai.(OpenAIManager); ok {
-				if docs, err := manager.ListUserDocuments(userID); err == nil && docs != nil {
+		r.forEachProvider(func(p Inter) {
+			if embedder, ok := p.(Embedder); ok {
+				if docs, err := embedder.ListUserDocuments(userID); err == nil && docs != nil {
 					allDocs = append(allDocs, docs...)
 				}
 			}
-		}
+		})
 		return allDocs, nil
 	}
 
-	providerType, err := create.FromString(provider)
+	embedder, err := r.embedder(provider)
 	if err != nil {
-		return nil, fmt.Errorf("неверный provider: %w", err)
-	}
-	switch providerType {
-	case create.ProviderGoogle:
-		if r.google == nil {
-			return nil, fmt.Errorf("Google провайдер не инициализирован")
-		}
-		if manager, ok := r.google.(GoogleManager); ok {
-			return manager.ListUserDocuments(userID)
-		}
-		return nil, fmt.Errorf("Google провайдер не поддерживает список документов")
-	case create.ProviderOpenAI:
-		if r.openai == nil {
-			return nil, fmt.Errorf("OpenAI провайдер не инициализирован")
-		}
-		if manager, ok := r.openai.(OpenAIManager); ok {
-			return manager.ListUserDocuments(userID)
-		}
-		return nil, fmt.Errorf("OpenAI провайдер не поддерживает список документов")
-	default:
-		return nil, fmt.Errorf("провайдер %s не поддерживает эмбеддинги", provider)
+		return nil, err
 	}
+	return embedder.ListUserDocuments(userID)
 }
 
 // ============================================================================
@@ -791,7 +1051,11 @@ func (r *Router) DeleteModel(userID uint32, provider create.ProviderType, delete
 	if r.modelsManager == nil {
 		return fmt.Errorf("модельный менеджер не инициализирован")
 	}
-	return r.modelsManager.DeleteModel(userID, provider, deleteFiles, progressCallback)
+	if err := r.modelsManager.DeleteModel(userID, provider, deleteFiles, progressCallback); err != nil {
+		return err
+	}
+	r.invalidateSemanticCache(userID)
+	return nil
 }
 
 // UpdateModelToDB обновляет модель в БД (без обновления у провайдера)
@@ -799,7 +1063,11 @@ func (r *Router) UpdateModelToDB(userID uint32, data *create.UniversalModelData)
 	if r.modelsManager == nil {
 		return fmt.Errorf("модельный менеджер не инициализирован")
 	}
-	return r.modelsManager.UpdateModelToDB(userID, data)
+	if err := r.modelsManager.UpdateModelToDB(userID, data); err != nil {
+		return err
+	}
+	r.invalidateSemanticCache(userID)
+	return nil
 }
 
 // UpdateModelEveryWhere обновляет модель в БД и у провайдера
@@ -807,7 +1075,11 @@ func (r *Router) UpdateModelEveryWhere(userID uint32, data *create.UniversalMode
 	if r.modelsManager == nil {
 		return fmt.Errorf("модельный менеджер не инициализирован")
 	}
-	return r.modelsManager.UpdateModelEveryWhere(userID, data)
+	if err := r.modelsManager.UpdateModelEveryWhere(userID, data); err != nil {
+		return err
+	}
+	r.invalidateSemanticCache(userID)
+	return nil
 }
 
 // GetUserModels получает все модели пользователя
@@ -831,7 +1103,11 @@ func (r *Router) SetActiveUserModel(userID uint32, provider create.ProviderType)
 	if r.modelsManager == nil {
 		return fmt.Errorf("модельный менеджер не инициализирован")
 	}
-	return r.modelsManager.SetActiveModelByProvider(userID, provider)
+	if err := r.modelsManager.SetActiveModelByProvider(userID, provider); err != nil {
+		return err
+	}
+	r.invalidateSemanticCache(userID)
+	return nil
 }
 
 // GetUserModelByProvider получает модель пользователя по провайдеру
@@ -853,6 +1129,7 @@ func (r *Router) ProvidersWithApiKeys(userID uint32) create.ProvidersAvailabilit
 // InvalidateUserAgentConfigCache инвалидирует кэш конфигурации модели для пользователя
 func (r *Router) InvalidateUserAgentConfigCache(userID uint32) {
 	r.forEachProvider(func(p Inter) { p.InvalidateUserAgentConfigCache(userID) })
+	r.invalidateSemanticCache(userID)
 }
 
 // DisconnectUser завершает активные сессии пользователя у всех инициализированных провайдеров:
@@ -0,0 +1,68 @@
+package model
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// Seeds детерминированный режим диалогов: seed генерации по dialogID. Пока seed задан,
+// провайдеры запрашивают ответ с этим seed и нулевой температурой, и повтор того же
+// вопроса даёт тот же ответ (QA воспроизводит жалобы пользователей).
+// Seed передают Gemini, OpenRouter, Grok и Ollama; OpenAI Responses API, YandexGPT и
+// GigaChat получают только нулевую температуру. Mistral (Conversations API) режим не
+// поддерживает. Нулевой *Seeds — режим выключен
+type Seeds struct {
+	m sync.Map // dialogID → int64
+}
+
+// NewSeeds создаёт пустой реестр
+func NewSeeds() *Seeds {
+	return &Seeds{}
+}
+
+// Set включает детерминированный режим диалога; отрицательный seed выключает его.
+// Возвращает true, если seed диалога изменился
+func (s *Seeds) Set(dialogID uint64, seed int64) bool {
+	if s == nil {
+		return false
+	}
+	if seed < 0 {
+		_, loaded := s.m.LoadAndDelete(dialogID)
+		return loaded
+	}
+	prev, loaded := s.m.Swap(dialogID, seed)
+	return !loaded || prev.(int64) != seed
+}
+
+// Get seed диалога; false — режим выключен
+func (s *Seeds) Get(dialogID uint64) (int64, bool) {
+	if s == nil {
+		return 0, false
+	}
+	v, ok := s.m.Load(dialogID)
+	if !ok {
+		return 0, false
+	}
+	return v.(int64), true
+}
+
+// Clear выключает детерминированный режим диалога
+func (s *Seeds) Clear(dialogID uint64) {
+	if s != nil {
+		s.m.Delete(dialogID)
+	}
+}
+
+// WithDeterministicSeeds включает детерминированный режим по запросу: seed диалога из s
+// передаётся провайдерам. Тот же реестр передаётся в startpoint.WithDeterministicSeeds —
+// он задаёт seed из поля Message.Seed и записывает его в метаданные диалога
+func WithDeterministicSeeds(s *Seeds) RouterOption {
+	return func(r *Router, _ context.Context, _ DB) error {
+		if s == nil {
+			return fmt.Errorf("реестр seed не может быть nil")
+		}
+		r.seeds = s
+		return nil
+	}
+}
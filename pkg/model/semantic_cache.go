@@ -0,0 +1,146 @@
+package model
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/ikermy/AiR_Common/pkg/mode"
+	"github.com/ikermy/AiR_Common/pkg/model/create"
+)
+
+// SemanticEmbedFunc строит эмбеддинг вопроса для семантического кэша
+// (например create.GenerateOpenAIEmbedding с ключом пользователя)
+type SemanticEmbedFunc func(userID uint32, text string) ([]float32, error)
+
+// SemanticCache хранит ответы модели по эмбеддингу вопроса и отдаёт их на почти
+// одинаковые вопросы (косинусная близость не ниже порога). Для FAQ-ботов экономит
+// большую часть запросов к модели. Ответы разделены по SemanticScope: близкий вопрос
+// другому ассистенту или в другом месте диалога кэш не получает. Записи живут ttl и
+// сбрасываются при изменении модели (Router.invalidateSemanticCache).
+// Каждый вопрос, для которого кэш применим, стоит одного запроса эмбеддинга
+type SemanticCache struct {
+	embed     SemanticEmbedFunc
+	threshold float32
+	ttl       time.Duration
+
+	mu      sync.Mutex
+	entries map[SemanticScope][]semanticEntry
+}
+
+// SemanticScope область, в которой ответы взаимозаменяемы: пользователь, провайдер,
+// ассистент и состояние диалога — хэш последнего ответа модели ("" — диалог без истории)
+type SemanticScope struct {
+	UserID    uint32
+	Provider  create.ProviderType
+	Assistant string
+	State     string
+}
+
+type semanticEntry struct {
+	embedding []float32
+	response  AssistResponse
+	expires   time.Time
+}
+
+// NewSemanticCache создаёт кэш. threshold <= 0 и ttl <= 0 заменяются значениями из mode
+func NewSemanticCache(embed SemanticEmbedFunc, threshold float32, ttl time.Duration) *SemanticCache {
+	if threshold <= 0 {
+		threshold = mode.SemanticCacheThreshold
+	}
+	if ttl <= 0 {
+		ttl = mode.SemanticCacheTTL
+	}
+	return &SemanticCache{
+		embed:     embed,
+		threshold: threshold,
+		ttl:       ttl,
+		entries:   make(map[SemanticScope][]semanticEntry),
+	}
+}
+
+// Lookup ищет ответ на близкий вопрос. Эмбеддинг вопроса возвращается и при промахе,
+// чтобы Store не считал его повторно; nil — если эмбеддинг построить не удалось
+func (c *SemanticCache) Lookup(scope SemanticScope, text string) (AssistResponse, []float32, bool) {
+	embedding, err := c.embed(scope.UserID, text)
+	if err != nil || len(embedding) == 0 {
+		return AssistResponse{}, nil, false
+	}
+
+	now := time.Now()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var (
+		best      AssistResponse
+		bestScore float32
+		live      = c.entries[scope][:0]
+	)
+	for _, e := range c.entries[scope] {
+		if now.After(e.expires) {
+			continue
+		}
+		live = append(live, e)
+		if score := cosine(embedding, e.embedding); score >= c.threshold && score > bestScore {
+			best, bestScore = e.response, score
+		}
+	}
+	c.entries[scope] = live
+
+	return best, embedding, bestScore > 0
+}
+
+// Store сохраняет ответ. Ответы с файлами, заблокированные модерацией и передающие
+// диалог оператору не кэшируются — они зависят от конкретного диалога
+func (c *SemanticCache) Store(scope SemanticScope, embedding []float32, response AssistResponse) {
+	if len(embedding) == 0 || !semanticCacheable(response) {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entries := append(c.entries[scope], semanticEntry{
+		embedding: embedding,
+		response:  response,
+		expires:   time.Now().Add(c.ttl),
+	})
+	if over := len(entries) - mode.SemanticCacheMaxEntries; over > 0 {
+		entries = entries[over:]
+	}
+	c.entries[scope] = entries
+}
+
+// Invalidate сбрасывает все ответы моделей пользователя
+func (c *SemanticCache) Invalidate(userID uint32) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key := range c.entries {
+		if key.UserID == userID {
+			delete(c.entries, key)
+		}
+	}
+}
+
+func semanticCacheable(response AssistResponse) bool {
+	return response.Message != "" && !response.Blocked && !response.Operator && !response.Meta &&
+		len(response.Action.SendFiles) == 0
+}
+
+// cosine косинусная близость; векторы разной размерности не сравниваются
+func cosine(a, b []float32) float32 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return float32(dot / (math.Sqrt(normA) * math.Sqrt(normB)))
+}
@@ -0,0 +1,103 @@
+package model
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ikermy/AiR_Common/pkg/model/create"
+)
+
+func TestSemanticCacheServesNearDuplicates(t *testing.T) {
+	vectors := map[string][]float32{
+		"Сколько стоит доставка?":  {1, 0, 0},
+		"Сколько стоит доставка??": {0.99, 0.05, 0},
+		"Как оформить возврат?":    {0, 1, 0},
+	}
+	cache := NewSemanticCache(func(_ uint32, text string) ([]float32, error) {
+		return vectors[text], nil
+	}, 0.95, time.Hour)
+	scope := SemanticScope{UserID: 1, Provider: create.ProviderOpenAI, Assistant: "sales"}
+
+	_, emb, hit := cache.Lookup(scope, "Сколько стоит доставка?")
+	if hit {
+		t.Fatal("hit on empty cache")
+	}
+	cache.Store(scope, emb, AssistResponse{Message: "500 ₽"})
+
+	if resp, _, hit := cache.Lookup(scope, "Сколько стоит доставка??"); !hit || resp.Message != "500 ₽" {
+		t.Errorf("near duplicate: hit=%v resp=%+v", hit, resp)
+	}
+	if _, _, hit := cache.Lookup(scope, "Как оформить возврат?"); hit {
+		t.Error("unrelated question served from cache")
+	}
+	for _, other := range []SemanticScope{
+		{UserID: 1, Provider: create.ProviderMistral, Assistant: "sales"},
+		{UserID: 1, Provider: create.ProviderOpenAI, Assistant: "support"},
+		{UserID: 1, Provider: create.ProviderOpenAI, Assistant: "sales", State: semanticState("Уточните город")},
+	} {
+		if _, _, hit := cache.Lookup(other, "Сколько стоит доставка?"); hit {
+			t.Errorf("answer served to another scope %+v", other)
+		}
+	}
+
+	cache.Invalidate(1)
+	if _, _, hit := cache.Lookup(scope, "Сколько стоит доставка?"); hit {
+		t.Error("hit after Invalidate")
+	}
+}
+
+// historyProvider — провайдер с локальной историей диалога (TurnRecorder)
+type historyProvider struct {
+	dialogsProvider
+	history  []string // чередование вопрос/ответ
+	requests int
+}
+
+func (p *historyProvider) Request(_ uint32, _ uint64, text string, _ ...FileUpload) (AssistResponse, error) {
+	p.requests++
+	answer := "ответ на " + text
+	p.history = append(p.history, text, answer)
+	return AssistResponse{Message: answer}, nil
+}
+
+func (p *historyProvider) LastAnswer(uint64) (string, bool) {
+	if len(p.history) == 0 {
+		return "", true
+	}
+	return p.history[len(p.history)-1], true
+}
+
+func (p *historyProvider) RecordTurn(_ uint64, question string, answer AssistResponse) bool {
+	p.history = append(p.history, question, answer.Message)
+	return true
+}
+
+func TestRouter_SemanticCacheScopedToDialogState(t *testing.T) {
+	cache := NewSemanticCache(func(uint32, string) ([]float32, error) {
+		return []float32{1, 0}, nil // все вопросы «одинаковые»
+	}, 0.95, time.Hour)
+	first := &historyProvider{dialogsProvider: dialogsProvider{dialogs: map[uint64]bool{1: true}}}
+	second := &historyProvider{dialogsProvider: dialogsProvider{dialogs: map[uint64]bool{2: true}}}
+	r := &Router{semantic: cache}
+	r.assistants.Store(uint64(1), "sales")
+	r.assistants.Store(uint64(2), "sales")
+
+	if _, err := r.requestProvider(first, create.ProviderOpenAI, 1, 1, "да"); err != nil {
+		t.Fatal(err)
+	}
+	// Новый диалог того же ассистента: ответ из кэша записан в историю провайдера
+	resp, err := r.requestProvider(second, create.ProviderOpenAI, 1, 2, "да")
+	if err != nil || resp.Message != "ответ на да" || second.requests != 0 || len(second.history) != 2 {
+		t.Fatalf("fresh dialog: resp=%+v err=%v requests=%d history=%v", resp, err, second.requests, second.history)
+	}
+	// Тот же вопрос после другого ответа ассистента идёт к модели
+	if _, err := r.requestProvider(second, create.ProviderOpenAI, 1, 2, "да"); err != nil || second.requests != 1 {
+		t.Fatalf("stale answer after another assistant turn: requests=%d err=%v", second.requests, err)
+	}
+
+	// Диалог, ассистент которого неизвестен Router, кэш не использует
+	third := &historyProvider{dialogsProvider: dialogsProvider{dialogs: map[uint64]bool{3: true}}}
+	if _, err := r.requestProvider(third, create.ProviderOpenAI, 1, 3, "да"); err != nil || third.requests != 1 {
+		t.Fatalf("unknown assistant: requests=%d err=%v", third.requests, err)
+	}
+}
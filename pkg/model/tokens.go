@@ -0,0 +1,25 @@
+package model
+
+import "github.com/ikermy/AiR_Common/pkg/model/create"
+
+// EstimateTokens оценка числа токенов text для модели modelName без обращения к API
+// (create.EstimateTokens). Одна реализация для обрезки истории, оценки стоимости
+// и проверки лимитов контекста
+func EstimateTokens(text, modelName string) int {
+	return create.EstimateTokens(text, modelName)
+}
+
+// CountTokens число токенов text для модели modelName: точное, если активный провайдер
+// пользователя умеет считать токены (TokenCounter), иначе оценка EstimateTokens.
+// exact сообщает, какой из способов сработал. Для Mistral всегда возвращается оценка —
+// точного подсчёта у провайдера нет
+func (r *Router) CountTokens(userID uint32, modelName, text string) (tokens int, exact bool) {
+	if manager, err := r.GetActiveUserManager(userID); err == nil {
+		if counter, ok := manager.(TokenCounter); ok {
+			if n, err := counter.CountTokens(userID, modelName, text); err == nil {
+				return n, true
+			}
+		}
+	}
+	return EstimateTokens(text, modelName), false
+}
@@ -2,6 +2,7 @@ package model
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"strings"
@@ -26,7 +27,7 @@ type Notifications struct {
 // Target структура для хранения целей модели
 type Target struct {
 	MetaAction string
-	Triggers   []string
+	Triggers   []string // create.CompileTriggers: фраза, "re:выражение", "~слово с опечатками"
 }
 
 // Assistant информация об ассистенте
@@ -40,6 +41,10 @@ type Assistant struct {
 	Provider   create.ProviderType
 	Espero     uint8
 	Ignore     bool
+	FollowUp   create.FollowUpConfig   // Напоминание при молчании пользователя
+	Operator   create.OperatorConfig   // Таймаут и повторы ожидания оператора
+	Rules      []create.EscalationRule // Правила эскалации (Metas.Triggers работают как правила с действием meta)
+	Fallback   *AssistResponse         // Ответ при критической ошибке модели перед завершением диалога (nil — startpoint.FatalFallbackMessage)
 }
 
 // RespModel универсальная структура респондента для всех провайдеров
@@ -62,6 +67,18 @@ type Services struct {
 // Action действия для выполнения
 type Action struct {
 	SendFiles []File `json:"send_files,omitempty"`
+	// Buttons кнопки под сообщением (inline-клавиатура Telegram, кнопки веб-чата)
+	Buttons []Button `json:"buttons,omitempty"`
+	// QuickReplies варианты быстрого ответа: payload отправляется как реплика пользователя
+	QuickReplies []Button `json:"quick_replies,omitempty"`
+}
+
+// Button кнопка ответа модели: с URL открывает ссылку, иначе возвращает Payload
+// (пустой Payload — возвращается Text)
+type Button struct {
+	Text    string `json:"text"`
+	Payload string `json:"payload,omitempty"`
+	URL     string `json:"url,omitempty"`
 }
 
 // FileType тип файла
@@ -88,6 +105,30 @@ type AssistResponse struct {
 	Action   Action `json:"action,omitempty"`
 	Meta     bool   `json:"target,omitempty"`
 	Operator bool   `json:"operator,omitempty"`
+	// Confidence уверенность модели в ответе (0..1), если схема ответа её запрашивает
+	Confidence *float64 `json:"confidence,omitempty"`
+	// Sentiment тональность сообщения пользователя (-1..1), сохраняется с ходом диалога
+	// при startpoint.WithSentimentScorer
+	Sentiment *float64 `json:"sentiment,omitempty"`
+	// Citations источники, на которые опирается ответ (поиск провайдера, например google_search)
+	Citations []Citation `json:"citations,omitempty"`
+	// Blocked ответ заменён модерацией; BlockReasons — нарушенные категории
+	Blocked      bool     `json:"blocked,omitempty"`
+	BlockReasons []string `json:"block_reasons,omitempty"`
+	// Extra значения полей, добавленных приложением в схему ответа (create.RegisterResponseField)
+	Extra map[string]any `json:"extra,omitempty"`
+}
+
+// ModerationResult результат проверки текста модерацией
+type ModerationResult struct {
+	Flagged    bool     `json:"flagged"`
+	Categories []string `json:"categories,omitempty"` // нарушенные категории (sexual, hate_and_discrimination, ...)
+}
+
+// Citation источник ответа модели
+type Citation struct {
+	URL   string `json:"url"`
+	Title string `json:"title,omitempty"`
 }
 
 // Ch канал для обмена сообщениями
@@ -97,10 +138,27 @@ type Ch struct {
 	UserID   uint32
 	DialogID uint64
 	RespName string
-	txClosed atomic.Bool
-	rxClosed atomic.Bool
+
+	// Backpressure: отправка ждёт свободного места не дольше SendTimeout
+	// (0 — create.ChanSendTimeout), затем сообщение передаётся в OnOverflow
+	SendTimeout time.Duration
+	OnOverflow  func(dir ChanDir, msg Message)
+
+	txClosed  atomic.Bool
+	rxClosed  atomic.Bool
+	txDropped atomic.Uint64
+	rxDropped atomic.Uint64
+	rxNotify  atomic.Pointer[func()]
 }
 
+// ChanDir направление канала Ch
+type ChanDir string
+
+const (
+	DirTx ChanDir = "tx" // ответы ассистента клиенту
+	DirRx ChanDir = "rx" // сообщения пользователя
+)
+
 // IsTxOpen проверяет, открыт ли канал TxCh для записи
 func (ch *Ch) IsTxOpen() bool {
 	return !ch.txClosed.Load()
@@ -112,7 +170,12 @@ func (ch *Ch) IsRxOpen() bool {
 }
 
 // SendToTx безопасно отправляет сообщение в TxCh
-func (ch *Ch) SendToTx(msg Message) (err error) {
+func (ch *Ch) SendToTx(msg Message) error {
+	return ch.SendToTxContext(context.Background(), msg)
+}
+
+// SendToTxContext отправляет сообщение в TxCh, ожидая свободного места не дольше SendTimeout или отмены ctx
+func (ch *Ch) SendToTxContext(ctx context.Context, msg Message) (err error) {
 	if !ch.IsTxOpen() {
 		return fmt.Errorf("канал TxCh закрыт для DialogID %d", ch.DialogID)
 	}
@@ -122,12 +185,11 @@ func (ch *Ch) SendToTx(msg Message) (err error) {
 			err = fmt.Errorf("%v", r)
 		}
 	}()
-	select {
-	case ch.TxCh <- msg:
-		return nil
-	case <-time.After(1 * time.Second):
-		return fmt.Errorf("таймаут отправки в TxCh для DialogID %d", ch.DialogID)
+	if err = SendTimeout(ctx, ch.TxCh, msg, ch.sendTimeout()); errors.Is(err, ErrQueueFull) {
+		ch.overflow(DirTx, msg)
+		return fmt.Errorf("таймаут отправки в TxCh для DialogID %d: %w", ch.DialogID, err)
 	}
+	return err
 }
 
 // SendToRx безопасно отправляет сообщение в RxCh
@@ -141,11 +203,48 @@ func (ch *Ch) SendToRx(msg Message) (err error) {
 			err = fmt.Errorf("%v", r)
 		}
 	}()
-	select {
-	case ch.RxCh <- msg:
-		return nil
-	default:
-		return fmt.Errorf("канал RxCh переполнен для DialogID %d", ch.DialogID)
+	if err = SendTimeout(context.Background(), ch.RxCh, msg, ch.sendTimeout()); errors.Is(err, ErrQueueFull) {
+		ch.overflow(DirRx, msg)
+		return fmt.Errorf("канал RxCh переполнен для DialogID %d: %w", ch.DialogID, err)
+	}
+	if err == nil {
+		if fn := ch.rxNotify.Load(); fn != nil {
+			(*fn)()
+		}
+	}
+	return err
+}
+
+// SetRxNotify задаёт функцию, вызываемую после каждой успешной записи через SendToRx
+// (используется пулом воркеров для планирования диалога). nil снимает уведомление.
+func (ch *Ch) SetRxNotify(fn func()) {
+	if fn == nil {
+		ch.rxNotify.Store(nil)
+		return
+	}
+	ch.rxNotify.Store(&fn)
+}
+
+// Dropped возвращает число сообщений, потерянных из-за переполнения TxCh и RxCh
+func (ch *Ch) Dropped() (tx, rx uint64) {
+	return ch.txDropped.Load(), ch.rxDropped.Load()
+}
+
+func (ch *Ch) sendTimeout() time.Duration {
+	if ch.SendTimeout > 0 {
+		return ch.SendTimeout
+	}
+	return create.ChanSendTimeout
+}
+
+func (ch *Ch) overflow(dir ChanDir, msg Message) {
+	if dir == DirTx {
+		ch.txDropped.Add(1)
+	} else {
+		ch.rxDropped.Add(1)
+	}
+	if ch.OnOverflow != nil {
+		ch.OnOverflow(dir, msg)
 	}
 }
 
@@ -174,6 +273,10 @@ func (ch *Ch) CloseRx() {
 	ch.rxClosed.Store(true)
 	time.Sleep(10 * time.Millisecond)
 	safeCloseMessage(ch.RxCh)
+	// Будим подписчика, чтобы он увидел закрытие канала
+	if fn := ch.rxNotify.Load(); fn != nil {
+		(*fn)()
+	}
 }
 
 // safeCloseMessage закрывает канал, перехватывая панику при повторном закрытии
@@ -211,8 +314,22 @@ type Message struct {
 	Name      string
 	Timestamp time.Time
 	Files     []FileUpload `json:"files,omitempty"`
+	// ExternalID идентификатор сообщения у канала (Telegram message_id и т.п.), используется для дедупликации
+	ExternalID string `json:"external_id,omitempty"`
+	// Part номер части длинного ответа из Parts (startpoint.WithMessageSplit); 0 — ответ не делился
+	Part  int `json:"part,omitempty"`
+	Parts int `json:"parts,omitempty"`
+	// Seed включает детерминированный режим диалога (model.Seeds): ответы на тот же вопрос
+	// воспроизводимы. Отрицательный seed выключает режим, nil — не меняет
+	Seed *int64 `json:"seed,omitempty"`
 }
 
+// Служебные типы сообщений TxCh: индикатор набора на время запроса к модели
+const (
+	MsgTypeTyping     = "typing"      // модель обрабатывает запрос, повторяется каждые create.TypingInterval
+	MsgTypeTypingDone = "typing_done" // обработка завершена, индикатор можно снять
+)
+
 // FileUpload представляет файл для отправки (code interpreter, изображения и т.д.)
 type FileUpload struct {
 	Name     string    `json:"name"`
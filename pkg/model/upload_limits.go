@@ -0,0 +1,100 @@
+package model
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	"github.com/ikermy/AiR_Common/pkg/mode"
+)
+
+// FileTooLargeError файл пользователя больше лимита своего типа. Текст ошибки
+// пригоден для показа пользователю; бот получает его через errors.As
+type FileTooLargeError struct {
+	Name  string
+	Type  FileType
+	Size  int64 // байт; у потока без длины — больше Limit, точное значение неизвестно
+	Limit int64 // байт
+}
+
+func (e *FileTooLargeError) Error() string {
+	return fmt.Sprintf("файл %q слишком большой: %s больше допустимых %s", e.Name, formatSize(e.Size), formatSize(e.Limit))
+}
+
+func formatSize(n int64) string {
+	const mb = 1 << 20
+	if n >= mb {
+		return fmt.Sprintf("%.1f МБ", float64(n)/mb)
+	}
+	return fmt.Sprintf("%d КБ", (n+1023)/1024)
+}
+
+// FileSizeLimits лимиты размера загружаемых файлов по типам в байтах (0 или нет записи — без лимита)
+type FileSizeLimits map[FileType]int64
+
+// DefaultFileSizeLimits лимиты из mode.UploadLimit*MB
+func DefaultFileSizeLimits() FileSizeLimits {
+	const mb = 1 << 20
+	return FileSizeLimits{
+		Photo: int64(mode.UploadLimitPhotoMB) * mb,
+		Video: int64(mode.UploadLimitVideoMB) * mb,
+		Audio: int64(mode.UploadLimitAudioMB) * mb,
+		Doc:   int64(mode.UploadLimitDocMB) * mb,
+	}
+}
+
+// Check проверяет размер файлов с содержимым (файлы только с URL провайдер скачивает сам).
+// Поток неизвестной длины читается не дальше лимита и заменяется буфером, поэтому
+// files[i].Content после проверки остаётся читаемым с начала
+func (l FileSizeLimits) Check(files []FileUpload) error {
+	if len(l) == 0 {
+		return nil
+	}
+	for i := range files {
+		f := &files[i]
+		if f.Content == nil {
+			continue
+		}
+		fileType := fileTypeByMime(f.MimeType)
+		if f.MimeType == "" {
+			fileType = DetectFileType(f.Name, nil)
+		}
+		limit := l[fileType]
+		if limit <= 0 {
+			continue
+		}
+
+		size, known := contentSize(f.Content)
+		if !known {
+			buf, err := io.ReadAll(io.LimitReader(f.Content, limit+1))
+			if err != nil {
+				return fmt.Errorf("ошибка чтения файла %q: %w", f.Name, err)
+			}
+			f.Content = bytes.NewReader(buf)
+			size = int64(len(buf))
+		}
+		if size > limit {
+			return &FileTooLargeError{Name: f.Name, Type: fileType, Size: size, Limit: limit}
+		}
+	}
+	return nil
+}
+
+// contentSize оставшаяся длина потока, если её можно узнать без чтения
+func contentSize(r io.Reader) (int64, bool) {
+	switch v := r.(type) {
+	case interface{ Len() int }: // bytes.Reader, bytes.Buffer, strings.Reader
+		return int64(v.Len()), true
+	case io.Seeker:
+		cur, err := v.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return 0, false
+		}
+		end, err := v.Seek(0, io.SeekEnd)
+		if _, errBack := v.Seek(cur, io.SeekStart); err != nil || errBack != nil {
+			return 0, false
+		}
+		return end - cur, true
+	}
+	return 0, false
+}
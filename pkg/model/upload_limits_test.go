@@ -0,0 +1,32 @@
+package model
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestFileSizeLimitsCheck(t *testing.T) {
+	limits := FileSizeLimits{Photo: 10, Doc: 20}
+
+	files := []FileUpload{
+		{Name: "a.jpg", MimeType: "image/jpeg", Content: strings.NewReader("0123456789")},
+		{Name: "b.pdf", Content: io.NopCloser(bytes.NewReader(make([]byte, 15)))}, // длина неизвестна
+		{Name: "c.mp3", MimeType: "audio/mpeg", Content: bytes.NewReader(make([]byte, 100))},
+		{Name: "d.jpg", URL: "https://example.test/d.jpg"},
+	}
+	if err := limits.Check(files); err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+	if data, _ := io.ReadAll(files[1].Content); len(data) != 15 {
+		t.Fatalf("поток после проверки потерял данные: %d байт", len(data))
+	}
+
+	err := limits.Check([]FileUpload{{Name: "big.png", Content: io.NopCloser(bytes.NewReader(make([]byte, 50)))}})
+	var tooLarge *FileTooLargeError
+	if !errors.As(err, &tooLarge) || tooLarge.Type != Photo || tooLarge.Limit != 10 || tooLarge.Size <= 10 {
+		t.Fatalf("ожидалась FileTooLargeError, получено %v", err)
+	}
+}
@@ -0,0 +1,87 @@
+package yandex
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ikermy/AiR_Common/pkg/model/create"
+)
+
+// GenerateEmbedding эмбеддинг документа (text-search-doc)
+func (m *Model) GenerateEmbedding(userID uint32, text string) ([]float32, error) {
+	return m.client.Embed(m.ctx, userID, text, false)
+}
+
+// UploadDocumentWithEmbedding сохраняет документ и его эмбеддинг в MariaDB
+// с привязкой к модели YandexGPT пользователя
+func (m *Model) UploadDocumentWithEmbedding(userID uint32, docName, content string, metadata create.DocumentMetadata) (string, error) {
+	modelId, err := m.getActiveModelId(userID)
+	if err != nil {
+		return "", fmt.Errorf("ошибка получения modelId: %w", err)
+	}
+	embedding, err := m.GenerateEmbedding(userID, content)
+	if err != nil {
+		return "", fmt.Errorf("ошибка генерации эмбеддинга: %w", err)
+	}
+
+	docID := fmt.Sprintf("yandex_doc_%d_%d", userID, time.Now().Unix())
+	if err := m.db.SaveEmbedding(userID, modelId, create.ProviderYandex, docID, docName, content, embedding, metadata); err != nil {
+		return "", fmt.Errorf("ошибка сохранения в БД: %w", err)
+	}
+	return docID, nil
+}
+
+// SearchSimilarDocuments ищет документы, близкие к запросу; эмбеддинг запроса строится
+// моделью text-search-query, парной к text-search-doc
+func (m *Model) SearchSimilarDocuments(userID uint32, query string, limit int) ([]create.VectorDocument, error) {
+	modelId, err := m.getActiveModelId(userID)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения modelId: %w", err)
+	}
+	// Пустая база — без лишнего запроса к API
+	count, err := m.db.CountModelEmbeddings(modelId)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка проверки наличия эмбеддингов: %w", err)
+	}
+	if count == 0 {
+		return []create.VectorDocument{}, nil
+	}
+
+	queryEmbedding, err := m.client.Embed(m.ctx, userID, query, true)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка генерации эмбеддинга запроса: %w", err)
+	}
+	return m.db.SearchSimilarEmbeddings(modelId, create.ProviderYandex, queryEmbedding, limit)
+}
+
+// DeleteDocument удаляет документ из БД по docID
+func (m *Model) DeleteDocument(userID uint32, docID string) error {
+	modelId, err := m.getActiveModelId(userID)
+	if err != nil {
+		return fmt.Errorf("ошибка получения modelId: %w", err)
+	}
+	return m.db.DeleteEmbedding(modelId, docID)
+}
+
+// ListUserDocuments возвращает список документов модели из БД
+func (m *Model) ListUserDocuments(userID uint32) ([]create.VectorDocument, error) {
+	modelId, err := m.getActiveModelId(userID)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения modelId: %w", err)
+	}
+	return m.db.ListModelEmbeddings(modelId, create.ProviderYandex)
+}
+
+// getActiveModelId modelId модели YandexGPT пользователя
+func (m *Model) getActiveModelId(userID uint32) (uint64, error) {
+	allModels, err := m.db.GetAllUserModels(userID)
+	if err != nil {
+		return 0, fmt.Errorf("ошибка получения моделей пользователя: %w", err)
+	}
+	for i := range allModels {
+		if allModels[i].Provider == create.ProviderYandex {
+			return allModels[i].ModelId, nil
+		}
+	}
+	return 0, fmt.Errorf("YandexGPT модель не найдена для пользователя %d", userID)
+}
@@ -0,0 +1,56 @@
+// Package yandex подключает YandexGPT (Yandex Cloud Foundation Models API) для клиентов,
+// которым требуется хранение и обработка данных в РФ. Диалоги обслуживает chatapi.Model:
+// история диалога и вызовы функций MCP приводятся к формату Chat Completions,
+// структурированный ответ — через jsonSchema. Ключ пользователя — "<folder_id>:<ключ>",
+// где ключ — API-ключ сервисного аккаунта или OAuth-токен (обменивается на IAM-токен).
+// Эмбеддинги text-search-doc/text-search-query хранятся в MariaDB для RAG.
+//
+//	router := model.NewModelRouter(ctx, db, yandex.NewAsRouterOption())
+package yandex
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ikermy/AiR_Common/pkg/mode"
+	"github.com/ikermy/AiR_Common/pkg/model"
+	"github.com/ikermy/AiR_Common/pkg/model/chatapi"
+	"github.com/ikermy/AiR_Common/pkg/model/create"
+)
+
+// Model диалоговая модель YandexGPT с эмбеддингами для RAG
+type Model struct {
+	*chatapi.Model
+	ctx    context.Context
+	db     chatapi.DB
+	client *create.YandexGPTClient
+}
+
+// New создаёт модель YandexGPT; ключ пользователя читается из БД
+func New(ctx context.Context, d chatapi.DB, actionHandler model.ActionHandler) *Model {
+	client := create.NewYandexGPTClient(mode.YandexLLMURL, mode.YandexIAMURL, func(userID uint32) string {
+		if key, err := d.GetUserAPIKey(userID, create.ProviderYandex); err == nil {
+			return key
+		}
+		return ""
+	})
+	return &Model{
+		Model:  chatapi.New(ctx, d, create.ProviderYandex, client, actionHandler),
+		ctx:    ctx,
+		db:     d,
+		client: client,
+	}
+}
+
+// NewAsRouterOption создаёт модель YandexGPT и возвращает её как опцию для ModelRouter
+func NewAsRouterOption() model.RouterOption {
+	return func(r *model.Router, ctx context.Context, db model.DB) error {
+		routerDB, ok := db.(chatapi.DB)
+		if !ok {
+			return fmt.Errorf("DB не соответствует интерфейсу chatapi.DB")
+		}
+		m := New(ctx, routerDB, model.NewUniversalActionHandler(ctx))
+		m.SetUniversalModel(create.New(ctx, routerDB))
+		return model.WithYandexModel(m)(r, ctx, db)
+	}
+}
@@ -0,0 +1,100 @@
+package yandex
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ikermy/AiR_Common/pkg/model/create"
+)
+
+func TestYandexGPTClient_IAMAndToolCalls(t *testing.T) {
+	var iamCalls, round atomic.Int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("/iam", func(w http.ResponseWriter, r *http.Request) {
+		iamCalls.Add(1)
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"iamToken":  "t1.iam",
+			"expiresAt": time.Now().Add(12 * time.Hour).Format(time.RFC3339Nano),
+		})
+	})
+	mux.HandleFunc("/completion", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer t1.iam" || r.Header.Get("x-folder-id") != "b1g" {
+			t.Errorf("авторизация: %q %q", r.Header.Get("Authorization"), r.Header.Get("x-folder-id"))
+		}
+		var req struct {
+			ModelURI   string           `json:"modelUri"`
+			Messages   []map[string]any `json:"messages"`
+			JSONSchema map[string]any   `json:"jsonSchema"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("тело запроса: %v", err)
+		}
+		if req.ModelURI != "gpt://b1g/yandexgpt/latest" || req.JSONSchema["schema"] == nil {
+			t.Errorf("modelUri/jsonSchema: %q %v", req.ModelURI, req.JSONSchema)
+		}
+		if round.Add(1) == 1 {
+			_, _ = w.Write([]byte(`{"result":{"alternatives":[{"status":"ALTERNATIVE_STATUS_TOOL_CALLS","message":{"role":"assistant",
+				"toolCallList":{"toolCalls":[{"functionCall":{"name":"free_slots","arguments":{"day":"пн"}}}]}}}],
+				"usage":{"inputTextTokens":"10","completionTokens":"5","totalTokens":"15"}}}`))
+			return
+		}
+		results := req.Messages[len(req.Messages)-1]["toolResultList"].(map[string]any)["toolResults"].([]any)
+		result := results[0].(map[string]any)["functionResult"].(map[string]any)
+		if result["name"] != "free_slots" || result["content"] != `{"slots":["10:00"]}` {
+			t.Errorf("результат функции: %v", result)
+		}
+		_, _ = w.Write([]byte(`{"result":{"alternatives":[{"status":"ALTERNATIVE_STATUS_FINAL","message":{"role":"assistant",
+			"text":"{\"message\":\"Свободно в 10:00\"}"}}],
+			"usage":{"inputTextTokens":"20","completionTokens":"7","totalTokens":"27"}}}`))
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	client := create.NewYandexGPTClient(srv.URL, srv.URL+"/iam", func(uint32) string { return "b1g:y0_oauth" })
+	req := create.ChatRequest{
+		Model: "yandexgpt/latest",
+		Messages: []create.ChatMessage{
+			{Role: "system", Content: "Ты администратор салона"},
+			{Role: "user", Content: "Есть запись на понедельник?"},
+		},
+		Tools: []create.ChatTool{{Type: "function", Function: create.ChatFunction{Name: "free_slots"}}},
+		ResponseFormat: map[string]any{
+			"type":        "json_schema",
+			"json_schema": map[string]any{"schema": map[string]any{"type": "object"}},
+		},
+	}
+	var gotArgs string
+	run := func(_ context.Context, _, arguments string) string {
+		gotArgs = arguments
+		return `{"slots":["10:00"]}`
+	}
+
+	resp, _, err := create.CompleteWithTools(context.Background(), client, 1, req, run)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gotArgs != `{"day":"пн"}` {
+		t.Fatalf("аргументы функции: %q", gotArgs)
+	}
+	if resp.Text() != `{"message":"Свободно в 10:00"}` || resp.Usage.TotalTokens != 42 {
+		t.Fatalf("ответ: %q %+v", resp.Text(), resp.Usage)
+	}
+	if iamCalls.Load() != 1 {
+		t.Fatalf("IAM-токен должен кэшироваться, запросов: %d", iamCalls.Load())
+	}
+}
+
+func TestParseYandexKey(t *testing.T) {
+	if _, _, err := create.ParseYandexKey("AQVNxxx"); err == nil {
+		t.Fatal("ключ без folder_id должен отклоняться")
+	}
+	folder, credential, err := create.ParseYandexKey("b1g:AQVNxxx")
+	if err != nil || folder != "b1g" || credential != "AQVNxxx" {
+		t.Fatalf("%q %q %v", folder, credential, err)
+	}
+}
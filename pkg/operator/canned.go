@@ -0,0 +1,175 @@
+package operator
+
+import (
+	"fmt"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/ikermy/AiR_Common/pkg/comdb"
+	"github.com/ikermy/AiR_Common/pkg/model"
+)
+
+// CannedStore — хранилище быстрых ответов (реализуется *comdb.DB)
+type CannedStore interface {
+	SaveCannedReply(r comdb.CannedReply) (uint64, error)
+	ListCannedReplies(userID uint32) ([]comdb.CannedReply, error)
+	DeleteCannedReply(userID uint32, id uint64) error
+}
+
+// WithCannedStore сохраняет быстрые ответы в store вместо памяти процесса
+func WithCannedStore(store CannedStore) Option {
+	return func(o *Operator) { o.canned.store = store }
+}
+
+// cannedVar переменная шаблона: {name}, {operator}, ...
+var cannedVar = regexp.MustCompile(`\{([a-zA-Z_][a-zA-Z0-9_]*)\}`)
+
+// RenderCanned подставляет vars в шаблон. Переменные без значения остаются как есть,
+// чтобы оператор заметил незаполненное место.
+func RenderCanned(text string, vars map[string]string) string {
+	return cannedVar.ReplaceAllStringFunc(text, func(m string) string {
+		if v, ok := vars[m[1:len(m)-1]]; ok {
+			return v
+		}
+		return m
+	})
+}
+
+// cannedLibrary шаблоны в памяти, если хранилище не подключено
+type cannedLibrary struct {
+	store CannedStore
+
+	mu     sync.Mutex
+	nextID uint64
+	byUser map[uint32][]comdb.CannedReply
+}
+
+func (c *cannedLibrary) save(r comdb.CannedReply) (uint64, error) {
+	if c.store != nil {
+		return c.store.SaveCannedReply(r)
+	}
+	if r.Text == "" {
+		return 0, fmt.Errorf("пустой текст шаблона")
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.byUser == nil {
+		c.byUser = make(map[uint32][]comdb.CannedReply)
+	}
+	list := c.byUser[r.UserID]
+	if r.ID != 0 {
+		for i := range list {
+			if list[i].ID == r.ID {
+				r.CreatedAt = list[i].CreatedAt
+				list[i] = r
+				return r.ID, nil
+			}
+		}
+		return 0, fmt.Errorf("шаблон %d не найден", r.ID)
+	}
+	c.nextID++
+	r.ID, r.CreatedAt = c.nextID, time.Now()
+	c.byUser[r.UserID] = append(list, r)
+	return r.ID, nil
+}
+
+func (c *cannedLibrary) list(userID uint32) ([]comdb.CannedReply, error) {
+	if c.store != nil {
+		return c.store.ListCannedReplies(userID)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]comdb.CannedReply(nil), c.byUser[userID]...), nil
+}
+
+func (c *cannedLibrary) delete(userID uint32, id uint64) error {
+	if c.store != nil {
+		return c.store.DeleteCannedReply(userID, id)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	list := c.byUser[userID]
+	for i := range list {
+		if list[i].ID == id {
+			c.byUser[userID] = append(list[:i], list[i+1:]...)
+			return nil
+		}
+	}
+	return nil
+}
+
+// SaveCannedReply добавляет быстрый ответ (ID == 0) или обновляет существующий
+func (o *Operator) SaveCannedReply(r comdb.CannedReply) (uint64, error) {
+	return o.canned.save(r)
+}
+
+// CannedReplies возвращает быстрые ответы пользователя
+func (o *Operator) CannedReplies(userID uint32) ([]comdb.CannedReply, error) {
+	return o.canned.list(userID)
+}
+
+// DeleteCannedReply удаляет быстрый ответ
+func (o *Operator) DeleteCannedReply(userID uint32, id uint64) error {
+	return o.canned.delete(userID, id)
+}
+
+// SendCannedReply отправляет быстрый ответ id в диалог от имени оператора senderName.
+// Ответ приходит в Respondent через ReceiveFromOperator, как обычное сообщение оператора,
+// поэтому диалог должен быть в операторском режиме (сессия открыта).
+func (o *Operator) SendCannedReply(userID uint32, dialogID uint64, id uint64, senderName string, vars map[string]string) error {
+	replies, err := o.canned.list(userID)
+	if err != nil {
+		return err
+	}
+	var text string
+	found := false
+	for _, r := range replies {
+		if r.ID == id {
+			text, found = r.Text, true
+			break
+		}
+	}
+	if !found {
+		return fmt.Errorf("шаблон %d не найден у пользователя %d", id, userID)
+	}
+
+	key := opKey{userID: userID, dialogID: dialogID}
+	val, ok := o.operatorChMap.Load(key)
+	if !ok {
+		return fmt.Errorf("session not found for user=%d dialog=%d", userID, dialogID)
+	}
+	s := val.(*session)
+
+	if _, ok := vars["operator"]; !ok && senderName != "" {
+		vars = withVar(vars, "operator", senderName)
+	}
+	msg := model.Message{
+		Operator:  model.Operator{Operator: true, SenderName: senderName},
+		Type:      "assist",
+		Content:   model.AssistResponse{Message: RenderCanned(text, vars)},
+		Name:      senderName,
+		Timestamp: time.Now(),
+	}
+
+	select {
+	case s.ch.RxCh <- msg:
+		s.touch()
+		o.trackResponse(key, s)
+		return nil
+	case <-s.ctx.Done():
+		return fmt.Errorf("operator session context cancelled")
+	case <-time.After(5 * time.Second):
+		return fmt.Errorf("timeout while sending canned reply")
+	}
+}
+
+// withVar возвращает копию vars с добавленной переменной
+func withVar(vars map[string]string, key, value string) map[string]string {
+	out := make(map[string]string, len(vars)+1)
+	for k, v := range vars {
+		out[k] = v
+	}
+	out[key] = value
+	return out
+}
@@ -0,0 +1,59 @@
+package operator
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ikermy/AiR_Common/pkg/comdb"
+	"github.com/ikermy/AiR_Common/pkg/model"
+)
+
+func TestRenderCanned(t *testing.T) {
+	got := RenderCanned("Здравствуйте, {name}! Меня зовут {operator}, заказ {order}.", map[string]string{"name": "Анна", "operator": "Олег"})
+	if want := "Здравствуйте, Анна! Меня зовут Олег, заказ {order}."; got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+func TestSendCannedReply(t *testing.T) {
+	o := New(context.Background())
+	defer o.Close()
+
+	id, err := o.SaveCannedReply(comdb.CannedReply{UserID: 1, Title: "приветствие", Text: "Добрый день, {name}, на связи {operator}"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if list, _ := o.CannedReplies(1); len(list) != 1 || list[0].ID != id {
+		t.Fatalf("шаблоны: %+v", list)
+	}
+	if list, _ := o.CannedReplies(2); len(list) != 0 {
+		t.Fatalf("шаблоны чужого пользователя: %+v", list)
+	}
+
+	if err := o.SendCannedReply(1, 7, id, "Олег", nil); err == nil {
+		t.Fatal("без сессии оператора ожидали ошибку")
+	}
+
+	// Сессия без SSE-подключения: достаточно каналов и таймера простоя
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	rx := make(chan model.Message, 1)
+	o.operatorChMap.Store(opKey{userID: 1, dialogID: 7}, &session{ch: OperatorCh{RxCh: rx}, ctx: ctx, cancel: cancel, idleTimer: time.NewTimer(time.Hour)})
+
+	if err := o.SendCannedReply(1, 7, id, "Олег", map[string]string{"name": "Анна"}); err != nil {
+		t.Fatal(err)
+	}
+	msg := <-rx
+	if msg.Content.Message != "Добрый день, Анна, на связи Олег" || !msg.Operator.Operator || msg.Type == "" {
+		t.Fatalf("сообщение: %+v", msg)
+	}
+	if err := o.SendCannedReply(1, 7, id+1, "Олег", nil); err == nil {
+		t.Fatal("ожидали ошибку для неизвестного шаблона")
+	}
+
+	_ = o.DeleteCannedReply(1, id)
+	if list, _ := o.CannedReplies(1); len(list) != 0 {
+		t.Fatalf("шаблон не удалён: %+v", list)
+	}
+}
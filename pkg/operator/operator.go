@@ -11,6 +11,7 @@ import (
 
 	"github.com/ikermy/AiR_Common/pkg/mode"
 	"github.com/ikermy/AiR_Common/pkg/model"
+	"github.com/ikermy/AiR_Common/pkg/safego"
 	"github.com/r3labs/sse/v2"
 )
 
@@ -41,9 +42,24 @@ type Operator struct {
 	ctx           context.Context
 	cancel        context.CancelFunc
 	operatorChMap sync.Map
+	router        *Router // распределение диалогов по команде операторов (nil — один оператор)
+	presence      presence
+	canned        cannedLibrary // быстрые ответы операторов
+	sla           slaMetrics    // время ответа и решения, таймауты
 	//cb            CallBack
 }
 
+// Option настройка Operator
+type Option func(*Operator)
+
+// WithRouter включает распределение эскалаций между операторами команды пользователя.
+// Назначенный оператор передаётся бэкенду в поле agent_id, бэкенд может перехватить
+// диалог событием SSE "takeover" с {"agent_id": "..."} или передать событием
+// "transfer" с {"from": "...", "to": "..."} (см. Transfer).
+func WithRouter(r *Router) Option {
+	return func(o *Operator) { o.router = r }
+}
+
 // ключ для сессии оператора
 type opKey struct {
 	userID   uint32
@@ -68,9 +84,12 @@ type session struct {
 	cleanupOnce sync.Once
 	// Канал для ошибок от операторского бэкенда
 	connectionErrorCh chan string
+	// SLA: первое сообщение оператору и первый ответ оператора
+	escalatedAt time.Time
+	respondedAt time.Time
 }
 
-func New(parent context.Context) *Operator {
+func New(parent context.Context, opts ...Option) *Operator {
 	ctx, cancel := context.WithCancel(parent)
 
 	o := &Operator{
@@ -78,6 +97,13 @@ func New(parent context.Context) *Operator {
 		cancel:        cancel,
 		operatorChMap: sync.Map{},
 	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.router != nil {
+		// Новые диалоги назначаются только операторам online
+		o.router.online = o.presence.online
+	}
 
 	return o
 }
@@ -86,6 +112,19 @@ func (o *Operator) Close() {
 	o.cancel()
 }
 
+// Router возвращает маршрутизатор операторов (nil, если не задан WithRouter)
+func (o *Operator) Router() *Router {
+	return o.router
+}
+
+// Takeover передаёт диалог другому оператору команды
+func (o *Operator) Takeover(userID uint32, dialogID uint64, agentID string) (Assignment, error) {
+	if o.router == nil {
+		return Assignment{}, fmt.Errorf("маршрутизатор операторов не задан")
+	}
+	return o.router.Takeover(userID, dialogID, agentID)
+}
+
 // DeleteSession удаляет сессию оператора для заданного пользователя и диалога
 func (o *Operator) DeleteSession(userID uint32, dialogID uint64) error {
 	key := opKey{userID: userID, dialogID: dialogID}
@@ -210,6 +249,10 @@ func (o *Operator) cleanup(key opKey, s *session) {
 
 		// Удаляем сессию из карты
 		o.operatorChMap.Delete(key)
+		o.trackClose(key, s)
+		if o.router != nil {
+			o.router.Release(key.userID, key.dialogID)
+		}
 
 		//logger.Debug("Session cleanup complete (user=%d, dialog=%d)", key.userID, key.dialogID)
 	})
@@ -262,7 +305,10 @@ func (o *Operator) listenerSession(key opKey, s *session) {
 				o.cleanup(key, s)
 				return
 			}
-			s.touch()
+			// Уведомление о передаче диалога — не активность пользователя, простой не продлевает
+			if msg.Type != MsgTypeTransfer {
+				s.touch()
+			}
 			//logger.Debug("Sending message via HTTP POST: %+v", msg)
 
 			go func(message model.Message) {
@@ -291,7 +337,7 @@ func (o *Operator) listenerSession(key opKey, s *session) {
 				return
 			}
 
-			s.touch()
+			// Простой продлевают только сообщения оператора, служебные события — нет
 			etype := string(event.Event)
 			edata := event.Data
 			//logger.Debug("Received SSE event '%s': %s", etype, string(edata))
@@ -323,6 +369,47 @@ func (o *Operator) listenerSession(key opKey, s *session) {
 				continue
 			}
 
+			if etype == "presence" {
+				var update struct {
+					AgentID string `json:"agent_id"`
+					Status  Status `json:"status"`
+				}
+				if err := json.Unmarshal(edata, &update); err == nil {
+					_ = o.SetOperatorStatus(key.userID, update.AgentID, update.Status)
+				}
+				continue
+			}
+
+			if etype == "transfer" {
+				var transfer struct {
+					From string `json:"from"`
+					To   string `json:"to"`
+				}
+				if err := json.Unmarshal(edata, &transfer); err != nil || o.router == nil {
+					continue
+				}
+				// Transfer пишет в TxCh, который читает этот же цикл
+				safego.Go("operator-transfer", func() {
+					if _, err := o.Transfer(key.userID, key.dialogID, transfer.From, transfer.To); err != nil {
+						//logger.Warn("transfer: %v", err)
+					}
+				}, safego.WithUserID(key.userID))
+				continue
+			}
+
+			if etype == "takeover" {
+				var takeover struct {
+					AgentID string `json:"agent_id"`
+				}
+				if err := json.Unmarshal(edata, &takeover); err != nil || o.router == nil {
+					continue
+				}
+				if _, err := o.router.Takeover(key.userID, key.dialogID, takeover.AgentID); err != nil {
+					//logger.Warn("takeover: %v", err)
+				}
+				continue
+			}
+
 			var receivedMsg model.Message
 			if len(edata) == 0 {
 				// пустой пакет от сервера — игнорируем
@@ -333,8 +420,10 @@ func (o *Operator) listenerSession(key opKey, s *session) {
 				continue
 			}
 
+			s.touch()
 			select {
 			case s.ch.RxCh <- receivedMsg:
+				o.trackResponse(key, s)
 				//logger.Debug("Message sent to RxCh: %+v", receivedMsg)
 			case <-s.ctx.Done():
 				return
@@ -386,10 +475,12 @@ func (o *Operator) sendMessage(baseURL string, s *session, msg model.Message) er
 
 	// Формируем конверт
 	type envelope struct {
-		UserID   uint32         `json:"user_id"`
-		DialogID uint64         `json:"dialog_id"`
-		SID      int64          `json:"sid"`
-		Msg      *model.Message `json:"msg,omitempty"`
+		UserID    uint32         `json:"user_id"`
+		DialogID  uint64         `json:"dialog_id"`
+		SID       int64          `json:"sid"`
+		AgentID   string         `json:"agent_id,omitempty"`
+		PrevAgent string         `json:"prev_agent,omitempty"` // прежний оператор для MsgTypeTransfer
+		Msg       *model.Message `json:"msg,omitempty"`
 	}
 	env := envelope{
 		UserID:   s.ch.userID,
@@ -397,6 +488,16 @@ func (o *Operator) sendMessage(baseURL string, s *session, msg model.Message) er
 		SID:      sid,
 		Msg:      &msg,
 	}
+	if o.router != nil {
+		// Нет свободного оператора с нужными навыками — без agent_id, бэкенд ставит диалог в общую очередь
+		skills := o.router.RequiredSkills(s.ch.userID, s.ch.DialogID)
+		if as, err := o.router.Assign(s.ch.userID, s.ch.DialogID, skills...); err == nil {
+			env.AgentID = as.AgentID
+			if msg.Type == MsgTypeTransfer {
+				env.PrevAgent = as.PrevAgent
+			}
+		}
+	}
 	jsonData, err := json.Marshal(env)
 	if err != nil {
 		return fmt.Errorf("failed to marshal envelope: %w", err)
@@ -431,6 +532,7 @@ func (o *Operator) AskOperator(ctx context.Context, userID uint32, dialogID uint
 	// Отправляем вопрос оператору
 	select {
 	case s.ch.TxCh <- question:
+		s.markEscalated()
 		//logger.Debug("Question sent to operator: %+v", question)
 	case <-ctx.Done():
 		return model.Message{}, ctx.Err()
@@ -463,6 +565,7 @@ func (o *Operator) SendToOperator(ctx context.Context, userID uint32, dialogID u
 
 	select {
 	case s.ch.TxCh <- question:
+		s.markEscalated()
 		//logger.Debug("Question sent to operator: %+v", question)
 		return nil
 	case <-ctx.Done():
@@ -0,0 +1,103 @@
+package operator
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Status присутствие оператора
+type Status string
+
+const (
+	StatusOnline  Status = "online"  // принимает диалоги
+	StatusAway    Status = "away"    // на связи, но новые диалоги не назначаются
+	StatusOffline Status = "offline" // не в сети
+)
+
+// presence статусы операторов по командам пользователей.
+// Пока для пользователя не сообщено ни одного статуса, операторы считаются доступными —
+// так ведут себя интеграции, которые присутствие не отслеживают.
+type presence struct {
+	mu    sync.RWMutex
+	teams map[uint32]map[string]Status // userID -> agentID -> статус
+}
+
+func (p *presence) set(userID uint32, agentID string, status Status) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.teams == nil {
+		p.teams = make(map[uint32]map[string]Status)
+	}
+	team, ok := p.teams[userID]
+	if !ok {
+		team = make(map[string]Status)
+		p.teams[userID] = team
+	}
+	team[agentID] = status
+}
+
+// online true, если оператор в сети или его статус неизвестен
+func (p *presence) online(userID uint32, agentID string) bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	status, ok := p.teams[userID][agentID]
+	return !ok || status == StatusOnline
+}
+
+// available true, если в команде есть оператор online или статусы не отслеживаются
+func (p *presence) available(userID uint32) bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	team, ok := p.teams[userID]
+	if !ok || len(team) == 0 {
+		return true
+	}
+	for _, status := range team {
+		if status == StatusOnline {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *presence) snapshot(userID uint32) map[string]Status {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	out := make(map[string]Status, len(p.teams[userID]))
+	for id, status := range p.teams[userID] {
+		out[id] = status
+	}
+	return out
+}
+
+// SetOperatorStatus задаёт присутствие оператора agentID из команды пользователя.
+// Для интеграции с одним оператором agentID может быть пустым.
+func (o *Operator) SetOperatorStatus(userID uint32, agentID string, status Status) error {
+	switch status {
+	case StatusOnline, StatusAway, StatusOffline:
+	default:
+		return fmt.Errorf("неизвестный статус оператора: %q", status)
+	}
+	o.presence.set(userID, agentID, status)
+	return nil
+}
+
+// OperatorAvailable true, если у пользователя есть оператор online
+// (или присутствие для пользователя не отслеживается)
+func (o *Operator) OperatorAvailable(userID uint32) bool {
+	return o.presence.available(userID)
+}
+
+// OperatorStatuses возвращает известные статусы операторов пользователя
+func (o *Operator) OperatorStatuses(userID uint32) map[string]Status {
+	return o.presence.snapshot(userID)
+}
+
+// OperatorFree true, если оператор может взять новый диалог прямо сейчас:
+// кто-то online и, при включённом WithRouter, у него есть свободное место
+func (o *Operator) OperatorFree(userID uint32) bool {
+	if !o.presence.available(userID) {
+		return false
+	}
+	return o.router == nil || o.router.HasCapacity(userID)
+}
@@ -0,0 +1,322 @@
+package operator
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Strategy стратегия выбора оператора из команды пользователя
+type Strategy string
+
+const (
+	RoundRobin Strategy = "round_robin" // по кругу
+	LeastBusy  Strategy = "least_busy"  // с наименьшим числом активных диалогов
+)
+
+// historyLimit сколько завершённых назначений хранить на пользователя
+const historyLimit = 1000
+
+// Agent оператор команды пользователя
+type Agent struct {
+	ID      string   `json:"id"`
+	Name    string   `json:"name"`
+	Skills  []string `json:"skills,omitempty"`   // теги навыков: "billing", "en", ...
+	MaxLoad int      `json:"max_load,omitempty"` // максимум одновременных диалогов (0 — без ограничения)
+}
+
+// Assignment запись о назначении диалога оператору
+type Assignment struct {
+	UserID     uint32    `json:"user_id"`
+	DialogID   uint64    `json:"dialog_id"`
+	AgentID    string    `json:"agent_id"`
+	Skills     []string  `json:"skills,omitempty"`     // требуемые навыки на момент назначения
+	PrevAgent  string    `json:"prev_agent,omitempty"` // предыдущий оператор при перехвате
+	AssignedAt time.Time `json:"assigned_at"`
+	ReleasedAt time.Time `json:"released_at,omitempty"`
+}
+
+// Router распределяет эскалации между операторами команды пользователя.
+// Один диалог закреплён за одним оператором до Release или перехвата (Takeover).
+type Router struct {
+	mu       sync.Mutex
+	strategy Strategy
+	teams    map[uint32]*team
+	online   func(userID uint32, agentID string) bool // фильтр присутствия (nil — все доступны)
+}
+
+type team struct {
+	agents   []*Agent               // в порядке добавления
+	load     map[string]int         // agentID -> активных диалогов
+	active   map[uint64]*Assignment // dialogID -> назначение
+	history  []Assignment           // завершённые назначения, старые первыми
+	required map[uint64][]string    // dialogID -> навыки, нужные диалогу (RequireSkills)
+	next     int                    // позиция round-robin
+}
+
+// NewRouter создаёт маршрутизатор с заданной стратегией (пустая — RoundRobin)
+func NewRouter(strategy Strategy) *Router {
+	if strategy == "" {
+		strategy = RoundRobin
+	}
+	return &Router{strategy: strategy, teams: make(map[uint32]*team)}
+}
+
+func (r *Router) team(userID uint32) *team {
+	t, ok := r.teams[userID]
+	if !ok {
+		t = &team{load: make(map[string]int), active: make(map[uint64]*Assignment), required: make(map[uint64][]string)}
+		r.teams[userID] = t
+	}
+	return t
+}
+
+// AddAgent добавляет оператора в команду пользователя или обновляет его данные
+func (r *Router) AddAgent(userID uint32, a Agent) error {
+	if a.ID == "" {
+		return fmt.Errorf("пустой id оператора")
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	t := r.team(userID)
+	for i, cur := range t.agents {
+		if cur.ID == a.ID {
+			t.agents[i] = &a
+			return nil
+		}
+	}
+	t.agents = append(t.agents, &a)
+	return nil
+}
+
+// RemoveAgent удаляет оператора из команды. Его активные диалоги освобождаются
+// и возвращаются списком для переназначения.
+func (r *Router) RemoveAgent(userID uint32, agentID string) []uint64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	t := r.team(userID)
+	for i, cur := range t.agents {
+		if cur.ID == agentID {
+			t.agents = append(t.agents[:i], t.agents[i+1:]...)
+			break
+		}
+	}
+
+	var orphaned []uint64
+	for dialogID, as := range t.active {
+		if as.AgentID == agentID {
+			orphaned = append(orphaned, dialogID)
+			t.release(dialogID)
+		}
+	}
+	sort.Slice(orphaned, func(i, j int) bool { return orphaned[i] < orphaned[j] })
+	return orphaned
+}
+
+// Agents возвращает команду пользователя
+func (r *Router) Agents(userID uint32) []Agent {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	t := r.team(userID)
+	out := make([]Agent, 0, len(t.agents))
+	for _, a := range t.agents {
+		out = append(out, *a)
+	}
+	return out
+}
+
+// Assign закрепляет диалог за оператором, владеющим всеми skills.
+// Если диалог уже назначен — возвращает текущее назначение.
+func (r *Router) Assign(userID uint32, dialogID uint64, skills ...string) (Assignment, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	t := r.team(userID)
+	if as, ok := t.active[dialogID]; ok {
+		return *as, nil
+	}
+
+	agent := t.pick(r.strategy, skills, func(a *Agent) bool { return r.online == nil || r.online(userID, a.ID) })
+	if agent == nil {
+		return Assignment{}, fmt.Errorf("нет свободного оператора для диалога %d (навыки: %v)", dialogID, skills)
+	}
+	as := &Assignment{UserID: userID, DialogID: dialogID, AgentID: agent.ID, Skills: skills, AssignedAt: time.Now()}
+	t.active[dialogID] = as
+	t.load[agent.ID]++
+	return *as, nil
+}
+
+// RequireSkills задаёт навыки, нужные оператору диалога; Operator передаёт их в Assign
+// при эскалации. Действует до Release диалога, без skills — снимает требование
+func (r *Router) RequireSkills(userID uint32, dialogID uint64, skills ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	t := r.team(userID)
+	if len(skills) == 0 {
+		delete(t.required, dialogID)
+		return
+	}
+	t.required[dialogID] = append([]string(nil), skills...)
+}
+
+// RequiredSkills навыки, заданные диалогу через RequireSkills
+func (r *Router) RequiredSkills(userID uint32, dialogID uint64) []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.team(userID).required[dialogID]...)
+}
+
+// Takeover передаёт диалог оператору agentID (перехват другим членом команды)
+func (r *Router) Takeover(userID uint32, dialogID uint64, agentID string) (Assignment, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	t := r.team(userID)
+	if t.agent(agentID) == nil {
+		return Assignment{}, fmt.Errorf("оператор %s не найден в команде пользователя %d", agentID, userID)
+	}
+
+	var prev string
+	var skills []string
+	if as, ok := t.active[dialogID]; ok {
+		if as.AgentID == agentID {
+			return *as, nil
+		}
+		prev, skills = as.AgentID, as.Skills
+		t.release(dialogID)
+	}
+	as := &Assignment{UserID: userID, DialogID: dialogID, AgentID: agentID, Skills: skills, PrevAgent: prev, AssignedAt: time.Now()}
+	t.active[dialogID] = as
+	t.load[agentID]++
+	return *as, nil
+}
+
+// Current возвращает активное назначение диалога
+func (r *Router) Current(userID uint32, dialogID uint64) (Assignment, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if as, ok := r.team(userID).active[dialogID]; ok {
+		return *as, true
+	}
+	return Assignment{}, false
+}
+
+// Release освобождает диалог (оператор завершил работу или сессия закрыта)
+func (r *Router) Release(userID uint32, dialogID uint64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	t := r.team(userID)
+	t.release(dialogID)
+	delete(t.required, dialogID)
+}
+
+// HasCapacity true, если в команде есть оператор online со свободным местом под новый диалог.
+// Для пользователя без команды возвращает true — распределение для него не настроено.
+func (r *Router) HasCapacity(userID uint32) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	t := r.team(userID)
+	if len(t.agents) == 0 {
+		return true
+	}
+	for _, a := range t.agents {
+		if (r.online == nil || r.online(userID, a.ID)) && t.eligible(a, nil) {
+			return true
+		}
+	}
+	return false
+}
+
+// Load возвращает число активных диалогов оператора
+func (r *Router) Load(userID uint32, agentID string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.team(userID).load[agentID]
+}
+
+// History возвращает завершённые назначения диалога (dialogID == 0 — все), старые первыми
+func (r *Router) History(userID uint32, dialogID uint64) []Assignment {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var out []Assignment
+	for _, as := range r.team(userID).history {
+		if dialogID == 0 || as.DialogID == dialogID {
+			out = append(out, as)
+		}
+	}
+	return out
+}
+
+func (t *team) agent(id string) *Agent {
+	for _, a := range t.agents {
+		if a.ID == id {
+			return a
+		}
+	}
+	return nil
+}
+
+func (t *team) release(dialogID uint64) {
+	as, ok := t.active[dialogID]
+	if !ok {
+		return
+	}
+	delete(t.active, dialogID)
+	if t.load[as.AgentID] > 0 {
+		t.load[as.AgentID]--
+	}
+	as.ReleasedAt = time.Now()
+	t.history = append(t.history, *as)
+	if len(t.history) > historyLimit {
+		t.history = t.history[len(t.history)-historyLimit:]
+	}
+}
+
+// pick выбирает оператора по стратегии среди подходящих по навыкам, загрузке и присутствию
+func (t *team) pick(strategy Strategy, skills []string, online func(*Agent) bool) *Agent {
+	n := len(t.agents)
+	if n == 0 {
+		return nil
+	}
+
+	switch strategy {
+	case LeastBusy:
+		var best *Agent
+		for _, a := range t.agents {
+			if !online(a) || !t.eligible(a, skills) {
+				continue
+			}
+			if best == nil || t.load[a.ID] < t.load[best.ID] {
+				best = a
+			}
+		}
+		return best
+	default: // RoundRobin
+		for i := 0; i < n; i++ {
+			a := t.agents[(t.next+i)%n]
+			if online(a) && t.eligible(a, skills) {
+				t.next = (t.next + i + 1) % n
+				return a
+			}
+		}
+		return nil
+	}
+}
+
+func (t *team) eligible(a *Agent, skills []string) bool {
+	if a.MaxLoad > 0 && t.load[a.ID] >= a.MaxLoad {
+		return false
+	}
+	for _, need := range skills {
+		found := false
+		for _, have := range a.Skills {
+			if have == need {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
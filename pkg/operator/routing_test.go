@@ -0,0 +1,178 @@
+package operator
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ikermy/AiR_Common/pkg/model"
+)
+
+func TestRouterRoundRobin(t *testing.T) {
+	r := NewRouter(RoundRobin)
+	for _, id := range []string{"a", "b", "c"} {
+		if err := r.AddAgent(1, Agent{ID: id}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var got []string
+	for d := uint64(1); d <= 4; d++ {
+		as, err := r.Assign(1, d)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, as.AgentID)
+	}
+	if want := []string{"a", "b", "c", "a"}; !equal(got, want) {
+		t.Fatalf("назначения %v, ожидали %v", got, want)
+	}
+
+	// Повторное назначение возвращает текущего оператора
+	if as, _ := r.Assign(1, 2); as.AgentID != "b" {
+		t.Fatalf("повторное назначение: %s", as.AgentID)
+	}
+}
+
+func TestRouterLeastBusyAndMaxLoad(t *testing.T) {
+	r := NewRouter(LeastBusy)
+	_ = r.AddAgent(1, Agent{ID: "a", MaxLoad: 1})
+	_ = r.AddAgent(1, Agent{ID: "b"})
+
+	first, _ := r.Assign(1, 1)
+	second, _ := r.Assign(1, 2)
+	third, _ := r.Assign(1, 3)
+	if first.AgentID != "a" || second.AgentID != "b" || third.AgentID != "b" {
+		t.Fatalf("назначения %s %s %s", first.AgentID, second.AgentID, third.AgentID)
+	}
+
+	r.Release(1, 1)
+	if next, _ := r.Assign(1, 4); next.AgentID != "a" {
+		t.Fatalf("после освобождения ожидали a, получили %s", next.AgentID)
+	}
+	if h := r.History(1, 1); len(h) != 1 || h[0].ReleasedAt.IsZero() {
+		t.Fatalf("история: %+v", h)
+	}
+}
+
+func TestRouterSkills(t *testing.T) {
+	r := NewRouter(RoundRobin)
+	_ = r.AddAgent(1, Agent{ID: "a", Skills: []string{"ru"}})
+	_ = r.AddAgent(1, Agent{ID: "b", Skills: []string{"ru", "billing"}})
+
+	as, err := r.Assign(1, 1, "billing")
+	if err != nil || as.AgentID != "b" {
+		t.Fatalf("ожидали b, получили %q (%v)", as.AgentID, err)
+	}
+	if _, err := r.Assign(1, 2, "en"); err == nil {
+		t.Fatal("ожидали ошибку: нет оператора с навыком en")
+	}
+	// Команды пользователей не пересекаются
+	if _, err := r.Assign(2, 1); err == nil {
+		t.Fatal("ожидали ошибку: у пользователя 2 нет операторов")
+	}
+}
+
+func TestSendMessageRequiredSkills(t *testing.T) {
+	r := NewRouter(RoundRobin)
+	_ = r.AddAgent(1, Agent{ID: "a", Skills: []string{"ru"}})
+	_ = r.AddAgent(1, Agent{ID: "b", Skills: []string{"ru", "billing"}})
+	o := New(context.Background(), WithRouter(r))
+	defer o.Close()
+
+	var got struct {
+		AgentID string `json:"agent_id"`
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		_ = json.NewDecoder(req.Body).Decode(&got)
+	}))
+	defer srv.Close()
+
+	s := testSession(o, 1, 5)
+	s.sidReady = make(chan struct{})
+	close(s.sidReady)
+	r.RequireSkills(1, 5, "billing")
+	if err := o.sendMessage(srv.URL, s, model.Message{}); err != nil {
+		t.Fatal(err)
+	}
+	if got.AgentID != "b" {
+		t.Fatalf("ожидали оператора с навыком billing, получили %q", got.AgentID)
+	}
+
+	r.Release(1, 5)
+	if skills := r.RequiredSkills(1, 5); len(skills) != 0 {
+		t.Fatalf("требование навыков осталось после Release: %v", skills)
+	}
+}
+
+func TestRouterTakeover(t *testing.T) {
+	r := NewRouter(RoundRobin)
+	_ = r.AddAgent(1, Agent{ID: "a"})
+	_ = r.AddAgent(1, Agent{ID: "b"})
+
+	_, _ = r.Assign(1, 1)
+	as, err := r.Takeover(1, 1, "b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if as.AgentID != "b" || as.PrevAgent != "a" {
+		t.Fatalf("перехват: %+v", as)
+	}
+	if r.Load(1, "a") != 0 || r.Load(1, "b") != 1 {
+		t.Fatalf("загрузка a=%d b=%d", r.Load(1, "a"), r.Load(1, "b"))
+	}
+	if _, err := r.Takeover(1, 1, "x"); err == nil {
+		t.Fatal("ожидали ошибку для неизвестного оператора")
+	}
+
+	if orphaned := r.RemoveAgent(1, "b"); len(orphaned) != 1 || orphaned[0] != 1 {
+		t.Fatalf("осиротевшие диалоги: %v", orphaned)
+	}
+	if _, ok := r.Current(1, 1); ok {
+		t.Fatal("диалог удалённого оператора должен быть освобождён")
+	}
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestPresence(t *testing.T) {
+	o := New(t.Context(), WithRouter(NewRouter(RoundRobin)))
+	defer o.Close()
+	_ = o.Router().AddAgent(1, Agent{ID: "a"})
+	_ = o.Router().AddAgent(1, Agent{ID: "b"})
+
+	if !o.OperatorAvailable(1) {
+		t.Fatal("без статусов операторы считаются доступными")
+	}
+	if err := o.SetOperatorStatus(1, "a", "busy"); err == nil {
+		t.Fatal("ожидали ошибку для неизвестного статуса")
+	}
+	_ = o.SetOperatorStatus(1, "a", StatusAway)
+	_ = o.SetOperatorStatus(1, "b", StatusOnline)
+	if as, err := o.Router().Assign(1, 1); err != nil || as.AgentID != "b" {
+		t.Fatalf("ожидали назначение b, получили %q (%v)", as.AgentID, err)
+	}
+
+	_ = o.SetOperatorStatus(1, "b", StatusOffline)
+	if o.OperatorAvailable(1) {
+		t.Fatal("нет операторов online")
+	}
+	if _, err := o.Router().Assign(1, 2); err == nil {
+		t.Fatal("ожидали ошибку: все операторы не в сети")
+	}
+	if !o.OperatorAvailable(2) {
+		t.Fatal("статусы пользователей не пересекаются")
+	}
+}
@@ -0,0 +1,148 @@
+package operator
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// SLAStats показатели оператора по завершённым эскалациям пользователя.
+// Без маршрутизатора (WithRouter) все диалоги учитываются под AgentID "".
+type SLAStats struct {
+	AgentID          string  `json:"agent_id"`
+	Dialogs          uint64  `json:"dialogs"`            // завершённых эскалаций
+	Responded        uint64  `json:"responded"`          // с ответом оператора
+	Timeouts         uint64  `json:"timeouts"`           // закрыто без ответа оператора
+	TimeoutRate      float64 `json:"timeout_rate"`       // Timeouts / Dialogs
+	FirstResponseAvg float64 `json:"first_response_avg"` // секунд от передачи диалога до первого ответа
+	FirstResponseMax float64 `json:"first_response_max"`
+	ResolutionAvg    float64 `json:"resolution_avg"` // секунд от передачи диалога до закрытия сессии (только с ответом)
+	ResolutionMax    float64 `json:"resolution_max"`
+}
+
+type slaCounter struct {
+	dialogs, responded, timeouts uint64
+	firstSum, firstMax           time.Duration
+	resolvedSum, resolvedMax     time.Duration
+}
+
+// slaMetrics счётчики SLA: userID -> agentID -> показатели
+type slaMetrics struct {
+	mu     sync.Mutex
+	byUser map[uint32]map[string]*slaCounter
+}
+
+func (m *slaMetrics) counter(userID uint32, agentID string) *slaCounter {
+	if m.byUser == nil {
+		m.byUser = make(map[uint32]map[string]*slaCounter)
+	}
+	agents, ok := m.byUser[userID]
+	if !ok {
+		agents = make(map[string]*slaCounter)
+		m.byUser[userID] = agents
+	}
+	c, ok := agents[agentID]
+	if !ok {
+		c = &slaCounter{}
+		agents[agentID] = c
+	}
+	return c
+}
+
+func (m *slaMetrics) firstResponse(userID uint32, agentID string, d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	c := m.counter(userID, agentID)
+	c.responded++
+	c.firstSum += d
+	c.firstMax = max(c.firstMax, d)
+}
+
+// closed учитывает завершение эскалации; d — длительность, если оператор отвечал
+func (m *slaMetrics) closed(userID uint32, agentID string, responded bool, d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	c := m.counter(userID, agentID)
+	c.dialogs++
+	if !responded {
+		c.timeouts++
+		return
+	}
+	c.resolvedSum += d
+	c.resolvedMax = max(c.resolvedMax, d)
+}
+
+func (m *slaMetrics) stats(userID uint32) []SLAStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make([]SLAStats, 0, len(m.byUser[userID]))
+	for agentID, c := range m.byUser[userID] {
+		st := SLAStats{
+			AgentID:          agentID,
+			Dialogs:          c.dialogs,
+			Responded:        c.responded,
+			Timeouts:         c.timeouts,
+			FirstResponseMax: c.firstMax.Seconds(),
+			ResolutionMax:    c.resolvedMax.Seconds(),
+		}
+		if c.dialogs > 0 {
+			st.TimeoutRate = float64(c.timeouts) / float64(c.dialogs)
+		}
+		if c.responded > 0 {
+			st.FirstResponseAvg = c.firstSum.Seconds() / float64(c.responded)
+		}
+		if resolved := c.dialogs - c.timeouts; resolved > 0 {
+			st.ResolutionAvg = c.resolvedSum.Seconds() / float64(resolved)
+		}
+		out = append(out, st)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].AgentID < out[j].AgentID })
+	return out
+}
+
+// SLAStats возвращает показатели операторов пользователя, отсортированные по AgentID
+func (o *Operator) SLAStats(userID uint32) []SLAStats {
+	return o.sla.stats(userID)
+}
+
+// agentOf текущий оператор диалога ("" без маршрутизатора или назначения)
+func (o *Operator) agentOf(key opKey) string {
+	if o.router == nil {
+		return ""
+	}
+	as, _ := o.router.Current(key.userID, key.dialogID)
+	return as.AgentID
+}
+
+// markEscalated отмечает начало ожидания оператора при первом сообщении в сессию
+func (s *session) markEscalated() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.escalatedAt.IsZero() {
+		s.escalatedAt = time.Now()
+	}
+}
+
+// trackResponse учитывает первый ответ оператора в сессии
+func (o *Operator) trackResponse(key opKey, s *session) {
+	s.mu.Lock()
+	if s.escalatedAt.IsZero() || !s.respondedAt.IsZero() {
+		s.mu.Unlock()
+		return
+	}
+	s.respondedAt = time.Now()
+	d := s.respondedAt.Sub(s.escalatedAt)
+	s.mu.Unlock()
+	o.sla.firstResponse(key.userID, o.agentOf(key), d)
+}
+
+// trackClose учитывает завершение сессии: решено, если оператор отвечал, иначе таймаут
+func (o *Operator) trackClose(key opKey, s *session) {
+	s.mu.Lock()
+	escalatedAt, responded := s.escalatedAt, !s.respondedAt.IsZero()
+	s.mu.Unlock()
+	if escalatedAt.IsZero() {
+		return
+	}
+	o.sla.closed(key.userID, o.agentOf(key), responded, time.Since(escalatedAt))
+}
@@ -0,0 +1,70 @@
+package operator
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ikermy/AiR_Common/pkg/comdb"
+	"github.com/ikermy/AiR_Common/pkg/model"
+)
+
+// testSession сессия без SSE-подключения
+func testSession(o *Operator, userID uint32, dialogID uint64) *session {
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &session{
+		ch:        OperatorCh{TxCh: make(chan model.Message, 4), RxCh: make(chan model.Message, 4), userID: userID, DialogID: dialogID},
+		ctx:       ctx,
+		cancel:    cancel,
+		idleTimer: time.NewTimer(time.Hour),
+	}
+	o.operatorChMap.Store(opKey{userID: userID, dialogID: dialogID}, s)
+	return s
+}
+
+func TestSLAStats(t *testing.T) {
+	r := NewRouter(RoundRobin)
+	_ = r.AddAgent(1, Agent{ID: "anna"})
+	o := New(context.Background(), WithRouter(r))
+	defer o.Close()
+
+	id, _ := o.SaveCannedReply(comdb.CannedReply{UserID: 1, Text: "Здравствуйте"})
+
+	// Диалог 1: оператор ответил, сессия закрыта — решено
+	testSession(o, 1, 1)
+	_, _ = r.Assign(1, 1)
+	if err := o.SendToOperator(context.Background(), 1, 1, model.Message{}); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(10 * time.Millisecond)
+	if err := o.SendCannedReply(1, 1, id, "Анна", nil); err != nil {
+		t.Fatal(err)
+	}
+	_ = o.SendCannedReply(1, 1, id, "Анна", nil) // повторный ответ не меняет время первого
+	_ = o.DeleteSession(1, 1)
+
+	// Диалог 2: ответа не было — таймаут
+	testSession(o, 1, 2)
+	_, _ = r.Assign(1, 2)
+	_ = o.SendToOperator(context.Background(), 1, 2, model.Message{})
+	_ = o.DeleteSession(1, 2)
+
+	// Сессия без сообщений оператору в статистику не попадает
+	testSession(o, 1, 3)
+	_ = o.DeleteSession(1, 3)
+
+	stats := o.SLAStats(1)
+	if len(stats) != 1 {
+		t.Fatalf("stats: %+v", stats)
+	}
+	st := stats[0]
+	if st.AgentID != "anna" || st.Dialogs != 2 || st.Responded != 1 || st.Timeouts != 1 || st.TimeoutRate != 0.5 {
+		t.Fatalf("counters: %+v", st)
+	}
+	if st.FirstResponseAvg <= 0 || st.FirstResponseAvg != st.FirstResponseMax || st.ResolutionAvg < st.FirstResponseAvg {
+		t.Fatalf("durations: %+v", st)
+	}
+	if len(o.SLAStats(2)) != 0 {
+		t.Fatalf("stats of other user: %+v", o.SLAStats(2))
+	}
+}
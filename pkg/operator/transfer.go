@@ -0,0 +1,77 @@
+package operator
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ikermy/AiR_Common/pkg/model"
+)
+
+// MsgTypeTransfer тип сообщения бэкенду о передаче диалога: конверт содержит
+// agent_id нового оператора и prev_agent прежнего, чтобы уведомить обоих
+const MsgTypeTransfer = "transfer"
+
+// TransferredMessage уведомление пользователю о смене оператора (%s — имя нового оператора)
+const TransferredMessage = "🔄 Диалог передан оператору %s"
+
+// Transfer передаёт активный диалог от оператора from оператору to той же команды.
+// Сессия оператора сохраняется, поэтому Respondent продолжает получать ответы из того же канала,
+// а новые вопросы пользователя уходят с agent_id нового оператора.
+// Бэкенд получает сообщение MsgTypeTransfer, пользователь — TransferredMessage.
+func (o *Operator) Transfer(userID uint32, dialogID uint64, from, to string) (Assignment, error) {
+	if o.router == nil {
+		return Assignment{}, fmt.Errorf("маршрутизатор операторов не задан")
+	}
+	key := opKey{userID: userID, dialogID: dialogID}
+	val, ok := o.operatorChMap.Load(key)
+	if !ok {
+		return Assignment{}, fmt.Errorf("session not found for user=%d dialog=%d", userID, dialogID)
+	}
+	s := val.(*session)
+
+	if cur, ok := o.router.Current(userID, dialogID); !ok || cur.AgentID != from {
+		return Assignment{}, fmt.Errorf("диалог %d не закреплён за оператором %s", dialogID, from)
+	}
+	if !o.presence.online(userID, to) {
+		return Assignment{}, fmt.Errorf("оператор %s не в сети", to)
+	}
+	as, err := o.router.Takeover(userID, dialogID, to)
+	if err != nil {
+		return Assignment{}, err
+	}
+
+	name := to
+	for _, a := range o.router.Agents(userID) {
+		if a.ID == to && a.Name != "" {
+			name = a.Name
+			break
+		}
+	}
+	notice := model.AssistResponse{Message: fmt.Sprintf(TransferredMessage, name)}
+
+	// Бэкенду: конверт с agent_id и prev_agent (см. sendMessage)
+	select {
+	case s.ch.TxCh <- model.Message{Type: MsgTypeTransfer, Content: notice, Name: name, Timestamp: time.Now()}:
+	case <-s.ctx.Done():
+		return as, fmt.Errorf("operator session context cancelled")
+	case <-time.After(5 * time.Second):
+		return as, fmt.Errorf("timeout while sending transfer to operator")
+	}
+
+	// Пользователю: как сообщение оператора, без выключения операторского режима
+	select {
+	case s.ch.RxCh <- model.Message{
+		Operator:  model.Operator{Operator: true, SenderName: name},
+		Type:      "assist",
+		Content:   notice,
+		Name:      name,
+		Timestamp: time.Now(),
+	}:
+		// Системное уведомление: таймер простоя не сбрасывается
+		return as, nil
+	case <-s.ctx.Done():
+		return as, fmt.Errorf("operator session context cancelled")
+	case <-time.After(5 * time.Second):
+		return as, fmt.Errorf("timeout while notifying user about transfer")
+	}
+}
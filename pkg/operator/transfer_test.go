@@ -0,0 +1,59 @@
+package operator
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ikermy/AiR_Common/pkg/model"
+)
+
+func TestTransfer(t *testing.T) {
+	r := NewRouter(RoundRobin)
+	_ = r.AddAgent(1, Agent{ID: "anna", Name: "Анна"})
+	_ = r.AddAgent(1, Agent{ID: "oleg", Name: "Олег"})
+	o := New(context.Background(), WithRouter(r))
+	defer o.Close()
+
+	if _, err := o.Transfer(1, 7, "anna", "oleg"); err == nil {
+		t.Fatal("без сессии оператора ожидали ошибку")
+	}
+
+	s := testSession(o, 1, 7)
+	if as, _ := r.Assign(1, 7); as.AgentID != "anna" {
+		t.Fatalf("назначение: %+v", as)
+	}
+	if _, err := o.Transfer(1, 7, "oleg", "anna"); err == nil {
+		t.Fatal("диалог не у oleg — ожидали ошибку")
+	}
+	_ = o.SetOperatorStatus(1, "oleg", StatusAway)
+	if _, err := o.Transfer(1, 7, "anna", "oleg"); err == nil {
+		t.Fatal("oleg не в сети — ожидали ошибку")
+	}
+	_ = o.SetOperatorStatus(1, "oleg", StatusOnline)
+
+	lastActive := s.lastActive
+	as, err := o.Transfer(1, 7, "anna", "oleg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !s.lastActive.Equal(lastActive) {
+		t.Fatal("уведомление о передаче продлило простой сессии")
+	}
+	if as.AgentID != "oleg" || as.PrevAgent != "anna" || r.Load(1, "anna") != 0 || r.Load(1, "oleg") != 1 {
+		t.Fatalf("назначение после передачи: %+v", as)
+	}
+
+	toBackend := <-s.ch.TxCh
+	if toBackend.Type != MsgTypeTransfer {
+		t.Fatalf("бэкенду: %+v", toBackend)
+	}
+	toUser := <-s.ch.RxCh
+	if toUser.Content.Message != "🔄 Диалог передан оператору Олег" || !toUser.Operator.Operator || toUser.Operator.SetOperator {
+		t.Fatalf("пользователю: %+v", toUser)
+	}
+
+	// Сессия та же: ответы нового оператора приходят в прежний канал Respondent
+	if ch := o.ReceiveFromOperator(context.Background(), 1, 7); ch != (<-chan model.Message)(s.ch.RxCh) {
+		t.Fatal("канал ответов оператора изменился")
+	}
+}
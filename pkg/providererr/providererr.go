@@ -0,0 +1,165 @@
+// Package providererr типизированные ошибки AI-провайдеров. HTTP-клиенты провайдеров
+// возвращают *StatusError, класс которого проверяется через errors.Is / errors.As:
+//
+//	switch {
+//	case errors.Is(err, providererr.ErrAuth):        // ключ недействителен
+//	case errors.Is(err, providererr.ErrQuota):       // баланс или квота исчерпаны
+//	case providererr.IsRateLimited(err):             // 429, RetryAfter — подсказка провайдера
+//	case errors.Is(err, providererr.ErrUnavailable): // 5xx, можно повторить
+//	}
+package providererr
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ikermy/AiR_Common/pkg/mode"
+)
+
+var (
+	ErrAuth           = errors.New("ключ API недействителен или нет доступа")
+	ErrQuota          = errors.New("квота или баланс провайдера исчерпаны")
+	ErrContentBlocked = errors.New("запрос или ответ заблокирован фильтром провайдера")
+	ErrContextTooLong = errors.New("запрос не помещается в контекст модели")
+	ErrUnavailable    = errors.New("провайдер временно недоступен")
+)
+
+// ErrRateLimited превышен лимит запросов (429). RetryAfter — сколько просит подождать
+// провайдер, меньше 0 — не сообщил
+type ErrRateLimited struct {
+	RetryAfter time.Duration
+}
+
+func (e *ErrRateLimited) Error() string {
+	if e.RetryAfter >= 0 {
+		return fmt.Sprintf("превышен лимит запросов провайдера, повтор через %v", e.RetryAfter)
+	}
+	return "превышен лимит запросов провайдера"
+}
+
+// StatusError ответ API провайдера с кодом ошибки; Unwrap возвращает класс ошибки
+// (ErrAuth, ErrQuota, *ErrRateLimited, ...), nil — ошибка не классифицирована (400, 404)
+type StatusError struct {
+	Provider   string // пусто — имя провайдера есть в контексте ошибки выше
+	StatusCode int
+	Body       string
+	Kind       error
+	// RetryAfter время из Retry-After (для ErrQuota — когда квота восстановится), меньше 0 — не сообщил
+	RetryAfter time.Duration
+}
+
+func (e *StatusError) Error() string {
+	if e.Provider == "" {
+		return fmt.Sprintf("API вернул статус %d: %s", e.StatusCode, e.Body)
+	}
+	return fmt.Sprintf("%s API вернул статус %d: %s", e.Provider, e.StatusCode, e.Body)
+}
+
+func (e *StatusError) Unwrap() error {
+	return e.Kind
+}
+
+// Маркеры в теле ответа, по которым один HTTP-статус делится на классы
+var (
+	// Дневная квота или оплата: 429 с ними — не лимит запросов, повтор через секунды не поможет
+	quotaMarkers = []string{
+		"insufficient_quota", "billing", "payment required", "credit balance",
+		"exceeded your current quota", "not enough funds", "quota exceeded for the day",
+		"per day", "perday", "daily limit", "daily quota",
+	}
+	contextMarkers = []string{
+		"context_length_exceeded", "maximum context length", "context window",
+		"input token count", "too many tokens", "prompt is too long", "tokens_limit_reached",
+	}
+	blockedMarkers = []string{
+		"content_filter", "content_policy", "content policy", "responsible ai",
+		"blocklist", "prohibited_content", "safety system", "safety filter",
+		"blocked by safety", "blocked due to safety",
+	}
+)
+
+// New ошибка ответа API со статусом status; класс определяется по статусу и телу ответа
+func New(provider string, status int, header http.Header, body []byte) error {
+	text := strings.TrimSpace(string(body))
+	retryAfter := mode.ParseRetryAfter(header.Get("Retry-After"))
+	return &StatusError{Provider: provider, StatusCode: status, Body: text, Kind: classify(status, retryAfter, text), RetryAfter: retryAfter}
+}
+
+// QuotaExhausted ответ 429 из-за исчерпанной квоты или оплаты, а не лимита запросов:
+// повтор через секунды не поможет
+func QuotaExhausted(status int, body []byte) bool {
+	return status == http.StatusTooManyRequests && containsAny(strings.ToLower(string(body)), quotaMarkers)
+}
+
+// classify класс ошибки: сначала по статусу, тело уточняет 429 и 4xx
+func classify(status int, retryAfter time.Duration, body string) error {
+	lower := strings.ToLower(body)
+	switch {
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		return ErrAuth
+	case status == http.StatusPaymentRequired:
+		return ErrQuota
+	case status == http.StatusTooManyRequests:
+		if containsAny(lower, quotaMarkers) {
+			return ErrQuota
+		}
+		return &ErrRateLimited{RetryAfter: retryAfter}
+	case status >= http.StatusInternalServerError:
+		return ErrUnavailable
+	case containsAny(lower, contextMarkers) || status == http.StatusRequestEntityTooLarge:
+		return ErrContextTooLong
+	case containsAny(lower, blockedMarkers):
+		return ErrContentBlocked
+	case containsAny(lower, quotaMarkers):
+		return ErrQuota
+	}
+	return nil
+}
+
+func containsAny(s string, markers []string) bool {
+	for _, m := range markers {
+		if strings.Contains(s, m) {
+			return true
+		}
+	}
+	return false
+}
+
+// Blocked ответ заблокирован фильтром провайдера без HTTP-ошибки (blockReason Gemini,
+// finish_reason content_filter); reason — причина от провайдера
+func Blocked(provider, reason string) error {
+	return fmt.Errorf("%s: %w (%s)", provider, ErrContentBlocked, reason)
+}
+
+// IsRateLimited превышен ли лимит запросов
+func IsRateLimited(err error) bool {
+	var rl *ErrRateLimited
+	return errors.As(err, &rl)
+}
+
+// RetryAfter время ожидания, которое сообщил провайдер в ошибке 429: до сброса лимита
+// запросов или до восстановления квоты
+func RetryAfter(err error) (time.Duration, bool) {
+	var rl *ErrRateLimited
+	if errors.As(err, &rl) && rl.RetryAfter >= 0 {
+		return rl.RetryAfter, true
+	}
+	var se *StatusError
+	if errors.As(err, &se) && se.Kind == ErrQuota && se.RetryAfter >= 0 {
+		return se.RetryAfter, true
+	}
+	return 0, false
+}
+
+// IsLimit лимит или квота провайдера: повтор запроса сейчас не поможет
+func IsLimit(err error) bool {
+	return errors.Is(err, ErrQuota) || IsRateLimited(err)
+}
+
+// IsTemporary ошибку можно повторить: 5xx провайдера
+func IsTemporary(err error) bool {
+	return errors.Is(err, ErrUnavailable)
+}
@@ -0,0 +1,64 @@
+package providererr
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestNew_Classify(t *testing.T) {
+	cases := []struct {
+		status int
+		body   string
+		want   error
+	}{
+		{401, `{"error":"invalid api key"}`, ErrAuth},
+		{429, `{"error":{"code":"insufficient_quota"}}`, ErrQuota},
+		{402, ``, ErrQuota},
+		{503, `overloaded`, ErrUnavailable},
+		{400, `{"error":{"code":"context_length_exceeded"}}`, ErrContextTooLong},
+		{400, `{"error":{"code":"content_filter"}}`, ErrContentBlocked},
+		{404, `not found`, nil},
+		{500, `billing service unavailable`, ErrUnavailable},
+		{429, `Quota exceeded for metric: GenerateRequestsPerMinutePerProjectPerModel`, nil},
+		{429, `Quota exceeded for metric: GenerateRequestsPerDayPerProjectPerModel`, ErrQuota},
+		{400, `invalid safety_settings threshold`, nil},
+		{400, `Your request was rejected as a result of our safety system`, ErrContentBlocked},
+	}
+	for _, c := range cases {
+		err := New("Test", c.status, http.Header{}, []byte(c.body))
+		got := errors.Unwrap(err)
+		if c.status == 429 && c.want == nil {
+			if !IsRateLimited(err) {
+				t.Errorf("%d %s: класс %v, want rate limit", c.status, c.body, got)
+			}
+			continue
+		}
+		if got != c.want {
+			t.Errorf("%d %s: класс %v, want %v", c.status, c.body, got, c.want)
+		}
+	}
+
+	header := http.Header{}
+	header.Set("Retry-After", "12")
+	err := fmt.Errorf("запрос: %w", New("Test", 429, header, []byte("slow down")))
+	if d, ok := RetryAfter(err); !ok || d != 12*time.Second || !IsLimit(err) {
+		t.Errorf("RetryAfter = %v %v", d, ok)
+	}
+	if err.Error() != "запрос: Test API вернул статус 429: slow down" {
+		t.Errorf("текст ошибки: %s", err)
+	}
+
+	// Квота в ответе 429: не лимит запросов, но подсказка Retry-After сохраняется
+	header.Set("Retry-After", "3600")
+	err = New("Test", 429, header, []byte(`{"error":{"message":"daily quota exhausted"}}`))
+	if d, ok := RetryAfter(err); !errors.Is(err, ErrQuota) || IsRateLimited(err) || !ok || d != time.Hour {
+		t.Errorf("квота: %v, RetryAfter = %v %v", err, d, ok)
+	}
+
+	if blocked := Blocked("Google", "SAFETY"); !errors.Is(blocked, ErrContentBlocked) {
+		t.Error("Blocked не относится к ErrContentBlocked")
+	}
+}
@@ -0,0 +1,162 @@
+// Package redact — замена персональных данных (телефоны, email, номера карт, свои выражения)
+// обратимыми токенами до отправки текста провайдерам моделей. Токены стабильны в пределах
+// диалога, поэтому модель видит «[PHONE_1]» в вопросе и в истории одинаково,
+// а ответ модели персонализируется обратно локально (Restore).
+package redact
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// Rule правило замены: совпадения Pattern заменяются токенами [Name_N].
+// Valid (может быть nil) дополнительно проверяет совпадение, например контрольную сумму карты
+type Rule struct {
+	Name    string
+	Pattern *regexp.Regexp
+	Valid   func(match string) bool
+}
+
+// Встроенные правила
+var (
+	Phone = Rule{
+		Name:    "PHONE",
+		Pattern: regexp.MustCompile(`(?:\+\d{1,3}|\b8)[\s\-(]*\d{3}[\s\-)]*\d{3}[\s\-]*\d{2}[\s\-]*\d{2}\b`),
+	}
+	Email = Rule{
+		Name:    "EMAIL",
+		Pattern: regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`),
+	}
+	Card = Rule{
+		Name:    "CARD",
+		Pattern: regexp.MustCompile(`\b\d(?:[ \-]?\d){12,18}\b`),
+		Valid:   luhn,
+	}
+)
+
+// DefaultRules телефоны, email и номера карт
+var DefaultRules = []Rule{Card, Phone, Email}
+
+// Redactor заменяет персональные данные токенами и хранит соответствия по диалогам
+type Redactor struct {
+	rules  []Rule
+	vaults sync.Map // key: uint64 (dialogID), value: *vault
+}
+
+type vault struct {
+	mu      sync.Mutex
+	byValue map[string]string // значение -> токен
+	byToken map[string]string // токен -> значение
+	counter map[string]int    // последний номер токена по правилу
+}
+
+// New создаёт Redactor; без правил используются DefaultRules
+func New(rules ...Rule) *Redactor {
+	if len(rules) == 0 {
+		rules = DefaultRules
+	}
+	return &Redactor{rules: rules}
+}
+
+// CustomRule правило из регулярного выражения пользователя (номер договора, паспорт и т.п.)
+func CustomRule(name, expr string) (Rule, error) {
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return Rule{}, fmt.Errorf("некорректное выражение правила %s: %w", name, err)
+	}
+	return Rule{Name: strings.ToUpper(name), Pattern: re}, nil
+}
+
+// Redact заменяет персональные данные в text токенами диалога
+func (r *Redactor) Redact(dialogID uint64, text string) string {
+	if r == nil || text == "" {
+		return text
+	}
+	v := r.vault(dialogID)
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	for _, rule := range r.rules {
+		text = rule.Pattern.ReplaceAllStringFunc(text, func(match string) string {
+			if rule.Valid != nil && !rule.Valid(match) {
+				return match
+			}
+			if token, ok := v.byValue[match]; ok {
+				return token
+			}
+			v.counter[rule.Name]++
+			token := fmt.Sprintf("[%s_%d]", rule.Name, v.counter[rule.Name])
+			v.byValue[match] = token
+			v.byToken[token] = match
+			return token
+		})
+	}
+	return text
+}
+
+// Restore возвращает исходные значения вместо токенов диалога
+func (r *Redactor) Restore(dialogID uint64, text string) string {
+	if r == nil || text == "" || !strings.Contains(text, "[") {
+		return text
+	}
+	value, ok := r.vaults.Load(dialogID)
+	if !ok {
+		return text
+	}
+	v := value.(*vault)
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	pairs := make([]string, 0, 2*len(v.byToken))
+	for token, original := range v.byToken {
+		pairs = append(pairs, token, original)
+	}
+	return strings.NewReplacer(pairs...).Replace(text)
+}
+
+// Forget удаляет соответствия диалога (при завершении диалога)
+func (r *Redactor) Forget(dialogID uint64) {
+	if r != nil {
+		r.vaults.Delete(dialogID)
+	}
+}
+
+func (r *Redactor) vault(dialogID uint64) *vault {
+	if value, ok := r.vaults.Load(dialogID); ok {
+		return value.(*vault)
+	}
+	value, _ := r.vaults.LoadOrStore(dialogID, &vault{
+		byValue: make(map[string]string),
+		byToken: make(map[string]string),
+		counter: make(map[string]int),
+	})
+	return value.(*vault)
+}
+
+// luhn проверяет контрольную сумму номера карты, чтобы не прятать произвольные длинные числа
+func luhn(number string) bool {
+	var digits []int
+	for _, c := range number {
+		if unicode.IsDigit(c) {
+			digits = append(digits, int(c-'0'))
+		}
+	}
+	if len(digits) < 13 {
+		return false
+	}
+	sum := 0
+	for i := len(digits) - 1; i >= 0; i-- {
+		d := digits[i]
+		if (len(digits)-1-i)%2 == 1 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+	}
+	return sum%10 == 0
+}
@@ -0,0 +1,73 @@
+package redact
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRedactAndRestore(t *testing.T) {
+	r := New()
+	in := "Позвоните на +7 (900) 123-45-67 или 8 900 765 43 21, почта ivan@example.com, карта 4111 1111 1111 1111"
+
+	out := r.Redact(1, in)
+	for _, leaked := range []string{"123-45-67", "765 43 21", "ivan@example.com", "4111 1111"} {
+		if strings.Contains(out, leaked) {
+			t.Fatalf("значение %q не замаскировано: %s", leaked, out)
+		}
+	}
+	for _, token := range []string{"[PHONE_1]", "[PHONE_2]", "[EMAIL_1]", "[CARD_1]"} {
+		if !strings.Contains(out, token) {
+			t.Fatalf("нет токена %s: %s", token, out)
+		}
+	}
+
+	if got := r.Restore(1, out); got != in {
+		t.Fatalf("Restore = %q, ожидалось %q", got, in)
+	}
+}
+
+func TestRedactStableTokensPerDialog(t *testing.T) {
+	r := New()
+	first := r.Redact(1, "почта ivan@example.com")
+	second := r.Redact(1, "повторю: ivan@example.com, и ещё petr@example.com")
+	if first != "почта [EMAIL_1]" || second != "повторю: [EMAIL_1], и ещё [EMAIL_2]" {
+		t.Fatalf("токены нестабильны: %q, %q", first, second)
+	}
+
+	// Другой диалог — своя нумерация и свои значения
+	if got := r.Restore(2, "[EMAIL_1]"); got != "[EMAIL_1]" {
+		t.Fatalf("токен чужого диалога восстановлен: %q", got)
+	}
+
+	r.Forget(1)
+	if got := r.Restore(1, "[EMAIL_1]"); got != "[EMAIL_1]" {
+		t.Fatalf("после Forget токен восстановлен: %q", got)
+	}
+}
+
+func TestRedactSkipsNonPII(t *testing.T) {
+	r := New()
+	for _, in := range []string{
+		"заказ 1234567890123 от 2024-05-01 12:30:45", // не проходит проверку Луна
+		"id 9001234567", // нет кода страны или ведущей 8
+	} {
+		if got := r.Redact(1, in); got != in {
+			t.Fatalf("Redact(%q) = %q", in, got)
+		}
+	}
+}
+
+func TestCustomRule(t *testing.T) {
+	rule, err := CustomRule("contract", `Д-\d{6}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := New(append(DefaultRules, rule)...)
+	if got := r.Redact(1, "договор Д-123456"); got != "договор [CONTRACT_1]" {
+		t.Fatalf("Redact = %q", got)
+	}
+
+	if _, err := CustomRule("bad", `(`); err == nil {
+		t.Fatal("ожидалась ошибка некорректного выражения")
+	}
+}
@@ -0,0 +1,121 @@
+// Package safego запускает горутины с перехватом паники.
+//
+// Паника в обработчике действия или провайдере не должна ронять весь процесс:
+// Go перехватывает её, пишет в лог со стеком и, при необходимости, перезапускает функцию.
+package safego
+
+import (
+	"fmt"
+	"runtime/debug"
+	"time"
+
+	"github.com/ikermy/AiR_Common/pkg/logger"
+)
+
+type config struct {
+	maxRestarts int             // 0 — не перезапускать, < 0 — без ограничения
+	delay       time.Duration   // пауза перед перезапуском
+	userID      uint32          // для поля user_id в логе
+	onPanic     func(rec any)   // колбэк после перехвата паники
+	done        <-chan struct{} // при закрытии перезапуски прекращаются
+}
+
+// Option настройка запуска горутины
+type Option func(*config)
+
+// WithRestart перезапускает fn после паники не более max раз (max < 0 — без ограничения)
+func WithRestart(max int, delay time.Duration) Option {
+	return func(c *config) {
+		c.maxRestarts = max
+		c.delay = delay
+	}
+}
+
+// WithUserID добавляет user_id в запись лога о панике
+func WithUserID(userID uint32) Option {
+	return func(c *config) {
+		c.userID = userID
+	}
+}
+
+// WithOnPanic вызывает fn после перехвата паники (например, чтобы отправить ошибку в errCh)
+func WithOnPanic(fn func(rec any)) Option {
+	return func(c *config) {
+		c.onPanic = fn
+	}
+}
+
+// WithDone прекращает перезапуски после закрытия done (обычно ctx.Done())
+func WithDone(done <-chan struct{}) Option {
+	return func(c *config) {
+		c.done = done
+	}
+}
+
+// Go запускает fn в отдельной горутине с перехватом паники
+func Go(name string, fn func(), opts ...Option) {
+	c := &config{}
+	for _, opt := range opts {
+		opt(c)
+	}
+	go loop(name, fn, c)
+}
+
+// Run выполняет fn в текущей горутине с перехватом паники.
+// Возвращает ошибку с описанием паники, если она произошла.
+func Run(name string, fn func(), opts ...Option) error {
+	c := &config{}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return run(name, fn, c)
+}
+
+func loop(name string, fn func(), c *config) {
+	for restarts := 0; ; restarts++ {
+		if err := run(name, fn, c); err == nil {
+			return
+		}
+		if c.maxRestarts == 0 || (c.maxRestarts > 0 && restarts >= c.maxRestarts) {
+			return
+		}
+
+		logger.L().Warn("перезапуск горутины после паники",
+			"goroutine", name, "restart", restarts+1, logger.UserID(c.userID))
+
+		if c.delay > 0 {
+			select {
+			case <-c.done:
+				return
+			case <-time.After(c.delay):
+			}
+		} else if c.done != nil {
+			select {
+			case <-c.done:
+				return
+			default:
+			}
+		}
+	}
+}
+
+func run(name string, fn func(), c *config) (err error) {
+	defer func() {
+		rec := recover()
+		if rec == nil {
+			return
+		}
+		err = fmt.Errorf("паника в горутине %s: %v", name, rec)
+		logger.L().Error("паника в горутине",
+			"goroutine", name, "panic", fmt.Sprint(rec), "stack", string(debug.Stack()), logger.UserID(c.userID))
+		if c.onPanic != nil {
+			// Колбэк не должен повторно уронить процесс (например, отправка в закрытый канал)
+			func() {
+				defer func() { _ = recover() }()
+				c.onPanic(rec)
+			}()
+		}
+	}()
+	fn()
+	return nil
+}
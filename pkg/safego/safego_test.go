@@ -0,0 +1,62 @@
+package safego
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRun_RecoversPanic(t *testing.T) {
+	var called atomic.Bool
+	err := Run("test", func() { panic("boom") }, WithOnPanic(func(rec any) {
+		called.Store(rec == "boom")
+	}))
+	if err == nil {
+		t.Fatalf("expected error from panic")
+	}
+	if !called.Load() {
+		t.Fatalf("onPanic not called with panic value")
+	}
+}
+
+func TestGo_RestartsAfterPanic(t *testing.T) {
+	var runs atomic.Int32
+	done := make(chan struct{})
+
+	Go("test", func() {
+		if runs.Add(1) < 3 {
+			panic("again")
+		}
+		close(done)
+	}, WithRestart(5, time.Millisecond))
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("goroutine was not restarted, runs=%d", runs.Load())
+	}
+}
+
+func TestGo_RestartLimit(t *testing.T) {
+	var runs atomic.Int32
+	Go("test", func() {
+		runs.Add(1)
+		panic("always")
+	}, WithRestart(2, 0))
+
+	time.Sleep(50 * time.Millisecond)
+	if got := runs.Load(); got != 3 {
+		t.Fatalf("expected 1 run + 2 restarts, got %d", got)
+	}
+}
+
+func TestGo_OnPanicCallbackMayPanic(t *testing.T) {
+	ch := make(chan int)
+	close(ch)
+	err := Run("test", func() { panic("boom") }, WithOnPanic(func(any) {
+		ch <- 1 // отправка в закрытый канал не должна уронить процесс
+	}))
+	if err == nil {
+		t.Fatalf("expected error from panic")
+	}
+}
@@ -0,0 +1,96 @@
+package startpoint
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ikermy/AiR_Common/pkg/comdb"
+	"github.com/ikermy/AiR_Common/pkg/logger"
+	"github.com/ikermy/AiR_Common/pkg/model"
+	"github.com/ikermy/AiR_Common/pkg/safego"
+	"github.com/ikermy/AiR_Common/pkg/webhook"
+)
+
+// AnalyticsStore хранилище дневных показателей ассистентов (реализуется *comdb.DB)
+type AnalyticsStore interface {
+	AddAssistantStats(s comdb.AssistantDayStats) error
+	GetAssistantStats(userID uint32, from, to time.Time) ([]comdb.AssistantDayStats, error)
+}
+
+// WithAnalytics считает по ассистентам и дням начатые диалоги, диалоги, достигшие цели,
+// число ходов пользователя до цели и запросы оператора. Счётчики берутся из событий
+// жизненного цикла диалога, поэтому работают и без WithEventBus/WithWebhooks.
+// Показатели читаются AssistantStats
+func WithAnalytics(store AnalyticsStore) Option {
+	return func(s *Start) {
+		if store != nil {
+			s.analytics = &analytics{store: store}
+		}
+	}
+}
+
+type analytics struct {
+	store   AnalyticsStore
+	dialogs sync.Map // uint64 (treadId) -> *dialogStats
+}
+
+// dialogStats счётчики активного диалога
+type dialogStats struct {
+	turns   atomic.Int32
+	reached atomic.Bool // цель засчитана: повторные ответы с target не считаются
+}
+
+func (a *analytics) dialog(treadId uint64) *dialogStats {
+	v, _ := a.dialogs.LoadOrStore(treadId, &dialogStats{})
+	return v.(*dialogStats)
+}
+
+// countTurn учитывает ход пользователя
+func (s *Start) countTurn(treadId uint64) {
+	if s.analytics != nil {
+		s.analytics.dialog(treadId).turns.Add(1)
+	}
+}
+
+// recordAnalytics учитывает событие диалога; запись в хранилище не задерживает ответ
+func (s *Start) recordAnalytics(u *model.RespModel, treadId uint64, event string) {
+	a := s.analytics
+	if a == nil {
+		return
+	}
+	delta := comdb.AssistantDayStats{UserID: u.Assist.UserID, AssistName: u.Assist.AssistName, Day: time.Now()}
+	switch event {
+	case webhook.EventDialogStarted:
+		delta.Dialogs = 1
+	case webhook.EventTargetReached:
+		d := a.dialog(treadId)
+		if !d.reached.CompareAndSwap(false, true) {
+			return
+		}
+		delta.Targets, delta.TargetTurns = 1, int(d.turns.Load())
+	case webhook.EventOperatorRequested:
+		delta.OperatorRequests = 1
+	case webhook.EventDialogEnded:
+		a.dialogs.Delete(treadId)
+		return
+	default:
+		return
+	}
+
+	safego.Go("analytics.add", func() {
+		if err := a.store.AddAssistantStats(delta); err != nil {
+			logger.ForDialog(delta.UserID, treadId).Warn("analytics: показатели не сохранены", "event", event, "err", err)
+		}
+	}, safego.WithUserID(u.Assist.UserID))
+}
+
+// AssistantStats показатели ассистентов пользователя по дням с from по to включительно:
+// конверсия в цель, среднее число ходов до цели и запросы оператора
+func (s *Start) AssistantStats(userID uint32, from, to time.Time) ([]comdb.AssistantDayStats, error) {
+	if s.analytics == nil {
+		return nil, fmt.Errorf("аналитика не подключена")
+	}
+	return s.analytics.store.GetAssistantStats(userID, from, to)
+}
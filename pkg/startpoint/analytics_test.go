@@ -0,0 +1,69 @@
+package startpoint
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ikermy/AiR_Common/pkg/comdb"
+	"github.com/ikermy/AiR_Common/pkg/model"
+	"github.com/ikermy/AiR_Common/pkg/webhook"
+)
+
+// chanAnalytics — AnalyticsStore, передающий приращения в канал
+type chanAnalytics struct {
+	added chan comdb.AssistantDayStats
+}
+
+func (c *chanAnalytics) AddAssistantStats(s comdb.AssistantDayStats) error {
+	c.added <- s
+	return nil
+}
+
+func (c *chanAnalytics) GetAssistantStats(uint32, time.Time, time.Time) ([]comdb.AssistantDayStats, error) {
+	return nil, nil
+}
+
+func TestAnalytics_TargetTurnsAndOperator(t *testing.T) {
+	store := &chanAnalytics{added: make(chan comdb.AssistantDayStats, 8)}
+	s := &Start{}
+	WithAnalytics(store)(s)
+	u := &model.RespModel{Assist: model.Assistant{UserID: 2, AssistName: "Продажи"}}
+
+	s.emit(u, 1, 9, webhook.EventDialogStarted, nil)
+	s.countTurn(9)
+	s.countTurn(9)
+	s.countTurn(9)
+	s.emit(u, 1, 9, webhook.EventOperatorRequested, nil)
+	s.emit(u, 1, 9, webhook.EventTargetReached, nil)
+	s.emit(u, 1, 9, webhook.EventTargetReached, nil) // цель диалога засчитывается один раз
+	s.emit(u, 1, 9, webhook.EventDialogEnded, nil)
+
+	var total comdb.AssistantDayStats
+	for range 3 {
+		select {
+		case d := <-store.added:
+			if d.AssistName != "Продажи" || d.UserID != 2 {
+				t.Fatalf("unexpected delta: %+v", d)
+			}
+			total.Dialogs += d.Dialogs
+			total.Targets += d.Targets
+			total.TargetTurns += d.TargetTurns
+			total.OperatorRequests += d.OperatorRequests
+		case <-time.After(2 * time.Second):
+			t.Fatal("stats not recorded")
+		}
+	}
+	select {
+	case d := <-store.added:
+		t.Fatalf("extra delta: %+v", d)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	total.Derive()
+	if total.Dialogs != 1 || total.Targets != 1 || total.TurnsToTarget != 3 || total.OperatorRequests != 1 || total.Conversion != 100 {
+		t.Fatalf("totals: %+v", total)
+	}
+	if _, ok := s.analytics.dialogs.Load(uint64(9)); ok {
+		t.Fatal("dialog counters not released on end")
+	}
+}
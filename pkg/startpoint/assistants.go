@@ -0,0 +1,215 @@
+package startpoint
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/ikermy/AiR_Common/pkg/logger"
+	"github.com/ikermy/AiR_Common/pkg/model"
+)
+
+// AssistantRoute ассистент пользователя и правила, по которым ему передаётся диалог
+type AssistantRoute struct {
+	ID       string // ключ ассистента у пользователя; пусто — Assist.AssistId
+	Assist   model.Assistant
+	Command  string   // команда выбора ("/sales"); пусто — без команды
+	Label    string   // кнопка меню; пусто — в меню не показывается
+	Keywords []string // слова намерения в первом сообщении (без учёта регистра)
+}
+
+// key ключ, за которым закрепляется диалог
+func (r AssistantRoute) key() string {
+	if r.ID != "" {
+		return r.ID
+	}
+	return r.Assist.AssistId
+}
+
+// AssistantSource ассистенты пользователя; первый — ассистент по умолчанию
+type AssistantSource func(userID uint32) ([]AssistantRoute, error)
+
+// IntentClassifier ключ ассистента (AssistantRoute.ID или AssistId) для первого сообщения
+// диалога (например, запросом к модели);
+// пустая строка — намерение не определено
+type IntentClassifier func(userID uint32, text string, routes []AssistantRoute) (string, error)
+
+// AssistantBindingStore — хранилище привязки диалога к ассистенту (реализуется *comdb.DB)
+type AssistantBindingStore interface {
+	SaveDialogAssistant(userID uint32, dialogID uint64, assistID string) error
+	GetDialogAssistant(dialogID uint64) (string, error)
+}
+
+// AssistantRouting выбор ассистента диалога, когда у пользователя их несколько
+type AssistantRouting struct {
+	Source   AssistantSource
+	Classify IntentClassifier      // nil — только команды и ключевые слова
+	Store    AssistantBindingStore // nil — привязка только в памяти процесса
+	Menu     bool                  // не распознано — показать меню вместо ассистента по умолчанию
+	MenuText string                // текст меню; пусто — AssistantMenuMessage
+}
+
+// AssistantMenuMessage текст меню выбора ассистента по умолчанию
+var AssistantMenuMessage = "Выберите, с кем продолжить разговор:"
+
+// Способ выбора ассистента (AssistantChoice.By)
+const (
+	RouteBound      = "bound"      // диалог уже привязан
+	RouteCommand    = "command"    // команда или кнопка меню
+	RouteKeyword    = "keyword"    // ключевые слова намерения
+	RouteClassifier = "classifier" // IntentClassifier
+	RouteDefault    = "default"    // первый ассистент пользователя
+)
+
+// AssistantChoice результат выбора ассистента для диалога
+type AssistantChoice struct {
+	ID     string // ключ ассистента (AssistantRoute.ID или AssistId)
+	Assist model.Assistant
+	By     string                // Route*; пусто — ассистент не выбран, показать Menu
+	Menu   *model.AssistResponse // меню выбора с кнопками-командами
+}
+
+type assistantRouter struct {
+	AssistantRouting
+	bound sync.Map // key: uint64 (dialogID), value: string (ключ ассистента)
+}
+
+// WithAssistantRouting несколько ассистентов у одного пользователя: диалог получает
+// ассистента по команде (Command или кнопке меню), ключевым словам, IntentClassifier
+// или первого из Source. Выбор закрепляется за диалогом (RouteAssistant)
+func WithAssistantRouting(r AssistantRouting) Option {
+	return func(s *Start) {
+		s.assistants = &assistantRouter{AssistantRouting: r}
+	}
+}
+
+// RouteAssistant выбирает ассистента для сообщения text диалога. Привязанный диалог
+// остаётся у своего ассистента; новый привязывается к выбранному. Приложение передаёт
+// Assist выбранного варианта в Model.GetOrSetRespGPT
+func (s *Start) RouteAssistant(userID uint32, dialogID uint64, text string) (AssistantChoice, error) {
+	r := s.assistants
+	if r == nil || r.Source == nil {
+		return AssistantChoice{}, fmt.Errorf("выбор ассистента не подключён")
+	}
+	routes, err := r.Source(userID)
+	if err != nil {
+		return AssistantChoice{}, fmt.Errorf("ошибка чтения ассистентов пользователя %d: %w", userID, err)
+	}
+	if len(routes) == 0 {
+		return AssistantChoice{}, fmt.Errorf("у пользователя %d нет ассистентов", userID)
+	}
+
+	if id := r.boundTo(dialogID); id != "" {
+		if route, ok := findRoute(routes, id); ok {
+			return AssistantChoice{ID: id, Assist: route.Assist, By: RouteBound}, nil
+		}
+		// Ассистент удалён — диалог выбирает заново
+	}
+
+	choice, ok := r.match(userID, dialogID, text, routes)
+	if !ok {
+		if menu := r.menu(routes); r.Menu && menu != nil {
+			return AssistantChoice{Menu: menu}, nil
+		}
+		choice = AssistantChoice{ID: routes[0].key(), Assist: routes[0].Assist, By: RouteDefault}
+	}
+	r.bind(userID, dialogID, choice.ID)
+	return choice, nil
+}
+
+// match ассистент по команде, ключевым словам или классификатору
+func (r *assistantRouter) match(userID uint32, dialogID uint64, text string, routes []AssistantRoute) (AssistantChoice, bool) {
+	text = strings.TrimSpace(text)
+	lower := strings.ToLower(text)
+
+	cmd, _, _ := strings.Cut(lower, " ")
+	for _, route := range routes {
+		if route.Command != "" && cmd == strings.ToLower(route.Command) {
+			return AssistantChoice{ID: route.key(), Assist: route.Assist, By: RouteCommand}, true
+		}
+	}
+	for _, route := range routes {
+		for _, kw := range route.Keywords {
+			if kw != "" && strings.Contains(lower, strings.ToLower(kw)) {
+				return AssistantChoice{ID: route.key(), Assist: route.Assist, By: RouteKeyword}, true
+			}
+		}
+	}
+	if r.Classify != nil && text != "" {
+		id, err := r.Classify(userID, text, routes)
+		if err != nil {
+			logger.ForDialog(userID, dialogID).Warn("assistants: ошибка классификатора намерения", "err", err)
+		} else if route, ok := findRoute(routes, id); ok {
+			return AssistantChoice{ID: id, Assist: route.Assist, By: RouteClassifier}, true
+		}
+	}
+	return AssistantChoice{}, false
+}
+
+// menu кнопки выбора ассистентов с Label и Command; nil — меню не из чего собрать
+func (r *assistantRouter) menu(routes []AssistantRoute) *model.AssistResponse {
+	var buttons []model.Button
+	for _, route := range routes {
+		if route.Label != "" && route.Command != "" {
+			buttons = append(buttons, model.Button{Text: route.Label, Payload: route.Command})
+		}
+	}
+	if len(buttons) < 2 {
+		return nil
+	}
+	text := r.MenuText
+	if text == "" {
+		text = AssistantMenuMessage
+	}
+	return &model.AssistResponse{Message: text, Action: model.Action{QuickReplies: buttons}}
+}
+
+// boundTo ключ ассистента, за которым закреплён диалог; пустая строка — не закреплён
+func (r *assistantRouter) boundTo(dialogID uint64) string {
+	if v, ok := r.bound.Load(dialogID); ok {
+		return v.(string)
+	}
+	if r.Store == nil {
+		return ""
+	}
+	id, err := r.Store.GetDialogAssistant(dialogID)
+	if err != nil {
+		logger.Warn("assistants: ошибка чтения ассистента диалога %d: %v", dialogID, err)
+		return ""
+	}
+	if id != "" {
+		r.bound.Store(dialogID, id)
+	}
+	return id
+}
+
+// bind закрепляет диалог за ассистентом
+func (r *assistantRouter) bind(userID uint32, dialogID uint64, id string) {
+	r.bound.Store(dialogID, id)
+	if r.Store == nil {
+		return
+	}
+	if err := r.Store.SaveDialogAssistant(userID, dialogID, id); err != nil {
+		logger.ForDialog(userID, dialogID).Warn("assistants: ассистент диалога не сохранён", "err", err)
+	}
+}
+
+// forget снимает привязку диалога из памяти по окончании его обслуживания;
+// в Store привязка остаётся, и вернувшийся диалог продолжит со своим ассистентом
+func (r *assistantRouter) forget(dialogID uint64) {
+	if r != nil {
+		r.bound.Delete(dialogID)
+	}
+}
+
+func findRoute(routes []AssistantRoute, id string) (AssistantRoute, bool) {
+	if id == "" {
+		return AssistantRoute{}, false
+	}
+	for _, route := range routes {
+		if route.key() == id {
+			return route, true
+		}
+	}
+	return AssistantRoute{}, false
+}
@@ -0,0 +1,111 @@
+package startpoint
+
+import (
+	"testing"
+
+	"github.com/ikermy/AiR_Common/pkg/model"
+	"github.com/ikermy/AiR_Common/pkg/webhook"
+)
+
+// memBindings — AssistantBindingStore в памяти
+type memBindings struct {
+	m map[uint64]string
+}
+
+func (b *memBindings) SaveDialogAssistant(_ uint32, dialogID uint64, assistID string) error {
+	if b.m == nil {
+		b.m = make(map[uint64]string)
+	}
+	b.m[dialogID] = assistID
+	return nil
+}
+
+func (b *memBindings) GetDialogAssistant(dialogID uint64) (string, error) {
+	return b.m[dialogID], nil
+}
+
+func testRoutes(uint32) ([]AssistantRoute, error) {
+	return []AssistantRoute{
+		{ID: "support", Assist: model.Assistant{AssistName: "Поддержка"}, Command: "/support", Label: "Поддержка", Keywords: []string{"не работает"}},
+		{ID: "sales", Assist: model.Assistant{AssistName: "Продажи"}, Command: "/sales", Label: "Купить", Keywords: []string{"цена", "купить"}},
+	}, nil
+}
+
+func TestRouteAssistant_CommandKeywordDefault(t *testing.T) {
+	store := &memBindings{}
+	s := &Start{}
+	WithAssistantRouting(AssistantRouting{Source: testRoutes, Store: store})(s)
+
+	cases := []struct {
+		dialog uint64
+		text   string
+		id, by string
+	}{
+		{1, "/sales хочу тариф", "sales", RouteCommand},
+		{2, "Какая ЦЕНА подписки?", "sales", RouteKeyword},
+		{3, "Здравствуйте", "support", RouteDefault},
+		{1, "/support", "sales", RouteBound}, // привязанный диалог остаётся у своего ассистента
+	}
+	for _, c := range cases {
+		got, err := s.RouteAssistant(7, c.dialog, c.text)
+		if err != nil {
+			t.Fatalf("%q: %v", c.text, err)
+		}
+		if got.ID != c.id || got.By != c.by {
+			t.Fatalf("%q: got %s/%s, want %s/%s", c.text, got.ID, got.By, c.id, c.by)
+		}
+	}
+	if store.m[2] != "sales" {
+		t.Fatalf("binding not persisted: %v", store.m)
+	}
+
+	// После перезапуска привязка читается из хранилища
+	restarted := &Start{}
+	WithAssistantRouting(AssistantRouting{Source: testRoutes, Store: store})(restarted)
+	if got, _ := restarted.RouteAssistant(7, 2, "привет"); got.ID != "sales" || got.By != RouteBound {
+		t.Fatalf("binding not restored: %+v", got)
+	}
+
+	// Окончание диалога освобождает привязку в памяти
+	s.emit(&model.RespModel{Assist: model.Assistant{UserID: 7}}, 1, 1, webhook.EventDialogEnded, nil)
+	if _, ok := s.assistants.bound.Load(uint64(1)); ok {
+		t.Fatal("binding kept in memory after dialog end")
+	}
+}
+
+func TestRouteAssistant_ClassifierAndMenu(t *testing.T) {
+	s := &Start{}
+	WithAssistantRouting(AssistantRouting{
+		Source: testRoutes,
+		Menu:   true,
+		Classify: func(_ uint32, text string, _ []AssistantRoute) (string, error) {
+			if text == "оформить заказ" {
+				return "sales", nil
+			}
+			return "", nil
+		},
+	})(s)
+
+	if got, _ := s.RouteAssistant(7, 1, "оформить заказ"); got.ID != "sales" || got.By != RouteClassifier {
+		t.Fatalf("classifier not used: %+v", got)
+	}
+
+	got, err := s.RouteAssistant(7, 2, "Здравствуйте")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Menu == nil || got.By != "" || len(got.Menu.Action.QuickReplies) != 2 ||
+		got.Menu.Action.QuickReplies[1].Payload != "/sales" {
+		t.Fatalf("expected menu, got %+v", got)
+	}
+	// Кнопка меню приходит командой
+	if got, _ := s.RouteAssistant(7, 2, "/sales"); got.ID != "sales" || got.By != RouteCommand {
+		t.Fatalf("menu choice not applied: %+v", got)
+	}
+}
+
+func TestRouteAssistant_NotConfigured(t *testing.T) {
+	if _, err := (&Start{}).RouteAssistant(7, 1, "привет"); err == nil {
+		t.Fatalf("expected error without WithAssistantRouting")
+	}
+}
@@ -0,0 +1,152 @@
+package startpoint
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ikermy/AiR_Common/pkg/mode"
+	"github.com/ikermy/AiR_Common/pkg/model"
+	"github.com/ikermy/AiR_Common/pkg/safego"
+)
+
+// Статусы доставки рассылки в диалог
+const (
+	BroadcastDelivered = "delivered"
+	BroadcastFailed    = "failed"  // диалог не принял сообщение (завершён или переполнен)
+	BroadcastSkipped   = "skipped" // рассылка отменена до отправки в диалог
+)
+
+// BroadcastStatus состояние рассылки
+type BroadcastStatus struct {
+	ID        uint64            `json:"id"`
+	Total     int               `json:"total"`
+	Delivered int               `json:"delivered"`
+	Failed    int               `json:"failed"`
+	Skipped   int               `json:"skipped"`
+	Done      bool              `json:"done"`
+	StartedAt time.Time         `json:"started_at"`
+	DoneAt    time.Time         `json:"done_at,omitempty"`
+	Dialogs   map[uint64]string `json:"dialogs"` // dialogID -> статус доставки
+}
+
+type broadcast struct {
+	mu     sync.Mutex
+	status BroadcastStatus
+	cancel context.CancelFunc
+}
+
+var broadcastSeq atomic.Uint64
+
+// Broadcast рассылает сообщение (текст и файлы через Action.SendFiles) активным диалогам,
+// подходящим под filter (nil — всем). Сообщение идёт обычным путём ответа ассистента:
+// в TxCh клиента и в историю диалога. Скорость — не больше rate сообщений в секунду
+// (0 — mode.BroadcastRate). Рассылка выполняется в фоне, состояние — BroadcastStatus.
+func (s *Start) Broadcast(content model.AssistResponse, filter func(DialogInfo) bool, rate int) (uint64, error) {
+	if content.Message == "" && len(content.Action.SendFiles) == 0 {
+		return 0, fmt.Errorf("пустое сообщение рассылки")
+	}
+	if rate <= 0 {
+		rate = mode.BroadcastRate
+	}
+
+	targets := s.ActiveDialogs(filter)
+	ctx, cancel := context.WithCancel(s.ctx)
+	b := &broadcast{
+		cancel: cancel,
+		status: BroadcastStatus{
+			ID:        broadcastSeq.Add(1),
+			Total:     len(targets),
+			StartedAt: time.Now(),
+			Dialogs:   make(map[uint64]string, len(targets)),
+		},
+	}
+	s.broadcasts.Store(b.status.ID, b)
+
+	safego.Go("broadcast", func() {
+		defer cancel()
+		interval := time.Second / time.Duration(rate)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for i, t := range targets {
+			if i > 0 {
+				select {
+				case <-ctx.Done():
+				case <-ticker.C:
+				}
+			}
+			if ctx.Err() != nil {
+				b.finish(targets[i:])
+				return
+			}
+
+			result := BroadcastFailed
+			if d, ok := s.activeDialog(t.DialogID); ok && d.deliver(Answer{Answer: content}) {
+				result = BroadcastDelivered
+			}
+			b.record(t.DialogID, result)
+		}
+		b.finish(nil)
+	})
+
+	return b.status.ID, nil
+}
+
+// BroadcastStatus возвращает копию состояния рассылки
+func (s *Start) BroadcastStatus(id uint64) (BroadcastStatus, bool) {
+	v, ok := s.broadcasts.Load(id)
+	if !ok {
+		return BroadcastStatus{}, false
+	}
+	b := v.(*broadcast)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	st := b.status
+	st.Dialogs = make(map[uint64]string, len(b.status.Dialogs))
+	for k, v := range b.status.Dialogs {
+		st.Dialogs[k] = v
+	}
+	return st, true
+}
+
+// CancelBroadcast останавливает рассылку; недоставленные диалоги получают статус skipped
+func (s *Start) CancelBroadcast(id uint64) error {
+	v, ok := s.broadcasts.Load(id)
+	if !ok {
+		return fmt.Errorf("рассылка %d не найдена", id)
+	}
+	v.(*broadcast).cancel()
+	return nil
+}
+
+// ForgetBroadcast удаляет состояние завершённой рассылки
+func (s *Start) ForgetBroadcast(id uint64) {
+	s.broadcasts.Delete(id)
+}
+
+func (b *broadcast) record(dialogID uint64, result string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.status.Dialogs[dialogID] = result
+	switch result {
+	case BroadcastDelivered:
+		b.status.Delivered++
+	case BroadcastFailed:
+		b.status.Failed++
+	case BroadcastSkipped:
+		b.status.Skipped++
+	}
+}
+
+func (b *broadcast) finish(skipped []DialogInfo) {
+	for _, t := range skipped {
+		b.record(t.DialogID, BroadcastSkipped)
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.status.Done = true
+	b.status.DoneAt = time.Now()
+}
@@ -0,0 +1,78 @@
+package startpoint
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ikermy/AiR_Common/pkg/model"
+)
+
+func TestBroadcast_FilterAndStatus(t *testing.T) {
+	s := New(context.Background(), nil, nil, nil, nil)
+	defer s.cancel()
+
+	okCh := make(chan Answer, 1)
+	fullCh := make(chan Answer) // без буфера — доставка не пройдёт
+	otherCh := make(chan Answer, 1)
+	s.registerActive(&model.RespModel{Assist: model.Assistant{UserID: 1}}, 1, 10, answerChDeliverer(okCh))
+	s.registerActive(&model.RespModel{Assist: model.Assistant{UserID: 1}}, 2, 11, answerChDeliverer(fullCh))
+	s.registerActive(&model.RespModel{Assist: model.Assistant{UserID: 2}}, 3, 12, answerChDeliverer(otherCh))
+
+	id, err := s.Broadcast(model.AssistResponse{Message: "анонс"}, func(d DialogInfo) bool { return d.UserID == 1 }, 100)
+	if err != nil {
+		t.Fatalf("Broadcast: %v", err)
+	}
+
+	var st BroadcastStatus
+	deadline := time.Now().Add(time.Second)
+	for {
+		st, _ = s.BroadcastStatus(id)
+		if st.Done || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	if !st.Done || st.Total != 2 || st.Delivered != 1 || st.Failed != 1 {
+		t.Fatalf("unexpected status: %+v", st)
+	}
+	if st.Dialogs[10] != BroadcastDelivered || st.Dialogs[11] != BroadcastFailed {
+		t.Fatalf("unexpected dialog statuses: %v", st.Dialogs)
+	}
+	if ans := <-okCh; ans.Answer.Message != "анонс" {
+		t.Fatalf("unexpected answer: %+v", ans)
+	}
+	if len(otherCh) != 0 {
+		t.Fatalf("filtered dialog must not receive broadcast")
+	}
+}
+
+func TestBroadcast_Cancel(t *testing.T) {
+	s := New(context.Background(), nil, nil, nil, nil)
+	defer s.cancel()
+
+	for i := uint64(1); i <= 3; i++ {
+		s.registerActive(&model.RespModel{}, i, i, answerChDeliverer(make(chan Answer, 1)))
+	}
+	id, _ := s.Broadcast(model.AssistResponse{Message: "акция"}, nil, 1)
+	time.Sleep(20 * time.Millisecond)
+	if err := s.CancelBroadcast(id); err != nil {
+		t.Fatalf("CancelBroadcast: %v", err)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		st, _ := s.BroadcastStatus(id)
+		if st.Done {
+			if st.Delivered != 1 || st.Skipped != 2 {
+				t.Fatalf("unexpected status after cancel: %+v", st)
+			}
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("broadcast was not cancelled")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
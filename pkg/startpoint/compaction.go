@@ -0,0 +1,167 @@
+package startpoint
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ikermy/AiR_Common/pkg/comdb"
+	"github.com/ikermy/AiR_Common/pkg/endpoint"
+	"github.com/ikermy/AiR_Common/pkg/logger"
+	"github.com/ikermy/AiR_Common/pkg/mode"
+	"github.com/ikermy/AiR_Common/pkg/model"
+	"github.com/ikermy/AiR_Common/pkg/safego"
+)
+
+// CompactionStore — хранилище истории диалогов для сжатия (реализуется *comdb.DB)
+type CompactionStore interface {
+	ListDialogsToCompact(minBytes int, idle time.Duration, limit int) ([]uint64, error)
+	ReadDialogData(dialogID uint64) ([]json.RawMessage, error)
+	ReplaceDialogHead(dialogID uint64, head []json.RawMessage, summary json.RawMessage) (bool, error)
+	MarkDialogCompacted(dialogID uint64) error
+}
+
+// DialogSummarizer сжимает ранние сообщения сохранённого диалога в сводку
+// (например, запросом к модели). Сводка прошлого сжатия приходит первым сообщением
+type DialogSummarizer func(dialogID uint64, history []endpoint.Message) (string, error)
+
+// dialogSummaryPrefix заголовок сводки, заменившей ранние сообщения в истории диалога
+const dialogSummaryPrefix = "Сводка ранней части диалога:\n"
+
+type dialogCompaction struct {
+	store     CompactionStore
+	summarize DialogSummarizer
+	// failures неудачные сжатия по диалогам; меняется только в проходе сжатия
+	failures map[uint64]compactionFailure
+}
+
+// compactionFailure неудачи сжатия диалога подряд и время следующей попытки
+type compactionFailure struct {
+	attempts int
+	next     time.Time
+}
+
+// WithDialogCompaction раз в mode.DialogCompactInterval заменяет ранние сообщения длинных
+// диалогов без новых сообщений дольше mode.DialogCompactIdle одной сводкой; последние
+// mode.DialogCompactKeep сообщений остаются целиком. Без summarize сводка — компактная
+// расшифровка длиной до mode.DialogCompactSummaryChars
+func WithDialogCompaction(store CompactionStore, summarize DialogSummarizer) Option {
+	return func(s *Start) {
+		s.compaction = &dialogCompaction{store: store, summarize: summarize}
+	}
+}
+
+func (s *Start) runCompaction() {
+	safego.Go("compaction", func() {
+		ticker := time.NewTicker(mode.DialogCompactInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-s.ctx.Done():
+				return
+			case <-ticker.C:
+				s.compactDialogs()
+			}
+		}
+	}, safego.WithRestart(-1, time.Minute), safego.WithDone(s.ctx.Done()))
+}
+
+// compactDialogs один проход сжатия; возвращает число сжатых диалогов
+func (s *Start) compactDialogs() int {
+	ids, err := s.compaction.store.ListDialogsToCompact(mode.DialogCompactMinBytes, mode.DialogCompactIdle, mode.DialogCompactBatch)
+	if err != nil {
+		logger.Warn("compaction: ошибка поиска диалогов: %v", err)
+		return 0
+	}
+	now := time.Now()
+	compacted := 0
+	for _, id := range ids {
+		if s.ctx.Err() != nil {
+			break
+		}
+		if f, failed := s.compaction.failures[id]; failed && now.Before(f.next) {
+			continue
+		}
+		ok, err := s.compactDialog(id)
+		if err != nil {
+			s.compaction.fail(id, now, err)
+			continue
+		}
+		delete(s.compaction.failures, id)
+		if ok {
+			compacted++
+		}
+	}
+	if compacted > 0 {
+		logger.Info("compaction: сжато диалогов: %d из %d", compacted, len(ids))
+	}
+	return compacted
+}
+
+// fail откладывает следующую попытку сжатия диалога по mode.DialogCompactRetry; после
+// последней попытки диалог отмечается сжатым до новых сообщений
+func (c *dialogCompaction) fail(dialogID uint64, now time.Time, err error) {
+	f := c.failures[dialogID]
+	f.attempts++
+	if f.attempts >= mode.DialogCompactRetry.Attempts() {
+		delete(c.failures, dialogID)
+		logger.Warn("compaction: диалог %d не сжат после %d попыток, пропущен до новых сообщений: %v", dialogID, f.attempts, err)
+		if err := c.store.MarkDialogCompacted(dialogID); err != nil {
+			logger.Warn("compaction: ошибка отметки диалога %d: %v", dialogID, err)
+		}
+		return
+	}
+	f.next = now.Add(mode.DialogCompactRetry.Backoff(f.attempts))
+	if c.failures == nil {
+		c.failures = make(map[uint64]compactionFailure)
+	}
+	c.failures[dialogID] = f
+	logger.Warn("compaction: диалог %d не сжат (попытка %d): %v", dialogID, f.attempts, err)
+}
+
+// compactDialog заменяет ранние сообщения диалога сводкой. false — сжимать нечего
+// или диалог изменился во время сжатия (повтор при следующем проходе)
+func (s *Start) compactDialog(dialogID uint64) (bool, error) {
+	store := s.compaction.store
+	data, err := store.ReadDialogData(dialogID)
+	if err != nil {
+		return false, err
+	}
+	keep := max(mode.DialogCompactKeep, 0)
+	if len(data) <= keep+1 {
+		return false, store.MarkDialogCompacted(dialogID)
+	}
+
+	head := data[:len(data)-keep]
+	history := make([]endpoint.Message, 0, len(head))
+	for _, raw := range head {
+		var msg endpoint.Message
+		if json.Unmarshal(raw, &msg) == nil {
+			history = append(history, msg)
+		}
+	}
+	if len(history) == 0 {
+		return false, store.MarkDialogCompacted(dialogID)
+	}
+
+	summary := ""
+	if s.compaction.summarize != nil {
+		if summary, err = s.compaction.summarize(dialogID, history); err != nil {
+			return false, fmt.Errorf("ошибка сводки диалога %d: %w", dialogID, err)
+		}
+	}
+	if summary = strings.TrimSpace(summary); summary == "" {
+		summary = truncateRunes(formatTranscript(history), mode.DialogCompactSummaryChars)
+	}
+
+	entry, err := json.Marshal(endpoint.Message{
+		Creator:   comdb.AI,
+		Message:   model.AssistResponse{Message: dialogSummaryPrefix + strings.TrimPrefix(summary, dialogSummaryPrefix)},
+		Timestamp: history[len(history)-1].Timestamp,
+	})
+	if err != nil {
+		return false, fmt.Errorf("ошибка сериализации сводки диалога %d: %w", dialogID, err)
+	}
+	return store.ReplaceDialogHead(dialogID, head, entry)
+}
@@ -0,0 +1,158 @@
+package startpoint
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ikermy/AiR_Common/pkg/comdb"
+	"github.com/ikermy/AiR_Common/pkg/endpoint"
+	"github.com/ikermy/AiR_Common/pkg/mode"
+	"github.com/ikermy/AiR_Common/pkg/model"
+)
+
+// memCompaction — CompactionStore в памяти
+type memCompaction struct {
+	dialogs map[uint64][]json.RawMessage
+	marked  map[uint64]bool
+}
+
+func (m *memCompaction) ListDialogsToCompact(int, time.Duration, int) ([]uint64, error) {
+	var ids []uint64
+	for id := range m.dialogs {
+		if !m.marked[id] {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+func (m *memCompaction) ReadDialogData(dialogID uint64) ([]json.RawMessage, error) {
+	return append([]json.RawMessage(nil), m.dialogs[dialogID]...), nil
+}
+
+func (m *memCompaction) ReplaceDialogHead(dialogID uint64, head []json.RawMessage, summary json.RawMessage) (bool, error) {
+	arr := m.dialogs[dialogID]
+	for i := range head {
+		if !bytes.Equal(arr[i], head[i]) {
+			return false, nil
+		}
+	}
+	m.dialogs[dialogID] = append([]json.RawMessage{summary}, arr[len(head):]...)
+	return true, m.MarkDialogCompacted(dialogID)
+}
+
+func (m *memCompaction) MarkDialogCompacted(dialogID uint64) error {
+	if m.marked == nil {
+		m.marked = make(map[uint64]bool)
+	}
+	m.marked[dialogID] = true
+	return nil
+}
+
+func storedDialog(t *testing.T, n int) []json.RawMessage {
+	t.Helper()
+	out := make([]json.RawMessage, n)
+	for i := range out {
+		creator := comdb.User
+		if i%2 == 1 {
+			creator = comdb.AI
+		}
+		raw, err := json.Marshal(endpoint.Message{
+			Creator:   creator,
+			Message:   model.AssistResponse{Message: "сообщение " + string(rune('a'+i))},
+			Timestamp: time.Unix(int64(i), 0),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		out[i] = raw
+	}
+	return out
+}
+
+func TestCompaction_ReplacesHeadWithSummary(t *testing.T) {
+	orig := mode.DialogCompactKeep
+	mode.DialogCompactKeep = 2
+	defer func() { mode.DialogCompactKeep = orig }()
+
+	store := &memCompaction{dialogs: map[uint64][]json.RawMessage{
+		5: storedDialog(t, 6),
+		6: storedDialog(t, 3), // сжимать нечего
+	}}
+	var got []endpoint.Message
+	s := &Start{ctx: context.Background(), compaction: &dialogCompaction{store: store, summarize: func(_ uint64, h []endpoint.Message) (string, error) {
+		got = h
+		return "пользователь спрашивал о доставке", nil
+	}}}
+
+	if n := s.compactDialogs(); n != 1 {
+		t.Fatalf("expected 1 compacted dialog, got %d", n)
+	}
+	if len(got) != 4 {
+		t.Fatalf("summarizer must get 4 early messages, got %d", len(got))
+	}
+	arr := store.dialogs[5]
+	if len(arr) != 3 {
+		t.Fatalf("expected summary + 2 kept messages, got %d", len(arr))
+	}
+	var summary endpoint.Message
+	if err := json.Unmarshal(arr[0], &summary); err != nil {
+		t.Fatal(err)
+	}
+	if summary.Creator != comdb.AI || summary.Message.Message != dialogSummaryPrefix+"пользователь спрашивал о доставке" ||
+		!summary.Timestamp.Equal(time.Unix(3, 0)) {
+		t.Fatalf("unexpected summary entry: %+v", summary)
+	}
+	if len(store.dialogs[6]) != 3 || !store.marked[6] {
+		t.Fatalf("short dialog must stay unchanged and be marked")
+	}
+}
+
+func TestCompaction_FallbackTranscript(t *testing.T) {
+	orig := mode.DialogCompactKeep
+	mode.DialogCompactKeep = 1
+	defer func() { mode.DialogCompactKeep = orig }()
+
+	store := &memCompaction{dialogs: map[uint64][]json.RawMessage{5: storedDialog(t, 3)}}
+	s := &Start{compaction: &dialogCompaction{store: store}}
+	if ok, err := s.compactDialog(5); !ok || err != nil {
+		t.Fatalf("compactDialog: ok=%v err=%v", ok, err)
+	}
+	var summary endpoint.Message
+	_ = json.Unmarshal(store.dialogs[5][0], &summary)
+	if !strings.Contains(summary.Message.Message, "Пользователь: сообщение a") ||
+		!strings.Contains(summary.Message.Message, "Ассистент: сообщение b") {
+		t.Fatalf("unexpected fallback summary: %q", summary.Message.Message)
+	}
+}
+
+func TestCompaction_FailingDialogBackoffAndCap(t *testing.T) {
+	origKeep, origRetry := mode.DialogCompactKeep, mode.DialogCompactRetry
+	mode.DialogCompactKeep = 1
+	mode.DialogCompactRetry = mode.RetryRule{MaxAttempts: 2, BaseDelay: time.Hour}
+	defer func() { mode.DialogCompactKeep, mode.DialogCompactRetry = origKeep, origRetry }()
+
+	store := &memCompaction{dialogs: map[uint64][]json.RawMessage{5: storedDialog(t, 3)}}
+	calls := 0
+	s := &Start{ctx: context.Background(), compaction: &dialogCompaction{store: store, summarize: func(uint64, []endpoint.Message) (string, error) {
+		calls++
+		return "", errors.New("модель недоступна")
+	}}}
+
+	s.compactDialogs()
+	s.compactDialogs() // до истечения паузы диалог пропускается
+	if calls != 1 || store.marked[5] {
+		t.Fatalf("expected one attempt before backoff expires: calls=%d marked=%v", calls, store.marked[5])
+	}
+
+	s.compaction.failures[5] = compactionFailure{attempts: 1, next: time.Now().Add(-time.Second)}
+	s.compactDialogs()
+	if calls != 2 || !store.marked[5] || len(s.compaction.failures) != 0 {
+		t.Fatalf("dialog must be marked after the last attempt: calls=%d marked=%v", calls, store.marked[5])
+	}
+}
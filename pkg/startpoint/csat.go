@@ -0,0 +1,241 @@
+package startpoint
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ikermy/AiR_Common/pkg/comdb"
+	"github.com/ikermy/AiR_Common/pkg/logger"
+	"github.com/ikermy/AiR_Common/pkg/mode"
+	"github.com/ikermy/AiR_Common/pkg/model"
+	"github.com/ikermy/AiR_Common/pkg/safego"
+	"github.com/ikermy/AiR_Common/pkg/webhook"
+)
+
+// MessageRating тип сообщения с оценкой диалога (model.Message.Type): Content.Message —
+// оценка, после неё через пробел может идти комментарий ("5 всё отлично")
+const MessageRating = "rating"
+
+// csatPayloadPrefix payload кнопок опроса: бот, который отправляет payload как реплику
+// пользователя, передаёт оценку в виде "csat:5"
+const csatPayloadPrefix = "csat:"
+
+// CSATQuestion текст опроса по умолчанию
+const CSATQuestion = "Пожалуйста, оцените, насколько мы вам помогли"
+
+// Поводы опроса удовлетворённости
+const (
+	CSATOnTarget = "target" // ответ ассистента достиг цели (Metas.MetaAction)
+	CSATOnTTL    = "ttl"    // диалог завершился по TTL
+)
+
+// CSATStore хранилище опросов (реализуется *comdb.DB)
+type CSATStore interface {
+	SaveCSATRequest(r comdb.CSATRating) error
+	SaveCSATRating(r comdb.CSATRating) error
+	GetCSATStats(userID uint32, since time.Time, satisfied int) ([]comdb.CSATStats, error)
+}
+
+// CSATSurvey настройки опроса удовлетворённости
+type CSATSurvey struct {
+	Store    CSATStore
+	OnTarget bool   // опрос после ответа, достигшего цели
+	OnTTL    bool   // опрос при завершении диалога по TTL
+	Question string // "" — CSATQuestion
+	Scale    int    // оценки 1..Scale; 0 — 5
+}
+
+// WithCSATSurvey после завершения диалога отправляет пользователю опрос с кнопками оценок.
+// Оценка приходит сообщением MessageRating или репликой с payload кнопки и в Respondent
+// не попадает. Опрос и оценка сохраняются в Store, события webhook.EventCSATRequested и
+// webhook.EventCSATRated уходят всегда: если диалог уже закрыт и опрос не доставлен,
+// его может отправить бот. Сводка по ассистентам — CSATStats
+func WithCSATSurvey(c CSATSurvey) Option {
+	return func(s *Start) {
+		if c.Store == nil || (!c.OnTarget && !c.OnTTL) {
+			return
+		}
+		if c.Question == "" {
+			c.Question = CSATQuestion
+		}
+		if c.Scale <= 0 {
+			c.Scale = 5
+		}
+		s.csat = &csatSurvey{CSATSurvey: c}
+	}
+}
+
+type csatSurvey struct {
+	CSATSurvey
+	pending sync.Map // uint64 (dialogID) -> csatPending
+}
+
+// csatPending опрос, ожидающий оценки
+type csatPending struct {
+	userID      uint32
+	respName    string
+	assistName  string
+	reason      string
+	requestedAt time.Time
+	rated       bool
+}
+
+// message опрос с кнопками оценок
+func (c *csatSurvey) message() model.AssistResponse {
+	buttons := make([]model.Button, c.Scale)
+	for i := range buttons {
+		n := strconv.Itoa(i + 1)
+		buttons[i] = model.Button{Text: n, Payload: csatPayloadPrefix + n}
+	}
+	return model.AssistResponse{Message: c.Question, Action: model.Action{QuickReplies: buttons}}
+}
+
+// satisfied минимальная оценка довольного пользователя: 4 из 5
+func (c *csatSurvey) satisfied() int {
+	return max(1, (c.Scale*4+4)/5)
+}
+
+// prune забывает опросы старше mode.CSATReplyWindow
+func (c *csatSurvey) prune(now time.Time) {
+	c.pending.Range(func(k, v any) bool {
+		if now.Sub(v.(csatPending).requestedAt) > mode.CSATReplyWindow {
+			c.pending.Delete(k)
+		}
+		return true
+	})
+}
+
+func (c *csatSurvey) enabled(reason string) bool {
+	return reason == CSATOnTarget && c.OnTarget || reason == CSATOnTTL && c.OnTTL
+}
+
+// requestCSAT отправляет опрос один раз на диалог. send доставляет опрос пользователю
+func (s *Start) requestCSAT(u *model.RespModel, respId, treadId uint64, reason string, send func(model.AssistResponse) bool) {
+	c := s.csat
+	if c == nil || !c.enabled(reason) {
+		return
+	}
+	now := time.Now()
+	c.prune(now)
+	p := csatPending{userID: u.Assist.UserID, respName: u.RespName, assistName: u.Assist.AssistName, reason: reason, requestedAt: now}
+	if prev, loaded := c.pending.LoadOrStore(treadId, p); loaded && now.Sub(prev.(csatPending).requestedAt) < mode.CSATReplyWindow {
+		return
+	}
+	c.pending.Store(treadId, p)
+
+	log := logger.ForDialog(u.Assist.UserID, treadId)
+	if err := c.Store.SaveCSATRequest(comdb.CSATRating{
+		DialogID: treadId, UserID: u.Assist.UserID, AssistName: u.Assist.AssistName, Reason: reason, RequestedAt: now,
+	}); err != nil {
+		log.Warn("csat: запрос оценки не сохранён", "err", err)
+	}
+
+	survey := c.message()
+	delivered := send(survey)
+	s.emit(u, respId, treadId, webhook.EventCSATRequested, map[string]any{
+		"reason": reason, "delivered": delivered, "survey": survey,
+	})
+}
+
+// scheduleTargetCSAT отправляет опрос после ответа, достигшего цели: пауза даёт ответу
+// дойти до пользователя раньше опроса
+func (s *Start) scheduleTargetCSAT(u *model.RespModel, respId, treadId uint64) {
+	if s.csat == nil || !s.csat.OnTarget {
+		return
+	}
+	time.AfterFunc(mode.CSATTargetDelay, func() {
+		safego.Go("csat.target", func() {
+			s.requestCSAT(u, respId, treadId, CSATOnTarget, func(survey model.AssistResponse) bool {
+				d, ok := s.activeDialog(treadId)
+				return ok && d.deliver(Answer{Answer: survey})
+			})
+		}, safego.WithUserID(u.Assist.UserID))
+	})
+}
+
+// ttlExpired диалог завершается потому, что истёк TTL модели
+func ttlExpired(u *model.RespModel) bool {
+	return !u.TTL.IsZero() && !u.TTL.After(time.Now())
+}
+
+// captureRating принимает оценку из сообщения пользователя. true — сообщение было
+// оценкой и дальше не обрабатывается
+func (s *Start) captureRating(userID uint32, treadId uint64, msg model.Message) bool {
+	c := s.csat
+	if c == nil {
+		return false
+	}
+	text := strings.TrimSpace(msg.Content.Message)
+	if msg.Type != MessageRating {
+		// Текст считается оценкой, только пока ждём ответ на опрос
+		v, ok := c.pending.Load(treadId)
+		if !ok || v.(csatPending).rated || time.Since(v.(csatPending).requestedAt) > mode.CSATReplyWindow {
+			return false
+		}
+		if strings.HasPrefix(text, csatPayloadPrefix) {
+			text = strings.TrimPrefix(text, csatPayloadPrefix)
+		} else if n, err := strconv.Atoi(text); err != nil || n < 1 || n > c.Scale {
+			return false
+		}
+	}
+
+	scoreText, comment, _ := strings.Cut(text, " ")
+	score, err := strconv.Atoi(scoreText)
+	if err == nil {
+		err = s.SubmitRating(userID, treadId, score, strings.TrimSpace(comment))
+	}
+	if err != nil {
+		logger.ForDialog(userID, treadId).Warn("csat: оценка не принята", "text", msg.Content.Message, "err", err)
+		// Отклонённый текст уходит в диалог как обычное сообщение
+		return msg.Type == MessageRating
+	}
+	return true
+}
+
+// SubmitRating сохраняет оценку диалога score (1..CSATSurvey.Scale). Для ботов,
+// которые принимают оценку сами (callback кнопки), в том числе после завершения диалога
+func (s *Start) SubmitRating(userID uint32, dialogID uint64, score int, comment string) error {
+	c := s.csat
+	if c == nil {
+		return fmt.Errorf("опрос удовлетворённости не подключён")
+	}
+	if score < 1 || score > c.Scale {
+		return fmt.Errorf("оценка %d вне шкалы 1..%d", score, c.Scale)
+	}
+
+	// Опрос остаётся в памяти отмеченным: повторная цель в том же диалоге не шлёт его снова
+	p := csatPending{userID: userID, requestedAt: time.Now()}
+	if v, ok := c.pending.Load(dialogID); ok {
+		p = v.(csatPending)
+	} else if d, ok := s.activeDialog(dialogID); ok {
+		p.userID, p.respName, p.assistName = d.info.UserID, d.info.RespName, d.info.AssistName
+	}
+	if p.userID != userID {
+		return fmt.Errorf("диалог %d не принадлежит пользователю %d", dialogID, userID)
+	}
+
+	p.rated = true
+	c.pending.Store(dialogID, p)
+
+	if err := c.Store.SaveCSATRating(comdb.CSATRating{
+		DialogID: dialogID, UserID: userID, AssistName: p.assistName, Reason: p.reason,
+		Score: score, Comment: comment, RequestedAt: p.requestedAt, RatedAt: time.Now(),
+	}); err != nil {
+		return err
+	}
+
+	u := &model.RespModel{RespName: p.respName, Assist: model.Assistant{UserID: userID, AssistName: p.assistName}}
+	s.emit(u, 0, dialogID, webhook.EventCSATRated, map[string]any{"score": score, "scale": c.Scale, "comment": comment})
+	return nil
+}
+
+// CSATStats сводка опросов пользователя по ассистентам с момента since
+func (s *Start) CSATStats(userID uint32, since time.Time) ([]comdb.CSATStats, error) {
+	if s.csat == nil {
+		return nil, fmt.Errorf("опрос удовлетворённости не подключён")
+	}
+	return s.csat.Store.GetCSATStats(userID, since, s.csat.satisfied())
+}
@@ -0,0 +1,113 @@
+package startpoint
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ikermy/AiR_Common/pkg/comdb"
+	"github.com/ikermy/AiR_Common/pkg/model"
+)
+
+// memCSAT — CSATStore в памяти
+type memCSAT struct {
+	requests []comdb.CSATRating
+	ratings  []comdb.CSATRating
+}
+
+func (m *memCSAT) SaveCSATRequest(r comdb.CSATRating) error {
+	m.requests = append(m.requests, r)
+	return nil
+}
+
+func (m *memCSAT) SaveCSATRating(r comdb.CSATRating) error {
+	m.ratings = append(m.ratings, r)
+	return nil
+}
+
+func (m *memCSAT) GetCSATStats(uint32, time.Time, int) ([]comdb.CSATStats, error) {
+	return nil, nil
+}
+
+func TestCSAT_RequestAndCapture(t *testing.T) {
+	store := &memCSAT{}
+	s := &Start{}
+	WithCSATSurvey(CSATSurvey{Store: store, OnTTL: true})(s)
+
+	u := &model.RespModel{RespName: "bot", Assist: model.Assistant{UserID: 3, AssistName: "Продажи"}}
+	var sent []model.AssistResponse
+	send := func(a model.AssistResponse) bool {
+		sent = append(sent, a)
+		return true
+	}
+
+	s.requestCSAT(u, 1, 10, CSATOnTarget, send) // повод не включён
+	s.requestCSAT(u, 1, 10, CSATOnTTL, send)
+	s.requestCSAT(u, 1, 10, CSATOnTTL, send) // опрос один раз на диалог
+	if len(sent) != 1 || len(store.requests) != 1 {
+		t.Fatalf("sent=%d requests=%d", len(sent), len(store.requests))
+	}
+	if qr := sent[0].Action.QuickReplies; len(qr) != 5 || qr[4].Payload != "csat:5" {
+		t.Fatalf("quick replies: %+v", qr)
+	}
+
+	// Обычный вопрос не оценка, цифра в ответ на опрос — оценка
+	if s.captureRating(3, 10, model.Message{Type: "user", Content: model.AssistResponse{Message: "а доставка?"}}) {
+		t.Fatal("question captured as rating")
+	}
+	if !s.captureRating(3, 10, model.Message{Type: "user", Content: model.AssistResponse{Message: "4"}}) {
+		t.Fatal("digit reply not captured")
+	}
+	if len(store.ratings) != 1 || store.ratings[0].Score != 4 || store.ratings[0].AssistName != "Продажи" || store.ratings[0].Reason != CSATOnTTL {
+		t.Fatalf("ratings: %+v", store.ratings)
+	}
+
+	// После оценки цифры снова обычные вопросы; явная оценка с комментарием принимается
+	if s.captureRating(3, 10, model.Message{Type: "user", Content: model.AssistResponse{Message: "2"}}) {
+		t.Fatal("digit captured after rating")
+	}
+	if !s.captureRating(3, 10, model.Message{Type: MessageRating, Content: model.AssistResponse{Message: "5 всё отлично"}}) {
+		t.Fatal("rating message not captured")
+	}
+	if r := store.ratings[len(store.ratings)-1]; r.Score != 5 || r.Comment != "всё отлично" {
+		t.Fatalf("rating with comment: %+v", r)
+	}
+}
+
+func TestCSAT_SubmitRatingValidation(t *testing.T) {
+	s := &Start{}
+	if err := s.SubmitRating(1, 1, 5, ""); err == nil {
+		t.Fatal("expected error without survey")
+	}
+	WithCSATSurvey(CSATSurvey{Store: &memCSAT{}, OnTarget: true, Scale: 10})(s)
+	if err := s.SubmitRating(1, 1, 11, ""); err == nil {
+		t.Fatal("expected error for score out of scale")
+	}
+	if got := s.csat.satisfied(); got != 8 {
+		t.Fatalf("satisfied for scale 10 = %d", got)
+	}
+}
+
+func TestCSAT_PayloadWithoutSurvey(t *testing.T) {
+	store := &memCSAT{}
+	s := &Start{}
+	WithCSATSurvey(CSATSurvey{Store: store, OnTTL: true})(s)
+
+	// Опрос не отправлялся — текст с префиксом остаётся обычным сообщением
+	if s.captureRating(3, 10, model.Message{Type: "user", Content: model.AssistResponse{Message: "csat: что это?"}}) {
+		t.Fatal("payload captured without survey")
+	}
+
+	u := &model.RespModel{RespName: "bot", Assist: model.Assistant{UserID: 3, AssistName: "Продажи"}}
+	s.requestCSAT(u, 1, 10, CSATOnTTL, func(model.AssistResponse) bool { return true })
+
+	// Отклонённая оценка не глотает сообщение
+	if s.captureRating(3, 10, model.Message{Type: "user", Content: model.AssistResponse{Message: "csat:9"}}) {
+		t.Fatal("rejected payload reported as captured")
+	}
+	if !s.captureRating(3, 10, model.Message{Type: "user", Content: model.AssistResponse{Message: "csat:3"}}) {
+		t.Fatal("payload reply not captured")
+	}
+	if len(store.ratings) != 1 || store.ratings[0].Score != 3 {
+		t.Fatalf("ratings: %+v", store.ratings)
+	}
+}
@@ -0,0 +1,138 @@
+package startpoint
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/ikermy/AiR_Common/pkg/comdb"
+	"github.com/ikermy/AiR_Common/pkg/logger"
+	"github.com/ikermy/AiR_Common/pkg/model"
+	"github.com/ikermy/AiR_Common/pkg/webhook"
+)
+
+// Причины попадания хода в dead-letter
+const (
+	DeadLetterFatal       = "fatal"       // критическая ошибка провайдера
+	DeadLetterUndelivered = "undelivered" // ответ не удалось передать в answerCh
+)
+
+// DeadLetterStore — хранилище необработанных ходов (реализуется *comdb.DB)
+type DeadLetterStore interface {
+	SaveDeadLetter(dl comdb.DeadLetter) (uint64, error)
+	ListDeadLetters(userID uint32, limit int) ([]comdb.DeadLetter, error)
+	GetDeadLetter(id uint64) (*comdb.DeadLetter, error)
+	MarkDeadLetterReplayed(id uint64) error
+}
+
+// deadLetterPayload — сериализуемая часть вопроса.
+// Содержимое файлов не сохраняется, только метаданные и URL.
+type deadLetterPayload struct {
+	Question []string           `json:"question"`
+	Voice    bool               `json:"voice"`
+	Files    []model.FileUpload `json:"files,omitempty"`
+	Operator model.Operator     `json:"operator"`
+	TraceID  string             `json:"trace_id,omitempty"`
+}
+
+// failedTurn — ход Respondent, который может попасть в dead-letter
+type failedTurn struct {
+	respId   uint64
+	dialogID uint64
+	ask      []string
+	quest    Question
+}
+
+// deadLetter сохраняет необработанный ход, если хранилище подключено
+func (s *Start) deadLetter(u *model.RespModel, turn failedTurn, reason string, cause error) {
+	if s.dlq == nil {
+		return
+	}
+
+	ask := turn.ask
+	if len(ask) == 0 {
+		ask = turn.quest.Question
+	}
+	payload, err := json.Marshal(deadLetterPayload{
+		Question: ask,
+		Voice:    turn.quest.Voice,
+		Files:    turn.quest.Files,
+		Operator: turn.quest.Operator,
+		TraceID:  turn.quest.TraceID,
+	})
+	if err != nil {
+		logger.ForDialog(u.Assist.UserID, turn.dialogID).Error("deadLetter: ошибка сериализации хода", "err", err)
+		return
+	}
+
+	dl := comdb.DeadLetter{
+		UserID:   u.Assist.UserID,
+		DialogID: turn.dialogID,
+		RespID:   turn.respId,
+		Reason:   reason,
+		Payload:  payload,
+	}
+	if cause != nil {
+		dl.Error = cause.Error()
+	}
+	if _, err := s.dlq.SaveDeadLetter(dl); err != nil {
+		logger.ForDialog(u.Assist.UserID, turn.dialogID).Error("deadLetter: не удалось сохранить ход", "reason", reason, "err", err)
+	}
+}
+
+// ListDeadLetters возвращает необработанные ходы пользователя
+func (s *Start) ListDeadLetters(userID uint32, limit int) ([]comdb.DeadLetter, error) {
+	if s.dlq == nil {
+		return nil, fmt.Errorf("хранилище dead-letter не подключено")
+	}
+	return s.dlq.ListDeadLetters(userID, limit)
+}
+
+// ReplayDeadLetter повторно отправляет ход в активный диалог через RxCh,
+// то есть по обычному пути Listener → Respondent. Диалог должен быть активен.
+func (s *Start) ReplayDeadLetter(id uint64) error {
+	if s.dlq == nil {
+		return fmt.Errorf("хранилище dead-letter не подключено")
+	}
+
+	dl, err := s.dlq.GetDeadLetter(id)
+	if err != nil {
+		return err
+	}
+	if dl.ReplayedAt != nil {
+		return fmt.Errorf("dead letter %d уже обработан", id)
+	}
+
+	// Недоставленное событие webhook — повторяем доставку, а не ход диалога
+	if dl.Reason == webhook.DeadLetterReason {
+		if s.webhooks == nil {
+			return fmt.Errorf("webhook не подключены, повтор dead letter %d невозможен", id)
+		}
+		if err := s.webhooks.Redeliver(*dl); err != nil {
+			return err
+		}
+		return s.dlq.MarkDeadLetterReplayed(id)
+	}
+
+	var p deadLetterPayload
+	if err := json.Unmarshal(dl.Payload, &p); err != nil {
+		return fmt.Errorf("ошибка разбора dead letter %d: %w", id, err)
+	}
+
+	ch, err := s.Mod.GetCh(dl.RespID)
+	if err != nil {
+		return fmt.Errorf("диалог %d неактивен, повтор невозможен: %w", dl.DialogID, err)
+	}
+
+	msgType := "user"
+	if p.Voice {
+		msgType = "user_voice"
+	}
+	content := model.AssistResponse{Message: strings.Join(p.Question, "\n")}
+	msg := s.Mod.NewMessage(p.Operator, msgType, &content, nil, p.Files...)
+	if err := ch.SendToRx(msg); err != nil {
+		return fmt.Errorf("ошибка повторной отправки dead letter %d: %w", id, err)
+	}
+
+	return s.dlq.MarkDeadLetterReplayed(id)
+}
@@ -0,0 +1,88 @@
+package startpoint
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/ikermy/AiR_Common/pkg/comdb"
+	"github.com/ikermy/AiR_Common/pkg/model"
+)
+
+type memDeadLetters struct {
+	items    map[uint64]*comdb.DeadLetter
+	replayed []uint64
+}
+
+func (m *memDeadLetters) SaveDeadLetter(dl comdb.DeadLetter) (uint64, error) {
+	if m.items == nil {
+		m.items = make(map[uint64]*comdb.DeadLetter)
+	}
+	dl.ID = uint64(len(m.items) + 1)
+	m.items[dl.ID] = &dl
+	return dl.ID, nil
+}
+
+func (m *memDeadLetters) ListDeadLetters(userID uint32, _ int) ([]comdb.DeadLetter, error) {
+	var out []comdb.DeadLetter
+	for _, dl := range m.items {
+		if dl.UserID == userID && dl.ReplayedAt == nil {
+			out = append(out, *dl)
+		}
+	}
+	return out, nil
+}
+
+func (m *memDeadLetters) GetDeadLetter(id uint64) (*comdb.DeadLetter, error) {
+	if dl, ok := m.items[id]; ok {
+		return dl, nil
+	}
+	return nil, fmt.Errorf("не найден")
+}
+
+func (m *memDeadLetters) MarkDeadLetterReplayed(id uint64) error {
+	m.replayed = append(m.replayed, id)
+	return nil
+}
+
+// chModel — минимальная реализация model.Inter для тестов: GetCh и NewMessage
+type chModel struct {
+	model.Inter
+	ch *model.Ch
+}
+
+func (c *chModel) GetCh(uint64) (*model.Ch, error) { return c.ch, nil }
+
+func (c *chModel) NewMessage(op model.Operator, msgType string, content *model.AssistResponse, _ *string, files ...model.FileUpload) model.Message {
+	return model.Message{Operator: op, Type: msgType, Content: *content, Files: files}
+}
+
+func TestDeadLetter_FatalAndReplay(t *testing.T) {
+	store := &memDeadLetters{}
+	ch := &model.Ch{RxCh: make(chan model.Message, 1), TxCh: make(chan model.Message, 1), DialogID: 5}
+	s := New(context.Background(), &chModel{ch: ch}, nil, nil, nil, WithDeadLetterStore(store))
+
+	u := &model.RespModel{Assist: model.Assistant{UserID: 9}}
+	turn := failedTurn{respId: 3, dialogID: 5, ask: []string{"первый", "второй"}, quest: Question{Voice: true}}
+	s.deadLetter(u, turn, DeadLetterFatal, errors.New("invalid api key"))
+
+	list, err := s.ListDeadLetters(9, 10)
+	if err != nil || len(list) != 1 {
+		t.Fatalf("expected 1 dead letter, got %d (%v)", len(list), err)
+	}
+	if list[0].Reason != DeadLetterFatal || list[0].Error != "invalid api key" {
+		t.Fatalf("unexpected dead letter: %+v", list[0])
+	}
+
+	if err := s.ReplayDeadLetter(list[0].ID); err != nil {
+		t.Fatalf("replay: %v", err)
+	}
+	msg := <-ch.RxCh
+	if msg.Type != "user_voice" || msg.Content.Message != "первый\nвторой" {
+		t.Fatalf("unexpected replayed message: %+v", msg)
+	}
+	if len(store.replayed) != 1 {
+		t.Fatalf("dead letter not marked as replayed")
+	}
+}
@@ -0,0 +1,75 @@
+package startpoint
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ikermy/AiR_Common/pkg/mode"
+	"github.com/ikermy/AiR_Common/pkg/model"
+)
+
+// dedupSweepInterval как часто чистить истёкшие ключи
+const dedupSweepInterval = 30 * time.Second
+
+// dedupCache отбрасывает повторно доставленные сообщения (например, повторные update от Telegram).
+// Ключ — (dialogID, ExternalID), а если ExternalID не задан — (dialogID, хэш содержимого)
+// с более коротким TTL, чтобы не глотать осознанные повторы пользователя.
+type dedupCache struct {
+	mu        sync.Mutex
+	seen      map[string]time.Time // ключ -> время истечения
+	lastSweep time.Time
+}
+
+func newDedupCache() *dedupCache {
+	return &dedupCache{seen: make(map[string]time.Time)}
+}
+
+// duplicate возвращает true, если сообщение уже было получено в пределах TTL
+func (c *dedupCache) duplicate(dialogID uint64, msg model.Message, now time.Time) bool {
+	if c == nil {
+		return false
+	}
+	key, ttl := dedupKey(dialogID, msg)
+	if ttl <= 0 {
+		return false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if now.Sub(c.lastSweep) >= dedupSweepInterval {
+		for k, exp := range c.seen {
+			if now.After(exp) {
+				delete(c.seen, k)
+			}
+		}
+		c.lastSweep = now
+	}
+
+	if exp, ok := c.seen[key]; ok && now.Before(exp) {
+		return true
+	}
+	c.seen[key] = now.Add(ttl)
+	return false
+}
+
+func dedupKey(dialogID uint64, msg model.Message) (string, time.Duration) {
+	prefix := strconv.FormatUint(dialogID, 10) + ":"
+	if msg.ExternalID != "" {
+		return prefix + "id:" + msg.ExternalID, mode.DedupTTL
+	}
+
+	h := sha256.New()
+	h.Write([]byte(msg.Type))
+	h.Write([]byte{0})
+	h.Write([]byte(msg.Content.Message))
+	for _, f := range msg.Files {
+		h.Write([]byte{0})
+		h.Write([]byte(f.Name))
+		h.Write([]byte(f.URL))
+	}
+	return prefix + "h:" + hex.EncodeToString(h.Sum(nil)[:16]), mode.DedupContentTTL
+}
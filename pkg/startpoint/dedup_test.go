@@ -0,0 +1,44 @@
+package startpoint
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ikermy/AiR_Common/pkg/mode"
+	"github.com/ikermy/AiR_Common/pkg/model"
+)
+
+func TestDedup_ByExternalID(t *testing.T) {
+	c := newDedupCache()
+	now := time.Now()
+	msg := model.Message{Type: "user", ExternalID: "tg-100", Content: model.AssistResponse{Message: "привет"}}
+
+	if c.duplicate(1, msg, now) {
+		t.Fatalf("first delivery must pass")
+	}
+	if !c.duplicate(1, msg, now.Add(time.Minute)) {
+		t.Fatalf("redelivery must be dropped")
+	}
+	if c.duplicate(2, msg, now) {
+		t.Fatalf("same id in another dialog must pass")
+	}
+	if c.duplicate(1, msg, now.Add(mode.DedupTTL+time.Second)) {
+		t.Fatalf("delivery after TTL must pass")
+	}
+}
+
+func TestDedup_ByContentHash(t *testing.T) {
+	c := newDedupCache()
+	now := time.Now()
+	msg := model.Message{Type: "user", Content: model.AssistResponse{Message: "да"}}
+
+	if c.duplicate(1, msg, now) {
+		t.Fatalf("first delivery must pass")
+	}
+	if !c.duplicate(1, msg, now.Add(time.Second)) {
+		t.Fatalf("fast identical redelivery must be dropped")
+	}
+	if c.duplicate(1, msg, now.Add(mode.DedupContentTTL+time.Second)) {
+		t.Fatalf("intentional repeat after content TTL must pass")
+	}
+}
@@ -0,0 +1,74 @@
+package startpoint
+
+import (
+	"github.com/ikermy/AiR_Common/pkg/model"
+)
+
+// deliverFunc доставляет ответ в активный диалог; false — диалог сейчас не может его принять
+type deliverFunc func(ans Answer) bool
+
+// DialogInfo описание активного диалога (для фильтров рассылки и мониторинга)
+type DialogInfo struct {
+	UserID     uint32 `json:"user_id"`
+	DialogID   uint64 `json:"dialog_id"`
+	RespID     uint64 `json:"resp_id"`
+	RespName   string `json:"resp_name"`
+	AssistName string `json:"assist_name"`
+	Provider   string `json:"provider"` // канал: telegram, whatsapp, ...
+}
+
+// activeDialog диалог, обслуживаемый Listener или пулом воркеров
+type activeDialog struct {
+	info    DialogInfo
+	deliver deliverFunc
+}
+
+func (s *Start) registerActive(u *model.RespModel, respId, treadId uint64, deliver deliverFunc) {
+	info := DialogInfo{
+		UserID:     u.Assist.UserID,
+		DialogID:   treadId,
+		RespID:     respId,
+		RespName:   u.RespName,
+		AssistName: u.Assist.AssistName,
+	}
+	info.Provider, _ = s.GetProviderForResponder(respId)
+	s.active.Store(treadId, &activeDialog{info: info, deliver: deliver})
+}
+
+func (s *Start) activeDialog(treadId uint64) (*activeDialog, bool) {
+	v, ok := s.active.Load(treadId)
+	if !ok {
+		return nil, false
+	}
+	return v.(*activeDialog), true
+}
+
+// ActiveDialogs возвращает активные диалоги, подходящие под filter (nil — все)
+func (s *Start) ActiveDialogs(filter func(DialogInfo) bool) []DialogInfo {
+	var out []DialogInfo
+	s.active.Range(func(_, v any) bool {
+		d := v.(*activeDialog)
+		if filter == nil || filter(d.info) {
+			out = append(out, d.info)
+		}
+		return true
+	})
+	return out
+}
+
+// answerChDeliverer доставляет ответ через answerCh Listener
+func answerChDeliverer(answerCh chan<- Answer) deliverFunc {
+	return func(ans Answer) (ok bool) {
+		defer func() {
+			if recover() != nil { // answerCh закрыт при завершении Listener
+				ok = false
+			}
+		}()
+		select {
+		case answerCh <- ans:
+			return true
+		default:
+			return false
+		}
+	}
+}
@@ -3,12 +3,12 @@ package startpoint
 import (
 	"errors"
 	"fmt"
-	"math"
 	"strings"
 	"time"
 
 	"github.com/ikermy/AiR_Common/pkg/mode"
 	"github.com/ikermy/AiR_Common/pkg/model"
+	"github.com/ikermy/AiR_Common/pkg/providererr"
 )
 
 // RetryableError представляет временную ошибку, которую можно повторить
@@ -83,11 +83,19 @@ func IsProviderLimitError(err error) bool {
 }
 
 // isProviderLimitError проверяет, связана ли ошибка с превышением лимита/квоты/подписки AI-провайдера
-// (429 Too Many Requests, rate limit exceeded, quota exceeded, billing errors и т.п.)
+// (429 Too Many Requests, rate limit exceeded, quota exceeded, billing errors и т.п.).
+// Ошибки клиентов провайдеров классифицированы providererr; текст проверяется для
+// остальных (SDK, Realtime)
 func isProviderLimitError(err error) bool {
 	if err == nil {
 		return false
 	}
+	if providererr.IsLimit(err) {
+		return true
+	}
+	if isClassified(err) {
+		return false
+	}
 	errStr := strings.ToLower(err.Error())
 	limitPatterns := []string{
 		"429 too many requests",
@@ -116,6 +124,12 @@ func isFatalErrorPattern(err error) bool {
 	if err == nil {
 		return false
 	}
+	if errors.Is(err, providererr.ErrAuth) {
+		return true
+	}
+	if isClassified(err) {
+		return false
+	}
 	errStr := err.Error()
 	fatalPatterns := []string{
 		"401", "403",
@@ -136,6 +150,12 @@ func isRetryableErrorPattern(err error) bool {
 	if err == nil {
 		return false
 	}
+	if providererr.IsTemporary(err) {
+		return true
+	}
+	if isClassified(err) {
+		return false
+	}
 	errStr := err.Error()
 	retryablePatterns := []string{
 		"500", "502", "503", "504",
@@ -158,20 +178,37 @@ func isRetryableErrorPattern(err error) bool {
 	return false
 }
 
+// isClassified ошибка — ответ API провайдера или ошибка с известным классом: по тексту
+// её не проверяем (тело ответа 400 может содержать "500" или "timeout")
+func isClassified(err error) bool {
+	var statusErr *providererr.StatusError
+	return errors.As(err, &statusErr) ||
+		errors.Is(err, providererr.ErrContentBlocked) || errors.Is(err, providererr.ErrContextTooLong)
+}
+
 // AskWithRetry выполняет запрос к модели с retry-логикой
 func (s *Start) AskWithRetry(userID uint32, respId, dialogID uint64, arrAsk []string, files ...model.FileUpload) (model.AssistResponse, error) {
 	var lastErr error
 
-	for attempt := 0; attempt < mode.RetryMaxAttempts; attempt++ {
+	if blocked, ok := s.moderateInput(userID, strings.Join(arrAsk, "\n")); ok {
+		return blocked, nil
+	}
+
+	rule := mode.Retry.Transient
+	for attempt := 0; attempt < rule.Attempts(); attempt++ {
 		response, err := s.ask(userID, respId, dialogID, arrAsk, files...)
 
 		if err == nil {
+			if blocked, ok := s.moderateOutput(userID, response); ok {
+				return blocked, nil
+			}
 			return response, nil
 		}
 
 		lastErr = err
 
-		// Лимитная ошибка провайдера (429, rate limit, quota, billing) — немедленный возврат
+		// Лимитная ошибка провайдера (429, rate limit, quota, billing) — немедленный возврат:
+		// 429 уже повторён HTTP-клиентом провайдера по mode.Retry.RateLimit
 		if isProviderLimitError(err) {
 			//logger.Warn("Лимитная ошибка провайдера для диалога %d: %v", dialogID, err)
 			return response, &ProviderLimitError{Err: err}
@@ -185,12 +222,12 @@ func (s *Start) AskWithRetry(userID uint32, respId, dialogID uint64, arrAsk []st
 
 		// Временная ошибка — retry
 		if isRetryableErrorPattern(err) {
-			if attempt == mode.RetryMaxAttempts-1 {
+			if attempt == rule.Attempts()-1 {
 				break
 			}
 
-			delay := time.Duration(mode.RetryBaseDelay) * time.Second * time.Duration(math.Pow(2, float64(attempt)))
-			//logger.Debug("Retry attempt %d/%d for dialog %d, waiting %v", attempt+1, mode.RetryMaxAttempts, dialogID, delay)
+			delay := rule.Backoff(attempt + 1)
+			//logger.Debug("Retry attempt %d/%d for dialog %d, waiting %v", attempt+1, rule.Attempts(), dialogID, delay)
 
 			select {
 			case <-s.ctx.Done():
@@ -206,6 +243,6 @@ func (s *Start) AskWithRetry(userID uint32, respId, dialogID uint64, arrAsk []st
 	}
 
 	// Все retry исчерпаны
-	//logger.Warn("Все %d попыток неуспешны для диалога %d", mode.RetryMaxAttempts, dialogID)
-	return model.AssistResponse{}, &NonCriticalError{Err: fmt.Errorf("все %d попыток неуспешны: %w", mode.RetryMaxAttempts, lastErr)}
+	//logger.Warn("Все %d попыток неуспешны для диалога %d", rule.Attempts(), dialogID)
+	return model.AssistResponse{}, &NonCriticalError{Err: fmt.Errorf("все %d попыток неуспешны: %w", rule.Attempts(), lastErr)}
 }
@@ -0,0 +1,64 @@
+package startpoint
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/ikermy/AiR_Common/pkg/model"
+	"github.com/ikermy/AiR_Common/pkg/providererr"
+)
+
+func TestErrorClassification_Typed(t *testing.T) {
+	limit := fmt.Errorf("ошибка запроса: %w", providererr.New("OpenAI", 429, http.Header{}, []byte("{}")))
+	auth := providererr.New("Mistral", 401, http.Header{}, nil)
+	unavailable := providererr.New("Google", 503, http.Header{}, nil)
+	// Тело 400 упоминает timeout, но это ошибка запроса — не повторяется
+	badRequest := providererr.New("Grok", 400, http.Header{}, []byte("invalid timeout parameter"))
+
+	if !isProviderLimitError(limit) || isFatalErrorPattern(limit) {
+		t.Error("429 — лимит провайдера")
+	}
+	if !isFatalErrorPattern(auth) || isRetryableErrorPattern(auth) {
+		t.Error("401 — критическая ошибка")
+	}
+	if !isRetryableErrorPattern(unavailable) {
+		t.Error("503 — временная ошибка")
+	}
+	if isRetryableErrorPattern(badRequest) || isFatalErrorPattern(badRequest) || isProviderLimitError(badRequest) {
+		t.Error("400 классифицирована по тексту")
+	}
+
+	// Ошибки без типа по-прежнему разбираются по тексту
+	if !isRetryableErrorPattern(errors.New("connection reset by peer")) {
+		t.Error("сетевая ошибка должна повторяться")
+	}
+}
+
+func TestHandleAskFailure_FatalFallback(t *testing.T) {
+	ch := &model.Ch{RxCh: make(chan model.Message, 1), TxCh: make(chan model.Message, 1), DialogID: 5}
+	end := &memEndpoint{}
+	s := New(context.Background(), &chModel{ch: ch}, end, nil, nil)
+	u := &model.RespModel{Assist: model.Assistant{UserID: 9, Fallback: &model.AssistResponse{Message: "Скоро вернёмся"}}}
+	errCh := make(chan error, 1)
+
+	fatal := &FatalError{Err: errors.New("invalid api key")}
+	if !s.handleAskFailure(u, fatal, make(chan Answer, 1), errCh, "критическая ошибка", failedTurn{respId: 3, dialogID: 5}) {
+		t.Fatal("критическая ошибка должна завершать Respondent")
+	}
+	if msg := <-ch.TxCh; msg.Content.Message != "Скоро вернёмся" {
+		t.Fatalf("ответ пользователю: %+v", msg)
+	}
+	if len(errCh) != 1 || len(end.events) != 1 || end.events[0] != EventFatalError {
+		t.Fatalf("ошибка %d, события %v", len(errCh), end.events)
+	}
+
+	// Без настройки ассистента — стандартный текст
+	u.Assist.Fallback = nil
+	s.fatalFallback(u, 3, 5, fatal)
+	if msg := <-ch.TxCh; msg.Content.Message != FatalFallbackMessage {
+		t.Fatalf("ответ по умолчанию: %+v", msg)
+	}
+}
@@ -0,0 +1,230 @@
+package startpoint
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ikermy/AiR_Common/pkg/comdb"
+	"github.com/ikermy/AiR_Common/pkg/mode"
+	"github.com/ikermy/AiR_Common/pkg/model"
+	"github.com/ikermy/AiR_Common/pkg/safego"
+)
+
+// OperatorJoinedMessage ответ пользователю, когда диалог дождался оператора
+const OperatorJoinedMessage = "👨‍💻 Оператор подключился к диалогу"
+
+// EscalationStore — хранилище очереди к операторам (реализуется *comdb.DB)
+type EscalationStore interface {
+	SaveEscalation(e comdb.Escalation) (uint64, error)
+	ListEscalations(userID uint32) ([]comdb.Escalation, error)
+	DeleteEscalation(dialogID uint64) error
+}
+
+// WithEscalationQueue включает очередь к операторам: если операторы на связи, но все заняты,
+// диалог встаёт в очередь с уведомлением о позиции вместо ожидания с фиксированным таймаутом
+// и переходит к оператору, когда тот освободится.
+//
+// Занятость определяется по OperatorFree реализации Operator (operator.Operator с WithRouter).
+// В режиме пула эскалация по флагу модели передаётся оператору напрямую, без очереди.
+func WithEscalationQueue(store EscalationStore) Option {
+	return func(s *Start) {
+		s.escalations = store
+	}
+}
+
+// queueUpdate событие очереди для Respondent: новая позиция или 0 — подошла очередь
+type queueUpdate struct {
+	position int
+}
+
+// escalationWaiter диалог, ожидающий оператора. Вопросы, пришедшие за время ожидания,
+// копятся в pending и уходят оператору вместе с исходным.
+type escalationWaiter struct {
+	updates chan queueUpdate
+
+	mu       sync.Mutex
+	pending  []Question
+	position int
+}
+
+func (w *escalationWaiter) add(quest Question) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.pending = append(w.pending, quest)
+}
+
+func (w *escalationWaiter) take() []Question {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	pending := w.pending
+	w.pending = nil
+	return pending
+}
+
+func (w *escalationWaiter) currentPosition() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.position
+}
+
+// moveTo сообщает Respondent новую позицию, если она изменилась
+func (w *escalationWaiter) moveTo(position int) {
+	w.mu.Lock()
+	changed := w.position != position
+	w.position = position
+	w.mu.Unlock()
+	if changed {
+		w.notify(queueUpdate{position: position})
+	}
+}
+
+// notify заменяет непрочитанное событие более свежим
+func (w *escalationWaiter) notify(upd queueUpdate) {
+	for {
+		select {
+		case w.updates <- upd:
+			return
+		default:
+		}
+		select {
+		case <-w.updates:
+		default:
+		}
+	}
+}
+
+// queuePositionMessage текст уведомления о месте в очереди
+func queuePositionMessage(position int) string {
+	return fmt.Sprintf("⏳ Все операторы заняты, вы %d-й в очереди", position)
+}
+
+// operatorFree true, если оператор может взять диалог прямо сейчас.
+// Реализации Operator без учёта занятости считаются всегда свободными.
+func (s *Start) operatorFree(userID uint32) bool {
+	if p, ok := s.Oper.(interface{ OperatorFree(uint32) bool }); ok {
+		return p.OperatorFree(userID)
+	}
+	return true
+}
+
+// needsQueue true, если вопрос к оператору нужно поставить в очередь
+func (s *Start) needsQueue(userID uint32, queued *escalationWaiter) bool {
+	return s.escalations != nil && (queued != nil || !s.operatorFree(userID))
+}
+
+// joinEscalationQueue ставит диалог в очередь (или добавляет вопрос к уже ожидающему)
+// и возвращает ожидание с актуальной позицией
+func (s *Start) joinEscalationQueue(u *model.RespModel, respId, treadId uint64, quest Question) (*escalationWaiter, error) {
+	if v, ok := s.escWaiters.Load(treadId); ok {
+		w := v.(*escalationWaiter)
+		w.add(quest)
+		return w, nil
+	}
+
+	if _, err := s.escalations.SaveEscalation(comdb.Escalation{
+		UserID:   u.Assist.UserID,
+		DialogID: treadId,
+		RespID:   respId,
+		Question: strings.Join(quest.Question, "\n"),
+		Voice:    quest.Voice,
+	}); err != nil {
+		return nil, fmt.Errorf("ошибка постановки диалога %d в очередь к оператору: %w", treadId, err)
+	}
+
+	w := &escalationWaiter{updates: make(chan queueUpdate, 1), pending: []Question{quest}}
+	s.escWaiters.Store(treadId, w)
+
+	queue, err := s.escalations.ListEscalations(u.Assist.UserID)
+	if err != nil {
+		// Без позиции диалог в очереди не остаётся: иначе следующий вопрос счёл бы его ожидающим
+		s.leaveEscalationQueue(treadId, true)
+		return nil, fmt.Errorf("ошибка чтения очереди к оператору: %w", err)
+	}
+	position := 0
+	for _, e := range queue {
+		if _, active := s.escWaiters.Load(e.DialogID); active {
+			position++
+		}
+		if e.DialogID == treadId {
+			break
+		}
+	}
+	w.mu.Lock()
+	w.position = position
+	w.mu.Unlock()
+	return w, nil
+}
+
+// leaveEscalationQueue снимает ожидание диалога. remove — удалить и запись в хранилище:
+// при завершении Start запись остаётся, чтобы после перезапуска диалог сохранил место.
+func (s *Start) leaveEscalationQueue(treadId uint64, remove bool) {
+	s.escWaiters.Delete(treadId)
+	if remove && s.escalations != nil {
+		if err := s.escalations.DeleteEscalation(treadId); err != nil {
+			//logger.Warn("escalation: не удалось удалить диалог %d из очереди: %v", treadId, err)
+		}
+	}
+}
+
+// OperatorQueue возвращает очередь к операторам пользователя (userID == 0 — всех)
+func (s *Start) OperatorQueue(userID uint32) ([]comdb.Escalation, error) {
+	if s.escalations == nil {
+		return nil, fmt.Errorf("очередь к операторам не подключена")
+	}
+	return s.escalations.ListEscalations(userID)
+}
+
+func (s *Start) runEscalationQueue() {
+	interval := mode.EscalationPollInterval
+	safego.Go("escalationQueue", func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-s.ctx.Done():
+				return
+			case <-ticker.C:
+				s.promoteEscalations(time.Now())
+			}
+		}
+	}, safego.WithRestart(-1, time.Second), safego.WithDone(s.ctx.Done()))
+}
+
+// promoteEscalations передаёт оператору первый ожидающий диалог каждого пользователя,
+// у которого освободился оператор, и обновляет позиции остальных.
+// Записи неактивных диалогов места в очереди не занимают и удаляются по mode.EscalationQueueTTL.
+func (s *Start) promoteEscalations(now time.Time) {
+	queue, err := s.escalations.ListEscalations(0)
+	if err != nil {
+		//logger.Error("escalation: ошибка чтения очереди: %v", err)
+		return
+	}
+
+	positions := make(map[uint32]int)
+	free := make(map[uint32]bool)
+	for _, e := range queue {
+		v, active := s.escWaiters.Load(e.DialogID)
+		if !active {
+			if now.Sub(e.CreatedAt) > mode.EscalationQueueTTL {
+				s.leaveEscalationQueue(e.DialogID, true)
+			}
+			continue
+		}
+		w := v.(*escalationWaiter)
+
+		if _, checked := free[e.UserID]; !checked {
+			// Одно продвижение на пользователя за опрос: занятость обновится после назначения
+			free[e.UserID] = s.operatorFree(e.UserID)
+			if free[e.UserID] {
+				free[e.UserID] = false
+				s.leaveEscalationQueue(e.DialogID, true)
+				w.notify(queueUpdate{position: 0})
+				continue
+			}
+		}
+		positions[e.UserID]++
+		w.moveTo(positions[e.UserID])
+	}
+}
@@ -0,0 +1,185 @@
+package startpoint
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ikermy/AiR_Common/pkg/comdb"
+	"github.com/ikermy/AiR_Common/pkg/mode"
+	"github.com/ikermy/AiR_Common/pkg/model"
+	"github.com/ikermy/AiR_Common/pkg/operator"
+)
+
+// memEscalations — EscalationStore в памяти
+type memEscalations struct {
+	mu     sync.Mutex
+	nextID uint64
+	queue  []comdb.Escalation
+}
+
+func (m *memEscalations) SaveEscalation(e comdb.Escalation) (uint64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, cur := range m.queue {
+		if cur.DialogID == e.DialogID {
+			return cur.ID, nil
+		}
+	}
+	m.nextID++
+	e.ID, e.CreatedAt = m.nextID, time.Now()
+	m.queue = append(m.queue, e)
+	return e.ID, nil
+}
+
+func (m *memEscalations) ListEscalations(userID uint32) ([]comdb.Escalation, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out []comdb.Escalation
+	for _, e := range m.queue {
+		if userID == 0 || e.UserID == userID {
+			out = append(out, e)
+		}
+	}
+	return out, nil
+}
+
+func (m *memEscalations) DeleteEscalation(dialogID uint64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, e := range m.queue {
+		if e.DialogID == dialogID {
+			m.queue = append(m.queue[:i], m.queue[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+// busyOperator — operator.Inter с управляемой занятостью
+type busyOperator struct {
+	operator.Inter
+	free atomic.Bool
+	mu   sync.Mutex
+	sent []string
+}
+
+func (o *busyOperator) OperatorFree(uint32) bool { return o.free.Load() }
+
+func (o *busyOperator) GetConnectionErrors(context.Context, uint32, uint64) <-chan string { return nil }
+
+func (o *busyOperator) ReceiveFromOperator(context.Context, uint32, uint64) <-chan model.Message {
+	return make(chan model.Message)
+}
+
+func (o *busyOperator) DeleteSession(uint32, uint64) error { return nil }
+
+func (o *busyOperator) SendToOperator(_ context.Context, _ uint32, _ uint64, msg model.Message) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.sent = append(o.sent, msg.Content.Message)
+	return nil
+}
+
+func TestEscalationQueue_PositionAndPromotion(t *testing.T) {
+	defer func(v time.Duration) { mode.EscalationPollInterval = v }(mode.EscalationPollInterval)
+	mode.EscalationPollInterval = time.Hour // продвигаем вручную
+
+	store := &memEscalations{}
+	oper := &busyOperator{}
+	s := New(context.Background(), &chModel{}, &memEndpoint{}, nil, oper, WithEscalationQueue(store))
+	defer s.cancel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	type dialog struct {
+		questionCh chan Question
+		answerCh   chan Answer
+	}
+	var dialogs []dialog
+	for id := uint64(1); id <= 2; id++ {
+		d := dialog{questionCh: make(chan Question, 1), answerCh: make(chan Answer, 4)}
+		u := &model.RespModel{Ctx: ctx, Assist: model.Assistant{UserID: 1}}
+		go s.Respondent(u, d.questionCh, d.answerCh, make(chan Answer, 4), id, id, make(chan error, 4))
+		dialogs = append(dialogs, d)
+	}
+
+	expect := func(d dialog, want string) Answer {
+		t.Helper()
+		select {
+		case ans := <-d.answerCh:
+			if ans.Answer.Message != want {
+				t.Fatalf("ожидали %q, получили %q", want, ans.Answer.Message)
+			}
+			return ans
+		case <-time.After(2 * time.Second):
+			t.Fatalf("нет ответа %q", want)
+		}
+		return Answer{}
+	}
+
+	for i, d := range dialogs {
+		d.questionCh <- Question{Question: []string{"нужен оператор"}, Operator: model.Operator{SetOperator: true}}
+		expect(d, queuePositionMessage(i+1))
+	}
+	if q, _ := s.OperatorQueue(1); len(q) != 2 {
+		t.Fatalf("в очереди %d диалогов", len(q))
+	}
+
+	oper.free.Store(true)
+	s.promoteEscalations(time.Now())
+
+	joined := expect(dialogs[0], OperatorJoinedMessage)
+	if !joined.Operator.SetOperator {
+		t.Fatalf("подключение оператора должно включать операторский режим у клиента")
+	}
+	expect(dialogs[1], queuePositionMessage(1))
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		oper.mu.Lock()
+		sent := strings.Join(oper.sent, ",")
+		oper.mu.Unlock()
+		if sent == "нужен оператор" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("оператору отправлено: %q", sent)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if q, _ := s.OperatorQueue(1); len(q) != 1 || q[0].DialogID != 2 {
+		t.Fatalf("после продвижения в очереди: %+v", q)
+	}
+}
+
+// failingListEscalations — хранилище очереди, которое не может прочитать очередь
+type failingListEscalations struct {
+	memEscalations
+}
+
+func (f *failingListEscalations) ListEscalations(uint32) ([]comdb.Escalation, error) {
+	return nil, errors.New("БД недоступна")
+}
+
+func TestJoinEscalationQueue_ListFailureLeavesNoWaiter(t *testing.T) {
+	store := &failingListEscalations{}
+	s := New(context.Background(), &chModel{}, &memEndpoint{}, nil, &busyOperator{}, WithEscalationQueue(store))
+	defer s.cancel()
+	u := &model.RespModel{Assist: model.Assistant{UserID: 1}}
+
+	if _, err := s.joinEscalationQueue(u, 1, 7, Question{Question: []string{"оператор"}}); err == nil {
+		t.Fatal("ожидали ошибку чтения очереди")
+	}
+	if _, ok := s.escWaiters.Load(uint64(7)); ok {
+		t.Fatal("ожидание осталось после ошибки")
+	}
+	if len(store.queue) != 0 {
+		t.Fatalf("запись осталась в хранилище: %+v", store.queue)
+	}
+}
@@ -0,0 +1,58 @@
+package startpoint
+
+import (
+	"github.com/ikermy/AiR_Common/pkg/events"
+	"github.com/ikermy/AiR_Common/pkg/logger"
+)
+
+// WithEventBus публикует события диалогов (SendEvent, Meta, жизненный цикл) в шину b
+// вместо прямых вызовов Endpoint и webhook. Start подписывает на шину запись в Endpoint
+// и, если задан WithWebhooks, отправку webhook; остальные подписчики (метрики, брокеры)
+// добавляются вызывающим. Ошибки Endpoint.Meta при этом только логируются.
+// Шину закрывает вызывающий после Shutdown.
+func WithEventBus(b *events.Bus) Option {
+	return func(s *Start) {
+		s.bus = b
+	}
+}
+
+// subscribeBus подписывает на шину доставку в Endpoint и webhook
+func (s *Start) subscribeBus() {
+	s.bus.Subscribe("endpoint", s.deliverEndpoint, events.Notify, events.Meta)
+	if s.webhooks != nil {
+		s.bus.Subscribe("webhook", s.webhooks.Handle,
+			events.DialogStarted, events.DialogEnded, events.TargetReached, events.OperatorRequested, events.Error, events.Alert,
+			events.AssistantSwitched, events.CSATRequested, events.CSATRated)
+	}
+}
+
+// deliverEndpoint подписчик шины: передаёт Notify и Meta в Endpoint
+func (s *Start) deliverEndpoint(e events.Event) {
+	switch e.Type {
+	case events.Notify:
+		s.End.SendEvent(e.UserID, e.Name, e.RespName, e.AssistName, e.Target)
+	case events.Meta:
+		if err := s.End.Meta(e.UserID, e.DialogID, e.Name, e.RespName, e.AssistName, e.Target); err != nil {
+			logger.ForDialog(e.UserID, e.DialogID).Error("events: ошибка Meta "+e.Name, "err", err)
+		}
+	}
+}
+
+// sendEvent уведомление каналам: через шину или напрямую Endpoint.SendEvent
+func (s *Start) sendEvent(userID uint32, event, respName, assistName, data string) {
+	if s.bus == nil {
+		s.End.SendEvent(userID, event, respName, assistName, data)
+		return
+	}
+	s.bus.Publish(events.Event{Type: events.Notify, Name: event, UserID: userID, RespName: respName, AssistName: assistName, Target: data})
+}
+
+// meta метка диалога: через шину (ошибка записи логируется подписчиком) или напрямую Endpoint.Meta
+func (s *Start) meta(userID uint32, treadId uint64, name, respName, assistName, target string) error {
+	if s.bus == nil {
+		return s.End.Meta(userID, treadId, name, respName, assistName, target)
+	}
+	s.bus.Publish(events.Event{Type: events.Meta, Name: name, UserID: userID, DialogID: treadId, RespName: respName, AssistName: assistName, Target: target,
+		Data: s.withVariant(userID, treadId, nil)})
+	return nil
+}
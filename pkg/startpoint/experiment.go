@@ -0,0 +1,55 @@
+package startpoint
+
+import (
+	"encoding/json"
+
+	"github.com/ikermy/AiR_Common/pkg/experiment"
+	"github.com/ikermy/AiR_Common/pkg/logger"
+)
+
+// MetaExperiment имя метки Meta с вариантом эксперимента диалога ("эксперимент/вариант")
+const MetaExperiment = "experiment"
+
+// WithExperiments помечает диалоги вариантами A/B-экспериментов r: метка MetaExperiment при старте
+// диалога, поля experiment/variant в событиях webhook/шины и в token_usage.
+// Настройки варианта применяет модель (тот же Registry передаётся в model.WithExperiments).
+func WithExperiments(r *experiment.Registry) Option {
+	return func(s *Start) {
+		s.experiments = r
+	}
+}
+
+// labelDialogStart записывает вариант эксперимента диалога в Meta
+func (s *Start) labelDialogStart(userID uint32, treadId uint64, respName, assistName string) {
+	a, ok := s.experiments.Assign(userID, treadId)
+	if !ok {
+		return
+	}
+	if err := s.meta(userID, treadId, MetaExperiment, respName, assistName, a.Label()); err != nil {
+		logger.ForDialog(userID, treadId).Warn("experiment: ошибка записи варианта", "err", err)
+	}
+}
+
+// withVariant добавляет в data поля experiment и variant диалога (data копируется)
+func (s *Start) withVariant(userID uint32, treadId uint64, data map[string]any) map[string]any {
+	a, ok := s.experiments.Assign(userID, treadId)
+	if !ok {
+		return data
+	}
+	out := make(map[string]any, len(data)+2)
+	for k, v := range data {
+		out[k] = v
+	}
+	out["experiment"] = a.Experiment
+	out["variant"] = a.Name
+	return out
+}
+
+// labelUsage добавляет вариант эксперимента в событие token_usage
+func (s *Start) labelUsage(userID uint32, treadId uint64, event map[string]any) string {
+	data, err := json.Marshal(s.withVariant(userID, treadId, event))
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
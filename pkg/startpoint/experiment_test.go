@@ -0,0 +1,61 @@
+package startpoint
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/ikermy/AiR_Common/pkg/events"
+	"github.com/ikermy/AiR_Common/pkg/experiment"
+	"github.com/ikermy/AiR_Common/pkg/model"
+	"github.com/ikermy/AiR_Common/pkg/webhook"
+)
+
+func TestExperiments_LabelEvents(t *testing.T) {
+	reg := experiment.NewRegistry()
+	if err := reg.Set(1, experiment.Experiment{Name: "temp", Variants: []experiment.Variant{{Name: "a"}, {Name: "b"}}}); err != nil {
+		t.Fatal(err)
+	}
+	want, _ := reg.Assign(1, 7)
+
+	bus := events.New(context.Background())
+	var (
+		mu  sync.Mutex
+		got []events.Event
+	)
+	bus.Subscribe("test", func(e events.Event) {
+		mu.Lock()
+		got = append(got, e)
+		mu.Unlock()
+	}, events.DialogStarted, events.Meta)
+	end := &metaEndpoint{}
+	s := New(context.Background(), nil, end, nil, nil, WithEventBus(bus), WithExperiments(reg))
+	u := &model.RespModel{Assist: model.Assistant{UserID: 1}}
+
+	s.emit(u, 2, 7, webhook.EventDialogStarted, nil)
+	s.labelDialogStart(1, 7, "resp", "assist")
+	usage := s.labelUsage(1, 7, map[string]any{"type": "token_usage"})
+	bus.Close()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(got) != 2 {
+		t.Fatalf("ожидали 2 события, получили %d", len(got))
+	}
+	for _, e := range got {
+		if e.Data["experiment"] != "temp" || e.Data["variant"] != want.Name {
+			t.Fatalf("событие %s без варианта: %v", e.Type, e.Data)
+		}
+	}
+	if got[1].Name != MetaExperiment || got[1].Target != want.Label() {
+		t.Fatalf("метка Meta: %+v", got[1])
+	}
+	if usage != `{"experiment":"temp","type":"token_usage","variant":"`+want.Name+`"}` {
+		t.Fatalf("token_usage: %s", usage)
+	}
+
+	// Диалоги пользователя без эксперимента не помечаются
+	if data := s.withVariant(2, 7, nil); data != nil {
+		t.Fatalf("лишние поля: %v", data)
+	}
+}
@@ -0,0 +1,124 @@
+package startpoint
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ikermy/AiR_Common/pkg/model"
+	"github.com/ikermy/AiR_Common/pkg/safego"
+)
+
+// EventFollowUp событие SendEvent об отправленном напоминании
+const EventFollowUp = "follow_up"
+
+// followUp напоминает пользователю, замолчавшему после ответа ассистента.
+// Таймер взводится после каждого доставленного сообщения ассистента и сбрасывается
+// сообщением пользователя; подряд отправляется не больше FollowUp.Max напоминаний.
+type followUp struct {
+	s       *Start
+	u       *model.RespModel
+	respId  uint64
+	treadId uint64
+	deliver deliverFunc
+
+	mu      sync.Mutex
+	timer   *time.Timer
+	sent    uint8 // напоминаний с последнего сообщения пользователя
+	stopped bool
+}
+
+// newFollowUp возвращает nil, если у ассистента нет правила напоминания
+func (s *Start) newFollowUp(u *model.RespModel, respId, treadId uint64, deliver deliverFunc) *followUp {
+	rule := u.Assist.FollowUp
+	if rule.After == 0 || (rule.Message == "" && rule.Prompt == "") {
+		return nil
+	}
+	return &followUp{s: s, u: u, respId: respId, treadId: treadId, deliver: deliver}
+}
+
+// userActive сбрасывает таймер и счётчик: пользователь ответил
+func (f *followUp) userActive() {
+	if f == nil {
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.sent = 0
+	if f.timer != nil {
+		f.timer.Stop()
+	}
+}
+
+// assistantSent взводит таймер после сообщения ассистента.
+// Ответы оператора таймер не взводят — диалог ведёт человек.
+func (f *followUp) assistantSent(op model.Operator) {
+	if f == nil || op.Operator {
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.stopped || f.sent >= f.max() {
+		return
+	}
+	after := time.Duration(f.u.Assist.FollowUp.After) * time.Minute
+	if f.timer == nil {
+		f.timer = time.AfterFunc(after, f.fire)
+		return
+	}
+	f.timer.Reset(after)
+}
+
+// stop отключает напоминания при завершении диалога
+func (f *followUp) stop() {
+	if f == nil {
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.stopped = true
+	if f.timer != nil {
+		f.timer.Stop()
+	}
+}
+
+func (f *followUp) max() uint8 {
+	if f.u.Assist.FollowUp.Max == 0 {
+		return 1
+	}
+	return f.u.Assist.FollowUp.Max
+}
+
+func (f *followUp) fire() {
+	f.mu.Lock()
+	if f.stopped || f.sent >= f.max() {
+		f.mu.Unlock()
+		return
+	}
+	f.sent++
+	f.mu.Unlock()
+
+	safego.Go("followUp", func() {
+		text := f.text()
+		if text == "" {
+			return
+		}
+		if !f.deliver(Answer{Answer: model.AssistResponse{Message: text}}) {
+			return
+		}
+		f.s.sendEvent(f.u.Assist.UserID, EventFollowUp, f.u.RespName, f.u.Assist.AssistName, text)
+	}, safego.WithUserID(f.u.Assist.UserID))
+}
+
+// text возвращает текст напоминания: ответ модели на Prompt или фиксированный Message
+func (f *followUp) text() string {
+	rule := f.u.Assist.FollowUp
+	if rule.Prompt != "" {
+		answer, err := f.s.AskWithRetry(f.u.Assist.UserID, f.respId, f.treadId, []string{rule.Prompt})
+		if err == nil && strings.TrimSpace(answer.Message) != "" {
+			return answer.Message
+		}
+		//logger.Warn("followUp: модель не сгенерировала напоминание dialogID=%d: %v", f.treadId, err, f.u.Assist.UserID)
+	}
+	return rule.Message
+}
@@ -0,0 +1,79 @@
+package startpoint
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ikermy/AiR_Common/pkg/model"
+	"github.com/ikermy/AiR_Common/pkg/model/create"
+)
+
+func TestFollowUp_LimitAndReset(t *testing.T) {
+	end := &memEndpoint{}
+	s := &Start{End: end}
+	u := &model.RespModel{Assist: model.Assistant{
+		UserID:   1,
+		FollowUp: create.FollowUpConfig{After: 60, Message: "вы ещё здесь?", Max: 2},
+	}}
+
+	delivered := make(chan Answer, 4)
+	f := s.newFollowUp(u, 1, 1, func(ans Answer) bool {
+		delivered <- ans
+		return true
+	})
+	defer f.stop()
+
+	wait := func() Answer {
+		select {
+		case ans := <-delivered:
+			return ans
+		case <-time.After(time.Second):
+			t.Fatalf("follow-up was not delivered")
+		}
+		return Answer{}
+	}
+
+	f.fire()
+	if ans := wait(); ans.Answer.Message != "вы ещё здесь?" {
+		t.Fatalf("unexpected follow-up: %+v", ans)
+	}
+	f.fire()
+	wait()
+	f.fire() // лимит исчерпан
+	select {
+	case <-delivered:
+		t.Fatalf("follow-up limit exceeded")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	f.userActive()
+	f.fire()
+	wait()
+
+	// Событие отправляется после доставки, ждём его
+	deadline := time.Now().Add(time.Second)
+	for {
+		end.mu.Lock()
+		events := append([]string(nil), end.events...)
+		end.mu.Unlock()
+		if len(events) == 3 && events[0] == EventFollowUp {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("unexpected events: %v", events)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestFollowUp_DisabledWithoutRule(t *testing.T) {
+	s := &Start{}
+	f := s.newFollowUp(&model.RespModel{}, 1, 1, nil)
+	if f != nil {
+		t.Fatalf("follow-up must be disabled without rule")
+	}
+	// Методы безопасны для nil
+	f.userActive()
+	f.assistantSent(model.Operator{})
+	f.stop()
+}
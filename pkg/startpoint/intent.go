@@ -0,0 +1,203 @@
+package startpoint
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ikermy/AiR_Common/pkg/logger"
+	"github.com/ikermy/AiR_Common/pkg/mode"
+	"github.com/ikermy/AiR_Common/pkg/model"
+	"github.com/ikermy/AiR_Common/pkg/safego"
+)
+
+// Intent намерение пользователя и действие перед запросом к модели. Действия
+// проверяются по порядку: Reply, Operator, Assistant; без действий намерение только
+// помечается Meta
+type Intent struct {
+	Name      string
+	Examples  []string              // фразы-примеры для NewCentroidDetector
+	Reply     *model.AssistResponse // готовый ответ: модель не запрашивается
+	Operator  bool                  // вопрос сразу адресуется оператору
+	Assistant string                // ключ ассистента (AssistantRoute.ID) для SwitchAssistant
+	Meta      string                // имя Meta; пусто — "intent:" + Name
+}
+
+// IntentMatch результат классификации: имя намерения ("" — не определено) и уверенность 0..1
+type IntentMatch struct {
+	Name  string
+	Score float32
+}
+
+// IntentDetector определяет намерение вопроса: маленькая быстрая модель,
+// NewCentroidDetector или своя реализация
+type IntentDetector func(userID uint32, text string) (IntentMatch, error)
+
+// IntentRouting классификатор намерений, который запускается перед запросом к модели
+type IntentRouting struct {
+	Detect   IntentDetector
+	Intents  []Intent
+	MinScore float32 // 0 — mode.IntentMinScore
+}
+
+// WithIntentRouting классифицирует собранный вопрос пользователя до запроса к модели.
+// Распознанное намерение помечается Meta и может ответить готовым текстом, передать
+// вопрос оператору или сменить ассистента диалога (нужен WithAssistantRouting).
+// Ошибка или тайм-аут (mode.IntentTimeout) классификатора не задерживают ответ модели
+func WithIntentRouting(r IntentRouting) Option {
+	return func(s *Start) {
+		if r.Detect == nil || len(r.Intents) == 0 {
+			return
+		}
+		if r.MinScore <= 0 {
+			r.MinScore = mode.IntentMinScore
+		}
+		s.intents = &r
+	}
+}
+
+// NewCentroidDetector классификатор по эмбеддингам: для каждого намерения строится
+// центроид эмбеддингов Examples, вопрос относится к ближайшему по косинусной близости.
+// Центроиды считаются при первом вопросе пользователя (ключи эмбеддингов у каждого свои)
+func NewCentroidDetector(embed model.SemanticEmbedFunc, intents []Intent) IntentDetector {
+	var centroids sync.Map // uint32 -> []intentCentroid
+	return func(userID uint32, text string) (IntentMatch, error) {
+		var list []intentCentroid
+		if v, ok := centroids.Load(userID); ok {
+			list = v.([]intentCentroid)
+		} else {
+			built, err := buildCentroids(embed, userID, intents)
+			if err != nil {
+				return IntentMatch{}, err
+			}
+			centroids.Store(userID, built)
+			list = built
+		}
+
+		vec, err := embed(userID, text)
+		if err != nil {
+			return IntentMatch{}, fmt.Errorf("ошибка эмбеддинга вопроса: %w", err)
+		}
+		var best IntentMatch
+		for _, c := range list {
+			if score := cosineSim(vec, c.vec); score > best.Score {
+				best = IntentMatch{Name: c.name, Score: score}
+			}
+		}
+		return best, nil
+	}
+}
+
+type intentCentroid struct {
+	name string
+	vec  []float32
+}
+
+func buildCentroids(embed model.SemanticEmbedFunc, userID uint32, intents []Intent) ([]intentCentroid, error) {
+	out := make([]intentCentroid, 0, len(intents))
+	for _, in := range intents {
+		var sum []float32
+		n := 0
+		for _, ex := range in.Examples {
+			vec, err := embed(userID, ex)
+			if err != nil {
+				return nil, fmt.Errorf("ошибка эмбеддинга примера намерения %s: %w", in.Name, err)
+			}
+			if sum == nil {
+				sum = make([]float32, len(vec))
+			}
+			if len(vec) != len(sum) {
+				continue
+			}
+			for i, x := range vec {
+				sum[i] += x
+			}
+			n++
+		}
+		if n == 0 {
+			continue
+		}
+		for i := range sum {
+			sum[i] /= float32(n)
+		}
+		out = append(out, intentCentroid{name: in.Name, vec: sum})
+	}
+	return out, nil
+}
+
+// cosineSim косинусная близость; векторы разной размерности не сравниваются
+func cosineSim(a, b []float32) float32 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return float32(dot / (math.Sqrt(normA) * math.Sqrt(normB)))
+}
+
+// classifyIntent определяет намерение вопроса и помечает диалог Meta; nil — намерение
+// не распознано, классификатор не подключён или не ответил вовремя
+func (s *Start) classifyIntent(u *model.RespModel, treadId uint64, question string, errCh chan<- error) *Intent {
+	r := s.intents
+	if r == nil || strings.TrimSpace(question) == "" {
+		return nil
+	}
+	log := logger.ForDialog(u.Assist.UserID, treadId)
+
+	type result struct {
+		match IntentMatch
+		err   error
+	}
+	done := make(chan result, 1)
+	safego.Go("intent.detect", func() {
+		m, err := r.Detect(u.Assist.UserID, question)
+		done <- result{m, err}
+	}, safego.WithUserID(u.Assist.UserID))
+
+	var res result
+	timer := time.NewTimer(mode.IntentTimeout)
+	defer timer.Stop()
+	select {
+	case res = <-done:
+	case <-timer.C:
+		log.Warn("intent: классификатор не ответил вовремя", "timeout", mode.IntentTimeout)
+		return nil
+	}
+	if res.err != nil {
+		log.Warn("intent: ошибка классификации", "err", res.err)
+		return nil
+	}
+	if res.match.Name == "" || res.match.Score < r.MinScore {
+		return nil
+	}
+
+	var in *Intent
+	for i := range r.Intents {
+		if r.Intents[i].Name == res.match.Name {
+			in = &r.Intents[i]
+			break
+		}
+	}
+	if in == nil {
+		return nil
+	}
+	log.Info("intent: намерение распознано", "intent", in.Name, "score", res.match.Score)
+
+	meta := in.Meta
+	if meta == "" {
+		meta = "intent:" + in.Name
+	}
+	if err := s.meta(u.Assist.UserID, treadId, meta, u.RespName, u.Assist.AssistName, u.Assist.Metas.MetaAction); err != nil {
+		s.sendError(errCh, fmt.Errorf("ошибка Meta %s userID=%d dialogID=%d: %w", meta, u.Assist.UserID, treadId, err))
+	}
+	return in
+}
@@ -0,0 +1,73 @@
+package startpoint
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/ikermy/AiR_Common/pkg/model"
+)
+
+// wordEmbed эмбеддинг по словарю: координата на каждое слово
+func wordEmbed(_ uint32, text string) ([]float32, error) {
+	words := []string{"цена", "стоимость", "оператор", "человек", "погода"}
+	vec := make([]float32, len(words))
+	lower := strings.ToLower(text)
+	for i, w := range words {
+		if strings.Contains(lower, w) {
+			vec[i] = 1
+		}
+	}
+	return vec, nil
+}
+
+func TestCentroidDetector(t *testing.T) {
+	intents := []Intent{
+		{Name: "price", Examples: []string{"какая цена", "стоимость тарифа"}},
+		{Name: "human", Examples: []string{"позовите оператора", "хочу с человеком"}},
+	}
+	detect := NewCentroidDetector(wordEmbed, intents)
+
+	m, err := detect(1, "Сколько стоимость и цена?")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Name != "price" || m.Score < 0.99 {
+		t.Fatalf("got %+v, want price", m)
+	}
+	if m, _ = detect(1, "какая погода"); m.Score != 0 {
+		t.Fatalf("unrelated question matched: %+v", m)
+	}
+}
+
+func TestClassifyIntent_ThresholdAndMeta(t *testing.T) {
+	end := &metaEndpoint{}
+	s := &Start{ctx: context.Background(), End: end}
+	reply := &model.AssistResponse{Message: "Тарифы: example.com/price"}
+	WithIntentRouting(IntentRouting{
+		Detect: NewCentroidDetector(wordEmbed, []Intent{{Name: "price", Examples: []string{"цена"}}}),
+		Intents: []Intent{
+			{Name: "price", Reply: reply},
+		},
+		MinScore: 0.9,
+	})(s)
+
+	u := &model.RespModel{Assist: model.Assistant{UserID: 1}}
+	errCh := make(chan error, 1)
+
+	in := s.classifyIntent(u, 5, "Какая цена?", errCh)
+	if in == nil || in.Reply != reply {
+		t.Fatalf("intent not recognized: %+v", in)
+	}
+	if len(end.metas) != 1 || end.metas[0] != "intent:price" {
+		t.Fatalf("metas = %v", end.metas)
+	}
+
+	// Уверенность ниже порога — вопрос уходит модели
+	if in = s.classifyIntent(u, 5, "цена и погода", errCh); in != nil {
+		t.Fatalf("low score intent returned: %+v", in)
+	}
+	if len(end.metas) != 1 {
+		t.Fatalf("meta for low score: %v", end.metas)
+	}
+}
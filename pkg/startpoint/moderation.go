@@ -0,0 +1,72 @@
+package startpoint
+
+import (
+	"strings"
+
+	"github.com/ikermy/AiR_Common/pkg/model"
+)
+
+// ModerationRefusalMessage ответ пользователю вместо отклонённого модерацией вопроса или ответа
+const ModerationRefusalMessage = "Извините, я не могу ответить на это сообщение."
+
+// ModerationPolicy что проверять модерацией и как реагировать на нарушение
+type ModerationPolicy struct {
+	Input    bool   // проверять вопрос пользователя до запроса к модели
+	Output   bool   // проверять ответ модели до отправки клиенту; дельты стриминга при этом не отправляются
+	Escalate bool   // передать диалог оператору вместо отказа
+	Refusal  string // текст отказа; пусто — ModerationRefusalMessage
+}
+
+// WithModeration подключает модерацию вопросов и ответов (model.Router, openai/google/mistral Model).
+// Ошибка модерации не блокирует диалог: ответ важнее недоступной проверки
+func WithModeration(m model.Moderator, policy ModerationPolicy) Option {
+	return func(s *Start) {
+		if m != nil {
+			s.moderator = m
+			s.moderation = policy
+		}
+	}
+}
+
+// moderatesOutput ответы модели проверяются до отправки клиенту
+func (s *Start) moderatesOutput() bool {
+	return s.moderator != nil && s.moderation.Output
+}
+
+// moderateInput проверяет вопрос. ok == true — вместо запроса к модели вернуть blocked
+func (s *Start) moderateInput(userID uint32, question string) (blocked model.AssistResponse, ok bool) {
+	if s.moderator == nil || !s.moderation.Input {
+		return model.AssistResponse{}, false
+	}
+	return s.moderate(userID, question)
+}
+
+// moderateOutput проверяет ответ модели. ok == true — клиенту уходит blocked вместо ответа
+func (s *Start) moderateOutput(userID uint32, answer model.AssistResponse) (blocked model.AssistResponse, ok bool) {
+	if !s.moderatesOutput() {
+		return model.AssistResponse{}, false
+	}
+	return s.moderate(userID, answer.Message)
+}
+
+func (s *Start) moderate(userID uint32, text string) (model.AssistResponse, bool) {
+	if strings.TrimSpace(text) == "" {
+		return model.AssistResponse{}, false
+	}
+	result, err := s.moderator.Moderate(s.ctx, userID, text)
+	if err != nil || !result.Flagged {
+		//logger.Warn("moderation: проверка недоступна: %v", err, userID)
+		return model.AssistResponse{}, false
+	}
+
+	blocked := model.AssistResponse{Blocked: true, BlockReasons: result.Categories}
+	if s.moderation.Escalate {
+		blocked.Operator = true
+		return blocked, true
+	}
+	blocked.Message = s.moderation.Refusal
+	if blocked.Message == "" {
+		blocked.Message = ModerationRefusalMessage
+	}
+	return blocked, true
+}
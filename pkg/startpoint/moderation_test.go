@@ -0,0 +1,49 @@
+package startpoint
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/ikermy/AiR_Common/pkg/model"
+)
+
+type stubModerator struct {
+	result model.ModerationResult
+	err    error
+}
+
+func (m stubModerator) Moderate(context.Context, uint32, string) (model.ModerationResult, error) {
+	return m.result, m.err
+}
+
+func TestModeratePolicy(t *testing.T) {
+	flagged := stubModerator{result: model.ModerationResult{Flagged: true, Categories: []string{"hate"}}}
+	cases := []struct {
+		name      string
+		moderator model.Moderator
+		policy    ModerationPolicy
+		blocked   bool
+		operator  bool
+		message   string
+	}{
+		{"input disabled", flagged, ModerationPolicy{Output: true}, false, false, ""},
+		{"refusal", flagged, ModerationPolicy{Input: true}, true, false, ModerationRefusalMessage},
+		{"custom refusal", flagged, ModerationPolicy{Input: true, Refusal: "нет"}, true, false, "нет"},
+		{"escalate", flagged, ModerationPolicy{Input: true, Escalate: true}, true, true, ""},
+		{"clean", stubModerator{}, ModerationPolicy{Input: true}, false, false, ""},
+		{"fails open", stubModerator{err: errors.New("503")}, ModerationPolicy{Input: true}, false, false, ""},
+	}
+	for _, c := range cases {
+		s := &Start{ctx: context.Background()}
+		WithModeration(c.moderator, c.policy)(s)
+
+		resp, ok := s.moderateInput(1, "текст")
+		if ok != c.blocked || resp.Operator != c.operator || resp.Message != c.message {
+			t.Errorf("%s: ok=%v resp=%+v", c.name, ok, resp)
+		}
+		if ok && (!resp.Blocked || len(resp.BlockReasons) != 1) {
+			t.Errorf("%s: block reasons not set: %+v", c.name, resp)
+		}
+	}
+}
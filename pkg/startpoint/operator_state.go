@@ -0,0 +1,80 @@
+package startpoint
+
+import (
+	"time"
+
+	"github.com/ikermy/AiR_Common/pkg/comdb"
+	"github.com/ikermy/AiR_Common/pkg/logger"
+	"github.com/ikermy/AiR_Common/pkg/mode"
+	"github.com/ikermy/AiR_Common/pkg/model"
+)
+
+// OperatorStateStore — хранилище операторского режима диалогов (реализуется *comdb.DB)
+type OperatorStateStore interface {
+	SaveOperatorState(st comdb.OperatorState) error
+	GetOperatorState(dialogID uint64) (*comdb.OperatorState, error)
+	DeleteOperatorState(dialogID uint64) error
+}
+
+// WithOperatorStateStore сохраняет операторский режим диалогов. После перезапуска Respondent
+// диалога снова подключается к оператору, а не возвращает пользователя к AI посреди разговора.
+// Состояние удаляется при завершении диалога и не восстанавливается старше mode.OperatorStateTTL
+// или без подключённого Operator
+func WithOperatorStateStore(store OperatorStateStore) Option {
+	return func(s *Start) {
+		s.opStates = store
+	}
+}
+
+// persistOperatorMode сохраняет включение (on) или выключение операторского режима диалога
+func (s *Start) persistOperatorMode(u *model.RespModel, respId, treadId uint64, on bool, since time.Time) {
+	if s.opStates == nil {
+		return
+	}
+	var err error
+	if on {
+		err = s.opStates.SaveOperatorState(comdb.OperatorState{
+			UserID:   u.Assist.UserID,
+			DialogID: treadId,
+			RespID:   respId,
+			Since:    since,
+		})
+	} else {
+		err = s.opStates.DeleteOperatorState(treadId)
+	}
+	if err != nil {
+		logger.ForDialog(u.Assist.UserID, treadId).Warn("не удалось сохранить операторский режим", "on", on, "err", err)
+	}
+}
+
+// restoreOperatorMode операторский режим, в котором диалог был до перезапуска;
+// since — начало режима для сводки модели после выхода
+func (s *Start) restoreOperatorMode(u *model.RespModel, treadId uint64) (since time.Time, ok bool) {
+	if s.opStates == nil || s.Oper == nil {
+		return time.Time{}, false
+	}
+	st, err := s.opStates.GetOperatorState(treadId)
+	if err != nil {
+		logger.ForDialog(u.Assist.UserID, treadId).Warn("не удалось прочитать операторский режим", "err", err)
+		return time.Time{}, false
+	}
+	if st == nil {
+		return time.Time{}, false
+	}
+	if time.Since(st.Since) > mode.OperatorStateTTL {
+		s.persistOperatorMode(u, st.RespID, treadId, false, time.Time{})
+		return time.Time{}, false
+	}
+	logger.ForDialog(u.Assist.UserID, treadId).Info("восстановлен операторский режим", "since", st.Since)
+	return st.Since, true
+}
+
+// clearOperatorMode удаляет операторский режим завершённого диалога, чтобы вернувшийся
+// пользователь начинал с AI. При остановке процесса (s.ctx отменён) состояние сохраняется
+// для восстановления после перезапуска
+func (s *Start) clearOperatorMode(u *model.RespModel, respId, treadId uint64) {
+	if s.opStates == nil || s.ctx == nil || s.ctx.Err() != nil {
+		return
+	}
+	s.persistOperatorMode(u, respId, treadId, false, time.Time{})
+}
@@ -0,0 +1,114 @@
+package startpoint
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ikermy/AiR_Common/pkg/comdb"
+	"github.com/ikermy/AiR_Common/pkg/mode"
+	"github.com/ikermy/AiR_Common/pkg/model"
+	"github.com/ikermy/AiR_Common/pkg/webhook"
+)
+
+// memOperatorStates — OperatorStateStore в памяти
+type memOperatorStates struct {
+	m map[uint64]comdb.OperatorState
+}
+
+func (s *memOperatorStates) SaveOperatorState(st comdb.OperatorState) error {
+	if s.m == nil {
+		s.m = make(map[uint64]comdb.OperatorState)
+	}
+	s.m[st.DialogID] = st
+	return nil
+}
+
+func (s *memOperatorStates) GetOperatorState(dialogID uint64) (*comdb.OperatorState, error) {
+	st, ok := s.m[dialogID]
+	if !ok {
+		return nil, nil
+	}
+	return &st, nil
+}
+
+func (s *memOperatorStates) DeleteOperatorState(dialogID uint64) error {
+	delete(s.m, dialogID)
+	return nil
+}
+
+func TestOperatorState_PersistAndRestore(t *testing.T) {
+	store := &memOperatorStates{}
+	s := &Start{opStates: store, Oper: offlineOperator{}}
+	u := &model.RespModel{Assist: model.Assistant{UserID: 7}}
+	since := time.Now().Add(-10 * time.Minute).Truncate(time.Second)
+
+	if _, ok := s.restoreOperatorMode(u, 5); ok {
+		t.Fatalf("dialog without operator state must stay with AI")
+	}
+
+	s.persistOperatorMode(u, 3, 5, true, since)
+	got, ok := s.restoreOperatorMode(u, 5)
+	if !ok || !got.Equal(since) {
+		t.Fatalf("expected operator mode since %v, got %v (ok=%v)", since, got, ok)
+	}
+	if st := store.m[5]; st.UserID != 7 || st.RespID != 3 {
+		t.Fatalf("unexpected stored state: %+v", st)
+	}
+
+	s.persistOperatorMode(u, 3, 5, false, since)
+	if _, ok := s.restoreOperatorMode(u, 5); ok {
+		t.Fatalf("operator mode must not be restored after exit")
+	}
+}
+
+func TestOperatorState_NoStore(t *testing.T) {
+	s := &Start{}
+	u := &model.RespModel{Assist: model.Assistant{UserID: 7}}
+	s.persistOperatorMode(u, 3, 5, true, time.Now())
+	if _, ok := s.restoreOperatorMode(u, 5); ok {
+		t.Fatalf("without store operator mode is not restored")
+	}
+}
+
+func TestOperatorState_ExpiredAndWithoutOperator(t *testing.T) {
+	store := &memOperatorStates{}
+	u := &model.RespModel{Assist: model.Assistant{UserID: 7}}
+
+	// Без Operator подключаться не к кому: состояние не восстанавливается
+	s := &Start{opStates: store}
+	s.persistOperatorMode(u, 3, 5, true, time.Now())
+	if _, ok := s.restoreOperatorMode(u, 5); ok {
+		t.Fatalf("operator mode restored without Operator")
+	}
+
+	s.Oper = offlineOperator{}
+	s.persistOperatorMode(u, 3, 5, true, time.Now().Add(-mode.OperatorStateTTL-time.Minute))
+	if _, ok := s.restoreOperatorMode(u, 5); ok {
+		t.Fatalf("expired operator mode restored")
+	}
+	if len(store.m) != 0 {
+		t.Fatalf("expired operator state must be deleted")
+	}
+}
+
+func TestOperatorState_ClearedOnDialogEnd(t *testing.T) {
+	store := &memOperatorStates{}
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &Start{ctx: ctx, opStates: store, Oper: offlineOperator{}}
+	u := &model.RespModel{Assist: model.Assistant{UserID: 7}}
+
+	// Остановка процесса: состояние нужно для восстановления после перезапуска
+	s.persistOperatorMode(u, 3, 5, true, time.Now())
+	cancel()
+	s.emit(u, 3, 5, webhook.EventDialogEnded, nil)
+	if _, ok := store.m[5]; !ok {
+		t.Fatalf("operator state deleted on shutdown")
+	}
+
+	s.ctx = context.Background()
+	s.emit(u, 3, 5, webhook.EventDialogEnded, nil)
+	if _, ok := store.m[5]; ok {
+		t.Fatalf("operator state kept after dialog end")
+	}
+}
@@ -0,0 +1,74 @@
+package startpoint
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ikermy/AiR_Common/pkg/model"
+	"github.com/ikermy/AiR_Common/pkg/model/create"
+)
+
+// operatorInboxSize сообщений оператора, ожидающих Respondent
+const operatorInboxSize = 8
+
+// setModeToAI системное сообщение оператора о выключении операторского режима
+const setModeToAI = "Set-Mode-To-AI"
+
+// operatorInbox канал сообщений, которые оператор отправил по своей инициативе.
+// Создаётся по требованию: сообщение может прийти раньше, чем запустится Respondent.
+func (s *Start) operatorInbox(treadId uint64) chan model.Message {
+	v, _ := s.opInbox.LoadOrStore(treadId, make(chan model.Message, operatorInboxSize))
+	return v.(chan model.Message)
+}
+
+// SendOperatorMessage отправляет сообщение оператора в активный диалог без вопроса пользователя.
+// Диалог переключается в операторский режим: ответ уходит клиенту с Operator.SetOperator,
+// чтобы бот пользователя включил режим, а следующие вопросы пользователя идут оператору.
+// Диалог из пула воркеров для этого передаётся выделенному Listener.
+func (s *Start) SendOperatorMessage(userID uint32, dialogID uint64, msg model.Message) error {
+	d, ok := s.activeDialog(dialogID)
+	if !ok || d.info.UserID != userID {
+		return fmt.Errorf("диалог %d пользователя %d не активен", dialogID, userID)
+	}
+	if msg.Content.Message == "" && len(msg.Content.Action.SendFiles) == 0 {
+		return fmt.Errorf("пустое сообщение оператора")
+	}
+
+	if s.pool != nil {
+		if v, ok := s.pool.dialogs.Load(dialogID); ok {
+			s.pool.release(v.(*pooledDialog), true)
+		}
+	}
+
+	msg.Operator.Operator = true
+	if msg.Type == "" {
+		msg.Type = "assist"
+	}
+	if msg.Timestamp.IsZero() {
+		msg.Timestamp = time.Now()
+	}
+	if err := model.SendTimeout(s.ctx, s.operatorInbox(dialogID), msg, create.ChanSendTimeout); err != nil {
+		return fmt.Errorf("не удалось передать сообщение оператора в диалог %d: %w", dialogID, err)
+	}
+	return nil
+}
+
+// ReturnToAI выключает операторский режим активного диалога, как если бы оператор
+// отправил системное сообщение Set-Mode-To-AI: сессия оператора удаляется,
+// боту вызывается DisableOperatorMode. Вне операторского режима ничего не делает.
+func (s *Start) ReturnToAI(userID uint32, dialogID uint64) error {
+	d, ok := s.activeDialog(dialogID)
+	if !ok || d.info.UserID != userID {
+		return fmt.Errorf("диалог %d пользователя %d не активен", dialogID, userID)
+	}
+	msg := model.Message{
+		Operator:  model.Operator{SetOperator: true, Operator: true},
+		Type:      "assist",
+		Content:   model.AssistResponse{Message: setModeToAI},
+		Timestamp: time.Now(),
+	}
+	if err := model.SendTimeout(s.ctx, s.operatorInbox(dialogID), msg, create.ChanSendTimeout); err != nil {
+		return fmt.Errorf("не удалось вернуть диалог %d модели: %w", dialogID, err)
+	}
+	return nil
+}
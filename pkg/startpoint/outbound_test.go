@@ -0,0 +1,189 @@
+package startpoint
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ikermy/AiR_Common/pkg/model"
+)
+
+func TestSendOperatorMessage_SwitchesToOperatorMode(t *testing.T) {
+	oper := &busyOperator{}
+	oper.free.Store(true)
+	s := New(context.Background(), &chModel{}, &memEndpoint{}, nil, oper)
+	defer s.cancel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	u := &model.RespModel{Ctx: ctx, Assist: model.Assistant{UserID: 1}}
+
+	if err := s.SendOperatorMessage(1, 3, model.Message{Content: model.AssistResponse{Message: "привет"}}); err == nil {
+		t.Fatal("для неактивного диалога ожидали ошибку")
+	}
+
+	questionCh, answerCh := make(chan Question, 1), make(chan Answer, 4)
+	s.registerActive(u, 3, 3, answerChDeliverer(answerCh))
+	go s.Respondent(u, questionCh, answerCh, make(chan Answer, 4), 3, 3, make(chan error, 4))
+
+	if err := s.SendOperatorMessage(2, 3, model.Message{Content: model.AssistResponse{Message: "привет"}}); err == nil {
+		t.Fatal("диалог другого пользователя: ожидали ошибку")
+	}
+	if err := s.SendOperatorMessage(1, 3, model.Message{Content: model.AssistResponse{Message: "Добрый день! Чем помочь?"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case ans := <-answerCh:
+		if ans.Answer.Message != "Добрый день! Чем помочь?" || !ans.Operator.SetOperator || !ans.Operator.Operator {
+			t.Fatalf("ответ: %+v", ans)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("сообщение оператора не доставлено")
+	}
+
+	// Ответ пользователя уходит оператору, а не модели
+	questionCh <- Question{Question: []string{"хочу вернуть заказ"}}
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		oper.mu.Lock()
+		n := len(oper.sent)
+		oper.mu.Unlock()
+		if n == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("вопрос пользователя не передан оператору")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestReturnToAI_DisablesOperatorMode(t *testing.T) {
+	oper := &busyOperator{}
+	oper.free.Store(true)
+	bot := &silentBot{}
+	s := New(context.Background(), &chModel{}, &memEndpoint{}, bot, oper)
+	defer s.cancel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	u := &model.RespModel{Ctx: ctx, Assist: model.Assistant{UserID: 1}}
+
+	if err := s.ReturnToAI(1, 4); err == nil {
+		t.Fatal("для неактивного диалога ожидали ошибку")
+	}
+
+	questionCh, answerCh := make(chan Question, 1), make(chan Answer, 4)
+	s.registerActive(u, 4, 4, answerChDeliverer(answerCh))
+	go s.Respondent(u, questionCh, answerCh, make(chan Answer, 4), 4, 4, make(chan error, 4))
+
+	// Вне операторского режима — без вызова бота
+	if err := s.ReturnToAI(1, 4); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SendOperatorMessage(1, 4, model.Message{Content: model.AssistResponse{Message: "здравствуйте"}}); err != nil {
+		t.Fatal(err)
+	}
+	<-answerCh
+	if err := s.ReturnToAI(1, 4); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for bot.disabled.Load() != 1 {
+		if time.Now().After(deadline) {
+			t.Fatalf("DisableOperatorMode вызван %d раз", bot.disabled.Load())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// gatedOperator — busyOperator с управляемым каналом ответов оператора. Первый вопрос
+// пользователя задерживается до закрытия gate; для каждого вопроса запоминается, сколько
+// ответов уже ждёт в answers
+type gatedOperator struct {
+	busyOperator
+	rx      chan model.Message
+	gate    chan struct{}
+	answers chan Answer
+
+	qmu     sync.Mutex
+	waiting []int
+}
+
+func (o *gatedOperator) ReceiveFromOperator(context.Context, uint32, uint64) <-chan model.Message {
+	return o.rx
+}
+
+func (o *gatedOperator) SendToOperator(context.Context, uint32, uint64, model.Message) error {
+	o.qmu.Lock()
+	o.waiting = append(o.waiting, len(o.answers))
+	first := len(o.waiting) == 1
+	o.qmu.Unlock()
+	if first {
+		<-o.gate
+	}
+	return nil
+}
+
+func TestRespondent_OperatorMessagePreemptsQuestion(t *testing.T) {
+	answerCh := make(chan Answer, 4)
+	oper := &gatedOperator{rx: make(chan model.Message, 1), gate: make(chan struct{}), answers: answerCh}
+	oper.free.Store(true)
+	s := New(context.Background(), &chModel{}, &memEndpoint{}, nil, oper)
+	defer s.cancel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	u := &model.RespModel{Ctx: ctx, Assist: model.Assistant{UserID: 1}}
+
+	questionCh := make(chan Question, 1)
+	s.registerActive(u, 5, 5, answerChDeliverer(answerCh))
+	go s.Respondent(u, questionCh, answerCh, make(chan Answer, 4), 5, 5, make(chan error, 4))
+
+	if err := s.SendOperatorMessage(1, 5, model.Message{Content: model.AssistResponse{Message: "Добрый день!"}}); err != nil {
+		t.Fatal(err)
+	}
+	<-answerCh
+
+	// Пока первый вопрос уходит оператору, приходят и ответ оператора, и следующий вопрос
+	questionCh <- Question{Question: []string{"где мой заказ?"}}
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		oper.qmu.Lock()
+		n := len(oper.waiting)
+		oper.qmu.Unlock()
+		if n == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("вопрос не передан оператору")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	oper.rx <- model.Message{Type: "assist", Content: model.AssistResponse{Message: "Заказ в пути"}}
+	questionCh <- Question{Question: []string{"а когда привезут?"}}
+	close(oper.gate)
+
+	deadline = time.Now().Add(2 * time.Second)
+	for {
+		oper.qmu.Lock()
+		waiting := append([]int(nil), oper.waiting...)
+		oper.qmu.Unlock()
+		if len(waiting) == 2 {
+			if waiting[1] != 1 {
+				t.Fatalf("ответ оператора ждал очереди вопросов: %v", waiting)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("второй вопрос не передан оператору")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if ans := <-answerCh; ans.Answer.Message != "Заказ в пути" {
+		t.Fatalf("ответ: %+v", ans)
+	}
+}
@@ -0,0 +1,131 @@
+package startpoint
+
+import (
+	"strings"
+	"time"
+
+	"github.com/ikermy/AiR_Common/pkg/comdb"
+	"github.com/ikermy/AiR_Common/pkg/logger"
+	"github.com/ikermy/AiR_Common/pkg/mode"
+	"github.com/ikermy/AiR_Common/pkg/model"
+)
+
+// PendingAskStore — хранилище вопросов, накопленных батчингом (реализуется *comdb.DB)
+type PendingAskStore interface {
+	SavePendingAsk(p comdb.PendingAsk) error
+	GetPendingAsk(dialogID uint64) (*comdb.PendingAsk, error)
+	DeletePendingAsk(dialogID uint64) error
+	ListPendingAsks() ([]comdb.PendingAsk, error)
+}
+
+// WithPendingAskStore сохраняет вопросы, которые Respondent копит Assist.Espero секунд
+// перед запросом к модели. После падения процесса New переотправляет их в RxCh
+// восстановленных диалогов (sweepPendingAsks), а Respondent, запущенный новым сообщением
+// пользователя, восстанавливает их и запускает таймер батчинга на оставшееся время.
+// Вопросы старше mode.PendingAskTTL не восстанавливаются
+func WithPendingAskStore(store PendingAskStore) Option {
+	return func(s *Start) {
+		s.pendingAsks = store
+	}
+}
+
+// savePendingAsk сохраняет все накопленные вопросы диалога
+func (s *Start) savePendingAsk(u *model.RespModel, respId, treadId uint64, asks []string, voice bool) {
+	if s.pendingAsks == nil {
+		return
+	}
+	err := s.pendingAsks.SavePendingAsk(comdb.PendingAsk{
+		UserID:    u.Assist.UserID,
+		DialogID:  treadId,
+		RespID:    respId,
+		Asks:      asks,
+		Voice:     voice,
+		UpdatedAt: time.Now(),
+	})
+	if err != nil {
+		logger.ForDialog(u.Assist.UserID, treadId).Warn("не удалось сохранить накопленные вопросы", "err", err)
+	}
+}
+
+// dropPendingAsk удаляет сохранённые вопросы: они ушли модели
+func (s *Start) dropPendingAsk(userID uint32, treadId uint64) {
+	if s.pendingAsks == nil {
+		return
+	}
+	if err := s.pendingAsks.DeletePendingAsk(treadId); err != nil {
+		logger.ForDialog(userID, treadId).Warn("не удалось удалить накопленные вопросы", "err", err)
+	}
+}
+
+// resumePendingAsk возвращает вопросы, накопленные до перезапуска, в буфер Endpoint.
+// wait — сколько осталось до отправки модели по таймеру батчинга; ok == false — восстанавливать нечего
+func (s *Start) resumePendingAsk(u *model.RespModel, respId, treadId uint64, now time.Time) (quest Question, wait time.Duration, ok bool) {
+	if s.pendingAsks == nil {
+		return Question{}, 0, false
+	}
+	log := logger.ForDialog(u.Assist.UserID, treadId)
+	p, err := s.pendingAsks.GetPendingAsk(treadId)
+	if err != nil {
+		log.Warn("не удалось прочитать накопленные вопросы", "err", err)
+		return Question{}, 0, false
+	}
+	if p == nil || len(p.Asks) == 0 {
+		return Question{}, 0, false
+	}
+	if now.Sub(p.UpdatedAt) > mode.PendingAskTTL {
+		s.dropPendingAsk(u.Assist.UserID, treadId)
+		return Question{}, 0, false
+	}
+
+	// Остатки буфера прежнего Respondent этого процесса совпадают с сохранёнными вопросами
+	s.End.GetUserAsk(treadId, respId)
+	for _, ask := range p.Asks {
+		s.End.SetUserAsk(treadId, respId, ask, u.Assist.Limit)
+	}
+
+	wait = max(time.Duration(u.Assist.Espero)*time.Second-now.Sub(p.UpdatedAt), 0)
+	log.Info("восстановлены накопленные вопросы", "count", len(p.Asks), "wait", wait)
+	return Question{Question: p.Asks, Voice: p.Voice}, wait, true
+}
+
+// sweepPendingAsks восстанавливает вопросы, накопленные до перезапуска, не дожидаясь
+// нового сообщения пользователя. Устаревшие удаляются; вопросы диалога, Respondent которого
+// восстановлен (Model.GetCh), уходят одним сообщением в RxCh — по обычному пути
+// Listener → Respondent, где снова запускается таймер батчинга Espero. Вопросы диалогов
+// без каналов остаются в хранилище до следующего сообщения пользователя (resumePendingAsk)
+func (s *Start) sweepPendingAsks(now time.Time) {
+	list, err := s.pendingAsks.ListPendingAsks()
+	if err != nil {
+		logger.Warn("pendingAsks: ошибка чтения накопленных вопросов: %v", err)
+		return
+	}
+	for _, p := range list {
+		if len(p.Asks) == 0 || now.Sub(p.UpdatedAt) > mode.PendingAskTTL {
+			s.dropPendingAsk(p.UserID, p.DialogID)
+			continue
+		}
+		if _, ok := s.activeDialog(p.DialogID); ok {
+			continue // Respondent диалога уже запущен и восстановил вопросы сам
+		}
+		ch, err := s.Mod.GetCh(p.RespID)
+		if err != nil {
+			continue
+		}
+
+		msgType := "user"
+		if p.Voice {
+			msgType = "user_voice"
+		}
+		content := model.AssistResponse{Message: strings.Join(p.Asks, "\n")}
+		// Удаляем до отправки: иначе Respondent восстановит те же вопросы второй раз
+		s.dropPendingAsk(p.UserID, p.DialogID)
+		if err := ch.SendToRx(s.Mod.NewMessage(model.Operator{}, msgType, &content, nil)); err != nil {
+			logger.ForDialog(p.UserID, p.DialogID).Warn("не удалось переотправить накопленные вопросы", "err", err)
+			if err := s.pendingAsks.SavePendingAsk(p); err != nil {
+				logger.ForDialog(p.UserID, p.DialogID).Warn("не удалось вернуть накопленные вопросы", "err", err)
+			}
+			continue
+		}
+		logger.ForDialog(p.UserID, p.DialogID).Info("накопленные вопросы переотправлены после перезапуска", "count", len(p.Asks))
+	}
+}
@@ -0,0 +1,130 @@
+package startpoint
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ikermy/AiR_Common/pkg/comdb"
+	"github.com/ikermy/AiR_Common/pkg/mode"
+	"github.com/ikermy/AiR_Common/pkg/model"
+)
+
+// memPendingAsks — PendingAskStore в памяти
+type memPendingAsks struct {
+	mu sync.Mutex
+	m  map[uint64]comdb.PendingAsk
+}
+
+func (s *memPendingAsks) SavePendingAsk(p comdb.PendingAsk) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.m == nil {
+		s.m = make(map[uint64]comdb.PendingAsk)
+	}
+	p.Asks = append([]string(nil), p.Asks...)
+	s.m[p.DialogID] = p
+	return nil
+}
+
+func (s *memPendingAsks) GetPendingAsk(dialogID uint64) (*comdb.PendingAsk, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, ok := s.m[dialogID]
+	if !ok {
+		return nil, nil
+	}
+	return &p, nil
+}
+
+func (s *memPendingAsks) DeletePendingAsk(dialogID uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.m, dialogID)
+	return nil
+}
+
+func (s *memPendingAsks) ListPendingAsks() ([]comdb.PendingAsk, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []comdb.PendingAsk
+	for _, p := range s.m {
+		out = append(out, p)
+	}
+	return out, nil
+}
+
+func (s *memPendingAsks) len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.m)
+}
+
+func TestPendingAsk_SaveAndResume(t *testing.T) {
+	store := &memPendingAsks{}
+	end := &memEndpoint{}
+	s := &Start{End: end, pendingAsks: store}
+	u := &model.RespModel{Assist: model.Assistant{UserID: 7, Espero: 10}}
+
+	s.savePendingAsk(u, 3, 5, []string{"первый", "второй"}, true)
+	saved := store.m[5].UpdatedAt
+
+	// Перезапуск через 4 секунды после последнего вопроса: таймер досчитывает оставшиеся 6
+	end.asks = map[uint64][]string{5: {"остаток прежнего Respondent"}}
+	quest, wait, ok := s.resumePendingAsk(u, 3, 5, saved.Add(4*time.Second))
+	if !ok {
+		t.Fatalf("expected pending asks to be resumed")
+	}
+	if wait != 6*time.Second || !quest.Voice || len(quest.Question) != 2 {
+		t.Fatalf("unexpected resume: %+v, wait %v", quest, wait)
+	}
+	if got := end.GetUserAsk(5, 3); len(got) != 2 || got[0] != "первый" || got[1] != "второй" {
+		t.Fatalf("endpoint buffer not restored: %v", got)
+	}
+
+	s.dropPendingAsk(7, 5)
+	if _, _, ok := s.resumePendingAsk(u, 3, 5, time.Now()); ok {
+		t.Fatalf("dropped asks must not be resumed")
+	}
+}
+
+func TestPendingAsk_ExpiredNotResumed(t *testing.T) {
+	store := &memPendingAsks{}
+	s := &Start{End: &memEndpoint{}, pendingAsks: store}
+	u := &model.RespModel{Assist: model.Assistant{UserID: 7, Espero: 10}}
+
+	s.savePendingAsk(u, 3, 5, []string{"вчерашний вопрос"}, false)
+	if _, _, ok := s.resumePendingAsk(u, 3, 5, time.Now().Add(mode.PendingAskTTL+time.Minute)); ok {
+		t.Fatalf("expired asks must not be resumed")
+	}
+	if store.len() != 0 {
+		t.Fatalf("expired asks must be deleted")
+	}
+}
+
+func TestPendingAsk_SweptOnStart(t *testing.T) {
+	store := &memPendingAsks{}
+	store.SavePendingAsk(comdb.PendingAsk{UserID: 7, DialogID: 5, RespID: 3, Asks: []string{"первый", "второй"}, UpdatedAt: time.Now()})
+	store.SavePendingAsk(comdb.PendingAsk{UserID: 7, DialogID: 6, RespID: 4, Asks: []string{"вчерашний"}, UpdatedAt: time.Now().Add(-mode.PendingAskTTL - time.Minute)})
+	ch := &model.Ch{RxCh: make(chan model.Message, 1), TxCh: make(chan model.Message, 1), DialogID: 5}
+
+	// Вопросы уходят в RxCh без нового сообщения пользователя
+	s := New(context.Background(), &chModel{ch: ch}, nil, nil, nil, WithPendingAskStore(store))
+	defer s.cancel()
+	select {
+	case msg := <-ch.RxCh:
+		if msg.Type != "user" || msg.Content.Message != "первый\nвторой" {
+			t.Fatalf("unexpected resent message: %+v", msg)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("pending asks not resent on start")
+	}
+	deadline := time.Now().Add(time.Second)
+	for store.len() != 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if store.len() != 0 {
+		t.Fatalf("resent and expired asks must be deleted: %d left", store.len())
+	}
+}
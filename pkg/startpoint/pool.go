@@ -0,0 +1,360 @@
+package startpoint
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/ikermy/AiR_Common/pkg/comdb"
+	"github.com/ikermy/AiR_Common/pkg/logger"
+	"github.com/ikermy/AiR_Common/pkg/model"
+	"github.com/ikermy/AiR_Common/pkg/model/create"
+	"github.com/ikermy/AiR_Common/pkg/safego"
+	"github.com/ikermy/AiR_Common/pkg/webhook"
+)
+
+// poolQueueSize ёмкость очереди готовых к обработке диалогов
+const poolQueueSize = 4096
+
+// WithWorkerPool включает режим пула: вместо пары Listener+Respondent на каждый диалог
+// сообщения всех диалогов обрабатывают workers общих воркеров из очереди диалогов.
+//
+// Режим рассчитан на высокую плотность диалогов с AI-ответами. Сообщения должны приходить
+// через Ch.SendToRx — он будит диалог в очереди. Батчинг вопросов (Espero) в пуле не
+// применяется, каждое сообщение обрабатывается сразу. Диалог, которому нужен оператор,
+// передаётся выделенному Listener и дальше работает в обычном режиме.
+func WithWorkerPool(workers int) Option {
+	return func(s *Start) {
+		if workers <= 0 {
+			return
+		}
+		s.pool = &dialogPool{s: s, workers: workers, runQ: make(chan *pooledDialog, poolQueueSize)}
+	}
+}
+
+// dialogPool общие воркеры и очередь диалогов, в RxCh которых есть сообщения
+type dialogPool struct {
+	s       *Start
+	workers int
+	runQ    chan *pooledDialog
+	dialogs sync.Map // treadId -> *pooledDialog
+}
+
+// pooledDialog диалог, обслуживаемый пулом
+type pooledDialog struct {
+	start  model.StartCh
+	errCh  chan<- error
+	queued atomic.Bool // диалог уже стоит в очереди или обрабатывается воркером
+	closed atomic.Bool
+	stops  []func() bool
+	follow *followUp
+}
+
+func (p *dialogPool) run() {
+	for i := 0; i < p.workers; i++ {
+		safego.Go("pool.worker", p.work, safego.WithRestart(-1, 0), safego.WithDone(p.s.ctx.Done()))
+	}
+}
+
+func (p *dialogPool) work() {
+	for {
+		select {
+		case <-p.s.ctx.Done():
+			return
+		case d := <-p.runQ:
+			p.serve(d)
+		}
+	}
+}
+
+// register ставит диалог на обслуживание пулом
+func (p *dialogPool) register(start model.StartCh, errCh chan<- error) {
+	d := &pooledDialog{start: start, errCh: errCh}
+	p.dialogs.Store(start.TreadId, d)
+
+	// Завершение диалога по контексту бота или Start без отдельной горутины
+	d.stops = append(d.stops,
+		context.AfterFunc(start.Ctx, func() { p.release(d, false) }),
+		context.AfterFunc(p.s.ctx, func() { p.release(d, false) }),
+	)
+	start.Chanel.SetRxNotify(func() { p.schedule(d) })
+	deliver := deliverFunc(func(ans Answer) bool {
+		if d.closed.Load() || !p.s.pooledDeliver(start.Model, start.Chanel, ans.Answer, ans.Operator, errCh) {
+			return false
+		}
+		p.s.End.SaveDialog(comdb.AI, start.TreadId, &ans.Answer)
+		d.follow.assistantSent(ans.Operator)
+		return true
+	})
+	d.follow = p.s.newFollowUp(start.Model, start.RespId, start.TreadId, deliver)
+	p.s.registerActive(start.Model, start.RespId, start.TreadId, deliver)
+
+	// Сообщения, пришедшие до регистрации
+	if len(start.Chanel.RxCh) > 0 {
+		p.schedule(d)
+	}
+}
+
+// schedule ставит диалог в очередь, если он ещё не в ней
+func (p *dialogPool) schedule(d *pooledDialog) {
+	if d.closed.Load() || !d.queued.CompareAndSwap(false, true) {
+		return
+	}
+	select {
+	case p.runQ <- d:
+	case <-p.s.ctx.Done():
+	}
+}
+
+// serve обрабатывает одно сообщение диалога и возвращает его в конец очереди, если остались ещё
+func (p *dialogPool) serve(d *pooledDialog) {
+	for {
+		if d.closed.Load() {
+			return
+		}
+		select {
+		case msg, ok := <-d.start.Chanel.RxCh:
+			if !ok {
+				p.release(d, false)
+				return
+			}
+			if p.s.pooledTurn(p, d, msg) {
+				return
+			}
+		default:
+		}
+
+		d.queued.Store(false)
+		if len(d.start.Chanel.RxCh) == 0 || !d.queued.CompareAndSwap(false, true) {
+			return
+		}
+		// Остались сообщения — в конец очереди, чтобы не задерживать другие диалоги
+		select {
+		case p.runQ <- d:
+			return
+		default:
+			// Очередь заполнена — продолжаем обслуживать этот диалог
+		}
+	}
+}
+
+// release снимает диалог с пула. handover — диалог переходит к выделенному Listener
+// вместе с pending-сообщениями.
+func (p *dialogPool) release(d *pooledDialog, handover bool, pending ...model.Message) {
+	if !d.closed.CompareAndSwap(false, true) {
+		return
+	}
+	for _, stop := range d.stops {
+		stop()
+	}
+	d.start.Chanel.SetRxNotify(nil)
+	p.s.active.Delete(d.start.TreadId)
+	d.follow.stop()
+	p.dialogs.CompareAndDelete(d.start.TreadId, d)
+
+	if handover {
+		// Services.Listener остаётся true — теперь его держит выделенный Listener
+		p.s.runListener(d.start, d.errCh, pending...)
+		return
+	}
+	if ttlExpired(d.start.Model) {
+		p.s.requestCSAT(d.start.Model, d.start.RespId, d.start.TreadId, CSATOnTTL, func(survey model.AssistResponse) bool {
+			return p.s.sendAssist(d.start.Chanel, model.Operator{}, survey, &d.start.Model.Assist.AssistName) == nil
+		})
+	}
+	p.s.responderProviders.Delete(d.start.RespId)
+	logger.UnbindDialog(d.start.TreadId)
+	d.start.Model.Services.Listener.Store(false)
+	p.s.emit(d.start.Model, d.start.RespId, d.start.TreadId, webhook.EventDialogEnded, nil)
+}
+
+// pooledTurn — один ход диалога в режиме пула: вопрос, запрос к модели, ответ, сохранение.
+// Возвращает true, если диалог снят с пула.
+func (s *Start) pooledTurn(p *dialogPool, d *pooledDialog, msg model.Message) (released bool) {
+	u, usrCh := d.start.Model, d.start.Chanel
+	respId, treadId := d.start.RespId, d.start.TreadId
+
+	if s.captureRating(u.Assist.UserID, treadId, msg) {
+		return false
+	}
+
+	// Операторский режим — только в выделенном Listener.
+	// Операторов нет на связи — сообщение обрабатывает AI без ожидания таймаута.
+	if msg.Operator.SetOperator || msg.Operator.Operator {
+		if s.operatorAvailable(u.Assist.UserID) {
+			p.release(d, true, msg)
+			return true
+		}
+		if err := s.Bot.DisableOperatorMode(u.Assist.UserID, treadId, true); err != nil {
+			s.sendError(d.errCh, fmt.Errorf("ошибка при отключении режима оператора: %w", err))
+		}
+		s.pooledDeliver(u, usrCh, operatorSystemAnswer(NoOperatorsMessage).Answer, model.Operator{}, d.errCh)
+		msg.Operator = model.Operator{SenderName: msg.Operator.SenderName}
+	}
+
+	if !s.admit(u, usrCh, treadId, msg) {
+		return false
+	}
+	d.follow.userActive()
+	s.applySeed(u, treadId, msg)
+	quest, err := newQuestion(msg)
+	if err != nil {
+		s.sendError(d.errCh, err)
+		return false
+	}
+	logger.BindDialog(treadId, quest.TraceID)
+
+	// Ошибки хода, как и в Listener, завершают обслуживание диалога
+	errCh := make(chan error, create.RxChanBuffer)
+	defer func() {
+		select {
+		case err := <-errCh:
+			logger.ForDialog(u.Assist.UserID, treadId).Error("pool: ошибка обработки диалога", "err", err)
+			s.emitError(u, respId, treadId, err)
+			s.sendError(d.errCh, err)
+			if !released {
+				p.release(d, false)
+				released = true
+			}
+		default:
+		}
+	}()
+
+	userMsg := s.Mod.NewMessage(msg.Operator, "user", &msg.Content, &msg.Name)
+	if err := usrCh.SendToTx(userMsg); err != nil {
+		s.sendError(errCh, fmt.Errorf("ошибка при отправке в канал TxCh: %v", err.Error()))
+		return false
+	}
+
+	ask := strings.Join(quest.Question, "\n")
+	ruleEscalation, ruleReply := s.checkTriggers(u, treadId, ask, errCh)
+	var intent *Intent
+	if ruleReply == "" {
+		intent = s.classifyIntent(u, treadId, ask, errCh)
+	}
+	if intent != nil && intent.Reply == nil && !intent.Operator && intent.Assistant != "" {
+		// Смена ассистента — в выделенном Listener, вопрос уходит ему
+		p.release(d, true, msg)
+		return true
+	}
+	s.End.SetUserAsk(treadId, respId, ask, u.Assist.Limit)
+	userAsk := s.End.GetUserAsk(treadId, respId)
+	if strings.TrimSpace(strings.Join(userAsk, "\n")) == "" {
+		return false
+	}
+
+	creator := comdb.User
+	if quest.Voice {
+		creator = comdb.UserVoice
+	}
+	fullAsk := model.AssistResponse{Message: strings.Join(userAsk, "\n")}
+	fullAsk.Sentiment = s.turnSentiment(u.Assist.UserID, fullAsk.Message)
+	s.countTurn(treadId)
+	s.End.SaveDialog(creator, treadId, &fullAsk)
+
+	var answer model.AssistResponse
+	switch {
+	case ruleReply != "":
+		answer = model.AssistResponse{Message: ruleReply}
+	case intent != nil && intent.Reply != nil:
+		answer = *intent.Reply
+	default:
+		answer, err = s.AskWithRetry(u.Assist.UserID, respId, treadId, userAsk, quest.Files...)
+	}
+	if err != nil {
+		fallback := make(chan Answer, 1)
+		if s.handleAskFailure(u, err, fallback, errCh, fmt.Sprintf("критическая ошибка для пользователя %d", u.Assist.UserID),
+			failedTurn{respId: respId, dialogID: treadId, ask: userAsk, quest: quest}) {
+			return false
+		}
+		select {
+		case ans := <-fallback:
+			s.pooledDeliver(u, usrCh, ans.Answer, model.Operator{}, errCh)
+			if ans.Err != nil {
+				s.sendError(errCh, fmt.Errorf("модель не смогла ответить (dialogID=%d): %w", treadId, ans.Err))
+			}
+		default:
+		}
+		return false
+	}
+
+	// Правила эскалации в пуле срабатывают после ответа модели, как флаг operator в ответе
+	if intent != nil && intent.Reply == nil && intent.Operator {
+		ruleEscalation = true
+	}
+	if !answer.Operator {
+		escalate, reply := s.checkAnswerRules(u, treadId, fullAsk.Message, answer, errCh)
+		if reply != "" {
+			answer = model.AssistResponse{Message: reply}
+		}
+		answer.Operator = ruleEscalation || escalate
+	}
+
+	// Модель запросила эскалацию — передаём вопрос оператору, а диалог выделенному Listener
+	escalate := answer.Operator && s.operatorAvailable(u.Assist.UserID)
+	if escalate {
+		s.sendEvent(u.Assist.UserID, "model-operator", u.RespName, u.Assist.AssistName, "")
+		s.emit(u, respId, treadId, webhook.EventOperatorRequested, nil)
+		s.sendTranscriptToOperator(u, treadId)
+		msgType := "user"
+		if quest.Voice {
+			msgType = "user_voice"
+		}
+		name := u.Assist.AssistName
+		opMsg := s.Mod.NewMessage(model.Operator{Operator: true, SenderName: quest.Operator.SenderName}, msgType, &fullAsk, &name, quest.Files...)
+		if errSend := s.Oper.SendToOperator(s.ctx, u.Assist.UserID, treadId, opMsg); errSend != nil {
+			s.sendError(errCh, fmt.Errorf("ошибка отправки эскалации оператору: %v", errSend))
+		}
+	}
+
+	if answer.Message != "" || len(answer.Action.SendFiles) > 0 {
+		answer.Action.SendFiles = model.CorrectFileTypes(s.ctx, answer.Action.SendFiles)
+		s.checkTarget(u, respId, treadId, answer, errCh)
+		if s.pooledDeliver(u, usrCh, answer, model.Operator{SetOperator: escalate}, errCh) {
+			s.End.SaveDialog(comdb.AI, treadId, &answer)
+			if !escalate {
+				d.follow.assistantSent(model.Operator{})
+			}
+		}
+	}
+
+	if escalate {
+		p.release(d, true)
+		return true
+	}
+	if answer.Operator {
+		s.pooledDeliver(u, usrCh, operatorSystemAnswer(NoOperatorsMessage).Answer, model.Operator{}, errCh)
+	}
+	return false
+}
+
+// pooledDeliver отправляет ответ ассистента клиенту
+func (s *Start) pooledDeliver(u *model.RespModel, usrCh *model.Ch, answer model.AssistResponse, op model.Operator, errCh chan<- error) bool {
+	if err := s.sendAssist(usrCh, op, answer, &u.Assist.AssistName); err != nil {
+		s.sendError(errCh, fmt.Errorf("ошибка при отправке в канал TxCh: %v", err.Error()))
+		return false
+	}
+	return true
+}
+
+// PoolStats состояние пула воркеров (нули, если пул не включён)
+type PoolStats struct {
+	Workers int `json:"workers"`
+	Dialogs int `json:"dialogs"` // диалогов на обслуживании
+	Queued  int `json:"queued"`  // диалогов в очереди
+}
+
+// PoolStats возвращает состояние пула воркеров
+func (s *Start) PoolStats() PoolStats {
+	if s.pool == nil {
+		return PoolStats{}
+	}
+	st := PoolStats{Workers: s.pool.workers, Queued: len(s.pool.runQ)}
+	s.pool.dialogs.Range(func(_, _ any) bool {
+		st.Dialogs++
+		return true
+	})
+	return st
+}
@@ -0,0 +1,193 @@
+package startpoint
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ikermy/AiR_Common/pkg/comdb"
+	"github.com/ikermy/AiR_Common/pkg/endpoint"
+	"github.com/ikermy/AiR_Common/pkg/mode"
+	"github.com/ikermy/AiR_Common/pkg/model"
+	"github.com/ikermy/AiR_Common/pkg/operator"
+)
+
+// memEndpoint — endpoint.Inter для тестов: буфер вопросов и журнал сохранений
+type memEndpoint struct {
+	endpoint.Inter
+	mu      sync.Mutex
+	asks    map[uint64][]string
+	saved   []comdb.CreatorType
+	history []endpoint.Message
+	events  []string
+}
+
+func (e *memEndpoint) SendEvent(_ uint32, event, _, _, _ string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.events = append(e.events, event)
+}
+
+func (e *memEndpoint) SetUserAsk(dialogID, _ uint64, ask string, _ ...uint32) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.asks == nil {
+		e.asks = make(map[uint64][]string)
+	}
+	e.asks[dialogID] = append(e.asks[dialogID], ask)
+	return false
+}
+
+func (e *memEndpoint) GetUserAsk(dialogID, _ uint64) []string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	res := e.asks[dialogID]
+	delete(e.asks, dialogID)
+	return res
+}
+
+func (e *memEndpoint) SaveDialog(creator comdb.CreatorType, _ uint64, resp *model.AssistResponse) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.saved = append(e.saved, creator)
+	e.history = append(e.history, endpoint.Message{Creator: creator, Message: *resp, Timestamp: time.Now()})
+}
+
+func (e *memEndpoint) GetDialogHistory(_ uint64, limit int) ([]endpoint.Message, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	h := e.history
+	if len(h) > limit {
+		h = h[len(h)-limit:]
+	}
+	return append([]endpoint.Message(nil), h...), nil
+}
+
+func newPooledDialog(ctx context.Context, dialogID uint64) (model.StartCh, *model.Ch) {
+	ch := &model.Ch{TxCh: make(chan model.Message, 16), RxCh: make(chan model.Message, 4), DialogID: dialogID}
+	u := &model.RespModel{
+		Assist:   model.Assistant{UserID: 1},
+		Services: model.Services{Listener: new(atomic.Bool), Respondent: new(atomic.Bool)},
+	}
+	return model.StartCh{Ctx: ctx, Model: u, Chanel: ch, TreadId: dialogID, RespId: dialogID}, ch
+}
+
+func TestPool_ServesDialogs(t *testing.T) {
+	defer func(v bool) { mode.TestAnswer = v }(mode.TestAnswer)
+	mode.TestAnswer = true
+
+	end := &memEndpoint{}
+	s := New(context.Background(), &chModel{}, end, nil, nil, WithWorkerPool(2))
+	defer s.cancel()
+
+	errCh := make(chan error, 4)
+	var chans []*model.Ch
+	for id := uint64(1); id <= 3; id++ {
+		start, ch := newPooledDialog(context.Background(), id)
+		s.StarterListener(start, errCh)
+		chans = append(chans, ch)
+	}
+	if st := s.PoolStats(); st.Workers != 2 || st.Dialogs != 3 {
+		t.Fatalf("unexpected pool stats: %+v", st)
+	}
+
+	for _, ch := range chans {
+		if err := ch.SendToRx(model.Message{Type: "user", Content: model.AssistResponse{Message: "привет"}}); err != nil {
+			t.Fatalf("SendToRx: %v", err)
+		}
+	}
+
+	for i, ch := range chans {
+		for _, want := range []string{"user", "assist"} {
+			select {
+			case msg := <-ch.TxCh:
+				if msg.Type != want {
+					t.Fatalf("dialog %d: expected %s, got %s", i+1, want, msg.Type)
+				}
+			case <-time.After(2 * time.Second):
+				t.Fatalf("dialog %d: no %s message", i+1, want)
+			}
+		}
+	}
+
+	end.mu.Lock()
+	saved := len(end.saved)
+	end.mu.Unlock()
+	if saved != 6 {
+		t.Fatalf("expected question and answer saved for each dialog, got %d", saved)
+	}
+	select {
+	case err := <-errCh:
+		t.Fatalf("unexpected error: %v", err)
+	default:
+	}
+}
+
+func TestPool_ReleasesOnContextCancel(t *testing.T) {
+	s := New(context.Background(), &chModel{}, &memEndpoint{}, nil, nil, WithWorkerPool(1))
+	defer s.cancel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	start, _ := newPooledDialog(ctx, 7)
+	s.StarterListener(start, make(chan error, 1))
+	if !start.Model.Services.Listener.Load() {
+		t.Fatalf("dialog must be marked as served")
+	}
+
+	cancel()
+	deadline := time.Now().Add(time.Second)
+	for s.PoolStats().Dialogs != 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if s.PoolStats().Dialogs != 0 || start.Model.Services.Listener.Load() {
+		t.Fatalf("dialog must be released after context cancel")
+	}
+}
+
+// offlineOperator — operator.Inter без операторов на связи
+type offlineOperator struct{ operator.Inter }
+
+func (offlineOperator) OperatorAvailable(uint32) bool { return false }
+
+type silentBot struct{ disabled atomic.Int32 }
+
+func (b *silentBot) DisableOperatorMode(uint32, uint64, ...bool) error {
+	b.disabled.Add(1)
+	return nil
+}
+
+func TestPool_NoOperatorsFallsBackToAI(t *testing.T) {
+	defer func(v bool) { mode.TestAnswer = v }(mode.TestAnswer)
+	mode.TestAnswer = true
+
+	bot := &silentBot{}
+	s := New(context.Background(), &chModel{}, &memEndpoint{}, bot, offlineOperator{}, WithWorkerPool(1))
+	defer s.cancel()
+
+	start, ch := newPooledDialog(context.Background(), 1)
+	s.StarterListener(start, make(chan error, 4))
+	if err := ch.SendToRx(model.Message{Type: "user", Operator: model.Operator{SetOperator: true}, Content: model.AssistResponse{Message: "позовите оператора"}}); err != nil {
+		t.Fatalf("SendToRx: %v", err)
+	}
+
+	var got []string
+	for len(got) < 3 {
+		select {
+		case msg := <-ch.TxCh:
+			got = append(got, msg.Type+":"+msg.Content.Message)
+		case <-time.After(2 * time.Second):
+			t.Fatalf("ожидали уведомление, вопрос и ответ AI, получили %v", got)
+		}
+	}
+	if got[0] != "assist:"+NoOperatorsMessage || got[1] != "user:позовите оператора" || got[2][:7] != "assist:" {
+		t.Fatalf("неожиданная последовательность: %v", got)
+	}
+	if bot.disabled.Load() != 1 {
+		t.Fatalf("операторский режим в боте должен быть отключён")
+	}
+	if st := s.PoolStats(); st.Dialogs != 1 {
+		t.Fatalf("диалог должен остаться в пуле: %+v", st)
+	}
+}
@@ -0,0 +1,130 @@
+package startpoint
+
+import (
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ikermy/AiR_Common/pkg/mode"
+)
+
+// RateLimitMessage вежливый ответ пользователю при превышении лимита сообщений
+const RateLimitMessage = "слишком много сообщений, подождите"
+
+// rateLimitWindow окно скользящего лимита
+const rateLimitWindow = time.Minute
+
+// RateLimitStats счётчики ограничителя входящих сообщений
+type RateLimitStats struct {
+	Allowed       uint64 `json:"allowed"`        // пропущено в Respondent
+	LimitedDialog uint64 `json:"limited_dialog"` // отклонено по лимиту диалога
+	LimitedUser   uint64 `json:"limited_user"`   // отклонено по лимиту пользователя
+}
+
+// rateLimiter ограничивает число сообщений в минуту на диалог и на пользователя (скользящее окно).
+// Лимиты берутся из mode.RateLimitPerDialog / mode.RateLimitPerUser, 0 — без ограничения.
+type rateLimiter struct {
+	mu        sync.Mutex
+	hits      map[string]*rateWindow
+	lastSweep time.Time
+
+	allowed       atomic.Uint64
+	limitedDialog atomic.Uint64
+	limitedUser   atomic.Uint64
+}
+
+type rateWindow struct {
+	times    []time.Time // время принятых сообщений в пределах окна
+	notified bool        // пользователь уже предупреждён о лимите этого окна
+}
+
+func newRateLimiter() *rateLimiter {
+	return &rateLimiter{hits: make(map[string]*rateWindow)}
+}
+
+// allow проверяет лимиты и учитывает сообщение, если оно принято.
+// notify == true только для первого отклонённого сообщения в окне сработавшего лимита
+// (диалога или пользователя), чтобы не отвечать пользователю на каждое сообщение серии.
+func (l *rateLimiter) allow(userID uint32, dialogID uint64, now time.Time) (ok, notify bool) {
+	if l == nil {
+		return true, false
+	}
+
+	dialogLimit, userLimit := mode.RateLimitPerDialog, mode.RateLimitPerUser
+	if dialogLimit <= 0 && userLimit <= 0 {
+		l.allowed.Add(1)
+		return true, false
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now.Sub(l.lastSweep) >= rateLimitWindow {
+		for k, w := range l.hits {
+			if w.trim(now); len(w.times) == 0 {
+				delete(l.hits, k)
+			}
+		}
+		l.lastSweep = now
+	}
+
+	dialog := l.window("d:"+strconv.FormatUint(dialogID, 10), now)
+	user := l.window("u:"+strconv.FormatUint(uint64(userID), 10), now)
+
+	switch {
+	case dialogLimit > 0 && len(dialog.times) >= dialogLimit:
+		l.limitedDialog.Add(1)
+		notify = !dialog.notified
+		dialog.notified = true
+		return false, notify
+	case userLimit > 0 && userID != 0 && len(user.times) >= userLimit:
+		l.limitedUser.Add(1)
+		notify = !user.notified
+		user.notified = true
+		return false, notify
+	}
+
+	dialog.times = append(dialog.times, now)
+	user.times = append(user.times, now)
+	l.allowed.Add(1)
+	return true, false
+}
+
+func (l *rateLimiter) window(key string, now time.Time) *rateWindow {
+	w, ok := l.hits[key]
+	if !ok {
+		w = &rateWindow{}
+		l.hits[key] = w
+	}
+	w.trim(now)
+	return w
+}
+
+// trim удаляет отметки старше окна; освободившееся окно снова разрешает предупреждение
+func (w *rateWindow) trim(now time.Time) {
+	cut := 0
+	for cut < len(w.times) && now.Sub(w.times[cut]) >= rateLimitWindow {
+		cut++
+	}
+	if cut > 0 {
+		w.times = append(w.times[:0], w.times[cut:]...)
+		w.notified = false
+	}
+}
+
+func (l *rateLimiter) stats() RateLimitStats {
+	if l == nil {
+		return RateLimitStats{}
+	}
+	return RateLimitStats{
+		Allowed:       l.allowed.Load(),
+		LimitedDialog: l.limitedDialog.Load(),
+		LimitedUser:   l.limitedUser.Load(),
+	}
+}
+
+// RateLimitStats возвращает счётчики ограничителя входящих сообщений (для метрик)
+func (s *Start) RateLimitStats() RateLimitStats {
+	return s.limiter.stats()
+}
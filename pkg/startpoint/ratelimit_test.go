@@ -0,0 +1,98 @@
+package startpoint
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ikermy/AiR_Common/pkg/mode"
+)
+
+func TestRateLimit_PerDialog(t *testing.T) {
+	defer func(d, u int) { mode.RateLimitPerDialog, mode.RateLimitPerUser = d, u }(mode.RateLimitPerDialog, mode.RateLimitPerUser)
+	mode.RateLimitPerDialog, mode.RateLimitPerUser = 2, 0
+
+	l := newRateLimiter()
+	now := time.Now()
+
+	for i := 0; i < 2; i++ {
+		if ok, _ := l.allow(1, 10, now); !ok {
+			t.Fatalf("message %d must pass", i)
+		}
+	}
+	if ok, notify := l.allow(1, 10, now); ok || !notify {
+		t.Fatalf("third message must be limited with notification, got ok=%v notify=%v", ok, notify)
+	}
+	if ok, notify := l.allow(1, 10, now); ok || notify {
+		t.Fatalf("repeated limit must not notify again, got ok=%v notify=%v", ok, notify)
+	}
+	if ok, _ := l.allow(1, 11, now); !ok {
+		t.Fatalf("another dialog must pass")
+	}
+	if ok, _ := l.allow(1, 10, now.Add(time.Minute)); !ok {
+		t.Fatalf("message after window must pass")
+	}
+
+	st := l.stats()
+	if st.Allowed != 4 || st.LimitedDialog != 2 || st.LimitedUser != 0 {
+		t.Fatalf("unexpected stats: %+v", st)
+	}
+}
+
+func TestRateLimit_PerUser(t *testing.T) {
+	defer func(d, u int) { mode.RateLimitPerDialog, mode.RateLimitPerUser = d, u }(mode.RateLimitPerDialog, mode.RateLimitPerUser)
+	mode.RateLimitPerDialog, mode.RateLimitPerUser = 0, 2
+
+	l := newRateLimiter()
+	now := time.Now()
+
+	l.allow(7, 1, now)
+	l.allow(7, 2, now)
+	if ok, notify := l.allow(7, 3, now); ok || !notify {
+		t.Fatalf("user limit must apply across dialogs, got ok=%v notify=%v", ok, notify)
+	}
+	if ok, notify := l.allow(7, 4, now); ok || notify {
+		t.Fatalf("user limit must notify once per window, got ok=%v notify=%v", ok, notify)
+	}
+	if ok, _ := l.allow(8, 3, now); !ok {
+		t.Fatalf("another user must pass")
+	}
+	if ok, notify := l.allow(7, 3, now.Add(time.Minute)); !ok || notify {
+		t.Fatalf("message after window must pass, got ok=%v notify=%v", ok, notify)
+	}
+	l.allow(7, 3, now.Add(time.Minute))
+	if ok, notify := l.allow(7, 3, now.Add(time.Minute)); ok || !notify {
+		t.Fatalf("user limit in a new window must notify again, got ok=%v notify=%v", ok, notify)
+	}
+	if st := l.stats(); st.LimitedUser != 3 {
+		t.Fatalf("unexpected stats: %+v", st)
+	}
+}
+
+func TestRateLimit_NotifiedPerKind(t *testing.T) {
+	defer func(d, u int) { mode.RateLimitPerDialog, mode.RateLimitPerUser = d, u }(mode.RateLimitPerDialog, mode.RateLimitPerUser)
+	mode.RateLimitPerDialog, mode.RateLimitPerUser = 2, 3
+
+	l := newRateLimiter()
+	now := time.Now()
+
+	l.allow(7, 1, now)
+	l.allow(7, 1, now)
+	l.allow(7, 2, now)
+	if ok, notify := l.allow(7, 2, now); ok || !notify {
+		t.Fatalf("user limit must notify, got ok=%v notify=%v", ok, notify)
+	}
+	// Предупреждение о лимите пользователя не гасит предупреждение о лимите диалога
+	if ok, notify := l.allow(7, 1, now); ok || !notify {
+		t.Fatalf("dialog limit must notify separately, got ok=%v notify=%v", ok, notify)
+	}
+}
+
+func TestRateLimit_NilSafe(t *testing.T) {
+	var l *rateLimiter
+	if ok, _ := l.allow(1, 1, time.Now()); !ok {
+		t.Fatalf("nil limiter must allow")
+	}
+	if st := (&Start{}).RateLimitStats(); st != (RateLimitStats{}) {
+		t.Fatalf("nil limiter stats must be zero: %+v", st)
+	}
+}
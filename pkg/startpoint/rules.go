@@ -0,0 +1,230 @@
+package startpoint
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/ikermy/AiR_Common/pkg/logger"
+	"github.com/ikermy/AiR_Common/pkg/model"
+	"github.com/ikermy/AiR_Common/pkg/model/create"
+	"github.com/ikermy/AiR_Common/pkg/safego"
+)
+
+// EventRuleTag событие SendEvent: правило пометило диалог тегом
+const EventRuleTag = "rule_tag"
+
+// ruleWebhookTimeout тайм-аут POST действия webhook
+const ruleWebhookTimeout = 5 * time.Second
+
+// SentimentFunc оценивает тональность текста от -1 (негатив) до 1 (позитив)
+type SentimentFunc func(text string) float64
+
+// WithSentiment задаёт оценку тональности для условий SentimentBelow (по умолчанию — словарная)
+func WithSentiment(fn SentimentFunc) Option {
+	return func(s *Start) {
+		if fn != nil {
+			s.sentiment = fn
+		}
+	}
+}
+
+// ruleRegexps кэш скомпилированных выражений правил; некорректное выражение хранится как nil
+var ruleRegexps sync.Map // string -> *regexp.Regexp
+
+func ruleRegexp(expr string) *regexp.Regexp {
+	if v, ok := ruleRegexps.Load(expr); ok {
+		return v.(*regexp.Regexp)
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		//logger.Warn("rules: некорректное выражение %q: %v", expr, err)
+		re = nil
+	}
+	ruleRegexps.Store(expr, re)
+	return re
+}
+
+// ruleTriggerSets кэш скомпилированных наборов Keywords: набор компилируется при первой
+// проверке ассистента, а не на каждом вопросе; набор с некорректным выражением хранится как nil
+var ruleTriggerSets sync.Map // string -> *create.TriggerMatcher
+
+func ruleTriggers(keywords []string) *create.TriggerMatcher {
+	key := strings.Join(keywords, "\x00")
+	if v, ok := ruleTriggerSets.Load(key); ok {
+		return v.(*create.TriggerMatcher)
+	}
+	m, err := create.CompileTriggers(keywords)
+	if err != nil {
+		logger.Warn("rules: некорректный триггер: %v", err)
+		m = nil
+	}
+	ruleTriggerSets.Store(key, m)
+	return m
+}
+
+// assistantRules правила ассистента; Metas.Triggers работают как правило с действием meta
+func assistantRules(u *model.RespModel) []create.EscalationRule {
+	rules := u.Assist.Rules
+	if len(u.Assist.Metas.Triggers) > 0 {
+		rules = append(append([]create.EscalationRule(nil), rules...), create.EscalationRule{
+			Name:     "triggers",
+			Keywords: u.Assist.Metas.Triggers,
+			Actions:  []string{create.RuleMeta},
+		})
+	}
+	return rules
+}
+
+// ruleMatches проверяет условия правила. answer == nil — проверка по вопросу,
+// правила с ConfidenceBelow при этом пропускаются (и наоборот).
+func (s *Start) ruleMatches(userID uint32, r create.EscalationRule, question string, answer *model.AssistResponse) bool {
+	if (r.ConfidenceBelow != nil) != (answer != nil) {
+		return false
+	}
+	if len(r.Keywords) == 0 && r.Regex == "" && r.SentimentBelow == nil && r.ConfidenceBelow == nil {
+		return false
+	}
+
+	if len(r.Keywords) > 0 {
+		if _, ok := ruleTriggers(r.Keywords).Match(question); !ok {
+			return false
+		}
+	}
+	if r.Regex != "" {
+		re := ruleRegexp(r.Regex)
+		if re == nil || !re.MatchString(question) {
+			return false
+		}
+	}
+	if r.SentimentBelow != nil && s.scoreSentiment(userID, question) >= *r.SentimentBelow {
+		return false
+	}
+	if r.ConfidenceBelow != nil && (answer.Confidence == nil || *answer.Confidence >= *r.ConfidenceBelow) {
+		return false
+	}
+	return true
+}
+
+// applyRules выполняет действия подошедших правил. escalate — хоть одно требует эскалации,
+// reply — готовый ответ первого правила с действием reply вместо ответа модели
+func (s *Start) applyRules(u *model.RespModel, treadId uint64, question string, answer *model.AssistResponse, errCh chan<- error) (escalate bool, reply string) {
+	for _, r := range assistantRules(u) {
+		if !s.ruleMatches(u.Assist.UserID, r, question, answer) {
+			continue
+		}
+		for _, action := range r.Actions {
+			switch action {
+			case create.RuleEscalate:
+				escalate = true
+			case create.RuleTag:
+				if r.Tag != "" {
+					s.sendEvent(u.Assist.UserID, EventRuleTag, u.RespName, u.Assist.AssistName, r.Tag)
+				}
+			case create.RuleMeta:
+				meta := r.Meta
+				if meta == "" {
+					meta = "trigger"
+				}
+				if err := s.meta(u.Assist.UserID, treadId, meta, u.RespName, u.Assist.AssistName, u.Assist.Metas.MetaAction); err != nil {
+					s.sendError(errCh, fmt.Errorf("ошибка Meta %s userID=%d dialogID=%d: %w", meta, u.Assist.UserID, treadId, err))
+				}
+			case create.RuleWebhook:
+				if r.Webhook != "" {
+					s.ruleWebhook(u, treadId, r, question, answer)
+				}
+			case create.RuleReply:
+				if reply == "" {
+					reply = r.Reply
+				}
+			default:
+				//logger.Warn("rules: неизвестное действие %q в правиле %q", action, r.Name)
+			}
+		}
+	}
+	return escalate, reply
+}
+
+// ruleWebhook отправляет срабатывание правила на внешний адрес, не задерживая ответ
+func (s *Start) ruleWebhook(u *model.RespModel, treadId uint64, r create.EscalationRule, question string, answer *model.AssistResponse) {
+	payload := struct {
+		Rule     string                `json:"rule"`
+		UserID   uint32                `json:"user_id"`
+		DialogID uint64                `json:"dialog_id"`
+		Question string                `json:"question"`
+		Answer   *model.AssistResponse `json:"answer,omitempty"`
+		Time     time.Time             `json:"time"`
+	}{r.Name, u.Assist.UserID, treadId, question, answer, time.Now()}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return
+	}
+	url := r.Webhook
+
+	safego.Go("rules.webhook", func() {
+		client := &http.Client{Timeout: ruleWebhookTimeout}
+		resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+		if err != nil {
+			//logger.Warn("rules: webhook %s: %v", url, err, u.Assist.UserID)
+			return
+		}
+		_ = resp.Body.Close()
+	}, safego.WithUserID(u.Assist.UserID))
+}
+
+// Словарь для оценки тональности по умолчанию (основы слов, без учёта регистра)
+var (
+	negativeStems = []string{
+		"ужас", "отврат", "плох", "хуж", "кошмар", "обман", "мошен", "верните", "возврат", "жалоб",
+		"безобраз", "разочар", "бесит", "надоел", "не работает", "сломал", "долго", "никто не", "суд",
+		"terrible", "awful", "bad", "worst", "scam", "refund", "complain", "angry", "broken", "useless",
+	}
+	positiveStems = []string{
+		"спасибо", "отлично", "хорош", "супер", "прекрасн", "класс", "благодар", "помогли", "рад", "люблю",
+		"thanks", "thank you", "great", "good", "excellent", "awesome", "love", "perfect", "helpful",
+	}
+)
+
+// lexiconSentiment грубая словарная оценка: (позитив - негатив) / (позитив + негатив)
+func lexiconSentiment(text string) float64 {
+	lower := strings.ToLower(text)
+	count := func(stems []string) int {
+		n := 0
+		for _, stem := range stems {
+			n += countWordPrefix(lower, stem)
+		}
+		return n
+	}
+	pos, neg := count(positiveStems), count(negativeStems)
+	if pos+neg == 0 {
+		return 0
+	}
+	return float64(pos-neg) / float64(pos+neg)
+}
+
+// countWordPrefix считает вхождения stem с начала слова ("рад" не совпадает с "отрада")
+func countWordPrefix(text, stem string) int {
+	n := 0
+	for i := 0; ; {
+		j := strings.Index(text[i:], stem)
+		if j < 0 {
+			return n
+		}
+		pos := i + j
+		if prev, _ := utf8.DecodeLastRuneInString(text[:pos]); pos == 0 || !isWordRune(prev) {
+			n++
+		}
+		i = pos + len(stem)
+	}
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
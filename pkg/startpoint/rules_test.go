@@ -0,0 +1,167 @@
+package startpoint
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ikermy/AiR_Common/pkg/events"
+	"github.com/ikermy/AiR_Common/pkg/model"
+	"github.com/ikermy/AiR_Common/pkg/model/create"
+)
+
+// metaEndpoint memEndpoint с записью Meta-событий
+type metaEndpoint struct {
+	memEndpoint
+	metas []string
+}
+
+func (e *metaEndpoint) Meta(_ uint32, _ uint64, meta, _, _, _ string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.metas = append(e.metas, meta)
+	return nil
+}
+
+func ptr(v float64) *float64 { return &v }
+
+func TestRuleMatches_Conditions(t *testing.T) {
+	s := &Start{}
+	cases := []struct {
+		name     string
+		rule     create.EscalationRule
+		question string
+		answer   *model.AssistResponse
+		want     bool
+	}{
+		{"keyword", create.EscalationRule{Keywords: []string{"Возврат"}}, "хочу возврат денег", nil, true},
+		{"keyword miss", create.EscalationRule{Keywords: []string{"возврат"}}, "где заказ", nil, false},
+		{"regex", create.EscalationRule{Regex: `(?i)заказ\s*№?\d+`}, "Заказ №123 не пришёл", nil, true},
+		{"bad regex", create.EscalationRule{Regex: `(`}, "(", nil, false},
+		{"keyword and regex", create.EscalationRule{Keywords: []string{"заказ"}, Regex: `\d+`}, "заказ не пришёл", nil, false},
+		{"sentiment", create.EscalationRule{SentimentBelow: ptr(-0.5)}, "ужасный сервис, верните деньги", nil, true},
+		{"sentiment positive", create.EscalationRule{SentimentBelow: ptr(-0.5)}, "спасибо, отлично", nil, false},
+		{"confidence skipped on question", create.EscalationRule{ConfidenceBelow: ptr(0.5)}, "вопрос", nil, false},
+		{"confidence low", create.EscalationRule{ConfidenceBelow: ptr(0.5)}, "вопрос", &model.AssistResponse{Confidence: ptr(0.2)}, true},
+		{"confidence high", create.EscalationRule{ConfidenceBelow: ptr(0.5)}, "вопрос", &model.AssistResponse{Confidence: ptr(0.9)}, false},
+		{"confidence unknown", create.EscalationRule{ConfidenceBelow: ptr(0.5)}, "вопрос", &model.AssistResponse{}, false},
+		{"question rule skipped on answer", create.EscalationRule{Keywords: []string{"вопрос"}}, "вопрос", &model.AssistResponse{}, false},
+		{"empty rule", create.EscalationRule{}, "вопрос", nil, false},
+	}
+	for _, c := range cases {
+		if got := s.ruleMatches(1, c.rule, c.question, c.answer); got != c.want {
+			t.Errorf("%s: got %v, want %v", c.name, got, c.want)
+		}
+	}
+}
+
+func TestApplyRules_Actions(t *testing.T) {
+	got := make(chan map[string]any, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		got <- body
+	}))
+	defer srv.Close()
+
+	end := &metaEndpoint{}
+	s := &Start{End: end}
+	u := &model.RespModel{Assist: model.Assistant{
+		UserID: 1,
+		Metas:  model.Target{Triggers: []string{"жалоба"}},
+		Rules: []create.EscalationRule{
+			{Name: "refund", Keywords: []string{"возврат"}, Actions: []string{create.RuleEscalate, create.RuleTag, create.RuleWebhook}, Tag: "refund", Webhook: srv.URL},
+			{Name: "vip", Regex: `VIP`, Actions: []string{create.RuleMeta}, Meta: "vip"},
+		},
+	}}
+
+	if escalate, _ := s.applyRules(u, 7, "просто вопрос", nil, nil); escalate {
+		t.Fatalf("no rule should escalate")
+	}
+	if escalate, _ := s.applyRules(u, 7, "VIP клиент, жалоба и возврат", nil, nil); !escalate {
+		t.Fatalf("refund rule should escalate")
+	}
+
+	select {
+	case body := <-got:
+		if body["rule"] != "refund" || body["dialog_id"] != float64(7) {
+			t.Fatalf("unexpected webhook payload: %v", body)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("webhook was not called")
+	}
+
+	end.mu.Lock()
+	defer end.mu.Unlock()
+	if len(end.events) != 1 || end.events[0] != EventRuleTag {
+		t.Fatalf("unexpected events: %v", end.events)
+	}
+	if len(end.metas) != 2 || end.metas[0] != "vip" || end.metas[1] != "trigger" {
+		t.Fatalf("unexpected metas: %v", end.metas)
+	}
+}
+
+func TestApplyRules_CustomSentiment(t *testing.T) {
+	var scored []string
+	s := &Start{End: &metaEndpoint{}}
+	WithSentiment(func(text string) float64 {
+		scored = append(scored, text)
+		return -1
+	})(s)
+
+	u := &model.RespModel{Assist: model.Assistant{Rules: []create.EscalationRule{
+		{SentimentBelow: ptr(0), Actions: []string{create.RuleEscalate}},
+	}}}
+	if escalate, _ := s.applyRules(u, 1, "нейтрально", nil, nil); !escalate {
+		t.Fatalf("custom sentiment should escalate")
+	}
+	if len(scored) != 1 {
+		t.Fatalf("sentiment func calls: %v", scored)
+	}
+}
+
+func TestApplyRules_Reply(t *testing.T) {
+	s := &Start{End: &metaEndpoint{}}
+	u := &model.RespModel{Assist: model.Assistant{Rules: []create.EscalationRule{
+		{Name: "hours", Keywords: []string{"~часы работы"}, Actions: []string{create.RuleReply, create.RuleMeta}, Reply: "Работаем с 9 до 18", Meta: "hours"},
+		{Name: "unsure", ConfidenceBelow: ptr(0.5), Actions: []string{create.RuleReply}, Reply: "Уточню и вернусь с ответом"},
+	}}}
+
+	escalate, reply := s.applyRules(u, 1, "Какие у вас чясы работы?", nil, nil)
+	if escalate || reply != "Работаем с 9 до 18" {
+		t.Fatalf("question rule: escalate=%v reply=%q", escalate, reply)
+	}
+	low := 0.2
+	if _, reply = s.applyRules(u, 1, "вопрос", &model.AssistResponse{Confidence: &low}, nil); reply != "Уточню и вернусь с ответом" {
+		t.Fatalf("answer rule reply = %q", reply)
+	}
+}
+
+func TestApplyRules_ThroughEventBus(t *testing.T) {
+	bus := events.New(context.Background())
+	end := &metaEndpoint{}
+	counter := &events.Counter{}
+	bus.Subscribe("metrics", counter.Handle)
+	s := New(context.Background(), nil, end, nil, nil, WithEventBus(bus))
+	u := &model.RespModel{Assist: model.Assistant{
+		UserID: 1,
+		Rules: []create.EscalationRule{
+			{Name: "vip", Keywords: []string{"vip"}, Actions: []string{create.RuleTag, create.RuleMeta}, Tag: "vip", Meta: "vip"},
+		},
+	}}
+
+	s.applyRules(u, 7, "я VIP", nil, nil)
+	bus.Close()
+
+	end.mu.Lock()
+	defer end.mu.Unlock()
+	if len(end.events) != 1 || end.events[0] != EventRuleTag || len(end.metas) != 1 || end.metas[0] != "vip" {
+		t.Fatalf("bus did not deliver to endpoint: events=%v metas=%v", end.events, end.metas)
+	}
+	if c := counter.Counts(); c["meta:vip"] != 1 || c["notify:"+EventRuleTag] != 1 {
+		t.Fatalf("unexpected counts: %v", c)
+	}
+}
@@ -0,0 +1,117 @@
+package startpoint
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/ikermy/AiR_Common/pkg/comdb"
+	"github.com/ikermy/AiR_Common/pkg/logger"
+	"github.com/ikermy/AiR_Common/pkg/mode"
+	"github.com/ikermy/AiR_Common/pkg/model"
+	"github.com/ikermy/AiR_Common/pkg/safego"
+)
+
+// scheduleBatch сколько отложенных сообщений забирать за один опрос
+const scheduleBatch = 500
+
+// ScheduleStore — хранилище отложенных сообщений (реализуется *comdb.DB)
+type ScheduleStore interface {
+	SaveScheduledMessage(m comdb.ScheduledMessage) (uint64, error)
+	ListDueScheduledMessages(now time.Time, limit int) ([]comdb.ScheduledMessage, error)
+	MarkScheduledMessageDelivered(id uint64) error
+	RescheduleScheduledMessage(id uint64, at time.Time) error
+	DeleteScheduledMessage(id uint64) error
+}
+
+// WithScheduleStore подключает хранилище отложенных сообщений и запускает их доставку
+func WithScheduleStore(store ScheduleStore) Option {
+	return func(s *Start) {
+		s.sched = store
+	}
+}
+
+// ScheduleMessage ставит сообщение ассистента в диалог на время at (например, «напомнить через час»).
+// Сообщение доставляется по обычному пути ответа (answerCh → TxCh и сохранение в историю),
+// когда наступило время и диалог активен. Неудачная доставка повторяется с растущей паузой
+// (до mode.ScheduleRetryMaxDelay).
+func (s *Start) ScheduleMessage(userID uint32, dialogID, respID uint64, at time.Time, content model.AssistResponse) (uint64, error) {
+	if s.sched == nil {
+		return 0, fmt.Errorf("хранилище отложенных сообщений не подключено")
+	}
+	payload, err := json.Marshal(content)
+	if err != nil {
+		return 0, fmt.Errorf("ошибка сериализации отложенного сообщения: %w", err)
+	}
+	return s.sched.SaveScheduledMessage(comdb.ScheduledMessage{
+		UserID:    userID,
+		DialogID:  dialogID,
+		RespID:    respID,
+		DeliverAt: at,
+		Payload:   payload,
+	})
+}
+
+// CancelScheduledMessage отменяет ещё не доставленное сообщение
+func (s *Start) CancelScheduledMessage(id uint64) error {
+	if s.sched == nil {
+		return fmt.Errorf("хранилище отложенных сообщений не подключено")
+	}
+	return s.sched.DeleteScheduledMessage(id)
+}
+
+func (s *Start) runScheduler() {
+	safego.Go("scheduler", func() {
+		ticker := time.NewTicker(mode.SchedulePollInterval)
+		defer ticker.Stop()
+		for {
+			s.deliverDue(time.Now())
+			select {
+			case <-s.ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}, safego.WithRestart(-1, time.Second), safego.WithDone(s.ctx.Done()))
+}
+
+// deliverDue доставляет наступившие сообщения в активные диалоги.
+// Недоставленные (диалог неактивен или answerCh занят) переносятся с экспоненциальной паузой,
+// чтобы не занимать начало очереди и не задерживать остальные сообщения
+func (s *Start) deliverDue(now time.Time) {
+	due, err := s.sched.ListDueScheduledMessages(now, scheduleBatch)
+	if err != nil {
+		//logger.Error("scheduler: ошибка чтения отложенных сообщений: %v", err)
+		return
+	}
+	for _, m := range due {
+		var content model.AssistResponse
+		if err := json.Unmarshal(m.Payload, &content); err != nil {
+			// Повтор не поможет: сообщение не будет доставлено никогда
+			logger.Warn("scheduler: отложенное сообщение %d удалено, ошибка разбора: %v", m.ID, err, m.UserID)
+			if err := s.sched.DeleteScheduledMessage(m.ID); err != nil {
+				logger.Warn("scheduler: не удалось удалить отложенное сообщение %d: %v", m.ID, err, m.UserID)
+			}
+			continue
+		}
+		d, ok := s.activeDialog(m.DialogID)
+		if !ok || !d.deliver(Answer{Answer: content}) {
+			if err := s.sched.RescheduleScheduledMessage(m.ID, now.Add(scheduleRetryDelay(m.Attempts))); err != nil {
+				logger.Warn("scheduler: не удалось перенести отложенное сообщение %d: %v", m.ID, err, m.UserID)
+			}
+			continue
+		}
+		if err := s.sched.MarkScheduledMessageDelivered(m.ID); err != nil {
+			//logger.Error("scheduler: не удалось отметить доставку %d: %v", m.ID, err, m.UserID)
+		}
+	}
+}
+
+// scheduleRetryDelay пауза перед следующей попыткой после attempts неудачных
+func scheduleRetryDelay(attempts uint32) time.Duration {
+	delay := mode.SchedulePollInterval
+	for i := uint32(0); i < attempts && delay < mode.ScheduleRetryMaxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, mode.ScheduleRetryMaxDelay)
+}
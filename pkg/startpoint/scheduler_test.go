@@ -0,0 +1,148 @@
+package startpoint
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ikermy/AiR_Common/pkg/comdb"
+	"github.com/ikermy/AiR_Common/pkg/mode"
+	"github.com/ikermy/AiR_Common/pkg/model"
+)
+
+type memSchedule struct {
+	mu        sync.Mutex
+	items     map[uint64]*comdb.ScheduledMessage
+	delivered []uint64
+}
+
+func (m *memSchedule) SaveScheduledMessage(sm comdb.ScheduledMessage) (uint64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.items == nil {
+		m.items = make(map[uint64]*comdb.ScheduledMessage)
+	}
+	sm.ID = uint64(len(m.items) + 1)
+	m.items[sm.ID] = &sm
+	return sm.ID, nil
+}
+
+func (m *memSchedule) ListDueScheduledMessages(now time.Time, _ int) ([]comdb.ScheduledMessage, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out []comdb.ScheduledMessage
+	for _, sm := range m.items {
+		if sm.DeliveredAt == nil && !sm.DeliverAt.After(now) {
+			out = append(out, *sm)
+		}
+	}
+	return out, nil
+}
+
+func (m *memSchedule) MarkScheduledMessageDelivered(id uint64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	m.items[id].DeliveredAt = &now
+	m.delivered = append(m.delivered, id)
+	return nil
+}
+
+func (m *memSchedule) RescheduleScheduledMessage(id uint64, at time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.items[id].DeliverAt = at
+	m.items[id].Attempts++
+	return nil
+}
+
+func (m *memSchedule) DeleteScheduledMessage(id uint64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.items, id)
+	return nil
+}
+
+func TestScheduler_DeliversDueToActiveDialog(t *testing.T) {
+	store := &memSchedule{}
+	s := &Start{ctx: context.Background(), sched: store}
+
+	now := time.Now()
+	dueID, err := s.ScheduleMessage(1, 10, 5, now.Add(-time.Second), model.AssistResponse{Message: "напоминание"})
+	if err != nil {
+		t.Fatalf("ScheduleMessage: %v", err)
+	}
+	if _, err := s.ScheduleMessage(1, 10, 5, now.Add(time.Hour), model.AssistResponse{Message: "позже"}); err != nil {
+		t.Fatalf("ScheduleMessage: %v", err)
+	}
+
+	// Диалог неактивен — сообщение ждёт следующей попытки
+	s.deliverDue(now)
+	if len(store.delivered) != 0 {
+		t.Fatalf("message must wait until dialog is active")
+	}
+	if sm := store.items[dueID]; sm.Attempts != 1 || !sm.DeliverAt.Equal(now.Add(mode.SchedulePollInterval)) {
+		t.Fatalf("undelivered message not rescheduled: %+v", sm)
+	}
+
+	answerCh := make(chan Answer, 1)
+	s.registerActive(&model.RespModel{}, 5, 10, answerChDeliverer(answerCh))
+	now = now.Add(mode.SchedulePollInterval)
+	s.deliverDue(now)
+
+	select {
+	case ans := <-answerCh:
+		if ans.Answer.Message != "напоминание" {
+			t.Fatalf("unexpected answer: %+v", ans)
+		}
+	default:
+		t.Fatalf("due message was not delivered to answerCh")
+	}
+	if len(store.delivered) != 1 || store.delivered[0] != dueID {
+		t.Fatalf("unexpected delivered ids: %v", store.delivered)
+	}
+}
+
+func TestScheduler_FailedMessageDoesNotBlockQueue(t *testing.T) {
+	store := &memSchedule{}
+	s := &Start{ctx: context.Background(), sched: store}
+	now := time.Now()
+
+	stuck, _ := s.ScheduleMessage(1, 10, 5, now.Add(-time.Hour), model.AssistResponse{Message: "в неактивный диалог"})
+	broken, _ := store.SaveScheduledMessage(comdb.ScheduledMessage{DialogID: 11, DeliverAt: now.Add(-time.Hour), Payload: []byte("{")})
+	next, _ := s.ScheduleMessage(1, 12, 6, now.Add(-time.Second), model.AssistResponse{Message: "в активный"})
+	answerCh := make(chan Answer, 1)
+	s.registerActive(&model.RespModel{}, 6, 12, answerChDeliverer(answerCh))
+
+	s.deliverDue(now)
+	if len(store.delivered) != 1 || store.delivered[0] != next {
+		t.Fatalf("message behind a failing one not delivered: %v", store.delivered)
+	}
+	if _, ok := store.items[broken]; ok {
+		t.Fatalf("malformed message must be deleted")
+	}
+	if !store.items[stuck].DeliverAt.After(now) {
+		t.Fatalf("failing message must be moved back in the queue")
+	}
+}
+
+func TestScheduleRetryDelay(t *testing.T) {
+	if d := scheduleRetryDelay(0); d != mode.SchedulePollInterval {
+		t.Fatalf("first retry: %v", d)
+	}
+	if d := scheduleRetryDelay(2); d != 4*mode.SchedulePollInterval {
+		t.Fatalf("third retry: %v", d)
+	}
+	if d := scheduleRetryDelay(100); d != mode.ScheduleRetryMaxDelay {
+		t.Fatalf("retry delay not capped: %v", d)
+	}
+}
+
+func TestScheduler_ClosedAnswerCh(t *testing.T) {
+	answerCh := make(chan Answer, 1)
+	close(answerCh)
+	if answerChDeliverer(answerCh)(Answer{}) {
+		t.Fatalf("delivery to closed answerCh must fail")
+	}
+}
@@ -0,0 +1,34 @@
+package startpoint
+
+import (
+	"strconv"
+
+	"github.com/ikermy/AiR_Common/pkg/logger"
+	"github.com/ikermy/AiR_Common/pkg/model"
+)
+
+// MetaSeed имя метки Meta с seed детерминированного режима диалога ("off" — режим выключен)
+const MetaSeed = "seed"
+
+// WithDeterministicSeeds включает детерминированный режим по запросу: поле Seed входящего
+// сообщения задаёт seed диалога, он записывается меткой MetaSeed. Seed передаёт провайдерам
+// модель (тот же реестр передаётся в model.WithDeterministicSeeds)
+func WithDeterministicSeeds(seeds *model.Seeds) Option {
+	return func(s *Start) {
+		s.seeds = seeds
+	}
+}
+
+// applySeed задаёт seed диалога из сообщения и записывает изменение в Meta
+func (s *Start) applySeed(u *model.RespModel, treadId uint64, msg model.Message) {
+	if msg.Seed == nil || !s.seeds.Set(treadId, *msg.Seed) {
+		return
+	}
+	target := "off"
+	if *msg.Seed >= 0 {
+		target = strconv.FormatInt(*msg.Seed, 10)
+	}
+	if err := s.meta(u.Assist.UserID, treadId, MetaSeed, u.RespName, u.Assist.AssistName, target); err != nil {
+		logger.ForDialog(u.Assist.UserID, treadId).Warn("seed: ошибка записи метки", "err", err)
+	}
+}
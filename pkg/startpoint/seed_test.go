@@ -0,0 +1,38 @@
+package startpoint
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ikermy/AiR_Common/pkg/model"
+)
+
+func TestApplySeed(t *testing.T) {
+	seeds := model.NewSeeds()
+	end := &metaEndpoint{}
+	s := New(context.Background(), nil, end, nil, nil, WithDeterministicSeeds(seeds))
+	u := &model.RespModel{Assist: model.Assistant{UserID: 1}}
+	seed, off := int64(42), int64(-1)
+
+	s.applySeed(u, 7, model.Message{Seed: &seed})
+	s.applySeed(u, 7, model.Message{Seed: &seed}) // тот же seed — метка не повторяется
+	s.applySeed(u, 7, model.Message{})
+	if got, ok := seeds.Get(7); !ok || got != 42 {
+		t.Fatalf("seed диалога: %d %v", got, ok)
+	}
+
+	s.applySeed(u, 7, model.Message{Seed: &off})
+	if _, ok := seeds.Get(7); ok {
+		t.Fatal("отрицательный seed не выключил режим")
+	}
+	if len(end.metas) != 2 || end.metas[0] != MetaSeed {
+		t.Fatalf("метки: %v", end.metas)
+	}
+
+	// Без реестра поле Seed игнорируется
+	plain := New(context.Background(), nil, end, nil, nil)
+	plain.applySeed(u, 8, model.Message{Seed: &seed})
+	if len(end.metas) != 2 {
+		t.Fatalf("лишняя метка: %v", end.metas)
+	}
+}
@@ -0,0 +1,81 @@
+package startpoint
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/ikermy/AiR_Common/pkg/logger"
+	"github.com/ikermy/AiR_Common/pkg/mode"
+	"github.com/ikermy/AiR_Common/pkg/model/create"
+)
+
+// SentimentScorer оценивает тональность сообщения пользователя от -1 (негатив) до 1 (позитив).
+// В отличие от SentimentFunc получает пользователя (ключи провайдера) и может вернуть ошибку
+type SentimentScorer func(userID uint32, text string) (float64, error)
+
+// WithSentimentScorer оценивает каждое сообщение пользователя. Оценка сохраняется с ходом
+// диалога (AssistResponse.Sentiment) для аналитики и используется условиями SentimentBelow
+// вместо WithSentiment. При ошибке оценщика используется оценка без него
+func WithSentimentScorer(fn SentimentScorer) Option {
+	return func(s *Start) {
+		s.scorer = fn
+	}
+}
+
+// LocalSentiment словарная оценка без обращения к провайдеру
+func LocalSentiment(_ uint32, text string) (float64, error) {
+	return lexiconSentiment(text), nil
+}
+
+const sentimentPrompt = "Rate the sentiment of the user message from -1 (very negative, angry) to 1 (very positive). " +
+	"Answer with one number only."
+
+// LLMSentiment оценка одиночным запросом к модели провайдера
+// (например небольшой модели через UniversalModel.Provider)
+func LLMSentiment(ctx context.Context, client create.ProviderClient, modelName string) SentimentScorer {
+	return func(userID uint32, text string) (float64, error) {
+		reqCtx, cancel := context.WithTimeout(ctx, mode.SentimentTimeout)
+		defer cancel()
+
+		resp, err := client.Request(reqCtx, userID, create.ProviderRequest{
+			Model:     modelName,
+			System:    sentimentPrompt,
+			Messages:  []create.ProviderMessage{{Role: "user", Text: text}},
+			MaxTokens: 8,
+		})
+		if err != nil {
+			return 0, err
+		}
+		v, err := strconv.ParseFloat(strings.TrimSpace(resp.Text), 64)
+		if err != nil {
+			return 0, fmt.Errorf("некорректная оценка тональности %q", resp.Text)
+		}
+		return max(-1, min(1, v)), nil
+	}
+}
+
+// scoreSentiment оценка для правил: WithSentimentScorer, WithSentiment или словарная
+func (s *Start) scoreSentiment(userID uint32, text string) float64 {
+	if s.scorer != nil {
+		v, err := s.scorer(userID, text)
+		if err == nil {
+			return v
+		}
+		logger.Warn("sentiment: ошибка оценки тональности: %v", err, userID)
+	}
+	if s.sentiment != nil {
+		return s.sentiment(text)
+	}
+	return lexiconSentiment(text)
+}
+
+// turnSentiment оценка сообщения для сохранения с ходом диалога; nil — WithSentimentScorer не задан
+func (s *Start) turnSentiment(userID uint32, text string) *float64 {
+	if s.scorer == nil || strings.TrimSpace(text) == "" {
+		return nil
+	}
+	v := s.scoreSentiment(userID, text)
+	return &v
+}
@@ -0,0 +1,46 @@
+package startpoint
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ikermy/AiR_Common/pkg/model"
+	"github.com/ikermy/AiR_Common/pkg/model/create"
+)
+
+func TestTurnSentiment(t *testing.T) {
+	s := &Start{}
+	if s.turnSentiment(1, "всё ужасно") != nil {
+		t.Fatal("without scorer sentiment must not be stored")
+	}
+
+	WithSentimentScorer(LocalSentiment)(s)
+	if v := s.turnSentiment(1, "всё ужасно, верните деньги"); v == nil || *v >= 0 {
+		t.Fatalf("negative message scored %v", v)
+	}
+
+	// Ошибка оценщика — словарная оценка
+	WithSentimentScorer(func(uint32, string) (float64, error) { return 0, errors.New("timeout") })(s)
+	if v := s.turnSentiment(1, "спасибо, отлично"); v == nil || *v <= 0 {
+		t.Fatalf("fallback scored %v", v)
+	}
+}
+
+func TestSentimentScorer_FeedsRules(t *testing.T) {
+	var users []uint32
+	s := &Start{End: &metaEndpoint{}}
+	WithSentimentScorer(func(userID uint32, _ string) (float64, error) {
+		users = append(users, userID)
+		return -0.9, nil
+	})(s)
+
+	u := &model.RespModel{Assist: model.Assistant{UserID: 42, Rules: []create.EscalationRule{
+		{Name: "angry", SentimentBelow: ptr(-0.5), Actions: []string{create.RuleEscalate}},
+	}}}
+	if escalate, _ := s.applyRules(u, 1, "нейтральный текст", nil, nil); !escalate {
+		t.Fatal("angry user should be escalated")
+	}
+	if len(users) != 1 || users[0] != 42 {
+		t.Fatalf("scorer calls: %v", users)
+	}
+}
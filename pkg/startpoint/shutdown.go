@@ -0,0 +1,110 @@
+package startpoint
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/ikermy/AiR_Common/pkg/com"
+	"github.com/ikermy/AiR_Common/pkg/comdb"
+	"github.com/ikermy/AiR_Common/pkg/mode"
+)
+
+// errShuttingDown возвращается ask во время drain — новые запросы к модели не начинаются
+var errShuttingDown = errors.New("сервис завершает работу")
+
+// drainPollInterval период проверки счётчиков при drain
+const drainPollInterval = 50 * time.Millisecond
+
+// Shutdown корректно завершает Start:
+//  1. перестаёт принимать новые запросы к модели;
+//  2. ждёт (не дольше mode.ShutdownDrainTimeout) завершения активных запросов;
+//  3. отменяет контекст и ждёт, пока воркеры дочитают очередь SaveDialog.
+func (s *Start) Shutdown(shutCh chan<- com.LogMsg) {
+	s.draining.Store(true)
+	deadline := time.Now().Add(mode.ShutdownDrainTimeout)
+
+	asksLeft := waitZero(&s.inflight, deadline)
+
+	if s.cancel != nil {
+		s.cancel()
+	}
+
+	savesLeft := waitZero(&s.saveWorkers, deadline)
+
+	if asksLeft > 0 || savesLeft > 0 {
+		shutCh <- com.LogMsg{
+			Msg: fmt.Sprintf("drain прерван по таймауту: активных запросов %d, воркеров сохранения %d", asksLeft, savesLeft),
+			Mod: "Startpoint",
+			Log: 1, // 1 - Error
+			UID: 0,
+		}
+	}
+	shutCh <- com.LogMsg{
+		Msg: "успешно завершил работу",
+		Mod: "Startpoint",
+		Log: 0, // 0 - Info
+		UID: 0,
+	}
+}
+
+// Draining сообщает, что Start находится в фазе завершения
+func (s *Start) Draining() bool {
+	return s.draining.Load()
+}
+
+// waitZero ждёт обнуления счётчика до deadline, возвращает остаток
+func waitZero(c *atomic.Int64, deadline time.Time) int64 {
+	for {
+		n := c.Load()
+		if n <= 0 || !time.Now().Before(deadline) {
+			return n
+		}
+		time.Sleep(drainPollInterval)
+	}
+}
+
+// flushPending переносит в очередь сохранения вопросы и ответы,
+// которые Respondent успел отдать, но Listener уже не прочитал.
+// Вызывается из defer Listener до закрытия каналов.
+func (s *Start) flushPending(treadId uint64, fullQuestCh, answerCh <-chan Answer, saveCh chan<- saveTask) {
+	// Сначала вопросы, затем ответы — сохраняем порядок "вопрос → ответ"
+	for drained := false; !drained; {
+		select {
+		case quest := <-fullQuestCh:
+			creator := comdb.User
+			if quest.VoiceQuestion {
+				creator = comdb.UserVoice
+			}
+			enqueueSave(saveCh, saveTask{creator: creator, treadId: treadId, resp: quest.Answer})
+		default:
+			drained = true
+		}
+	}
+
+	for drained := false; !drained; {
+		select {
+		case resp := <-answerCh:
+			if resp.Err != nil {
+				continue
+			}
+			creator := comdb.AI
+			if resp.Operator.Operator {
+				creator = comdb.Operator
+			}
+			enqueueSave(saveCh, saveTask{creator: creator, treadId: treadId, resp: resp.Answer})
+		default:
+			drained = true
+		}
+	}
+}
+
+// enqueueSave отправляет задачу воркеру сохранения, не блокируясь дольше секунды
+func enqueueSave(saveCh chan<- saveTask, t saveTask) {
+	select {
+	case saveCh <- t:
+	case <-time.After(time.Second):
+		//logger.Warn("saveCh переполнен при drain, запись диалога %d потеряна", t.treadId)
+	}
+}
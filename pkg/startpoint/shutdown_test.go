@@ -0,0 +1,151 @@
+package startpoint
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ikermy/AiR_Common/pkg/com"
+	"github.com/ikermy/AiR_Common/pkg/comdb"
+	"github.com/ikermy/AiR_Common/pkg/model"
+)
+
+func TestShutdown_WaitsForInflight(t *testing.T) {
+	s := New(context.Background(), nil, nil, nil, nil)
+	s.inflight.Add(1)
+
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		if s.ctx.Err() != nil {
+			t.Errorf("context cancelled before in-flight request finished")
+		}
+		s.inflight.Add(-1)
+	}()
+
+	shutCh := make(chan com.LogMsg, 4)
+	start := time.Now()
+	s.Shutdown(shutCh)
+
+	if time.Since(start) < 100*time.Millisecond {
+		t.Fatalf("Shutdown returned before drain")
+	}
+	if !s.Draining() {
+		t.Fatalf("expected draining state")
+	}
+	if msg := <-shutCh; msg.Log != 0 {
+		t.Fatalf("unexpected drain timeout report: %+v", msg)
+	}
+}
+
+func TestShutdown_RejectsNewAsks(t *testing.T) {
+	s := New(context.Background(), nil, nil, nil, nil)
+	s.draining.Store(true)
+
+	_, err := s.ask(1, 1, 1, []string{"вопрос"})
+	if !IsNonCriticalError(err) {
+		t.Fatalf("expected non-critical error during drain, got %v", err)
+	}
+}
+
+func TestFlushPending_KeepsOrder(t *testing.T) {
+	s := New(context.Background(), nil, nil, nil, nil)
+	fullQuestCh := make(chan Answer, 2)
+	answerCh := make(chan Answer, 2)
+	saveCh := make(chan saveTask, 4)
+
+	answerCh <- Answer{Answer: model.AssistResponse{Message: "ответ"}}
+	answerCh <- Answer{Err: context.Canceled}
+	fullQuestCh <- Answer{Answer: model.AssistResponse{Message: "вопрос"}, VoiceQuestion: true}
+
+	s.flushPending(7, fullQuestCh, answerCh, saveCh)
+	close(saveCh)
+
+	var got []saveTask
+	for task := range saveCh {
+		got = append(got, task)
+	}
+	if len(got) != 2 {
+		t.Fatalf("expected 2 tasks, got %d", len(got))
+	}
+	if got[0].creator != comdb.UserVoice || got[1].creator != comdb.AI {
+		t.Fatalf("unexpected order: %+v", got)
+	}
+}
+
+// panicOnceEndpoint паникует на первом SaveDialog
+type panicOnceEndpoint struct {
+	memEndpoint
+	panicked atomic.Bool
+}
+
+func (e *panicOnceEndpoint) SaveDialog(creator comdb.CreatorType, treadId uint64, resp *model.AssistResponse) {
+	if e.panicked.CompareAndSwap(false, true) {
+		panic("save failed")
+	}
+	e.memEndpoint.SaveDialog(creator, treadId, resp)
+}
+
+func TestShutdown_WaitsForSaveWorkerAfterPanic(t *testing.T) {
+	end := &panicOnceEndpoint{}
+	s := New(context.Background(), nil, nil, nil, nil)
+	s.End = end
+	saveCh := make(chan saveTask, 4)
+	s.startSaveWorker(1, saveCh)
+
+	saveCh <- saveTask{creator: comdb.User, treadId: 7}
+	saveCh <- saveTask{creator: comdb.AI, treadId: 7}
+	for deadline := time.Now().Add(2 * time.Second); ; {
+		end.mu.Lock()
+		n := len(end.saved)
+		end.mu.Unlock()
+		if n == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("worker not restarted after panic")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := s.saveWorkers.Load(); n != 1 {
+		t.Fatalf("saveWorkers after restart = %d, want 1", n)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		s.Shutdown(make(chan com.LogMsg, 4))
+		close(done)
+	}()
+	select {
+	case <-done:
+		t.Fatal("Shutdown returned while save worker is running")
+	case <-time.After(150 * time.Millisecond):
+	}
+
+	close(saveCh)
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Shutdown did not return after save worker finished")
+	}
+	if n := s.saveWorkers.Load(); n != 0 {
+		t.Fatalf("saveWorkers after shutdown = %d", n)
+	}
+}
+
+// restoringModel — модель, восстанавливающая контекст прежнего процесса
+type restoringModel struct {
+	chModel
+	restored int
+}
+
+func (m *restoringModel) RestoreContextAfterStart() { m.restored++ }
+
+func TestNew_RestoresContextBeforeStart(t *testing.T) {
+	mod := &restoringModel{}
+	s := New(context.Background(), mod, &memEndpoint{}, nil, nil)
+	defer s.cancel()
+	if mod.restored != 1 {
+		t.Fatalf("RestoreContextAfterStart called %d times, want 1", mod.restored)
+	}
+}
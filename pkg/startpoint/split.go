@@ -0,0 +1,157 @@
+package startpoint
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf16"
+
+	"github.com/ikermy/AiR_Common/pkg/model"
+)
+
+// WithMessageSplit делит ответы ассистента длиннее limit на несколько сообщений
+// (mode.TelegramMessageLimit для Telegram). Длина считается в единицах UTF-16, как её
+// считают Telegram и другие каналы: эмодзи вне BMP — две единицы. Без опции ответ уходит одним сообщением
+func WithMessageSplit(limit int) Option {
+	return func(s *Start) {
+		if limit > 0 {
+			s.splitLimit = limit
+		}
+	}
+}
+
+// SplitResponse делит текст ответа на части не длиннее limit единиц UTF-16 по границам абзацев,
+// предложений или слов. Файлы, кнопки и флаги ответа остаются у последней части
+func SplitResponse(resp model.AssistResponse, limit int) []model.AssistResponse {
+	chunks := splitText(resp.Message, limit)
+	if len(chunks) < 2 {
+		return []model.AssistResponse{resp}
+	}
+	parts := make([]model.AssistResponse, len(chunks))
+	for i, chunk := range chunks[:len(chunks)-1] {
+		parts[i] = model.AssistResponse{Message: chunk}
+	}
+	resp.Message = chunks[len(chunks)-1]
+	parts[len(parts)-1] = resp
+	return parts
+}
+
+// splitText делит text на части не длиннее limit единиц UTF-16 (limit <= 0 — без деления).
+// Разрез проходит только между символами: суррогатная пара не разрывается
+func splitText(text string, limit int) []string {
+	runes := []rune(text)
+	if limit <= 0 || utf16Len(runes) <= limit {
+		return []string{text}
+	}
+
+	var chunks []string
+	for {
+		n := fitRunes(runes, limit)
+		if n == len(runes) {
+			break
+		}
+		cut := splitPoint(runes[:n+1])
+		if chunk := strings.TrimSpace(string(runes[:cut])); chunk != "" {
+			chunks = append(chunks, chunk)
+		}
+		runes = []rune(strings.TrimLeftFunc(string(runes[cut:]), unicode.IsSpace))
+	}
+	if rest := strings.TrimSpace(string(runes)); rest != "" {
+		chunks = append(chunks, rest)
+	}
+	return chunks
+}
+
+// splitPoint позиция разреза в window (limit+1 символов): последняя граница абзаца,
+// затем предложения, строки, слова — если она не раньше середины окна; иначе любая
+// найденная граница или жёсткий разрез по лимиту
+func splitPoint(window []rune) int {
+	limit := len(window) - 1
+	var fallback int
+	for _, find := range []func([]rune) int{lastParagraph, lastSentence, lastLine, lastSpace} {
+		cut := find(window)
+		if cut > limit/2 {
+			return cut
+		}
+		if fallback == 0 {
+			fallback = cut
+		}
+	}
+	if fallback > 0 {
+		return fallback
+	}
+	return limit
+}
+
+// utf16Len длина runes в единицах UTF-16
+func utf16Len(runes []rune) int {
+	n := 0
+	for _, r := range runes {
+		n += utf16.RuneLen(r)
+	}
+	return n
+}
+
+// fitRunes сколько первых символов runes помещается в limit единиц UTF-16 (не меньше одного)
+func fitRunes(runes []rune, limit int) int {
+	units := 0
+	for i, r := range runes {
+		units += utf16.RuneLen(r)
+		if units > limit {
+			return max(i, 1)
+		}
+	}
+	return len(runes)
+}
+
+func lastParagraph(w []rune) int {
+	for i := len(w) - 2; i > 0; i-- {
+		if w[i] == '\n' && w[i+1] == '\n' {
+			return i
+		}
+	}
+	return 0
+}
+
+// lastSentence позиция после знака конца предложения, за которым идёт пробел
+func lastSentence(w []rune) int {
+	for i := len(w) - 2; i > 0; i-- {
+		if strings.ContainsRune(".!?…", w[i]) && unicode.IsSpace(w[i+1]) {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+func lastLine(w []rune) int {
+	for i := len(w) - 1; i > 0; i-- {
+		if w[i] == '\n' {
+			return i
+		}
+	}
+	return 0
+}
+
+func lastSpace(w []rune) int {
+	for i := len(w) - 1; i > 0; i-- {
+		if unicode.IsSpace(w[i]) {
+			return i
+		}
+	}
+	return 0
+}
+
+// sendAssist отправляет ответ ассистента в TxCh; длинный ответ (WithMessageSplit) — по частям
+// в исходном порядке, с номером части в Message.Part
+func (s *Start) sendAssist(usrCh *model.Ch, op model.Operator, answer model.AssistResponse, name *string) error {
+	parts := SplitResponse(answer, s.splitLimit)
+	for i := range parts {
+		msg := s.Mod.NewMessage(op, "assist", &parts[i], name)
+		if len(parts) > 1 {
+			msg.Part, msg.Parts = i+1, len(parts)
+		}
+		if err := usrCh.SendToTx(msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package startpoint
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/ikermy/AiR_Common/pkg/model"
+)
+
+func TestSplitText_SentenceBoundaries(t *testing.T) {
+	text := "Первое предложение. Второе предложение! Третье предложение? Четвёртое."
+	chunks := splitText(text, 40)
+	want := []string{"Первое предложение. Второе предложение!", "Третье предложение? Четвёртое."}
+	if len(chunks) != len(want) {
+		t.Fatalf("chunks = %q", chunks)
+	}
+	for i := range want {
+		if chunks[i] != want[i] {
+			t.Fatalf("chunk %d = %q, want %q", i, chunks[i], want[i])
+		}
+	}
+	if got := splitText("коротко", 40); len(got) != 1 || got[0] != "коротко" {
+		t.Fatalf("короткий текст разделён: %q", got)
+	}
+}
+
+func TestSplitText_NoBoundaries(t *testing.T) {
+	chunks := splitText(strings.Repeat("я", 25), 10)
+	if len(chunks) != 3 || utf8.RuneCountInString(chunks[0]) != 10 || utf8.RuneCountInString(chunks[2]) != 5 {
+		t.Fatalf("chunks = %q", chunks)
+	}
+}
+
+func TestSplitText_UTF16Units(t *testing.T) {
+	// Эмодзи вне BMP — две единицы UTF-16: по символам текст помещался бы в лимит
+	text := strings.Repeat("🙂", 15)
+	chunks := splitText(text, 20)
+	if len(chunks) != 2 || utf16Len([]rune(chunks[0])) != 20 || utf16Len([]rune(chunks[1])) != 10 {
+		t.Fatalf("chunks = %q", chunks)
+	}
+
+	text = "Готово 🎉🎉 Заказ 🚚 в пути. Ждите 👍"
+	for _, chunk := range splitText(text, 16) {
+		if n := utf16Len([]rune(chunk)); n > 16 || !utf8.ValidString(chunk) {
+			t.Fatalf("часть %q: %d единиц UTF-16", chunk, n)
+		}
+	}
+	if got := splitText("🙂🙂", 1); len(got) != 2 {
+		t.Fatalf("лимит меньше символа: %q", got)
+	}
+}
+
+func TestSendAssist_SplitsInOrder(t *testing.T) {
+	ch := &model.Ch{TxCh: make(chan model.Message, 8), RxCh: make(chan model.Message, 1)}
+	s := New(context.Background(), &chModel{ch: ch}, nil, nil, nil, WithMessageSplit(20))
+	defer s.cancel()
+
+	answer := model.AssistResponse{
+		Message: "Раз два три. Четыре пять шесть. Семь восемь.",
+		Action:  model.Action{SendFiles: []model.File{{Type: model.Doc, URL: "https://example.test/a.pdf"}}},
+		Meta:    true,
+	}
+	if err := s.sendAssist(ch, model.Operator{}, answer, nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(ch.TxCh) != 3 {
+		t.Fatalf("ожидалось 3 сообщения, получено %d", len(ch.TxCh))
+	}
+	for i := 1; i <= 3; i++ {
+		msg := <-ch.TxCh
+		if msg.Part != i || msg.Parts != 3 || utf8.RuneCountInString(msg.Content.Message) > 20 {
+			t.Fatalf("часть %d: %+v", i, msg)
+		}
+		last := i == 3
+		if (len(msg.Content.Action.SendFiles) == 1) != last || msg.Content.Meta != last {
+			t.Fatalf("файлы и флаги должны быть только у последней части: %+v", msg)
+		}
+	}
+}
@@ -8,15 +8,20 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
-	"github.com/ikermy/AiR_Common/pkg/com"
 	"github.com/ikermy/AiR_Common/pkg/comdb"
 	"github.com/ikermy/AiR_Common/pkg/endpoint"
+	"github.com/ikermy/AiR_Common/pkg/events"
+	"github.com/ikermy/AiR_Common/pkg/experiment"
+	"github.com/ikermy/AiR_Common/pkg/logger"
 	"github.com/ikermy/AiR_Common/pkg/mode"
 	"github.com/ikermy/AiR_Common/pkg/model"
 	"github.com/ikermy/AiR_Common/pkg/model/create"
 	"github.com/ikermy/AiR_Common/pkg/operator"
+	"github.com/ikermy/AiR_Common/pkg/safego"
+	"github.com/ikermy/AiR_Common/pkg/webhook"
 )
 
 // safeStopTimer корректно останавливает таймер, очищая канал если сигнал уже был отправлен.
@@ -42,17 +47,9 @@ func (s *Start) routeQuestToOperator(
 	fullQuestCh chan Answer,
 	errCh chan error,
 ) (shouldReturn bool) {
-	msgType := "user"
-	if quest.Voice {
-		msgType = "user_voice"
-	}
 	content := model.AssistResponse{Message: strings.Join(quest.Question, "\n")}
-	name := u.Assist.AssistName
-	opMsg := s.Mod.NewMessage(
-		model.Operator{SetOperator: false, Operator: false, SenderName: quest.Operator.SenderName},
-		msgType, &content, &name, quest.Files...,
-	)
-	if err := s.Oper.SendToOperator(s.ctx, u.Assist.UserID, treadId, opMsg); err != nil {
+	content.Sentiment = s.turnSentiment(u.Assist.UserID, content.Message)
+	if err := s.sendQuestToOperator(u, treadId, quest, false); err != nil {
 		s.sendError(errCh, fmt.Errorf("ошибка отправки сообщения оператору: %v", err))
 	}
 	select {
@@ -64,6 +61,22 @@ func (s *Start) routeQuestToOperator(
 	return false
 }
 
+// sendQuestToOperator неблокирующе отправляет вопрос пользователя оператору.
+// escalation помечает сообщение как запрос на подключение оператора (Operator: true).
+func (s *Start) sendQuestToOperator(u *model.RespModel, treadId uint64, quest Question, escalation bool) error {
+	msgType := "user"
+	if quest.Voice {
+		msgType = "user_voice"
+	}
+	content := model.AssistResponse{Message: strings.Join(quest.Question, "\n")}
+	name := u.Assist.AssistName
+	opMsg := s.Mod.NewMessage(
+		model.Operator{Operator: escalation, SenderName: quest.Operator.SenderName},
+		msgType, &content, &name, quest.Files...,
+	)
+	return s.Oper.SendToOperator(s.ctx, u.Assist.UserID, treadId, opMsg)
+}
+
 // sendError безопасно отправляет ошибку в errCh без блокировки.
 // Если канал переполнен, ошибка логируется как предупреждение.
 func (s *Start) sendError(errCh chan<- error, err error) {
@@ -100,18 +113,45 @@ func (s *Start) sendFallbackAnswer(answerCh chan<- Answer, err error) {
 	})
 }
 
+// FatalFallbackMessage ответ пользователю при критической ошибке модели, если у ассистента
+// не задан Assistant.Fallback
+const FatalFallbackMessage = "⚠️ Технические неполадки, оператор уведомлён. Пожалуйста, напишите нам чуть позже."
+
+// EventFatalError событие SendEvent о критической ошибке модели
+const EventFatalError = "ai-fatal-error"
+
+// fatalFallback сообщает пользователю о неполадках до завершения диалога (иначе он остаётся
+// без ответа) и поднимает тревогу: событие EventFatalError каналам и webhook.EventAlert.
+// Ответ отправляется в TxCh напрямую: answerCh после ошибки в errCh уже не читается
+func (s *Start) fatalFallback(u *model.RespModel, respId, treadId uint64, err error) {
+	answer := model.AssistResponse{Message: FatalFallbackMessage}
+	if u.Assist.Fallback != nil {
+		answer = *u.Assist.Fallback
+	}
+	if ch, chErr := s.Mod.GetCh(respId); chErr == nil {
+		if sendErr := s.sendAssist(ch, model.Operator{}, answer, &u.Assist.AssistName); sendErr != nil {
+			logger.ForDialog(u.Assist.UserID, treadId).Warn("fallback: ответ не доставлен", "err", sendErr)
+		}
+	}
+	s.sendEvent(u.Assist.UserID, EventFatalError, u.RespName, u.Assist.AssistName, err.Error())
+	s.emit(u, respId, treadId, webhook.EventAlert, map[string]any{"error": err.Error(), "fallback": answer.Message})
+}
+
 func (s *Start) handleAskFailure(
 	u *model.RespModel,
 	err error,
 	answerCh chan<- Answer,
 	errCh chan<- error,
 	fatalMessage string,
+	turn failedTurn,
 ) (shouldReturn bool) {
 	if IsProviderLimitError(err) {
 		s.handleProviderLimitError(u.Assist.UserID, u.RespName, u.Assist.AssistName, err.Error())
 		return false
 	}
 	if IsFatalError(err) {
+		s.deadLetter(u, turn, DeadLetterFatal, err)
+		s.fatalFallback(u, turn.respId, turn.dialogID, err)
 		s.sendError(errCh, fmt.Errorf("%s: %v", fatalMessage, err))
 		return true
 	}
@@ -126,11 +166,42 @@ func operatorSystemAnswer(message string) Answer {
 	}
 }
 
-func operatorTimeoutMessage() string {
-	if mode.OperatorResponseTimeout%60 == 0 && mode.OperatorResponseTimeout >= 60 {
-		return fmt.Sprintf("⏱️ Оператор не ответил в течение %d мин\nПродолжаю работу в режиме AI-агента 🧠", mode.OperatorResponseTimeout/60)
+// NoOperatorsMessage ответ пользователю, когда операторов нет на связи
+const NoOperatorsMessage = "🚫👨‍💻 Нет доступных операторов \n Продолжаю работу в режиме AI-агента 🧠"
+
+// operatorAvailable true, если у пользователя есть оператор на связи.
+// Реализации Operator без отслеживания присутствия считаются всегда доступными.
+func (s *Start) operatorAvailable(userID uint32) bool {
+	if p, ok := s.Oper.(interface{ OperatorAvailable(uint32) bool }); ok {
+		return p.OperatorAvailable(userID)
+	}
+	return true
+}
+
+// noOperatorFallback тихо отключает операторский режим в боте и сообщает пользователю,
+// что вопрос обработает AI. Возвращает false, если answerCh закрыт.
+func (s *Start) noOperatorFallback(u *model.RespModel, treadId uint64, answerCh chan<- Answer, errCh chan<- error) bool {
+	if err := s.Bot.DisableOperatorMode(u.Assist.UserID, treadId, true); err != nil {
+		s.sendError(errCh, fmt.Errorf("ошибка при отключении режима оператора: %w", err))
+	}
+	return s.pushAnswer(answerCh, errCh, operatorSystemAnswer(NoOperatorsMessage), "канал answerCh закрыт при отправке сообщения об отсутствии операторов")
+}
+
+// operatorTimeout время ожидания первого ответа оператора на одну попытку
+func operatorTimeout(u *model.RespModel) time.Duration {
+	if u.Assist.Operator.Timeout > 0 {
+		return time.Duration(u.Assist.Operator.Timeout) * time.Second
 	}
-	return fmt.Sprintf("⏱️ Оператор не ответил в течение %d сек\nПродолжаю работу в режиме AI-агента 🧠", mode.OperatorResponseTimeout)
+	return time.Duration(mode.OperatorResponseTimeout) * time.Second
+}
+
+// operatorTimeoutMessage сообщает, сколько всего ждали оператора с учётом повторов
+func operatorTimeoutMessage(u *model.RespModel) string {
+	total := int(operatorTimeout(u)/time.Second) * (int(u.Assist.Operator.Retries) + 1)
+	if total%60 == 0 && total >= 60 {
+		return fmt.Sprintf("⏱️ Оператор не ответил в течение %d мин\nПродолжаю работу в режиме AI-агента 🧠", total/60)
+	}
+	return fmt.Sprintf("⏱️ Оператор не ответил в течение %d сек\nПродолжаю работу в режиме AI-агента 🧠", total)
 }
 
 func stopOperatorTimeoutTimer(timer *time.Timer, timeoutCh <-chan struct{}) *time.Timer {
@@ -147,29 +218,67 @@ func stopOperatorTimeoutTimer(timer *time.Timer, timeoutCh <-chan struct{}) *tim
 
 func (s *Start) startOperatorMode(u *model.RespModel, treadId uint64, timeoutCh chan<- struct{}) (<-chan model.Message, *time.Timer) {
 	operatorRxCh := s.Oper.ReceiveFromOperator(s.ctx, u.Assist.UserID, treadId)
-	operatorTimeoutTimer := time.AfterFunc(time.Duration(mode.OperatorResponseTimeout)*time.Second, func() {
+	return operatorRxCh, armOperatorTimeout(u, timeoutCh)
+}
+
+// armOperatorTimeout взводит таймер одной попытки ожидания оператора
+func armOperatorTimeout(u *model.RespModel, timeoutCh chan<- struct{}) *time.Timer {
+	return time.AfterFunc(operatorTimeout(u), func() {
 		select {
 		case timeoutCh <- struct{}{}:
 		default:
 		}
 	})
-	return operatorRxCh, operatorTimeoutTimer
 }
 
 // handleProviderLimitError обрабатывает лимитную ошибку AI-провайдера:
 // отправляет уведомление пользователю через внешние каналы и возвращает deaf=false для продолжения цикла.
 // Возвращает true, если вызывающий должен выполнить continue.
 func (s *Start) handleProviderLimitError(userID uint32, respName, assistName, errMsg string) bool {
-	s.End.SendEvent(userID, "ai-provider-limit", respName, assistName, errMsg)
+	s.sendEvent(userID, "ai-provider-limit", respName, assistName, errMsg)
 	return true
 }
 
+// checkTriggers применяет правила ассистента к вопросу пользователя.
+// Возвращает true, если правило требует передать вопрос оператору, и готовый ответ
+// правила reply, которым отвечают вместо модели.
+func (s *Start) checkTriggers(u *model.RespModel, treadId uint64, userQuestion string, errCh chan<- error) (bool, string) {
+	return s.applyRules(u, treadId, userQuestion, nil, errCh)
+}
+
+// checkAnswerRules применяет правила ассистента, зависящие от ответа модели (уверенность).
+// Возвращает true, если правило требует эскалации, и готовый ответ правила reply,
+// который заменяет ответ модели.
+func (s *Start) checkAnswerRules(u *model.RespModel, treadId uint64, userQuestion string, answer model.AssistResponse, errCh chan<- error) (bool, string) {
+	return s.applyRules(u, treadId, userQuestion, &answer, errCh)
+}
+
+// checkTarget отправляет Meta-событие "target", если ассистент пометил ответ как достигший цели
+func (s *Start) checkTarget(u *model.RespModel, respId, treadId uint64, answer model.AssistResponse, errCh chan<- error) {
+	if u.Assist.Metas.MetaAction == "" {
+		return
+	}
+	if answer.Meta { // Ассистент пометил ответ как достигший цели
+		s.emit(u, respId, treadId, webhook.EventTargetReached, map[string]any{"target": u.Assist.Metas.MetaAction})
+		if err := s.meta(u.Assist.UserID, treadId, "target", u.RespName, u.Assist.AssistName, u.Assist.Metas.MetaAction); err != nil {
+			s.sendError(errCh, fmt.Errorf("ошибка Meta цель userID=%d dialogID=%d: %w", u.Assist.UserID, treadId, err))
+		}
+		s.scheduleTargetCSAT(u, respId, treadId)
+	}
+
+	// Только для Lead Hunter достижение цели с передачей контакта
+	if err := s.End.CallOptional(int64(respId)); err != nil {
+		//logger.Error("ошибка вызова CallOptional для respId %d: %v", respId, err)
+	}
+}
+
 // Question структура для хранения вопросов пользователя
 type Question struct {
 	Question []string           // Вопрос пользователя, может состоять из нескольких вопросов
 	Voice    bool               // Флаг, указывающий, что вопрос был задан голосом
 	Files    []model.FileUpload // Файлы, прикрепленные к вопросу
 	Operator model.Operator     // Если true — вопрос должен быть отправлен оператору, а не модели
+	TraceID  string             // Идентификатор хода для сквозной трассировки логов
 }
 
 // Answer структура для хранения ответов пользователя
@@ -208,6 +317,57 @@ type Start struct {
 	// Накопители потоковых дельт по респондентам.
 	// key: uint64 (respId), value: *streamAccumulator
 	streamAccumulators sync.Map
+
+	// Drain при завершении: новые запросы к модели не принимаются,
+	// Shutdown ждёт активные запросы и воркеры сохранения диалога
+	draining    atomic.Bool
+	inflight    atomic.Int64 // активные запросы к модели (ask)
+	saveWorkers atomic.Int64 // активные воркеры SaveDialog
+
+	dlq     DeadLetterStore // хранилище необработанных ходов (nil — не сохраняются)
+	dedup   *dedupCache     // отсев повторно доставленных входящих сообщений
+	limiter *rateLimiter    // лимит входящих сообщений на диалог и пользователя
+	pool    *dialogPool     // пул воркеров (nil — Listener+Respondent на каждый диалог)
+	sched   ScheduleStore   // хранилище отложенных сообщений (nil — планировщик выключен)
+
+	sentiment   SentimentFunc        // тональность для правил эскалации (nil — словарная оценка)
+	scorer      SentimentScorer      // тональность сообщений пользователя, сохраняется с ходом (nil — не сохраняется)
+	summarizer  TranscriptSummarizer // сводка ранних сообщений для оператора (nil — без сводки)
+	webhooks    *webhook.Dispatcher  // исходящие события диалогов (nil — не отправляются)
+	bus         *events.Bus          // шина событий диалогов (nil — прямые вызовы Endpoint и webhook)
+	escalations EscalationStore      // очередь к операторам (nil — ожидание с фиксированным таймаутом)
+	pendingAsks PendingAskStore      // накопленные батчингом вопросы (nil — только в памяти)
+	opStates    OperatorStateStore   // операторский режим диалогов (nil — теряется при перезапуске)
+	compaction  *dialogCompaction    // сжатие истории давних диалогов (nil — выключено)
+	assistants  *assistantRouter     // выбор ассистента диалога (nil — один ассистент у пользователя)
+	intents     *IntentRouting       // классификатор намерений перед запросом к модели (nil — без него)
+	csat        *csatSurvey          // опрос удовлетворённости после диалога (nil — без опроса)
+	analytics   *analytics           // дневные показатели ассистентов (nil — не считаются)
+	topics      *TopicTagging        // разметка тем завершённых диалогов (nil — без разметки)
+	experiments *experiment.Registry // A/B-эксперименты для меток диалогов (nil — без меток)
+	seeds       *model.Seeds         // детерминированный режим диалогов (nil — Message.Seed игнорируется)
+	moderator   model.Moderator      // модерация вопросов и ответов (nil — без проверки)
+	moderation  ModerationPolicy     // что проверять и как реагировать на нарушение
+	splitLimit  int                  // максимальная длина сообщения ассистента (0 — без деления)
+	escWaiters  sync.Map             // key: uint64 (treadId), value: *escalationWaiter
+	opInbox     sync.Map             // key: uint64 (treadId), value: chan model.Message (SendOperatorMessage)
+	switchInbox sync.Map             // key: uint64 (treadId), value: chan model.Assistant (SwitchAssistant)
+
+	// Активные диалоги для доставки вне хода пользователя (отложенные сообщения, рассылки).
+	// key: uint64 (treadId), value: *activeDialog
+	active sync.Map
+
+	broadcasts sync.Map // key: uint64 (id рассылки), value: *broadcast
+}
+
+// Option настраивает Start при создании
+type Option func(*Start)
+
+// WithDeadLetterStore подключает хранилище необработанных ходов (обычно *comdb.DB)
+func WithDeadLetterStore(store DeadLetterStore) Option {
+	return func(s *Start) {
+		s.dlq = store
+	}
 }
 
 // streamAccumulator накапливает сырые дельты и извлекает текст из поля "message".
@@ -623,9 +783,9 @@ func skipJSONValue(s string, i int) (next int, ok bool) {
 }
 
 // New создаёт новый экземпляр Start
-func New(parent context.Context, mod Model, end Endpoint, bot BotInterface, operator Operator) *Start {
+func New(parent context.Context, mod Model, end Endpoint, bot BotInterface, operator Operator, opts ...Option) *Start {
 	ctx, cancel := context.WithCancel(parent)
-	return &Start{
+	s := &Start{
 		ctx:    ctx,
 		cancel: cancel,
 
@@ -633,28 +793,45 @@ func New(parent context.Context, mod Model, end Endpoint, bot BotInterface, oper
 		End:  end,
 		Bot:  bot,
 		Oper: operator,
-	}
-}
 
-// Shutdown останавливает внутренний контекст Start и даёт возможность корректно завершить фоновые операции
-func (s *Start) Shutdown(shutCh chan<- com.LogMsg) {
-	if s.cancel != nil {
-		s.cancel()
+		dedup:   newDedupCache(),
+		limiter: newRateLimiter(),
 	}
-	shutCh <- com.LogMsg{
-		Msg: "успешно завершил работу",
-		Mod: "Startpoint",
-		Log: 0, // 0 - Info
-		UID: 0,
+	for _, opt := range opts {
+		opt(s)
 	}
+	// Респонденты и кэш истории прежнего процесса восстанавливаются до приёма сообщений
+	if restorer, ok := mod.(model.ContextRestorer); ok {
+		restorer.RestoreContextAfterStart()
+	}
+	if s.bus != nil {
+		s.subscribeBus()
+	}
+	if s.pool != nil {
+		s.pool.run()
+	}
+	if s.sched != nil {
+		s.runScheduler()
+	}
+	if s.escalations != nil {
+		s.runEscalationQueue()
+	}
+	if s.compaction != nil {
+		s.runCompaction()
+	}
+	if s.pendingAsks != nil {
+		safego.Go("pendingAsks.sweep", func() {
+			s.sweepPendingAsks(time.Now())
+		})
+	}
+	return s
 }
 
 func (s *Start) ask(userID uint32, respId, dialogID uint64, arrAsk []string, files ...model.FileUpload) (model.AssistResponse, error) {
 	var emptyResponse model.AssistResponse
+	// Каналы не закрываются: после тайм-аута горутина запроса ещё может в них писать
 	answerCh := make(chan model.AssistResponse, 1)
 	errCh := make(chan error, 1)
-	defer close(answerCh)
-	defer close(errCh)
 
 	var ask string
 	for _, v := range arrAsk {
@@ -667,6 +844,12 @@ func (s *Start) ask(userID uint32, respId, dialogID uint64, arrAsk []string, fil
 		return emptyResponse, fmt.Errorf("ASK EMPTY MESSAGE AND NO FILES")
 	}
 
+	if s.draining.Load() {
+		return emptyResponse, &NonCriticalError{Err: errShuttingDown}
+	}
+	s.inflight.Add(1)
+	defer s.inflight.Add(-1)
+
 	if mode.TestAnswer {
 		filesInfo := ""
 		if len(files) > 0 {
@@ -678,10 +861,19 @@ func (s *Start) ask(userID uint32, respId, dialogID uint64, arrAsk []string, fil
 	}
 
 	// Контекст ожидания ответа модели с таймаутом, завязанным на общий контекст Start
-	ctx, cancel := context.WithTimeout(s.ctx, mode.ErrorTimeOutDurationForAssistAnswer*time.Minute)
+	ctx, cancel := context.WithTimeout(logger.WithTraceID(s.ctx, logger.DialogTrace(dialogID)), mode.ErrorTimeOutDurationForAssistAnswer*time.Minute)
 	defer cancel()
 
-	go func() {
+	// Индикатор набора на время запроса; typing_done уходит в TxCh раньше ответа
+	defer s.startTyping(ctx, respId)()
+
+	// Горутина запроса учитывается в inflight до своего завершения, а не до выхода из ask:
+	// остановка Start дожидается и запросов, переживших тайм-аут
+	started := time.Now()
+	s.inflight.Add(1)
+	safego.Go("ask", func() {
+		defer s.inflight.Add(-1)
+
 		// Ранний выход, если контекст уже отменён
 		select {
 		case <-ctx.Done():
@@ -714,12 +906,16 @@ func (s *Start) ask(userID uint32, respId, dialogID uint64, arrAsk []string, fil
 		//logger.Debug("📊 [MONITOR] TxCh начало: буфер=%d/%d (%.1f%%), respId=%d",
 		//	len(ch.TxCh), cap(ch.TxCh), float64(len(ch.TxCh))/float64(cap(ch.TxCh))*100.0, respId, userID)
 
-		streamErr := s.Mod.RequestStreaming(userID, dialogID, ask, func(delta string, done bool) error {
-			// Проверяем контекст в начале - если отменён, не обрабатываем дельту
-			select {
-			case <-ctx.Done():
+		streamErr := s.requestStreaming(ctx, userID, dialogID, ask, func(delta string, done bool) error {
+			// Проверяем контекст в начале - если отменён, не обрабатываем дельту.
+			// Финальный ответ провайдера, не поддерживающего отмену, принимается: клиенту он
+			// уже не уйдёт, но останется в истории диалога и в кэше повторов Router
+			if ctx.Err() != nil {
+				if done {
+					fullResponse = delta
+					return nil
+				}
 				return fmt.Errorf("context cancelled")
-			default:
 			}
 
 			if done {
@@ -766,6 +962,11 @@ func (s *Start) ask(userID uint32, respId, dialogID uint64, arrAsk []string, fil
 								eventType == "function_result" ||
 								eventType == "token_usage" {
 								isJSONEvent = true
+								if eventType == "token_usage" && s.experiments != nil {
+									if labeled := s.labelUsage(userID, dialogID, event); labeled != "" {
+										delta = labeled
+									}
+								}
 
 								// JSON события отправляем немедленно
 								deltaMsg := s.Mod.NewMessage(
@@ -790,8 +991,9 @@ func (s *Start) ask(userID uint32, respId, dialogID uint64, arrAsk []string, fil
 					}
 				}
 
-				// Обычные текстовые дельты - накапливаем в батч
-				if !isJSONEvent {
+				// Обычные текстовые дельты - накапливаем в батч.
+				// При модерации ответов текст уходит клиенту только после проверки
+				if !isJSONEvent && !s.moderatesOutput() {
 					deltaBatch.WriteString(delta)
 					batchCount++
 
@@ -807,19 +1009,17 @@ func (s *Start) ask(userID uint32, respId, dialogID uint64, arrAsk []string, fil
 						// НЕБЛОКИРУЮЩАЯ отправка с проверкой контекста
 						select {
 						case ch.TxCh <- deltaMsg:
-							// Успешно отправлено
+							// Очищаем буфер после отправки
+							deltaBatch.Reset()
+							batchCount = 0
 						case <-ctx.Done():
 							// Контекст отменён - прерываем обработку
 							//logger.Debug("ask: отправка батча прервана (context cancelled)", userID)
 							return fmt.Errorf("context cancelled")
 						default:
-							// Канал переполнен, пропускаем эту дельту (клиент увидит следующую)
-							//logger.Warn("ask: канал TxCh переполнен, пропущена дельта (len=%d)", deltaBatch.Len(), userID)
+							// Канал переполнен — батч не теряется, а уйдёт вместе со следующими дельтами
+							//logger.Warn("ask: канал TxCh переполнен, дельта отложена (len=%d)", deltaBatch.Len(), userID)
 						}
-
-						// Очищаем буфер после отправки
-						deltaBatch.Reset()
-						batchCount = 0
 					}
 				}
 			}
@@ -830,8 +1030,23 @@ func (s *Start) ask(userID uint32, respId, dialogID uint64, arrAsk []string, fil
 		//logger.Debug("📊 [MONITOR] TxCh финал: буфер=%d/%d (%.1f%%), всего дельт=%d, respId=%d",
 		//	len(ch.TxCh), cap(ch.TxCh), float64(len(ch.TxCh))/float64(cap(ch.TxCh))*100.0, deltaCounter, respId, userID)
 
+		if ctx.Err() != nil {
+			// ask уже вернул ошибку тайм-аута — результат только логируется
+			log := logger.FromContext(ctx)
+			switch {
+			case streamErr == nil && fullResponse != "":
+				log.Warn("ask: ответ модели получен после тайм-аута и не отправлен",
+					logger.UserID(userID), logger.DialogID(dialogID), "elapsed", time.Since(started))
+			case streamErr != nil:
+				log.Info("ask: запрос к модели прерван",
+					logger.UserID(userID), logger.DialogID(dialogID), "elapsed", time.Since(started), "err", streamErr)
+			}
+			return
+		}
+
 		if streamErr != nil {
-			//logger.Error("ask: ошибка запроса к модели, dialogID=%d: %v", dialogID, streamErr, userID)
+			logger.FromContext(ctx).Error("ask: ошибка запроса к модели",
+				logger.UserID(userID), logger.DialogID(dialogID), "err", streamErr)
 			select {
 			case errCh <- fmt.Errorf("ask error making request: %w", streamErr):
 			default:
@@ -851,7 +1066,12 @@ func (s *Start) ask(userID uint32, respId, dialogID uint64, arrAsk []string, fil
 		case answerCh <- body:
 		case <-ctx.Done():
 		}
-	}()
+	}, safego.WithUserID(userID), safego.WithOnPanic(func(rec any) {
+		select {
+		case errCh <- fmt.Errorf("ask: паника при запросе к модели: %v", rec):
+		default:
+		}
+	}))
 
 	// Жду либо ответа, либо ошибки, либо отмены/таймаута
 	select {
@@ -865,6 +1085,16 @@ func (s *Start) ask(userID uint32, respId, dialogID uint64, arrAsk []string, fil
 	}
 }
 
+// requestStreaming запрос к модели; отмена ctx прерывает его, если модель реализует
+// model.ContextStreamer (Router и все провайдеры)
+func (s *Start) requestStreaming(ctx context.Context, userID uint32, dialogID uint64, ask string,
+	onDelta func(delta string, done bool) error, files ...model.FileUpload) error {
+	if cs, ok := s.Mod.(model.ContextStreamer); ok {
+		return cs.RequestStreamingContext(ctx, userID, dialogID, ask, onDelta, files...)
+	}
+	return s.Mod.RequestStreaming(userID, dialogID, ask, onDelta, files...)
+}
+
 func (s *Start) Respondent(u *model.RespModel, questionCh chan Question, answerCh, fullQuestCh chan Answer,
 	respId, treadId uint64, errCh chan error) {
 	var (
@@ -878,15 +1108,156 @@ func (s *Start) Respondent(u *model.RespModel, questionCh chan Question, answerC
 		operatorErrorCh      <-chan string        // Канал для получения ошибок от операторского бэка
 		operatorTimeoutTimer *time.Timer          // Таймер для отслеживания таймаута ответа оператора
 		operatorTimeoutCh    chan struct{}        // Канал для сигнала о таймауте оператора
+		queued               *escalationWaiter    // Ожидание в очереди к оператору (nil — не в очереди)
+		operatorRetries      uint8                // Повторов ожидания оператора в текущей эскалации
+		ruleEscalation       bool                 // Правило эскалации сработало на вопросе текущего хода
+		ruleReply            string               // Готовый ответ правила reply на вопрос текущего хода
+		operatorSince        time.Time            // Начало операторского режима — для сводки модели после выхода
+		pending              []string             // Вопросы в буфере Endpoint, ещё не отправленные модели
+		resumeWait           time.Duration        // Остаток таймера батчинга восстановленных вопросов
 	)
 
+	// Ожидание в очереди снимается вместе с Respondent; запись в хранилище удаляется,
+	// только если завершился диалог, а не весь Start
+	defer func() {
+		if queued != nil {
+			s.leaveEscalationQueue(treadId, s.ctx.Err() == nil)
+		}
+	}()
+
+	// Сообщения, которые оператор отправил сам, без вопроса пользователя
+	operatorInbox := s.operatorInbox(treadId)
+	defer s.opInbox.CompareAndDelete(treadId, operatorInbox)
+
+	// Смена ассистента (SwitchAssistant): Respondent продолжает с копией u, чтобы
+	// не менять Assist, который читает Listener
+	switchInbox := s.assistantInbox(treadId)
+	defer s.switchInbox.CompareAndDelete(treadId, switchInbox)
+
+	// queueLane возвращает канал событий очереди к оператору (nil — диалог не в очереди)
+	queueLane := func() <-chan queueUpdate {
+		if queued != nil {
+			return queued.updates
+		}
+		return nil
+	}
+
+	// enqueue ставит вопрос в очередь к оператору и сообщает позицию.
+	// ok == false — очередь недоступна, нужно действовать без неё: ошибка хранилища
+	// очереди не завершает диалог, вопрос идёт обычным путём (таймаут оператора, AI)
+	enqueue := func(quest Question) (ok, shouldReturn bool) {
+		w, err := s.joinEscalationQueue(u, respId, treadId, quest)
+		if err != nil {
+			logger.ForDialog(u.Assist.UserID, treadId).Error("escalation: очередь к оператору недоступна", "err", err)
+			return false, false
+		}
+		queued = w
+		return true, !s.pushAnswer(answerCh, errCh, operatorSystemAnswer(queuePositionMessage(w.currentPosition())),
+			"канал answerCh закрыт при отправке позиции в очереди")
+	}
+
 	// Создаём канал для таймаута оператора
 	operatorTimeoutCh = make(chan struct{}, 1)
 
 	// Получаем канал ошибок сразу при запуске Respondent
-	operatorErrorCh = s.Oper.GetConnectionErrors(s.ctx, u.Assist.UserID, treadId)
+	if s.Oper != nil {
+		operatorErrorCh = s.Oper.GetConnectionErrors(s.ctx, u.Assist.UserID, treadId)
+	}
+
+	// operatorLane возвращает канал оператора, если режим включён (иначе nil — case не срабатывает)
+	operatorLane := func() <-chan model.Message {
+		if operatorMode && operatorRxCh != nil {
+			return operatorRxCh
+		}
+		return nil
+	}
+
+	// Разговор с оператором, прерванный перезапуском, продолжается без таймаута первого ответа.
+	// Изменения режима сохраняются в начале каждой итерации и при выходе из Respondent
+	if since, ok := s.restoreOperatorMode(u, treadId); ok {
+		operatorMode = true
+		operatorSince = since
+		operatorRxCh = s.Oper.ReceiveFromOperator(s.ctx, u.Assist.UserID, treadId)
+	}
+	operatorPersisted := operatorMode
+	syncOperatorMode := func() {
+		if operatorMode != operatorPersisted {
+			s.persistOperatorMode(u, respId, treadId, operatorMode, operatorSince)
+			operatorPersisted = operatorMode
+		}
+	}
+	defer syncOperatorMode()
+
+	// handleOperatorMsg доставляет сообщение оператора пользователю.
+	// Возвращает true если вызывающий должен выйти из Respondent.
+	handleOperatorMsg := func(operatorMsg model.Message) (shouldReturn bool) {
+		if operatorMsg.Type == "" {
+			return false // Пустое сообщение из nil канала
+		}
+
+		// Проверка на системное сообщение о выключении режима
+		if operatorMsg.Operator.SetOperator &&
+			operatorMsg.Operator.Operator &&
+			operatorMsg.Content.Message == setModeToAI {
+			//logger.Debug("Получено системное сообщение о выключении режима оператора")
+			operatorMode = false
+			s.injectOperatorSummary(u, treadId, operatorSince)
+			operatorSince = time.Time{}
+
+			// Удаляем сессию оператора
+			err := s.Oper.DeleteSession(u.Assist.UserID, treadId)
+			if err != nil {
+				s.sendError(errCh, fmt.Errorf("ошибка при удалении текущей сессии оператора: %v", err))
+			}
+
+			// Вызываем колбэк для корректного завершения сессии оператора
+			err = s.Bot.DisableOperatorMode(u.Assist.UserID, treadId)
+			if err != nil {
+				s.sendError(errCh, fmt.Errorf("ошибка при отключении режима оператора: %w", err))
+			}
+			return false
+		}
+
+		// Останавливаем таймер ожидания первого ответа оператора
+		// После первого ответа режим становится постоянным (без таймера)
+		operatorRetries = 0
+		if operatorTimeoutTimer != nil {
+			operatorTimeoutTimer = stopOperatorTimeoutTimer(operatorTimeoutTimer, operatorTimeoutCh)
+			//logger.Debug("Таймер оператора остановлен - режим теперь постоянный")
+		}
+
+		// Отправка ответа оператора пользователю
+		answ := Answer{
+			Answer:        operatorMsg.Content,
+			VoiceQuestion: false,
+			Operator:      operatorMsg.Operator,
+		}
+
+		return !s.pushAnswer(answerCh, errCh, answ, "канал answerCh закрыт или переполнен")
+	}
+
+	// Вопросы, накопленные до падения процесса, продолжают батчинг с оставшегося времени таймера
+	var resumeCh chan Question
+	if quest, wait, ok := s.resumePendingAsk(u, respId, treadId, time.Now()); ok {
+		resumeCh = make(chan Question, 1)
+		resumeCh <- quest
+		resumeWait = wait
+	}
 
 	for {
+		syncOperatorMode()
+
+		// Приоритетная полоса: уже пришедшие ответы оператора доставляются раньше,
+		// чем select случайно выберет очередной вопрос пользователя
+		select {
+		case operatorMsg := <-operatorLane():
+			if handleOperatorMsg(operatorMsg) {
+				return
+			}
+			continue
+		default:
+		}
+
 		select {
 		case <-s.ctx.Done():
 			//logger.Debug("Start context canceled in Respondent %s", u.RespName)
@@ -918,7 +1289,7 @@ func (s *Start) Respondent(u *model.RespModel, questionCh chan Question, answerC
 				if !s.pushAnswer(
 					answerCh,
 					errCh,
-					operatorSystemAnswer("🚫👨‍💻 Нет доступных операторов \n Продолжаю работу в режиме AI-агента 🧠"),
+					operatorSystemAnswer(NoOperatorsMessage),
 					"канал answerCh закрыт при отправке сообщения об ошибке tg_id",
 				) {
 					return
@@ -929,14 +1300,84 @@ func (s *Start) Respondent(u *model.RespModel, questionCh chan Question, answerC
 				continue
 			}
 
+		case assist := <-switchInbox:
+			next := *u
+			next.Assist = assist
+			u = &next
+			continue
+
+		// Оператор написал первым — включаем операторский режим без таймаута первого ответа
+		case opMsg := <-operatorInbox:
+			if opMsg.Content.Message == setModeToAI && opMsg.Operator.SetOperator { // ReturnToAI
+				if operatorMode && handleOperatorMsg(opMsg) {
+					return
+				}
+				continue
+			}
+			if !operatorMode {
+				operatorMode = true
+				operatorSince = time.Now()
+				operatorRxCh = s.Oper.ReceiveFromOperator(s.ctx, u.Assist.UserID, treadId)
+			}
+			operatorTimeoutTimer = stopOperatorTimeoutTimer(operatorTimeoutTimer, operatorTimeoutCh)
+			if queued != nil {
+				s.leaveEscalationQueue(treadId, true)
+				queued = nil
+			}
+			opMsg.Operator.SetOperator = true
+			if !s.pushAnswer(answerCh, errCh, Answer{Answer: opMsg.Content, Operator: opMsg.Operator},
+				"канал answerCh закрыт при отправке сообщения оператора") {
+				return
+			}
+			continue
+
+		// Очередь к оператору: новая позиция или оператор освободился
+		case upd := <-queueLane():
+			if upd.position > 0 {
+				s.trySendAnswer(answerCh, operatorSystemAnswer(queuePositionMessage(upd.position)))
+				continue
+			}
+			pending := queued.take()
+			queued = nil
+
+			// Дальше — обычный операторский режим с таймаутом первого ответа
+			operatorMode = true
+			operatorSince = time.Now()
+			operatorRxCh, operatorTimeoutTimer = s.startOperatorMode(u, treadId, operatorTimeoutCh)
+			if !s.pushAnswer(answerCh, errCh,
+				Answer{Answer: model.AssistResponse{Message: OperatorJoinedMessage}, Operator: model.Operator{SetOperator: true}},
+				"канал answerCh закрыт при подключении оператора из очереди") {
+				return
+			}
+			s.emit(u, respId, treadId, webhook.EventOperatorRequested, nil)
+			s.sendTranscriptToOperator(u, treadId)
+			for _, quest := range pending {
+				if err := s.sendQuestToOperator(u, treadId, quest, true); err != nil {
+					s.sendError(errCh, fmt.Errorf("ошибка отправки вопроса из очереди оператору: %v", err))
+				}
+			}
+			continue
+
 		// Обработка таймаута ожидания ответа оператора
 		case <-operatorTimeoutCh:
-			//logger.Warn("Таймаут ожидания ответа оператора (%d сек), переключение на AI режим",
-			//	mode.OperatorResponseTimeout)
-
 			// Останавливаем таймер
 			operatorTimeoutTimer = stopOperatorTimeoutTimer(operatorTimeoutTimer, operatorTimeoutCh)
 
+			// Политика ассистента: повторно напоминаем оператору о вопросе, прежде чем перейти к AI
+			if operatorRetries < u.Assist.Operator.Retries {
+				operatorRetries++
+				if len(currentQuest.Question) > 0 {
+					if err := s.sendQuestToOperator(u, treadId, currentQuest, true); err != nil {
+						s.sendError(errCh, fmt.Errorf("ошибка повторной отправки вопроса оператору: %v", err))
+					}
+				}
+				operatorTimeoutTimer = armOperatorTimeout(u, operatorTimeoutCh)
+				continue
+			}
+			//logger.Warn("Таймаут ожидания ответа оператора (%s, попыток %d), переключение на AI режим",
+			//	operatorTimeout(u), operatorRetries+1)
+			operatorRetries = 0
+
 			// Отключаем операторский режим
 			operatorMode = false
 			operatorRxCh = nil
@@ -952,7 +1393,7 @@ func (s *Start) Respondent(u *model.RespModel, questionCh chan Question, answerC
 			}
 
 			// Отправляем информационное сообщение пользователю о переключении на AI
-			s.trySendAnswer(answerCh, operatorSystemAnswer(operatorTimeoutMessage()))
+			s.trySendAnswer(answerCh, operatorSystemAnswer(operatorTimeoutMessage(u)))
 
 			// Если есть текущий вопрос без ответа, обрабатываем его через AI
 			if !deaf && currentQuest.Question != nil && len(currentQuest.Question) > 0 {
@@ -965,7 +1406,8 @@ func (s *Start) Respondent(u *model.RespModel, questionCh chan Question, answerC
 				answer, err := s.AskWithRetry(u.Assist.UserID, respId, treadId, userAsk, currentQuest.Files...)
 				if err != nil {
 					deaf = false
-					if s.handleAskFailure(u, err, answerCh, errCh, "критическая ошибка при обработке вопроса после таймаута оператора") {
+					if s.handleAskFailure(u, err, answerCh, errCh, "критическая ошибка при обработке вопроса после таймаута оператора",
+						failedTurn{respId: respId, dialogID: treadId, ask: userAsk, quest: currentQuest}) {
 						return
 					}
 				} else {
@@ -989,56 +1431,21 @@ func (s *Start) Respondent(u *model.RespModel, questionCh chan Question, answerC
 			continue
 
 		// Обработка сообщений от оператора (только если канал инициализирован)
-		case operatorMsg := <-func() <-chan model.Message {
-			if operatorMode && operatorRxCh != nil {
-				return operatorRxCh
-			}
-			return nil
-		}():
-			if operatorMsg.Type == "" {
-				continue // Пустое сообщение из nil канала
-			}
-
-			// Проверка на системное сообщение о выключении режима
-			if operatorMsg.Operator.SetOperator &&
-				operatorMsg.Operator.Operator &&
-				operatorMsg.Content.Message == "Set-Mode-To-AI" {
-				//logger.Debug("Получено системное сообщение о выключении режима оператора")
-				operatorMode = false
-
-				// Удаляем сессию оператора
-				err := s.Oper.DeleteSession(u.Assist.UserID, treadId)
-				if err != nil {
-					s.sendError(errCh, fmt.Errorf("ошибка при удалении текущей сессии оператора: %v", err))
-				}
-
-				// Вызываем колбэк для корректного завершения сессии оператора
-				err = s.Bot.DisableOperatorMode(u.Assist.UserID, treadId)
-				if err != nil {
-					s.sendError(errCh, fmt.Errorf("ошибка при отключении режима оператора: %w", err))
-				}
-				continue
-			}
-
-			// Останавливаем таймер ожидания первого ответа оператора
-			// После первого ответа режим становится постоянным (без таймера)
-			if operatorTimeoutTimer != nil {
-				operatorTimeoutTimer = stopOperatorTimeoutTimer(operatorTimeoutTimer, operatorTimeoutCh)
-				//logger.Debug("Таймер оператора остановлен - режим теперь постоянный")
-			}
-
-			// Отправка ответа оператора пользователю
-			answ := Answer{
-				Answer:        operatorMsg.Content,
-				VoiceQuestion: false,
-				Operator:      operatorMsg.Operator,
-			}
-
-			if !s.pushAnswer(answerCh, errCh, answ, "канал answerCh закрыт или переполнен") {
+		case operatorMsg := <-operatorLane():
+			if handleOperatorMsg(operatorMsg) {
 				return
 			}
 			continue // т.к. это операторское сообщение то сразу ждём следующее, а не спускаемся вниз по логике AI
 
+		case quest := <-resumeCh:
+			// Вопросы уже в буфере Endpoint: остаётся дождаться таймера батчинга
+			resumeCh = nil
+			currentQuest = quest
+			VoiceQuestion = quest.Voice
+			pending = quest.Question
+			safeStopTimer(askTimer)
+			askTimer = time.NewTimer(resumeWait)
+
 		case quest, open := <-questionCh:
 			if !open {
 				s.sendError(errCh, fmt.Errorf("канал questionCh закрыт"))
@@ -1046,6 +1453,7 @@ func (s *Start) Respondent(u *model.RespModel, questionCh chan Question, answerC
 			}
 
 			currentQuest = quest
+			logger.BindDialog(treadId, quest.TraceID)
 
 			// Если уже активен операторский режим — шлём сообщение оператору неблокирующе и не идём в AI
 			if operatorMode {
@@ -1056,13 +1464,43 @@ func (s *Start) Respondent(u *model.RespModel, questionCh chan Question, answerC
 				continue
 			}
 
+			// Операторов нет на связи — не ждём таймаута, вопрос сразу обрабатывает AI
+			if (quest.Operator.SetOperator || quest.Operator.Operator) && !s.operatorAvailable(u.Assist.UserID) {
+				if !s.noOperatorFallback(u, treadId, answerCh, errCh) {
+					return
+				}
+				quest.Operator = model.Operator{SenderName: quest.Operator.SenderName}
+				currentQuest = quest
+			}
+
+			// Операторы на связи, но все заняты — ждём в очереди, вопрос уйдёт оператору при продвижении
+			if quest.Operator.SetOperator && !operatorMode && s.needsQueue(u.Assist.UserID, queued) {
+				ok, shouldReturn := enqueue(quest)
+				if shouldReturn {
+					return
+				}
+				if ok {
+					safeStopTimer(askTimer)
+					select {
+					case fullQuestCh <- Answer{Answer: model.AssistResponse{Message: strings.Join(quest.Question, "\n")}, VoiceQuestion: quest.Voice}:
+					default:
+						s.sendError(errCh, fmt.Errorf("канал fullQuestCh закрыт или переполнен"))
+						return
+					}
+					continue
+				}
+			}
+
 			// Обработка SetOperator режима
 			if quest.Operator.SetOperator {
 				// Инициализация канала оператора при первом включении режима
 				if !operatorMode {
 					operatorMode = true
+					operatorSince = time.Now()
 					operatorRxCh, operatorTimeoutTimer = s.startOperatorMode(u, treadId, operatorTimeoutCh)
-					//logger.Debug("Включен операторский режим (таймаут: %d сек)", mode.OperatorResponseTimeout)
+					s.emit(u, respId, treadId, webhook.EventOperatorRequested, nil)
+					s.sendTranscriptToOperator(u, treadId)
+					//logger.Debug("Включен операторский режим (таймаут: %s)", operatorTimeout(u))
 				}
 
 				safeStopTimer(askTimer)
@@ -1072,31 +1510,21 @@ func (s *Start) Respondent(u *model.RespModel, questionCh chan Question, answerC
 				continue
 			}
 
-			// Проверка триггеров
-			if len(u.Assist.Metas.Triggers) > 0 {
-				userQuestion := strings.Join(quest.Question, "\n")
-				for _, trigger := range u.Assist.Metas.Triggers {
-					if strings.Contains(userQuestion, trigger) {
-						if err := s.End.Meta(u.Assist.UserID, treadId, "trigger", u.RespName, u.Assist.AssistName, u.Assist.Metas.MetaAction); err != nil {
-							s.sendError(errCh, fmt.Errorf("ошибка Meta триггер userID=%d dialogID=%d: %w", u.Assist.UserID, treadId, err))
-						}
-
-						//currentQuest.Operator.Operator = true
-						// Активация операторского режима при триггере
-						//if !operatorMode {
-						//	operatorMode = true
-						//	operatorRxCh = s.Inter.ReceiveFromOperator(s.ctx, u.Assist.UserID, treadId)
-						//	logger.Debug("Операторский режим активирован по триггеру для пользователя %d")
-						//}
-						//logger.Debug("'Respondent' триггер найден в вопросе пользователя, запрашиваю операторский режим")
-					}
-				}
+			// Правила эскалации по вопросу: действия выполняются сразу, эскалация — после батчинга
+			escalate, reply := s.checkTriggers(u, treadId, strings.Join(quest.Question, "\n"), errCh)
+			if escalate {
+				ruleEscalation = true
+			}
+			if reply != "" && ruleReply == "" {
+				ruleReply = reply
 			}
 
 			ask = strings.Join(quest.Question, "\n")
 			VoiceQuestion = quest.Voice
 
 			if s.End.SetUserAsk(treadId, respId, ask, u.Assist.Limit) {
+				pending = append(pending, ask)
+				s.savePendingAsk(u, respId, treadId, pending, VoiceQuestion)
 				askTimer = time.NewTimer(time.Duration(u.Assist.Espero) * time.Second)
 			} else {
 				if askTimer == nil {
@@ -1131,6 +1559,12 @@ func (s *Start) Respondent(u *model.RespModel, questionCh chan Question, answerC
 				}
 				//logger.Debug("User context canceled during inputLoop %s", u.RespName)
 				return
+			case operatorMsg := <-operatorLane():
+				// Ответ оператора не ждёт окончания батчинга вопросов для AI
+				if handleOperatorMsg(operatorMsg) {
+					askTimer.Stop()
+					return
+				}
 			case inputStruct, open := <-questionCh:
 				if !open {
 					askTimer.Stop()
@@ -1144,6 +1578,8 @@ func (s *Start) Respondent(u *model.RespModel, questionCh chan Question, answerC
 				ask = strings.Join(inputStruct.Question, "\n")
 				// Добавляю вопрос для контекста
 				if s.End.SetUserAsk(treadId, respId, ask, u.Assist.Limit) {
+					pending = append(pending, ask)
+					s.savePendingAsk(u, respId, treadId, pending, VoiceQuestion)
 					// Перезапускаю таймер
 					if !askTimer.Stop() {
 						<-askTimer.C // Сбрасываем любой оставшийся сигнал, чтобы избежать гонок
@@ -1169,6 +1605,10 @@ func (s *Start) Respondent(u *model.RespModel, questionCh chan Question, answerC
 
 		// Собираем batched вопрос
 		userAsk := s.End.GetUserAsk(treadId, respId)
+		if len(pending) > 0 {
+			s.dropPendingAsk(u.Assist.UserID, treadId)
+			pending = nil
+		}
 		if strings.TrimSpace(strings.Join(userAsk, "\n")) == "" {
 			// Пустой запрос, пропускаем
 			continue
@@ -1180,6 +1620,8 @@ func (s *Start) Respondent(u *model.RespModel, questionCh chan Question, answerC
 			},
 			VoiceQuestion: VoiceQuestion, // Передаём информацию о голосовом вопросе
 		}
+		fullAsk.Answer.Sentiment = s.turnSentiment(u.Assist.UserID, fullAsk.Answer.Message)
+		s.countTurn(treadId)
 
 		// Проверяю что канал fullQuestCh не закрыт
 		select {
@@ -1194,8 +1636,68 @@ func (s *Start) Respondent(u *model.RespModel, questionCh chan Question, answerC
 			err              error
 			operatorAnswered bool
 			setOperatorMode  bool
+			noOperators      bool   // эскалация модели не состоялась: операторов нет на связи
+			queueNotice      string // эскалация модели поставлена в очередь: позиция для пользователя
 		)
 
+		// Правило эскалации — вопрос сначала адресуется оператору, как при явном запросе
+		if ruleEscalation {
+			ruleEscalation = false
+			if !operatorMode {
+				currentQuest.Operator.Operator = true
+			}
+		}
+
+		// Готовый ответ правила или намерения вопроса, оператор или другой ассистент — до запроса к модели
+		var intentReply *model.AssistResponse
+		if ruleReply != "" {
+			intentReply = &model.AssistResponse{Message: ruleReply}
+			ruleReply = ""
+		} else if !operatorMode && !currentQuest.Operator.Operator {
+			if in := s.classifyIntent(u, treadId, fullAsk.Answer.Message, errCh); in != nil {
+				switch {
+				case in.Reply != nil:
+					intentReply = in.Reply
+				case in.Operator:
+					currentQuest.Operator.Operator = true
+				case in.Assistant != "":
+					if errSwitch := s.SwitchAssistant(treadId, in.Assistant); errSwitch != nil {
+						logger.ForDialog(u.Assist.UserID, treadId).Warn("intent: ассистент не сменён", "assistant", in.Assistant, "err", errSwitch)
+						break
+					}
+					select {
+					case assist := <-switchInbox:
+						next := *u
+						next.Assist = assist
+						u = &next
+					default:
+					}
+				}
+			}
+		}
+
+		// Операторский флаг пришёл с батчем вопросов, а операторов нет на связи
+		if currentQuest.Operator.Operator && !operatorMode && !s.operatorAvailable(u.Assist.UserID) {
+			if !s.noOperatorFallback(u, treadId, answerCh, errCh) {
+				return
+			}
+			currentQuest.Operator.Operator = false
+		}
+
+		// Все операторы заняты — встаём в очередь, а пока отвечает AI
+		if currentQuest.Operator.Operator && !operatorMode && s.needsQueue(u.Assist.UserID, queued) {
+			quest := currentQuest
+			quest.Question, quest.Voice = userAsk, VoiceQuestion
+			if w, errQueue := s.joinEscalationQueue(u, respId, treadId, quest); errQueue == nil {
+				queued = w
+				currentQuest.Operator.Operator = false
+				queueNotice = queuePositionMessage(w.currentPosition())
+			} else {
+				// Без очереди запрос идёт обычным путём, диалог не прерывается
+				logger.ForDialog(u.Assist.UserID, treadId).Error("escalation: очередь к оператору недоступна", "err", errQueue)
+			}
+		}
+
 		// Операторский запрос (явный), без SetOperator — сначала пробуем синхронно спросить оператора
 		if currentQuest.Operator.Operator {
 			// Если вопрос помечен как операторский но операторский режим ещё не включён,
@@ -1207,6 +1709,10 @@ func (s *Start) Respondent(u *model.RespModel, questionCh chan Question, answerC
 			content := model.AssistResponse{Message: strings.Join(userAsk, "\n")}
 			name := u.Assist.AssistName
 			opMsg := s.Mod.NewMessage(model.Operator{Operator: true, SenderName: currentQuest.Operator.SenderName}, msgType, &content, &name, currentQuest.Files...)
+			if !operatorMode {
+				s.emit(u, respId, treadId, webhook.EventOperatorRequested, nil)
+				s.sendTranscriptToOperator(u, treadId)
+			}
 
 			var respMsg model.Message
 			respMsg, err = s.Oper.AskOperator(s.ctx, u.Assist.UserID, treadId, opMsg)
@@ -1217,7 +1723,8 @@ func (s *Start) Respondent(u *model.RespModel, questionCh chan Question, answerC
 				answer, err = s.AskWithRetry(u.Assist.UserID, respId, treadId, userAsk, currentQuest.Files...)
 				if err != nil {
 					deaf = false
-					if s.handleAskFailure(u, err, answerCh, errCh, fmt.Sprintf("критическая ошибка для пользователя %d", u.Assist.UserID)) {
+					if s.handleAskFailure(u, err, answerCh, errCh, fmt.Sprintf("критическая ошибка для пользователя %d", u.Assist.UserID),
+						failedTurn{respId: respId, dialogID: treadId, ask: userAsk, quest: currentQuest}) {
 						return
 					}
 					continue
@@ -1232,8 +1739,9 @@ func (s *Start) Respondent(u *model.RespModel, questionCh chan Question, answerC
 				// Включаем постоянный режим после успешного ответа оператора
 				if !operatorMode {
 					operatorMode = true
+					operatorSince = time.Now()
 					operatorRxCh, operatorTimeoutTimer = s.startOperatorMode(u, treadId, operatorTimeoutCh)
-					//logger.Debug("Операторский режим активирован после ответа оператора (таймаут: %d сек)", mode.OperatorResponseTimeout)
+					//logger.Debug("Операторский режим активирован после ответа оператора (таймаут: %s)", operatorTimeout(u))
 				} else if operatorTimeoutTimer != nil {
 					// Оператор ответил - останавливаем таймер навсегда
 					// Режим становится постоянным
@@ -1242,24 +1750,59 @@ func (s *Start) Respondent(u *model.RespModel, questionCh chan Question, answerC
 				}
 			}
 
+		} else if intentReply != nil {
+			answer = *intentReply
 		} else {
 			// Отправляю запрос в OpenAI
 			answer, err = s.AskWithRetry(u.Assist.UserID, respId, treadId, userAsk, currentQuest.Files...)
 			if err != nil {
 				deaf = false
-				if s.handleAskFailure(u, err, answerCh, errCh, fmt.Sprintf("критическая ошибка для пользователя %d", u.Assist.UserID)) {
+				if s.handleAskFailure(u, err, answerCh, errCh, fmt.Sprintf("критическая ошибка для пользователя %d", u.Assist.UserID),
+					failedTurn{respId: respId, dialogID: treadId, ask: userAsk, quest: currentQuest}) {
 					return
 				}
 				continue
 			}
 
+			// Правила по ответу модели (например, низкая уверенность) могут запросить оператора
+			if !answer.Operator {
+				escalate, reply := s.checkAnswerRules(u, treadId, strings.Join(userAsk, "\n"), answer, errCh)
+				if reply != "" {
+					answer = model.AssistResponse{Message: reply}
+				}
+				answer.Operator = escalate
+			}
+
+			// Модель запросила оператора, но никого нет на связи — отвечает AI, режим не включаем
+			if answer.Operator && !operatorMode && !s.operatorAvailable(u.Assist.UserID) {
+				answer.Operator = false
+				noOperators = true
+			}
+
+			// Модель запросила оператора, а все заняты — в очередь, позиция сообщается после ответа
+			if answer.Operator && !operatorMode && s.needsQueue(u.Assist.UserID, queued) {
+				quest := currentQuest
+				quest.Question, quest.Voice = userAsk, VoiceQuestion
+				if w, errQueue := s.joinEscalationQueue(u, respId, treadId, quest); errQueue == nil {
+					queued = w
+					answer.Operator = false
+					queueNotice = queuePositionMessage(w.currentPosition())
+				} else {
+					// Без очереди запрос идёт обычным путём, диалог не прерывается
+					logger.ForDialog(u.Assist.UserID, treadId).Error("escalation: очередь к оператору недоступна", "err", errQueue)
+				}
+			}
+
 			// Пришёл ответ от модели, проверяю на флаг запроса операторского режима
 			if answer.Operator {
 				// Модель запросила эскалацию к оператору
 				if !operatorMode {
 					operatorMode = true
+					operatorSince = time.Now()
 					operatorRxCh, operatorTimeoutTimer = s.startOperatorMode(u, treadId, operatorTimeoutCh)
-					s.End.SendEvent(u.Assist.UserID, "model-operator", u.RespName, u.Assist.AssistName, "")
+					s.sendEvent(u.Assist.UserID, "model-operator", u.RespName, u.Assist.AssistName, "")
+					s.emit(u, respId, treadId, webhook.EventOperatorRequested, nil)
+					s.sendTranscriptToOperator(u, treadId)
 					//logger.Debug("Операторский режим активирован по флагу ответа модели")
 				}
 
@@ -1311,22 +1854,17 @@ func (s *Start) Respondent(u *model.RespModel, questionCh chan Question, answerC
 
 		// Если пустой ответ
 		if answer.Message == "" && len(answer.Action.SendFiles) == 0 {
+			if noOperators && !s.noOperatorFallback(u, treadId, answerCh, errCh) {
+				return
+			}
+			if queueNotice != "" {
+				s.trySendAnswer(answerCh, operatorSystemAnswer(queueNotice))
+			}
 			continue
 		}
 
 		// Проверяю на содержание в ответе цели из u.Assist.Metas.MetaAction
-		if u.Assist.Metas.MetaAction != "" {
-			if answer.Meta { // Ассистент пометил ответ как достигший цели
-				if err := s.End.Meta(u.Assist.UserID, treadId, "target", u.RespName, u.Assist.AssistName, u.Assist.Metas.MetaAction); err != nil {
-					s.sendError(errCh, fmt.Errorf("ошибка Meta цель userID=%d dialogID=%d: %w", u.Assist.UserID, treadId, err))
-				}
-			}
-
-			// Только для Lead Hunter достижение цели с передачей контакта
-			if err := s.End.CallOptional(int64(respId)); err != nil {
-				//logger.Error("ошибка вызова CallOptional для respId %d: %v", respId, err)
-			}
-		}
+		s.checkTarget(u, respId, treadId, answer, errCh)
 
 		// Отправляем ответ вызывающей функции
 		answ := Answer{
@@ -1341,11 +1879,20 @@ func (s *Start) Respondent(u *model.RespModel, questionCh chan Question, answerC
 		select {
 		case answerCh <- answ:
 		default:
+			errFull := fmt.Errorf("канал answerCh закрыт или переполнен")
+			s.deadLetter(u, failedTurn{respId: respId, dialogID: treadId, ask: userAsk, quest: currentQuest}, DeadLetterUndelivered, errFull)
 			select {
-			case errCh <- fmt.Errorf("канал answerCh закрыт или переполнен"):
+			case errCh <- errFull:
 			default:
 			}
 		}
+
+		if noOperators && !s.noOperatorFallback(u, treadId, answerCh, errCh) {
+			return
+		}
+		if queueNotice != "" {
+			s.trySendAnswer(answerCh, operatorSystemAnswer(queueNotice))
+		}
 	}
 }
 
@@ -1366,7 +1913,7 @@ func (s *Start) StarterRespondent(
 		wg.Add(1)
 		s.respondentWG.Store(treadId, wg)
 
-		go func() {
+		safego.Go("Respondent", func() {
 			defer func() {
 				u.Services.Respondent.Store(false)
 				wg.Done()
@@ -1383,7 +1930,10 @@ func (s *Start) StarterRespondent(
 
 			s.Respondent(u, questionCh, answerCh, fullQuestCh, respId, treadId, errCh)
 			//logger.Debug("StarterRespondent: s.Respondent завершился для respId=%d", respId)
-		}()
+		}, safego.WithUserID(u.Assist.UserID), safego.WithOnPanic(func(rec any) {
+			// Listener без Respondent бесполезен — завершаем его через errCh
+			s.sendError(errCh, fmt.Errorf("паника в Respondent dialogID=%d: %v", treadId, rec))
+		}))
 	}
 }
 
@@ -1402,61 +1952,143 @@ func (s *Start) StarterListener(start model.StartCh, errCh chan<- error) {
 
 	if !start.Model.Services.Listener.Load() {
 		start.Model.Services.Listener.Store(true)
-		go func() {
-			defer func() {
-				start.Model.Services.Listener.Store(false)
-				//logger.Debug("[%s] StarterListener: Listener завершен для respId=%d", start.Provider, start.RespId, start.Model.Assist.UserID)
-			}()
-			// - родительского s.ctx (общий контекст Start)
-			// - или контекста бота start.Ctx
-			listenerCtx, listenerCancel := context.WithCancel(s.ctx)
-			defer listenerCancel()
+		s.emit(start.Model, start.RespId, start.TreadId, webhook.EventDialogStarted, nil)
+		s.labelDialogStart(start.Model.Assist.UserID, start.TreadId, start.Model.RespName, start.Model.Assist.AssistName)
+		if s.pool != nil {
+			// Режим пула: диалог обслуживают общие воркеры, отдельные горутины не создаются
+			s.pool.register(start, errCh)
+			return
+		}
+		s.runListener(start, errCh)
+	} else {
+		//logger.Debug("[%s] StarterListener: Listener уже запущен для respId=%d", start.Provider, start.RespId, start.Model.Assist.UserID)
+	}
+}
 
-			// Связываем с контекстом бота
-			go func() {
-				select {
-				case <-start.Ctx.Done():
-					listenerCancel()
-				case <-listenerCtx.Done():
-				}
-			}()
+// runListener запускает выделенный Listener диалога.
+// pending — сообщения, уже прочитанные из RxCh (передача диалога из пула воркеров).
+func (s *Start) runListener(start model.StartCh, errCh chan<- error, pending ...model.Message) {
+	safego.Go("Listener", func() {
+		defer func() {
+			start.Model.Services.Listener.Store(false)
+			s.emit(start.Model, start.RespId, start.TreadId, webhook.EventDialogEnded, nil)
+			//logger.Debug("[%s] StarterListener: Listener завершен для respId=%d", start.Provider, start.RespId, start.Model.Assist.UserID)
+		}()
+		// - родительского s.ctx (общий контекст Start)
+		// - или контекста бота start.Ctx
+		listenerCtx, listenerCancel := context.WithCancel(s.ctx)
+		defer listenerCancel()
 
-			// Если контекст бота уже отменён — не запускаем Listener
+		// Связываем с контекстом бота
+		safego.Go("Listener.ctx", func() {
 			select {
 			case <-start.Ctx.Done():
-				//logger.Debug("[%s] StarterListener отменён по контексту бота %s", start.Provider, start.Model.RespName, start.Model.Assist.UserID)
-				return
-			default:
+				listenerCancel()
+			case <-listenerCtx.Done():
 			}
+		})
 
-			if err := s.Listener(start.Model, start.Chanel, start.RespId, start.TreadId); err != nil {
-				//logger.Error("[%s] StarterListener: ошибка в Listener для respId=%d: %v", start.Provider, start.RespId, err, start.Model.Assist.UserID)
-				select {
-				case errCh <- err: // Отправляем ошибку в App
-				default:
-					//logger.Warn("[%s] Не удалось отправить ошибку в errCh: %v", start.Provider, err, start.Model.Assist.UserID)
-				}
+		// Если контекст бота уже отменён — не запускаем Listener
+		select {
+		case <-start.Ctx.Done():
+			//logger.Debug("[%s] StarterListener отменён по контексту бота %s", start.Provider, start.Model.RespName, start.Model.Assist.UserID)
+			return
+		default:
+		}
+
+		if err := s.listen(start.Model, start.Chanel, start.RespId, start.TreadId, pending...); err != nil {
+			s.emitError(start.Model, start.RespId, start.TreadId, err)
+			//logger.Error("[%s] StarterListener: ошибка в Listener для respId=%d: %v", start.Provider, start.RespId, err, start.Model.Assist.UserID)
+			select {
+			case errCh <- err: // Отправляем ошибку в App
+			default:
+				//logger.Warn("[%s] Не удалось отправить ошибку в errCh: %v", start.Provider, err, start.Model.Assist.UserID)
 			}
-		}()
-	} else {
-		//logger.Debug("[%s] StarterListener: Listener уже запущен для respId=%d", start.Provider, start.RespId, start.Model.Assist.UserID)
-	}
+		}
+	}, safego.WithUserID(start.Model.Assist.UserID), safego.WithOnPanic(func(rec any) {
+		select {
+		case errCh <- fmt.Errorf("паника в Listener respId=%d: %v", start.RespId, rec):
+		default:
+		}
+	}))
 }
 
 // saveTask — задание для воркера сохранения диалога.
 // Использование единственной горутины-воркера гарантирует порядок записей:
 // вопрос пользователя всегда сохраняется раньше ответа модели.
+// startSaveWorker запускает воркер, сохраняющий задачи saveCh до его закрытия.
+// При панике в SaveDialog воркер перезапускается, чтобы не блокировать saveCh; счётчик
+// saveWorkers уменьшается только по закрытию saveCh, поэтому перезапуск его не трогает
+func (s *Start) startSaveWorker(userID uint32, saveCh <-chan saveTask) {
+	s.saveWorkers.Add(1)
+	safego.Go("Listener.save", func() {
+		for t := range saveCh {
+			s.End.SaveDialog(t.creator, t.treadId, &t.resp)
+		}
+		s.saveWorkers.Add(-1)
+	}, safego.WithUserID(userID), safego.WithRestart(-1, 0))
+}
+
 type saveTask struct {
 	creator comdb.CreatorType
 	treadId uint64
 	resp    model.AssistResponse
 }
 
+// admit отсеивает дубликаты и сообщения сверх лимита.
+// Превышен лимит — сообщение не ставится в очередь, один раз за окно пользователю вежливо отвечаем.
+func (s *Start) admit(u *model.RespModel, usrCh *model.Ch, treadId uint64, msg model.Message) bool {
+	// Повторная доставка того же сообщения (Telegram redelivery) — отбрасываем до Respondent
+	if s.dedup.duplicate(treadId, msg, time.Now()) {
+		//logger.Debug("Listener: дубликат сообщения отброшен dialogID=%d", treadId, u.Assist.UserID)
+		return false
+	}
+	if ok, notify := s.limiter.allow(u.Assist.UserID, treadId, time.Now()); !ok {
+		if notify {
+			s.politeReply(u, usrCh)
+		}
+		return false
+	}
+	return true
+}
+
+// politeReply просит пользователя подождать
+func (s *Start) politeReply(u *model.RespModel, usrCh *model.Ch) {
+	content := model.AssistResponse{Message: RateLimitMessage}
+	_ = usrCh.SendToTx(s.Mod.NewMessage(model.Operator{}, "assist", &content, &u.Assist.AssistName))
+}
+
+// newQuestion создаёт вопрос из входящего сообщения пользователя
+func newQuestion(msg model.Message) (Question, error) {
+	var voice bool
+	switch msg.Type {
+	case "user":
+		voice = false // Сообщение от пользователя не голосовое
+	case "user_voice":
+		voice = true // Сообщение от пользователя голосовое
+	default:
+		return Question{}, fmt.Errorf("неизвестный тип сообщения: %s", msg.Type)
+	}
+	return Question{
+		Question: strings.Split(msg.Content.Message, "\n"),
+		Voice:    voice,
+		Files:    msg.Files,    // Файлы, прикрепленные к вопросу
+		Operator: msg.Operator, // Помечаем оператором при триггере или если уже отмечено
+		TraceID:  logger.NewTraceID(),
+	}, nil
+}
+
 // Listener слушает канал от пользователя и обрабатывает сообщения
 func (s *Start) Listener(u *model.RespModel, usrCh *model.Ch, respId uint64, treadId uint64) error {
+	return s.listen(u, usrCh, respId, treadId)
+}
+
+// listen — Listener, который сначала обрабатывает pending-сообщения
+func (s *Start) listen(u *model.RespModel, usrCh *model.Ch, respId uint64, treadId uint64, pending ...model.Message) error {
 	// Сохраняем provider для этого respId (берем из StartCh через responderProviders)
 	// Defer удалит его при завершении Listener
 	defer s.responderProviders.Delete(respId)
+	defer logger.UnbindDialog(treadId)
 
 	question := make(chan Question, create.RxChanBuffer)
 	fullQuestCh := make(chan Answer, create.RxChanBuffer)
@@ -1480,10 +2112,10 @@ func (s *Start) Listener(u *model.RespModel, usrCh *model.Ch, respId uint64, tre
 
 			// Ждем с таймаутом
 			done := make(chan struct{})
-			go func() {
+			safego.Go("Listener.wait", func() {
 				wg.Wait()
 				close(done)
-			}()
+			})
 
 			select {
 			case <-done:
@@ -1493,6 +2125,9 @@ func (s *Start) Listener(u *model.RespModel, usrCh *model.Ch, respId uint64, tre
 			}
 		}
 
+		// Сохраняем то, что Respondent успел отдать, но Listener не прочитал
+		s.flushPending(treadId, fullQuestCh, answerCh, saveCh)
+
 		close(question)
 		close(fullQuestCh)
 		close(answerCh)
@@ -1502,13 +2137,17 @@ func (s *Start) Listener(u *model.RespModel, usrCh *model.Ch, respId uint64, tre
 		close(saveCh)
 	}()
 
+	// Отложенные сообщения идут тем же путём, что и ответы модели
+	s.registerActive(u, respId, treadId, answerChDeliverer(answerCh))
+	defer s.active.Delete(treadId)
+
+	// Напоминание при молчании пользователя (если задано правило ассистента)
+	follow := s.newFollowUp(u, respId, treadId, answerChDeliverer(answerCh))
+	defer follow.stop()
+
 	// Запускаем воркер сохранения диалога.
 	// Единственная горутина обеспечивает строгий порядок: вопрос всегда перед ответом.
-	go func() {
-		for t := range saveCh {
-			s.End.SaveDialog(t.creator, t.treadId, &t.resp)
-		}
-	}()
+	s.startSaveWorker(u.Assist.UserID, saveCh)
 
 	// Передаем контекст listener в модель пользователя
 	userCtx, userCancel := context.WithCancel(listenerCtx)
@@ -1517,7 +2156,60 @@ func (s *Start) Listener(u *model.RespModel, usrCh *model.Ch, respId uint64, tre
 	// Обновляем контекст в модели пользователя
 	u.Ctx = userCtx
 
-	go s.StarterRespondent(u, question, answerCh, fullQuestCh, respId, treadId, errCh)
+	s.StarterRespondent(u, question, answerCh, fullQuestCh, respId, treadId, errCh)
+
+	// incoming передаёт сообщение пользователя в Respondent и дублирует его клиенту
+	incoming := func(msg model.Message) error {
+		if s.captureRating(u.Assist.UserID, treadId, msg) {
+			return nil
+		}
+		if !s.admit(u, usrCh, treadId, msg) {
+			return nil
+		}
+		follow.userActive()
+
+		s.applySeed(u, treadId, msg)
+		quest, err := newQuestion(msg)
+		if err != nil {
+			// Неизвестный тип сообщения, пропускаю
+			//logger.Warn("Listener: неизвестный тип=%s", msg.Type)
+			s.sendError(errCh, err)
+			return nil
+		}
+
+		// Защита от паники при отправке в questionCh
+		select {
+		case question <- quest:
+			// Успешно отправлено в очередь
+		case <-s.ctx.Done():
+			//logger.Debug("Контекст отменен при отправке в questionCh")
+			return fmt.Errorf("контекст отменен")
+		case <-time.After(create.ChanSendTimeout):
+			// Respondent не успевает — сообщаем пользователю вместо тихой потери вопроса
+			// НЕ завершаем Listener — продолжаем работу
+			s.politeReply(u, usrCh)
+			return nil
+		}
+
+		// Отправляю вопрос клиента в виде сообщения
+		userMsg := s.Mod.NewMessage(msg.Operator, "user", &msg.Content, &msg.Name)
+
+		if err := usrCh.SendToTx(userMsg); err != nil {
+			select {
+			case errCh <- fmt.Errorf("ошибка при отправке в канал TxCh: %v", err.Error()):
+			default:
+				//logger.Warn("Ошибка отправки ответа в TxCh для dialogID %d: %v", treadId, err)
+			}
+		}
+		return nil
+	}
+
+	// Сообщения, полученные до запуска Listener (передача диалога из пула воркеров)
+	for _, msg := range pending {
+		if err := incoming(msg); err != nil {
+			return err
+		}
+	}
 
 	for {
 		select {
@@ -1525,10 +2217,15 @@ func (s *Start) Listener(u *model.RespModel, usrCh *model.Ch, respId uint64, tre
 			//logger.Debug("Start context отменён в Listener %s", u.RespName)
 			return nil
 		case err := <-errCh:
-			//logger.Error("Listener: получена ошибка из errCh: %v", err)
+			logger.ForDialog(u.Assist.UserID, treadId).Error("Listener: получена ошибка из errCh", "err", err)
 			return err // Возвращаем возможные ошибки
 		case <-u.Ctx.Done():
 			//logger.Debug("Context.Done Listener %s", u.RespName)
+			if ttlExpired(u) {
+				s.requestCSAT(u, respId, treadId, CSATOnTTL, func(survey model.AssistResponse) bool {
+					return s.sendAssist(usrCh, model.Operator{}, survey, &u.Assist.AssistName) == nil
+				})
+			}
 			return nil
 		case msg, ok := <-usrCh.RxCh:
 			if !ok {
@@ -1536,53 +2233,8 @@ func (s *Start) Listener(u *model.RespModel, usrCh *model.Ch, respId uint64, tre
 				return nil
 			}
 
-			// Создаю вопрос
-			var quest Question
-
-			switch msg.Type {
-			case "user":
-				quest = Question{
-					Question: strings.Split(msg.Content.Message, "\n"),
-					Voice:    false,        // Сообщение от пользователя не голосовое
-					Files:    msg.Files,    // Файлы, прикрепленные к вопросу
-					Operator: msg.Operator, // Помечаем оператором при триггере или если уже отмечено
-				}
-			case "user_voice":
-				quest = Question{
-					Question: strings.Split(msg.Content.Message, "\n"),
-					Voice:    true,         // Сообщение от пользователя голосовое
-					Files:    msg.Files,    // Файлы, прикрепленные к вопросу
-					Operator: msg.Operator, // Помечаем оператором при триггере или если уже отмечено
-				}
-			default:
-				// Неизвестный тип сообщения, пропускаю
-				//logger.Warn("Listener: неизвестный тип=%s", msg.Type)
-				s.sendError(errCh, fmt.Errorf("неизвестный тип сообщения: %s", msg.Type))
-				continue
-			}
-
-			// Защита от паники при отправке в questionCh
-			select {
-			case question <- quest:
-				// Успешно отправлено в очередь
-			case <-s.ctx.Done():
-				//logger.Debug("Контекст отменен при отправке в questionCh")
-				return fmt.Errorf("контекст отменен")
-			case <-time.After(500 * time.Millisecond):
-				// Редкий случай переполнения — тихо пропускаем
-				// НЕ завершаем Listener — продолжаем работу
-			}
-
-			// Отправляю вопрос клиента в виде сообщения
-			userMsg := s.Mod.NewMessage(msg.Operator, "user", &msg.Content, &msg.Name)
-
-			if err := usrCh.SendToTx(userMsg); err != nil {
-				select {
-				case errCh <- fmt.Errorf("ошибка при отправке в канал TxCh: %v", err.Error()):
-				default:
-					//logger.Warn("Ошибка отправки ответа в TxCh для dialogID %d: %v", treadId, err)
-				}
-				continue
+			if err := incoming(msg); err != nil {
+				return err
 			}
 
 		case quest := <-fullQuestCh: // Пришёл полный вопрос пользователя
@@ -1591,16 +2243,15 @@ func (s *Start) Listener(u *model.RespModel, usrCh *model.Ch, respId uint64, tre
 			if quest.VoiceQuestion {
 				creator = comdb.UserVoice
 			}
-			select {
-			case saveCh <- saveTask{creator: creator, treadId: treadId, resp: quest.Answer}:
-			default:
+			// Ждём воркер сохранения не дольше ChanSendTimeout, чтобы всплеск не терял историю
+			if err := model.SendTimeout(listenerCtx, saveCh, saveTask{creator: creator, treadId: treadId, resp: quest.Answer}, create.ChanSendTimeout); err != nil {
 				//logger.Warn("saveCh переполнен, вопрос пользователя не сохранён для dialogID %d", treadId)
 			}
 		case resp := <-answerCh: // Пришёл ответ ассистента/оператора
-			assistMsg := s.Mod.NewMessage(resp.Operator, "assist", &resp.Answer, &u.Assist.AssistName)
+			resp.Answer.Action.SendFiles = model.CorrectFileTypes(s.ctx, resp.Answer.Action.SendFiles)
 
 			// Безопасная отправка ответа в TxCh
-			if err := usrCh.SendToTx(assistMsg); err != nil {
+			if err := s.sendAssist(usrCh, resp.Operator, resp.Answer, &u.Assist.AssistName); err != nil {
 				select {
 				case errCh <- fmt.Errorf("ошибка при отправке в канал TxCh: %v", err.Error()):
 				default:
@@ -1617,15 +2268,15 @@ func (s *Start) Listener(u *model.RespModel, usrCh *model.Ch, respId uint64, tre
 				}
 				continue
 			}
+			follow.assistantSent(resp.Operator)
 
 			// Сохраняем через воркер — строго после вопроса (fullQuestCh был отправлен раньше)
 			creator := comdb.AI
 			if resp.Operator.Operator {
 				creator = comdb.Operator
 			}
-			select {
-			case saveCh <- saveTask{creator: creator, treadId: treadId, resp: resp.Answer}:
-			default:
+			// Ждём воркер сохранения не дольше ChanSendTimeout, чтобы всплеск не терял историю
+			if err := model.SendTimeout(listenerCtx, saveCh, saveTask{creator: creator, treadId: treadId, resp: resp.Answer}, create.ChanSendTimeout); err != nil {
 				//logger.Warn("saveCh переполнен, ответ ассистента не сохранён для dialogID %d", treadId)
 			}
 		}
@@ -1,9 +1,13 @@
 package startpoint
 
 import (
+	"context"
 	"testing"
+	"time"
 
+	"github.com/ikermy/AiR_Common/pkg/mode"
 	"github.com/ikermy/AiR_Common/pkg/model"
+	"github.com/ikermy/AiR_Common/pkg/model/create"
 )
 
 func TestExtractStreamText_PartialMessage(t *testing.T) {
@@ -135,3 +139,114 @@ func TestStart_ProcessStreamDelta_FunctionCallArgumentsDelta(t *testing.T) {
 		t.Fatalf("unexpected delta arguments: %q", result.Arguments)
 	}
 }
+
+func TestOperatorTimeoutMessage_PerAssistant(t *testing.T) {
+	u := &model.RespModel{Assist: model.Assistant{Operator: create.OperatorConfig{Timeout: 30, Retries: 3}}}
+	if got := operatorTimeout(u); got != 30*time.Second {
+		t.Fatalf("unexpected timeout: %s", got)
+	}
+	if got, want := operatorTimeoutMessage(u), "⏱️ Оператор не ответил в течение 2 мин\nПродолжаю работу в режиме AI-агента 🧠"; got != want {
+		t.Fatalf("unexpected message: %q", got)
+	}
+	if got := operatorTimeout(&model.RespModel{}); got != time.Duration(mode.OperatorResponseTimeout)*time.Second {
+		t.Fatalf("expected mode default, got %s", got)
+	}
+}
+
+func TestRespondent_RetriesOperatorBeforeAI(t *testing.T) {
+	defer func(v bool) { mode.TestAnswer = v }(mode.TestAnswer)
+	mode.TestAnswer = true
+
+	oper := &busyOperator{}
+	oper.free.Store(true)
+	bot := &silentBot{}
+	s := New(context.Background(), &chModel{}, &memEndpoint{}, bot, oper)
+	defer s.cancel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	u := &model.RespModel{Ctx: ctx, Assist: model.Assistant{UserID: 1, Operator: create.OperatorConfig{Timeout: 1, Retries: 1}}}
+	questionCh, answerCh := make(chan Question, 1), make(chan Answer, 4)
+	go s.Respondent(u, questionCh, answerCh, make(chan Answer, 4), 1, 1, make(chan error, 4))
+
+	started := time.Now()
+	questionCh <- Question{Question: []string{"нужен человек"}, Operator: model.Operator{SetOperator: true}}
+
+	select {
+	case ans := <-answerCh:
+		if ans.Answer.Message != operatorTimeoutMessage(u) {
+			t.Fatalf("unexpected answer: %q", ans.Answer.Message)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no fallback to AI")
+	}
+	if waited := time.Since(started); waited < 2*time.Second {
+		t.Fatalf("fallback before retry: waited %s", waited)
+	}
+	oper.mu.Lock()
+	sent := len(oper.sent)
+	oper.mu.Unlock()
+	if sent != 2 {
+		t.Fatalf("expected question and one retry sent to operator, got %d", sent)
+	}
+	if bot.disabled.Load() != 1 {
+		t.Fatalf("operator mode must be disabled in bot")
+	}
+
+	// Вопрос без ответа оператора обрабатывает модель
+	select {
+	case ans := <-answerCh:
+		if ans.Operator.Operator {
+			t.Fatalf("expected AI answer, got %+v", ans)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("no AI answer after operator timeout")
+	}
+}
+
+// cancelModel — модель, запрос которой ждёт отмены контекста
+type cancelModel struct {
+	chModel
+	started   chan struct{}
+	cancelled chan struct{}
+}
+
+func (c *cancelModel) RequestStreamingContext(ctx context.Context, _ uint32, _ uint64, _ string,
+	_ func(delta string, done bool) error, _ ...model.FileUpload) error {
+	close(c.started)
+	<-ctx.Done()
+	close(c.cancelled)
+	return ctx.Err()
+}
+
+func TestAsk_CancelsProviderRequest(t *testing.T) {
+	ch := &model.Ch{TxCh: make(chan model.Message, 10), RxCh: make(chan model.Message, 1)}
+	m := &cancelModel{chModel: chModel{ch: ch}, started: make(chan struct{}), cancelled: make(chan struct{})}
+	ctx, cancel := context.WithCancel(context.Background())
+	s := New(ctx, m, nil, nil, nil)
+
+	errCh := make(chan error, 1)
+	go func() {
+		_, err := s.ask(1, 2, 3, []string{"вопрос"})
+		errCh <- err
+	}()
+
+	<-m.started
+	cancel()
+	select {
+	case err := <-errCh:
+		if err == nil {
+			t.Fatalf("expected context error")
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("ask did not return after cancel")
+	}
+	select {
+	case <-m.cancelled:
+	case <-time.After(time.Second):
+		t.Fatalf("provider request was not cancelled")
+	}
+	for s.inflight.Load() != 0 {
+		time.Sleep(time.Millisecond)
+	}
+}
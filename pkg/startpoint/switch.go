@@ -0,0 +1,114 @@
+package startpoint
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ikermy/AiR_Common/pkg/logger"
+	"github.com/ikermy/AiR_Common/pkg/mode"
+	"github.com/ikermy/AiR_Common/pkg/model"
+	"github.com/ikermy/AiR_Common/pkg/model/create"
+	"github.com/ikermy/AiR_Common/pkg/webhook"
+)
+
+// assistantSwitchPrefix заголовок сводки, с которой новый ассистент продолжает диалог
+const assistantSwitchPrefix = "Диалог передан тебе от другого ассистента. Сводка разговора (учитывай её в следующих ответах):\n"
+
+// assistantInbox канал смены ассистента для Respondent диалога.
+// Создаётся по требованию: Respondent подхватит смену, когда запустится.
+func (s *Start) assistantInbox(treadId uint64) chan model.Assistant {
+	v, _ := s.switchInbox.LoadOrStore(treadId, make(chan model.Assistant, 1))
+	return v.(chan model.Assistant)
+}
+
+// SwitchAssistant передаёт активный диалог ассистенту newAssistID (AssistantRoute.ID или
+// AssistId из WithAssistantRouting), в том числе ассистенту другого провайдера.
+// Новый ассистент получает сводку разговора (WithTranscriptSummarizer или компактная
+// расшифровка), следующие вопросы пользователя обслуживает он. Событие
+// webhook.EventAssistantSwitched уходит с Data["from"] и Data["to"]
+func (s *Start) SwitchAssistant(dialogID uint64, newAssistID string) error {
+	d, ok := s.activeDialog(dialogID)
+	if !ok {
+		return fmt.Errorf("диалог %d не активен", dialogID)
+	}
+	r := s.assistants
+	if r == nil || r.Source == nil {
+		return fmt.Errorf("выбор ассистента не подключён")
+	}
+	userID := d.info.UserID
+	routes, err := r.Source(userID)
+	if err != nil {
+		return fmt.Errorf("ошибка чтения ассистентов пользователя %d: %w", userID, err)
+	}
+	route, ok := findRoute(routes, newAssistID)
+	if !ok {
+		return fmt.Errorf("ассистент %s не найден у пользователя %d", newAssistID, userID)
+	}
+	from := r.boundTo(dialogID)
+	if from == newAssistID {
+		return nil
+	}
+
+	assist := route.Assist
+	assist.UserID = userID
+	next, err := s.Mod.GetOrSetRespGPT(assist, dialogID, d.info.RespID, d.info.RespName)
+	if err != nil {
+		return fmt.Errorf("ошибка подключения ассистента %s к диалогу %d: %w", newAssistID, dialogID, err)
+	}
+	// Router направляет запросы диалога провайдеру нового ассистента
+	if pinner, ok := s.Mod.(model.DialogPinner); ok && assist.Provider != 0 {
+		if err := pinner.PinDialog(dialogID, assist.Provider); err != nil {
+			return fmt.Errorf("ошибка передачи диалога %d провайдеру %s: %w", dialogID, assist.Provider, err)
+		}
+	}
+
+	s.carryOverContext(next, dialogID)
+
+	if s.pool != nil {
+		if v, ok := s.pool.dialogs.Load(dialogID); ok {
+			s.pool.release(v.(*pooledDialog), true)
+		}
+	}
+	if err := model.SendTimeout(s.ctx, s.assistantInbox(dialogID), assist, create.ChanSendTimeout); err != nil {
+		return fmt.Errorf("не удалось передать диалог %d ассистенту %s: %w", dialogID, newAssistID, err)
+	}
+
+	r.bind(userID, dialogID, newAssistID)
+	info := d.info
+	info.AssistName = assist.AssistName
+	s.active.CompareAndSwap(dialogID, d, &activeDialog{info: info, deliver: d.deliver})
+
+	s.emit(next, d.info.RespID, dialogID, webhook.EventAssistantSwitched, map[string]any{"from": from, "to": newAssistID})
+	return nil
+}
+
+// carryOverContext добавляет новому ассистенту сводку разговора, если модель держит
+// историю диалога локально; иначе история читается провайдером из БД
+func (s *Start) carryOverContext(u *model.RespModel, dialogID uint64) {
+	injector, ok := s.Mod.(model.ContextInjector)
+	if !ok {
+		return
+	}
+	log := logger.ForDialog(u.Assist.UserID, dialogID)
+	history, err := s.End.GetDialogHistory(dialogID, mode.OperatorTranscriptHistory)
+	if err != nil {
+		log.Warn("switch: история диалога не прочитана", "err", err)
+		return
+	}
+	if len(history) == 0 {
+		return
+	}
+	summary := ""
+	if s.summarizer != nil {
+		if summary, err = s.summarizer(u, dialogID, history); err != nil {
+			log.Warn("switch: ошибка сводки", "err", err)
+			summary = ""
+		}
+	}
+	if summary = strings.TrimSpace(summary); summary == "" {
+		summary = formatTranscript(history)
+	}
+	if summary != "" {
+		injector.InjectContext(dialogID, assistantSwitchPrefix+summary)
+	}
+}
@@ -0,0 +1,84 @@
+package startpoint
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/ikermy/AiR_Common/pkg/comdb"
+	"github.com/ikermy/AiR_Common/pkg/model"
+	"github.com/ikermy/AiR_Common/pkg/model/create"
+)
+
+// switchModel — модель, запоминающая смену ассистента, закрепление и сводку
+type switchModel struct {
+	model.Inter
+	assist   model.Assistant
+	pinned   create.ProviderType
+	injected string
+}
+
+func (m *switchModel) GetOrSetRespGPT(assist model.Assistant, _, _ uint64, respName string) (*model.RespModel, error) {
+	m.assist = assist
+	return &model.RespModel{Assist: assist, RespName: respName}, nil
+}
+
+func (m *switchModel) PinDialog(_ uint64, kind create.ProviderType) error {
+	m.pinned = kind
+	return nil
+}
+
+func (m *switchModel) InjectContext(_ uint64, text string) bool {
+	m.injected = text
+	return true
+}
+
+func TestSwitchAssistant(t *testing.T) {
+	mod := &switchModel{}
+	end := &memEndpoint{}
+	end.SaveDialog(comdb.User, 10, &model.AssistResponse{Message: "нужен счёт на оплату"})
+	routes := func(uint32) ([]AssistantRoute, error) {
+		return []AssistantRoute{
+			{ID: "support", Assist: model.Assistant{AssistName: "Поддержка", Provider: create.ProviderOpenAI}},
+			{ID: "accountant", Assist: model.Assistant{AssistName: "Бухгалтер", Provider: create.ProviderGoogle}},
+		}, nil
+	}
+	s := New(context.Background(), mod, end, nil, nil, WithAssistantRouting(AssistantRouting{Source: routes}))
+	defer s.cancel()
+
+	if err := s.SwitchAssistant(10, "accountant"); err == nil {
+		t.Fatalf("inactive dialog must not be switched")
+	}
+
+	s.registerActive(&model.RespModel{Assist: model.Assistant{UserID: 7, AssistName: "Поддержка"}}, 3, 10, nil)
+	if _, err := s.RouteAssistant(7, 10, "привет"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SwitchAssistant(10, "unknown"); err == nil {
+		t.Fatalf("unknown assistant must be rejected")
+	}
+	if err := s.SwitchAssistant(10, "accountant"); err != nil {
+		t.Fatalf("switch: %v", err)
+	}
+
+	if mod.assist.UserID != 7 || mod.assist.AssistName != "Бухгалтер" || mod.pinned != create.ProviderGoogle {
+		t.Fatalf("model not switched: assist=%+v pinned=%v", mod.assist, mod.pinned)
+	}
+	if !strings.HasPrefix(mod.injected, assistantSwitchPrefix) || !strings.Contains(mod.injected, "нужен счёт на оплату") {
+		t.Fatalf("context not carried over: %q", mod.injected)
+	}
+	select {
+	case assist := <-s.assistantInbox(10):
+		if assist.AssistName != "Бухгалтер" {
+			t.Fatalf("respondent got %+v", assist)
+		}
+	default:
+		t.Fatalf("respondent not notified")
+	}
+	if got, _ := s.RouteAssistant(7, 10, "ещё вопрос"); got.ID != "accountant" || got.By != RouteBound {
+		t.Fatalf("dialog not rebound: %+v", got)
+	}
+	if d, _ := s.activeDialog(10); d.info.AssistName != "Бухгалтер" {
+		t.Fatalf("active dialog info not updated: %+v", d.info)
+	}
+}
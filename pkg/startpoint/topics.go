@@ -0,0 +1,172 @@
+package startpoint
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ikermy/AiR_Common/pkg/comdb"
+	"github.com/ikermy/AiR_Common/pkg/endpoint"
+	"github.com/ikermy/AiR_Common/pkg/logger"
+	"github.com/ikermy/AiR_Common/pkg/mode"
+	"github.com/ikermy/AiR_Common/pkg/model"
+	"github.com/ikermy/AiR_Common/pkg/model/create"
+	"github.com/ikermy/AiR_Common/pkg/safego"
+)
+
+// TopicTagger размечает расшифровку завершённого диалога темами из taxonomy
+// (пустая taxonomy — свободные короткие метки)
+type TopicTagger func(userID uint32, taxonomy []string, transcript string) ([]string, error)
+
+// TopicStore хранилище тем диалогов (реализуется *comdb.DB)
+type TopicStore interface {
+	SaveDialogTopics(t comdb.DialogTopics) error
+	GetTopTopics(userID uint32, assistName string, since time.Time, limit int) ([]comdb.TopicCount, error)
+}
+
+// TopicTagging настройки разметки тем
+type TopicTagging struct {
+	Store    TopicStore
+	Tagger   TopicTagger
+	Taxonomy []string // допустимые темы; метки вне списка отбрасываются
+	Max      int      // тем на диалог; 0 — mode.TopicMaxPerDialog
+}
+
+// WithTopicTagging после завершения диалога размечает его темами и сохраняет их в Store.
+// Разметка идёт в фоне по последним mode.TopicHistoryMessages сообщениям; диалоги без
+// сообщений пользователя не размечаются. Частые темы для панели — TopTopics
+func WithTopicTagging(t TopicTagging) Option {
+	return func(s *Start) {
+		if t.Store == nil || t.Tagger == nil {
+			return
+		}
+		if t.Max <= 0 {
+			t.Max = mode.TopicMaxPerDialog
+		}
+		s.topics = &t
+	}
+}
+
+const topicPrompt = "You label customer support dialogs by topic. "
+
+// LLMTopicTagger разметка одиночным запросом к модели провайдера
+// (например небольшой модели через UniversalModel.Provider)
+func LLMTopicTagger(ctx context.Context, client create.ProviderClient, modelName string) TopicTagger {
+	return func(userID uint32, taxonomy []string, transcript string) ([]string, error) {
+		reqCtx, cancel := context.WithTimeout(ctx, mode.TopicTimeout)
+		defer cancel()
+
+		system := topicPrompt + "Answer with 1-3 short topics in the dialog language, comma separated, or \"none\"."
+		if len(taxonomy) > 0 {
+			system = topicPrompt + "Choose 1-3 topics only from this list: " + strings.Join(taxonomy, "; ") +
+				". Answer with the chosen topics exactly as written, comma separated, or \"none\"."
+		}
+		resp, err := client.Request(reqCtx, userID, create.ProviderRequest{
+			Model:     modelName,
+			System:    system,
+			Messages:  []create.ProviderMessage{{Role: "user", Text: transcript}},
+			MaxTokens: 64,
+		})
+		if err != nil {
+			return nil, err
+		}
+		text := strings.TrimSpace(resp.Text)
+		if strings.EqualFold(strings.Trim(text, ".\""), "none") {
+			return nil, nil
+		}
+		return strings.FieldsFunc(text, func(r rune) bool { return r == ',' || r == ';' || r == '\n' }), nil
+	}
+}
+
+// normalizeTopics приводит метки к темам таксономии (без учёта регистра), убирает
+// повторы и оставляет не больше limit
+func normalizeTopics(labels, taxonomy []string, limit int) []string {
+	canonical := make(map[string]string, len(taxonomy))
+	for _, t := range taxonomy {
+		canonical[create.NormalizeTriggerText(t)] = strings.TrimSpace(t)
+	}
+	seen := make(map[string]bool, len(labels))
+	var out []string
+	for _, label := range labels {
+		key := create.NormalizeTriggerText(label)
+		if key == "" || seen[key] {
+			continue
+		}
+		topic := key
+		if len(taxonomy) > 0 {
+			var ok bool
+			if topic, ok = canonical[key]; !ok {
+				continue
+			}
+		} else if r := []rune(topic); len(r) > 64 {
+			topic = string(r[:64])
+		}
+		seen[key] = true
+		out = append(out, topic)
+		if len(out) == limit {
+			break
+		}
+	}
+	return out
+}
+
+// tagTopics размечает завершённый диалог в фоне
+func (s *Start) tagTopics(u *model.RespModel, treadId uint64) {
+	t := s.topics
+	if t == nil || treadId == 0 {
+		return
+	}
+	userID, assistName := u.Assist.UserID, u.Assist.AssistName
+	safego.Go("topics.tag", func() {
+		log := logger.ForDialog(userID, treadId)
+		history, err := s.End.GetDialogHistory(treadId, mode.TopicHistoryMessages)
+		if err != nil {
+			log.Warn("topics: история диалога не прочитана", "err", err)
+			return
+		}
+		if !hasUserMessage(history) {
+			return
+		}
+
+		labels, err := t.Tagger(userID, t.Taxonomy, formatTranscript(history))
+		if err != nil {
+			log.Warn("topics: диалог не размечен", "err", err)
+			return
+		}
+		topics := normalizeTopics(labels, t.Taxonomy, t.Max)
+		if len(topics) == 0 {
+			return
+		}
+		if err := t.Store.SaveDialogTopics(comdb.DialogTopics{
+			DialogID: treadId, UserID: userID, AssistName: assistName, Topics: topics, TaggedAt: time.Now(),
+		}); err != nil {
+			log.Warn("topics: темы не сохранены", "err", err)
+		}
+	}, safego.WithUserID(userID))
+}
+
+// hasUserMessage в истории есть непустое сообщение пользователя
+func hasUserMessage(history []endpoint.Message) bool {
+	for _, msg := range history {
+		switch msg.Creator {
+		case comdb.User, comdb.UserVoice, comdb.SpeechRealTimeUser:
+			if strings.TrimSpace(msg.Message.Message) != "" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// TopTopics самые частые темы диалогов пользователя с момента since (assistName "" —
+// по всем ассистентам), не больше limit
+func (s *Start) TopTopics(userID uint32, assistName string, since time.Time, limit int) ([]comdb.TopicCount, error) {
+	if s.topics == nil {
+		return nil, fmt.Errorf("разметка тем не подключена")
+	}
+	if limit <= 0 {
+		limit = 10
+	}
+	return s.topics.Store.GetTopTopics(userID, assistName, since, limit)
+}
@@ -0,0 +1,76 @@
+package startpoint
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ikermy/AiR_Common/pkg/comdb"
+	"github.com/ikermy/AiR_Common/pkg/endpoint"
+	"github.com/ikermy/AiR_Common/pkg/model"
+	"github.com/ikermy/AiR_Common/pkg/webhook"
+)
+
+// chanTopics — TopicStore, передающий сохранённые темы в канал
+type chanTopics struct {
+	saved chan comdb.DialogTopics
+}
+
+func (c *chanTopics) SaveDialogTopics(t comdb.DialogTopics) error {
+	c.saved <- t
+	return nil
+}
+
+func (c *chanTopics) GetTopTopics(uint32, string, time.Time, int) ([]comdb.TopicCount, error) {
+	return nil, nil
+}
+
+func TestNormalizeTopics(t *testing.T) {
+	taxonomy := []string{"Доставка", "Оплата", "Возврат"}
+	got := normalizeTopics([]string{" доставка.", "Цена", "ОПЛАТА", "Доставка", "возврат"}, taxonomy, 2)
+	if strings.Join(got, ",") != "Доставка,Оплата" {
+		t.Fatalf("taxonomy topics: %v", got)
+	}
+	if got := normalizeTopics([]string{"Сроки  доставки!", "сроки доставки", ""}, nil, 3); strings.Join(got, ",") != "сроки доставки" {
+		t.Fatalf("free topics: %v", got)
+	}
+}
+
+func TestTopicTagging_OnDialogEnded(t *testing.T) {
+	store := &chanTopics{saved: make(chan comdb.DialogTopics, 2)}
+	end := &memEndpoint{history: []endpoint.Message{
+		{Creator: comdb.User, Message: model.AssistResponse{Message: "Когда привезут заказ?"}},
+		{Creator: comdb.AI, Message: model.AssistResponse{Message: "Завтра до 18:00"}},
+	}}
+	var transcript string
+	tagger := func(_ uint32, taxonomy []string, text string) ([]string, error) {
+		transcript = text
+		return []string{"доставка", "погода"}, nil
+	}
+	s := &Start{End: end}
+	WithTopicTagging(TopicTagging{Store: store, Tagger: tagger, Taxonomy: []string{"Доставка", "Оплата"}})(s)
+	u := &model.RespModel{Assist: model.Assistant{UserID: 4, AssistName: "Продажи"}}
+
+	s.emit(u, 1, 12, webhook.EventDialogEnded, nil)
+
+	select {
+	case got := <-store.saved:
+		if got.DialogID != 12 || got.UserID != 4 || got.AssistName != "Продажи" || strings.Join(got.Topics, ",") != "Доставка" {
+			t.Fatalf("saved: %+v", got)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("topics not saved")
+	}
+	if !strings.Contains(transcript, "Пользователь: Когда привезут заказ?") {
+		t.Fatalf("transcript: %q", transcript)
+	}
+
+	// Диалог без сообщений пользователя не размечается
+	end.history = []endpoint.Message{{Creator: comdb.AI, Message: model.AssistResponse{Message: "Здравствуйте!"}}}
+	s.emit(u, 1, 13, webhook.EventDialogEnded, nil)
+	select {
+	case got := <-store.saved:
+		t.Fatalf("dialog without user messages tagged: %+v", got)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
@@ -0,0 +1,178 @@
+package startpoint
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/ikermy/AiR_Common/pkg/comdb"
+	"github.com/ikermy/AiR_Common/pkg/endpoint"
+	"github.com/ikermy/AiR_Common/pkg/logger"
+	"github.com/ikermy/AiR_Common/pkg/mode"
+	"github.com/ikermy/AiR_Common/pkg/model"
+	"github.com/ikermy/AiR_Common/pkg/safego"
+)
+
+// TranscriptSummarizer сжимает ранние сообщения диалога в сводку для оператора
+// (например, запросом к модели или по базе знаний)
+type TranscriptSummarizer func(u *model.RespModel, treadId uint64, history []endpoint.Message) (string, error)
+
+// WithTranscriptSummarizer добавляет к расшифровке для оператора сводку сообщений,
+// не вошедших в последние Operator.Transcript
+func WithTranscriptSummarizer(fn TranscriptSummarizer) Option {
+	return func(s *Start) {
+		s.summarizer = fn
+	}
+}
+
+// transcriptTurns сколько последних сообщений передать оператору целиком
+func transcriptTurns(u *model.RespModel) int {
+	if u.Assist.Operator.Transcript > 0 {
+		return int(u.Assist.Operator.Transcript)
+	}
+	return mode.OperatorTranscriptTurns
+}
+
+// operatorTranscript компактная расшифровка диалога: сводка ранних сообщений и последние целиком.
+// Пустая строка — истории нет или расшифровка отключена.
+func (s *Start) operatorTranscript(u *model.RespModel, treadId uint64) (string, error) {
+	turns := transcriptTurns(u)
+	if turns <= 0 {
+		return "", nil
+	}
+	limit := max(turns, mode.OperatorTranscriptHistory)
+
+	history, err := s.End.GetDialogHistory(treadId, limit)
+	if err != nil {
+		return "", fmt.Errorf("ошибка чтения истории диалога %d для оператора: %w", treadId, err)
+	}
+	if len(history) == 0 {
+		return "", nil
+	}
+
+	var sb strings.Builder
+	recent := history
+	if len(history) > turns {
+		earlier := history[:len(history)-turns]
+		recent = history[len(history)-turns:]
+		summary := ""
+		if s.summarizer != nil {
+			if summary, err = s.summarizer(u, treadId, earlier); err != nil {
+				//logger.Warn("transcript: ошибка сводки диалога %d: %v", treadId, err, u.Assist.UserID)
+				summary = ""
+			}
+		}
+		if summary = strings.TrimSpace(summary); summary != "" {
+			sb.WriteString("Кратко: ")
+			sb.WriteString(summary)
+			sb.WriteString("\n")
+		} else {
+			fmt.Fprintf(&sb, "… ранее сообщений: %d\n", len(earlier))
+		}
+	}
+
+	sb.WriteString(formatTranscript(recent))
+	return strings.TrimSuffix(sb.String(), "\n"), nil
+}
+
+// formatTranscript строки "Роль: текст" с обрезкой длинных сообщений
+func formatTranscript(msgs []endpoint.Message) string {
+	var sb strings.Builder
+	for _, msg := range msgs {
+		text := strings.TrimSpace(msg.Message.Message)
+		if text == "" {
+			continue
+		}
+		sb.WriteString(creatorLabel(msg.Creator))
+		sb.WriteString(": ")
+		sb.WriteString(truncateRunes(strings.Join(strings.Fields(text), " "), mode.OperatorTranscriptChars))
+		sb.WriteString("\n")
+	}
+	return strings.TrimSuffix(sb.String(), "\n")
+}
+
+// sendTranscriptToOperator отправляет оператору расшифровку диалога перед первым вопросом.
+// Расшифровка идёт сообщением типа "transcript" без флага эскалации; её сбой не прерывает диалог.
+func (s *Start) sendTranscriptToOperator(u *model.RespModel, treadId uint64) {
+	text, err := s.operatorTranscript(u, treadId)
+	if err != nil {
+		logger.ForDialog(u.Assist.UserID, treadId).Warn("transcript: история для оператора не собрана", "err", err)
+		return
+	}
+	if text == "" {
+		return
+	}
+	content := model.AssistResponse{Message: text}
+	name := u.Assist.AssistName
+	msg := s.Mod.NewMessage(model.Operator{}, "transcript", &content, &name)
+	if err := s.Oper.SendToOperator(s.ctx, u.Assist.UserID, treadId, msg); err != nil {
+		logger.ForDialog(u.Assist.UserID, treadId).Warn("transcript: ошибка отправки истории оператору", "err", err)
+	}
+}
+
+func creatorLabel(c comdb.CreatorType) string {
+	switch c {
+	case comdb.User, comdb.UserVoice, comdb.SpeechRealTimeUser:
+		return "Пользователь"
+	case comdb.Operator:
+		return "Оператор"
+	default:
+		return "Ассистент"
+	}
+}
+
+// truncateRunes обрезает text до limit символов с многоточием
+func truncateRunes(text string, limit int) string {
+	if limit <= 0 || utf8.RuneCountInString(text) <= limit {
+		return text
+	}
+	r := []rune(text)
+	return string(r[:limit]) + "…"
+}
+
+// operatorSummaryPrefix заголовок сводки разговора с оператором в истории модели
+const operatorSummaryPrefix = "Сводка разговора пользователя с оператором (учитывай её в следующих ответах):\n"
+
+// injectOperatorSummary после возврата диалога AI добавляет в историю модели сводку
+// сообщений с момента since: через WithTranscriptSummarizer или компактную расшифровку.
+// Работает, если модель реализует model.ContextInjector.
+func (s *Start) injectOperatorSummary(u *model.RespModel, treadId uint64, since time.Time) {
+	injector, ok := s.Mod.(model.ContextInjector)
+	if !ok || since.IsZero() {
+		return
+	}
+	summarizer := s.summarizer
+
+	safego.Go("operatorSummary", func() {
+		log := logger.ForDialog(u.Assist.UserID, treadId)
+		history, err := s.End.GetDialogHistory(treadId, mode.OperatorTranscriptHistory)
+		if err != nil {
+			log.Warn("operatorSummary: история диалога не прочитана", "err", err)
+			return
+		}
+		var part []endpoint.Message
+		for _, msg := range history {
+			if !msg.Timestamp.Before(since) {
+				part = append(part, msg)
+			}
+		}
+		if len(part) == 0 {
+			return
+		}
+
+		summary := ""
+		if summarizer != nil {
+			if summary, err = summarizer(u, treadId, part); err != nil {
+				log.Warn("operatorSummary: ошибка сводки", "err", err)
+				summary = ""
+			}
+		}
+		if summary = strings.TrimSpace(summary); summary == "" {
+			summary = formatTranscript(part)
+		}
+		if summary != "" {
+			injector.InjectContext(treadId, operatorSummaryPrefix+summary)
+		}
+	}, safego.WithUserID(u.Assist.UserID))
+}
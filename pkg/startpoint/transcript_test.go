@@ -0,0 +1,94 @@
+package startpoint
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ikermy/AiR_Common/pkg/comdb"
+	"github.com/ikermy/AiR_Common/pkg/endpoint"
+	"github.com/ikermy/AiR_Common/pkg/mode"
+	"github.com/ikermy/AiR_Common/pkg/model"
+	"github.com/ikermy/AiR_Common/pkg/model/create"
+)
+
+func TestOperatorTranscript_LastTurnsAndSummary(t *testing.T) {
+	end := &memEndpoint{}
+	for _, m := range []struct {
+		creator comdb.CreatorType
+		text    string
+	}{
+		{comdb.User, "здравствуйте"},
+		{comdb.AI, "добрый день"},
+		{comdb.User, "где мой   заказ"},
+		{comdb.AI, strings.Repeat("а", mode.OperatorTranscriptChars+10)},
+	} {
+		end.SaveDialog(m.creator, 1, &model.AssistResponse{Message: m.text})
+	}
+
+	s := &Start{End: end}
+	u := &model.RespModel{Assist: model.Assistant{Operator: create.OperatorConfig{Transcript: 2}}}
+
+	text, err := s.operatorTranscript(u, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(text, "\n")
+	if len(lines) != 3 || lines[0] != "… ранее сообщений: 2" || lines[1] != "Пользователь: где мой заказ" {
+		t.Fatalf("unexpected transcript:\n%s", text)
+	}
+	if !strings.HasPrefix(lines[2], "Ассистент: ") || !strings.HasSuffix(lines[2], "…") {
+		t.Fatalf("long message should be truncated: %q", lines[2])
+	}
+
+	var summarized []endpoint.Message
+	WithTranscriptSummarizer(func(_ *model.RespModel, _ uint64, history []endpoint.Message) (string, error) {
+		summarized = history
+		return "клиент поздоровался", nil
+	})(s)
+	text, _ = s.operatorTranscript(u, 1)
+	if !strings.HasPrefix(text, "Кратко: клиент поздоровался\n") || len(summarized) != 2 {
+		t.Fatalf("unexpected summary transcript (%d summarized):\n%s", len(summarized), text)
+	}
+}
+
+func TestOperatorTranscript_Empty(t *testing.T) {
+	s := &Start{End: &memEndpoint{}}
+	if text, err := s.operatorTranscript(&model.RespModel{}, 1); err != nil || text != "" {
+		t.Fatalf("empty history: %q, %v", text, err)
+	}
+}
+
+// injectModel модель с кэшем истории для сводки после оператора
+type injectModel struct {
+	model.Inter
+	injected chan string
+}
+
+func (m *injectModel) InjectContext(_ uint64, text string) bool {
+	m.injected <- text
+	return true
+}
+
+func TestInjectOperatorSummary(t *testing.T) {
+	end := &memEndpoint{}
+	end.SaveDialog(comdb.User, 1, &model.AssistResponse{Message: "до оператора"})
+	time.Sleep(time.Millisecond)
+	since := time.Now()
+	end.SaveDialog(comdb.User, 1, &model.AssistResponse{Message: "верните деньги"})
+	end.SaveDialog(comdb.Operator, 1, &model.AssistResponse{Message: "оформил возврат"})
+
+	mod := &injectModel{injected: make(chan string, 1)}
+	s := &Start{End: end, Mod: mod}
+	s.injectOperatorSummary(&model.RespModel{}, 1, since)
+
+	select {
+	case text := <-mod.injected:
+		want := operatorSummaryPrefix + "Пользователь: верните деньги\nОператор: оформил возврат"
+		if text != want {
+			t.Fatalf("got %q, want %q", text, want)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("summary was not injected")
+	}
+}
@@ -0,0 +1,62 @@
+package startpoint
+
+import (
+	"context"
+	"time"
+
+	"github.com/ikermy/AiR_Common/pkg/mode"
+	"github.com/ikermy/AiR_Common/pkg/model"
+	"github.com/ikermy/AiR_Common/pkg/model/create"
+	"github.com/ikermy/AiR_Common/pkg/safego"
+)
+
+// startTyping отправляет в TxCh событие "typing" и повторяет его каждые create.TypingInterval,
+// пока модель обрабатывает запрос. Возвращаемая функция останавливает повтор и отправляет "typing_done".
+// События служебные: при переполнении TxCh они пропускаются, не вытесняя ответы.
+func (s *Start) startTyping(ctx context.Context, respId uint64) (stop func()) {
+	if !mode.TypingEvents || s.Mod == nil {
+		return func() {}
+	}
+	ch, err := s.Mod.GetCh(respId)
+	if err != nil {
+		return func() {}
+	}
+
+	send := func(msgType string) {
+		msg := s.Mod.NewMessage(model.Operator{}, msgType, &model.AssistResponse{}, nil)
+		defer func() { _ = recover() }() // TxCh может быть закрыт
+		if !ch.IsTxOpen() {
+			return
+		}
+		select {
+		case ch.TxCh <- msg:
+		default:
+		}
+	}
+
+	send(model.MsgTypeTyping)
+
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	safego.Go("typing", func() {
+		defer close(stopped)
+		ticker := time.NewTicker(create.TypingInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				send(model.MsgTypeTyping)
+			}
+		}
+	})
+
+	return func() {
+		close(done)
+		<-stopped
+		send(model.MsgTypeTypingDone)
+	}
+}
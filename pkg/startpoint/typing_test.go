@@ -0,0 +1,38 @@
+package startpoint
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ikermy/AiR_Common/pkg/mode"
+	"github.com/ikermy/AiR_Common/pkg/model"
+)
+
+func TestTyping_StartAndDone(t *testing.T) {
+	ch := &model.Ch{TxCh: make(chan model.Message, 4), RxCh: make(chan model.Message, 1)}
+	s := New(context.Background(), &chModel{ch: ch}, nil, nil, nil)
+	defer s.cancel()
+
+	stop := s.startTyping(context.Background(), 1)
+	if msg := <-ch.TxCh; msg.Type != model.MsgTypeTyping {
+		t.Fatalf("expected typing event, got %q", msg.Type)
+	}
+	stop()
+	if msg := <-ch.TxCh; msg.Type != model.MsgTypeTypingDone {
+		t.Fatalf("expected typing_done event, got %q", msg.Type)
+	}
+}
+
+func TestTyping_Disabled(t *testing.T) {
+	defer func(v bool) { mode.TypingEvents = v }(mode.TypingEvents)
+	mode.TypingEvents = false
+
+	ch := &model.Ch{TxCh: make(chan model.Message, 4), RxCh: make(chan model.Message, 1)}
+	s := New(context.Background(), &chModel{ch: ch}, nil, nil, nil)
+	defer s.cancel()
+
+	s.startTyping(context.Background(), 1)()
+	if len(ch.TxCh) != 0 {
+		t.Fatalf("no events expected when typing is disabled")
+	}
+}
@@ -0,0 +1,56 @@
+package startpoint
+
+import (
+	"github.com/ikermy/AiR_Common/pkg/events"
+	"github.com/ikermy/AiR_Common/pkg/model"
+	"github.com/ikermy/AiR_Common/pkg/webhook"
+)
+
+// WithWebhooks отправляет события диалогов (начало и конец, цель, запрос оператора, ошибки)
+// подписчикам пользователя через d
+func WithWebhooks(d *webhook.Dispatcher) Option {
+	return func(s *Start) {
+		s.webhooks = d
+	}
+}
+
+// emit ставит событие диалога в очередь доставки (через шину, если задан WithEventBus),
+// не задерживая обработку
+func (s *Start) emit(u *model.RespModel, respId, treadId uint64, event string, data map[string]any) {
+	s.recordAnalytics(u, treadId, event)
+	if event == webhook.EventDialogEnded {
+		s.tagTopics(u, treadId)
+		s.assistants.forget(treadId)
+		s.clearOperatorMode(u, respId, treadId)
+	}
+	data = s.withVariant(u.Assist.UserID, treadId, data)
+	if s.bus != nil {
+		s.bus.Publish(events.Event{
+			Type:       events.Type(event),
+			UserID:     u.Assist.UserID,
+			DialogID:   treadId,
+			RespID:     respId,
+			RespName:   u.RespName,
+			AssistName: u.Assist.AssistName,
+			Data:       data,
+		})
+		return
+	}
+	if s.webhooks == nil {
+		return
+	}
+	s.webhooks.Dispatch(webhook.Event{
+		Type:       event,
+		UserID:     u.Assist.UserID,
+		DialogID:   treadId,
+		RespID:     respId,
+		RespName:   u.RespName,
+		AssistName: u.Assist.AssistName,
+		Data:       data,
+	})
+}
+
+// emitError событие webhook.EventError с текстом ошибки
+func (s *Start) emitError(u *model.RespModel, respId, treadId uint64, err error) {
+	s.emit(u, respId, treadId, webhook.EventError, map[string]any{"error": err.Error()})
+}
@@ -0,0 +1,146 @@
+package storage
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ikermy/AiR_Common/pkg/mode"
+)
+
+// S3 S3-совместимое хранилище (AWS, Yandex Object Storage, MinIO) с подписью
+// запросов AWS Signature V4 в строке запроса
+type S3 struct {
+	Endpoint  string // https://s3.amazonaws.com, https://storage.yandexcloud.net
+	Region    string // us-east-1, ru-central1
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	PathStyle bool   // https://endpoint/bucket/key вместо https://bucket.endpoint/key (MinIO)
+	PublicURL string // базовый URL публичного бакета или CDN; пусто — ссылка presigned GET на mode.MediaURLTTL
+
+	now func() time.Time // для тестов
+}
+
+// PresignUpload presigned PUT объекта key
+func (s *S3) PresignUpload(_ context.Context, key, contentType string) (Upload, error) {
+	if s.Endpoint == "" || s.Bucket == "" || s.AccessKey == "" || s.SecretKey == "" {
+		return Upload{}, fmt.Errorf("S3: не заданы endpoint, bucket или ключи доступа")
+	}
+	uploadURL, err := s.Presign(http.MethodPut, key, mode.MediaUploadTTL)
+	if err != nil {
+		return Upload{}, err
+	}
+
+	fileURL := ""
+	if s.PublicURL != "" {
+		fileURL = strings.TrimSuffix(s.PublicURL, "/") + "/" + encodePath(key)
+	} else if fileURL, err = s.Presign(http.MethodGet, key, mode.MediaURLTTL); err != nil {
+		return Upload{}, err
+	}
+
+	header := http.Header{}
+	if contentType != "" {
+		header.Set("Content-Type", contentType)
+	}
+	return Upload{Method: http.MethodPut, URL: uploadURL, Header: header, FileURL: fileURL}, nil
+}
+
+// Presign URL запроса method к объекту key, действительный ttl (не больше 7 дней).
+// Подписывается только заголовок host, тело не подписывается (UNSIGNED-PAYLOAD)
+func (s *S3) Presign(method, key string, ttl time.Duration) (string, error) {
+	base, err := url.Parse(s.Endpoint)
+	if err != nil || base.Host == "" {
+		return "", fmt.Errorf("S3: некорректный endpoint %q", s.Endpoint)
+	}
+	host, uri := base.Host, "/"+encodePath(key)
+	if s.PathStyle {
+		uri = "/" + s.Bucket + uri
+	} else {
+		host = s.Bucket + "." + host
+	}
+
+	now := time.Now
+	if s.now != nil {
+		now = s.now
+	}
+	t := now().UTC()
+	amzDate := t.Format("20060102T150405Z")
+	date := amzDate[:8]
+	scope := date + "/" + s.Region + "/s3/aws4_request"
+
+	query := map[string]string{
+		"X-Amz-Algorithm":     "AWS4-HMAC-SHA256",
+		"X-Amz-Credential":    s.AccessKey + "/" + scope,
+		"X-Amz-Date":          amzDate,
+		"X-Amz-Expires":       strconv.Itoa(int(ttl.Seconds())),
+		"X-Amz-SignedHeaders": "host",
+	}
+	canonicalQuery := canonicalQueryString(query)
+	canonicalRequest := strings.Join([]string{
+		method, uri, canonicalQuery, "host:" + host + "\n", "host", "UNSIGNED-PAYLOAD",
+	}, "\n")
+	stringToSign := strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, scope, sha256Hex(canonicalRequest)}, "\n")
+
+	key4 := hmacSHA256([]byte("AWS4"+s.SecretKey), date)
+	key4 = hmacSHA256(key4, s.Region)
+	key4 = hmacSHA256(key4, "s3")
+	key4 = hmacSHA256(key4, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key4, stringToSign))
+
+	return base.Scheme + "://" + host + uri + "?" + canonicalQuery + "&X-Amz-Signature=" + signature, nil
+}
+
+func canonicalQueryString(query map[string]string) string {
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		parts[i] = uriEncode(k, true) + "=" + uriEncode(query[k], true)
+	}
+	return strings.Join(parts, "&")
+}
+
+// encodePath кодирует ключ объекта по правилам SigV4, сохраняя "/"
+func encodePath(key string) string {
+	return uriEncode(strings.TrimPrefix(key, "/"), false)
+}
+
+// uriEncode кодирование URI по SigV4: всё, кроме A-Z a-z 0-9 - _ . ~, в %XX
+func uriEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c >= 'A' && c <= 'Z', c >= 'a' && c <= 'z', c >= '0' && c <= '9', c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+func sha256Hex(data string) string {
+	sum := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(sum[:])
+}
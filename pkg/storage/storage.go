@@ -0,0 +1,75 @@
+// Package storage сохраняет сгенерированные медиафайлы (изображения, видео) в объектное
+// хранилище по presigned URL: данные загружаются напрямую, без передачи base64 через
+// RunAction, а в send_files попадает ссылка на сохранённый файл.
+package storage
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/ikermy/AiR_Common/pkg/mode"
+)
+
+// Upload presigned-загрузка одного объекта
+type Upload struct {
+	Method  string      // обычно PUT
+	URL     string      // presigned URL загрузки
+	Header  http.Header // заголовки, которые нужно передать с загрузкой
+	FileURL string      // ссылка на файл после загрузки (публичная или presigned GET)
+}
+
+// MediaStore хранилище, выдающее presigned URL для загрузки объектов
+type MediaStore interface {
+	PresignUpload(ctx context.Context, key, contentType string) (Upload, error)
+}
+
+// MediaKey ключ объекта для файла пользователя: media/<userID>/<unix>_<имя>
+func MediaKey(userID uint32, fileName string) string {
+	name := strings.ReplaceAll(path.Base(fileName), " ", "_")
+	return fmt.Sprintf("media/%d/%d_%s", userID, time.Now().Unix(), name)
+}
+
+// Put загружает data по presigned URL и возвращает ссылку на файл
+func Put(ctx context.Context, store MediaStore, key, contentType string, data []byte) (string, error) {
+	upload, err := store.PresignUpload(ctx, key, contentType)
+	if err != nil {
+		return "", fmt.Errorf("ошибка получения presigned URL для %s: %w", key, err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, mode.MediaUploadTimeout)
+	defer cancel()
+
+	method := upload.Method
+	if method == "" {
+		method = http.MethodPut
+	}
+	req, err := http.NewRequestWithContext(ctx, method, upload.URL, bytes.NewReader(data))
+	if err != nil {
+		return "", fmt.Errorf("ошибка создания запроса загрузки %s: %w", key, err)
+	}
+	for name, values := range upload.Header {
+		for _, v := range values {
+			req.Header.Add(name, v)
+		}
+	}
+	if contentType != "" && req.Header.Get("Content-Type") == "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("ошибка загрузки %s: %w", key, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return "", fmt.Errorf("хранилище отклонило загрузку %s: %s %s", key, resp.Status, strings.TrimSpace(string(body)))
+	}
+	return upload.FileURL, nil
+}
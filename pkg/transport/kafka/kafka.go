@@ -0,0 +1,133 @@
+// Package kafka — transport.Broker поверх Kafka (github.com/segmentio/kafka-go).
+//
+// Ключ сообщения — ключ диалога, партиция выбирается по его хэшу, поэтому сообщения
+// одного диалога читаются по порядку одним участником группы.
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ikermy/AiR_Common/pkg/logger"
+	"github.com/ikermy/AiR_Common/pkg/mode"
+	"github.com/ikermy/AiR_Common/pkg/transport"
+	kafkago "github.com/segmentio/kafka-go"
+)
+
+// Option настройка Broker
+type Option func(*Broker)
+
+// WithDialer задаёт Dialer для TLS/SASL
+func WithDialer(d *kafkago.Dialer) Option {
+	return func(b *Broker) { b.dialer = d }
+}
+
+// WithStartOffset задаёт смещение для новых групп (по умолчанию kafkago.LastOffset — только новые сообщения)
+func WithStartOffset(offset int64) Option {
+	return func(b *Broker) { b.startOffset = offset }
+}
+
+// Broker реализация transport.Broker
+type Broker struct {
+	brokers     []string
+	dialer      *kafkago.Dialer
+	startOffset int64
+	writer      *kafkago.Writer
+
+	mu      sync.Mutex
+	readers []*kafkago.Reader
+}
+
+var _ transport.Broker = (*Broker)(nil)
+
+// New создаёт Broker для адресов brokers
+func New(brokers []string, opts ...Option) (*Broker, error) {
+	if len(brokers) == 0 {
+		return nil, fmt.Errorf("не заданы адреса Kafka")
+	}
+	b := &Broker{brokers: brokers, startOffset: kafkago.LastOffset}
+	for _, opt := range opts {
+		opt(b)
+	}
+	b.writer = &kafkago.Writer{
+		Addr:                   kafkago.TCP(brokers...),
+		Balancer:               &kafkago.Hash{},
+		RequiredAcks:           kafkago.RequireAll,
+		AllowAutoTopicCreation: true,
+	}
+	if b.dialer != nil {
+		b.writer.Transport = &kafkago.Transport{TLS: b.dialer.TLS, SASL: b.dialer.SASLMechanism}
+	}
+	return b, nil
+}
+
+// Publish записывает сообщение с ключом key
+func (b *Broker) Publish(ctx context.Context, topic, key string, data []byte) error {
+	if err := b.writer.WriteMessages(ctx, kafkago.Message{Topic: topic, Key: []byte(key), Value: data}); err != nil {
+		return fmt.Errorf("ошибка записи в Kafka %s: %w", topic, err)
+	}
+	return nil
+}
+
+// Subscribe читает topic в группе group. Смещение фиксируется после успешной обработки;
+// при ошибке обработчика сообщение повторяется через mode.TransportRetryDelay,
+// не пропуская следующие сообщения партиции. После mode.TransportMaxAttempts неудач
+// сообщение пропускается с записью в лог: постоянная ошибка (удалённый ассистент,
+// закрытый канал) не останавливает остальные диалоги партиции.
+func (b *Broker) Subscribe(ctx context.Context, topic, group string, h transport.Handler) error {
+	r := kafkago.NewReader(kafkago.ReaderConfig{
+		Brokers:     b.brokers,
+		GroupID:     group,
+		Topic:       topic,
+		Dialer:      b.dialer,
+		StartOffset: b.startOffset,
+		MaxWait:     500 * time.Millisecond,
+	})
+	b.mu.Lock()
+	b.readers = append(b.readers, r)
+	b.mu.Unlock()
+	defer func() { _ = r.Close() }()
+
+	for {
+		msg, err := r.FetchMessage(ctx)
+		if err != nil {
+			if ctx.Err() != nil || errors.Is(err, context.Canceled) {
+				return nil
+			}
+			return fmt.Errorf("ошибка чтения Kafka %s: %w", topic, err)
+		}
+		for attempt := 1; ; attempt++ {
+			err := h(string(msg.Key), msg.Value)
+			if err == nil {
+				break
+			}
+			if attempt >= max(mode.TransportMaxAttempts, 1) {
+				logger.Error("kafka: сообщение %s/%s пропущено после %d попыток: %v", topic, msg.Key, attempt, err)
+				break
+			}
+			select {
+			case <-time.After(mode.TransportRetryDelay):
+			case <-ctx.Done():
+				return nil
+			}
+		}
+		if err := r.CommitMessages(ctx, msg); err != nil && ctx.Err() == nil {
+			return fmt.Errorf("ошибка фиксации смещения Kafka %s: %w", topic, err)
+		}
+	}
+}
+
+// Close закрывает писателя и читателей
+func (b *Broker) Close() error {
+	b.mu.Lock()
+	readers := b.readers
+	b.readers = nil
+	b.mu.Unlock()
+	for _, r := range readers {
+		_ = r.Close()
+	}
+	return b.writer.Close()
+}
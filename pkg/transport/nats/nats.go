@@ -0,0 +1,233 @@
+// Package nats — transport.Broker поверх NATS JetStream (github.com/nats-io/nats.go/jetstream).
+//
+// Каждый топик — отдельный поток. Ключ сообщения (диалог) раскладывается по mode.TransportLanes
+// разделам с субъектами "<topic>.<раздел>.<key>"; группа подписки — по durable-консьюмеру на раздел
+// с MaxAckPending = 1. JetStream не выдаёт следующее сообщение раздела, пока не подтверждено
+// предыдущее, поэтому сообщения диалога обрабатываются по порядку и при нескольких экземплярах
+// в одной группе. Сообщение подтверждается после успешной обработки (at-least-once).
+// Число разделов должно совпадать у всех издателей и подписчиков.
+package nats
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ikermy/AiR_Common/pkg/logger"
+	"github.com/ikermy/AiR_Common/pkg/mode"
+	"github.com/ikermy/AiR_Common/pkg/safego"
+	"github.com/ikermy/AiR_Common/pkg/transport"
+	natsgo "github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// consumerInactive время, после которого неиспользуемый консьюмер удаляется сервером
+const consumerInactive = time.Hour
+
+// Broker реализация transport.Broker
+type Broker struct {
+	nc      *natsgo.Conn
+	js      jetstream.JetStream
+	ownConn bool
+
+	streams sync.Map // topic -> имя потока
+}
+
+var _ transport.Broker = (*Broker)(nil)
+
+// Connect подключается к NATS по url и создаёт Broker; соединение закрывается в Close
+func Connect(url string, opts ...natsgo.Option) (*Broker, error) {
+	nc, err := natsgo.Connect(url, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка подключения к NATS %s: %w", url, err)
+	}
+	b, err := New(nc)
+	if err != nil {
+		nc.Close()
+		return nil, err
+	}
+	b.ownConn = true
+	return b, nil
+}
+
+// New создаёт Broker поверх существующего соединения (его закрывает вызывающий)
+func New(nc *natsgo.Conn) (*Broker, error) {
+	js, err := jetstream.New(nc)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка инициализации JetStream: %w", err)
+	}
+	return &Broker{nc: nc, js: js}, nil
+}
+
+// stream создаёт (или обновляет) поток топика при первом обращении
+func (b *Broker) stream(ctx context.Context, topic string) (string, error) {
+	if name, ok := b.streams.Load(topic); ok {
+		return name.(string), nil
+	}
+	name := sanitize(strings.ToUpper(topic))
+	if _, err := b.js.CreateOrUpdateStream(ctx, jetstream.StreamConfig{
+		Name:     name,
+		Subjects: []string{topic + ".>"},
+		MaxAge:   mode.TransportMaxAge,
+	}); err != nil {
+		return "", fmt.Errorf("ошибка создания потока JetStream %s: %w", name, err)
+	}
+	b.streams.Store(topic, name)
+	return name, nil
+}
+
+// Publish публикует сообщение в субъект "<topic>.<раздел>.<key>" и ждёт подтверждения сервера
+func (b *Broker) Publish(ctx context.Context, topic, key string, data []byte) error {
+	if _, err := b.stream(ctx, topic); err != nil {
+		return err
+	}
+	if _, err := b.js.Publish(ctx, subject(topic, key), data); err != nil {
+		return fmt.Errorf("ошибка публикации в JetStream %s: %w", topic, err)
+	}
+	return nil
+}
+
+// Subscribe читает topic durable-консьюмерами группы group (по одному на раздел) до отмены ctx.
+// Разделы обрабатываются параллельно, сообщения раздела — по одному. При ошибке обработчика
+// сообщение повторяется через mode.TransportRetryDelay с продлением AckWait.
+func (b *Broker) Subscribe(ctx context.Context, topic, group string, h transport.Handler) error {
+	name, err := b.stream(ctx, topic)
+	if err != nil {
+		return err
+	}
+
+	n := partitions()
+	var (
+		wg       sync.WaitGroup
+		lanes    = make([]chan jetstream.Msg, 0, n)
+		contexts = make([]jetstream.ConsumeContext, 0, n)
+	)
+	stop := func() {
+		for _, cc := range contexts {
+			cc.Stop()
+			<-cc.Closed()
+		}
+		for _, l := range lanes {
+			close(l)
+		}
+		wg.Wait()
+	}
+
+	for p := range n {
+		cons, err := b.js.CreateOrUpdateConsumer(ctx, name, jetstream.ConsumerConfig{
+			Durable:           sanitize(group) + "_" + strconv.Itoa(p),
+			FilterSubject:     topic + "." + strconv.Itoa(p) + ".>",
+			AckPolicy:         jetstream.AckExplicitPolicy,
+			DeliverPolicy:     jetstream.DeliverNewPolicy,
+			MaxAckPending:     1,
+			MaxDeliver:        max(mode.TransportMaxAttempts, 1), // паника обработчика — повторная доставка
+			InactiveThreshold: consumerInactive,
+		})
+		if err != nil {
+			stop()
+			return fmt.Errorf("ошибка создания консьюмера JetStream %s/%s: %w", name, group, err)
+		}
+
+		in := make(chan jetstream.Msg, 1)
+		lanes = append(lanes, in)
+		wg.Add(1)
+		prefix := topic + "." + strconv.Itoa(p) + "."
+		safego.Go("nats.lane", func() {
+			defer wg.Done()
+			runLane(ctx, in, prefix, h)
+		})
+
+		cc, err := cons.Consume(func(msg jetstream.Msg) {
+			select {
+			case in <- msg:
+			case <-ctx.Done():
+			}
+		})
+		if err != nil {
+			stop()
+			return fmt.Errorf("ошибка подписки JetStream %s/%s: %w", name, group, err)
+		}
+		contexts = append(contexts, cc)
+	}
+
+	<-ctx.Done()
+	stop()
+	return nil
+}
+
+// runLane обрабатывает сообщения раздела по очереди. Паника обработчика не останавливает раздел:
+// сообщение возвращается серверу (Nak) и будет доставлено повторно
+func runLane(ctx context.Context, in <-chan jetstream.Msg, prefix string, h transport.Handler) {
+	for msg := range in {
+		if err := safego.Run("nats.process", func() { process(ctx, prefix, msg, h) }); err != nil {
+			_ = msg.Nak()
+		}
+	}
+}
+
+// process вызывает обработчик до успеха и подтверждает сообщение. После
+// mode.TransportMaxAttempts неудач сообщение снимается с доставки (Term) с записью в лог,
+// чтобы постоянная ошибка не занимала раздел до mode.TransportMaxAge
+func process(ctx context.Context, prefix string, msg jetstream.Msg, h transport.Handler) {
+	key := strings.TrimPrefix(msg.Subject(), prefix)
+	for attempt := 1; ; attempt++ {
+		err := h(key, msg.Data())
+		if err == nil {
+			break
+		}
+		if attempt >= max(mode.TransportMaxAttempts, 1) {
+			logger.Error("nats: сообщение %s снято с доставки после %d попыток: %v", msg.Subject(), attempt, err)
+			_ = msg.Term()
+			return
+		}
+		_ = msg.InProgress()
+		select {
+		case <-time.After(mode.TransportRetryDelay):
+		case <-ctx.Done():
+			_ = msg.Nak()
+			return
+		}
+	}
+	if err := msg.Ack(); err != nil {
+		logger.Warn("nats: подтверждение сообщения %s не отправлено: %v", msg.Subject(), err)
+	}
+}
+
+// Close закрывает соединение, если оно создано Connect
+func (b *Broker) Close() error {
+	if b.ownConn {
+		b.nc.Close()
+	}
+	return nil
+}
+
+// partitions число разделов топика
+func partitions() int {
+	return max(mode.TransportLanes, 1)
+}
+
+// subject субъект сообщения с ключом key: "<topic>.<раздел>.<key>"
+func subject(topic, key string) string {
+	return topic + "." + strconv.Itoa(lane(key, partitions())) + "." + key
+}
+
+func lane(key string, n int) int {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(key))
+	return int(h.Sum32() % uint32(n))
+}
+
+// sanitize имена потоков и консьюмеров не могут содержать ".", "*", ">" и пробелы
+func sanitize(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '.', '*', '>', ' ', '\t', '\n':
+			return '_'
+		}
+		return r
+	}, s)
+}
@@ -0,0 +1,139 @@
+package nats
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ikermy/AiR_Common/pkg/mode"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// fakeMsg — jetstream.Msg с учётом подтверждений
+type fakeMsg struct {
+	jetstream.Msg
+	subject string
+	data    string
+
+	mu                      sync.Mutex
+	acks, naks, prog, terms int
+}
+
+func (m *fakeMsg) Subject() string { return m.subject }
+func (m *fakeMsg) Data() []byte    { return []byte(m.data) }
+
+func (m *fakeMsg) Ack() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.acks++
+	return nil
+}
+
+func (m *fakeMsg) Nak() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.naks++
+	return nil
+}
+
+func (m *fakeMsg) Term() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.terms++
+	return nil
+}
+
+func (m *fakeMsg) InProgress() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.prog++
+	return nil
+}
+
+func TestSubject_SamePartitionForKey(t *testing.T) {
+	s := subject("air.questions", "42")
+	if s != subject("air.questions", "42") {
+		t.Fatalf("partition of a key must be stable")
+	}
+	parts := strings.SplitN(s, ".", 4)
+	if len(parts) != 4 || parts[3] != "42" {
+		t.Fatalf("unexpected subject %q", s)
+	}
+	if parts[2] == "" || strings.TrimPrefix(s, "air.questions."+parts[2]+".") != "42" {
+		t.Fatalf("key not recoverable from %q", s)
+	}
+}
+
+func TestRunLane_OrderRetryAndPanic(t *testing.T) {
+	oldDelay := mode.TransportRetryDelay
+	mode.TransportRetryDelay = time.Millisecond
+	defer func() { mode.TransportRetryDelay = oldDelay }()
+
+	var (
+		got      []string
+		failures = 2
+	)
+	h := func(key string, data []byte) error {
+		if string(data) == "panic" {
+			panic("обработчик упал")
+		}
+		if string(data) == "retry" && failures > 0 {
+			failures--
+			return errors.New("временная ошибка")
+		}
+		got = append(got, key+":"+string(data))
+		return nil
+	}
+
+	msgs := []*fakeMsg{
+		{subject: "t.3.7", data: "first"},
+		{subject: "t.3.7", data: "retry"},
+		{subject: "t.3.7", data: "panic"},
+		{subject: "t.3.7", data: "last"},
+	}
+	in := make(chan jetstream.Msg, len(msgs))
+	for _, m := range msgs {
+		in <- m
+	}
+	close(in)
+	runLane(context.Background(), in, "t.3.", h)
+
+	if strings.Join(got, ",") != "7:first,7:retry,7:last" {
+		t.Fatalf("unexpected order: %v", got)
+	}
+	if msgs[1].acks != 1 || msgs[1].prog != 2 {
+		t.Fatalf("retried message: acks=%d in_progress=%d", msgs[1].acks, msgs[1].prog)
+	}
+	if msgs[2].acks != 0 || msgs[2].naks != 1 {
+		t.Fatalf("panicked message must be returned to the server: acks=%d naks=%d", msgs[2].acks, msgs[2].naks)
+	}
+}
+
+func TestProcess_NakOnCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	msg := &fakeMsg{subject: "t.0.1", data: "x"}
+	process(ctx, "t.0.", msg, func(string, []byte) error { return errors.New("нет связи") })
+	if msg.acks != 0 || msg.naks != 1 {
+		t.Fatalf("cancelled message: acks=%d naks=%d", msg.acks, msg.naks)
+	}
+}
+
+func TestProcess_TermAfterMaxAttempts(t *testing.T) {
+	oldDelay, oldAttempts := mode.TransportRetryDelay, mode.TransportMaxAttempts
+	mode.TransportRetryDelay, mode.TransportMaxAttempts = time.Millisecond, 3
+	defer func() { mode.TransportRetryDelay, mode.TransportMaxAttempts = oldDelay, oldAttempts }()
+
+	calls := 0
+	msg := &fakeMsg{subject: "t.0.1", data: "x"}
+	process(context.Background(), "t.0.", msg, func(string, []byte) error {
+		calls++
+		return errors.New("ошибка открытия диалога")
+	})
+	if calls != 3 || msg.terms != 1 || msg.acks != 0 || msg.naks != 0 {
+		t.Fatalf("calls=%d terms=%d acks=%d naks=%d", calls, msg.terms, msg.acks, msg.naks)
+	}
+}
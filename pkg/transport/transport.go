@@ -0,0 +1,319 @@
+// Package transport переносит каналы model.Ch через брокер сообщений, чтобы фронтенды ботов
+// и воркеры моделей работали отдельными сервисами, общаясь только через брокер.
+//
+// Фронтенд (Client) публикует сообщения пользователя из RxCh в топик вопросов и раздаёт
+// ответы из топика ответов в TxCh своих диалогов. Воркер (Worker) читает вопросы, открывает
+// для диалога локальный Ch (например, запуская startpoint.Listener) и публикует его TxCh
+// в топик ответов. Ключ сообщения — диалог (Key), поэтому брокер сохраняет порядок внутри диалога.
+//
+// Конкретный брокер подключается через Broker: см. transport/kafka и transport/nats.
+package transport
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ikermy/AiR_Common/pkg/logger"
+	"github.com/ikermy/AiR_Common/pkg/mode"
+	"github.com/ikermy/AiR_Common/pkg/model"
+	"github.com/ikermy/AiR_Common/pkg/safego"
+)
+
+// Envelope сообщение Ch в брокере
+type Envelope struct {
+	UserID   uint32        `json:"user_id"`
+	DialogID uint64        `json:"dialog_id"`
+	RespName string        `json:"resp_name,omitempty"`
+	Message  model.Message `json:"message"`
+}
+
+// Key ключ диалога в брокере: "userID:dialogID"
+func Key(userID uint32, dialogID uint64) string {
+	return strconv.FormatUint(uint64(userID), 10) + ":" + strconv.FormatUint(dialogID, 10)
+}
+
+// ParseKey разбирает ключ Key
+func ParseKey(key string) (uint32, uint64, error) {
+	u, d, ok := strings.Cut(key, ":")
+	if !ok {
+		return 0, 0, fmt.Errorf("некорректный ключ диалога %q", key)
+	}
+	userID, err := strconv.ParseUint(u, 10, 32)
+	if err != nil {
+		return 0, 0, fmt.Errorf("некорректный userID в ключе %q: %w", key, err)
+	}
+	dialogID, err := strconv.ParseUint(d, 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("некорректный dialogID в ключе %q: %w", key, err)
+	}
+	return uint32(userID), dialogID, nil
+}
+
+// Handler обработчик сообщения брокера; ошибка — сообщение не подтверждается и будет доставлено повторно
+type Handler func(key string, data []byte) error
+
+// Broker клиент брокера сообщений
+type Broker interface {
+	// Publish публикует data в topic; сообщения с одинаковым key доставляются по порядку
+	Publish(ctx context.Context, topic, key string, data []byte) error
+	// Subscribe читает topic в группе group до отмены ctx (доставка at-least-once).
+	// Сообщения одной группы делятся между её участниками.
+	Subscribe(ctx context.Context, topic, group string, h Handler) error
+	Close() error
+}
+
+type config struct {
+	questions, answers string
+	group              string
+}
+
+// Option настройка Client и Worker
+type Option func(*config)
+
+// WithTopics задаёт топики вопросов и ответов (по умолчанию mode.TransportQuestions и mode.TransportAnswers)
+func WithTopics(questions, answers string) Option {
+	return func(c *config) {
+		c.questions, c.answers = questions, answers
+	}
+}
+
+// WithGroup задаёт группу подписки. Воркеры одного сервиса должны использовать общую группу
+// (по умолчанию mode.TransportWorkerGroup); у каждого Client своя группа, чтобы он видел все ответы
+// (по умолчанию mode.TransportClientGroup или "air-client-<hostname>"). Группа не меняется между
+// перезапусками, чтобы на брокере не копились брошенные консьюмеры.
+func WithGroup(group string) Option {
+	return func(c *config) {
+		c.group = group
+	}
+}
+
+func newConfig(group string, opts []Option) config {
+	c := config{questions: mode.TransportQuestions, answers: mode.TransportAnswers, group: group}
+	for _, opt := range opts {
+		opt(&c)
+	}
+	return c
+}
+
+// registry локальные каналы диалогов по Key
+type registry struct {
+	mu    sync.Mutex
+	chans map[string]*model.Ch
+}
+
+func (r *registry) get(key string) (*model.Ch, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ch, ok := r.chans[key]
+	return ch, ok
+}
+
+// add регистрирует ch, если для key ещё нет канала
+func (r *registry) add(key string, ch *model.Ch) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.chans == nil {
+		r.chans = make(map[string]*model.Ch)
+	}
+	if _, ok := r.chans[key]; ok {
+		return false
+	}
+	r.chans[key] = ch
+	return true
+}
+
+func (r *registry) remove(key string, ch *model.Ch) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.chans[key] == ch {
+		delete(r.chans, key)
+	}
+}
+
+// pump публикует сообщения src в topic, пока src не закрыт или ctx не отменён
+func pump(ctx context.Context, b Broker, topic string, ch *model.Ch, src <-chan model.Message) {
+	key := Key(ch.UserID, ch.DialogID)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-src:
+			if !ok {
+				return
+			}
+			data, err := json.Marshal(Envelope{UserID: ch.UserID, DialogID: ch.DialogID, RespName: ch.RespName, Message: msg})
+			if err != nil {
+				logger.ForDialog(ch.UserID, ch.DialogID).Error("transport: ошибка сериализации сообщения", "err", err)
+				continue
+			}
+			if err := b.Publish(ctx, topic, key, data); err != nil {
+				logger.ForDialog(ch.UserID, ch.DialogID).Error("transport: сообщение не опубликовано в "+topic, "err", err)
+			}
+		}
+	}
+}
+
+func decode(data []byte) (Envelope, error) {
+	var env Envelope
+	if err := json.Unmarshal(data, &env); err != nil {
+		return env, fmt.Errorf("ошибка разбора сообщения транспорта: %w", err)
+	}
+	return env, nil
+}
+
+// Client сторона фронтенда: RxCh диалогов → топик вопросов, топик ответов → TxCh
+type Client struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	b      Broker
+	cfg    config
+	reg    registry
+}
+
+// NewClient создаёт Client и подписывается на топик ответов
+func NewClient(parent context.Context, b Broker, opts ...Option) *Client {
+	ctx, cancel := context.WithCancel(parent)
+	c := &Client{ctx: ctx, cancel: cancel, b: b, cfg: newConfig(clientGroup(), opts)}
+	subscribe(ctx, b, c.cfg.answers, c.cfg.group, c.onAnswer)
+	return c
+}
+
+// Attach начинает передавать диалог через брокер: сообщения из ch.RxCh уходят воркерам,
+// их ответы приходят в ch.TxCh. Диалог отключается при закрытии RxCh.
+func (c *Client) Attach(ch *model.Ch) error {
+	key := Key(ch.UserID, ch.DialogID)
+	if !c.reg.add(key, ch) {
+		return fmt.Errorf("диалог %s уже подключён к транспорту", key)
+	}
+	safego.Go("transport.questions", func() {
+		defer c.reg.remove(key, ch)
+		pump(c.ctx, c.b, c.cfg.questions, ch, ch.RxCh)
+	}, safego.WithUserID(ch.UserID))
+	return nil
+}
+
+// onAnswer передаёт ответ в TxCh диалога; ответы чужих и закрытых диалогов пропускаются
+func (c *Client) onAnswer(key string, data []byte) error {
+	ch, ok := c.reg.get(key)
+	if !ok {
+		return nil
+	}
+	env, err := decode(data)
+	if err != nil {
+		logger.ForDialog(ch.UserID, ch.DialogID).Warn("transport: ответ пропущен", "err", err)
+		return nil
+	}
+	// Переполнение TxCh обрабатывает ch.OnOverflow: повторная доставка задержала бы остальные диалоги
+	if err := ch.SendToTxContext(c.ctx, env.Message); err != nil && ch.IsTxOpen() {
+		logger.ForDialog(ch.UserID, ch.DialogID).Warn("transport: ответ не передан в TxCh", "err", err)
+	}
+	return nil
+}
+
+// Close отключает все диалоги и подписку; брокер закрывает вызывающий
+func (c *Client) Close() {
+	c.cancel()
+}
+
+// Opener открывает локальный канал диалога на стороне воркера по первому сообщению.
+// Обычно создаёт model.Ch и запускает для него startpoint.Listener.
+type Opener func(env Envelope) (*model.Ch, error)
+
+// Worker сторона воркера модели: топик вопросов → RxCh, TxCh → топик ответов
+type Worker struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	b      Broker
+	cfg    config
+	open   Opener
+	reg    registry
+	mu     sync.Mutex // сериализует открытие диалогов
+}
+
+// NewWorker создаёт Worker и подписывается на топик вопросов
+func NewWorker(parent context.Context, b Broker, open Opener, opts ...Option) *Worker {
+	ctx, cancel := context.WithCancel(parent)
+	w := &Worker{ctx: ctx, cancel: cancel, b: b, cfg: newConfig(mode.TransportWorkerGroup, opts), open: open}
+	subscribe(ctx, b, w.cfg.questions, w.cfg.group, w.onQuestion)
+	return w
+}
+
+// onQuestion передаёт вопрос в RxCh диалога, открывая его при необходимости.
+// Ошибка записи в RxCh возвращается брокеру для повторной доставки.
+func (w *Worker) onQuestion(key string, data []byte) error {
+	env, err := decode(data)
+	if err != nil {
+		logger.Warn("transport: вопрос %s пропущен: %v", key, err)
+		return nil
+	}
+	ch, err := w.channel(key, env)
+	if err != nil {
+		return err
+	}
+	return ch.SendToRx(env.Message)
+}
+
+func (w *Worker) channel(key string, env Envelope) (*model.Ch, error) {
+	if ch, ok := w.reg.get(key); ok && ch.IsRxOpen() {
+		return ch, nil
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if ch, ok := w.reg.get(key); ok {
+		if ch.IsRxOpen() {
+			return ch, nil
+		}
+		w.reg.remove(key, ch)
+	}
+
+	ch, err := w.open(env)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка открытия диалога %s: %w", key, err)
+	}
+	w.reg.add(key, ch)
+	safego.Go("transport.answers", func() {
+		defer w.reg.remove(key, ch)
+		pump(w.ctx, w.b, w.cfg.answers, ch, ch.TxCh)
+	}, safego.WithUserID(ch.UserID))
+	return ch, nil
+}
+
+// Close прекращает чтение вопросов и публикацию ответов; брокер закрывает вызывающий
+func (w *Worker) Close() {
+	w.cancel()
+}
+
+// subscribe читает topic до отмены ctx. Прерванная ошибкой или паникой подписка
+// возобновляется через mode.TransportRetryDelay
+func subscribe(ctx context.Context, b Broker, topic, group string, h Handler) {
+	delay := mode.TransportRetryDelay
+	safego.Go("transport.subscribe", func() {
+		for ctx.Err() == nil {
+			if err := b.Subscribe(ctx, topic, group, h); err != nil && ctx.Err() == nil {
+				logger.Error("transport: подписка на %s прервана: %v", topic, err)
+			}
+			select {
+			case <-ctx.Done():
+			case <-time.After(delay):
+			}
+		}
+	}, safego.WithRestart(-1, delay), safego.WithDone(ctx.Done()))
+}
+
+// clientGroup группа Client по умолчанию
+func clientGroup() string {
+	if mode.TransportClientGroup != "" {
+		return mode.TransportClientGroup
+	}
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		host = "local"
+	}
+	return "air-client-" + host
+}
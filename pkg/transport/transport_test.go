@@ -0,0 +1,211 @@
+package transport
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ikermy/AiR_Common/pkg/mode"
+	"github.com/ikermy/AiR_Common/pkg/model"
+)
+
+// memBroker брокер в памяти: каждое сообщение получает по одному подписчику каждой группы
+type memBroker struct {
+	mu   sync.Mutex
+	subs map[string]map[string]chan [2]string // topic -> group -> очередь
+}
+
+func (b *memBroker) queue(topic, group string) chan [2]string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.subs == nil {
+		b.subs = make(map[string]map[string]chan [2]string)
+	}
+	if b.subs[topic] == nil {
+		b.subs[topic] = make(map[string]chan [2]string)
+	}
+	q, ok := b.subs[topic][group]
+	if !ok {
+		q = make(chan [2]string, 64)
+		b.subs[topic][group] = q
+	}
+	return q
+}
+
+func (b *memBroker) Publish(_ context.Context, topic, key string, data []byte) error {
+	b.mu.Lock()
+	groups := b.subs[topic]
+	b.mu.Unlock()
+	for _, q := range groups {
+		q <- [2]string{key, string(data)}
+	}
+	return nil
+}
+
+func (b *memBroker) Subscribe(ctx context.Context, topic, group string, h Handler) error {
+	q := b.queue(topic, group)
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case m := <-q:
+			for h(m[0], []byte(m[1])) != nil {
+				time.Sleep(10 * time.Millisecond)
+			}
+		}
+	}
+}
+
+func (b *memBroker) Close() error { return nil }
+
+func (b *memBroker) waitGroups(t *testing.T, topic string, n int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		b.mu.Lock()
+		got := len(b.subs[topic])
+		b.mu.Unlock()
+		if got >= n {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("no subscribers on %s", topic)
+}
+
+func newCh(userID uint32, dialogID uint64) *model.Ch {
+	return &model.Ch{TxCh: make(chan model.Message, 4), RxCh: make(chan model.Message, 4), UserID: userID, DialogID: dialogID}
+}
+
+func TestClientWorker_RoundTrip(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	b := &memBroker{}
+
+	var mu sync.Mutex
+	opened := 0
+	worker := NewWorker(ctx, b, func(env Envelope) (*model.Ch, error) {
+		mu.Lock()
+		opened++
+		mu.Unlock()
+		ch := newCh(env.UserID, env.DialogID)
+		go func() { // эхо-Listener
+			for msg := range ch.RxCh {
+				_ = ch.SendToTx(model.Message{Type: "assist", Content: model.AssistResponse{Message: "re: " + msg.Content.Message}})
+			}
+		}()
+		return ch, nil
+	})
+	defer worker.Close()
+	client := NewClient(ctx, b)
+	defer client.Close()
+	b.waitGroups(t, mode.TransportQuestions, 1)
+	b.waitGroups(t, mode.TransportAnswers, 1)
+
+	front := newCh(1, 7)
+	if err := client.Attach(front); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Attach(front); err == nil {
+		t.Fatalf("second Attach must fail")
+	}
+	other := newCh(1, 8)
+	_ = client.Attach(other)
+
+	for _, text := range []string{"один", "два"} {
+		if err := front.SendToRx(model.Message{Type: "user", Content: model.AssistResponse{Message: text}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, want := range []string{"re: один", "re: два"} {
+		select {
+		case msg := <-front.TxCh:
+			if msg.Content.Message != want {
+				t.Fatalf("got %q, want %q", msg.Content.Message, want)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("no answer %q", want)
+		}
+	}
+	select {
+	case msg := <-other.TxCh:
+		t.Fatalf("answer leaked to another dialog: %+v", msg)
+	default:
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if opened != 1 {
+		t.Fatalf("dialog opened %d times", opened)
+	}
+}
+
+func TestKey(t *testing.T) {
+	u, d, err := ParseKey(Key(42, 1<<40))
+	if err != nil || u != 42 || d != 1<<40 {
+		t.Fatalf("ParseKey: %d %d %v", u, d, err)
+	}
+	if _, _, err := ParseKey("42"); err == nil {
+		t.Fatalf("expected error")
+	}
+}
+
+// flakyBroker — Broker, подписка которого сначала падает с паникой, затем с ошибкой
+type flakyBroker struct {
+	memBroker
+	mu    sync.Mutex
+	calls int
+}
+
+func (b *flakyBroker) Subscribe(ctx context.Context, topic, group string, h Handler) error {
+	b.mu.Lock()
+	b.calls++
+	call := b.calls
+	b.mu.Unlock()
+	switch call {
+	case 1:
+		panic("сбой клиента брокера")
+	case 2:
+		return errors.New("соединение потеряно")
+	}
+	return b.memBroker.Subscribe(ctx, topic, group, h)
+}
+
+func TestSubscribe_Resumes(t *testing.T) {
+	oldDelay := mode.TransportRetryDelay
+	mode.TransportRetryDelay = time.Millisecond
+	defer func() { mode.TransportRetryDelay = oldDelay }()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	b := &flakyBroker{}
+	got := make(chan string, 1)
+	subscribe(ctx, b, "topic", "group", func(key string, _ []byte) error {
+		got <- key
+		return nil
+	})
+	b.waitGroups(t, "topic", 1)
+	_ = b.Publish(ctx, "topic", "1:2", nil)
+	select {
+	case key := <-got:
+		if key != "1:2" {
+			t.Fatalf("unexpected key %q", key)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("subscription not resumed after panic and error")
+	}
+}
+
+func TestClientGroup_Stable(t *testing.T) {
+	if g := clientGroup(); g != clientGroup() || !strings.HasPrefix(g, "air-client-") {
+		t.Fatalf("unexpected client group %q", g)
+	}
+	old := mode.TransportClientGroup
+	mode.TransportClientGroup = "front-1"
+	defer func() { mode.TransportClientGroup = old }()
+	if g := clientGroup(); g != "front-1" {
+		t.Fatalf("configured group ignored: %q", g)
+	}
+}
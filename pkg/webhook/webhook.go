@@ -0,0 +1,399 @@
+// Package webhook рассылает события диалогов на внешние адреса (CRM и т.п.).
+//
+// Каждая доставка подписывается HMAC-SHA256 секретом подписки, повторяется с
+// экспоненциальной задержкой, а после исчерпания попыток сохраняется в dead-letter.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ikermy/AiR_Common/pkg/comdb"
+	"github.com/ikermy/AiR_Common/pkg/events"
+	"github.com/ikermy/AiR_Common/pkg/mode"
+	"github.com/ikermy/AiR_Common/pkg/safego"
+)
+
+// Типы событий
+const (
+	EventDialogStarted     = "dialog.started"
+	EventDialogEnded       = "dialog.ended"
+	EventTargetReached     = "target.reached"
+	EventOperatorRequested = "operator.requested"
+	EventError             = "error"
+	EventAlert             = "alert"              // критическая ошибка модели, диалог завершён
+	EventAssistantSwitched = "assistant.switched" // диалог передан другому ассистенту: Data["from"], Data["to"] — AssistId
+	EventCSATRequested     = "csat.requested"     // пользователю отправлен опрос удовлетворённости: Data["reason"], Data["delivered"], Data["survey"]
+	EventCSATRated         = "csat.rated"         // пользователь оценил диалог: Data["score"], Data["scale"], Data["comment"]
+)
+
+// Заголовки запроса
+const (
+	HeaderEvent     = "X-AiR-Event"
+	HeaderID        = "X-AiR-Delivery"
+	HeaderTimestamp = "X-AiR-Timestamp"
+	HeaderSignature = "X-AiR-Signature" // "sha256=" + hex(HMAC(secret, timestamp + "." + body))
+)
+
+// DeadLetterReason причина dead-letter для недоставленного события
+const DeadLetterReason = "webhook"
+
+// Event событие диалога
+type Event struct {
+	ID         string         `json:"id"`
+	Type       string         `json:"type"`
+	UserID     uint32         `json:"user_id"`
+	DialogID   uint64         `json:"dialog_id,omitempty"`
+	RespID     uint64         `json:"resp_id,omitempty"`
+	RespName   string         `json:"resp_name,omitempty"`
+	AssistName string         `json:"assist_name,omitempty"`
+	Data       map[string]any `json:"data,omitempty"`
+	Time       time.Time      `json:"time"`
+}
+
+// Subscription адрес, на который пользователь получает события
+type Subscription struct {
+	ID     string   `json:"id"`
+	UserID uint32   `json:"user_id"`
+	URL    string   `json:"url"`
+	Secret string   `json:"secret,omitempty"`
+	Events []string `json:"events,omitempty"` // пусто — все события
+}
+
+func (s Subscription) wants(event string) bool {
+	if len(s.Events) == 0 {
+		return true
+	}
+	for _, e := range s.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// DeadLetterStore — хранилище недоставленных событий (реализуется *comdb.DB)
+type DeadLetterStore interface {
+	SaveDeadLetter(dl comdb.DeadLetter) (uint64, error)
+}
+
+// Option настройка Dispatcher
+type Option func(*Dispatcher)
+
+// WithDeadLetters сохраняет события, не доставленные за все попытки
+func WithDeadLetters(store DeadLetterStore) Option {
+	return func(d *Dispatcher) { d.dlq = store }
+}
+
+// WithHTTPClient заменяет HTTP-клиент (по умолчанию с тайм-аутом mode.WebhookTimeout)
+func WithHTTPClient(c *http.Client) Option {
+	return func(d *Dispatcher) { d.client = c }
+}
+
+type delivery struct {
+	sub   Subscription
+	event Event
+	body  []byte
+}
+
+// Dispatcher очередь доставки событий с фиксированным числом воркеров
+type Dispatcher struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	client *http.Client
+	dlq    DeadLetterStore
+	queue  chan delivery
+	wg     sync.WaitGroup
+
+	maxAttempts int
+	retryDelay  time.Duration
+
+	mu   sync.RWMutex
+	subs map[uint32][]Subscription
+}
+
+// New создаёт Dispatcher и запускает mode.WebhookWorkers воркеров
+func New(parent context.Context, opts ...Option) *Dispatcher {
+	ctx, cancel := context.WithCancel(parent)
+	d := &Dispatcher{
+		ctx:         ctx,
+		cancel:      cancel,
+		client:      &http.Client{Timeout: mode.WebhookTimeout},
+		queue:       make(chan delivery, mode.WebhookQueueSize),
+		maxAttempts: mode.WebhookMaxAttempts,
+		retryDelay:  mode.WebhookRetryDelay,
+		subs:        make(map[uint32][]Subscription),
+	}
+	for _, opt := range opts {
+		opt(d)
+	}
+	for i := 0; i < max(mode.WebhookWorkers, 1); i++ {
+		d.wg.Add(1)
+		safego.Go("webhook.worker", func() {
+			defer d.wg.Done()
+			d.work()
+		})
+	}
+	return d
+}
+
+// Close останавливает воркеры. Недоставленные события из очереди попадают в dead-letter.
+func (d *Dispatcher) Close() {
+	d.cancel()
+	d.wg.Wait()
+	for {
+		select {
+		case dl := <-d.queue:
+			d.deadLetter(dl, fmt.Errorf("dispatcher остановлен"))
+		default:
+			return
+		}
+	}
+}
+
+// Subscribe добавляет подписку или заменяет подписку с тем же ID
+func (d *Dispatcher) Subscribe(s Subscription) error {
+	if s.URL == "" {
+		return fmt.Errorf("пустой адрес подписки")
+	}
+	if s.ID == "" {
+		s.ID = newID()
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	list := d.subs[s.UserID]
+	for i := range list {
+		if list[i].ID == s.ID {
+			list[i] = s
+			return nil
+		}
+	}
+	d.subs[s.UserID] = append(list, s)
+	return nil
+}
+
+// Unsubscribe удаляет подписку пользователя
+func (d *Dispatcher) Unsubscribe(userID uint32, id string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	list := d.subs[userID]
+	for i := range list {
+		if list[i].ID == id {
+			d.subs[userID] = append(list[:i:i], list[i+1:]...)
+			return
+		}
+	}
+}
+
+// Subscriptions возвращает подписки пользователя
+func (d *Dispatcher) Subscriptions(userID uint32) []Subscription {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return append([]Subscription(nil), d.subs[userID]...)
+}
+
+// Dispatch ставит событие в очередь для всех подписок пользователя и не блокирует вызывающего.
+// При переполненной очереди событие сразу уходит в dead-letter.
+func (d *Dispatcher) Dispatch(e Event) {
+	if e.ID == "" {
+		e.ID = newID()
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+
+	d.mu.RLock()
+	subs := d.subs[e.UserID]
+	d.mu.RUnlock()
+
+	var body []byte
+	for _, sub := range subs {
+		if !sub.wants(e.Type) {
+			continue
+		}
+		if body == nil {
+			var err error
+			if body, err = json.Marshal(e); err != nil {
+				//logger.Error("webhook: ошибка сериализации события %s: %v", e.Type, err)
+				return
+			}
+		}
+		dl := delivery{sub: sub, event: e, body: body}
+		select {
+		case d.queue <- dl:
+		default:
+			d.deadLetter(dl, fmt.Errorf("очередь доставки переполнена"))
+		}
+	}
+}
+
+// Handle подписчик events.Bus: ставит в очередь события жизненного цикла диалога,
+// Notify и Meta пропускает
+func (d *Dispatcher) Handle(e events.Event) {
+	switch e.Type {
+	case events.DialogStarted, events.DialogEnded, events.TargetReached, events.OperatorRequested, events.Error, events.Alert,
+		events.AssistantSwitched, events.CSATRequested, events.CSATRated:
+	default:
+		return
+	}
+	d.Dispatch(Event{
+		Type:       string(e.Type),
+		UserID:     e.UserID,
+		DialogID:   e.DialogID,
+		RespID:     e.RespID,
+		RespName:   e.RespName,
+		AssistName: e.AssistName,
+		Data:       e.Data,
+		Time:       e.Time,
+	})
+}
+
+func (d *Dispatcher) work() {
+	for {
+		select {
+		case <-d.ctx.Done():
+			return
+		case dl := <-d.queue:
+			d.deliver(dl)
+		}
+	}
+}
+
+// deliver отправляет событие с повторами; 4xx, кроме 408 и 429, не повторяется
+func (d *Dispatcher) deliver(dl delivery) {
+	delay := d.retryDelay
+	var err error
+	for attempt := 1; attempt <= d.maxAttempts; attempt++ {
+		var retry bool
+		if retry, err = d.post(dl); err == nil {
+			return
+		}
+		if !retry || attempt == d.maxAttempts {
+			break
+		}
+		select {
+		case <-time.After(delay):
+			delay *= 2
+		case <-d.ctx.Done():
+			d.deadLetter(dl, err)
+			return
+		}
+	}
+	d.deadLetter(dl, err)
+}
+
+func (d *Dispatcher) post(dl delivery) (retry bool, err error) {
+	req, err := http.NewRequestWithContext(d.ctx, http.MethodPost, dl.sub.URL, bytes.NewReader(dl.body))
+	if err != nil {
+		return false, fmt.Errorf("ошибка создания запроса: %w", err)
+	}
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEvent, dl.event.Type)
+	req.Header.Set(HeaderID, dl.event.ID)
+	req.Header.Set(HeaderTimestamp, ts)
+	if dl.sub.Secret != "" {
+		req.Header.Set(HeaderSignature, Sign(dl.sub.Secret, ts, dl.body))
+	}
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return true, fmt.Errorf("ошибка POST %s: %w", dl.sub.URL, err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	retry = resp.StatusCode >= 500 || resp.StatusCode == http.StatusRequestTimeout || resp.StatusCode == http.StatusTooManyRequests
+	return retry, fmt.Errorf("POST %s: %s", dl.sub.URL, resp.Status)
+}
+
+func (d *Dispatcher) deadLetter(dl delivery, cause error) {
+	if d.dlq == nil {
+		//logger.Warn("webhook: событие %s не доставлено на %s: %v", dl.event.Type, dl.sub.URL, cause)
+		return
+	}
+	payload, err := json.Marshal(struct {
+		Subscription string          `json:"subscription"`
+		URL          string          `json:"url"`
+		Event        json.RawMessage `json:"event"`
+	}{dl.sub.ID, dl.sub.URL, dl.body})
+	if err != nil {
+		return
+	}
+	dialogID := dl.event.DialogID
+	if dialogID == 0 {
+		dialogID = dl.event.RespID // SaveDeadLetter требует непустой dialog_id
+	}
+	if dialogID == 0 {
+		return
+	}
+	if _, err := d.dlq.SaveDeadLetter(comdb.DeadLetter{
+		UserID:   dl.event.UserID,
+		DialogID: dialogID,
+		RespID:   dl.event.RespID,
+		Reason:   DeadLetterReason,
+		Error:    cause.Error(),
+		Payload:  payload,
+	}); err != nil {
+		//logger.Error("webhook: ошибка сохранения dead-letter: %v", err)
+	}
+}
+
+// Redeliver повторно ставит в очередь событие из dead-letter (Reason == DeadLetterReason).
+// Подписка должна существовать: доставка идёт на её текущий адрес и с текущим секретом.
+func (d *Dispatcher) Redeliver(dl comdb.DeadLetter) error {
+	if dl.Reason != DeadLetterReason {
+		return fmt.Errorf("dead letter %d не относится к webhook", dl.ID)
+	}
+	var p struct {
+		Subscription string          `json:"subscription"`
+		Event        json.RawMessage `json:"event"`
+	}
+	if err := json.Unmarshal(dl.Payload, &p); err != nil {
+		return fmt.Errorf("ошибка разбора dead letter %d: %w", dl.ID, err)
+	}
+	var e Event
+	if err := json.Unmarshal(p.Event, &e); err != nil {
+		return fmt.Errorf("ошибка разбора события dead letter %d: %w", dl.ID, err)
+	}
+
+	for _, sub := range d.Subscriptions(dl.UserID) {
+		if sub.ID != p.Subscription {
+			continue
+		}
+		select {
+		case d.queue <- delivery{sub: sub, event: e, body: p.Event}:
+			return nil
+		default:
+			return fmt.Errorf("очередь доставки переполнена")
+		}
+	}
+	return fmt.Errorf("подписка %s пользователя %d не найдена", p.Subscription, dl.UserID)
+}
+
+// Sign подпись тела запроса: "sha256=" + hex(HMAC-SHA256(secret, timestamp + "." + body)).
+// Получатель сверяет её с заголовком HeaderSignature.
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func newID() string {
+	b := make([]byte, 12)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,189 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ikermy/AiR_Common/pkg/comdb"
+	"github.com/ikermy/AiR_Common/pkg/events"
+	"github.com/ikermy/AiR_Common/pkg/mode"
+)
+
+type memDeadLetters struct {
+	mu  sync.Mutex
+	dls []comdb.DeadLetter
+}
+
+func (m *memDeadLetters) SaveDeadLetter(dl comdb.DeadLetter) (uint64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.dls = append(m.dls, dl)
+	return uint64(len(m.dls)), nil
+}
+
+func (m *memDeadLetters) list() []comdb.DeadLetter {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]comdb.DeadLetter(nil), m.dls...)
+}
+
+func fastRetries(t *testing.T) {
+	prevDelay, prevAttempts := mode.WebhookRetryDelay, mode.WebhookMaxAttempts
+	mode.WebhookRetryDelay, mode.WebhookMaxAttempts = time.Millisecond, 3
+	t.Cleanup(func() { mode.WebhookRetryDelay, mode.WebhookMaxAttempts = prevDelay, prevAttempts })
+}
+
+func TestDispatch_SignedWithRetries(t *testing.T) {
+	fastRetries(t)
+	var calls atomic.Int32
+	got := make(chan *http.Request, 1)
+	bodies := make(chan []byte, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) < 3 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		got <- r
+		bodies <- body
+	}))
+	defer srv.Close()
+
+	d := New(context.Background())
+	defer d.Close()
+	_ = d.Subscribe(Subscription{UserID: 1, URL: srv.URL, Secret: "s3cret", Events: []string{EventTargetReached}})
+
+	d.Dispatch(Event{Type: EventDialogStarted, UserID: 1, DialogID: 7}) // не подписан
+	d.Dispatch(Event{Type: EventTargetReached, UserID: 1, DialogID: 7})
+
+	select {
+	case r := <-got:
+		body := <-bodies
+		if r.Header.Get(HeaderEvent) != EventTargetReached {
+			t.Fatalf("event header: %q", r.Header.Get(HeaderEvent))
+		}
+		if want := Sign("s3cret", r.Header.Get(HeaderTimestamp), body); r.Header.Get(HeaderSignature) != want {
+			t.Fatalf("signature %q, want %q", r.Header.Get(HeaderSignature), want)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("событие не доставлено")
+	}
+	time.Sleep(20 * time.Millisecond)
+	if n := calls.Load(); n != 3 {
+		t.Fatalf("calls = %d, want 3 (2 повтора, неподписанное событие не отправляется)", n)
+	}
+}
+
+func TestDispatch_DeadLetterAndRedeliver(t *testing.T) {
+	fastRetries(t)
+	var fail atomic.Bool
+	fail.Store(true)
+	delivered := make(chan struct{}, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fail.Load() {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		delivered <- struct{}{}
+	}))
+	defer srv.Close()
+
+	dlq := &memDeadLetters{}
+	d := New(context.Background(), WithDeadLetters(dlq))
+	defer d.Close()
+	_ = d.Subscribe(Subscription{ID: "crm", UserID: 1, URL: srv.URL})
+	d.Dispatch(Event{Type: EventError, UserID: 1, DialogID: 7, Data: map[string]any{"error": "boom"}})
+
+	deadline := time.Now().Add(2 * time.Second)
+	for len(dlq.list()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	dls := dlq.list()
+	if len(dls) != 1 || dls[0].Reason != DeadLetterReason || dls[0].DialogID != 7 {
+		t.Fatalf("dead letters: %+v", dls)
+	}
+
+	fail.Store(false)
+	if err := d.Redeliver(dls[0]); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-delivered:
+	case <-time.After(2 * time.Second):
+		t.Fatal("повторная доставка не состоялась")
+	}
+
+	d.Unsubscribe(1, "crm")
+	if err := d.Redeliver(dls[0]); err == nil {
+		t.Fatal("без подписки ожидали ошибку")
+	}
+}
+
+func TestHandle_AssistantSwitched(t *testing.T) {
+	bodies := make(chan []byte, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if r.Header.Get(HeaderEvent) == EventAssistantSwitched {
+			bodies <- body
+		}
+	}))
+	defer srv.Close()
+
+	d := New(context.Background())
+	defer d.Close()
+	_ = d.Subscribe(Subscription{UserID: 1, URL: srv.URL, Events: []string{EventAssistantSwitched}})
+
+	d.Handle(events.Event{Type: events.AssistantSwitched, UserID: 1, DialogID: 7, Data: map[string]any{"from": "asst_a", "to": "asst_b"}})
+
+	select {
+	case body := <-bodies:
+		var e Event
+		if err := json.Unmarshal(body, &e); err != nil {
+			t.Fatal(err)
+		}
+		if e.Type != EventAssistantSwitched || e.DialogID != 7 || e.Data["from"] != "asst_a" || e.Data["to"] != "asst_b" {
+			t.Fatalf("payload: %+v", e)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("assistant.switched не доставлено")
+	}
+}
+
+func TestHandle_CSATEvents(t *testing.T) {
+	got := make(chan Event, 2)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var e Event
+		body, _ := io.ReadAll(r.Body)
+		if err := json.Unmarshal(body, &e); err == nil {
+			got <- e
+		}
+	}))
+	defer srv.Close()
+
+	d := New(context.Background())
+	defer d.Close()
+	_ = d.Subscribe(Subscription{UserID: 1, URL: srv.URL, Events: []string{EventCSATRequested, EventCSATRated}})
+
+	d.Handle(events.Event{Type: events.CSATRequested, UserID: 1, DialogID: 7, Data: map[string]any{"reason": "ended"}})
+	d.Handle(events.Event{Type: events.CSATRated, UserID: 1, DialogID: 7, Data: map[string]any{"score": 5}})
+
+	seen := map[string]Event{}
+	for range 2 {
+		select {
+		case e := <-got:
+			seen[e.Type] = e
+		case <-time.After(2 * time.Second):
+			t.Fatalf("CSAT события не доставлены: %v", seen)
+		}
+	}
+	if seen[EventCSATRequested].Data["reason"] != "ended" || seen[EventCSATRated].Data["score"] != float64(5) {
+		t.Fatalf("payloads: %+v", seen)
+	}
+}